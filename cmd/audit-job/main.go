@@ -0,0 +1,310 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command audit-job reviews a scope against a policy library as a Cloud Run job or Batch job,
+// turning audits of an organization into a scheduled job without servers to run. Each task of
+// the job exports the scope with the Cloud Asset API, or reads an existing export, and reviews the
+// shard of the assets given by its task index. The report of each task is written as JSON to
+// resultsPrefix, for gcv merge to combine, and the violations are streamed into BigQuery.
+//
+// Flags default to environment variables, so the job is configured in its definition:
+//
+//	GCV_SCOPE            scope to export, organizations/<number>, folders/<number> or projects/<id>
+//	GCV_EXPORT_PREFIX    gs:// prefix the tasks export the scope to
+//	GCV_EXPORT_URIS      gs:// objects or prefixes of an existing export, instead of exporting
+//	GCV_ASSET_TYPES      asset types to export, all types if unset
+//	GCV_CONTENT_TYPES    content types to export, RESOURCE and IAM_POLICY if unset
+//	POLICY_PATH          policies to review with, local or gs:// paths
+//	POLICY_LIBRARY_PATH  policy library of POLICY_PATH
+//	GCV_RESULTS_PREFIX   gs:// or local prefix the reports of the tasks are written below
+//	GCV_BIGQUERY_TABLE   project.dataset.table BigQuery table violations are written to
+//	GCV_POST_RUN_HOOK    shell command each task runs with the summary JSON of its shard on stdin
+//	GCV_HOOK_TIMEOUT     maximum run time of GCV_POST_RUN_HOOK, 1m if unset
+//
+// The task index and count are read from CLOUD_RUN_TASK_INDEX and CLOUD_RUN_TASK_COUNT, or
+// BATCH_TASK_INDEX and BATCH_TASK_COUNT, and the run ID shared by the tasks from
+// CLOUD_RUN_EXECUTION or BATCH_JOB_UID.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/forseti-security/config-validator/pkg/asset"
+	"github.com/forseti-security/config-validator/pkg/cai"
+	"github.com/forseti-security/config-validator/pkg/gcv"
+	"github.com/forseti-security/config-validator/pkg/gcv/configs"
+	"github.com/forseti-security/config-validator/pkg/hooks"
+	"github.com/forseti-security/config-validator/pkg/logging"
+	"github.com/forseti-security/config-validator/pkg/report"
+	"github.com/forseti-security/config-validator/pkg/shard"
+	"github.com/forseti-security/config-validator/pkg/sink"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	bigquery "google.golang.org/api/bigquery/v2"
+	cloudasset "google.golang.org/api/cloudasset/v1"
+	storage "google.golang.org/api/storage/v1"
+)
+
+// sinkBatchSize is the number of results with violations buffered before they are written to
+// BigQuery.
+const sinkBatchSize = 100
+
+var (
+	scope             = flag.String("scope", os.Getenv("GCV_SCOPE"), "Scope to export, one of organizations/<number>, folders/<number> or projects/<id or number>")
+	exportPrefix      = flag.String("exportPrefix", os.Getenv("GCV_EXPORT_PREFIX"), "gs://bucket/path prefix each task exports the scope below, in <run ID>/task-<index>")
+	exportURIs        = flag.String("exportURIs", os.Getenv("GCV_EXPORT_URIS"), "Comma separated gs:// objects or prefixes of an existing export to review instead of exporting scope")
+	assetTypes        = flag.String("assetTypes", os.Getenv("GCV_ASSET_TYPES"), "Comma separated asset types to export, all types if empty")
+	contentTypes      = flag.String("contentTypes", os.Getenv("GCV_CONTENT_TYPES"), "Comma separated content types to export, defaults to RESOURCE,IAM_POLICY")
+	policyPath        = flag.String("policyPath", os.Getenv("POLICY_PATH"), "Comma separated local or gs:// paths of the policies to review with")
+	policyLibraryPath = flag.String("policyLibraryPath", os.Getenv("POLICY_LIBRARY_PATH"), "Policy library of policyPath")
+	resultsPrefix     = flag.String("resultsPrefix", os.Getenv("GCV_RESULTS_PREFIX"), "gs://bucket/path or local prefix the JSON report of each task is written below, in <run ID>/report-<index>-of-<count>.json")
+	bigqueryTable     = flag.String("bigqueryTable", os.Getenv("GCV_BIGQUERY_TABLE"), "BigQuery table violations are written to, in project.dataset.table form")
+	postRunHook       = flag.String("postRunHook", os.Getenv("GCV_POST_RUN_HOOK"), "Shell command run after the shard is reviewed, with the run summary JSON on stdin and GCV_* run metadata in the environment")
+	hookTimeout       = flag.String("postRunHookTimeout", os.Getenv("GCV_HOOK_TIMEOUT"), "Maximum run time of postRunHook, such as 30s or 0 for no limit, defaults to 1m")
+	logFormat         = flag.String("logFormat", logging.JSON, "Log format, text or json for one Cloud Logging structured entry per line")
+	logLevel          = flag.String("logLevel", "info", "Minimum level of logged lines, one of debug, info, warn, error")
+)
+
+// firstEnv returns the value of the first of the environment variables that is set.
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// taskShard returns the shard of the task from the environment of Cloud Run and Batch jobs, the
+// whole scope outside of jobs.
+func taskShard() (shard.Spec, error) {
+	index := firstEnv("CLOUD_RUN_TASK_INDEX", "BATCH_TASK_INDEX")
+	count := firstEnv("CLOUD_RUN_TASK_COUNT", "BATCH_TASK_COUNT")
+	if count == "" {
+		if index != "" && index != "0" {
+			return shard.Spec{}, errors.Errorf("task index %s is set without a task count", index)
+		}
+		return shard.Spec{}, nil
+	}
+	if index == "" {
+		index = "0"
+	}
+	return shard.Parse(index + "/" + count)
+}
+
+// splitList splits a comma separated list, dropping empty items.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// job reviews the shard of a task.
+type job struct {
+	validator *gcv.Validator
+	shard     shard.Spec
+	report    *report.Report
+	sink      sink.Sink
+	// pending are the results with violations not yet written to sink.
+	pending []*gcv.Result
+}
+
+// reviewFile reviews the records of the shard in the export at uri, a gs:// object or prefix.
+func (j *job) reviewFile(ctx context.Context, uri string) error {
+	path, err := configs.NewPath(uri)
+	if err != nil {
+		return err
+	}
+	return path.Walk(ctx, func(name string, r io.Reader) error {
+		reader := shard.NewReader(asset.NewReader(r, name), j.shard)
+		for {
+			record, err := reader.Next()
+			if err == io.EOF {
+				return nil
+			}
+			if decodeErr, ok := err.(*asset.DecodeError); ok {
+				logging.FromContext(ctx).Error("failed to decode asset", zap.Stringer("source", decodeErr.Source), zap.Error(decodeErr.Err))
+				j.report.AddError()
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			result, err := j.validator.ReviewRecord(ctx, record)
+			if errors.Cause(err) == gcv.ErrUnknownAncestry {
+				// Reviewing the remaining assets would silently miss the violations of the constraints.
+				return errors.Wrapf(err, "%s", record.Source)
+			}
+			if err != nil {
+				name, _ := record.Asset["name"].(string)
+				logging.FromContext(ctx).Error("failed to review asset",
+					zap.String(logging.AssetKey, name), zap.Stringer("source", record.Source), zap.Error(err))
+				j.report.AddError()
+				continue
+			}
+			j.report.Add(result)
+			if err := j.write(ctx, result); err != nil {
+				return err
+			}
+		}
+	})
+}
+
+// write buffers result for the sink, writing the buffered results once there are sinkBatchSize.
+// A nil result writes the buffered results.
+func (j *job) write(ctx context.Context, result *gcv.Result) error {
+	if j.sink == nil {
+		return nil
+	}
+	if result != nil && len(result.ConstraintViolations) != 0 {
+		j.pending = append(j.pending, result)
+	}
+	if len(j.pending) == 0 || (result != nil && len(j.pending) < sinkBatchSize) {
+		return nil
+	}
+	if err := j.sink.Write(ctx, j.pending); err != nil {
+		return err
+	}
+	j.pending = nil
+	return nil
+}
+
+func run(ctx context.Context) error {
+	spec, err := taskShard()
+	if err != nil {
+		return err
+	}
+	runID := firstEnv("CLOUD_RUN_EXECUTION", "BATCH_JOB_UID")
+	if runID == "" {
+		runID = logging.NewRunID()
+	}
+	ctx = logging.WithFields(logging.WithRunID(ctx, runID), zap.Stringer("shard", spec))
+	if *policyPath == "" {
+		return errors.New("policyPath is required")
+	}
+	if *resultsPrefix == "" && *bigqueryTable == "" {
+		return errors.New("resultsPrefix or bigqueryTable is required, the results would be lost")
+	}
+	timeout := time.Minute
+	if *hookTimeout != "" {
+		if timeout, err = time.ParseDuration(*hookTimeout); err != nil {
+			return errors.Wrapf(err, "invalid postRunHookTimeout")
+		}
+	}
+
+	v, err := gcv.NewValidator(gcv.WithPolicyPaths(splitList(*policyPath)...), gcv.WithPolicyLibrary(*policyLibraryPath))
+	if err != nil {
+		return err
+	}
+	j := &job{validator: v, shard: spec, report: report.New(v)}
+	if *bigqueryTable != "" {
+		parts := strings.Split(*bigqueryTable, ".")
+		if len(parts) != 3 {
+			return errors.Errorf("invalid bigqueryTable %q, want project.dataset.table", *bigqueryTable)
+		}
+		service, err := bigquery.NewService(ctx)
+		if err != nil {
+			return err
+		}
+		if j.sink, err = sink.NewBigQuery(service, sink.BigQueryOptions{
+			Project: parts[0], Dataset: parts[1], Table: parts[2], RunID: runID,
+		}); err != nil {
+			return err
+		}
+	}
+
+	uris := splitList(*exportURIs)
+	if len(uris) == 0 {
+		if *scope == "" || *exportPrefix == "" {
+			return errors.New("scope and exportPrefix are required without exportURIs")
+		}
+		assets, err := cloudasset.NewService(ctx)
+		if err != nil {
+			return err
+		}
+		objects, err := storage.NewService(ctx)
+		if err != nil {
+			return err
+		}
+		// Tasks export to their own objects, as they run without coordination.
+		prefix := fmt.Sprintf("%s/%s/task-%d", strings.TrimSuffix(*exportPrefix, "/"), runID, spec.Index)
+		if uris, err = cai.NewExporter(assets, objects).ExportFiles(ctx, cai.ExportOptions{
+			Parent:       *scope,
+			AssetTypes:   splitList(*assetTypes),
+			ContentTypes: splitList(*contentTypes),
+			OutputPrefix: prefix,
+		}); err != nil {
+			return err
+		}
+	}
+
+	j.report.Manifest = report.NewManifest(runID, v, uris)
+	for _, uri := range uris {
+		if err := j.reviewFile(ctx, uri); err != nil {
+			return errors.Wrapf(err, "failed to review %s", uri)
+		}
+	}
+	if err := j.write(ctx, nil); err != nil {
+		return err
+	}
+	j.report.Manifest.Finish()
+	if *resultsPrefix != "" {
+		content, err := json.MarshalIndent(j.report, "", "  ")
+		if err != nil {
+			return errors.Wrapf(err, "failed to encode report")
+		}
+		count := spec.Count
+		if count == 0 {
+			count = 1
+		}
+		path := fmt.Sprintf("%s/%s/report-%d-of-%d.json", strings.TrimSuffix(*resultsPrefix, "/"), runID, spec.Index, count)
+		if !strings.HasPrefix(path, "gs://") {
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return errors.Wrapf(err, "failed to create %s", filepath.Dir(path))
+			}
+		}
+		if err := configs.WriteFile(ctx, path, content); err != nil {
+			return err
+		}
+	}
+	logging.FromContext(ctx).Info("reviewed shard", zap.Int("assets", j.report.Assets), zap.Int("violations", len(j.report.Violations)),
+		zap.Int("errors", j.report.Errors))
+	if *postRunHook == "" {
+		return nil
+	}
+	return hooks.Run(ctx, hooks.NewReportSummary(j.report), []hooks.Hook{{Command: *postRunHook, Timeout: timeout, Output: os.Stdout}})
+}
+
+func main() {
+	flag.Parse()
+	if err := logging.Setup(*logFormat, *logLevel); err != nil {
+		fmt.Fprintf(os.Stderr, "audit-job: %v\n", err)
+		os.Exit(1)
+	}
+	if err := run(context.Background()); err != nil {
+		zap.L().Fatal("Audit failed", zap.Error(err))
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/forseti-security/config-validator/pkg/gcv"
+	"github.com/forseti-security/config-validator/pkg/gcv/configs"
+	"github.com/forseti-security/config-validator/pkg/logging"
+	"github.com/forseti-security/config-validator/pkg/report"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// checkpoint saves the progress of a review to a local file or gs:// object at most once per
+// interval, so that an interrupted review resumes where it left off. The partial report is saved
+// as the state of the checkpoint. A nil checkpoint does nothing.
+type checkpoint struct {
+	path       string
+	interval   time.Duration
+	checkpoint *gcv.Checkpoint
+	report     *report.Report
+	saved      time.Time
+}
+
+// loadCheckpoint returns the checkpoint of the review at path, restoring r from it if a review
+// with the same policies saved it.
+func loadCheckpoint(ctx context.Context, path string, interval time.Duration, v *gcv.Validator, r *report.Report) (*checkpoint, error) {
+	c := &checkpoint{path: path, interval: interval, checkpoint: gcv.NewCheckpoint(v), report: r, saved: time.Now()}
+	content, found, err := configs.ReadFile(ctx, path)
+	if err != nil || !found {
+		return c, err
+	}
+	saved, err := gcv.ReadCheckpoint(bytes.NewReader(content))
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid checkpoint %s", path)
+	}
+	if !saved.Resumable(v) {
+		logging.FromContext(ctx).Warn("policies changed since the checkpoint was saved, starting over", zap.String("checkpoint", path))
+		return c, nil
+	}
+	if err := json.Unmarshal(saved.State, r); err != nil {
+		return nil, errors.Wrapf(err, "invalid report in checkpoint %s", path)
+	}
+	logging.FromContext(ctx).Info("resuming review", zap.String("checkpoint", path), zap.Int("processed", len(saved.Processed)))
+	c.checkpoint = saved
+	return c, nil
+}
+
+// done returns true if the record with key and hash was processed before the checkpoint was saved.
+func (c *checkpoint) done(key, hash string) bool {
+	return c != nil && c.checkpoint.Done(key, hash)
+}
+
+// mark records the record with key and hash as processed, saving the checkpoint if the interval
+// has passed.
+func (c *checkpoint) mark(ctx context.Context, key, hash string) error {
+	if c == nil {
+		return nil
+	}
+	c.checkpoint.Mark(key, hash)
+	if time.Since(c.saved) < c.interval {
+		return nil
+	}
+	return c.save(ctx)
+}
+
+func (c *checkpoint) save(ctx context.Context) error {
+	state, err := json.Marshal(c.report)
+	if err != nil {
+		return errors.Wrapf(err, "failed to encode report")
+	}
+	c.checkpoint.SetState(state)
+	var content bytes.Buffer
+	if err := c.checkpoint.Write(&content); err != nil {
+		return err
+	}
+	if err := configs.WriteFile(ctx, c.path, content.Bytes()); err != nil {
+		return errors.Wrapf(err, "failed to save checkpoint")
+	}
+	c.saved = time.Now()
+	return nil
+}
+
+// finish removes the checkpoint of a completed review, so the next review starts over.
+func (c *checkpoint) finish(ctx context.Context) error {
+	if c == nil {
+		return nil
+	}
+	return configs.RemoveFile(ctx, c.path)
+}
@@ -0,0 +1,101 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/forseti-security/config-validator/pkg/gcv"
+	"github.com/forseti-security/config-validator/pkg/gcv/configs"
+	"github.com/forseti-security/config-validator/pkg/report"
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// constraintInfo is the listing of a loaded constraint.
+type constraintInfo struct {
+	Name     string `json:"name"`
+	Kind     string `json:"kind"`
+	Severity string `json:"severity,omitempty"`
+	Path     string `json:"path"`
+	// Parameters are the effective parameters, with the defaults of the template applied.
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+}
+
+func newListConstraintsCmd() *cobra.Command {
+	var output string
+	cmd := &cobra.Command{
+		Use:     "list-constraints",
+		Short:   "List the constraints of the policy library with their severities.",
+		Example: `gcv list-constraints --policies ./policy-library/policies --libs ./policy-library/lib --output json`,
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return listConstraints(cmd.OutOrStdout(), output)
+		},
+	}
+	cmd.Flags().StringVarP(&output, "output", "o", report.Table, "Output format, one of json, yaml, table.")
+	return cmd
+}
+
+func listConstraints(w io.Writer, output string) error {
+	v, err := newValidator()
+	if err != nil {
+		return err
+	}
+	constraints := []constraintInfo{}
+	for _, constraint := range v.Constraints() {
+		severity, _, _ := unstructured.NestedString(constraint.Object, "spec", "severity")
+		parameters, _, _ := unstructured.NestedMap(constraint.Object, "spec", "parameters")
+		constraints = append(constraints, constraintInfo{
+			Name:       gcv.ConstraintName(constraint),
+			Kind:       constraint.GetKind(),
+			Severity:   severity,
+			Path:       configs.DeclaredPath(constraint),
+			Parameters: parameters,
+		})
+	}
+
+	switch output {
+	case report.JSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(constraints)
+	case report.YAML:
+		out, err := yaml.Marshal(constraints)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(out)
+		return err
+	case report.Table:
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "NAME\tSEVERITY\tPATH")
+		for _, c := range constraints {
+			severity := c.Severity
+			if severity == "" {
+				severity = "-"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", c.Name, severity, c.Path)
+		}
+		return tw.Flush()
+	}
+	return errors.Errorf("unknown output format %q, want one of %s", output, strings.Join([]string{report.JSON, report.YAML, report.Table}, ", "))
+}
@@ -0,0 +1,65 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/forseti-security/config-validator/pkg/gcv/configs"
+	"github.com/spf13/cobra"
+)
+
+func newGatekeeperCmd() *cobra.Command {
+	var output string
+	cmd := &cobra.Command{
+		Use:   "gatekeeper",
+		Short: "Write the Kubernetes templates and constraints of the policy library in Gatekeeper format.",
+		Long: "Write the templates and constraints of the policy library for the Kubernetes target as YAML that can be " +
+			"applied to clusters running Gatekeeper, so that the library serves both CAI reviews and admission control. " +
+			"Templates for GCP resources are skipped. The output is stdout, a local path or a gs://bucket/object URL.",
+		Example: `gcv gatekeeper --policies ./policy-library/policies --libs ./policy-library/lib | kubectl apply -f -`,
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			config, err := newValidatorConfig()
+			if err != nil {
+				return err
+			}
+			var buf bytes.Buffer
+			export, err := config.WriteGatekeeper(&buf)
+			if err != nil {
+				return err
+			}
+			if output == "" {
+				_, err = cmd.OutOrStdout().Write(buf.Bytes())
+			} else {
+				err = configs.WriteFile(context.Background(), output, buf.Bytes())
+			}
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "Wrote %d templates and %d constraints.\n", export.Templates, export.Constraints)
+			if len(export.Skipped) != 0 {
+				fmt.Fprintf(cmd.ErrOrStderr(), "Skipped %d templates without a Kubernetes target: %s.\n",
+					len(export.Skipped), strings.Join(export.Skipped, ", "))
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&output, "output", "o", "", "Path of the YAML file, stdout if not set.")
+	return cmd
+}
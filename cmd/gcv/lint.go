@@ -0,0 +1,53 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/forseti-security/config-validator/pkg/lint"
+	"github.com/spf13/cobra"
+)
+
+func newLintCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:     "lint",
+		Short:   "Lint the templates and constraints of the policy library.",
+		Example: `gcv lint --policies ./policy-library/policies --libs ./policy-library/lib`,
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			w := cmd.OutOrStdout()
+			diagnostics, err := lint.LintPaths(context.Background(), policyFlags.policies, policyFlags.libs)
+			if err != nil {
+				return err
+			}
+			for _, d := range diagnostics {
+				fmt.Fprintln(w, d)
+			}
+			if lint.HasErrors(diagnostics) {
+				return &exitError{code: 1}
+			}
+			// Loading catches anything the linter does not check for.
+			if _, err := newValidator(); err != nil {
+				return err
+			}
+			if len(diagnostics) == 0 {
+				fmt.Fprintln(w, "No lint errors found.")
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command gcv reviews CAI exports against a policy library and lints and tests the library.
+//
+// The review subcommand exits with a status that reflects the most severe violation found, or 0
+// if all violations are below the severity given with --fail-on:
+//
+//	0  no violations
+//	1  the review could not be run
+//	2  violations of constraints with low or no severity
+//	3  violations of medium severity constraints
+//	4  violations of high severity constraints
+//	5  violations of critical severity constraints
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/forseti-security/config-validator/pkg/gcv"
+	"github.com/forseti-security/config-validator/pkg/gcv/configs"
+	"github.com/forseti-security/config-validator/pkg/logging"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// exitError is returned by subcommands that completed but need to exit with a status other than 0.
+type exitError struct {
+	code int
+}
+
+func (e *exitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.code)
+}
+
+var policyFlags struct {
+	policies []string
+	libs     string
+	bundles  []string
+}
+
+// newValidator returns a validator for the policies selected by the policy flags.
+func newValidator(opts ...gcv.Option) (*gcv.Validator, error) {
+	opts = append(opts, gcv.WithPolicyPaths(policyFlags.policies...), gcv.WithPolicyLibrary(policyFlags.libs), gcv.WithBundles(policyFlags.bundles...))
+	return gcv.NewValidator(opts...)
+}
+
+// newValidatorConfig returns the configuration of the policies selected by the policy flags.
+func newValidatorConfig() (*configs.Configuration, error) {
+	config, err := gcv.NewValidatorConfig(policyFlags.policies, policyFlags.libs)
+	if err != nil || len(policyFlags.bundles) == 0 {
+		return config, err
+	}
+	return config.SelectBundles(policyFlags.bundles)
+}
+
+// noPoliciesAnnotation marks commands that do not read the policies, which need no --policies.
+const noPoliciesAnnotation = "gcv/no-policies"
+
+var logFlags struct {
+	format string
+	level  string
+}
+
+func newRootCmd() *cobra.Command {
+	rootCmd := &cobra.Command{
+		Use:           "gcv",
+		Short:         "Review CAI data against a policy library, and lint and test the library.",
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := logging.Setup(logFlags.format, logFlags.level); err != nil {
+				return err
+			}
+			if len(policyFlags.policies) == 0 && cmd.Annotations[noPoliciesAnnotation] == "" {
+				return errors.New(`required flag(s) "policies" not set`)
+			}
+			return nil
+		},
+	}
+	rootCmd.PersistentFlags().StringSliceVar(&policyFlags.policies, "policies", nil, "Path to one or more policies directories, built in policy sets such as "+configs.BuiltinCIS+
+		" or PolicySet manifests ending in "+configs.PolicySetSuffix+".")
+	rootCmd.PersistentFlags().StringVar(&policyFlags.libs, "libs", "", "Path to the libs directory, not needed for built in policy sets and PolicySet manifests that declare their library.")
+	rootCmd.PersistentFlags().StringSliceVar(&policyFlags.bundles, "bundles", nil,
+		"Load only the constraints of these policy library bundles, such as cis-v1.1, selected by their "+configs.BundleAnnotationPrefix+" annotations.")
+	rootCmd.PersistentFlags().StringVar(&logFlags.format, "log-format", logging.Text, "Log format, text or json for one Cloud Logging structured entry per line.")
+	rootCmd.PersistentFlags().StringVar(&logFlags.level, "log-level", "info", "Minimum level of logged lines, one of debug, info, warn, error.")
+	rootCmd.AddCommand(newReviewCmd(), newMergeCmd(), newGatekeeperCmd(), newMigrateCmd(), newListConstraintsCmd(), newLintCmd(), newTestCmd(),
+		newVerifyCmd(), newVersionCmd())
+	return rootCmd
+}
+
+func main() {
+	err := newRootCmd().Execute()
+	if err == nil {
+		return
+	}
+	if exitErr, ok := errors.Cause(err).(*exitError); ok {
+		os.Exit(exitErr.code)
+	}
+	fmt.Fprintf(os.Stderr, "gcv: %v\n", err)
+	os.Exit(1)
+}
@@ -0,0 +1,111 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/forseti-security/config-validator/pkg/gcv/configs"
+	"github.com/forseti-security/config-validator/pkg/report"
+	"github.com/forseti-security/config-validator/pkg/shard"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+func newMergeCmd() *cobra.Command {
+	var output, failOn, cluster string
+	var audits, columns []string
+	var sign signFlags
+	cmd := &cobra.Command{
+		Use:   "merge [flags] REPORT...",
+		Short: "Combine the json reports of the shards of a review into one report.",
+		Long: "Combine the json reports written by gcv review --shard into the report of the whole review. " +
+			"Reports are local paths or gs://bucket/object URLs, and must come from the given policies.",
+		Example: `for i in 0 1 2 3; do gcv review --policies ./policies --shard $i/4 --output json resources.json > shard-$i.json; done
+gcv merge --policies ./policies --output sarif shard-*.json`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			threshold, err := report.ParseSeverity(failOn)
+			if err != nil {
+				return err
+			}
+			if err := checkAuditFlags(audits, cluster); err != nil {
+				return err
+			}
+			if err := sign.check(); err != nil {
+				return err
+			}
+			return merge(context.Background(), cmd.OutOrStdout(), args, output, columns, threshold, audits, cluster, sign)
+		},
+	}
+	cmd.Flags().StringVarP(&output, "output", "o", report.Table, "Output format, one of "+strings.Join(report.Formats, ", ")+".")
+	addColumnsFlag(cmd, &columns)
+	cmd.Flags().StringVar(&failOn, "fail-on", "",
+		"Exit non-zero only for violations of at least this severity, one of low, medium, high, critical. Defaults to any violation.")
+	addAuditFlags(cmd, &audits, &cluster)
+	addSignFlags(cmd, &sign)
+	return cmd
+}
+
+func merge(ctx context.Context, w io.Writer, files []string, output string, columns []string, threshold int, audits []string, cluster string, sign signFlags) error {
+	v, err := newValidator()
+	if err != nil {
+		return err
+	}
+	out, err := sign.writer(ctx, w)
+	if err != nil {
+		return err
+	}
+	var reports []*report.Report
+	for _, file := range files {
+		content, found, err := configs.ReadFile(ctx, file)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return errors.Errorf("report %s does not exist", file)
+		}
+		r := &report.Report{}
+		if err := json.Unmarshal(content, r); err != nil {
+			return errors.Wrapf(err, "invalid report %s", file)
+		}
+		if r.PolicyVersion != v.PolicyVersion() {
+			return errors.Errorf("report %s was reviewed with policy version %s, want %s", file, r.PolicyVersion, v.PolicyVersion())
+		}
+		reports = append(reports, r)
+	}
+	merged, err := shard.MergeReports(reports...)
+	if err != nil {
+		return err
+	}
+	for _, file := range audits {
+		if err := importAudit(ctx, merged, file, cluster); err != nil {
+			return errors.Wrapf(err, "failed to import %s", file)
+		}
+	}
+	if err := writeReport(out, merged, output, columns); err != nil {
+		return err
+	}
+	if err := out.sign(ctx); err != nil {
+		return err
+	}
+	if code := report.ExitCode(merged.MaxSeverityRank(), threshold); code != 0 {
+		return &exitError{code: code}
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/forseti-security/config-validator/pkg/gcv/configs"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+func newMigrateCmd() *cobra.Command {
+	var write bool
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Rewrite legacy v1alpha1 templates and constraints of the policy library in the current format.",
+		Long: "Rewrite the v1alpha1 templates and constraints of old policy library versions as v1beta1, with the " +
+			"Rego library inlined into the templates. Lists the files that would change and the documents that " +
+			"cannot be migrated, which are left as they are. Comments within migrated documents are not kept.",
+		Example: `gcv migrate --policies ./policy-library/policies --libs ./policy-library/lib --write`,
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+			migration, err := configs.Migrate(ctx, policyFlags.policies, policyFlags.libs)
+			if err != nil {
+				return err
+			}
+			w := cmd.OutOrStdout()
+			for _, file := range migration.Files {
+				if write {
+					if err := configs.WriteFile(ctx, file.Path, file.Content); err != nil {
+						return errors.Wrapf(err, "failed to write %s", file.Path)
+					}
+				}
+				fmt.Fprintf(w, "%s: %d templates, %d constraints\n", file.Path, file.Templates, file.Constraints)
+			}
+			for _, problem := range migration.Problems {
+				fmt.Fprintf(w, "not migrated: %s\n", problem)
+			}
+			if !write && len(migration.Files) != 0 {
+				fmt.Fprintln(w, "Run with --write to rewrite the files.")
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&write, "write", false, "Rewrite the policy files, rather than listing the changes.")
+	return cmd
+}
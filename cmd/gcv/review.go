@@ -0,0 +1,381 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/forseti-security/config-validator/pkg/ancestry"
+	"github.com/forseti-security/config-validator/pkg/asset"
+	"github.com/forseti-security/config-validator/pkg/gcv"
+	"github.com/forseti-security/config-validator/pkg/gcv/configs"
+	"github.com/forseti-security/config-validator/pkg/gkaudit"
+	"github.com/forseti-security/config-validator/pkg/hooks"
+	"github.com/forseti-security/config-validator/pkg/logging"
+	"github.com/forseti-security/config-validator/pkg/redact"
+	"github.com/forseti-security/config-validator/pkg/remediation"
+	"github.com/forseti-security/config-validator/pkg/report"
+	"github.com/forseti-security/config-validator/pkg/shard"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+func newReviewCmd() *cobra.Command {
+	var output, failOn, quarantine, checkpointPath, shardSpec, dedupKey, cluster, ancestryCache, ancestryMap string
+	var reportSkipped, snippets, resolveAncestry, multiTarget bool
+	var checkpointInterval, hookTimeout time.Duration
+	var redactPatterns, audits, columns, postRunHooks []string
+	var sign signFlags
+	cmd := &cobra.Command{
+		Use:   "review [flags] FILE...",
+		Short: "Review CAI exports read from local files, Cloud Storage or stdin.",
+		Long: "Review CAI exports, newline delimited JSON or length delimited Asset protos, optionally gzip compressed. " +
+			"Files are local paths, gs://bucket/object URLs, or - for stdin.",
+		Example: `gcv review --policies ./policy-library/policies --libs ./policy-library/lib --output sarif resources.json
+gsutil cat gs://my-bucket/resources.json | gcv review --policies ./policy-library/policies -`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			threshold, err := report.ParseSeverity(failOn)
+			if err != nil {
+				return err
+			}
+			var opts []gcv.Option
+			if reportSkipped {
+				opts = append(opts, gcv.WithSkippedAssets())
+			}
+			if multiTarget {
+				opts = append(opts, gcv.WithMultiTargetReview())
+			}
+			if snippets {
+				opts = append(opts, gcv.WithRemediator(remediation.NewEngine()))
+			}
+			if len(redactPatterns) != 0 {
+				redactor, err := redact.New(redactPatterns)
+				if err != nil {
+					return err
+				}
+				opts = append(opts, gcv.WithRedactor(redactor))
+			}
+			if resolveAncestry || ancestryMap != "" {
+				resolver, err := ancestry.New(context.Background(), ancestry.Config{Resolve: resolveAncestry, Mapping: ancestryMap, Cache: ancestryCache})
+				if err != nil {
+					return err
+				}
+				opts = append(opts, gcv.WithAncestryResolver(resolver))
+			}
+			if quarantine != "" {
+				f, err := os.Create(quarantine)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				opts = append(opts, gcv.WithQuarantine(gcv.NewQuarantine(f)))
+			}
+			run := reviewRun{output: output, columns: columns, threshold: threshold, checkpoint: checkpointPath, checkpointInterval: checkpointInterval,
+				audits: audits, cluster: cluster, sign: sign, hooks: postRunHooks, hookTimeout: hookTimeout}
+			if err := checkAuditFlags(audits, cluster); err != nil {
+				return err
+			}
+			if err := sign.check(); err != nil {
+				return err
+			}
+			if shardSpec != "" {
+				if len(audits) != 0 {
+					return errors.New("--gatekeeper-audit cannot be combined with --shard, pass it to gcv merge instead")
+				}
+				if run.shard, err = shard.Parse(shardSpec); err != nil {
+					return err
+				}
+			}
+			if dedupKey != "" {
+				if run.dedup, err = gcv.NewDeduplicator(dedupKey); err != nil {
+					return err
+				}
+			}
+			return review(context.Background(), cmd.OutOrStdout(), args, run, opts...)
+		},
+	}
+	cmd.Flags().StringVarP(&output, "output", "o", report.Table, "Output format, one of "+strings.Join(report.Formats, ", ")+".")
+	addColumnsFlag(cmd, &columns)
+	cmd.Flags().StringVar(&failOn, "fail-on", "",
+		"Exit non-zero only for violations of at least this severity, one of low, medium, high, critical. Defaults to any violation.")
+	cmd.Flags().BoolVar(&reportSkipped, "report-skipped", false,
+		"Count assets of content types no target supports per asset type in the report, instead of failing their review.")
+	cmd.Flags().BoolVar(&multiTarget, "multi-target", false,
+		"Also review Kubernetes assets against the constraints of the GCP target, reporting the violations of both targets together.")
+	cmd.Flags().BoolVar(&snippets, "remediation-snippets", false, "Attach gcloud commands and Terraform changes fixing violations of supported constraint kinds to the json and yaml output.")
+	cmd.Flags().StringSliceVar(&redactPatterns, "redact", nil,
+		"Replace the fields of assets and violation metadata matching these patterns, such as resource.data.metadata.items[*].value, in the output.")
+	cmd.Flags().StringVar(&quarantine, "quarantine", "", "Write assets whose review panicked to this file, which can be reviewed again to reproduce the panic.")
+	cmd.Flags().StringVar(&checkpointPath, "checkpoint", "", "Save the progress of the review to this file or gs:// object, and resume from it if it exists. It is removed once the review completes.")
+	cmd.Flags().DurationVar(&checkpointInterval, "checkpoint-interval", time.Minute, "How often the progress of the review is saved with --checkpoint.")
+	cmd.Flags().StringVar(&shardSpec, "shard", "",
+		"Review only the assets of one shard of the exports, given as index/count such as 0/8. Combine the json reports of the shards with gcv merge.")
+	cmd.Flags().StringVar(&dedupKey, "dedup", "",
+		"Review only the first record of assets found more than once in the exports, identified by "+strings.Join(gcv.DedupKeys, " or ")+".")
+	cmd.Flags().BoolVar(&resolveAncestry, "resolve-ancestry", false,
+		"Resolve the ancestors of assets without ancestry information, such as those of feed events, with the Cloud Resource Manager API.")
+	cmd.Flags().StringVar(&ancestryCache, "ancestry-cache", "", "Cache the projects and folders looked up by --resolve-ancestry in this file or gs:// object, for later runs to reuse.")
+	cmd.Flags().StringVar(&ancestryMap, "ancestry-map", "",
+		"Take the ancestry of projects and folders from this YAML mapping file or gs:// object, for runs that cannot reach the Cloud Resource Manager API. "+
+			"With --resolve-ancestry, the mapping overrides the API.")
+	cmd.Flags().StringArrayVar(&postRunHooks, "post-run-hook", nil,
+		"Shell command run after the report is written, with the run summary JSON on stdin and GCV_* run metadata in the environment. May be repeated.")
+	cmd.Flags().DurationVar(&hookTimeout, "post-run-hook-timeout", time.Minute, "Maximum run time of each post-run hook, 0 for no limit.")
+	addAuditFlags(cmd, &audits, &cluster)
+	addSignFlags(cmd, &sign)
+	return cmd
+}
+
+// reviewRun holds the settings of a review run.
+type reviewRun struct {
+	// output is the report format.
+	output string
+	// columns optionally replaces the default columns of the csv and tsv output.
+	columns []string
+	// threshold is the severity rank of violations that fail the run.
+	threshold int
+	// checkpoint optionally saves the progress of the run to this file or object.
+	checkpoint         string
+	checkpointInterval time.Duration
+	// shard restricts the run to the assets of one shard of the exports.
+	shard shard.Spec
+	// dedup optionally drops duplicate assets.
+	dedup *gcv.Deduplicator
+	// audits are files of Gatekeeper constraints whose audit violations are added to the report,
+	// recorded on the cluster with the CAI name cluster.
+	audits  []string
+	cluster string
+	// sign optionally signs the report.
+	sign signFlags
+	// hooks are the post-run hook commands, run with hookTimeout once the report is written.
+	hooks       []string
+	hookTimeout time.Duration
+}
+
+// reviewer reviews exports into a report.
+type reviewer struct {
+	validator *gcv.Validator
+	report    *report.Report
+	// checkpoint saves the progress of the review, if set.
+	checkpoint *checkpoint
+	shard      shard.Spec
+	dedup      *gcv.Deduplicator
+}
+
+func review(ctx context.Context, w io.Writer, files []string, run reviewRun, opts ...gcv.Option) error {
+	v, err := newValidator(opts...)
+	if err != nil {
+		return err
+	}
+	out, err := run.sign.writer(ctx, w)
+	if err != nil {
+		return err
+	}
+	runID := logging.NewRunID()
+	ctx = logging.WithRunID(ctx, runID)
+	r := report.New(v)
+	var c *checkpoint
+	if run.checkpoint != "" {
+		if c, err = loadCheckpoint(ctx, run.checkpoint, run.checkpointInterval, v, r); err != nil {
+			return err
+		}
+	}
+	// A resumed review keeps the manifest of the run it continues.
+	if r.Manifest == nil {
+		r.Manifest = report.NewManifest(runID, v, files)
+	}
+	rv := &reviewer{validator: v, report: r, checkpoint: c, shard: run.shard, dedup: run.dedup}
+	for _, file := range files {
+		if err := rv.reviewFile(ctx, file); err != nil {
+			return errors.Wrapf(err, "failed to review %s", file)
+		}
+	}
+	for _, file := range run.audits {
+		if err := importAudit(ctx, r, file, run.cluster); err != nil {
+			return errors.Wrapf(err, "failed to import %s", file)
+		}
+	}
+	r.Manifest.Finish()
+	if err := writeReport(out, r, run.output, run.columns); err != nil {
+		return err
+	}
+	if err := out.sign(ctx); err != nil {
+		return err
+	}
+	if err := c.finish(ctx); err != nil {
+		return err
+	}
+	var postRunHooks []hooks.Hook
+	for _, command := range run.hooks {
+		// The report is written to stdout.
+		postRunHooks = append(postRunHooks, hooks.Hook{Command: command, Timeout: run.hookTimeout, Output: os.Stderr})
+	}
+	if err := hooks.Run(ctx, hooks.NewReportSummary(r), postRunHooks); err != nil {
+		return err
+	}
+	if code := report.ExitCode(r.MaxSeverityRank(), run.threshold); code != 0 {
+		return &exitError{code: code}
+	}
+	return nil
+}
+
+// reviewFile reviews the export at file, which is - for stdin, a gs:// URL or a local path.
+func (rv *reviewer) reviewFile(ctx context.Context, file string) error {
+	if file == "-" {
+		return rv.reviewExport(ctx, os.Stdin, "")
+	}
+	if strings.HasPrefix(file, "gs://") {
+		path, err := configs.NewPath(file)
+		if err != nil {
+			return err
+		}
+		// Objects are streamed, exports larger than memory are reviewed as they are read.
+		return path.Walk(ctx, func(name string, r io.Reader) error {
+			return rv.reviewExport(ctx, r, name)
+		})
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return rv.reviewExport(ctx, f, file)
+}
+
+// reviewExport reviews the records of the shard of an export, skipping duplicate records and the
+// records the checkpoint saved as processed. Records that fail to decode or review are counted as errors and marked as
+// processed too, so a resumed review does not count them twice.
+func (rv *reviewer) reviewExport(ctx context.Context, in io.Reader, file string) error {
+	v, r, c := rv.validator, rv.report, rv.checkpoint
+	reader := shard.NewReader(asset.NewReader(in, file), rv.shard)
+	for {
+		record, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if decodeErr, ok := err.(*asset.DecodeError); ok {
+			// Records that fail to decode have no asset name, they are identified by their source.
+			key := "!" + decodeErr.Source.String()
+			if c.done(key, "") {
+				continue
+			}
+			logging.FromContext(ctx).Error("failed to decode asset", zap.Stringer("source", decodeErr.Source), zap.Error(decodeErr.Err))
+			r.AddError()
+			if err := c.mark(ctx, key, ""); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if rv.dedup != nil && rv.dedup.Duplicate(record.Asset) {
+			// Duplicates are identified by their source, so a resumed review does not count them twice.
+			key := "=" + record.Source.String()
+			if !c.done(key, "") {
+				r.AddDuplicate()
+				if err := c.mark(ctx, key, ""); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		var key, hash string
+		if c != nil {
+			if key, hash, err = gcv.CheckpointKey(record.Asset); err != nil {
+				return err
+			}
+			if c.done(key, hash) {
+				continue
+			}
+		}
+		result, err := v.ReviewRecord(ctx, record)
+		if errors.Cause(err) == gcv.ErrUnknownAncestry {
+			// Reviewing the remaining assets would silently miss the violations of the constraints.
+			return errors.Wrapf(err, "%s", record.Source)
+		}
+		if err != nil {
+			name, _ := record.Asset["name"].(string)
+			logging.FromContext(ctx).Error("failed to review asset",
+				zap.String(logging.AssetKey, name), zap.Stringer("source", record.Source), zap.Error(err))
+			r.AddError()
+		} else {
+			r.Add(result)
+		}
+		if err := c.mark(ctx, key, hash); err != nil {
+			return err
+		}
+	}
+}
+
+// checkAuditFlags checks the --gatekeeper-audit and --cluster flags.
+func checkAuditFlags(audits []string, cluster string) error {
+	if len(audits) != 0 && cluster == "" {
+		return errors.New("--gatekeeper-audit requires --cluster")
+	}
+	return nil
+}
+
+// addAuditFlags adds the --gatekeeper-audit and --cluster flags to cmd.
+func addColumnsFlag(cmd *cobra.Command, columns *[]string) {
+	cmd.Flags().StringSliceVar(columns, "columns", nil,
+		"Columns of the csv and tsv output, any of "+strings.Join(report.Columns, ", ")+". Defaults to "+strings.Join(report.DefaultColumns, ",")+".")
+}
+
+// writeReport writes r in format output, with columns if set.
+func writeReport(w io.Writer, r *report.Report, output string, columns []string) error {
+	if len(columns) != 0 {
+		return r.WriteColumns(w, output, columns)
+	}
+	return r.Write(w, output)
+}
+
+func addAuditFlags(cmd *cobra.Command, audits *[]string, cluster *string) {
+	cmd.Flags().StringSliceVar(audits, "gatekeeper-audit", nil,
+		"Add the violations Gatekeeper audit recorded in these files of kubectl get constraints -o yaml output to the report, "+
+			"except those the review found as well.")
+	cmd.Flags().StringVar(cluster, "cluster", "",
+		"The CAI name of the cluster the --gatekeeper-audit files come from, such as //container.googleapis.com/projects/p/locations/l/clusters/c.")
+}
+
+// importAudit adds the violations recorded in the Gatekeeper constraints in file, a local path or
+// gs:// URL, to r.
+func importAudit(ctx context.Context, r *report.Report, file, cluster string) error {
+	content, found, err := configs.ReadFile(ctx, file)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return errors.Errorf("%s does not exist", file)
+	}
+	audit, err := gkaudit.Read(content, cluster, nil)
+	if err != nil {
+		return err
+	}
+	for _, constraint := range audit.Truncated {
+		logging.FromContext(ctx).Warn("Gatekeeper recorded only some of the violations of the constraint",
+			zap.String("file", file), zap.String("constraint", constraint))
+	}
+	for _, result := range audit.Results {
+		r.AddImported(result)
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/forseti-security/config-validator/pkg/gcv/configs"
+	"github.com/forseti-security/config-validator/pkg/signing"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	cloudkms "google.golang.org/api/cloudkms/v1"
+)
+
+// signFlags holds the flags signing the output of a command.
+type signFlags struct {
+	key       string
+	signature string
+}
+
+func addSignFlags(cmd *cobra.Command, flags *signFlags) {
+	cmd.Flags().StringVar(&flags.key, "sign-key", "",
+		"Sign the output with this PEM encoded private key file, or Cloud KMS key version given as "+signing.KMSPrefix+"projects/.../cryptoKeyVersions/N. Requires --signature.")
+	cmd.Flags().StringVar(&flags.signature, "signature", "", "Write the signature of the output made with --sign-key to this file or gs:// object.")
+}
+
+// check returns an error if the flags are inconsistent.
+func (f *signFlags) check() error {
+	if (f.key == "") != (f.signature == "") {
+		return errors.New("--sign-key and --signature must be given together")
+	}
+	return nil
+}
+
+// signedWriter passes the output of a command to w while keeping a copy to sign.
+type signedWriter struct {
+	w         io.Writer
+	signer    signing.Signer
+	signature string
+	content   bytes.Buffer
+}
+
+// writer returns a signedWriter writing to w, which signs nothing unless --sign-key is given.
+func (f *signFlags) writer(ctx context.Context, w io.Writer) (*signedWriter, error) {
+	sw := &signedWriter{w: w, signature: f.signature}
+	if f.key == "" {
+		return sw, nil
+	}
+	if strings.HasPrefix(f.key, signing.KMSPrefix) {
+		service, err := cloudkms.NewService(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if sw.signer, err = signing.NewKMSSigner(ctx, service, strings.TrimPrefix(f.key, signing.KMSPrefix)); err != nil {
+			return nil, err
+		}
+		return sw, nil
+	}
+	content, err := ioutil.ReadFile(f.key)
+	if err != nil {
+		return nil, err
+	}
+	key, err := signing.ParsePrivateKey(content)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid --sign-key %s", f.key)
+	}
+	if sw.signer, err = signing.NewLocalSigner(key); err != nil {
+		return nil, err
+	}
+	return sw, nil
+}
+
+func (sw *signedWriter) Write(p []byte) (int, error) {
+	if sw.signer != nil {
+		sw.content.Write(p)
+	}
+	return sw.w.Write(p)
+}
+
+// sign writes the signature of the output written so far, if there is a signer.
+func (sw *signedWriter) sign(ctx context.Context) error {
+	if sw.signer == nil {
+		return nil
+	}
+	s, err := signing.Sign(ctx, sw.signer, sw.content.Bytes())
+	if err != nil {
+		return err
+	}
+	var out bytes.Buffer
+	if err := s.Write(&out); err != nil {
+		return err
+	}
+	return errors.Wrapf(configs.WriteFile(ctx, sw.signature, out.Bytes()), "failed to write signature %s", sw.signature)
+}
+
+func newVerifyCmd() *cobra.Command {
+	var key, signature string
+	cmd := &cobra.Command{
+		Use:   "verify --key KEY --signature SIGNATURE FILE",
+		Short: "Verify the signature of an output written with --sign-key.",
+		Long: "Verify that FILE, the output of gcv review or gcv merge, is unchanged since it was signed with --sign-key. " +
+			"Files and signatures are local paths or gs://bucket/object URLs. Exits 1 if the signature is invalid.",
+		Example: `gcv review --policies ./policies --output json --sign-key key.pem --signature report.sig resources.json > report.json
+gcv verify --key public.pem --signature report.sig report.json`,
+		Annotations: map[string]string{noPoliciesAnnotation: "true"},
+		Args:        cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+			public, err := publicKey(ctx, key)
+			if err != nil {
+				return err
+			}
+			content, err := readFile(ctx, args[0])
+			if err != nil {
+				return err
+			}
+			encoded, err := readFile(ctx, signature)
+			if err != nil {
+				return err
+			}
+			s, err := signing.ReadSignature(bytes.NewReader(encoded))
+			if err != nil {
+				return err
+			}
+			if err := signing.Verify(content, s, public); err != nil {
+				return errors.Wrapf(err, "%s", args[0])
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "%s: signature by %s verified\n", args[0], s.KeyID)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&key, "key", "",
+		"The PEM encoded public key file of the signer, or its Cloud KMS key version given as "+signing.KMSPrefix+"projects/.../cryptoKeyVersions/N.")
+	cmd.Flags().StringVar(&signature, "signature", "", "The signature file or gs:// object written with --signature.")
+	for _, name := range []string{"key", "signature"} {
+		if err := cmd.MarkFlagRequired(name); err != nil {
+			panic(err)
+		}
+	}
+	return cmd
+}
+
+// publicKey returns the public key read from the file key, or of the Cloud KMS key version key.
+func publicKey(ctx context.Context, key string) (crypto.PublicKey, error) {
+	if strings.HasPrefix(key, signing.KMSPrefix) {
+		service, err := cloudkms.NewService(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return signing.KMSPublicKey(ctx, service, strings.TrimPrefix(key, signing.KMSPrefix))
+	}
+	content, err := ioutil.ReadFile(key)
+	if err != nil {
+		return nil, err
+	}
+	public, err := signing.ParsePublicKey(content)
+	return public, errors.Wrapf(err, "invalid --key %s", key)
+}
+
+// readFile returns the content of the local path or gs:// URL file, which must exist.
+func readFile(ctx context.Context, file string) ([]byte, error) {
+	content, found, err := configs.ReadFile(ctx, file)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, errors.Errorf("%s does not exist", file)
+	}
+	return content, nil
+}
@@ -0,0 +1,60 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+
+	"github.com/forseti-security/config-validator/pkg/policytest"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+func newTestCmd() *cobra.Command {
+	var verbose bool
+	cmd := &cobra.Command{
+		Use:     "test",
+		Short:   "Run the PolicyTest cases found alongside the policies and report which failed.",
+		Example: `gcv test --policies ./policy-library/policies --libs ./policy-library/lib`,
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+			suites, err := policytest.Load(ctx, policyFlags.policies)
+			if err != nil {
+				return err
+			}
+			if len(suites) == 0 {
+				return errors.Errorf("no %s documents found in %v", policytest.Kind, policyFlags.policies)
+			}
+			v, err := newValidator()
+			if err != nil {
+				return err
+			}
+			report, err := policytest.Run(ctx, v, suites)
+			if err != nil {
+				return err
+			}
+			if err := report.Write(cmd.OutOrStdout(), verbose); err != nil {
+				return err
+			}
+			if report.Failed() > 0 {
+				return &exitError{code: 1}
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&verbose, "verbose", false, "Print passed cases as well as failed ones.")
+	return cmd
+}
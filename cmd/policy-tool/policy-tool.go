@@ -15,55 +15,9 @@
 package main
 
 import (
-	"flag"
-	"fmt"
-	"os"
-
-	"github.com/forseti-security/config-validator/cmd/policy-tool/debug"
-	"github.com/forseti-security/config-validator/cmd/policy-tool/lint"
-	"github.com/forseti-security/config-validator/cmd/policy-tool/status"
-	_ "github.com/golang/glog"
-	"github.com/spf13/cobra"
-	"github.com/spf13/pflag"
-)
-
-var (
-	rootCmd = &cobra.Command{
-		Use:   "policy-tool",
-		Short: "Tool for managing constraint template bundles.",
-	}
+	"github.com/forseti-security/config-validator/pkg/cli"
 )
 
-var glogFlags = map[string]struct{}{
-	"alsologtostderr":  {},
-	"log_backtrace_at": {},
-	"log_dir":          {},
-	"logtostderr":      {},
-	"stderrthreshold":  {},
-	"v":                {},
-	"vmodule":          {},
-}
-
-func init() {
-	rootCmd.AddCommand(debug.Cmd)
-	rootCmd.AddCommand(lint.Cmd)
-	rootCmd.AddCommand(status.Cmd)
-	flag.CommandLine.VisitAll(func(f *flag.Flag) {
-		if _, ok := glogFlags[f.Name]; ok {
-			pflag.CommandLine.AddGoFlag(f)
-		}
-	})
-}
-
 func main() {
-	// glog complains if we don't parse flags
-	args := os.Args
-	os.Args = os.Args[0:1]
-	flag.Parse()
-	os.Args = args
-
-	if err := rootCmd.Execute(); err != nil {
-		fmt.Printf("%#v\n", err)
-		os.Exit(1)
-	}
+	cli.Execute()
 }
@@ -16,81 +16,539 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"expvar"
 	"flag"
 	"fmt"
-	"log"
+	"io/ioutil"
 	"net"
+	"net/http"
+	"net/http/pprof"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 
+	"github.com/forseti-security/config-validator/pkg/ancestry"
 	"github.com/forseti-security/config-validator/pkg/api/validator"
+	"github.com/forseti-security/config-validator/pkg/externaldata"
+	"github.com/forseti-security/config-validator/pkg/feed"
 	"github.com/forseti-security/config-validator/pkg/gcv"
-	"github.com/golang/glog"
+	"github.com/forseti-security/config-validator/pkg/iammembers"
+	"github.com/forseti-security/config-validator/pkg/logging"
+	"github.com/forseti-security/config-validator/pkg/notify"
+	"github.com/forseti-security/config-validator/pkg/pacing"
+	"github.com/forseti-security/config-validator/pkg/ratelimit"
+	"github.com/forseti-security/config-validator/pkg/redact"
+	"github.com/forseti-security/config-validator/pkg/rpcauth"
+	"github.com/forseti-security/config-validator/pkg/rpcconfig"
+	"github.com/forseti-security/config-validator/pkg/server"
+	"github.com/forseti-security/config-validator/pkg/tlsconfig"
+	"github.com/forseti-security/config-validator/pkg/transform"
+	"github.com/grpc-ecosystem/grpc-gateway/runtime"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	cloudidentity "google.golang.org/api/cloudidentity/v1"
+	crmv1 "google.golang.org/api/cloudresourcemanager/v1"
+	"google.golang.org/api/option"
+	pubsub "google.golang.org/api/pubsub/v1"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
 )
 
 var (
-	policyPath = flag.String("policyPath", os.Getenv("POLICY_PATH"), "directories, separated by comma, containing policy templates and configs")
+	policyPath = flag.String("policyPath", os.Getenv("POLICY_PATH"), "directories, separated by comma, containing policy templates and configs, built in policy sets such as builtin:cis, PolicySet manifests ending in .policyset.yaml")
 	// TODO(corb): Template development will eventually inline library code, but the currently template examples have dependency rego code.
 	//  This flag will be deprecated when the template tooling is complete.
 	policyLibraryPath  = flag.String("policyLibraryPath", os.Getenv("POLICY_LIBRARY_PATH"), "directory containing policy templates and configs")
+	policySets         = flag.String("policySets", "", "Additional policy sets requests select by policy_set, in name=path form, e.g. sales=gs://bucket/sales,hr=./hr. Repeat a name for several paths")
+	bundles            = flag.String("bundles", "", "Comma separated policy library bundles, such as cis-v1.1, all policy sets load only the constraints of these bundles")
 	port               = flag.Int("port", 10000, "The server port")
+	restPort           = flag.Int("restPort", 0, "Port to serve the REST/JSON gateway of the RPC service on, 0 disables the gateway")
 	maxMessageRecvSize = flag.Int(
-		"maxMessageRecvSize", 128*1024*1024, "The max message receive size for the RPC service")
+		"maxMessageRecvSize", rpcconfig.DefaultMaxMessageSize, "The max message receive size for the RPC service")
+	maxMessageSendSize = flag.Int(
+		"maxMessageSendSize", 0, "The max message send size for the RPC service, 0 keeps the gRPC default")
+	tlsCertFile = flag.String("tlsCertFile", "", "PEM certificate chain for serving TLS, reloaded on change")
+	tlsKeyFile  = flag.String("tlsKeyFile", "", "PEM private key for tlsCertFile, reloaded on change")
+	tlsClientCA = flag.String(
+		"tlsClientCAFile", "", "PEM bundle of CAs used to verify client certificates, reloaded on change")
+	tlsRequireClientCert = flag.Bool(
+		"tlsRequireClientCert", false, "Reject connections without a client certificate signed by tlsClientCAFile (mTLS)")
+	authIDTokenAudiences = flag.String(
+		"authIDTokenAudiences", "", "Comma separated audiences of the Google-signed ID tokens callers authenticate with as bearer tokens, such as the URL of the server")
+	authClientCerts = flag.Bool(
+		"authClientCerts", false, "Authenticate callers by their client certificates verified against tlsClientCAFile")
+	authPolicy = flag.String(
+		"authPolicy", "", "YAML file of the rules allowing authenticated callers RPCs, all callers authenticated by authIDTokenAudiences or authClientCerts may call all RPCs without it")
+	resultCacheSize = flag.Int(
+		"resultCacheSize", 0, "Number of review results to cache by asset content, 0 disables the cache")
+	clientPoolSize = flag.Int(
+		"clientPoolSize", 1, "Number of Constraint Framework clients per policy set and target that concurrent reviews are spread across")
+	assetTransforms = flag.String(
+		"assetTransforms", "", "YAML file of CEL transforms applied to assets before review")
+	redactFields = flag.String(
+		"redactFields", "", "Patterns, separated by comma, of asset and violation metadata fields replaced in review results, e.g. resource.data.metadata.items[*].value")
+	feedSubscription = flag.String(
+		"feedSubscription", "", "Pub/Sub subscription (projects/<p>/subscriptions/<s>) of a CAI feed to review continuously")
+	violationsTopic = flag.String(
+		"violationsTopic", "", "Pub/Sub topic (projects/<p>/topics/<t>) violations from feedSubscription are published to")
+	pprofAddr = flag.String(
+		"pprofAddr", "", "Address to serve pprof profiling endpoints on, e.g. localhost:6060, empty disables profiling")
+	policyVersion = flag.String(
+		"policyVersion", "", "Semantic version of the policy set stamped on violations, defaults to the content hash of the policies")
+	apiQPS = flag.String(
+		"apiQPS", "", "Per API request rate limits for Cloud API calls in name=qps form, e.g. pubsub=100")
+	apiRetries         = flag.Int("apiRetries", 5, "Number of times a Cloud API call throttled with 429 is retried")
+	expandGroupMembers = flag.Bool(
+		"expandGroupMembers", false, "Expand group members of IAM policies with the Cloud Identity API before review")
+	groupCacheTTL     = flag.Duration("groupCacheTTL", 10*time.Minute, "How long group memberships looked up by expandGroupMembers are cached")
+	multiTargetReview = flag.Bool(
+		"multiTargetReview", false, "Also review Kubernetes assets against the constraints of the GCP target, merging the violations of both targets")
+	resolveAncestry = flag.Bool(
+		"resolveAncestry", false, "Resolve the ancestors of assets without ancestry information, such as those of feed events, with the Cloud Resource Manager API")
+	ancestryCacheTTL = flag.Duration("ancestryCacheTTL", time.Hour, "How long projects and folders looked up by resolveAncestry are cached")
+	ancestryCache    = flag.String("ancestryCache", "", "File or gs:// object caching the projects and folders looked up by resolveAncestry across restarts")
+	ancestryMap      = flag.String("ancestryMap", "", "YAML file or gs:// object mapping projects and folders to their ancestry, used instead of or with resolveAncestry")
+	dataProviders    = flag.String(
+		"dataProviders", "", "HTTP data providers templates can call with external_data, in name=url form, e.g. cmdb=https://cmdb.example.com/lookup")
+	logFormat       = flag.String("logFormat", logging.Text, "Log format, text or json for one Cloud Logging structured entry per line")
+	logLevel        = flag.String("logLevel", "info", "Minimum level of logged lines, one of debug, info, warn, error")
+	shutdownTimeout = flag.Duration(
+		"shutdownTimeout", 30*time.Second, "How long in-flight requests are drained on SIGTERM before they are cancelled")
+	webhookURL = flag.String(
+		"webhookURL", os.Getenv("WEBHOOK_URL"), "HTTP endpoint new violations are posted to, empty disables notifications")
+	webhookFormat = flag.String(
+		"webhookFormat", notify.FormatJSON, "Payload format of webhookURL requests, one of json, slack, teams")
+	webhookTemplate = flag.String(
+		"webhookTemplate", "", "File with a Go text/template for webhookURL request bodies, overrides webhookFormat")
+	webhookBatchSize     = flag.Int("webhookBatchSize", 50, "Maximum number of violations posted in one webhookURL request")
+	webhookFlushInterval = flag.Duration("webhookFlushInterval", 30*time.Second, "How long new violations wait to be batched before they are posted")
+	webhookRenotifyAfter = flag.Duration(
+		"webhookRenotifyAfter", 24*time.Hour, "How long a violation that is still found is not posted again")
+	quarantineFile       = flag.String("quarantineFile", "", "File assets whose review panicked are appended to as JSON lines, for reproducing the panic")
+	maxConcurrentReviews = flag.Int(
+		"maxConcurrentReviews", 0, "Number of Review and StreamReview calls each client may run at once, 0 for no limit")
+	maxAssetsPerSecond = flag.Float64(
+		"maxAssetsPerSecond", 0, "Sustained rate of assets each client may have reviewed, 0 for no limit")
+	assetBurst = flag.Int(
+		"assetBurst", 0, "Number of assets an idle client may have reviewed at once under maxAssetsPerSecond, defaults to one second of the rate")
 )
 
-type gcvServer struct {
-	validator *gcv.ParallelValidator
+// parsePolicySets returns the paths of the policy sets in policySets by name, with the paths of
+// policyPath as the set with the empty name.
+func parsePolicySets(policyPath, policySets string) (map[string][]string, error) {
+	sets := map[string][]string{"": strings.Split(policyPath, ",")}
+	if strings.TrimSpace(policySets) == "" {
+		return sets, nil
+	}
+	for _, pair := range strings.Split(policySets, ",") {
+		parts := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, errors.Errorf("invalid policy set %q, want name=path", pair)
+		}
+		sets[parts[0]] = append(sets[parts[0]], parts[1])
+	}
+	return sets, nil
 }
 
-func (s *gcvServer) AddData(ctx context.Context, request *validator.AddDataRequest) (*validator.AddDataResponse, error) {
-	return &validator.AddDataResponse{}, status.Error(codes.Internal, "Not supported")
+// servePprof serves the pprof endpoints and exported variables on addr until the process exits.
+func servePprof(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/debug/vars", expvar.Handler())
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	zap.L().Info("serving pprof", zap.String("address", addr))
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		zap.L().Error("pprof server stopped", zap.Error(err))
+	}
 }
 
-func (s *gcvServer) Audit(ctx context.Context, request *validator.AuditRequest) (*validator.AuditResponse, error) {
-	return &validator.AuditResponse{}, status.Error(codes.Internal, "Not supported")
+// newGuard returns the guard authenticating and authorizing callers as configured by the auth
+// flags, nil if no authentication is configured.
+func newGuard() (*rpcauth.Guard, error) {
+	var authenticators []rpcauth.Authenticator
+	if *authIDTokenAudiences != "" {
+		a, err := rpcauth.NewIDTokenAuthenticator(strings.Split(*authIDTokenAudiences, ","))
+		if err != nil {
+			return nil, err
+		}
+		authenticators = append(authenticators, a)
+	}
+	if *authClientCerts {
+		if *tlsClientCA == "" {
+			return nil, errors.New("authClientCerts requires tlsClientCAFile")
+		}
+		authenticators = append(authenticators, rpcauth.NewCertificateAuthenticator())
+	}
+	if len(authenticators) == 0 {
+		if *authPolicy != "" {
+			return nil, errors.New("authPolicy requires authIDTokenAudiences or authClientCerts")
+		}
+		return nil, nil
+	}
+	var policy *rpcauth.Policy
+	if *authPolicy != "" {
+		var err error
+		if policy, err = rpcauth.Load(*authPolicy); err != nil {
+			return nil, err
+		}
+	}
+	return rpcauth.NewGuard(policy, authenticators...), nil
 }
 
-func (s *gcvServer) Reset(ctx context.Context, request *validator.ResetRequest) (*validator.ResetResponse, error) {
-	return &validator.ResetResponse{}, status.Error(codes.Internal, "Not supported")
+// deploymentFlags returns whether the features configured by the flags are enabled, as reported
+// by GetCapabilities.
+func deploymentFlags() map[string]bool {
+	return map[string]bool{
+		"tls":                   *tlsCertFile != "",
+		"authentication":        *authIDTokenAudiences != "" || *authClientCerts,
+		"rest-gateway":          *restPort != 0,
+		"result-cache":          *resultCacheSize != 0,
+		"multi-target-review":   *multiTargetReview,
+		"asset-transforms":      *assetTransforms != "",
+		"redaction":             *redactFields != "",
+		"expand-group-members":  *expandGroupMembers,
+		"resolve-ancestry":      *resolveAncestry || *ancestryMap != "",
+		"external-data":         *dataProviders != "",
+		"feed":                  *feedSubscription != "",
+		"webhook-notifications": *webhookURL != "",
+		"review-limits":         *maxConcurrentReviews != 0 || *maxAssetsPerSecond != 0,
+	}
 }
 
-func (s *gcvServer) Review(ctx context.Context, request *validator.ReviewRequest) (*validator.ReviewResponse, error) {
-	return s.validator.Review(ctx, request)
+// restMethod returns the RPC method serving a request of the REST gateway, empty if there is none.
+func restMethod(r *http.Request) string {
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/v1/review":
+		return "/validator.Validator/Review"
+	case r.Method == http.MethodGet && r.URL.Path == "/v1/constraints":
+		return "/validator.Validator/ListConstraints"
+	case r.Method == http.MethodGet && r.URL.Path == "/v1/capabilities":
+		return "/validator.Validator/GetCapabilities"
+	}
+	return ""
 }
 
-func newServer(stopChannel chan struct{}, policyPaths []string, policyLibraryPath string) (*gcvServer, error) {
-	cv, err := gcv.NewValidator(policyPaths, policyLibraryPath)
+// newRESTServer returns the server of the REST gateway of the RPC service on restPort, with TLS
+// if tlsConfig is set and requests authorized by guard if set. Requests are handled in process
+// without going through gRPC.
+func newRESTServer(service validator.ValidatorServer, tlsConfig *tls.Config, guard *rpcauth.Guard) *http.Server {
+	mux := runtime.NewServeMux(runtime.WithMarshalerOption(runtime.MIMEWildcard, &runtime.JSONPb{OrigName: true}))
+	if err := validator.RegisterValidatorHandlerServer(context.Background(), mux, service); err != nil {
+		zap.L().Fatal("Failed to register REST gateway", zap.Error(err))
+	}
+	var handler http.Handler = mux
+	if guard != nil {
+		handler = guard.Handler(mux, restMethod)
+	}
+	httpServer := &http.Server{
+		Addr: fmt.Sprintf(":%d", *restPort),
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.Body = http.MaxBytesReader(w, r.Body, int64(*maxMessageRecvSize))
+			// Rate limits tell clients without an identity apart by their address, as for gRPC.
+			if addr, err := net.ResolveTCPAddr("tcp", r.RemoteAddr); err == nil {
+				r = r.WithContext(peer.NewContext(r.Context(), &peer.Peer{Addr: addr}))
+			}
+			handler.ServeHTTP(w, r)
+		}),
+		TLSConfig: tlsConfig,
+	}
+	return httpServer
+}
+
+// serveREST serves the REST gateway until it is shut down.
+func serveREST(httpServer *http.Server) {
+	zap.L().Info("serving REST gateway", zap.Int("port", *restPort))
+	var err error
+	if httpServer.TLSConfig != nil {
+		err = httpServer.ListenAndServeTLS("", "")
+	} else {
+		err = httpServer.ListenAndServe()
+	}
+	if err != http.ErrServerClosed {
+		zap.L().Fatal("REST gateway stopped", zap.Error(err))
+	}
+}
+
+// newAPIPacer returns the transport pacing Cloud API calls according to apiQPS. The pacing
+// statistics are exported as the api_pacing variable.
+func newAPIPacer() (*pacing.Transport, error) {
+	fallback := pacing.Config{MaxRetries: *apiRetries}
+	configs, err := pacing.ParseConfigs(*apiQPS, fallback)
 	if err != nil {
 		return nil, err
 	}
-	v := gcv.NewParallelValidator(stopChannel, cv)
-	return &gcvServer{
-		validator: v,
-	}, nil
+	pacer := pacing.New(nil, configs, fallback)
+	expvar.Publish("api_pacing", expvar.Func(func() interface{} { return pacer.Stats() }))
+	return pacer, nil
+}
+
+// newGroupExpander returns the enricher expanding IAM group members with the Cloud Identity API.
+func newGroupExpander(ctx context.Context, pacer *pacing.Transport) (*iammembers.Expander, error) {
+	client, err := pacing.NewHTTPClient(ctx, pacer, option.WithScopes(cloudidentity.CloudIdentityGroupsReadonlyScope))
+	if err != nil {
+		return nil, err
+	}
+	service, err := cloudidentity.NewService(ctx, option.WithHTTPClient(client))
+	if err != nil {
+		return nil, err
+	}
+	return iammembers.NewCloudIdentityExpander(service, iammembers.WithTTL(*groupCacheTTL)), nil
+}
+
+// newNotifier returns the notifier posting new violations to webhookURL in batches.
+func newNotifier() (*notify.Batcher, notify.Notifier, error) {
+	opts := []notify.WebhookOption{notify.WithFormat(*webhookFormat)}
+	if *webhookTemplate != "" {
+		text, err := ioutil.ReadFile(*webhookTemplate)
+		if err != nil {
+			return nil, nil, err
+		}
+		opts = append(opts, notify.WithTemplate(string(text)))
+	}
+	hook, err := notify.NewWebhook(*webhookURL, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	batcher := notify.NewBatcher(hook, *webhookBatchSize, *webhookFlushInterval)
+	return batcher, notify.NewDeduplicator(batcher, *webhookRenotifyAfter), nil
+}
+
+// runFeed reviews the CAI feed notifications from feedSubscription until ctx is done, exiting the
+// process if the feed cannot be set up.
+func runFeed(ctx context.Context, cv gcv.ConfigValidator, pacer *pacing.Transport, notifier notify.Notifier) {
+	if *violationsTopic == "" {
+		zap.L().Fatal("feedSubscription requires violationsTopic")
+	}
+	client, err := pacing.NewHTTPClient(ctx, pacer)
+	if err != nil {
+		zap.L().Fatal("Failed to create API client", zap.Error(err))
+	}
+	service, err := pubsub.NewService(ctx, option.WithHTTPClient(client))
+	if err != nil {
+		zap.L().Fatal("Failed to create Pub/Sub client", zap.Error(err))
+	}
+	processor := feed.NewProcessor(cv, feed.NewSubscription(service, *feedSubscription), feed.NewTopic(service, *violationsTopic))
+	processor.Notifier = notifier
+	zap.L().Info("reviewing feed", zap.String("subscription", *feedSubscription), zap.String("topic", *violationsTopic))
+	if err := processor.Run(ctx); err != context.Canceled {
+		zap.L().Fatal("Feed processing stopped", zap.Error(err))
+	}
 }
 
 func main() {
 	flag.Parse()
+	if err := logging.Setup(*logFormat, *logLevel); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to configure logging: %v\n", err)
+		os.Exit(1)
+	}
+	if *pprofAddr != "" {
+		go servePprof(*pprofAddr)
+	}
 	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", *port))
 	if err != nil {
-		log.Fatalf("failed to listen on port %d: %v", *port, err)
+		zap.L().Fatal("failed to listen", zap.Int("port", *port), zap.Error(err))
 	}
 
 	stopChannel := make(chan struct{})
 	defer close(stopChannel)
-	grpcServer := grpc.NewServer(
-		grpc.MaxRecvMsgSize(*maxMessageRecvSize),
-	)
-	policyPaths := strings.Split(*policyPath, ",")
-	serverImpl, err := newServer(stopChannel, policyPaths, *policyLibraryPath)
+	serverOpts, err := rpcconfig.ServerOptions(rpcconfig.Options{
+		MaxRecvSize: *maxMessageRecvSize,
+		MaxSendSize: *maxMessageSendSize,
+	})
+	if err != nil {
+		zap.L().Fatal("Failed to configure RPC service", zap.Error(err))
+	}
+	var tlsConfig *tls.Config
+	if *tlsCertFile != "" || *tlsKeyFile != "" {
+		tlsConfig, err = tlsconfig.NewServerConfig(tlsconfig.ServerOptions{
+			CertFile:          *tlsCertFile,
+			KeyFile:           *tlsKeyFile,
+			ClientCAFile:      *tlsClientCA,
+			RequireClientCert: *tlsRequireClientCert,
+		})
+		if err != nil {
+			zap.L().Fatal("Failed to configure TLS", zap.Error(err))
+		}
+		serverOpts = append(serverOpts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	} else if *tlsClientCA != "" || *tlsRequireClientCert {
+		zap.L().Fatal("tlsClientCAFile and tlsRequireClientCert require tlsCertFile and tlsKeyFile")
+	}
+	grpcConfig := server.GRPCConfig{ServerOptions: serverOpts}
+	guard, err := newGuard()
+	if err != nil {
+		zap.L().Fatal("Failed to configure authentication", zap.Error(err))
+	}
+	if guard != nil {
+		grpcConfig.UnaryInterceptors = append(grpcConfig.UnaryInterceptors, guard.UnaryServerInterceptor())
+		grpcConfig.StreamInterceptors = append(grpcConfig.StreamInterceptors, guard.StreamServerInterceptor())
+	}
+	sets, err := parsePolicySets(*policyPath, *policySets)
+	if err != nil {
+		zap.L().Fatal("Failed to configure policy sets", zap.Error(err))
+	}
+	// Responses and published violations only name the assets, so results need not hold on to them.
+	validatorOpts := []gcv.Option{
+		gcv.WithResultCache(*resultCacheSize), gcv.WithClientPool(*clientPoolSize), gcv.WithoutResourceBodies()}
+	if *multiTargetReview {
+		validatorOpts = append(validatorOpts, gcv.WithMultiTargetReview())
+	}
+	if *policyVersion != "" {
+		validatorOpts = append(validatorOpts, gcv.WithPolicyVersion(*policyVersion))
+	}
+	if *bundles != "" {
+		validatorOpts = append(validatorOpts, gcv.WithBundles(strings.Split(*bundles, ",")...))
+	}
+	if *assetTransforms != "" {
+		transformer, err := transform.Load(*assetTransforms)
+		if err != nil {
+			zap.L().Fatal("Failed to load asset transforms", zap.Error(err))
+		}
+		validatorOpts = append(validatorOpts, gcv.WithTransformer(transformer))
+	}
+	if *redactFields != "" {
+		redactor, err := redact.New(strings.Split(*redactFields, ","))
+		if err != nil {
+			zap.L().Fatal("Failed to configure redaction", zap.Error(err))
+		}
+		validatorOpts = append(validatorOpts, gcv.WithRedactor(redactor))
+	}
+	pacer, err := newAPIPacer()
+	if err != nil {
+		zap.L().Fatal("Failed to configure API pacing", zap.Error(err))
+	}
+	if *expandGroupMembers {
+		expander, err := newGroupExpander(context.Background(), pacer)
+		if err != nil {
+			zap.L().Fatal("Failed to create Cloud Identity client", zap.Error(err))
+		}
+		validatorOpts = append(validatorOpts, gcv.WithEnricher(expander))
+	}
+	if *resolveAncestry || *ancestryMap != "" {
+		resolver, err := ancestry.New(context.Background(), ancestry.Config{
+			Resolve: *resolveAncestry,
+			Mapping: *ancestryMap,
+			Cache:   *ancestryCache,
+			NewClient: func(ctx context.Context) (*http.Client, error) {
+				return pacing.NewHTTPClient(ctx, pacer, option.WithScopes(crmv1.CloudPlatformReadOnlyScope))
+			},
+		}, ancestry.WithTTL(*ancestryCacheTTL))
+		if err != nil {
+			zap.L().Fatal("Failed to set up ancestry resolution", zap.Error(err))
+		}
+		validatorOpts = append(validatorOpts, gcv.WithAncestryResolver(resolver))
+	}
+	providers, err := externaldata.ParseHTTPProviders(*dataProviders, nil)
+	if err != nil {
+		zap.L().Fatal("Failed to configure data providers", zap.Error(err))
+	}
+	for name, provider := range providers {
+		validatorOpts = append(validatorOpts, gcv.WithDataProvider(name, provider))
+	}
+	if *quarantineFile != "" {
+		f, err := os.OpenFile(*quarantineFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+		if err != nil {
+			zap.L().Fatal("Failed to open quarantine file", zap.Error(err))
+		}
+		defer f.Close()
+		validatorOpts = append(validatorOpts, gcv.WithQuarantine(gcv.NewQuarantine(f)))
+	}
+	implOpts := []server.Option{server.WithValidatorOptions(validatorOpts...), server.WithFlags(deploymentFlags())}
+	if *maxConcurrentReviews != 0 || *maxAssetsPerSecond != 0 {
+		limiter := ratelimit.New(ratelimit.Options{
+			MaxConcurrent:   *maxConcurrentReviews,
+			AssetsPerSecond: *maxAssetsPerSecond,
+			Burst:           *assetBurst,
+		})
+		expvar.Publish("review_limits", expvar.Func(func() interface{} { return limiter.Stats() }))
+		implOpts = append(implOpts, server.WithLimiter(limiter))
+	}
+	var batcher *notify.Batcher
+	var notifier notify.Notifier
+	if *webhookURL != "" {
+		batcher, notifier, err = newNotifier()
+		if err != nil {
+			zap.L().Fatal("Failed to configure webhook notifications", zap.Error(err))
+		}
+		implOpts = append(implOpts, server.WithNotifier(notifier))
+	}
+	serverImpl, err := server.New(stopChannel, sets, *policyLibraryPath, implOpts...)
 	if err != nil {
-		log.Fatalf("Failed to load server %v", err)
+		zap.L().Fatal("Failed to load server", zap.Error(err))
+	}
+	expvar.Publish("review_memory", expvar.Func(func() interface{} { return serverImpl.MemoryStats() }))
+	grpcServer := serverImpl.NewGRPCServer(grpcConfig)
+	go serverImpl.ReloadOnHangup()
+	var restServer *http.Server
+	if *restPort != 0 {
+		restServer = newRESTServer(serverImpl, tlsConfig, guard)
+		go serveREST(restServer)
+	}
+	feedCtx, stopFeed := context.WithCancel(context.Background())
+	feedDone := make(chan struct{})
+	if *feedSubscription != "" {
+		go func() {
+			defer close(feedDone)
+			policies, _ := serverImpl.PolicySet("")
+			runFeed(feedCtx, policies, pacer, notifier)
+		}()
+	} else {
+		close(feedDone)
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- grpcServer.Serve(lis)
+	}()
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+	select {
+	case err := <-serveErr:
+		zap.L().Fatal("RPC server ungracefully stopped", zap.Error(err))
+	case sig := <-signals:
+		zap.L().Info("shutting down", zap.String("signal", sig.String()), zap.Duration("timeout", *shutdownTimeout))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancel()
+	// Feed notifications interrupted by the shutdown are not acknowledged and will be redelivered.
+	stopFeed()
+	drain(ctx, grpcServer, restServer)
+	<-feedDone
+	serverImpl.WaitNotifications()
+	if batcher != nil {
+		if err := batcher.Close(ctx); err != nil {
+			zap.L().Error("failed to send pending notifications", zap.Error(err))
+		}
+	}
+	zap.L().Info("server stopped", zap.Any("api_pacing", pacer.Stats()), zap.Any("review_memory", serverImpl.MemoryStats()))
+	zap.L().Sync()
+}
+
+// drain stops the servers from accepting requests and waits for the in-flight requests to
+// complete until ctx is done, then cancels the remaining ones.
+func drain(ctx context.Context, grpcServer *grpc.Server, restServer *http.Server) {
+	stopped := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(stopped)
+	}()
+	if restServer != nil {
+		if err := restServer.Shutdown(ctx); err != nil {
+			zap.L().Warn("REST requests cancelled", zap.Error(err))
+			restServer.Close()
+		}
 	}
-	validator.RegisterValidatorServer(grpcServer, serverImpl)
-	if err := grpcServer.Serve(lis); err != nil {
-		glog.Fatalf("RPC server ungracefully stopped: %v", err)
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		zap.L().Warn("shutdown timeout expired, cancelling in-flight requests")
+		grpcServer.Stop()
 	}
 }
@@ -8,26 +8,32 @@ require (
 	github.com/davecgh/go-spew v1.1.1
 	github.com/ghodss/yaml v1.0.0
 	github.com/go-logr/zapr v0.1.1 // indirect
+	github.com/go-openapi/errors v0.19.2
 	github.com/go-openapi/spec v0.19.4
 	github.com/go-openapi/strfmt v0.19.3
 	github.com/go-openapi/validate v0.19.4
+	github.com/gobwas/glob v0.2.3
 	github.com/gogo/protobuf v1.3.0
-	github.com/golang/glog v0.0.0-20160126235308-23def4e6c14b
-	github.com/golang/protobuf v1.3.3
-	github.com/google/go-cmp v0.3.1
+	github.com/golang/protobuf v1.3.4
+	github.com/google/cel-go v0.4.2
+	github.com/google/go-cmp v0.4.0
 	github.com/gregjones/httpcache v0.0.0-20190611155906-901d90724c79 // indirect
+	github.com/grpc-ecosystem/grpc-gateway v1.14.3
 	github.com/hashicorp/go-multierror v1.0.0
 	github.com/imdario/mergo v0.3.7 // indirect
+	github.com/mattn/go-sqlite3 v1.14.0
 	github.com/open-policy-agent/frameworks/constraint v0.0.0-20200127222620-69dff9b895a2
 	github.com/open-policy-agent/gatekeeper v0.0.0-20200130050101-a7990e5bc83a
 	github.com/open-policy-agent/opa v0.17.2
-	github.com/pkg/errors v0.8.1
+	github.com/pkg/errors v0.9.1
 	github.com/smallfish/simpleyaml v0.0.0-20170911015856-a32031077861
 	github.com/spf13/cobra v0.0.5
 	github.com/spf13/pflag v1.0.3
-	google.golang.org/api v0.4.0
+	go.uber.org/zap v1.10.0
+	golang.org/x/time v0.0.0-20181108054448-85acf8d2951c
+	google.golang.org/api v0.15.0
 	google.golang.org/genproto v0.0.0-20200319113533-08878b785e9c
-	google.golang.org/grpc v1.27.0
+	google.golang.org/grpc v1.27.1
 	k8s.io/api v0.16.4
 	k8s.io/apiextensions-apiserver v0.16.4
 	k8s.io/apimachinery v0.16.4
@@ -0,0 +1,303 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ancestry resolves the ancestors of assets that arrive without ancestry information,
+// which is common for the assets of feed events. Without ancestors, assets have no ancestry path
+// and constraints targeting parts of the resource hierarchy silently miss them.
+//
+// The ancestors of an asset are found from its resource.parent or from the project in its name,
+// and are returned in the order of the ancestors field of CAI exports:
+//
+//	"ancestors": ["projects/123", "folders/456", "organizations/789"]
+//
+// Resolved projects and folders are cached in memory, and optionally in a Store shared by runs.
+package ancestry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/forseti-security/config-validator/pkg/gcv/configs"
+	"github.com/pkg/errors"
+	crmv1 "google.golang.org/api/cloudresourcemanager/v1"
+	crmv2 "google.golang.org/api/cloudresourcemanager/v2"
+)
+
+// defaultTTL is how long resolved resources are cached by default.
+const defaultTTL = time.Hour
+
+// maxDepth bounds the depth of the resource hierarchy, folders nest up to 10 levels.
+const maxDepth = 16
+
+// resourceManagerPrefix is the prefix of the full names of resource manager resources.
+const resourceManagerPrefix = "//cloudresourcemanager.googleapis.com/"
+
+// Hierarchy looks up the resources of the resource hierarchy.
+type Hierarchy interface {
+	// Lookup returns the canonical name of the project or folder name, such as projects/123 for
+	// projects/my-project, and the name of its parent, which is a folder or an organization.
+	Lookup(ctx context.Context, name string) (canonical, parent string, err error)
+}
+
+// Entry is a resolved resource of the hierarchy.
+type Entry struct {
+	// Name is the canonical name of the resource.
+	Name   string `json:"name"`
+	Parent string `json:"parent"`
+	// Resolved is when the resource was looked up.
+	Resolved time.Time `json:"resolved"`
+}
+
+// Store persists resolved resources, keyed by the name they were looked up with.
+type Store interface {
+	Load(ctx context.Context) (map[string]*Entry, error)
+	Save(ctx context.Context, entries map[string]*Entry) error
+}
+
+// Option configures optional Resolver behavior.
+type Option func(*Resolver)
+
+// WithTTL sets how long resolved resources are cached, defaults to an hour. Entries loaded from
+// a Store expire a TTL after they were resolved.
+func WithTTL(ttl time.Duration) Option {
+	return func(r *Resolver) {
+		r.ttl = ttl
+	}
+}
+
+// WithStore loads cached resources from store on first use, and saves them to it whenever a
+// resource is resolved.
+func WithStore(store Store) Option {
+	return func(r *Resolver) {
+		r.store = store
+	}
+}
+
+// WithOverrides uses the ancestry of the projects and folders in mapping instead of looking them
+// up, so that the mapping can fill in for resources the hierarchy cannot see.
+func WithOverrides(mapping *Mapping) Option {
+	return func(r *Resolver) {
+		r.overrides = mapping
+	}
+}
+
+// Resolver resolves the ancestors of assets. It is safe for concurrent use.
+type Resolver struct {
+	hierarchy Hierarchy
+	ttl       time.Duration
+	now       func() time.Time
+	store     Store
+	overrides *Mapping
+
+	mu     sync.Mutex
+	loaded bool
+	cache  map[string]*Entry
+}
+
+// NewResolver returns a Resolver looking up resources with hierarchy.
+func NewResolver(hierarchy Hierarchy, opts ...Option) *Resolver {
+	r := &Resolver{
+		hierarchy: hierarchy,
+		ttl:       defaultTTL,
+		now:       time.Now,
+		cache:     map[string]*Entry{},
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// NewResourceManagerResolver returns a Resolver looking up projects with the v1 and folders with
+// the v2 Cloud Resource Manager API. The services require the
+// resourcemanager.projects.get and resourcemanager.folders.get permissions.
+func NewResourceManagerResolver(projects *crmv1.Service, folders *crmv2.Service, opts ...Option) *Resolver {
+	return NewResolver(&resourceManager{projects: projects, folders: folders}, opts...)
+}
+
+// Ancestors returns the ancestors of the asset with the full resource name name and the parent
+// of its resource, which may be empty. It returns nil if the asset has no parent that can be
+// told from either.
+func (r *Resolver) Ancestors(ctx context.Context, name, parent string) ([]string, error) {
+	node := startNode(name, parent)
+	var ancestors []string
+	for node != "" {
+		if len(ancestors) == maxDepth {
+			return nil, errors.Errorf("ancestry of %s is deeper than %d levels: %s", name, maxDepth, strings.Join(ancestors, ", "))
+		}
+		if strings.HasPrefix(node, "organizations/") {
+			// Organizations are the root of the hierarchy, there is nothing to look up.
+			ancestors = append(ancestors, node)
+			break
+		}
+		if override := r.overrides.lookup(node); override != nil {
+			ancestors = append(ancestors, override...)
+			break
+		}
+		entry, err := r.lookup(ctx, node)
+		if err != nil {
+			return nil, err
+		}
+		ancestors = append(ancestors, entry.Name)
+		node = entry.Parent
+	}
+	return ancestors, nil
+}
+
+// lookup returns the cached entry of the project or folder name, looking it up if needed.
+func (r *Resolver) lookup(ctx context.Context, name string) (*Entry, error) {
+	r.mu.Lock()
+	if err := r.load(ctx); err != nil {
+		r.mu.Unlock()
+		return nil, err
+	}
+	entry, found := r.cache[name]
+	r.mu.Unlock()
+	if found && r.now().Before(entry.Resolved.Add(r.ttl)) {
+		return entry, nil
+	}
+
+	canonical, parent, err := r.hierarchy.Lookup(ctx, name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to look up %s", name)
+	}
+	entry = &Entry{Name: canonical, Parent: parent, Resolved: r.now()}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cache[name] = entry
+	r.cache[canonical] = entry
+	if r.store == nil {
+		return entry, nil
+	}
+	return entry, errors.Wrapf(r.store.Save(ctx, r.cache), "failed to save resolved ancestry")
+}
+
+// load loads the store into the cache once. It must be called with mu held.
+func (r *Resolver) load(ctx context.Context) error {
+	if r.loaded || r.store == nil {
+		return nil
+	}
+	entries, err := r.store.Load(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "failed to load resolved ancestry")
+	}
+	for name, entry := range entries {
+		r.cache[name] = entry
+	}
+	r.loaded = true
+	return nil
+}
+
+// startNode returns the lowest resource of the hierarchy the asset with name and parent belongs
+// to: the asset itself for projects, folders and organizations, then its parent, then the first
+// project, folder or organization in its name.
+func startNode(name, parent string) string {
+	if strings.HasPrefix(name, resourceManagerPrefix) {
+		if node := hierarchyNode(strings.TrimPrefix(name, resourceManagerPrefix)); node != "" {
+			return node
+		}
+	}
+	if node := hierarchyNode(strings.TrimPrefix(parent, resourceManagerPrefix)); node != "" {
+		return node
+	}
+	parts := strings.Split(strings.TrimPrefix(name, "//"), "/")
+	// The first part is the service.
+	for idx := 1; idx+1 < len(parts); idx++ {
+		if node := hierarchyNode(parts[idx] + "/" + parts[idx+1]); node != "" {
+			return node
+		}
+	}
+	return ""
+}
+
+// hierarchyNode returns path if it names a project, folder or organization, empty otherwise.
+func hierarchyNode(path string) string {
+	parts := strings.Split(path, "/")
+	if len(parts) != 2 || parts[1] == "" {
+		return ""
+	}
+	switch parts[0] {
+	case "projects", "folders", "organizations":
+		return path
+	}
+	return ""
+}
+
+// resourceManager looks up the hierarchy with the Cloud Resource Manager API.
+type resourceManager struct {
+	projects *crmv1.Service
+	folders  *crmv2.Service
+}
+
+var _ Hierarchy = &resourceManager{}
+
+// Lookup implements Hierarchy.
+func (h *resourceManager) Lookup(ctx context.Context, name string) (string, string, error) {
+	if strings.HasPrefix(name, "folders/") {
+		folder, err := h.folders.Folders.Get(name).Context(ctx).Do()
+		if err != nil {
+			return "", "", err
+		}
+		return folder.Name, folder.Parent, nil
+	}
+	project, err := h.projects.Projects.Get(strings.TrimPrefix(name, "projects/")).Context(ctx).Do()
+	if err != nil {
+		return "", "", err
+	}
+	var parent string
+	if project.Parent != nil {
+		// Parents are of type folder or organization.
+		parent = project.Parent.Type + "s/" + project.Parent.Id
+	}
+	return fmt.Sprintf("projects/%d", project.ProjectNumber), parent, nil
+}
+
+// fileStore stores resolved resources as JSON in a local file or Cloud Storage object.
+type fileStore struct {
+	path string
+}
+
+var _ Store = &fileStore{}
+
+// NewFileStore returns a Store keeping resolved resources as JSON in the file or gs:// object
+// at path, which is created when the first resource is resolved.
+func NewFileStore(path string) Store {
+	return &fileStore{path: path}
+}
+
+// Load implements Store.
+func (s *fileStore) Load(ctx context.Context) (map[string]*Entry, error) {
+	content, found, err := configs.ReadFile(ctx, s.path)
+	if err != nil || !found {
+		return nil, err
+	}
+	entries := map[string]*Entry{}
+	if err := json.Unmarshal(content, &entries); err != nil {
+		return nil, errors.Wrapf(err, "invalid ancestry cache %s", s.path)
+	}
+	return entries, nil
+}
+
+// Save implements Store.
+func (s *fileStore) Save(ctx context.Context, entries map[string]*Entry) error {
+	content, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	return configs.WriteFile(ctx, s.path, content)
+}
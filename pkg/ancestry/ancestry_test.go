@@ -0,0 +1,243 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ancestry
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	crmv1 "google.golang.org/api/cloudresourcemanager/v1"
+	crmv2 "google.golang.org/api/cloudresourcemanager/v2"
+)
+
+// fakeHierarchy serves resources from a map and counts lookups.
+type fakeHierarchy struct {
+	resources map[string][2]string
+	lookups   map[string]int
+}
+
+func newFakeHierarchy() *fakeHierarchy {
+	return &fakeHierarchy{
+		resources: map[string][2]string{
+			"projects/my-project": {"projects/123", "folders/456"},
+			"projects/123":        {"projects/123", "folders/456"},
+			"projects/orphan":     {"projects/789", ""},
+			"folders/456":         {"folders/456", "folders/1"},
+			"folders/1":           {"folders/1", "organizations/2"},
+			"folders/loop":        {"folders/loop", "folders/loop"},
+		},
+		lookups: map[string]int{},
+	}
+}
+
+func (f *fakeHierarchy) Lookup(ctx context.Context, name string) (string, string, error) {
+	f.lookups[name]++
+	resource, found := f.resources[name]
+	if !found {
+		return "", "", errors.Errorf("%s not found", name)
+	}
+	return resource[0], resource[1], nil
+}
+
+func TestAncestors(t *testing.T) {
+	var testCases = []struct {
+		name   string
+		asset  string
+		parent string
+		want   []string
+	}{
+		{
+			name:  "project in name",
+			asset: "//compute.googleapis.com/projects/my-project/zones/us-central1-a/instances/vm",
+			want:  []string{"projects/123", "folders/456", "folders/1", "organizations/2"},
+		},
+		{
+			name:   "parent",
+			asset:  "//storage.googleapis.com/my-bucket",
+			parent: "//cloudresourcemanager.googleapis.com/projects/123",
+			want:   []string{"projects/123", "folders/456", "folders/1", "organizations/2"},
+		},
+		{
+			name:   "project",
+			asset:  "//cloudresourcemanager.googleapis.com/projects/123",
+			parent: "//cloudresourcemanager.googleapis.com/folders/456",
+			want:   []string{"projects/123", "folders/456", "folders/1", "organizations/2"},
+		},
+		{
+			name:  "folder",
+			asset: "//cloudresourcemanager.googleapis.com/folders/1",
+			want:  []string{"folders/1", "organizations/2"},
+		},
+		{
+			name:  "organization",
+			asset: "//cloudresourcemanager.googleapis.com/organizations/2",
+			want:  []string{"organizations/2"},
+		},
+		{
+			name:  "organization in name",
+			asset: "//logging.googleapis.com/organizations/2/sinks/audit",
+			want:  []string{"organizations/2"},
+		},
+		{
+			name:  "project without parent",
+			asset: "//iam.googleapis.com/projects/orphan/serviceAccounts/sa@orphan.iam.gserviceaccount.com",
+			want:  []string{"projects/789"},
+		},
+		{
+			name:  "unknown",
+			asset: "//storage.googleapis.com/my-bucket",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := NewResolver(newFakeHierarchy())
+			got, err := r.Ancestors(context.Background(), tc.asset, tc.parent)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected ancestors (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestAncestorsErrors(t *testing.T) {
+	r := NewResolver(newFakeHierarchy())
+	if _, err := r.Ancestors(context.Background(), "//compute.googleapis.com/projects/missing/global/networks/n", ""); err == nil {
+		t.Error("expected error for unknown project")
+	}
+	if _, err := r.Ancestors(context.Background(), "//cloudresourcemanager.googleapis.com/folders/loop", ""); err == nil {
+		t.Error("expected error for cyclic hierarchy")
+	}
+}
+
+func TestAncestorsCache(t *testing.T) {
+	ctx := context.Background()
+	h := newFakeHierarchy()
+	now := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+	r := NewResolver(h, WithTTL(time.Minute))
+	r.now = func() time.Time { return now }
+
+	for _, name := range []string{
+		"//compute.googleapis.com/projects/my-project/global/networks/a",
+		"//compute.googleapis.com/projects/my-project/global/networks/b",
+		"//cloudresourcemanager.googleapis.com/projects/123",
+	} {
+		if _, err := r.Ancestors(ctx, name, ""); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	// Projects are cached by ID and number.
+	want := map[string]int{"projects/my-project": 1, "folders/456": 1, "folders/1": 1}
+	if diff := cmp.Diff(want, h.lookups); diff != "" {
+		t.Errorf("unexpected lookups (-want +got):\n%s", diff)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, err := r.Ancestors(ctx, "//cloudresourcemanager.googleapis.com/folders/1", ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if h.lookups["folders/1"] != 2 {
+		t.Errorf("got %d lookups of expired folders/1, want 2", h.lookups["folders/1"])
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "ancestry")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "ancestry.json")
+
+	first := newFakeHierarchy()
+	if _, err := NewResolver(first, WithStore(NewFileStore(path))).Ancestors(ctx, "//cloudresourcemanager.googleapis.com/projects/123", ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("store was not saved: %v", err)
+	}
+
+	// A resolver sharing the store looks up nothing again.
+	second := newFakeHierarchy()
+	got, err := NewResolver(second, WithStore(NewFileStore(path))).Ancestors(ctx, "//compute.googleapis.com/projects/123/global/networks/n", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if diff := cmp.Diff([]string{"projects/123", "folders/456", "folders/1", "organizations/2"}, got); diff != "" {
+		t.Errorf("unexpected ancestors (-want +got):\n%s", diff)
+	}
+	if len(second.lookups) != 0 {
+		t.Errorf("got lookups %v, want none", second.lookups)
+	}
+
+	if err := ioutil.WriteFile(path, []byte("not json"), 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := NewResolver(second, WithStore(NewFileStore(path))).Ancestors(ctx, "//cloudresourcemanager.googleapis.com/projects/123", ""); err == nil {
+		t.Error("expected error for invalid store")
+	}
+}
+
+func TestResourceManager(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/projects/my-project":
+			_ = json.NewEncoder(w).Encode(&crmv1.Project{
+				ProjectId:     "my-project",
+				ProjectNumber: 123,
+				Parent:        &crmv1.ResourceId{Type: "folder", Id: "456"},
+			})
+		case "/v2/folders/456":
+			_ = json.NewEncoder(w).Encode(&crmv2.Folder{Name: "folders/456", Parent: "organizations/789"})
+		default:
+			http.Error(w, `{"error": {"code": 404, "message": "not found"}}`, http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	projects, err := crmv1.New(server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	projects.BasePath = server.URL + "/"
+	folders, err := crmv2.New(server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	folders.BasePath = server.URL + "/"
+
+	r := NewResourceManagerResolver(projects, folders)
+	got, err := r.Ancestors(context.Background(), "//compute.googleapis.com/projects/my-project/global/networks/n", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if diff := cmp.Diff([]string{"projects/123", "folders/456", "organizations/789"}, got); diff != "" {
+		t.Errorf("unexpected ancestors (-want +got):\n%s", diff)
+	}
+	if _, err := r.Ancestors(context.Background(), "//compute.googleapis.com/projects/other/global/networks/n", ""); err == nil {
+		t.Error("expected error for unknown project")
+	}
+}
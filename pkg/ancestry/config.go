@@ -0,0 +1,85 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ancestry
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/pkg/errors"
+	crmv1 "google.golang.org/api/cloudresourcemanager/v1"
+	crmv2 "google.golang.org/api/cloudresourcemanager/v2"
+	"google.golang.org/api/option"
+)
+
+// Source returns the ancestors of assets, as Resolver and Mapping do.
+type Source interface {
+	Ancestors(ctx context.Context, name, parent string) ([]string, error)
+}
+
+// Config selects the sources of ancestry combined by New, as set by the ancestry flags of the
+// commands.
+type Config struct {
+	// Resolve looks up projects and folders with the Cloud Resource Manager API.
+	Resolve bool
+	// Mapping is the local path or gs:// URL of a mapping file. Its ancestry takes precedence
+	// over the API, or is used alone without Resolve.
+	Mapping string
+	// Cache is the local path or gs:// URL of the file caching the resources looked up with the
+	// API, for later runs to reuse.
+	Cache string
+	// NewClient optionally returns the HTTP client of the API, which is called only with Resolve.
+	// The API is called with the application default credentials otherwise.
+	NewClient func(ctx context.Context) (*http.Client, error)
+}
+
+// New returns the source of ancestry selected by config. opts configure the Resolver of the
+// Cloud Resource Manager API.
+func New(ctx context.Context, config Config, opts ...Option) (Source, error) {
+	opts = append([]Option{}, opts...)
+	if config.Mapping != "" {
+		m, err := ReadMapping(ctx, config.Mapping)
+		if err != nil {
+			return nil, err
+		}
+		if !config.Resolve {
+			return m, nil
+		}
+		opts = append(opts, WithOverrides(m))
+	}
+	if !config.Resolve {
+		return nil, errors.New("ancestry requires a mapping or the Cloud Resource Manager API")
+	}
+	clientOpts := []option.ClientOption{option.WithScopes(crmv1.CloudPlatformReadOnlyScope)}
+	if config.NewClient != nil {
+		client, err := config.NewClient(ctx)
+		if err != nil {
+			return nil, err
+		}
+		clientOpts = []option.ClientOption{option.WithHTTPClient(client)}
+	}
+	projects, err := crmv1.NewService(ctx, clientOpts...)
+	if err != nil {
+		return nil, err
+	}
+	folders, err := crmv2.NewService(ctx, clientOpts...)
+	if err != nil {
+		return nil, err
+	}
+	if config.Cache != "" {
+		opts = append(opts, WithStore(NewFileStore(config.Cache)))
+	}
+	return NewResourceManagerResolver(projects, folders, opts...), nil
+}
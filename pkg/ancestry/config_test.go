@@ -0,0 +1,127 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ancestry
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	crmv1 "google.golang.org/api/cloudresourcemanager/v1"
+)
+
+// redirectTransport sends requests to the server at url.
+type redirectTransport struct {
+	url *url.URL
+}
+
+func (t *redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req.URL.Scheme, req.URL.Host = t.url.Scheme, t.url.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestNew(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "ancestry")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	mapping := filepath.Join(dir, "ancestry.yaml")
+	if err := ioutil.WriteFile(mapping, []byte("folders/456: organizations/9/folders/456"), 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/projects/my-project" {
+			http.Error(w, `{"error": {"code": 404, "message": "not found"}}`, http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(&crmv1.Project{
+			ProjectId:     "my-project",
+			ProjectNumber: 123,
+			Parent:        &crmv1.ResourceId{Type: "folder", Id: "456"},
+		})
+	}))
+	defer server.Close()
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	newClient := func(ctx context.Context) (*http.Client, error) {
+		return &http.Client{Transport: &redirectTransport{url: serverURL}}, nil
+	}
+
+	var testCases = []struct {
+		name    string
+		config  Config
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:   "mapping",
+			config: Config{Mapping: mapping},
+			want:   []string{"folders/456", "organizations/9"},
+		},
+		{
+			name:   "API with mapping overrides",
+			config: Config{Resolve: true, Mapping: mapping, Cache: filepath.Join(dir, "cache.json"), NewClient: newClient},
+			want:   []string{"projects/123", "folders/456", "organizations/9"},
+		},
+		{
+			name:    "missing mapping",
+			config:  Config{Mapping: filepath.Join(dir, "missing.yaml")},
+			wantErr: true,
+		},
+		{
+			name:    "neither",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			source, err := New(ctx, tc.config)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			name := "//cloudresourcemanager.googleapis.com/folders/456"
+			if tc.config.Resolve {
+				name = "//compute.googleapis.com/projects/my-project/global/networks/n"
+			}
+			got, err := source.Ancestors(ctx, name, "")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected ancestors (-want +got):\n%s", diff)
+			}
+		})
+	}
+	if _, err := os.Stat(filepath.Join(dir, "cache.json")); err != nil {
+		t.Errorf("looked up resources were not cached: %v", err)
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ancestry
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/forseti-security/config-validator/pkg/gcv/configs"
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+)
+
+// Mapping holds the ancestry of projects and folders given by a file, for runs that cannot reach
+// the Resource Manager API. Mapping files are YAML or JSON objects of ancestry paths, from the
+// organization down to the resource, by project ID, project number or folder:
+//
+//	my-project: organizations/1/folders/2/projects/123
+//	"456": organizations/1/projects/456
+//	folders/2: organizations/1/folders/2
+//
+// A Mapping resolves the ancestors of assets like a Resolver does, and can override the
+// ancestry looked up by a Resolver with WithOverrides.
+type Mapping struct {
+	// ancestors holds the ancestors of each resource in the order of CAI exports.
+	ancestors map[string][]string
+}
+
+// ReadMapping reads the mapping file at path, a local path or gs:// URL.
+func ReadMapping(ctx context.Context, path string) (*Mapping, error) {
+	content, found, err := configs.ReadFile(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, errors.Errorf("ancestry mapping %s does not exist", path)
+	}
+	m, err := ParseMapping(content)
+	return m, errors.Wrapf(err, "invalid ancestry mapping %s", path)
+}
+
+// ParseMapping parses the content of a mapping file.
+func ParseMapping(content []byte) (*Mapping, error) {
+	paths := map[string]string{}
+	if err := yaml.Unmarshal(content, &paths); err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(paths))
+	for key := range paths {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	m := &Mapping{ancestors: map[string][]string{}}
+	for _, key := range keys {
+		node := key
+		if !strings.Contains(key, "/") {
+			node = "projects/" + key
+		}
+		if hierarchyNode(node) == "" || strings.HasPrefix(node, "organizations/") {
+			return nil, errors.Errorf("%q is not a project or folder", key)
+		}
+		ancestors, err := parseAncestryPath(paths[key])
+		if err != nil {
+			return nil, errors.Wrapf(err, "ancestry of %s", key)
+		}
+		kind := strings.SplitN(node, "/", 2)[0]
+		if !strings.HasPrefix(ancestors[0], kind+"/") {
+			return nil, errors.Errorf("ancestry of %s ends with %s, want one of %s", key, ancestors[0], kind)
+		}
+		m.ancestors[node] = ancestors
+	}
+	return m, nil
+}
+
+// parseAncestryPath returns the ancestors of the ancestry path, nearest first.
+func parseAncestryPath(path string) ([]string, error) {
+	parts := strings.Split(configs.NormalizeAncestry(path), "/")
+	if len(parts)%2 != 0 {
+		return nil, errors.Errorf("%q is not a sequence of kinds and IDs", path)
+	}
+	ancestors := make([]string, 0, len(parts)/2)
+	for idx := len(parts) - 2; idx >= 0; idx -= 2 {
+		node := hierarchyNode(parts[idx] + "/" + parts[idx+1])
+		if node == "" {
+			return nil, errors.Errorf("unexpected %s/%s in %q, want organizations, folders or projects", parts[idx], parts[idx+1], path)
+		}
+		ancestors = append(ancestors, node)
+	}
+	return ancestors, nil
+}
+
+// Ancestors returns the ancestors of the asset with the full resource name name and the parent
+// of its resource, as Resolver.Ancestors does. It returns nil if the mapping does not cover the
+// asset.
+func (m *Mapping) Ancestors(ctx context.Context, name, parent string) ([]string, error) {
+	node := startNode(name, parent)
+	if strings.HasPrefix(node, "organizations/") {
+		return []string{node}, nil
+	}
+	return m.lookup(node), nil
+}
+
+// lookup returns a copy of the ancestors of the project or folder node, nil if there are none or
+// m is nil.
+func (m *Mapping) lookup(node string) []string {
+	if m == nil {
+		return nil
+	}
+	ancestors, found := m.ancestors[node]
+	if !found {
+		return nil
+	}
+	return append([]string{}, ancestors...)
+}
@@ -0,0 +1,138 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ancestry
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const testMapping = `
+my-project: organizations/1/folders/2/projects/123
+"456": organization/1/project/456
+folders/2: organizations/1/folders/2
+`
+
+func TestMapping(t *testing.T) {
+	m, err := ParseMapping([]byte(testMapping))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var testCases = []struct {
+		name   string
+		asset  string
+		parent string
+		want   []string
+	}{
+		{
+			name:  "project ID",
+			asset: "//compute.googleapis.com/projects/my-project/zones/us-central1-a/instances/vm",
+			want:  []string{"projects/123", "folders/2", "organizations/1"},
+		},
+		{
+			name:   "project number",
+			asset:  "//storage.googleapis.com/my-bucket",
+			parent: "//cloudresourcemanager.googleapis.com/projects/456",
+			want:   []string{"projects/456", "organizations/1"},
+		},
+		{
+			name:  "folder",
+			asset: "//cloudresourcemanager.googleapis.com/folders/2",
+			want:  []string{"folders/2", "organizations/1"},
+		},
+		{
+			name:  "organization",
+			asset: "//cloudresourcemanager.googleapis.com/organizations/1",
+			want:  []string{"organizations/1"},
+		},
+		{
+			name:  "unmapped project",
+			asset: "//compute.googleapis.com/projects/other/global/networks/default",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := m.Ancestors(context.Background(), tc.asset, tc.parent)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected ancestors (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParseMappingErrors(t *testing.T) {
+	for _, content := range []string{
+		"- organizations/1/projects/123",
+		"organizations/1: organizations/1",
+		"buckets/b: organizations/1/projects/123",
+		"my-project: organizations/1/projects",
+		"my-project: organizations/1/buckets/123",
+		"my-project: organizations/1/folders/2",
+	} {
+		if _, err := ParseMapping([]byte(content)); err == nil {
+			t.Errorf("ParseMapping(%q) succeeded, want error", content)
+		}
+	}
+}
+
+func TestReadMapping(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "mapping")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "ancestry.yaml")
+	if _, err := ReadMapping(ctx, path); err == nil {
+		t.Errorf("reading missing mapping succeeded, want error")
+	}
+	if err := ioutil.WriteFile(path, []byte(testMapping), 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	m, err := ReadMapping(ctx, path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := m.lookup("projects/my-project"); len(got) != 3 {
+		t.Errorf("got ancestors %v, want 3", got)
+	}
+}
+
+func TestResolverOverrides(t *testing.T) {
+	m, err := ParseMapping([]byte("folders/456: organizations/9/folders/456"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	h := newFakeHierarchy()
+	r := NewResolver(h, WithOverrides(m))
+	got, err := r.Ancestors(context.Background(), "//compute.googleapis.com/projects/my-project/global/networks/default", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if diff := cmp.Diff([]string{"projects/123", "folders/456", "organizations/9"}, got); diff != "" {
+		t.Errorf("unexpected ancestors (-want +got):\n%s", diff)
+	}
+	if h.lookups["folders/456"] != 0 {
+		t.Errorf("overridden folder was looked up")
+	}
+}
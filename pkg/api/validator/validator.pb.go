@@ -8,6 +8,7 @@ import (
 	fmt "fmt"
 	proto "github.com/golang/protobuf/proto"
 	_struct "github.com/golang/protobuf/ptypes/struct"
+	_ "google.golang.org/genproto/googleapis/api/annotations"
 	v1 "google.golang.org/genproto/googleapis/cloud/asset/v1"
 	v12 "google.golang.org/genproto/googleapis/cloud/orgpolicy/v1"
 	v11 "google.golang.org/genproto/googleapis/iam/v1"
@@ -29,6 +30,31 @@ var _ = math.Inf
 // proto package needs to be updated.
 const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
 
+type Diagnostic_Severity int32
+
+const (
+	Diagnostic_ERROR   Diagnostic_Severity = 0
+	Diagnostic_WARNING Diagnostic_Severity = 1
+)
+
+var Diagnostic_Severity_name = map[int32]string{
+	0: "ERROR",
+	1: "WARNING",
+}
+
+var Diagnostic_Severity_value = map[string]int32{
+	"ERROR":   0,
+	"WARNING": 1,
+}
+
+func (x Diagnostic_Severity) String() string {
+	return proto.EnumName(Diagnostic_Severity_name, int32(x))
+}
+
+func (Diagnostic_Severity) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_bf1c6ec7c0d80dd5, []int{15, 0}
+}
+
 // Asset contains GCP resource metadata and additional metadata set on a resource, such as Cloud IAM policy.
 // WARNING: these field names are directly used to structure data passed to templates.
 // Changes in field names will result in changes to the data provided to the templates.
@@ -250,11 +276,21 @@ type Violation struct {
 	Message string `protobuf:"bytes,3,opt,name=message,proto3" json:"message,omitempty"`
 	// Metadata is optional. It contains the constraint-specific information that can potentially be used for remediation.
 	// Example: In a firewall rule constraint violation, Metadata can contain the open port number.
+	// Integers that cannot be represented exactly as a double (e.g. large int64 IDs) are encoded as
+	// decimal strings, following the proto3 JSON mapping for int64.
 	Metadata *_struct.Value `protobuf:"bytes,4,opt,name=metadata,proto3" json:"metadata,omitempty"`
 	// The full constraint configuration.
 	ConstraintConfig *Constraint `protobuf:"bytes,5,opt,name=constraint_config,json=constraintConfig,proto3" json:"constraint_config,omitempty"`
 	// The constraint severity
-	Severity             string   `protobuf:"bytes,6,opt,name=severity,proto3" json:"severity,omitempty"`
+	Severity string `protobuf:"bytes,6,opt,name=severity,proto3" json:"severity,omitempty"`
+	// Version of the policy set the violation was found with. This is the declared version of the
+	// policy set if one was set, the content hash of its templates and constraints otherwise.
+	PolicyVersion string `protobuf:"bytes,7,opt,name=policy_version,json=policyVersion,proto3" json:"policy_version,omitempty"`
+	// Guidance on fixing the violation, from the remediation annotation of the constraint.
+	Remediation string `protobuf:"bytes,8,opt,name=remediation,proto3" json:"remediation,omitempty"`
+	// Link to documentation on fixing the violation, from the remediationURL annotation of the
+	// constraint.
+	RemediationUrl       string   `protobuf:"bytes,9,opt,name=remediation_url,json=remediationUrl,proto3" json:"remediation_url,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -327,6 +363,27 @@ func (m *Violation) GetSeverity() string {
 	return ""
 }
 
+func (m *Violation) GetPolicyVersion() string {
+	if m != nil {
+		return m.PolicyVersion
+	}
+	return ""
+}
+
+func (m *Violation) GetRemediation() string {
+	if m != nil {
+		return m.Remediation
+	}
+	return ""
+}
+
+func (m *Violation) GetRemediationUrl() string {
+	if m != nil {
+		return m.RemediationUrl
+	}
+	return ""
+}
+
 type AddDataRequest struct {
 	Assets               []*Asset `protobuf:"bytes,1,rep,name=assets,proto3" json:"assets,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
@@ -530,7 +587,12 @@ func (m *ResetResponse) XXX_DiscardUnknown() {
 var xxx_messageInfo_ResetResponse proto.InternalMessageInfo
 
 type ReviewRequest struct {
-	Assets               []*Asset `protobuf:"bytes,1,rep,name=assets,proto3" json:"assets,omitempty"`
+	Assets []*Asset `protobuf:"bytes,1,rep,name=assets,proto3" json:"assets,omitempty"`
+	// Name of the policy set the assets are reviewed against, the default set if empty.
+	PolicySet string `protobuf:"bytes,2,opt,name=policy_set,json=policySet,proto3" json:"policy_set,omitempty"`
+	// Maximum number of violations in each response of StreamReview, 500 if not set. Ignored by
+	// Review.
+	BatchSize            int32    `protobuf:"varint,3,opt,name=batch_size,json=batchSize,proto3" json:"batch_size,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -568,6 +630,20 @@ func (m *ReviewRequest) GetAssets() []*Asset {
 	return nil
 }
 
+func (m *ReviewRequest) GetPolicySet() string {
+	if m != nil {
+		return m.PolicySet
+	}
+	return ""
+}
+
+func (m *ReviewRequest) GetBatchSize() int32 {
+	if m != nil {
+		return m.BatchSize
+	}
+	return 0
+}
+
 type ReviewResponse struct {
 	Violations           []*Violation `protobuf:"bytes,1,rep,name=violations,proto3" json:"violations,omitempty"`
 	XXX_NoUnkeyedLiteral struct{}     `json:"-"`
@@ -607,7 +683,607 @@ func (m *ReviewResponse) GetViolations() []*Violation {
 	return nil
 }
 
+type GetCapabilitiesRequest struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetCapabilitiesRequest) Reset()         { *m = GetCapabilitiesRequest{} }
+func (m *GetCapabilitiesRequest) String() string { return proto.CompactTextString(m) }
+func (*GetCapabilitiesRequest) ProtoMessage()    {}
+func (*GetCapabilitiesRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_bf1c6ec7c0d80dd5, []int{11}
+}
+
+func (m *GetCapabilitiesRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetCapabilitiesRequest.Unmarshal(m, b)
+}
+func (m *GetCapabilitiesRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetCapabilitiesRequest.Marshal(b, m, deterministic)
+}
+func (m *GetCapabilitiesRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetCapabilitiesRequest.Merge(m, src)
+}
+func (m *GetCapabilitiesRequest) XXX_Size() int {
+	return xxx_messageInfo_GetCapabilitiesRequest.Size(m)
+}
+func (m *GetCapabilitiesRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetCapabilitiesRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetCapabilitiesRequest proto.InternalMessageInfo
+
+// GetCapabilitiesResponse describes what the server supports, for orchestrating systems to adapt
+// to deployments of different versions.
+type GetCapabilitiesResponse struct {
+	// Version of the validator binary.
+	ValidatorVersion string `protobuf:"bytes,1,opt,name=validator_version,json=validatorVersion,proto3" json:"validator_version,omitempty"`
+	// Content hash of this API definition, which changes with every change of the API.
+	ProtoVersion string `protobuf:"bytes,2,opt,name=proto_version,json=protoVersion,proto3" json:"proto_version,omitempty"`
+	// Names of the RPC methods of the Validator service.
+	Methods []string `protobuf:"bytes,3,rep,name=methods,proto3" json:"methods,omitempty"`
+	// Version of Open Policy Agent evaluating Rego templates.
+	OpaVersion string `protobuf:"bytes,4,opt,name=opa_version,json=opaVersion,proto3" json:"opa_version,omitempty"`
+	// Names of the Constraint Framework targets constraints are written for.
+	Targets []string `protobuf:"bytes,5,rep,name=targets,proto3" json:"targets,omitempty"`
+	// Formats of the CAI exports the validator reads.
+	InputFormats []string `protobuf:"bytes,6,rep,name=input_formats,json=inputFormats,proto3" json:"input_formats,omitempty"`
+	// Optional features supported by the binary.
+	Features []string `protobuf:"bytes,7,rep,name=features,proto3" json:"features,omitempty"`
+	// Whether features configured by the server flags are enabled in this deployment.
+	Flags map[string]bool `protobuf:"bytes,8,rep,name=flags,proto3" json:"flags,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"varint,2,opt,name=value,proto3"`
+	// Names of the policy sets requests can select, besides the default set.
+	PolicySets           []string `protobuf:"bytes,9,rep,name=policy_sets,json=policySets,proto3" json:"policy_sets,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetCapabilitiesResponse) Reset()         { *m = GetCapabilitiesResponse{} }
+func (m *GetCapabilitiesResponse) String() string { return proto.CompactTextString(m) }
+func (*GetCapabilitiesResponse) ProtoMessage()    {}
+func (*GetCapabilitiesResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_bf1c6ec7c0d80dd5, []int{12}
+}
+
+func (m *GetCapabilitiesResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetCapabilitiesResponse.Unmarshal(m, b)
+}
+func (m *GetCapabilitiesResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetCapabilitiesResponse.Marshal(b, m, deterministic)
+}
+func (m *GetCapabilitiesResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetCapabilitiesResponse.Merge(m, src)
+}
+func (m *GetCapabilitiesResponse) XXX_Size() int {
+	return xxx_messageInfo_GetCapabilitiesResponse.Size(m)
+}
+func (m *GetCapabilitiesResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetCapabilitiesResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetCapabilitiesResponse proto.InternalMessageInfo
+
+func (m *GetCapabilitiesResponse) GetValidatorVersion() string {
+	if m != nil {
+		return m.ValidatorVersion
+	}
+	return ""
+}
+
+func (m *GetCapabilitiesResponse) GetProtoVersion() string {
+	if m != nil {
+		return m.ProtoVersion
+	}
+	return ""
+}
+
+func (m *GetCapabilitiesResponse) GetMethods() []string {
+	if m != nil {
+		return m.Methods
+	}
+	return nil
+}
+
+func (m *GetCapabilitiesResponse) GetOpaVersion() string {
+	if m != nil {
+		return m.OpaVersion
+	}
+	return ""
+}
+
+func (m *GetCapabilitiesResponse) GetTargets() []string {
+	if m != nil {
+		return m.Targets
+	}
+	return nil
+}
+
+func (m *GetCapabilitiesResponse) GetInputFormats() []string {
+	if m != nil {
+		return m.InputFormats
+	}
+	return nil
+}
+
+func (m *GetCapabilitiesResponse) GetFeatures() []string {
+	if m != nil {
+		return m.Features
+	}
+	return nil
+}
+
+func (m *GetCapabilitiesResponse) GetFlags() map[string]bool {
+	if m != nil {
+		return m.Flags
+	}
+	return nil
+}
+
+func (m *GetCapabilitiesResponse) GetPolicySets() []string {
+	if m != nil {
+		return m.PolicySets
+	}
+	return nil
+}
+
+// PolicyFile is a YAML file of constraint templates and/or constraints.
+type PolicyFile struct {
+	// Path of the file, used to report where diagnostics were found.
+	Path                 string   `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	Content              string   `protobuf:"bytes,2,opt,name=content,proto3" json:"content,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *PolicyFile) Reset()         { *m = PolicyFile{} }
+func (m *PolicyFile) String() string { return proto.CompactTextString(m) }
+func (*PolicyFile) ProtoMessage()    {}
+func (*PolicyFile) Descriptor() ([]byte, []int) {
+	return fileDescriptor_bf1c6ec7c0d80dd5, []int{13}
+}
+
+func (m *PolicyFile) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_PolicyFile.Unmarshal(m, b)
+}
+func (m *PolicyFile) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_PolicyFile.Marshal(b, m, deterministic)
+}
+func (m *PolicyFile) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_PolicyFile.Merge(m, src)
+}
+func (m *PolicyFile) XXX_Size() int {
+	return xxx_messageInfo_PolicyFile.Size(m)
+}
+func (m *PolicyFile) XXX_DiscardUnknown() {
+	xxx_messageInfo_PolicyFile.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_PolicyFile proto.InternalMessageInfo
+
+func (m *PolicyFile) GetPath() string {
+	if m != nil {
+		return m.Path
+	}
+	return ""
+}
+
+func (m *PolicyFile) GetContent() string {
+	if m != nil {
+		return m.Content
+	}
+	return ""
+}
+
+type LintRequest struct {
+	Files                []*PolicyFile `protobuf:"bytes,1,rep,name=files,proto3" json:"files,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}      `json:"-"`
+	XXX_unrecognized     []byte        `json:"-"`
+	XXX_sizecache        int32         `json:"-"`
+}
+
+func (m *LintRequest) Reset()         { *m = LintRequest{} }
+func (m *LintRequest) String() string { return proto.CompactTextString(m) }
+func (*LintRequest) ProtoMessage()    {}
+func (*LintRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_bf1c6ec7c0d80dd5, []int{14}
+}
+
+func (m *LintRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_LintRequest.Unmarshal(m, b)
+}
+func (m *LintRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_LintRequest.Marshal(b, m, deterministic)
+}
+func (m *LintRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_LintRequest.Merge(m, src)
+}
+func (m *LintRequest) XXX_Size() int {
+	return xxx_messageInfo_LintRequest.Size(m)
+}
+func (m *LintRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_LintRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_LintRequest proto.InternalMessageInfo
+
+func (m *LintRequest) GetFiles() []*PolicyFile {
+	if m != nil {
+		return m.Files
+	}
+	return nil
+}
+
+// Diagnostic is a problem found while linting a policy file.
+type Diagnostic struct {
+	Path string `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	// 1-based line the problem was found at, 0 if unknown.
+	Line     int32               `protobuf:"varint,2,opt,name=line,proto3" json:"line,omitempty"`
+	Severity Diagnostic_Severity `protobuf:"varint,3,opt,name=severity,proto3,enum=validator.Diagnostic_Severity" json:"severity,omitempty"`
+	// Kind of problem, e.g. unknown-parameter.
+	Code                 string   `protobuf:"bytes,4,opt,name=code,proto3" json:"code,omitempty"`
+	Message              string   `protobuf:"bytes,5,opt,name=message,proto3" json:"message,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Diagnostic) Reset()         { *m = Diagnostic{} }
+func (m *Diagnostic) String() string { return proto.CompactTextString(m) }
+func (*Diagnostic) ProtoMessage()    {}
+func (*Diagnostic) Descriptor() ([]byte, []int) {
+	return fileDescriptor_bf1c6ec7c0d80dd5, []int{15}
+}
+
+func (m *Diagnostic) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Diagnostic.Unmarshal(m, b)
+}
+func (m *Diagnostic) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Diagnostic.Marshal(b, m, deterministic)
+}
+func (m *Diagnostic) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Diagnostic.Merge(m, src)
+}
+func (m *Diagnostic) XXX_Size() int {
+	return xxx_messageInfo_Diagnostic.Size(m)
+}
+func (m *Diagnostic) XXX_DiscardUnknown() {
+	xxx_messageInfo_Diagnostic.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Diagnostic proto.InternalMessageInfo
+
+func (m *Diagnostic) GetPath() string {
+	if m != nil {
+		return m.Path
+	}
+	return ""
+}
+
+func (m *Diagnostic) GetLine() int32 {
+	if m != nil {
+		return m.Line
+	}
+	return 0
+}
+
+func (m *Diagnostic) GetSeverity() Diagnostic_Severity {
+	if m != nil {
+		return m.Severity
+	}
+	return Diagnostic_ERROR
+}
+
+func (m *Diagnostic) GetCode() string {
+	if m != nil {
+		return m.Code
+	}
+	return ""
+}
+
+func (m *Diagnostic) GetMessage() string {
+	if m != nil {
+		return m.Message
+	}
+	return ""
+}
+
+type LintResponse struct {
+	Diagnostics          []*Diagnostic `protobuf:"bytes,1,rep,name=diagnostics,proto3" json:"diagnostics,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}      `json:"-"`
+	XXX_unrecognized     []byte        `json:"-"`
+	XXX_sizecache        int32         `json:"-"`
+}
+
+func (m *LintResponse) Reset()         { *m = LintResponse{} }
+func (m *LintResponse) String() string { return proto.CompactTextString(m) }
+func (*LintResponse) ProtoMessage()    {}
+func (*LintResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_bf1c6ec7c0d80dd5, []int{16}
+}
+
+func (m *LintResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_LintResponse.Unmarshal(m, b)
+}
+func (m *LintResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_LintResponse.Marshal(b, m, deterministic)
+}
+func (m *LintResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_LintResponse.Merge(m, src)
+}
+func (m *LintResponse) XXX_Size() int {
+	return xxx_messageInfo_LintResponse.Size(m)
+}
+func (m *LintResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_LintResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_LintResponse proto.InternalMessageInfo
+
+func (m *LintResponse) GetDiagnostics() []*Diagnostic {
+	if m != nil {
+		return m.Diagnostics
+	}
+	return nil
+}
+
+type ListConstraintsRequest struct {
+	// Name of the policy set to list the constraints of, the default set if empty.
+	PolicySet            string   `protobuf:"bytes,1,opt,name=policy_set,json=policySet,proto3" json:"policy_set,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ListConstraintsRequest) Reset()         { *m = ListConstraintsRequest{} }
+func (m *ListConstraintsRequest) String() string { return proto.CompactTextString(m) }
+func (*ListConstraintsRequest) ProtoMessage()    {}
+func (*ListConstraintsRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_bf1c6ec7c0d80dd5, []int{17}
+}
+
+func (m *ListConstraintsRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListConstraintsRequest.Unmarshal(m, b)
+}
+func (m *ListConstraintsRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListConstraintsRequest.Marshal(b, m, deterministic)
+}
+func (m *ListConstraintsRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListConstraintsRequest.Merge(m, src)
+}
+func (m *ListConstraintsRequest) XXX_Size() int {
+	return xxx_messageInfo_ListConstraintsRequest.Size(m)
+}
+func (m *ListConstraintsRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListConstraintsRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListConstraintsRequest proto.InternalMessageInfo
+
+func (m *ListConstraintsRequest) GetPolicySet() string {
+	if m != nil {
+		return m.PolicySet
+	}
+	return ""
+}
+
+// ConstraintInfo describes a constraint loaded by the server.
+type ConstraintInfo struct {
+	// Name of the constraint in "[Kind].[Name]" format, as in Violation.constraint.
+	Name     string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Kind     string `protobuf:"bytes,2,opt,name=kind,proto3" json:"kind,omitempty"`
+	Severity string `protobuf:"bytes,3,opt,name=severity,proto3" json:"severity,omitempty"`
+	// Path of the file the constraint was loaded from.
+	Path string `protobuf:"bytes,4,opt,name=path,proto3" json:"path,omitempty"`
+	// Effective parameters of the constraint, with the defaults declared by its
+	// template applied to the parameters it omits.
+	Parameters           *_struct.Value `protobuf:"bytes,5,opt,name=parameters,proto3" json:"parameters,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}       `json:"-"`
+	XXX_unrecognized     []byte         `json:"-"`
+	XXX_sizecache        int32          `json:"-"`
+}
+
+func (m *ConstraintInfo) Reset()         { *m = ConstraintInfo{} }
+func (m *ConstraintInfo) String() string { return proto.CompactTextString(m) }
+func (*ConstraintInfo) ProtoMessage()    {}
+func (*ConstraintInfo) Descriptor() ([]byte, []int) {
+	return fileDescriptor_bf1c6ec7c0d80dd5, []int{18}
+}
+
+func (m *ConstraintInfo) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ConstraintInfo.Unmarshal(m, b)
+}
+func (m *ConstraintInfo) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ConstraintInfo.Marshal(b, m, deterministic)
+}
+func (m *ConstraintInfo) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ConstraintInfo.Merge(m, src)
+}
+func (m *ConstraintInfo) XXX_Size() int {
+	return xxx_messageInfo_ConstraintInfo.Size(m)
+}
+func (m *ConstraintInfo) XXX_DiscardUnknown() {
+	xxx_messageInfo_ConstraintInfo.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ConstraintInfo proto.InternalMessageInfo
+
+func (m *ConstraintInfo) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *ConstraintInfo) GetKind() string {
+	if m != nil {
+		return m.Kind
+	}
+	return ""
+}
+
+func (m *ConstraintInfo) GetSeverity() string {
+	if m != nil {
+		return m.Severity
+	}
+	return ""
+}
+
+func (m *ConstraintInfo) GetPath() string {
+	if m != nil {
+		return m.Path
+	}
+	return ""
+}
+
+func (m *ConstraintInfo) GetParameters() *_struct.Value {
+	if m != nil {
+		return m.Parameters
+	}
+	return nil
+}
+
+type ListConstraintsResponse struct {
+	Constraints []*ConstraintInfo `protobuf:"bytes,1,rep,name=constraints,proto3" json:"constraints,omitempty"`
+	// Version of the loaded policy set, as in Violation.policy_version.
+	PolicyVersion        string   `protobuf:"bytes,2,opt,name=policy_version,json=policyVersion,proto3" json:"policy_version,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ListConstraintsResponse) Reset()         { *m = ListConstraintsResponse{} }
+func (m *ListConstraintsResponse) String() string { return proto.CompactTextString(m) }
+func (*ListConstraintsResponse) ProtoMessage()    {}
+func (*ListConstraintsResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_bf1c6ec7c0d80dd5, []int{19}
+}
+
+func (m *ListConstraintsResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListConstraintsResponse.Unmarshal(m, b)
+}
+func (m *ListConstraintsResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListConstraintsResponse.Marshal(b, m, deterministic)
+}
+func (m *ListConstraintsResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListConstraintsResponse.Merge(m, src)
+}
+func (m *ListConstraintsResponse) XXX_Size() int {
+	return xxx_messageInfo_ListConstraintsResponse.Size(m)
+}
+func (m *ListConstraintsResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListConstraintsResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListConstraintsResponse proto.InternalMessageInfo
+
+func (m *ListConstraintsResponse) GetConstraints() []*ConstraintInfo {
+	if m != nil {
+		return m.Constraints
+	}
+	return nil
+}
+
+func (m *ListConstraintsResponse) GetPolicyVersion() string {
+	if m != nil {
+		return m.PolicyVersion
+	}
+	return ""
+}
+
+type ReloadPoliciesRequest struct {
+	// Name of the policy set to reload, the default set if empty.
+	PolicySet            string   `protobuf:"bytes,1,opt,name=policy_set,json=policySet,proto3" json:"policy_set,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ReloadPoliciesRequest) Reset()         { *m = ReloadPoliciesRequest{} }
+func (m *ReloadPoliciesRequest) String() string { return proto.CompactTextString(m) }
+func (*ReloadPoliciesRequest) ProtoMessage()    {}
+func (*ReloadPoliciesRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_bf1c6ec7c0d80dd5, []int{20}
+}
+
+func (m *ReloadPoliciesRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ReloadPoliciesRequest.Unmarshal(m, b)
+}
+func (m *ReloadPoliciesRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ReloadPoliciesRequest.Marshal(b, m, deterministic)
+}
+func (m *ReloadPoliciesRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ReloadPoliciesRequest.Merge(m, src)
+}
+func (m *ReloadPoliciesRequest) XXX_Size() int {
+	return xxx_messageInfo_ReloadPoliciesRequest.Size(m)
+}
+func (m *ReloadPoliciesRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ReloadPoliciesRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ReloadPoliciesRequest proto.InternalMessageInfo
+
+func (m *ReloadPoliciesRequest) GetPolicySet() string {
+	if m != nil {
+		return m.PolicySet
+	}
+	return ""
+}
+
+type ReloadPoliciesResponse struct {
+	// Version of the reloaded policy set, as in Violation.policy_version.
+	PolicyVersion string `protobuf:"bytes,1,opt,name=policy_version,json=policyVersion,proto3" json:"policy_version,omitempty"`
+	// Number of loaded constraints.
+	Constraints          int32    `protobuf:"varint,2,opt,name=constraints,proto3" json:"constraints,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ReloadPoliciesResponse) Reset()         { *m = ReloadPoliciesResponse{} }
+func (m *ReloadPoliciesResponse) String() string { return proto.CompactTextString(m) }
+func (*ReloadPoliciesResponse) ProtoMessage()    {}
+func (*ReloadPoliciesResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_bf1c6ec7c0d80dd5, []int{21}
+}
+
+func (m *ReloadPoliciesResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ReloadPoliciesResponse.Unmarshal(m, b)
+}
+func (m *ReloadPoliciesResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ReloadPoliciesResponse.Marshal(b, m, deterministic)
+}
+func (m *ReloadPoliciesResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ReloadPoliciesResponse.Merge(m, src)
+}
+func (m *ReloadPoliciesResponse) XXX_Size() int {
+	return xxx_messageInfo_ReloadPoliciesResponse.Size(m)
+}
+func (m *ReloadPoliciesResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_ReloadPoliciesResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ReloadPoliciesResponse proto.InternalMessageInfo
+
+func (m *ReloadPoliciesResponse) GetPolicyVersion() string {
+	if m != nil {
+		return m.PolicyVersion
+	}
+	return ""
+}
+
+func (m *ReloadPoliciesResponse) GetConstraints() int32 {
+	if m != nil {
+		return m.Constraints
+	}
+	return 0
+}
+
 func init() {
+	proto.RegisterEnum("validator.Diagnostic_Severity", Diagnostic_Severity_name, Diagnostic_Severity_value)
 	proto.RegisterType((*Asset)(nil), "validator.Asset")
 	proto.RegisterType((*Constraint)(nil), "validator.Constraint")
 	proto.RegisterType((*Violation)(nil), "validator.Violation")
@@ -619,58 +1295,113 @@ func init() {
 	proto.RegisterType((*ResetResponse)(nil), "validator.ResetResponse")
 	proto.RegisterType((*ReviewRequest)(nil), "validator.ReviewRequest")
 	proto.RegisterType((*ReviewResponse)(nil), "validator.ReviewResponse")
+	proto.RegisterType((*GetCapabilitiesRequest)(nil), "validator.GetCapabilitiesRequest")
+	proto.RegisterType((*GetCapabilitiesResponse)(nil), "validator.GetCapabilitiesResponse")
+	proto.RegisterMapType((map[string]bool)(nil), "validator.GetCapabilitiesResponse.FlagsEntry")
+	proto.RegisterType((*PolicyFile)(nil), "validator.PolicyFile")
+	proto.RegisterType((*LintRequest)(nil), "validator.LintRequest")
+	proto.RegisterType((*Diagnostic)(nil), "validator.Diagnostic")
+	proto.RegisterType((*LintResponse)(nil), "validator.LintResponse")
+	proto.RegisterType((*ListConstraintsRequest)(nil), "validator.ListConstraintsRequest")
+	proto.RegisterType((*ConstraintInfo)(nil), "validator.ConstraintInfo")
+	proto.RegisterType((*ListConstraintsResponse)(nil), "validator.ListConstraintsResponse")
+	proto.RegisterType((*ReloadPoliciesRequest)(nil), "validator.ReloadPoliciesRequest")
+	proto.RegisterType((*ReloadPoliciesResponse)(nil), "validator.ReloadPoliciesResponse")
 }
 
 func init() { proto.RegisterFile("validator.proto", fileDescriptor_bf1c6ec7c0d80dd5) }
 
 var fileDescriptor_bf1c6ec7c0d80dd5 = []byte{
-	// 734 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xa4, 0x54, 0xcf, 0x4f, 0xdb, 0x4a,
-	0x10, 0x4e, 0x20, 0x04, 0x3c, 0x24, 0xfc, 0x58, 0x3d, 0x1e, 0xc6, 0xe2, 0xbd, 0xa6, 0xee, 0x25,
-	0xbd, 0x38, 0x22, 0xa5, 0x07, 0xa0, 0x12, 0x04, 0xda, 0x8a, 0x43, 0x0f, 0xc8, 0xad, 0x90, 0x2a,
-	0x55, 0x8a, 0x16, 0x67, 0x30, 0x2b, 0xd9, 0xde, 0xd4, 0xbb, 0x71, 0x9b, 0xff, 0xa1, 0x7f, 0x6b,
-	0x6f, 0xbd, 0x57, 0x5e, 0xef, 0x3a, 0x76, 0xe1, 0x00, 0xea, 0xcd, 0x33, 0xf3, 0xcd, 0x37, 0xdf,
-	0x7e, 0x3b, 0x6b, 0xd8, 0xcc, 0x68, 0xc4, 0x26, 0x54, 0xf2, 0xd4, 0x9b, 0xa6, 0x5c, 0x72, 0x62,
-	0x95, 0x09, 0xc7, 0x09, 0x39, 0x0f, 0x23, 0x1c, 0x30, 0x1a, 0x0f, 0xb2, 0x83, 0xc1, 0x94, 0x47,
-	0x2c, 0x98, 0x17, 0x30, 0x67, 0x5f, 0xd7, 0x54, 0x74, 0x33, 0xbb, 0x1d, 0x08, 0x99, 0xce, 0x02,
-	0xa9, 0xab, 0xae, 0xae, 0x06, 0x11, 0x9f, 0x4d, 0x06, 0x54, 0x08, 0x94, 0x39, 0x83, 0xfa, 0x10,
-	0x1a, 0xf3, 0xb2, 0x86, 0xe1, 0x69, 0x58, 0xf0, 0xe7, 0xb8, 0x32, 0xd0, 0xd0, 0x63, 0x23, 0x64,
-	0x82, 0x89, 0x64, 0x72, 0x3e, 0xa0, 0x41, 0x80, 0x42, 0x04, 0x3c, 0x91, 0xf8, 0x5d, 0xc6, 0x34,
-	0xa1, 0x21, 0xa6, 0x6a, 0x80, 0xca, 0x8f, 0x23, 0xcc, 0x30, 0xd2, 0xbd, 0x27, 0x4f, 0xec, 0xad,
-	0x0d, 0x3e, 0x7d, 0x6c, 0xb3, 0xc0, 0x34, 0x63, 0x01, 0x8e, 0xa7, 0x98, 0xb2, 0x18, 0x25, 0x6a,
-	0x37, 0xdd, 0x9f, 0x2d, 0x58, 0x19, 0xe5, 0xa7, 0x26, 0x04, 0x5a, 0x09, 0x8d, 0xd1, 0x6e, 0xf6,
-	0x9a, 0x7d, 0xcb, 0x57, 0xdf, 0xe4, 0x3f, 0x00, 0x65, 0xc9, 0x58, 0xce, 0xa7, 0x68, 0x2f, 0xa9,
-	0x8a, 0xa5, 0x32, 0x9f, 0xe6, 0x53, 0x24, 0x2f, 0xa0, 0x4b, 0x93, 0x00, 0x85, 0x4c, 0xe7, 0xe3,
-	0x29, 0x95, 0x77, 0xf6, 0xb2, 0x42, 0x74, 0x4c, 0xf2, 0x8a, 0xca, 0x3b, 0x72, 0x02, 0x6b, 0x29,
-	0x0a, 0x3e, 0x4b, 0x03, 0xb4, 0x5b, 0xbd, 0x66, 0x7f, 0x7d, 0xf8, 0xcc, 0x2b, 0x54, 0x7b, 0xca,
-	0x59, 0x4f, 0xf1, 0x79, 0xd9, 0x81, 0xe7, 0x6b, 0x98, 0x5f, 0x36, 0x90, 0x43, 0x00, 0x46, 0x63,
-	0x7d, 0x66, 0x7b, 0x45, 0xb5, 0xef, 0x98, 0x76, 0x46, 0xe3, 0xbc, 0xed, 0x4a, 0x15, 0x7d, 0x8b,
-	0xd1, 0xb8, 0xf8, 0x24, 0xfb, 0x60, 0x15, 0x12, 0x78, 0x2a, 0xec, 0x76, 0x6f, 0x59, 0xa9, 0x36,
-	0x09, 0x72, 0x06, 0xc0, 0xd3, 0xd0, 0x70, 0xae, 0xf6, 0x96, 0xfb, 0xeb, 0xc3, 0xe7, 0x75, 0x49,
-	0x8b, 0xfb, 0xad, 0xf0, 0xf3, 0x34, 0xd4, 0xfc, 0x5f, 0xa0, 0x5b, 0xbb, 0x0c, 0x7b, 0x4d, 0x09,
-	0x7b, 0x5d, 0x0a, 0xd3, 0xb7, 0xe1, 0x3d, 0x74, 0x1b, 0x39, 0xe5, 0x48, 0xe5, 0x0b, 0xb6, 0xcb,
-	0x86, 0xdf, 0xa1, 0x95, 0x98, 0x7c, 0x86, 0x4e, 0x75, 0x4d, 0x6c, 0x4b, 0x91, 0x1f, 0x3e, 0x91,
-	0xfc, 0x43, 0xde, 0x7b, 0xd9, 0xf0, 0xd7, 0xe9, 0x22, 0x24, 0x77, 0xb0, 0x7d, 0x6f, 0x11, 0x6c,
-	0x50, 0xfc, 0x47, 0x8f, 0xe6, 0xff, 0x58, 0x30, 0x5c, 0x19, 0x82, 0xcb, 0x86, 0xbf, 0x25, 0xfe,
-	0xc8, 0x9d, 0xef, 0xc2, 0x8e, 0x3e, 0x84, 0x26, 0xd0, 0x56, 0xb9, 0x67, 0x00, 0x17, 0x3c, 0x11,
-	0x32, 0xa5, 0x2c, 0x91, 0x64, 0x08, 0x6b, 0x31, 0x4a, 0x3a, 0xa1, 0x92, 0xea, 0xdb, 0xfd, 0xd7,
-	0xe8, 0x30, 0x0f, 0xd7, 0xbb, 0xa6, 0xd1, 0x0c, 0xfd, 0x12, 0xe7, 0xfe, 0x6a, 0x82, 0x75, 0xcd,
-	0x78, 0x44, 0x25, 0xe3, 0x09, 0xf9, 0x1f, 0x20, 0x28, 0xf9, 0xf4, 0xf2, 0x56, 0x32, 0xc4, 0xa9,
-	0xac, 0x5f, 0xb1, 0xc0, 0x8b, 0xed, 0xb2, 0x61, 0x35, 0x46, 0x21, 0x68, 0x88, 0x7a, 0x73, 0x4d,
-	0x58, 0xd3, 0xd5, 0x7a, 0x9c, 0x2e, 0x72, 0x0e, 0xdb, 0x8b, 0xb9, 0xf9, 0xb1, 0x6f, 0x59, 0x58,
-	0xae, 0xec, 0xe2, 0x2f, 0xb6, 0x38, 0xbd, 0xbf, 0xb5, 0xc0, 0x5f, 0x28, 0x78, 0xae, 0x56, 0x60,
-	0x86, 0x29, 0x93, 0x73, 0xbb, 0x5d, 0xa8, 0x35, 0xb1, 0x7b, 0x0c, 0x1b, 0xa3, 0xc9, 0xe4, 0x2d,
-	0x95, 0xd4, 0xc7, 0xaf, 0x33, 0x14, 0x92, 0xf4, 0xa1, 0x5d, 0xfc, 0xb1, 0xec, 0xa6, 0xda, 0xe2,
-	0xad, 0xca, 0x18, 0xf5, 0xa8, 0x7d, 0x5d, 0x77, 0xb7, 0x61, 0xb3, 0xec, 0x15, 0x53, 0x9e, 0x08,
-	0x74, 0x37, 0xa0, 0x33, 0x9a, 0x4d, 0x98, 0xd4, 0x64, 0xee, 0x3b, 0xe8, 0xea, 0xb8, 0x00, 0xe4,
-	0x6f, 0x2f, 0x33, 0x36, 0x9b, 0x09, 0xff, 0x54, 0x26, 0x94, 0x77, 0xe0, 0x57, 0x70, 0x39, 0xad,
-	0x8f, 0xf9, 0x68, 0x4d, 0xbb, 0x09, 0x5d, 0x1d, 0xeb, 0xb9, 0x47, 0x79, 0x22, 0x63, 0xf8, 0xed,
-	0xe9, 0xa7, 0x78, 0x0f, 0x1b, 0xa6, 0xf5, 0x6f, 0x34, 0x0e, 0x7f, 0x2c, 0x81, 0x75, 0x6d, 0x30,
-	0xe4, 0x1c, 0x56, 0xb5, 0x37, 0x64, 0xaf, 0x3a, 0xba, 0xe6, 0xb5, 0xe3, 0x3c, 0x54, 0xd2, 0x47,
-	0x6a, 0x90, 0x37, 0xb0, 0xa2, 0xcc, 0x23, 0xbb, 0x55, 0x58, 0xc5, 0x5e, 0xc7, 0xbe, 0x5f, 0xa8,
-	0x76, 0x2b, 0x8f, 0x6a, 0xdd, 0x55, 0x17, 0x6b, 0xdd, 0x75, 0x3b, 0x1b, 0xe4, 0x14, 0xda, 0x85,
-	0x2b, 0xa4, 0x8e, 0xaa, 0x78, 0xec, 0xec, 0x3d, 0x50, 0x31, 0x04, 0x37, 0x6d, 0xb5, 0xd2, 0xaf,
-	0x7e, 0x07, 0x00, 0x00, 0xff, 0xff, 0xde, 0x36, 0x8a, 0xd5, 0x6b, 0x07, 0x00, 0x00,
+	// 1418 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xa4, 0x57, 0xfb, 0x6e, 0x13, 0x47,
+	0x17, 0x8f, 0x13, 0x3b, 0xc9, 0x1e, 0x3b, 0xb6, 0x33, 0x22, 0xc9, 0xb2, 0xe2, 0x83, 0xb0, 0xe8,
+	0xd3, 0x97, 0xaf, 0xa8, 0x4e, 0x49, 0x29, 0x97, 0x50, 0xa9, 0x84, 0x40, 0x02, 0x12, 0xa2, 0x68,
+	0x42, 0x83, 0x2a, 0x55, 0xb2, 0x26, 0xeb, 0x89, 0x33, 0x62, 0xbd, 0x6b, 0x76, 0xc6, 0xa6, 0x46,
+	0xea, 0x3f, 0x7d, 0x85, 0xbe, 0x40, 0xfb, 0x2a, 0x7d, 0x85, 0xbe, 0x42, 0xab, 0xfe, 0xd7, 0x67,
+	0xa8, 0xe6, 0xb6, 0x3b, 0xbe, 0x00, 0x41, 0xfc, 0xb7, 0xe7, 0xf6, 0x3b, 0xd7, 0x39, 0xc7, 0x86,
+	0xc6, 0x90, 0xc4, 0xac, 0x43, 0x44, 0x9a, 0xb5, 0xfa, 0x59, 0x2a, 0x52, 0xe4, 0xe5, 0x8c, 0xe0,
+	0x52, 0x37, 0x4d, 0xbb, 0x31, 0xdd, 0x26, 0x7d, 0xb6, 0x4d, 0x92, 0x24, 0x15, 0x44, 0xb0, 0x34,
+	0xe1, 0x5a, 0x31, 0x08, 0x8c, 0x94, 0x91, 0xde, 0xf6, 0xf0, 0xc6, 0x76, 0x3f, 0x8d, 0x59, 0x34,
+	0x32, 0x32, 0x6b, 0xa9, 0xa8, 0x93, 0xc1, 0xe9, 0x36, 0x17, 0xd9, 0x20, 0x12, 0x46, 0x1a, 0x1a,
+	0x69, 0x14, 0xa7, 0x83, 0xce, 0x36, 0xe1, 0x9c, 0x0a, 0x89, 0xa0, 0x3e, 0x2c, 0xfa, 0xff, 0xc7,
+	0x74, 0xd2, 0xac, 0xab, 0xf1, 0xa5, 0x5e, 0x4e, 0x18, 0xd5, 0x5d, 0x1b, 0x48, 0x87, 0x26, 0x82,
+	0x89, 0xd1, 0x36, 0x89, 0x22, 0xca, 0x79, 0x94, 0x26, 0x82, 0xfe, 0x28, 0x7a, 0x24, 0x21, 0x5d,
+	0x9a, 0x29, 0x07, 0x8a, 0xdf, 0x8e, 0xe9, 0x90, 0xc6, 0xc6, 0xf6, 0xde, 0x47, 0xda, 0x8e, 0x39,
+	0xfe, 0xe6, 0xbc, 0xc6, 0x9c, 0x66, 0x43, 0x16, 0xd1, 0x76, 0x9f, 0x66, 0xac, 0x47, 0x05, 0x35,
+	0xb5, 0x0e, 0xff, 0x29, 0x43, 0x65, 0x4f, 0x66, 0x8d, 0x10, 0x94, 0x13, 0xd2, 0xa3, 0x7e, 0x69,
+	0xb3, 0xb4, 0xe5, 0x61, 0xf5, 0x8d, 0xfe, 0x03, 0xa0, 0x4a, 0xd2, 0x16, 0xa3, 0x3e, 0xf5, 0xe7,
+	0x95, 0xc4, 0x53, 0x9c, 0x17, 0xa3, 0x3e, 0x45, 0xd7, 0x60, 0x85, 0x24, 0x11, 0xe5, 0x22, 0x1b,
+	0xb5, 0xfb, 0x44, 0x9c, 0xf9, 0x0b, 0x4a, 0xa3, 0x66, 0x99, 0xcf, 0x89, 0x38, 0x43, 0xf7, 0x60,
+	0x39, 0xa3, 0x3c, 0x1d, 0x64, 0x11, 0xf5, 0xcb, 0x9b, 0xa5, 0xad, 0xea, 0xce, 0x95, 0x96, 0x8e,
+	0xba, 0xa5, 0x2a, 0xdb, 0x52, 0x78, 0xad, 0xe1, 0x8d, 0x16, 0x36, 0x6a, 0x38, 0x37, 0x40, 0x37,
+	0x01, 0x18, 0xe9, 0x99, 0x9c, 0xfd, 0x8a, 0x32, 0x5f, 0xb3, 0xe6, 0x8c, 0xf4, 0xa4, 0xd9, 0x73,
+	0x25, 0xc4, 0x1e, 0x23, 0x3d, 0xfd, 0x89, 0x2e, 0x81, 0xa7, 0x43, 0x48, 0x33, 0xee, 0x2f, 0x6e,
+	0x2e, 0xa8, 0xa8, 0x2d, 0x03, 0xdd, 0x07, 0x48, 0xb3, 0xae, 0xc5, 0x5c, 0xda, 0x5c, 0xd8, 0xaa,
+	0xee, 0x5c, 0x1d, 0x0f, 0xa9, 0xe8, 0xaf, 0x83, 0x9f, 0x66, 0x5d, 0x83, 0xff, 0x03, 0xac, 0x8c,
+	0x35, 0xc3, 0x5f, 0x56, 0x81, 0x7d, 0x95, 0x07, 0x66, 0xba, 0xd1, 0x9a, 0xd5, 0x0d, 0x09, 0xb9,
+	0xa7, 0xf8, 0x1a, 0xed, 0xf1, 0x1c, 0xae, 0x11, 0x87, 0x46, 0xdf, 0x43, 0xcd, 0x1d, 0x13, 0xdf,
+	0x53, 0xe0, 0x37, 0x3f, 0x12, 0xfc, 0xa9, 0xb4, 0x7d, 0x3c, 0x87, 0xab, 0xa4, 0x20, 0xd1, 0x19,
+	0xac, 0x4e, 0x0d, 0x82, 0x0f, 0x0a, 0xff, 0xee, 0xb9, 0xf1, 0x8f, 0x34, 0xc2, 0x73, 0x0b, 0xf0,
+	0x78, 0x0e, 0x37, 0xf9, 0x04, 0xef, 0xc1, 0x06, 0xac, 0x99, 0x24, 0x0c, 0x80, 0x29, 0x55, 0x78,
+	0x1f, 0x60, 0x3f, 0x4d, 0xb8, 0xc8, 0x08, 0x4b, 0x04, 0xda, 0x81, 0xe5, 0x1e, 0x15, 0xa4, 0x43,
+	0x04, 0x31, 0xdd, 0x5d, 0xb7, 0x71, 0xd8, 0x87, 0xdb, 0x3a, 0x26, 0xf1, 0x80, 0xe2, 0x5c, 0x2f,
+	0xfc, 0x7b, 0x1e, 0xbc, 0x63, 0x96, 0xc6, 0x6a, 0x15, 0xa0, 0xcb, 0x00, 0x51, 0x8e, 0x67, 0x86,
+	0xd7, 0xe1, 0xa0, 0xc0, 0x19, 0x3f, 0x3d, 0xc0, 0x39, 0x8d, 0x7c, 0x58, 0xea, 0x51, 0xce, 0x49,
+	0x97, 0x9a, 0xc9, 0xb5, 0xe4, 0x58, 0x5c, 0xe5, 0xf3, 0xc5, 0x85, 0x1e, 0xc0, 0x6a, 0xe1, 0x57,
+	0xa6, 0x7d, 0xca, 0xba, 0xf9, 0xc8, 0x16, 0x3b, 0xae, 0xc8, 0x1e, 0x37, 0x0b, 0xfd, 0x7d, 0xa5,
+	0x2e, 0xa3, 0xe5, 0x74, 0x48, 0x33, 0x26, 0x46, 0xfe, 0xa2, 0x8e, 0xd6, 0xd2, 0xe8, 0xbf, 0x50,
+	0xd7, 0x35, 0x6c, 0x0f, 0x69, 0xc6, 0x59, 0x9a, 0xf8, 0x4b, 0x4a, 0x63, 0x45, 0x73, 0x8f, 0x35,
+	0x13, 0x6d, 0x42, 0x35, 0xa3, 0x3d, 0xda, 0x61, 0xaa, 0x3e, 0x6a, 0x34, 0x3d, 0xec, 0xb2, 0xd0,
+	0xff, 0xa0, 0xe1, 0x90, 0xed, 0x41, 0xa6, 0x67, 0xcc, 0xc3, 0x75, 0x87, 0xfd, 0x5d, 0x16, 0x87,
+	0xbb, 0x50, 0xdf, 0xeb, 0x74, 0x1e, 0x12, 0x41, 0x30, 0x7d, 0x3d, 0xa0, 0x5c, 0xa0, 0x2d, 0x58,
+	0xd4, 0x3b, 0xd2, 0x2f, 0xa9, 0x77, 0xd3, 0x74, 0x12, 0x53, 0x6b, 0x04, 0x1b, 0x79, 0xb8, 0x0a,
+	0x8d, 0xdc, 0x96, 0xf7, 0xd3, 0x84, 0xd3, 0xb0, 0x0e, 0xb5, 0xbd, 0x41, 0x87, 0x09, 0x03, 0x16,
+	0x3e, 0x82, 0x15, 0x43, 0x6b, 0x05, 0xf9, 0xda, 0x87, 0xb6, 0xb1, 0xd6, 0xc3, 0x05, 0xc7, 0x43,
+	0xde, 0x75, 0xec, 0xe8, 0x49, 0x58, 0x4c, 0x39, 0xcd, 0x61, 0x1b, 0xb0, 0x62, 0x68, 0xe3, 0xf7,
+	0x8d, 0x64, 0x0c, 0x19, 0x7d, 0xf3, 0xd1, 0x59, 0xc8, 0x05, 0x68, 0x6a, 0xce, 0xa9, 0xb0, 0x0b,
+	0x50, 0x73, 0x8e, 0xa8, 0x90, 0xe2, 0x13, 0x22, 0xa2, 0xb3, 0x36, 0x67, 0x6f, 0xf5, 0x0c, 0x55,
+	0xb0, 0xa7, 0x38, 0x47, 0xec, 0x2d, 0x0d, 0x0f, 0xa0, 0x6e, 0x1d, 0x7f, 0x52, 0x86, 0x3e, 0xac,
+	0x1f, 0x52, 0xb1, 0x4f, 0xfa, 0xe4, 0x84, 0xc5, 0x4c, 0x30, 0xca, 0x6d, 0xae, 0xbf, 0x2d, 0xc0,
+	0xc6, 0x94, 0xc8, 0xf8, 0xba, 0x0e, 0xab, 0x39, 0x70, 0x3e, 0x32, 0xfa, 0x81, 0x34, 0x73, 0x81,
+	0x9d, 0x9a, 0x6b, 0xb0, 0xa2, 0x06, 0x3b, 0x57, 0xd4, 0xb9, 0xd6, 0x14, 0xd3, 0x2a, 0xa9, 0xf7,
+	0x22, 0xce, 0xd2, 0x0e, 0xf7, 0x17, 0xd4, 0x56, 0xb5, 0x24, 0xba, 0x02, 0xd5, 0xb4, 0x4f, 0x72,
+	0xe3, 0xb2, 0x7e, 0x86, 0x69, 0x9f, 0x38, 0xa6, 0x82, 0x64, 0x5d, 0x59, 0xf3, 0x8a, 0x36, 0x35,
+	0xa4, 0xf4, 0xcc, 0x92, 0xfe, 0x40, 0xb4, 0x4f, 0xd3, 0xac, 0x47, 0x84, 0x5d, 0xd8, 0x35, 0xc5,
+	0x3c, 0xd0, 0x3c, 0xf9, 0x2e, 0x4e, 0x29, 0x11, 0x83, 0x8c, 0x72, 0xb5, 0xb1, 0x3d, 0x9c, 0xd3,
+	0x68, 0x1f, 0x2a, 0xa7, 0x31, 0xe9, 0x72, 0x7f, 0x59, 0x95, 0xf3, 0x73, 0xa7, 0x9c, 0xef, 0x28,
+	0x4d, 0xeb, 0x40, 0xea, 0x3f, 0x4a, 0x44, 0x36, 0xc2, 0xda, 0x56, 0x26, 0x50, 0x34, 0x9a, 0xfb,
+	0x9e, 0xf2, 0x01, 0x79, 0xa7, 0x79, 0x70, 0x07, 0xa0, 0xb0, 0x42, 0x4d, 0x58, 0x78, 0x45, 0x47,
+	0xa6, 0x9a, 0xf2, 0x13, 0x5d, 0x80, 0xca, 0x50, 0x2e, 0x04, 0x55, 0xb8, 0x65, 0xac, 0x89, 0xdd,
+	0xf9, 0x3b, 0xa5, 0x70, 0x17, 0x40, 0x6f, 0xf6, 0x03, 0x16, 0x53, 0x79, 0x66, 0xd5, 0xa9, 0x34,
+	0x67, 0x56, 0x7e, 0xcb, 0xe2, 0xa8, 0x2d, 0x99, 0xd8, 0x11, 0xb3, 0x64, 0xb8, 0x0b, 0xd5, 0xa7,
+	0x72, 0x53, 0x98, 0xc1, 0xbd, 0x0e, 0x95, 0x53, 0x16, 0x53, 0x3b, 0x39, 0xee, 0x5a, 0x29, 0x5c,
+	0x60, 0xad, 0x13, 0xfe, 0x5e, 0x02, 0x78, 0xc8, 0x48, 0x37, 0x49, 0xb9, 0x60, 0xd1, 0x4c, 0xc7,
+	0x08, 0xca, 0x31, 0x4b, 0x74, 0xcc, 0x15, 0xac, 0xbe, 0xd1, 0xae, 0xb3, 0x82, 0xe4, 0x44, 0xd7,
+	0x77, 0x2e, 0x3b, 0x6e, 0x0a, 0xc0, 0xd6, 0x91, 0xd1, 0x72, 0x56, 0x14, 0x82, 0x72, 0x94, 0x76,
+	0xa8, 0xe9, 0xbf, 0xfa, 0x76, 0x97, 0x6c, 0x65, 0x6c, 0xc9, 0x86, 0x21, 0x2c, 0x5b, 0x0c, 0xe4,
+	0x41, 0xe5, 0x11, 0xc6, 0xdf, 0xe2, 0xe6, 0x1c, 0xaa, 0xc2, 0xd2, 0xcb, 0x3d, 0xfc, 0xec, 0xc9,
+	0xb3, 0xc3, 0x66, 0x29, 0x3c, 0x84, 0x9a, 0x2e, 0x80, 0x19, 0xea, 0xdb, 0x50, 0xed, 0xe4, 0x21,
+	0xcc, 0xaa, 0x43, 0x11, 0x20, 0x76, 0x35, 0xc3, 0xdb, 0xb0, 0xfe, 0x94, 0x71, 0x51, 0x6c, 0x5f,
+	0xfb, 0x86, 0x26, 0xde, 0x78, 0x69, 0xe2, 0x8d, 0x87, 0xbf, 0x96, 0xa0, 0x5e, 0x58, 0x3d, 0x49,
+	0x4e, 0xd3, 0x99, 0x3f, 0x95, 0x10, 0x94, 0x5f, 0xb1, 0xa4, 0x63, 0x1a, 0xa8, 0xbe, 0x51, 0x30,
+	0x51, 0x4a, 0x6f, 0xbc, 0x54, 0xaa, 0x1d, 0x65, 0xa7, 0x1d, 0xb7, 0x00, 0xfa, 0x24, 0x23, 0xea,
+	0x82, 0xf2, 0x0f, 0xdc, 0x43, 0x47, 0x33, 0xfc, 0x09, 0x36, 0xa6, 0x72, 0x33, 0xf5, 0xba, 0x07,
+	0xd5, 0xe2, 0xc8, 0xd8, 0x7a, 0x5d, 0x9c, 0x79, 0x8e, 0x64, 0x6a, 0xd8, 0xd5, 0x9e, 0x71, 0x71,
+	0xe6, 0x67, 0x5c, 0x9c, 0xf0, 0x16, 0xac, 0x61, 0x1a, 0xa7, 0xa4, 0xa3, 0x66, 0x90, 0xd1, 0xf3,
+	0x56, 0x96, 0xc0, 0xfa, 0xa4, 0x9d, 0x89, 0x7a, 0xda, 0x71, 0xe9, 0x1d, 0xa7, 0xce, 0x4d, 0x4e,
+	0x4f, 0xb1, 0xcb, 0xda, 0xf9, 0xab, 0x02, 0xde, 0xb1, 0xcd, 0x15, 0x3d, 0x80, 0x25, 0x73, 0x93,
+	0x90, 0x5b, 0x82, 0xf1, 0x1b, 0x17, 0x04, 0xb3, 0x44, 0xe6, 0x94, 0xcc, 0xa1, 0xaf, 0xa1, 0xa2,
+	0x8e, 0x16, 0xda, 0x70, 0xd5, 0x9c, 0xb3, 0x16, 0xf8, 0xd3, 0x02, 0xd7, 0x5a, 0xdd, 0xa6, 0x31,
+	0x6b, 0xf7, 0x7a, 0x05, 0xfe, 0xb4, 0x20, 0xb7, 0x7e, 0x01, 0x8b, 0xfa, 0x9e, 0xa0, 0x71, 0x2d,
+	0xe7, 0xb6, 0x05, 0x17, 0x67, 0x48, 0x0c, 0xc0, 0xda, 0xcf, 0x7f, 0xfc, 0xf9, 0xcb, 0x7c, 0x63,
+	0xb7, 0xf4, 0x59, 0x08, 0xf2, 0x3f, 0x41, 0xa6, 0xb1, 0x32, 0x68, 0x4c, 0xec, 0x49, 0x74, 0xf5,
+	0x7d, 0x3b, 0x54, 0xfb, 0x09, 0x3f, 0xbc, 0x66, 0x43, 0x5f, 0x39, 0x44, 0xa8, 0x29, 0xbd, 0x45,
+	0xae, 0x83, 0x43, 0xa8, 0x1d, 0x89, 0x8c, 0x92, 0xde, 0xa7, 0xe4, 0x33, 0xf7, 0x45, 0x09, 0xdd,
+	0x85, 0xb2, 0xdc, 0x0f, 0x68, 0xdd, 0x51, 0x73, 0x36, 0x66, 0xb0, 0x31, 0xc5, 0xcf, 0xab, 0xf9,
+	0x1a, 0x1a, 0x13, 0xaf, 0x66, 0x2c, 0xef, 0xd9, 0xdb, 0x22, 0x08, 0xdf, 0xa7, 0x62, 0xb0, 0x37,
+	0x54, 0xde, 0xab, 0xa8, 0xa1, 0xf2, 0x76, 0xf0, 0x5f, 0x42, 0x7d, 0x7c, 0xe2, 0xd1, 0xe6, 0x58,
+	0x7a, 0x33, 0x1e, 0x51, 0x70, 0xf5, 0x3d, 0x1a, 0x36, 0x97, 0x93, 0x45, 0xb5, 0x1d, 0xbe, 0xfc,
+	0x77, 0x00, 0x17, 0xee, 0x17, 0xf1, 0x4c, 0x0f, 0x00, 0x00,
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -694,6 +1425,23 @@ type ValidatorClient interface {
 	// Review checks the GCP resources and returns any constraint violations.  Note that referential checks are not supported
 	// with this mode.
 	Review(ctx context.Context, in *ReviewRequest, opts ...grpc.CallOption) (*ReviewResponse, error)
+	// GetCapabilities returns the versions, targets, input formats and features of the server.
+	GetCapabilities(ctx context.Context, in *GetCapabilitiesRequest, opts ...grpc.CallOption) (*GetCapabilitiesResponse, error)
+	// StreamReview is Review with the violations sent in batches of at most batch_size as the
+	// assets are reviewed, for reviews whose violations exceed the maximum message size. Violations
+	// are sent in the order of the assets in the request, and the violations of each asset are
+	// sorted by constraint. Errors reviewing assets end the stream after all other violations were
+	// sent. It is only available through gRPC.
+	StreamReview(ctx context.Context, in *ReviewRequest, opts ...grpc.CallOption) (Validator_StreamReviewClient, error)
+	// Lint checks constraint templates and constraints against the policy library of the server
+	// without loading them, and returns the problems found.
+	Lint(ctx context.Context, in *LintRequest, opts ...grpc.CallOption) (*LintResponse, error)
+	// ListConstraints returns the constraints assets are reviewed against.
+	ListConstraints(ctx context.Context, in *ListConstraintsRequest, opts ...grpc.CallOption) (*ListConstraintsResponse, error)
+	// ReloadPolicies loads the policies again from the sources the server was started with and
+	// swaps them in for the current ones. If the policies fail to load, the error is returned and
+	// the current policies are kept.
+	ReloadPolicies(ctx context.Context, in *ReloadPoliciesRequest, opts ...grpc.CallOption) (*ReloadPoliciesResponse, error)
 }
 
 type validatorClient struct {
@@ -740,6 +1488,74 @@ func (c *validatorClient) Review(ctx context.Context, in *ReviewRequest, opts ..
 	return out, nil
 }
 
+func (c *validatorClient) GetCapabilities(ctx context.Context, in *GetCapabilitiesRequest, opts ...grpc.CallOption) (*GetCapabilitiesResponse, error) {
+	out := new(GetCapabilitiesResponse)
+	err := c.cc.Invoke(ctx, "/validator.Validator/GetCapabilities", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *validatorClient) StreamReview(ctx context.Context, in *ReviewRequest, opts ...grpc.CallOption) (Validator_StreamReviewClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Validator_serviceDesc.Streams[0], "/validator.Validator/StreamReview", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &validatorStreamReviewClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Validator_StreamReviewClient interface {
+	Recv() (*ReviewResponse, error)
+	grpc.ClientStream
+}
+
+type validatorStreamReviewClient struct {
+	grpc.ClientStream
+}
+
+func (x *validatorStreamReviewClient) Recv() (*ReviewResponse, error) {
+	m := new(ReviewResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *validatorClient) Lint(ctx context.Context, in *LintRequest, opts ...grpc.CallOption) (*LintResponse, error) {
+	out := new(LintResponse)
+	err := c.cc.Invoke(ctx, "/validator.Validator/Lint", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *validatorClient) ListConstraints(ctx context.Context, in *ListConstraintsRequest, opts ...grpc.CallOption) (*ListConstraintsResponse, error) {
+	out := new(ListConstraintsResponse)
+	err := c.cc.Invoke(ctx, "/validator.Validator/ListConstraints", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *validatorClient) ReloadPolicies(ctx context.Context, in *ReloadPoliciesRequest, opts ...grpc.CallOption) (*ReloadPoliciesResponse, error) {
+	out := new(ReloadPoliciesResponse)
+	err := c.cc.Invoke(ctx, "/validator.Validator/ReloadPolicies", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // ValidatorServer is the server API for Validator service.
 type ValidatorServer interface {
 	// AddData adds GCP resource metadata to be audited later.
@@ -751,6 +1567,23 @@ type ValidatorServer interface {
 	// Review checks the GCP resources and returns any constraint violations.  Note that referential checks are not supported
 	// with this mode.
 	Review(context.Context, *ReviewRequest) (*ReviewResponse, error)
+	// GetCapabilities returns the versions, targets, input formats and features of the server.
+	GetCapabilities(context.Context, *GetCapabilitiesRequest) (*GetCapabilitiesResponse, error)
+	// StreamReview is Review with the violations sent in batches of at most batch_size as the
+	// assets are reviewed, for reviews whose violations exceed the maximum message size. Violations
+	// are sent in the order of the assets in the request, and the violations of each asset are
+	// sorted by constraint. Errors reviewing assets end the stream after all other violations were
+	// sent. It is only available through gRPC.
+	StreamReview(*ReviewRequest, Validator_StreamReviewServer) error
+	// Lint checks constraint templates and constraints against the policy library of the server
+	// without loading them, and returns the problems found.
+	Lint(context.Context, *LintRequest) (*LintResponse, error)
+	// ListConstraints returns the constraints assets are reviewed against.
+	ListConstraints(context.Context, *ListConstraintsRequest) (*ListConstraintsResponse, error)
+	// ReloadPolicies loads the policies again from the sources the server was started with and
+	// swaps them in for the current ones. If the policies fail to load, the error is returned and
+	// the current policies are kept.
+	ReloadPolicies(context.Context, *ReloadPoliciesRequest) (*ReloadPoliciesResponse, error)
 }
 
 // UnimplementedValidatorServer can be embedded to have forward compatible implementations.
@@ -769,6 +1602,21 @@ func (*UnimplementedValidatorServer) Reset(ctx context.Context, req *ResetReques
 func (*UnimplementedValidatorServer) Review(ctx context.Context, req *ReviewRequest) (*ReviewResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Review not implemented")
 }
+func (*UnimplementedValidatorServer) GetCapabilities(ctx context.Context, req *GetCapabilitiesRequest) (*GetCapabilitiesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCapabilities not implemented")
+}
+func (*UnimplementedValidatorServer) StreamReview(req *ReviewRequest, srv Validator_StreamReviewServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamReview not implemented")
+}
+func (*UnimplementedValidatorServer) Lint(ctx context.Context, req *LintRequest) (*LintResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Lint not implemented")
+}
+func (*UnimplementedValidatorServer) ListConstraints(ctx context.Context, req *ListConstraintsRequest) (*ListConstraintsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListConstraints not implemented")
+}
+func (*UnimplementedValidatorServer) ReloadPolicies(ctx context.Context, req *ReloadPoliciesRequest) (*ReloadPoliciesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReloadPolicies not implemented")
+}
 
 func RegisterValidatorServer(s *grpc.Server, srv ValidatorServer) {
 	s.RegisterService(&_Validator_serviceDesc, srv)
@@ -846,6 +1694,99 @@ func _Validator_Review_Handler(srv interface{}, ctx context.Context, dec func(in
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Validator_GetCapabilities_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetCapabilitiesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ValidatorServer).GetCapabilities(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/validator.Validator/GetCapabilities",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ValidatorServer).GetCapabilities(ctx, req.(*GetCapabilitiesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Validator_StreamReview_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ReviewRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ValidatorServer).StreamReview(m, &validatorStreamReviewServer{stream})
+}
+
+type Validator_StreamReviewServer interface {
+	Send(*ReviewResponse) error
+	grpc.ServerStream
+}
+
+type validatorStreamReviewServer struct {
+	grpc.ServerStream
+}
+
+func (x *validatorStreamReviewServer) Send(m *ReviewResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Validator_Lint_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LintRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ValidatorServer).Lint(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/validator.Validator/Lint",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ValidatorServer).Lint(ctx, req.(*LintRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Validator_ListConstraints_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListConstraintsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ValidatorServer).ListConstraints(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/validator.Validator/ListConstraints",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ValidatorServer).ListConstraints(ctx, req.(*ListConstraintsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Validator_ReloadPolicies_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReloadPoliciesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ValidatorServer).ReloadPolicies(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/validator.Validator/ReloadPolicies",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ValidatorServer).ReloadPolicies(ctx, req.(*ReloadPoliciesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _Validator_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "validator.Validator",
 	HandlerType: (*ValidatorServer)(nil),
@@ -866,7 +1807,29 @@ var _Validator_serviceDesc = grpc.ServiceDesc{
 			MethodName: "Review",
 			Handler:    _Validator_Review_Handler,
 		},
+		{
+			MethodName: "GetCapabilities",
+			Handler:    _Validator_GetCapabilities_Handler,
+		},
+		{
+			MethodName: "Lint",
+			Handler:    _Validator_Lint_Handler,
+		},
+		{
+			MethodName: "ListConstraints",
+			Handler:    _Validator_ListConstraints_Handler,
+		},
+		{
+			MethodName: "ReloadPolicies",
+			Handler:    _Validator_ReloadPolicies_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamReview",
+			Handler:       _Validator_StreamReview_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "validator.proto",
 }
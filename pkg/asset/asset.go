@@ -17,39 +17,207 @@ package asset
 import (
 	"bytes"
 	"encoding/json"
-	"regexp"
+	"fmt"
 	"strings"
 
 	"github.com/forseti-security/config-validator/pkg/api/validator"
 	"github.com/forseti-security/config-validator/pkg/gcv/configs"
-	"github.com/golang/glog"
 	"github.com/golang/protobuf/jsonpb"
-	"github.com/hashicorp/go-multierror"
 	"github.com/pkg/errors"
-	admissionv1beta1 "k8s.io/api/admission/v1beta1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
-	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/runtime/schema"
+	"go.uber.org/zap"
 )
 
-const logRequestsVerboseLevel = 2
+// FieldError describes a problem with one field of an asset.
+type FieldError struct {
+	// Field is the name of the field as in CAI exports, such as ancestry_path.
+	Field string
+	// Reason describes what is wrong with the field.
+	Reason string
+}
+
+// ValidationError is returned for assets that fail validation. It identifies the asset by name,
+// type and, when read from an export, its location, and lists the offending fields.
+type ValidationError struct {
+	// Name is the name of the asset, empty if it has none.
+	Name string
+	// AssetType is the type of the asset, empty if it has none.
+	AssetType string
+	// Source is the location of the asset in an export, if known.
+	Source *Source
+	// Fields are the problems found, at least one.
+	Fields []FieldError
+}
+
+// Error implements error.
+func (e *ValidationError) Error() string {
+	var b strings.Builder
+	if e.Name != "" {
+		fmt.Fprintf(&b, "invalid asset %q", e.Name)
+	} else {
+		b.WriteString("invalid asset with no name")
+	}
+	if e.AssetType != "" {
+		fmt.Fprintf(&b, " of type %s", e.AssetType)
+	}
+	if e.Source != nil {
+		fmt.Fprintf(&b, " at %s", e.Source)
+	}
+	for idx, field := range e.Fields {
+		if idx == 0 {
+			b.WriteString(": ")
+		} else {
+			b.WriteString("; ")
+		}
+		fmt.Fprintf(&b, "%s: %s", field.Field, field.Reason)
+	}
+	return b.String()
+}
 
+// add records a problem with field.
+func (e *ValidationError) add(field, reason string) {
+	e.Fields = append(e.Fields, FieldError{Field: field, Reason: reason})
+}
+
+// errorOrNil returns e if any problems were recorded.
+func (e *ValidationError) errorOrNil() error {
+	if len(e.Fields) == 0 {
+		return nil
+	}
+	return e
+}
+
+// ancestryKinds are the resource kinds that can appear in ancestry paths.
+var ancestryKinds = map[string]bool{
+	"organizations": true,
+	"folders":       true,
+	"projects":      true,
+}
+
+// checkAncestryPath returns why the normalized ancestry path is malformed, or an empty string if
+// it is a sequence of organizations, folders and projects followed by their IDs.
+func checkAncestryPath(path string) string {
+	parts := strings.Split(path, "/")
+	if len(parts)%2 != 0 {
+		return fmt.Sprintf("%q is not a sequence of kinds and IDs", path)
+	}
+	for idx := 0; idx < len(parts); idx += 2 {
+		if !ancestryKinds[parts[idx]] {
+			return fmt.Sprintf("unexpected kind %q in %q, want organizations, folders or projects", parts[idx], path)
+		}
+		if parts[idx+1] == "" {
+			return fmt.Sprintf("missing ID of %s in %q", parts[idx], path)
+		}
+	}
+	return ""
+}
+
+// ValidateAsset checks that asset has a name, a type, well formed ancestry information and any
+// content. It returns a *ValidationError listing all problems found.
 func ValidateAsset(asset *validator.Asset) error {
-	var result *multierror.Error
+	verr := &ValidationError{Name: asset.GetName(), AssetType: asset.GetAssetType()}
 	if asset.GetName() == "" {
-		result = multierror.Append(result, errors.New("missing asset name"))
-	}
-	if asset.GetAncestryPath() == "" {
-		result = multierror.Append(result, errors.Errorf("asset %q missing ancestry path", asset.GetName()))
+		verr.add("name", "missing")
 	}
 	if asset.GetAssetType() == "" {
-		result = multierror.Append(result, errors.Errorf("asset %q missing type", asset.GetName()))
+		verr.add("asset_type", "missing")
+	}
+	switch {
+	case len(asset.GetAncestors()) != 0:
+		if reason := checkAncestryPath(AncestryPath(asset.GetAncestors())); reason != "" {
+			verr.add("ancestors", reason)
+		}
+	case asset.GetAncestryPath() != "":
+		if reason := checkAncestryPath(configs.NormalizeAncestry(asset.GetAncestryPath())); reason != "" {
+			verr.add("ancestry_path", reason)
+		}
+	default:
+		verr.add("ancestry_path", "missing, and no ancestors are set")
 	}
 	if asset.GetResource() == nil && asset.GetIamPolicy() == nil && asset.GetOrgPolicy() == nil && asset.GetAccessContextPolicy() == nil {
-		result = multierror.Append(result, errors.Errorf("asset %q missing all of these: resource, IAM policy, Org Policy, Access Context Policy", asset.GetName()))
+		verr.add("resource", "missing all of these: resource, iam_policy, org_policy, access context policy")
 	}
-	return result.ErrorOrNil()
+	return verr.errorOrNil()
+}
+
+// contentFields are the fields of which JSON assets must have at least one.
+var contentFields = []string{"resource", "iam_policy", "org_policy", "access_policy", "access_level", "service_perimeter"}
+
+// ValidateJSON checks that the JSON form of an asset has a name, a type and well formed ancestry
+// information, and if requireContent is set, any of the kinds of content in CAI exports. It
+// returns a *ValidationError listing all problems found.
+func ValidateJSON(asset map[string]interface{}, requireContent bool) error {
+	verr := &ValidationError{}
+	for _, field := range []string{"name", "asset_type"} {
+		switch value := asset[field].(type) {
+		case nil:
+			verr.add(field, "missing")
+		case string:
+			if value == "" {
+				verr.add(field, "missing")
+			}
+		default:
+			verr.add(field, fmt.Sprintf("got %T, want string", value))
+		}
+	}
+	verr.Name, _ = asset["name"].(string)
+	verr.AssetType, _ = asset["asset_type"].(string)
+
+	if ancestors := asset["ancestors"]; ancestors != nil && !isEmptyList(ancestors) {
+		if path, reason := jsonAncestryPath(ancestors); reason != "" {
+			verr.add("ancestors", reason)
+		} else if reason := checkAncestryPath(path); reason != "" {
+			verr.add("ancestors", reason)
+		}
+	} else {
+		switch path := asset["ancestry_path"].(type) {
+		case nil:
+			verr.add("ancestry_path", "missing, and no ancestors are set")
+		case string:
+			if path == "" {
+				verr.add("ancestry_path", "missing, and no ancestors are set")
+			} else if reason := checkAncestryPath(configs.NormalizeAncestry(path)); reason != "" {
+				verr.add("ancestry_path", reason)
+			}
+		default:
+			verr.add("ancestry_path", fmt.Sprintf("got %T, want string", path))
+		}
+	}
+
+	if requireContent {
+		hasContent := false
+		for _, field := range contentFields {
+			if asset[field] != nil {
+				hasContent = true
+			}
+		}
+		if !hasContent {
+			verr.add("resource", "missing all of these: "+strings.Join(contentFields, ", "))
+		}
+	}
+	return verr.errorOrNil()
+}
+
+func isEmptyList(v interface{}) bool {
+	list, ok := v.([]interface{})
+	return ok && len(list) == 0
+}
+
+// jsonAncestryPath returns the ancestry path given by the ancestors list of a JSON asset, or why
+// the list is invalid.
+func jsonAncestryPath(ancestors interface{}) (string, string) {
+	list, ok := ancestors.([]interface{})
+	if !ok {
+		return "", fmt.Sprintf("got %T, want list of strings", ancestors)
+	}
+	strs := make([]string, len(list))
+	for idx, item := range list {
+		s, ok := item.(string)
+		if !ok {
+			return "", fmt.Sprintf("got %T at index %d, want string", item, idx)
+		}
+		strs[idx] = s
+	}
+	return AncestryPath(strs), ""
 }
 
 func ConvertResourceViaJSONToInterface(asset *validator.Asset) (interface{}, error) {
@@ -62,19 +230,86 @@ func ConvertResourceViaJSONToInterface(asset *validator.Asset) (interface{}, err
 	if asset.Resource != nil {
 		CleanStructValue(asset.Resource.Data)
 	}
-	glog.V(logRequestsVerboseLevel).Infof("converting asset to golang interface: %v", asset)
+	zap.L().Debug("converting asset to golang interface", zap.String("asset", asset.Name))
 	var buf bytes.Buffer
 	if err := m.Marshal(&buf, asset); err != nil {
 		return nil, errors.Wrapf(err, "marshalling to json with asset %s: %v", asset.Name, asset)
 	}
 	var f interface{}
-	err := json.Unmarshal(buf.Bytes(), &f)
+	err := UnmarshalJSON(buf.Bytes(), &f)
 	if err != nil {
 		return nil, errors.Wrapf(err, "marshalling from json with asset %s: %v", asset.Name, asset)
 	}
 	return f, nil
 }
 
+// UnmarshalJSON decodes data into v, keeping numbers as json.Number so that int64 values such as
+// project numbers and byte counts do not lose precision by passing through float64.
+func UnmarshalJSON(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(v); err != nil {
+		return err
+	}
+	if decoder.More() {
+		return errors.Errorf("unexpected data after JSON value")
+	}
+	return nil
+}
+
+// NormalizeFieldNames renames the fields of asset given by their JSON names, such as assetType
+// and iamPolicy in the responses of the Cloud Asset API, to the proto field names used by CAI
+// exports. The fields within the resource data are the fields of the resource itself and are kept
+// as they are, as are fields whose proto name is already set.
+func NormalizeFieldNames(asset map[string]interface{}) {
+	normalizeFieldNames(asset, false)
+}
+
+// normalizeFieldNames renames the fields of m and of the objects within it to their proto names.
+// If resource is set, m is the resource of an asset, and its data is kept as is.
+func normalizeFieldNames(m map[string]interface{}, resource bool) {
+	renames := map[string]string{}
+	for key, value := range m {
+		if resource && key == "data" {
+			continue
+		}
+		normalizeValueFieldNames(value, key == "resource" && !resource)
+		if name := protoName(key); name != key {
+			renames[key] = name
+		}
+	}
+	for key, name := range renames {
+		if _, found := m[name]; !found {
+			m[name] = m[key]
+			delete(m, key)
+		}
+	}
+}
+
+func normalizeValueFieldNames(value interface{}, resource bool) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		normalizeFieldNames(v, resource)
+	case []interface{}:
+		for _, item := range v {
+			normalizeValueFieldNames(item, false)
+		}
+	}
+}
+
+// protoName returns the snake case proto field name of the lower camel case JSON name.
+func protoName(jsonName string) string {
+	var b strings.Builder
+	for _, r := range jsonName {
+		if 'A' <= r && r <= 'Z' {
+			b.WriteByte('_')
+			r += 'a' - 'A'
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
 // SanitizeAncestryPath will populate the AncestryPath field from the ancestors list, or fix the pre-populated one
 // if no ancestry list is provided.
 func SanitizeAncestryPath(asset *validator.Asset) error {
@@ -100,108 +335,3 @@ func AncestryPath(ancestors []string) string {
 	}
 	return strings.Join(revAncestors, "/")
 }
-
-// UnwrapCAIResource will unwrap a K8S resource from the CAI payload and populate any omitted fields.
-func UnwrapCAIResource(asset map[string]interface{}) (*unstructured.Unstructured, error) {
-	groupKind, found, err := unstructured.NestedString(asset, "asset_type")
-	if err != nil {
-		return nil, errors.Wrapf(err, "failed to access asset_type field")
-	}
-	if !found {
-		return nil, errors.Errorf("asset_type field not found")
-	}
-
-	parts := strings.Split(groupKind, "/")
-	if len(parts) != 2 {
-		return nil, errors.Errorf("expected asset_type to be of form \"<group>/<kind>\", got %s", groupKind)
-	}
-
-	group := parts[0]
-	kind := parts[1]
-	// CAI pretends that the core resources are part of the "k8s.io" apiGroup.  For compatibility with what one would
-	// see in kubernetes, we set the group to empty string ("").
-	if group == "k8s.io" {
-		group = ""
-	}
-
-	version, found, err := unstructured.NestedString(asset, "resource", "version")
-	if err != nil {
-		return nil, errors.Wrapf(err, "failed to access resource.version field")
-	}
-	if !found {
-		return nil, errors.Errorf("resource.version field not found")
-	}
-
-	resource, found, err := unstructured.NestedMap(asset, "resource", "data")
-	if err != nil {
-		return nil, errors.Wrapf(err, "failed to access resource.data field")
-	}
-	if !found {
-		return nil, errors.Errorf("resource.data field not found")
-	}
-
-	u := &unstructured.Unstructured{Object: resource}
-	u.SetGroupVersionKind(schema.GroupVersionKind{
-		Group:   group,
-		Version: version,
-		Kind:    kind,
-	})
-
-	ancestors, found, err := unstructured.NestedStringSlice(asset, "ancestors")
-	if err != nil {
-		return nil, errors.Wrapf(err, "failed to access ancestors field")
-	}
-	if !found {
-		return nil, errors.Errorf("ancestors field not found")
-	}
-
-	annotations := u.GetAnnotations()
-	if annotations == nil {
-		annotations = map[string]string{}
-	}
-	annotations["validator.forsetisecurity.org/ancestorPath"] = AncestryPath(ancestors)
-	u.SetAnnotations(annotations)
-
-	return u, nil
-}
-
-// ConvertToAdmissionRequest converts a CAI asset containing a K8S type to an AdmissionRequest which is the format that
-// the Gatekeeper Constraint Framework target expects.
-func ConvertToAdmissionRequest(asset map[string]interface{}) (*admissionv1beta1.AdmissionRequest, error) {
-	resource, err := UnwrapCAIResource(asset)
-	if err != nil {
-		return nil, errors.Wrapf(err, "failed to unwrap k8s resource from CAI asset")
-	}
-
-	resourceJSON, err := json.Marshal(resource.Object)
-	if err != nil {
-		return nil, errors.Wrapf(err, "failed to convert unwrapped resource to JSON")
-	}
-
-	gvk := resource.GroupVersionKind()
-	req := &admissionv1beta1.AdmissionRequest{
-		Kind: metav1.GroupVersionKind{
-			Group:   gvk.Group,
-			Version: gvk.Version,
-			Kind:    gvk.Kind,
-		},
-		Object: runtime.RawExtension{
-			Raw: resourceJSON,
-		},
-		Name: resource.GetName(),
-	}
-	return req, nil
-}
-
-// k8s assset names will follow pattern:
-// //container.googleapis.com/projects/*/(locations|zones)/*/clusters/*/k8s
-var assetPath = regexp.MustCompile(`^//container.googleapis.com/projects/[^/]*/(locations|zones)/[^/]*/clusters/[^/]*/k8s`)
-
-// IsK8S returns true if the CAI asset is an asset from a kubernetes cluster.
-func IsK8S(asset map[string]interface{}) bool {
-	assetName, found, err := unstructured.NestedString(asset, "name")
-	if !found || err != nil {
-		return false
-	}
-	return assetPath.MatchString(assetName)
-}
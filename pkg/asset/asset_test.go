@@ -97,3 +97,164 @@ func TestConvertResourceToInterface(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateJSON(t *testing.T) {
+	testCases := []struct {
+		description    string
+		input          map[string]interface{}
+		requireContent bool
+		want           string
+	}{
+		{
+			description: "valid with ancestry path",
+			input: map[string]interface{}{
+				"name": "//storage.googleapis.com/b", "asset_type": "storage.googleapis.com/Bucket",
+				"ancestry_path": "organization/1/folder/2/project/3", "resource": map[string]interface{}{},
+			},
+			requireContent: true,
+		},
+		{
+			description: "valid with ancestors",
+			input: map[string]interface{}{
+				"name": "//storage.googleapis.com/b", "asset_type": "storage.googleapis.com/Bucket",
+				"ancestors": []interface{}{"projects/3", "organizations/1"}, "iam_policy": map[string]interface{}{},
+			},
+			requireContent: true,
+		},
+		{
+			description: "content not required",
+			input: map[string]interface{}{
+				"name": "//storage.googleapis.com/b", "asset_type": "storage.googleapis.com/Bucket",
+				"ancestry_path": "organizations/1",
+			},
+		},
+		{
+			description: "missing name",
+			input: map[string]interface{}{
+				"asset_type": "storage.googleapis.com/Bucket", "ancestry_path": "organizations/1",
+				"resource": map[string]interface{}{},
+			},
+			requireContent: true,
+			want:           "invalid asset with no name of type storage.googleapis.com/Bucket: name: missing",
+		},
+		{
+			description: "missing content",
+			input: map[string]interface{}{
+				"name": "//storage.googleapis.com/b", "asset_type": "storage.googleapis.com/Bucket",
+				"ancestry_path": "organizations/1",
+			},
+			requireContent: true,
+			want: `invalid asset "//storage.googleapis.com/b" of type storage.googleapis.com/Bucket: resource: ` +
+				"missing all of these: resource, iam_policy, org_policy, access_policy, access_level, service_perimeter",
+		},
+		{
+			description: "malformed ancestry path",
+			input: map[string]interface{}{
+				"name": "//storage.googleapis.com/b", "asset_type": 7,
+				"ancestry_path": "organizations/1/buckets/2", "resource": map[string]interface{}{},
+			},
+			requireContent: true,
+			want: `invalid asset "//storage.googleapis.com/b": asset_type: got int, want string; ` +
+				`ancestry_path: unexpected kind "buckets" in "organizations/1/buckets/2", want organizations, folders or projects`,
+		},
+		{
+			description: "malformed ancestors",
+			input: map[string]interface{}{
+				"name": "//storage.googleapis.com/b", "asset_type": "storage.googleapis.com/Bucket",
+				"ancestors": []interface{}{"projects", "organizations/1"}, "resource": map[string]interface{}{},
+			},
+			requireContent: true,
+			want: `invalid asset "//storage.googleapis.com/b" of type storage.googleapis.com/Bucket: ` +
+				`ancestors: "organizations/1/projects" is not a sequence of kinds and IDs`,
+		},
+		{
+			description: "missing ancestry",
+			input: map[string]interface{}{
+				"name": "//storage.googleapis.com/b", "asset_type": "storage.googleapis.com/Bucket",
+				"ancestors": []interface{}{}, "resource": map[string]interface{}{},
+			},
+			requireContent: true,
+			want: `invalid asset "//storage.googleapis.com/b" of type storage.googleapis.com/Bucket: ` +
+				"ancestry_path: missing, and no ancestors are set",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			err := ValidateJSON(tc.input, tc.requireContent)
+			if tc.want == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if _, ok := err.(*ValidationError); !ok {
+				t.Fatalf("got error %v, want *ValidationError", err)
+			}
+			if err.Error() != tc.want {
+				t.Errorf("got error\n%s\nwant\n%s", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestValidateAsset(t *testing.T) {
+	err := ValidateAsset(&validator.Asset{AncestryPath: "organizations/1/projects"})
+	verr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("got error %v, want *ValidationError", err)
+	}
+	var fields []string
+	for _, field := range verr.Fields {
+		fields = append(fields, field.Field)
+	}
+	if diff := cmp.Diff([]string{"name", "asset_type", "ancestry_path", "resource"}, fields); diff != "" {
+		t.Errorf("unexpected invalid fields (-want +got):\n%s", diff)
+	}
+	if err := ValidateAsset(&validator.Asset{
+		Name: "//storage.googleapis.com/b", AssetType: "storage.googleapis.com/Bucket",
+		Ancestors: []string{"projects/3", "organizations/1"}, Resource: &asset.Resource{},
+	}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestNormalizeFieldNames(t *testing.T) {
+	asset := map[string]interface{}{}
+	err := UnmarshalJSON([]byte(`{
+  "name": "//storage.googleapis.com/my-bucket",
+  "assetType": "storage.googleapis.com/Bucket",
+  "ancestors": ["projects/1", "organizations/2"],
+  "resource": {
+    "discoveryName": "Bucket",
+    "data": {"iamConfiguration": {"uniformBucketLevelAccess": {"enabled": true}}}
+  },
+  "iamPolicy": {"bindings": [{"role": "roles/viewer", "members": ["allUsers"]}], "auditConfigs": []},
+  "orgPolicy": [{"listPolicy": {"allValues": "DENY"}}],
+  "ancestry_path": "organizations/2/projects/1",
+  "ancestryPath": "ignored"
+}`), &asset)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	NormalizeFieldNames(asset)
+
+	want := map[string]interface{}{}
+	if err := UnmarshalJSON([]byte(`{
+  "name": "//storage.googleapis.com/my-bucket",
+  "asset_type": "storage.googleapis.com/Bucket",
+  "ancestors": ["projects/1", "organizations/2"],
+  "resource": {
+    "discovery_name": "Bucket",
+    "data": {"iamConfiguration": {"uniformBucketLevelAccess": {"enabled": true}}}
+  },
+  "iam_policy": {"bindings": [{"role": "roles/viewer", "members": ["allUsers"]}], "audit_configs": []},
+  "org_policy": [{"list_policy": {"all_values": "DENY"}}],
+  "ancestry_path": "organizations/2/projects/1",
+  "ancestryPath": "ignored"
+}`), &want); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if diff := cmp.Diff(want, asset); diff != "" {
+		t.Errorf("unexpected asset (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package asset
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/forseti-security/config-validator/pkg/api/validator"
+	"github.com/golang/protobuf/jsonpb"
+)
+
+// benchmarkExport is a newline delimited CAI export of typical GCP and K8S assets.
+const benchmarkExport = "../../test/cai/assets.json"
+
+func readBenchmarkExport(b *testing.B) []byte {
+	data, err := ioutil.ReadFile(benchmarkExport)
+	if err != nil {
+		b.Fatal("unexpected error", err)
+	}
+	return data
+}
+
+func benchmarkAssets(b *testing.B) []*validator.Asset {
+	var assets []*validator.Asset
+	for _, line := range bytes.Split(bytes.TrimSpace(readBenchmarkExport(b)), []byte("\n")) {
+		pbAsset := &validator.Asset{}
+		if err := jsonpb.Unmarshal(bytes.NewReader(line), pbAsset); err != nil {
+			b.Fatal("unexpected error", err)
+		}
+		assets = append(assets, pbAsset)
+	}
+	return assets
+}
+
+func BenchmarkReader(b *testing.B) {
+	data := readBenchmarkExport(b)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		reader := NewReader(bytes.NewReader(data), benchmarkExport)
+		for {
+			if _, err := reader.Next(); err == io.EOF {
+				break
+			} else if err != nil {
+				b.Fatal("unexpected error", err)
+			}
+		}
+	}
+}
+
+func BenchmarkConvertResourceViaJSONToInterface(b *testing.B) {
+	for _, pbAsset := range benchmarkAssets(b) {
+		pbAsset := pbAsset
+		b.Run(pbAsset.AssetType, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := ConvertResourceViaJSONToInterface(pbAsset); err != nil {
+					b.Fatal("unexpected error", err)
+				}
+			}
+		})
+	}
+}
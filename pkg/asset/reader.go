@@ -0,0 +1,306 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package asset
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/pkg/errors"
+	assetpb "google.golang.org/genproto/googleapis/cloud/asset/v1"
+	orgpolicy "google.golang.org/genproto/googleapis/cloud/orgpolicy/v1"
+	accesscontextmanager "google.golang.org/genproto/googleapis/identity/accesscontextmanager/v1"
+)
+
+// Source identifies the location of an asset record in a CAI export.
+type Source struct {
+	// File is the name of the export file, empty if the data was not read from a file.
+	File string `json:"file,omitempty"`
+	// Line is the 1-based line number of the record.
+	Line int `json:"line"`
+	// Offset is the byte offset of the start of the record.
+	Offset int64 `json:"offset"`
+}
+
+// String returns the source in file:line format.
+func (s Source) String() string {
+	return fmt.Sprintf("%s:%d", s.File, s.Line)
+}
+
+// Record is a single asset read from a CAI export.
+type Record struct {
+	// Asset is the decoded asset.
+	Asset map[string]interface{}
+	// Source is where the asset was read from.
+	Source Source
+}
+
+// DecodeError is returned by Reader.Next when a record could not be decoded. Reading can continue
+// with the next record.
+type DecodeError struct {
+	// Source is the location of the record that failed to decode.
+	Source Source
+	// Err is the underlying decode error.
+	Err error
+}
+
+// Error implements error.
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("failed to decode asset at %s: %s", e.Source, e.Err)
+}
+
+// gzipMagic are the first bytes of a gzip stream.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// Reader reads assets from a CAI export, either newline delimited JSON or a stream of length
+// delimited binary Asset protos.
+type Reader struct {
+	r      *bufio.Reader
+	err    error
+	proto  bool
+	file   string
+	line   int
+	offset int64
+}
+
+// NewReader returns a Reader for the export in r. File is used to populate the source of the
+// returned records. Gzip compressed exports are detected and decompressed while reading, in
+// which case the offsets of the returned records refer to the decompressed export.
+//
+// Binary exports are detected by decoding their first record, see isProtoExport. Use
+// NewProtoReader if the format is known to be binary.
+func NewReader(r io.Reader, file string) *Reader {
+	reader := newReader(r, file)
+	if reader.err == nil {
+		reader.proto = isProtoExport(reader.r)
+	}
+	return reader
+}
+
+// NewProtoReader returns a Reader for a binary export in r, a stream of google.cloud.asset.v1.Asset
+// protos each preceded by its varint encoded length. The source line of the returned records is
+// the 1-based index of the record in the export. Gzip compressed exports are detected as with
+// NewReader. Resource data is a Struct in the proto, so integers beyond 2^53 lose precision.
+func NewProtoReader(r io.Reader, file string) *Reader {
+	reader := newReader(r, file)
+	reader.proto = true
+	return reader
+}
+
+func newReader(r io.Reader, file string) *Reader {
+	reader := &Reader{r: bufio.NewReader(r), file: file}
+	if magic, _ := reader.r.Peek(len(gzipMagic)); bytes.Equal(magic, gzipMagic) {
+		gz, err := gzip.NewReader(reader.r)
+		if err != nil {
+			reader.err = errors.Wrapf(err, "failed to decompress %s", file)
+			return reader
+		}
+		reader.r = bufio.NewReader(gz)
+	}
+	return reader
+}
+
+// isProtoExport returns whether the export in r is binary, a stream of length delimited Asset
+// protos. A binary export is detected by trial decoding its first record, as its length may be a
+// byte that also starts a JSON export, such as whitespace. Records too large to be peeked have a
+// multi-byte length, which no JSON export starts with, and are only checked for the tag of the
+// name field.
+func isProtoExport(r *bufio.Reader) bool {
+	start, _ := r.Peek(binary.MaxVarintLen64)
+	length, n := binary.Uvarint(start)
+	if n <= 0 || length == 0 || length > maxProtoRecordSize {
+		return false
+	}
+	record, err := r.Peek(n + int(length))
+	if len(record) <= n || record[n] != protoNameTag {
+		return false
+	}
+	if err != nil {
+		return err == bufio.ErrBufferFull
+	}
+	pbAsset := &assetpb.Asset{}
+	return proto.Unmarshal(record[n:], pbAsset) == nil && pbAsset.GetName() != ""
+}
+
+// Next returns the next record in the export, or io.EOF when the export is exhausted. Blank lines
+// are skipped. Records that fail to decode are reported as a *DecodeError, after which the reader
+// can continue with the next record.
+func (r *Reader) Next() (*Record, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	if r.proto {
+		return r.nextProto()
+	}
+	for {
+		line, err := r.r.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, errors.Wrapf(err, "failed to read %s", r.file)
+		}
+		if len(line) == 0 && err == io.EOF {
+			return nil, io.EOF
+		}
+
+		source := Source{File: r.file, Line: r.line + 1, Offset: r.offset}
+		r.line++
+		r.offset += int64(len(line))
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		asset := map[string]interface{}{}
+		if err := UnmarshalJSON(line, &asset); err != nil {
+			return nil, &DecodeError{Source: source, Err: err}
+		}
+		return &Record{Asset: asset, Source: source}, nil
+	}
+}
+
+const (
+	// protoNameTag is the tag of the name field of google.cloud.asset.v1.Asset.
+	protoNameTag = 1<<3 | 2
+	// maxProtoRecordSize bounds the size of a single binary asset, larger lengths indicate a
+	// corrupt export.
+	maxProtoRecordSize = 64 << 20
+)
+
+// protoMarshaler converts binary assets to the JSON export format, which uses the proto field
+// names.
+var protoMarshaler = &jsonpb.Marshaler{OrigName: true}
+
+// protoPolicyField is a field of google.cloud.asset.v1.Asset that is missing from assetpb.Asset.
+// Such fields end up in XXX_unrecognized and are decoded separately.
+type protoPolicyField struct {
+	name     string
+	repeated bool
+	message  func() proto.Message
+}
+
+// protoPolicyFields are the org policy and access context fields by field number.
+var protoPolicyFields = map[uint64]protoPolicyField{
+	6: {name: "org_policy", repeated: true, message: func() proto.Message { return &orgpolicy.Policy{} }},
+	7: {name: "access_policy", message: func() proto.Message { return &accesscontextmanager.AccessPolicy{} }},
+	8: {name: "access_level", message: func() proto.Message { return &accesscontextmanager.AccessLevel{} }},
+	9: {name: "service_perimeter", message: func() proto.Message { return &accesscontextmanager.ServicePerimeter{} }},
+}
+
+func (r *Reader) nextProto() (*Record, error) {
+	size, err := binary.ReadUvarint(r.r)
+	if err == io.EOF {
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read record length at offset %d of %s", r.offset, r.file)
+	}
+	if size > maxProtoRecordSize {
+		return nil, errors.Errorf("record length %d at offset %d of %s exceeds maximum size", size, r.offset, r.file)
+	}
+
+	source := Source{File: r.file, Line: r.line + 1, Offset: r.offset}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r.r, data); err != nil {
+		return nil, errors.Wrapf(err, "failed to read record at offset %d of %s", r.offset, r.file)
+	}
+	var lengthBuf [binary.MaxVarintLen64]byte
+	r.line++
+	r.offset += int64(binary.PutUvarint(lengthBuf[:], size)) + int64(size)
+
+	pbAsset := &assetpb.Asset{}
+	if err := proto.Unmarshal(data, pbAsset); err != nil {
+		return nil, &DecodeError{Source: source, Err: err}
+	}
+	assetJSON, err := protoMarshaler.MarshalToString(pbAsset)
+	if err != nil {
+		return nil, &DecodeError{Source: source, Err: err}
+	}
+	asset := map[string]interface{}{}
+	if err := UnmarshalJSON([]byte(assetJSON), &asset); err != nil {
+		return nil, &DecodeError{Source: source, Err: err}
+	}
+	if err := decodePolicyFields(pbAsset.XXX_unrecognized, asset); err != nil {
+		return nil, &DecodeError{Source: source, Err: err}
+	}
+	return &Record{Asset: asset, Source: source}, nil
+}
+
+// decodePolicyFields adds the org policy and access context fields found in the unrecognized
+// fields of an Asset proto to asset. Other unrecognized fields are skipped.
+func decodePolicyFields(data []byte, asset map[string]interface{}) error {
+	for len(data) > 0 {
+		tag, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errors.Errorf("invalid field tag")
+		}
+		data = data[n:]
+		var value []byte
+		switch wireType := tag & 7; wireType {
+		case proto.WireVarint:
+			if _, n = binary.Uvarint(data); n <= 0 {
+				return errors.Errorf("invalid varint in field %d", tag>>3)
+			}
+			data = data[n:]
+			continue
+		case proto.WireFixed64, proto.WireFixed32:
+			size := 8
+			if wireType == proto.WireFixed32 {
+				size = 4
+			}
+			if len(data) < size {
+				return errors.Errorf("truncated field %d", tag>>3)
+			}
+			data = data[size:]
+			continue
+		case proto.WireBytes:
+			size, n := binary.Uvarint(data)
+			if n <= 0 || size > uint64(len(data)-n) {
+				return errors.Errorf("truncated field %d", tag>>3)
+			}
+			value, data = data[n:n+int(size)], data[n+int(size):]
+		default:
+			return errors.Errorf("unsupported wire type %d in field %d", wireType, tag>>3)
+		}
+
+		field, found := protoPolicyFields[tag>>3]
+		if !found {
+			continue
+		}
+		msg := field.message()
+		if err := proto.Unmarshal(value, msg); err != nil {
+			return errors.Wrapf(err, "failed to decode %s", field.name)
+		}
+		msgJSON, err := protoMarshaler.MarshalToString(msg)
+		if err != nil {
+			return errors.Wrapf(err, "failed to convert %s to JSON", field.name)
+		}
+		var v interface{}
+		if err := UnmarshalJSON([]byte(msgJSON), &v); err != nil {
+			return errors.Wrapf(err, "failed to convert %s to JSON", field.name)
+		}
+		if field.repeated {
+			values, _ := asset[field.name].([]interface{})
+			asset[field.name] = append(values, v)
+		} else {
+			asset[field.name] = v
+		}
+	}
+	return nil
+}
@@ -0,0 +1,333 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package asset
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/go-cmp/cmp"
+	assetpb "google.golang.org/genproto/googleapis/cloud/asset/v1"
+	orgpolicy "google.golang.org/genproto/googleapis/cloud/orgpolicy/v1"
+	accesscontextmanager "google.golang.org/genproto/googleapis/identity/accesscontextmanager/v1"
+)
+
+const testExport = `{"name": "a"}
+
+{"name": "b", "resource": {"data": {"size": 9007199254740993}}}
+not json
+{"name": "c"}`
+
+type readRecord struct {
+	name   string
+	source Source
+	err    bool
+}
+
+var wantTestExport = []readRecord{
+	{name: "a", source: Source{File: "export.json", Line: 1, Offset: 0}},
+	{name: "b", source: Source{File: "export.json", Line: 3, Offset: 15}},
+	{source: Source{File: "export.json", Line: 4, Offset: 79}, err: true},
+	{name: "c", source: Source{File: "export.json", Line: 5, Offset: 88}},
+}
+
+// readAll returns the records and decode errors read by reader.
+func readAll(t *testing.T, reader *Reader) []readRecord {
+	var gots []readRecord
+	for {
+		record, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if decodeErr, ok := err.(*DecodeError); ok {
+			gots = append(gots, readRecord{source: decodeErr.Source, err: true})
+			continue
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		gots = append(gots, readRecord{name: record.Asset["name"].(string), source: record.Source})
+	}
+	return gots
+}
+
+func TestReader(t *testing.T) {
+	gots := readAll(t, NewReader(strings.NewReader(testExport), "export.json"))
+	if diff := cmp.Diff(gots, wantTestExport, cmp.AllowUnexported(readRecord{})); diff != "" {
+		t.Errorf("records mismatch, +got -want\n%s", diff)
+	}
+}
+
+func TestReaderGzip(t *testing.T) {
+	// Concatenated gzip members, as produced by compressing an export in parts.
+	var compressed bytes.Buffer
+	for _, part := range []string{testExport[:40], testExport[40:]} {
+		gz := gzip.NewWriter(&compressed)
+		if _, err := gz.Write([]byte(part)); err != nil {
+			t.Fatal(err)
+		}
+		if err := gz.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	gots := readAll(t, NewReader(&compressed, "export.json"))
+	if diff := cmp.Diff(gots, wantTestExport, cmp.AllowUnexported(readRecord{})); diff != "" {
+		t.Errorf("records mismatch, +got -want\n%s", diff)
+	}
+}
+
+func TestReaderGzipCorrupt(t *testing.T) {
+	reader := NewReader(bytes.NewReader([]byte{0x1f, 0x8b, 0x00}), "export.json.gz")
+	_, err := reader.Next()
+	if err == nil || err == io.EOF {
+		t.Fatalf("got %v, want decompression error", err)
+	}
+	if _, ok := err.(*DecodeError); ok {
+		t.Errorf("got recoverable decode error %v, want fatal error", err)
+	}
+}
+
+// protoExport converts a JSON export to a binary export, returning the records the JSON export
+// decodes to without fields unknown to the Asset proto.
+func protoExport(t *testing.T, jsonExport string) ([]byte, []map[string]interface{}) {
+	var export bytes.Buffer
+	var want []map[string]interface{}
+	reader := NewReader(strings.NewReader(jsonExport), "export.json")
+	for {
+		record, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		delete(record.Asset, "ancestry_path")
+		want = append(want, record.Asset)
+
+		assetJSON, err := json.Marshal(record.Asset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pbAsset := &assetpb.Asset{}
+		if err := jsonpb.UnmarshalString(string(assetJSON), pbAsset); err != nil {
+			t.Fatal(err)
+		}
+		data, err := proto.Marshal(pbAsset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var length [binary.MaxVarintLen64]byte
+		export.Write(length[:binary.PutUvarint(length[:], uint64(len(data)))])
+		export.Write(data)
+	}
+	return export.Bytes(), want
+}
+
+// numbersAsFloat compares numbers by value, binary exports store all numbers as doubles.
+var numbersAsFloat = cmp.Transformer("float", func(n json.Number) float64 {
+	f, _ := n.Float64()
+	return f
+})
+
+func TestProtoReader(t *testing.T) {
+	jsonExport, err := ioutil.ReadFile("../../test/cai/assets.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	export, want := protoExport(t, string(jsonExport))
+
+	for name, reader := range map[string]*Reader{
+		"detected": NewReader(bytes.NewReader(export), "export.pb"),
+		"explicit": NewProtoReader(bytes.NewReader(export), "export.pb"),
+	} {
+		t.Run(name, func(t *testing.T) {
+			var got []map[string]interface{}
+			var offset int64
+			for {
+				record, err := reader.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if record.Source.Line != len(got)+1 || record.Source.Offset < offset {
+					t.Errorf("got source %+v after offset %d for record %d", record.Source, offset, len(got)+1)
+				}
+				offset = record.Source.Offset
+				got = append(got, record.Asset)
+			}
+			if diff := cmp.Diff(want, got, numbersAsFloat); diff != "" {
+				t.Errorf("records mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestReaderDetection(t *testing.T) {
+	// binaryExport returns a binary export of assets with the given names.
+	binaryExport := func(names ...string) []byte {
+		var export []byte
+		for _, name := range names {
+			data, err := proto.Marshal(&assetpb.Asset{Name: name})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var length [binary.MaxVarintLen64]byte
+			export = append(export, length[:binary.PutUvarint(length[:], uint64(len(data)))]...)
+			export = append(export, data...)
+		}
+		return export
+	}
+	type testCase struct {
+		name   string
+		export []byte
+		want   []readRecord
+	}
+	testCases := []testCase{
+		{
+			name:   "json with leading whitespace",
+			export: []byte(" \n\t{\"name\": \"a\"}\n"),
+			want:   []readRecord{{name: "a", source: Source{Line: 2, Offset: 2}}},
+		},
+		{
+			name:   "binary record larger than the read buffer",
+			export: binaryExport(strings.Repeat("a", 5000)),
+			want:   []readRecord{{name: strings.Repeat("a", 5000), source: Source{Line: 1}}},
+		},
+		{
+			name:   "json array",
+			export: []byte(`[{"name": "a"}]`),
+			want:   []readRecord{{source: Source{Line: 1}, err: true}},
+		},
+	}
+	// Records of these lengths start with a tab, line feed, carriage return or space.
+	for _, length := range []int{9, 10, 13, 32} {
+		name := strings.Repeat("a", length-2)
+		testCases = append(testCases, testCase{
+			name:   fmt.Sprintf("binary record of %d bytes", length),
+			export: binaryExport(name, "b"),
+			want: []readRecord{
+				{name: name, source: Source{Line: 1}},
+				{name: "b", source: Source{Line: 2, Offset: int64(length + 1)}},
+			},
+		})
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := readAll(t, NewReader(bytes.NewReader(tc.export), ""))
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(readRecord{})); diff != "" {
+				t.Errorf("records mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestProtoReaderErrors(t *testing.T) {
+	var testCases = []struct {
+		name        string
+		export      []byte
+		recoverable bool
+	}{
+		{
+			name:   "truncated record",
+			export: []byte{0x0a, 0x0a, 0x03, 'a'},
+		},
+		{
+			name:   "oversized record",
+			export: []byte{0xff, 0xff, 0xff, 0xff, 0x0f},
+		},
+		{
+			name:        "invalid asset",
+			export:      []byte{0x02, 0x0a, 0x05},
+			recoverable: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewProtoReader(bytes.NewReader(tc.export), "export.pb").Next()
+			if err == nil || err == io.EOF {
+				t.Fatalf("got %v, want error", err)
+			}
+			if _, ok := err.(*DecodeError); ok != tc.recoverable {
+				t.Errorf("got %v, want recoverable %v", err, tc.recoverable)
+			}
+		})
+	}
+}
+
+// appendField appends msg as the length delimited field number field to data.
+func appendField(t *testing.T, data []byte, field uint64, msg proto.Message) []byte {
+	value, err := proto.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = append(data, proto.EncodeVarint(field<<3|proto.WireBytes)...)
+	data = append(data, proto.EncodeVarint(uint64(len(value)))...)
+	return append(data, value...)
+}
+
+func TestProtoReaderPolicyFields(t *testing.T) {
+	data, err := proto.Marshal(&assetpb.Asset{
+		Name:      "//cloudresourcemanager.googleapis.com/projects/1",
+		AssetType: "cloudresourcemanager.googleapis.com/Project",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, constraint := range []string{"constraints/compute.vmExternalIpAccess", "constraints/iam.allowedPolicyMemberDomains"} {
+		data = appendField(t, data, 6, &orgpolicy.Policy{Constraint: constraint})
+	}
+	data = appendField(t, data, 9, &accesscontextmanager.ServicePerimeter{
+		Name:   "accessPolicies/1/servicePerimeters/p",
+		Status: &accesscontextmanager.ServicePerimeterConfig{RestrictedServices: []string{"storage.googleapis.com"}},
+	})
+	// An unknown varint field is skipped.
+	data = append(data, proto.EncodeVarint(15<<3|proto.WireVarint)...)
+	data = append(data, proto.EncodeVarint(300)...)
+
+	var export bytes.Buffer
+	export.Write(proto.EncodeVarint(uint64(len(data))))
+	export.Write(data)
+	record, err := NewProtoReader(&export, "export.pb").Next()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]interface{}{
+		"name":       "//cloudresourcemanager.googleapis.com/projects/1",
+		"asset_type": "cloudresourcemanager.googleapis.com/Project",
+		"org_policy": []interface{}{
+			map[string]interface{}{"constraint": "constraints/compute.vmExternalIpAccess"},
+			map[string]interface{}{"constraint": "constraints/iam.allowedPolicyMemberDomains"},
+		},
+		"service_perimeter": map[string]interface{}{
+			"name":   "accessPolicies/1/servicePerimeters/p",
+			"status": map[string]interface{}{"restricted_services": []interface{}{"storage.googleapis.com"}},
+		},
+	}
+	if diff := cmp.Diff(want, record.Asset); diff != "" {
+		t.Errorf("record mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -15,9 +15,13 @@
 package bundlemanager
 
 import (
-	"github.com/golang/glog"
+	"os"
+	"path/filepath"
+	"strings"
+
 	constraintv1alpha1 "github.com/open-policy-agent/frameworks/constraint/pkg/apis/templates/v1alpha1"
 	"github.com/pkg/errors"
+	"go.uber.org/zap"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -64,9 +68,25 @@ func New() *BundleManager {
 }
 
 func (b *BundleManager) Load(path string) error {
+	// Only YAML files hold policies, JSON files may be asset fixtures of policy tests.
+	var filenames []string
+	err := filepath.Walk(path, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && strings.HasSuffix(filePath, ".yaml") {
+			filenames = append(filenames, filePath)
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to list files in %s", path)
+	}
+	if len(filenames) == 0 {
+		return nil
+	}
 	filenameOptions := &resource.FilenameOptions{
-		Recursive: true,
-		Filenames: []string{path},
+		Filenames: filenames,
 	}
 
 	cfgFlags := genericclioptions.NewConfigFlags(true)
@@ -85,12 +105,12 @@ func (b *BundleManager) Load(path string) error {
 	}
 
 	for _, info := range infos {
-		glog.V(1).Infof("Found %s", info.Name)
+		zap.L().Debug("found object", zap.String("name", info.Name))
 		for k, v := range info.Object.(metav1.Object).GetAnnotations() {
-			glog.V(2).Infof("  %s=%s", k, v)
+			zap.L().Debug("annotation", zap.String("name", info.Name), zap.String("key", k), zap.String("value", v))
 		}
 		if info.Object.GetObjectKind().GroupVersionKind() != constraintTemplateGvk {
-			glog.V(2).Infof("skipping %s", info.Name)
+			zap.L().Debug("skipping object", zap.String("name", info.Name))
 			continue
 		}
 		b.add(info.Object.(Object))
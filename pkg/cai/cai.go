@@ -0,0 +1,249 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cai exports assets through the Cloud Asset API and streams them into review, so users
+// do not have to run exports and download the results themselves.
+package cai
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/forseti-security/config-validator/pkg/asset"
+	"github.com/forseti-security/config-validator/pkg/gcv"
+	"github.com/forseti-security/config-validator/pkg/logging"
+	"github.com/forseti-security/config-validator/pkg/multierror"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	cloudasset "google.golang.org/api/cloudasset/v1"
+	storage "google.golang.org/api/storage/v1"
+)
+
+// Content types that can be exported.
+const (
+	ContentTypeResource  = "RESOURCE"
+	ContentTypeIAMPolicy = "IAM_POLICY"
+	ContentTypeOrgPolicy = "ORG_POLICY"
+	// ContentTypeAccessPolicy exports Access Context Manager policies, access levels and service
+	// perimeters. It requires an organization scope.
+	ContentTypeAccessPolicy = "ACCESS_POLICY"
+)
+
+// defaultPollInterval is how often export operations are polled for completion.
+const defaultPollInterval = 5 * time.Second
+
+// ExportOptions describes an export of the current state of a scope.
+type ExportOptions struct {
+	// Parent is the scope to export, one of organizations/<number>, folders/<number> or
+	// projects/<id or number>.
+	Parent string
+	// AssetTypes limits the export to the given asset types, all types are exported if empty.
+	AssetTypes []string
+	// ContentTypes are exported one after the other, defaults to RESOURCE and IAM_POLICY. Org
+	// policies set with the v1 API are only exported with ORG_POLICY, policies managed through
+	// orgpolicy.googleapis.com are resources of type orgpolicy.googleapis.com/Policy.
+	ContentTypes []string
+	// OutputPrefix is the gs://bucket/path prefix export files are written to. One object per
+	// content type is written below it.
+	OutputPrefix string
+}
+
+// Exporter runs Cloud Asset exports and reads back their results.
+type Exporter struct {
+	assets  *cloudasset.Service
+	storage *storage.Service
+	// PollInterval is how often export operations are checked for completion.
+	PollInterval time.Duration
+}
+
+// NewExporter returns an Exporter calling the Cloud Asset API through assets and reading the
+// exported files through storage.
+func NewExporter(assets *cloudasset.Service, storage *storage.Service) *Exporter {
+	return &Exporter{assets: assets, storage: storage, PollInterval: defaultPollInterval}
+}
+
+// Export exports the scope described by opts and calls fn with every exported asset. Records that
+// fail to decode are skipped and reported in the returned error after all records were processed.
+// An error returned by fn stops the export.
+func (e *Exporter) Export(ctx context.Context, opts ExportOptions, fn func(*asset.Record) error) error {
+	bucket, prefix, contentTypes, err := checkOptions(opts)
+	if err != nil {
+		return err
+	}
+	var errs multierror.Errors
+	for _, contentType := range contentTypes {
+		object := path(prefix, strings.ToLower(contentType)+".json")
+		if err := e.export(ctx, opts, contentType, bucket, object); err != nil {
+			return err
+		}
+		if err := e.read(ctx, bucket, object, fn, &errs); err != nil {
+			return err
+		}
+	}
+	return errs.ToError()
+}
+
+// ExportFiles exports the scope described by opts without reading the exported files, and returns
+// their gs:// URIs, one per content type, for the files to be read later or by other processes.
+func (e *Exporter) ExportFiles(ctx context.Context, opts ExportOptions) ([]string, error) {
+	bucket, prefix, contentTypes, err := checkOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	var uris []string
+	for _, contentType := range contentTypes {
+		object := path(prefix, strings.ToLower(contentType)+".json")
+		if err := e.export(ctx, opts, contentType, bucket, object); err != nil {
+			return nil, err
+		}
+		uris = append(uris, fmt.Sprintf("gs://%s/%s", bucket, object))
+	}
+	return uris, nil
+}
+
+// checkOptions validates opts and returns the bucket and path of their output prefix and the
+// content types to export.
+func checkOptions(opts ExportOptions) (string, string, []string, error) {
+	if err := validateParent(opts.Parent); err != nil {
+		return "", "", nil, err
+	}
+	bucket, prefix, err := parseGCSPath(opts.OutputPrefix)
+	if err != nil {
+		return "", "", nil, err
+	}
+	contentTypes := opts.ContentTypes
+	if len(contentTypes) == 0 {
+		contentTypes = []string{ContentTypeResource, ContentTypeIAMPolicy}
+	}
+	return bucket, prefix, contentTypes, nil
+}
+
+// export exports the assets of contentType in the scope described by opts to object in bucket and
+// waits for the export to complete.
+func (e *Exporter) export(ctx context.Context, opts ExportOptions, contentType, bucket, object string) error {
+	request := &cloudasset.ExportAssetsRequest{
+		AssetTypes:  opts.AssetTypes,
+		ContentType: contentType,
+		OutputConfig: &cloudasset.OutputConfig{
+			GcsDestination: &cloudasset.GcsDestination{Uri: fmt.Sprintf("gs://%s/%s", bucket, object)},
+		},
+	}
+	op, err := e.assets.V1.ExportAssets(opts.Parent, request).Context(ctx).Do()
+	if err != nil {
+		return errors.Wrapf(err, "failed to export %s of %s", contentType, opts.Parent)
+	}
+	if err := e.wait(ctx, op); err != nil {
+		return errors.Wrapf(err, "failed to export %s of %s", contentType, opts.Parent)
+	}
+	logging.FromContext(ctx).Info("exported assets", zap.String("content_type", contentType), zap.String("parent", opts.Parent),
+		zap.String("uri", fmt.Sprintf("gs://%s/%s", bucket, object)))
+	return nil
+}
+
+// Review exports the scope described by opts and reviews every exported asset with v.
+func (e *Exporter) Review(ctx context.Context, v *gcv.Validator, opts ExportOptions) ([]*gcv.Result, error) {
+	var results []*gcv.Result
+	err := e.Export(ctx, opts, func(record *asset.Record) error {
+		result, err := v.ReviewRecord(ctx, record)
+		if err != nil {
+			return errors.Wrapf(err, "failed to review %s", record.Source)
+		}
+		results = append(results, result)
+		return nil
+	})
+	return results, err
+}
+
+// wait polls op until it completes.
+func (e *Exporter) wait(ctx context.Context, op *cloudasset.Operation) error {
+	name := op.Name
+	for !op.Done {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(e.PollInterval):
+		}
+		var err error
+		op, err = e.assets.Operations.Get(name).Context(ctx).Do()
+		if err != nil {
+			return errors.Wrapf(err, "failed to get operation %s", name)
+		}
+	}
+	if op.Error != nil {
+		return errors.Errorf("operation %s failed with code %d: %s", name, op.Error.Code, op.Error.Message)
+	}
+	return nil
+}
+
+// read streams the records of an export file to fn.
+func (e *Exporter) read(
+	ctx context.Context, bucket, object string, fn func(*asset.Record) error, errs *multierror.Errors) error {
+	resp, err := e.storage.Objects.Get(bucket, object).Context(ctx).Download()
+	if err != nil {
+		return errors.Wrapf(err, "failed to download gs://%s/%s", bucket, object)
+	}
+	defer resp.Body.Close()
+
+	reader := asset.NewReader(resp.Body, fmt.Sprintf("gs://%s/%s", bucket, object))
+	for {
+		record, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if decodeErr, ok := err.(*asset.DecodeError); ok {
+			errs.Add(decodeErr)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+}
+
+func validateParent(parent string) error {
+	for _, prefix := range []string{"organizations/", "folders/", "projects/"} {
+		if strings.HasPrefix(parent, prefix) && len(parent) > len(prefix) {
+			return nil
+		}
+	}
+	return errors.Errorf("invalid export scope %q, expected organizations/, folders/ or projects/", parent)
+}
+
+// parseGCSPath splits a gs://bucket/path URI into its bucket and path.
+func parseGCSPath(uri string) (string, string, error) {
+	if !strings.HasPrefix(uri, "gs://") {
+		return "", "", errors.Errorf("invalid output prefix %q, expected gs://bucket/path", uri)
+	}
+	parts := strings.SplitN(strings.TrimPrefix(uri, "gs://"), "/", 2)
+	if parts[0] == "" {
+		return "", "", errors.Errorf("invalid output prefix %q, missing bucket", uri)
+	}
+	if len(parts) == 1 {
+		return parts[0], "", nil
+	}
+	return parts[0], strings.Trim(parts[1], "/"), nil
+}
+
+func path(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "/" + name
+}
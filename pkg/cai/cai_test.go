@@ -0,0 +1,256 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/forseti-security/config-validator/pkg/asset"
+	"github.com/forseti-security/config-validator/pkg/gcv"
+	"github.com/google/go-cmp/cmp"
+	cloudasset "google.golang.org/api/cloudasset/v1"
+	storage "google.golang.org/api/storage/v1"
+)
+
+const (
+	testPolicyDir  = "../../test/cf"
+	testLibraryDir = "../../test/cf/library"
+)
+
+const (
+	bucketJSON    = `{"name":"//storage.googleapis.com/my-storage-bucket","asset_type":"storage.googleapis.com/Bucket","ancestors":["projects/3","organizations/1"],"resource":{"version":"v1","discovery_document_uri":"https://www.googleapis.com/discovery/v1/apis/storage/v1/rest","discovery_name":"Bucket","parent":"//cloudresourcemanager.googleapis.com/projects/68478495408","data":{"acl":[],"billing":{},"cors":[],"defaultObjectAcl":[],"encryption":{},"etag":"CAI=","iamConfiguration":{"bucketPolicyOnly":{}},"id":"my-storage-bucket","labels":{},"lifecycle":{"rule":[]},"location":"US-CENTRAL1","logging":{},"metageneration":2,"name":"my-storage-bucket","owner":{},"projectNumber":68478495408,"retentionPolicy":{},"selfLink":"https://www.googleapis.com/storage/v1/b/my-storage-bucket","storageClass":"STANDARD","timeCreated":"2018-07-23T17:30:22.691Z","updated":"2018-07-23T17:30:23.324Z","versioning":{},"website":{}}}}`
+	iamPolicyJSON = `{"name":"//storage.googleapis.com/my-storage-bucket","asset_type":"storage.googleapis.com/Bucket","ancestors":["projects/3","organizations/1"],"iam_policy":{"etag":"BwVu0thcxBs=","bindings":[{"role":"roles/storage.objectViewer","members":["allUsers"]}]}}`
+)
+
+// fakeAPI serves the subset of the Cloud Asset and Cloud Storage APIs used by the Exporter.
+// Export operations complete on the first poll.
+type fakeAPI struct {
+	mu       sync.Mutex
+	objects  map[string]string
+	exports  []*cloudasset.ExportAssetsRequest
+	polls    int
+	failWith *cloudasset.Status
+}
+
+func (f *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/v1/projects/p:exportAssets":
+		request := &cloudasset.ExportAssetsRequest{}
+		_ = json.NewDecoder(r.Body).Decode(request)
+		f.exports = append(f.exports, request)
+		_ = json.NewEncoder(w).Encode(&cloudasset.Operation{Name: "projects/p/operations/export"})
+	case r.Method == http.MethodGet && r.URL.Path == "/v1/projects/p/operations/export":
+		f.polls++
+		_ = json.NewEncoder(w).Encode(&cloudasset.Operation{
+			Name:  "projects/p/operations/export",
+			Done:  true,
+			Error: f.failWith,
+		})
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/b/bucket/o/") && r.URL.Query().Get("alt") == "media":
+		content, found := f.objects[strings.TrimPrefix(r.URL.Path, "/b/bucket/o/")]
+		if !found {
+			http.Error(w, `{"error": {"code": 404, "message": "not found"}}`, http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(content))
+	default:
+		http.Error(w, "unexpected request "+r.Method+" "+r.URL.String(), http.StatusBadRequest)
+	}
+}
+
+func newTestExporter(t *testing.T, fake *fakeAPI) (*Exporter, func()) {
+	server := httptest.NewServer(fake)
+	assets, err := cloudasset.New(server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assets.BasePath = server.URL + "/"
+	store, err := storage.New(server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.BasePath = server.URL + "/"
+	exporter := NewExporter(assets, store)
+	exporter.PollInterval = time.Millisecond
+	return exporter, server.Close
+}
+
+func TestExport(t *testing.T) {
+	fake := &fakeAPI{
+		objects: map[string]string{
+			"exports/resource.json":   bucketJSON + "\n",
+			"exports/iam_policy.json": iamPolicyJSON + "\n{bad json\n",
+		},
+	}
+	exporter, cleanup := newTestExporter(t, fake)
+	defer cleanup()
+
+	var sources []string
+	err := exporter.Export(context.Background(), ExportOptions{
+		Parent:       "projects/p",
+		AssetTypes:   []string{"storage.googleapis.com/Bucket"},
+		OutputPrefix: "gs://bucket/exports/",
+	}, func(record *asset.Record) error {
+		sources = append(sources, record.Source.String())
+		return nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "gs://bucket/exports/iam_policy.json:2") {
+		t.Errorf("got error %v, want decode error for line 2 of the IAM policy export", err)
+	}
+
+	wantSources := []string{"gs://bucket/exports/resource.json:1", "gs://bucket/exports/iam_policy.json:1"}
+	if diff := cmp.Diff(wantSources, sources); diff != "" {
+		t.Errorf("unexpected sources (-want +got):\n%s", diff)
+	}
+	if len(fake.exports) != 2 {
+		t.Fatalf("got %d export requests, want 2", len(fake.exports))
+	}
+	for idx, contentType := range []string{ContentTypeResource, ContentTypeIAMPolicy} {
+		got := fake.exports[idx]
+		if got.ContentType != contentType {
+			t.Errorf("export %d: got content type %s, want %s", idx, got.ContentType, contentType)
+		}
+		wantURI := "gs://bucket/exports/" + strings.ToLower(contentType) + ".json"
+		if got.OutputConfig.GcsDestination.Uri != wantURI {
+			t.Errorf("export %d: got destination %s, want %s", idx, got.OutputConfig.GcsDestination.Uri, wantURI)
+		}
+		if diff := cmp.Diff([]string{"storage.googleapis.com/Bucket"}, got.AssetTypes); diff != "" {
+			t.Errorf("export %d: unexpected asset types (-want +got):\n%s", idx, diff)
+		}
+	}
+}
+
+func TestExportFiles(t *testing.T) {
+	fake := &fakeAPI{}
+	exporter, cleanup := newTestExporter(t, fake)
+	defer cleanup()
+
+	uris, err := exporter.ExportFiles(context.Background(), ExportOptions{
+		Parent:       "projects/p",
+		ContentTypes: []string{ContentTypeResource, ContentTypeOrgPolicy},
+		OutputPrefix: "gs://bucket/exports",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// The exported files are not read.
+	want := []string{"gs://bucket/exports/resource.json", "gs://bucket/exports/org_policy.json"}
+	if diff := cmp.Diff(want, uris); diff != "" {
+		t.Errorf("unexpected URIs (-want +got):\n%s", diff)
+	}
+	if len(fake.exports) != 2 {
+		t.Errorf("got %d export requests, want 2", len(fake.exports))
+	}
+}
+
+func TestExportOperationFailure(t *testing.T) {
+	fake := &fakeAPI{failWith: &cloudasset.Status{Code: 7, Message: "permission denied"}}
+	exporter, cleanup := newTestExporter(t, fake)
+	defer cleanup()
+
+	err := exporter.Export(context.Background(), ExportOptions{
+		Parent:       "projects/p",
+		OutputPrefix: "gs://bucket",
+	}, func(*asset.Record) error {
+		t.Errorf("unexpected record")
+		return nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "permission denied") {
+		t.Errorf("got error %v, want operation failure", err)
+	}
+	if fake.polls != 1 {
+		t.Errorf("got %d polls, want 1", fake.polls)
+	}
+}
+
+func TestExportOptionsErrors(t *testing.T) {
+	var testCases = []struct {
+		name string
+		opts ExportOptions
+	}{
+		{
+			name: "missing scope",
+			opts: ExportOptions{OutputPrefix: "gs://bucket"},
+		},
+		{
+			name: "invalid scope",
+			opts: ExportOptions{Parent: "billingAccounts/1", OutputPrefix: "gs://bucket"},
+		},
+		{
+			name: "invalid output prefix",
+			opts: ExportOptions{Parent: "projects/p", OutputPrefix: "/tmp/exports"},
+		},
+		{
+			name: "missing bucket",
+			opts: ExportOptions{Parent: "projects/p", OutputPrefix: "gs:///exports"},
+		},
+	}
+	exporter := NewExporter(nil, nil)
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := exporter.Export(context.Background(), tc.opts, func(*asset.Record) error { return nil })
+			if err == nil {
+				t.Errorf("expected error")
+			}
+		})
+	}
+}
+
+func TestReview(t *testing.T) {
+	fake := &fakeAPI{
+		objects: map[string]string{
+			"resource.json":   bucketJSON + "\n",
+			"iam_policy.json": iamPolicyJSON + "\n",
+		},
+	}
+	exporter, cleanup := newTestExporter(t, fake)
+	defer cleanup()
+	v, err := gcv.NewValidator(gcv.WithPolicyPaths(testPolicyDir), gcv.WithPolicyLibrary(testLibraryDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := exporter.Review(context.Background(), v, ExportOptions{
+		Parent:       "projects/p",
+		OutputPrefix: "gs://bucket",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want 2", len(results))
+	}
+	for _, result := range results {
+		if result.Source == nil || !strings.HasPrefix(result.Source.File, "gs://bucket/") {
+			t.Errorf("got source %v, want export file", result.Source)
+		}
+	}
+	// The test policies only cover the bucket resource, not its IAM policy.
+	if len(results[0].ConstraintViolations) == 0 {
+		t.Errorf("expected violations for the bucket without logging")
+	}
+	if len(results[1].ConstraintViolations) != 0 {
+		t.Errorf("got %d violations for the IAM policy, want 0", len(results[1].ConstraintViolations))
+	}
+}
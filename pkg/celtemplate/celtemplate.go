@@ -0,0 +1,256 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package celtemplate supports constraint templates whose logic is written in CEL rather than
+// Rego. A template lists validations in place of rego:
+//
+//   targets:
+//     validation.gcp.forsetisecurity.org:
+//       cel:
+//         assetTypes: [storage.googleapis.com/Bucket]
+//         validations:
+//         - expression: has(asset.resource.data.logging)
+//           message: bucket logging must be enabled
+//         - expression: asset.resource.data.location in params.locations
+//           messageExpression: "'location ' + asset.resource.data.location + ' is not allowed'"
+//
+// Validations only apply to assets of the listed assetTypes, or to all assets if none are
+// listed. Each expression sees the asset under review as "asset" and the constraint parameters as
+// "params", and must evaluate to true for the asset to be valid. A validation that evaluates
+// to false is a violation with its message, the result of its messageExpression if set, or the
+// failed expression otherwise.
+//
+// CEL templates are converted to Rego calling the cel_validate function, so they are matched,
+// reviewed and reported like any other template.
+package celtemplate
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/forseti-security/config-validator/pkg/internal/celjson"
+	"github.com/forseti-security/config-validator/pkg/multierror"
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/checker/decls"
+	celtypes "github.com/google/cel-go/common/types"
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/topdown"
+	"github.com/open-policy-agent/opa/types"
+	"github.com/pkg/errors"
+)
+
+// BuiltinName is the name of the Rego function evaluating validations.
+const BuiltinName = "cel_validate"
+
+const (
+	assetVar  = "asset"
+	paramsVar = "params"
+)
+
+// Config is the cel section of a template target.
+type Config struct {
+	// AssetTypes limits the validations to assets of these types.
+	AssetTypes []string `json:"assetTypes,omitempty"`
+	// Validations are the expressions assets must satisfy.
+	Validations []Validation `json:"validations"`
+}
+
+// Validation is a CEL expression an asset must satisfy.
+type Validation struct {
+	// Expression must evaluate to true for valid assets.
+	Expression string `json:"expression"`
+	// Message is reported when Expression evaluates to false.
+	Message string `json:"message,omitempty"`
+	// MessageExpression evaluates to the reported message, overriding Message.
+	MessageExpression string `json:"messageExpression,omitempty"`
+}
+
+// regoTemplate evaluates the validations embedded as JSON in the template.
+const regoTemplate = `package templates.gcp.%s
+
+deny[{
+	"msg": failure.msg,
+	"details": failure.details,
+}] {
+	%s
+	params := object.get(input.constraint.spec, "parameters", {})
+	failure := cel_validate(%s, input.asset, params)[_]
+}
+`
+
+// Rego returns the Rego of a template of kind evaluating the validations of config. All invalid
+// expressions are reported together.
+func Rego(kind string, config Config) (string, error) {
+	if len(config.Validations) == 0 {
+		return "", errors.Errorf("no CEL validations specified in template")
+	}
+	var errs multierror.Errors
+	for idx, validation := range config.Validations {
+		if _, err := compile(validation.Expression); err != nil {
+			errs.Add(errors.Wrapf(err, "validation %d expression", idx))
+		}
+		if validation.MessageExpression != "" {
+			if _, err := compile(validation.MessageExpression); err != nil {
+				errs.Add(errors.Wrapf(err, "validation %d messageExpression", idx))
+			}
+		}
+	}
+	if !errs.Empty() {
+		return "", errs.ToError()
+	}
+	match := "true"
+	if len(config.AssetTypes) != 0 {
+		assetTypes, err := json.Marshal(config.AssetTypes)
+		if err != nil {
+			return "", err
+		}
+		match = fmt.Sprintf("input.asset.asset_type == %s[_]", assetTypes)
+	}
+	validations, err := json.Marshal(config.Validations)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(regoTemplate, kind, match, validations), nil
+}
+
+var (
+	envOnce sync.Once
+	env     *cel.Env
+	envErr  error
+
+	// programs caches compiled expressions by source.
+	programs sync.Map
+)
+
+// compile returns the program of expr, compiling it on first use.
+func compile(expr string) (cel.Program, error) {
+	if program, ok := programs.Load(expr); ok {
+		return program.(cel.Program), nil
+	}
+	envOnce.Do(func() {
+		env, envErr = cel.NewEnv(cel.Declarations(
+			decls.NewIdent(assetVar, decls.Dyn, nil),
+			decls.NewIdent(paramsVar, decls.Dyn, nil),
+		))
+	})
+	if envErr != nil {
+		return nil, errors.Wrapf(envErr, "failed to create CEL environment")
+	}
+	checked, issues := env.Compile(expr)
+	if issues != nil && issues.Err() != nil {
+		return nil, errors.Errorf("%q: %s", expr, issues.Err())
+	}
+	program, err := env.Program(checked)
+	if err != nil {
+		return nil, errors.Wrapf(err, "%q", expr)
+	}
+	programs.Store(expr, program)
+	return program, nil
+}
+
+func init() {
+	ast.RegisterBuiltin(&ast.Builtin{
+		Name: BuiltinName,
+		Decl: types.NewFunction(
+			types.Args(types.NewArray(nil, types.A), types.A, types.A),
+			types.NewArray(nil, types.A),
+		),
+	})
+	topdown.RegisterBuiltinFunc(BuiltinName, builtinValidate)
+}
+
+func builtinValidate(bctx topdown.BuiltinContext, args []*ast.Term, iter func(*ast.Term) error) error {
+	var validations []Validation
+	if err := ast.As(args[0].Value, &validations); err != nil {
+		return errors.Wrapf(err, "invalid validations")
+	}
+	asset, err := ast.JSON(args[1].Value)
+	if err != nil {
+		return err
+	}
+	params, err := ast.JSON(args[2].Value)
+	if err != nil {
+		return err
+	}
+
+	failures, err := Validate(validations, asset, params)
+	if err != nil {
+		return err
+	}
+	value, err := ast.InterfaceToValue(failures)
+	if err != nil {
+		return err
+	}
+	return iter(ast.NewTerm(value))
+}
+
+// Validate evaluates validations against asset and params, returning a {"msg", "details"}
+// object for each validation the asset fails. The details hold the failed expression and the
+// name of the asset.
+func Validate(validations []Validation, asset, params interface{}) ([]interface{}, error) {
+	input := map[string]interface{}{assetVar: celjson.Value(asset), paramsVar: celjson.Value(params)}
+	failures := []interface{}{}
+	for _, validation := range validations {
+		program, err := compile(validation.Expression)
+		if err != nil {
+			return nil, err
+		}
+		out, _, err := program.Eval(input)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to evaluate %q", validation.Expression)
+		}
+		valid, ok := out.(celtypes.Bool)
+		if !ok {
+			return nil, errors.Errorf("%q evaluated to %v, want a bool", validation.Expression, out)
+		}
+		if valid {
+			continue
+		}
+
+		msg, err := message(validation, input)
+		if err != nil {
+			return nil, err
+		}
+		details := map[string]interface{}{"expression": validation.Expression}
+		if obj, ok := asset.(map[string]interface{}); ok && obj["name"] != nil {
+			details["resource"] = obj["name"]
+		}
+		failures = append(failures, map[string]interface{}{"msg": msg, "details": details})
+	}
+	return failures, nil
+}
+
+// message returns the message reported for a failed validation.
+func message(validation Validation, input map[string]interface{}) (string, error) {
+	if validation.MessageExpression == "" {
+		if validation.Message != "" {
+			return validation.Message, nil
+		}
+		return fmt.Sprintf("failed expression: %s", validation.Expression), nil
+	}
+	program, err := compile(validation.MessageExpression)
+	if err != nil {
+		return "", err
+	}
+	out, _, err := program.Eval(input)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to evaluate %q", validation.MessageExpression)
+	}
+	msg, ok := out.(celtypes.String)
+	if !ok {
+		return "", errors.Errorf("%q evaluated to %v, want a string", validation.MessageExpression, out)
+	}
+	return string(msg), nil
+}
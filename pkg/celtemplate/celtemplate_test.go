@@ -0,0 +1,171 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package celtemplate
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/open-policy-agent/opa/rego"
+)
+
+var testBucket = map[string]interface{}{
+	"name":       "//storage.googleapis.com/b",
+	"asset_type": "storage.googleapis.com/Bucket",
+	"resource": map[string]interface{}{
+		"data": map[string]interface{}{
+			"location": "EU",
+			"size":     json.Number("3"),
+		},
+	},
+}
+
+func TestValidate(t *testing.T) {
+	var testCases = []struct {
+		name        string
+		validations []Validation
+		params      interface{}
+		want        []interface{}
+	}{
+		{
+			name:        "valid",
+			validations: []Validation{{Expression: "asset.resource.data.location in params.locations"}},
+			params:      map[string]interface{}{"locations": []interface{}{"EU", "US"}},
+			want:        []interface{}{},
+		},
+		{
+			name:        "default message",
+			validations: []Validation{{Expression: "asset.resource.data.size < 2"}},
+			want: []interface{}{map[string]interface{}{
+				"msg":     "failed expression: asset.resource.data.size < 2",
+				"details": map[string]interface{}{"expression": "asset.resource.data.size < 2", "resource": "//storage.googleapis.com/b"},
+			}},
+		},
+		{
+			name: "messages",
+			validations: []Validation{
+				{Expression: "false", Message: "static"},
+				{Expression: "false", Message: "ignored", MessageExpression: "asset.name + ' is in ' + asset.resource.data.location"},
+			},
+			want: []interface{}{
+				map[string]interface{}{
+					"msg":     "static",
+					"details": map[string]interface{}{"expression": "false", "resource": "//storage.googleapis.com/b"},
+				},
+				map[string]interface{}{
+					"msg":     "//storage.googleapis.com/b is in EU",
+					"details": map[string]interface{}{"expression": "false", "resource": "//storage.googleapis.com/b"},
+				},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Validate(tc.validations, testBucket, tc.params)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected failures (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestValidateErrors(t *testing.T) {
+	for _, validation := range []Validation{
+		{Expression: "asset.resource.data.location"},
+		{Expression: "asset.missing == 1"},
+		{Expression: "false", MessageExpression: "1"},
+	} {
+		if _, err := Validate([]Validation{validation}, testBucket, map[string]interface{}{}); err == nil {
+			t.Errorf("%+v: expected error", validation)
+		}
+	}
+}
+
+func TestRego(t *testing.T) {
+	src, err := Rego("TestConstraint", Config{
+		AssetTypes:  []string{"storage.googleapis.com/Bucket"},
+		Validations: []Validation{{Expression: "asset.resource.data.location == params.location", Message: "wrong location"}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var testCases = []struct {
+		name  string
+		input map[string]interface{}
+		want  []interface{}
+	}{
+		{
+			name: "violation",
+			input: map[string]interface{}{
+				"asset":      testBucket,
+				"constraint": map[string]interface{}{"spec": map[string]interface{}{"parameters": map[string]interface{}{"location": "US"}}},
+			},
+			want: []interface{}{"wrong location"},
+		},
+		{
+			name: "no violation",
+			input: map[string]interface{}{
+				"asset":      testBucket,
+				"constraint": map[string]interface{}{"spec": map[string]interface{}{"parameters": map[string]interface{}{"location": "EU"}}},
+			},
+		},
+		{
+			name: "other asset type",
+			input: map[string]interface{}{
+				"asset":      map[string]interface{}{"name": "//bigquery.googleapis.com/d", "asset_type": "bigquery.googleapis.com/Dataset"},
+				"constraint": map[string]interface{}{"spec": map[string]interface{}{}},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rs, err := rego.New(
+				rego.Module("template.rego", src),
+				rego.Query("[msg | data.templates.gcp.TestConstraint.deny[_].msg = msg]"),
+				rego.Input(tc.input),
+			).Eval(context.Background())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got := rs[0].Expressions[0].Value.([]interface{})
+			if len(tc.want) == 0 && len(got) == 0 {
+				return
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected messages (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRegoInvalid(t *testing.T) {
+	_, err := Rego("TestConstraint", Config{Validations: []Validation{
+		{Expression: "asset.name =="},
+		{Expression: "true", MessageExpression: "'unterminated"},
+	}})
+	if err == nil || !strings.Contains(err.Error(), "validation 0 expression") || !strings.Contains(err.Error(), "validation 1 messageExpression") {
+		t.Errorf("got error %v, want both invalid expressions", err)
+	}
+	if _, err := Rego("TestConstraint", Config{}); err == nil {
+		t.Errorf("expected error without validations")
+	}
+}
@@ -0,0 +1,42 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cis holds a minimal set of policies checking GCP resources against the CIS Google Cloud
+// Platform Foundation Benchmark v1.1.0, built into the validator so that a meaningful audit can
+// be run without a policy library. The templates are self-contained and use no Rego library.
+package cis
+
+// Name is the name of the policy set, loaded from the policy path builtin:cis.
+const Name = "cis"
+
+// files maps the paths of the policy files of the set to their content.
+var files = map[string]string{
+	"templates/cis_gcp_firewall_open_port.yaml":    firewallOpenPortTemplate,
+	"templates/cis_gcp_public_iam.yaml":            publicIAMTemplate,
+	"templates/cis_gcp_bucket_uniform_access.yaml": bucketUniformAccessTemplate,
+	"templates/cis_gcp_sql_public_network.yaml":    sqlPublicNetworkTemplate,
+	"templates/cis_gcp_sql_require_ssl.yaml":       sqlRequireSSLTemplate,
+	"templates/cis_gcp_default_network.yaml":       defaultNetworkTemplate,
+	"constraints/cis_gcp.yaml":                     constraints,
+}
+
+// Files returns the content of the policy files of the set by their path relative to the root of
+// the set.
+func Files() map[string]string {
+	copied := make(map[string]string, len(files))
+	for path, content := range files {
+		copied[path] = content
+	}
+	return copied
+}
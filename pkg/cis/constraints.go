@@ -0,0 +1,125 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cis
+
+// constraints are the constraints of the set, annotated with the CIS recommendation they check.
+const constraints = `apiVersion: constraints.gatekeeper.sh/v1beta1
+kind: CISGCPDefaultNetworkConstraint
+metadata:
+  name: cis-3-1-no-default-network
+  annotations:
+    benchmark: CIS11_3.01
+    bundles.validator.forsetisecurity.org/cis-v1.1: "3.01"
+    validation.gcp.forsetisecurity.org/remediation: Delete the default network and create custom mode networks with the firewall rules you need.
+    validation.gcp.forsetisecurity.org/remediationURL: https://cloud.google.com/vpc/docs/vpc#default-network
+spec:
+  severity: medium
+  parameters: {}
+---
+apiVersion: constraints.gatekeeper.sh/v1beta1
+kind: CISGCPFirewallOpenPortConstraint
+metadata:
+  name: cis-3-6-no-ssh-from-internet
+  annotations:
+    benchmark: CIS11_3.06
+    bundles.validator.forsetisecurity.org/cis-v1.1: "3.06"
+    validation.gcp.forsetisecurity.org/remediation: Restrict the source ranges of the firewall rule, or reach instances through IAP TCP forwarding.
+    validation.gcp.forsetisecurity.org/remediationURL: https://cloud.google.com/iap/docs/using-tcp-forwarding
+spec:
+  severity: high
+  parameters:
+    ports: ["22"]
+---
+apiVersion: constraints.gatekeeper.sh/v1beta1
+kind: CISGCPFirewallOpenPortConstraint
+metadata:
+  name: cis-3-7-no-rdp-from-internet
+  annotations:
+    benchmark: CIS11_3.07
+    bundles.validator.forsetisecurity.org/cis-v1.1: "3.07"
+    validation.gcp.forsetisecurity.org/remediation: Restrict the source ranges of the firewall rule, or reach instances through IAP TCP forwarding.
+    validation.gcp.forsetisecurity.org/remediationURL: https://cloud.google.com/iap/docs/using-tcp-forwarding
+spec:
+  severity: high
+  parameters:
+    ports: ["3389"]
+---
+apiVersion: constraints.gatekeeper.sh/v1beta1
+kind: CISGCPPublicIAMConstraint
+metadata:
+  name: cis-5-1-no-public-buckets
+  annotations:
+    benchmark: CIS11_5.01
+    bundles.validator.forsetisecurity.org/cis-v1.1: "5.01"
+    validation.gcp.forsetisecurity.org/remediation: Remove the allUsers and allAuthenticatedUsers members from the IAM policy of the bucket.
+    validation.gcp.forsetisecurity.org/remediationURL: https://cloud.google.com/storage/docs/using-public-access-prevention
+spec:
+  severity: high
+  parameters:
+    asset_types: ["storage.googleapis.com/Bucket"]
+---
+apiVersion: constraints.gatekeeper.sh/v1beta1
+kind: CISGCPBucketUniformAccessConstraint
+metadata:
+  name: cis-5-2-bucket-uniform-access
+  annotations:
+    benchmark: CIS11_5.02
+    bundles.validator.forsetisecurity.org/cis-v1.1: "5.02"
+    validation.gcp.forsetisecurity.org/remediation: Enable uniform bucket-level access on the bucket.
+    validation.gcp.forsetisecurity.org/remediationURL: https://cloud.google.com/storage/docs/using-uniform-bucket-level-access
+spec:
+  severity: medium
+  parameters: {}
+---
+apiVersion: constraints.gatekeeper.sh/v1beta1
+kind: CISGCPSQLRequireSSLConstraint
+metadata:
+  name: cis-6-4-sql-require-ssl
+  annotations:
+    benchmark: CIS11_6.04
+    bundles.validator.forsetisecurity.org/cis-v1.1: "6.04"
+    validation.gcp.forsetisecurity.org/remediation: Require SSL connections in the connections settings of the instance.
+    validation.gcp.forsetisecurity.org/remediationURL: https://cloud.google.com/sql/docs/mysql/configure-ssl-instance
+spec:
+  severity: medium
+  parameters: {}
+---
+apiVersion: constraints.gatekeeper.sh/v1beta1
+kind: CISGCPSQLPublicNetworkConstraint
+metadata:
+  name: cis-6-5-no-sql-public-network
+  annotations:
+    benchmark: CIS11_6.05
+    bundles.validator.forsetisecurity.org/cis-v1.1: "6.05"
+    validation.gcp.forsetisecurity.org/remediation: Remove 0.0.0.0/0 from the authorized networks of the instance.
+    validation.gcp.forsetisecurity.org/remediationURL: https://cloud.google.com/sql/docs/mysql/authorize-networks
+spec:
+  severity: high
+  parameters: {}
+---
+apiVersion: constraints.gatekeeper.sh/v1beta1
+kind: CISGCPPublicIAMConstraint
+metadata:
+  name: cis-7-1-no-public-datasets
+  annotations:
+    benchmark: CIS11_7.01
+    bundles.validator.forsetisecurity.org/cis-v1.1: "7.01"
+    validation.gcp.forsetisecurity.org/remediation: Remove the allUsers and allAuthenticatedUsers members from the IAM policy of the dataset.
+    validation.gcp.forsetisecurity.org/remediationURL: https://cloud.google.com/bigquery/docs/control-access-to-resources-iam
+spec:
+  severity: high
+  parameters:
+    asset_types: ["bigquery.googleapis.com/Dataset"]
+`
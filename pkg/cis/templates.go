@@ -0,0 +1,305 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cis
+
+// regoUtil holds the helpers shared by the templates, appended to the Rego of each template.
+const regoUtil = `
+      # has_field returns whether an object has a field.
+      has_field(object, field) {
+      	object[field]
+      }
+
+      # False is a tricky special case, as false responses would create an undefined document
+      # unless they are explicitly tested for.
+      has_field(object, field) {
+      	object[field] == false
+      }
+
+      has_field(object, field) = false {
+      	not object[field]
+      	not object[field] == false
+      }
+
+      # get_default returns the value of an object's field or the provided default value.
+      get_default(object, field, _default) = output {
+      	has_field(object, field)
+      	output = object[field]
+      }
+
+      get_default(object, field, _default) = output {
+      	has_field(object, field) == false
+      	output = _default
+      }
+`
+
+const firewallOpenPortTemplate = `apiVersion: templates.gatekeeper.sh/v1beta1
+kind: ConstraintTemplate
+metadata:
+  name: cisgcpfirewallopenportconstraint
+spec:
+  crd:
+    spec:
+      names:
+        kind: CISGCPFirewallOpenPortConstraint
+      validation:
+        openAPIV3Schema:
+          properties:
+            ports:
+              type: array
+              description: The TCP ports that must not be open to the internet.
+              items:
+                type: string
+  targets:
+  - target: validation.gcp.forsetisecurity.org
+    rego: |
+      package templates.gcp.CISGCPFirewallOpenPortConstraint
+
+      violation[{
+      	"msg": message,
+      	"details": metadata,
+      }] {
+      	asset := input.review
+      	asset.asset_type == "compute.googleapis.com/Firewall"
+
+      	rule := asset.resource.data
+      	get_default(rule, "direction", "INGRESS") == "INGRESS"
+      	get_default(rule, "disabled", false) == false
+      	get_default(rule, "sourceRanges", [])[_] == "0.0.0.0/0"
+
+      	allowed := get_default(rule, "allowed", [])[_]
+      	tcp_protocol(allowed.IPProtocol)
+      	port := input.parameters.ports[_]
+      	port_allowed(allowed, port)
+
+      	message := sprintf("%v allows ingress from 0.0.0.0/0 to TCP port %v.", [asset.name, port])
+      	metadata := {
+      		"resource": asset.name,
+      		"port": port,
+      	}
+      }
+
+      tcp_protocol("tcp")
+
+      tcp_protocol("all")
+
+      # Rules allowing a protocol without ports allow all its ports.
+      port_allowed(allowed, port) {
+      	has_field(allowed, "ports") == false
+      }
+
+      port_allowed(allowed, port) {
+      	allowed.ports[_] == port
+      }
+
+      port_allowed(allowed, port) {
+      	bounds := split(allowed.ports[_], "-")
+      	count(bounds) == 2
+      	to_number(bounds[0]) <= to_number(port)
+      	to_number(port) <= to_number(bounds[1])
+      }
+` + regoUtil
+
+const publicIAMTemplate = `apiVersion: templates.gatekeeper.sh/v1beta1
+kind: ConstraintTemplate
+metadata:
+  name: cisgcppubliciamconstraint
+spec:
+  crd:
+    spec:
+      names:
+        kind: CISGCPPublicIAMConstraint
+      validation:
+        openAPIV3Schema:
+          properties:
+            asset_types:
+              type: array
+              description: The asset types whose IAM policies must not grant roles to allUsers or allAuthenticatedUsers.
+              items:
+                type: string
+  targets:
+  - target: validation.gcp.forsetisecurity.org
+    rego: |
+      package templates.gcp.CISGCPPublicIAMConstraint
+
+      violation[{
+      	"msg": message,
+      	"details": metadata,
+      }] {
+      	asset := input.review
+      	asset.asset_type == input.parameters.asset_types[_]
+
+      	binding := asset.iam_policy.bindings[_]
+      	member := binding.members[_]
+      	public_member(member)
+
+      	message := sprintf("%v grants %v to %v.", [asset.name, binding.role, member])
+      	metadata := {
+      		"resource": asset.name,
+      		"role": binding.role,
+      		"member": member,
+      	}
+      }
+
+      public_member("allUsers")
+
+      public_member("allAuthenticatedUsers")
+`
+
+const bucketUniformAccessTemplate = `apiVersion: templates.gatekeeper.sh/v1beta1
+kind: ConstraintTemplate
+metadata:
+  name: cisgcpbucketuniformaccessconstraint
+spec:
+  crd:
+    spec:
+      names:
+        kind: CISGCPBucketUniformAccessConstraint
+      validation:
+        openAPIV3Schema:
+          properties: {}
+  targets:
+  - target: validation.gcp.forsetisecurity.org
+    rego: |
+      package templates.gcp.CISGCPBucketUniformAccessConstraint
+
+      violation[{
+      	"msg": message,
+      	"details": metadata,
+      }] {
+      	asset := input.review
+      	asset.asset_type == "storage.googleapis.com/Bucket"
+
+      	bucket := asset.resource.data
+      	not uniform_access(bucket)
+
+      	message := sprintf("%v does not have uniform bucket-level access enabled.", [asset.name])
+      	metadata := {"resource": asset.name}
+      }
+
+      uniform_access(bucket) {
+      	bucket.iamConfiguration.uniformBucketLevelAccess.enabled == true
+      }
+
+      # Uniform bucket-level access was called bucket policy only before.
+      uniform_access(bucket) {
+      	bucket.iamConfiguration.bucketPolicyOnly.enabled == true
+      }
+`
+
+const sqlPublicNetworkTemplate = `apiVersion: templates.gatekeeper.sh/v1beta1
+kind: ConstraintTemplate
+metadata:
+  name: cisgcpsqlpublicnetworkconstraint
+spec:
+  crd:
+    spec:
+      names:
+        kind: CISGCPSQLPublicNetworkConstraint
+      validation:
+        openAPIV3Schema:
+          properties: {}
+  targets:
+  - target: validation.gcp.forsetisecurity.org
+    rego: |
+      package templates.gcp.CISGCPSQLPublicNetworkConstraint
+
+      violation[{
+      	"msg": message,
+      	"details": metadata,
+      }] {
+      	asset := input.review
+      	asset.asset_type == "sqladmin.googleapis.com/Instance"
+
+      	network := asset.resource.data.settings.ipConfiguration.authorizedNetworks[_]
+      	network.value == "0.0.0.0/0"
+
+      	message := sprintf("%v authorizes connections from 0.0.0.0/0.", [asset.name])
+      	metadata := {"resource": asset.name}
+      }
+`
+
+const sqlRequireSSLTemplate = `apiVersion: templates.gatekeeper.sh/v1beta1
+kind: ConstraintTemplate
+metadata:
+  name: cisgcpsqlrequiresslconstraint
+spec:
+  crd:
+    spec:
+      names:
+        kind: CISGCPSQLRequireSSLConstraint
+      validation:
+        openAPIV3Schema:
+          properties: {}
+  targets:
+  - target: validation.gcp.forsetisecurity.org
+    rego: |
+      package templates.gcp.CISGCPSQLRequireSSLConstraint
+
+      violation[{
+      	"msg": message,
+      	"details": metadata,
+      }] {
+      	asset := input.review
+      	asset.asset_type == "sqladmin.googleapis.com/Instance"
+
+      	instance := asset.resource.data
+      	not requires_ssl(instance)
+
+      	message := sprintf("%v does not require SSL for incoming connections.", [asset.name])
+      	metadata := {"resource": asset.name}
+      }
+
+      requires_ssl(instance) {
+      	instance.settings.ipConfiguration.requireSsl == true
+      }
+
+      requires_ssl(instance) {
+      	ssl_only_mode(instance.settings.ipConfiguration.sslMode)
+      }
+
+      ssl_only_mode("ENCRYPTED_ONLY")
+
+      ssl_only_mode("TRUSTED_CLIENT_CERTIFICATE_REQUIRED")
+`
+
+const defaultNetworkTemplate = `apiVersion: templates.gatekeeper.sh/v1beta1
+kind: ConstraintTemplate
+metadata:
+  name: cisgcpdefaultnetworkconstraint
+spec:
+  crd:
+    spec:
+      names:
+        kind: CISGCPDefaultNetworkConstraint
+      validation:
+        openAPIV3Schema:
+          properties: {}
+  targets:
+  - target: validation.gcp.forsetisecurity.org
+    rego: |
+      package templates.gcp.CISGCPDefaultNetworkConstraint
+
+      violation[{
+      	"msg": message,
+      	"details": metadata,
+      }] {
+      	asset := input.review
+      	asset.asset_type == "compute.googleapis.com/Network"
+      	asset.resource.data.name == "default"
+
+      	message := sprintf("%v is the default network, which has permissive firewall rules.", [asset.name])
+      	metadata := {"resource": asset.name}
+      }
+`
@@ -0,0 +1,176 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cli exposes the policy-tool command tree so that downstream
+// distributions can embed it and register their own subcommands, flags and
+// config sections without forking cmd/.
+package cli
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/forseti-security/config-validator/pkg/cli/debug"
+	"github.com/forseti-security/config-validator/pkg/cli/diff"
+	"github.com/forseti-security/config-validator/pkg/cli/lint"
+	"github.com/forseti-security/config-validator/pkg/cli/status"
+	"github.com/forseti-security/config-validator/pkg/cli/test"
+	"github.com/forseti-security/config-validator/pkg/cli/version"
+	"github.com/forseti-security/config-validator/pkg/logging"
+	"github.com/forseti-security/config-validator/pkg/report"
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// Option configures the root command returned by NewRootCmd.
+type Option func(*options)
+
+type options struct {
+	use      string
+	short    string
+	commands []*cobra.Command
+	flags    []func(*pflag.FlagSet)
+	sections map[string]interface{}
+	preRuns  []func(cmd *cobra.Command, args []string) error
+}
+
+// WithName overrides the name and short description of the root command.
+func WithName(use, short string) Option {
+	return func(o *options) {
+		o.use = use
+		o.short = short
+	}
+}
+
+// WithCommands adds subcommands to the root command alongside the built in ones.
+func WithCommands(cmds ...*cobra.Command) Option {
+	return func(o *options) {
+		o.commands = append(o.commands, cmds...)
+	}
+}
+
+// WithPersistentFlags registers additional flags that are available to every subcommand.
+func WithPersistentFlags(register func(*pflag.FlagSet)) Option {
+	return func(o *options) {
+		o.flags = append(o.flags, register)
+	}
+}
+
+// WithConfigSection registers a named section of the YAML file passed via --config. When the file
+// contains the section, it is decoded into target before any subcommand runs. Target must be a
+// pointer.
+func WithConfigSection(name string, target interface{}) Option {
+	return func(o *options) {
+		o.sections[name] = target
+	}
+}
+
+// WithPreRun registers a hook that runs after flags and config sections have been processed and
+// before the selected subcommand.
+func WithPreRun(fn func(cmd *cobra.Command, args []string) error) Option {
+	return func(o *options) {
+		o.preRuns = append(o.preRuns, fn)
+	}
+}
+
+// NewRootCmd returns the policy-tool root command with the built in subcommands and any extensions
+// provided via opts.
+func NewRootCmd(opts ...Option) *cobra.Command {
+	o := &options{
+		use:      "policy-tool",
+		short:    "Tool for managing constraint template bundles.",
+		sections: map[string]interface{}{},
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	var configPath, logFormat, logLevel string
+	rootCmd := &cobra.Command{
+		Use:   o.use,
+		Short: o.short,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := logging.Setup(logFormat, logLevel); err != nil {
+				return err
+			}
+			if err := loadConfigSections(configPath, o.sections); err != nil {
+				return err
+			}
+			for _, preRun := range o.preRuns {
+				if err := preRun(cmd, args); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+	rootCmd.PersistentFlags().StringVar(&configPath, "config", "", "Path to a YAML config file with sections for registered extensions.")
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", logging.Text, "Log format, text or json for one Cloud Logging structured entry per line.")
+	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "info", "Minimum level of logged lines, one of debug, info, warn, error.")
+	for _, register := range o.flags {
+		register(rootCmd.PersistentFlags())
+	}
+
+	rootCmd.AddCommand(debug.NewCmd())
+	rootCmd.AddCommand(diff.NewCmd())
+	rootCmd.AddCommand(lint.NewCmd())
+	rootCmd.AddCommand(status.NewCmd())
+	rootCmd.AddCommand(version.NewCmd())
+	rootCmd.AddCommand(test.NewCmd())
+	rootCmd.AddCommand(o.commands...)
+	return rootCmd
+}
+
+// loadConfigSections decodes the registered sections from the config file at path.
+func loadConfigSections(path string, sections map[string]interface{}) error {
+	if path == "" {
+		return nil
+	}
+	configBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read config %s", path)
+	}
+	var raw map[string]interface{}
+	if err := yaml.Unmarshal(configBytes, &raw); err != nil {
+		return errors.Wrapf(err, "failed to parse config %s", path)
+	}
+	for name, target := range sections {
+		section, found := raw[name]
+		if !found {
+			continue
+		}
+		sectionBytes, err := yaml.Marshal(section)
+		if err != nil {
+			return errors.Wrapf(err, "failed to read config section %s", name)
+		}
+		if err := yaml.Unmarshal(sectionBytes, target); err != nil {
+			return errors.Wrapf(err, "failed to decode config section %s", name)
+		}
+	}
+	return nil
+}
+
+// Execute builds the root command with opts and runs it, exiting the process on error.
+func Execute(opts ...Option) {
+	if err := NewRootCmd(opts...).Execute(); err != nil {
+		if exitErr, ok := errors.Cause(err).(*report.ExitError); ok {
+			os.Exit(exitErr.Code)
+		}
+		fmt.Printf("%#v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+type sinkConfig struct {
+	Endpoint string `json:"endpoint"`
+	Retries  int    `json:"retries"`
+}
+
+func TestExtensions(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "cliTest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	config := "sink:\n  endpoint: https://example.com\n  retries: 3\nother:\n  foo: bar\n"
+	if err := ioutil.WriteFile(configPath, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var gotConfig sinkConfig
+	var gotFlag string
+	var ran bool
+	sinkCmd := &cobra.Command{
+		Use: "sink",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ran = true
+			return nil
+		},
+	}
+	root := NewRootCmd(
+		WithName("my-tool", "My tool."),
+		WithCommands(sinkCmd),
+		WithPersistentFlags(func(fs *pflag.FlagSet) {
+			fs.StringVar(&gotFlag, "sink-name", "", "name of the sink")
+		}),
+		WithConfigSection("sink", &gotConfig),
+	)
+	root.SetArgs([]string{"sink", "--config", configPath, "--sink-name", "primary"})
+	if err := root.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !ran {
+		t.Errorf("sink command did not run")
+	}
+	if gotFlag != "primary" {
+		t.Errorf("got flag %q, want %q", gotFlag, "primary")
+	}
+	wantConfig := sinkConfig{Endpoint: "https://example.com", Retries: 3}
+	if gotConfig != wantConfig {
+		t.Errorf("got config %+v, want %+v", gotConfig, wantConfig)
+	}
+	if root.Use != "my-tool" {
+		t.Errorf("got root name %q, want %q", root.Use, "my-tool")
+	}
+}
+
+func TestIndependentRoots(t *testing.T) {
+	first := NewRootCmd()
+	second := NewRootCmd(WithName("my-tool", "My policy tool."))
+	for _, root := range []*cobra.Command{first, second} {
+		cmd, _, err := root.Find([]string{"debug"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cmd.Root() != root {
+			t.Errorf("debug command of %s has root %s", root.Name(), cmd.Root().Name())
+		}
+	}
+}
@@ -0,0 +1,315 @@
+package debug
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/forseti-security/config-validator/pkg/asset"
+	"github.com/forseti-security/config-validator/pkg/cai"
+	"github.com/forseti-security/config-validator/pkg/externaldata"
+	"github.com/forseti-security/config-validator/pkg/gcv"
+	"github.com/forseti-security/config-validator/pkg/hooks"
+	"github.com/forseti-security/config-validator/pkg/iammembers"
+	"github.com/forseti-security/config-validator/pkg/logging"
+	"github.com/forseti-security/config-validator/pkg/pacing"
+	"github.com/forseti-security/config-validator/pkg/report"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	cloudasset "google.golang.org/api/cloudasset/v1"
+	cloudidentity "google.golang.org/api/cloudidentity/v1"
+	"google.golang.org/api/option"
+	storage "google.golang.org/api/storage/v1"
+)
+
+// NewCmd returns the debug command.
+func NewCmd() *cobra.Command {
+	flags := &debugFlags{}
+	cmd := &cobra.Command{
+		Use:     "debug",
+		Short:   "Run the config validator on a set of policies / cai data and print out any info on errors.",
+		Example: `policy-tool debug --policies ./forseti-security/policy-library/policies --libs ./forseti-security/policy-library/libs --file resource.json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return debugCmd(cmd, flags)
+		},
+	}
+	cmd.Flags().StringSliceVar(&flags.policies, "policies", nil, "Path to one or more policies directories.")
+	cmd.Flags().StringVar(&flags.libs, "libs", "", "Path to the libs directory.")
+	cmd.Flags().StringSliceVar(&flags.files, "file", nil, "CAI export files to process, newline delimited JSON or length delimited Asset protos, optionally gzip compressed.")
+	cmd.Flags().StringVar(&flags.version, "policy-version", "", "Semantic version of the policy set, defaults to the content hash of the policies.")
+	cmd.Flags().StringVar(&flags.runID, "run-id", "", "Identifier of the run passed to post-run hooks, defaults to the start time.")
+	cmd.Flags().StringArrayVar(&flags.hooks, "post-run-hook", nil,
+		"Shell command run after all files are processed, with the run summary JSON on stdin and GCV_* run metadata in the environment. May be repeated.")
+	cmd.Flags().DurationVar(&flags.hookTimeout, "post-run-hook-timeout", time.Minute, "Maximum run time of each post-run hook, 0 for no limit.")
+	cmd.Flags().StringVar(&flags.exportScope, "export-scope", "",
+		"Export and process the current assets of this scope (organizations/<number>, folders/<number> or projects/<id>) through the Cloud Asset API.")
+	cmd.Flags().StringSliceVar(&flags.exportTypes, "export-asset-types", nil, "Asset types to export, defaults to all types.")
+	cmd.Flags().StringSliceVar(&flags.exportContent, "export-content-types", nil,
+		"Content types to export, RESOURCE, IAM_POLICY, ORG_POLICY or ACCESS_POLICY, defaults to RESOURCE and IAM_POLICY.")
+	cmd.Flags().StringVar(&flags.exportTo, "export-output", "", "gs://bucket/path prefix the Cloud Asset export is written to.")
+	cmd.Flags().StringVar(&flags.apiQPS, "api-qps", "", "Per API request rate limits for Cloud API calls in name=qps form, e.g. cloudasset=5,storage=50.")
+	cmd.Flags().IntVar(&flags.apiRetries, "api-retries", 5, "Number of times a Cloud API call throttled with 429 is retried.")
+	cmd.Flags().BoolVar(&flags.inventory, "inventory", false,
+		"Review all assets together after reading them, so that templates can reference other assets through data.inventory.")
+	cmd.Flags().BoolVar(&flags.expandGroups, "expand-group-members", false,
+		"Expand group members of IAM policies with the Cloud Identity API, adding expanded_members to each binding.")
+	cmd.Flags().StringVar(&flags.providers, "data-providers", "",
+		"HTTP data providers templates can call with external_data, in name=url form, e.g. cmdb=https://cmdb.example.com/lookup.")
+	cmd.Flags().StringVar(&flags.trace, "trace", "",
+		"Name of a constraint to review each asset against on its own, printing the Rego evaluation trace.")
+	cmd.Flags().StringVar(&flags.failOn, "fail-on", "",
+		"Exit non-zero if there are violations of at least this severity, one of low, medium, high, critical.")
+	if err := cmd.MarkFlagRequired("policies"); err != nil {
+		panic(err)
+	}
+	return cmd
+}
+
+// debugFlags holds the flag values of a debug command.
+type debugFlags struct {
+	policies      []string
+	libs          string
+	files         []string
+	runID         string
+	version       string
+	hooks         []string
+	hookTimeout   time.Duration
+	exportScope   string
+	exportTypes   []string
+	exportContent []string
+	exportTo      string
+	apiQPS        string
+	apiRetries    int
+	expandGroups  bool
+	inventory     bool
+	providers     string
+	trace         string
+	failOn        string
+}
+
+func debugCmd(cmd *cobra.Command, flags *debugFlags) error {
+	ctx := context.Background()
+	if flags.trace != "" && flags.inventory {
+		return errors.New("--trace cannot be combined with --inventory")
+	}
+	threshold, err := report.ParseSeverity(flags.failOn)
+	if err != nil {
+		return err
+	}
+	fallback := pacing.Config{MaxRetries: flags.apiRetries}
+	apiConfigs, err := pacing.ParseConfigs(flags.apiQPS, fallback)
+	if err != nil {
+		return err
+	}
+	pacer := pacing.New(nil, apiConfigs, fallback)
+	defer printAPIStats(pacer)
+
+	var opts []gcv.Option
+	if flags.version != "" {
+		opts = append(opts, gcv.WithPolicyVersion(flags.version))
+	}
+	if flags.expandGroups {
+		client, err := pacing.NewHTTPClient(ctx, pacer, option.WithScopes(cloudidentity.CloudIdentityGroupsReadonlyScope))
+		if err != nil {
+			return err
+		}
+		identityService, err := cloudidentity.NewService(ctx, option.WithHTTPClient(client))
+		if err != nil {
+			return err
+		}
+		opts = append(opts, gcv.WithEnricher(iammembers.NewCloudIdentityExpander(identityService)))
+	}
+	providers, err := externaldata.ParseHTTPProviders(flags.providers, nil)
+	if err != nil {
+		return err
+	}
+	for name, provider := range providers {
+		opts = append(opts, gcv.WithDataProvider(name, provider))
+	}
+	validator, err := gcv.NewValidator(append(opts, gcv.WithPolicyPaths(flags.policies...), gcv.WithPolicyLibrary(flags.libs))...)
+	if err != nil {
+		fmt.Printf("Errors Loading Policies:\n%s\n", err)
+		os.Exit(1)
+	}
+
+	runID := flags.runID
+	if runID == "" {
+		runID = time.Now().UTC().Format(time.RFC3339)
+	}
+	ctx = logging.WithRunID(ctx, runID)
+	summary := hooks.NewSummary(runID, validator.PolicyVersion())
+	r := &reviewer{validator: validator, summary: summary, report: report.New(validator), inventory: flags.inventory, trace: flags.trace}
+
+	for _, fileName := range flags.files {
+		if err := debugFile(ctx, r, fileName); err != nil {
+			logging.FromContext(ctx).Error("failed to read file", zap.String("file", fileName), zap.Error(err))
+		}
+	}
+
+	if flags.exportScope != "" {
+		if err := debugExport(ctx, r, pacer, flags); err != nil {
+			logging.FromContext(ctx).Error("failed to export assets", zap.String("scope", flags.exportScope), zap.Error(err))
+		}
+	}
+	if err := r.flush(ctx); err != nil {
+		logging.FromContext(ctx).Error("failed to review inventory", zap.Error(err))
+	}
+
+	var postRunHooks []hooks.Hook
+	for _, command := range flags.hooks {
+		postRunHooks = append(postRunHooks, hooks.Hook{Command: command, Timeout: flags.hookTimeout, Output: os.Stdout})
+	}
+	if err := hooks.Run(ctx, summary, postRunHooks); err != nil {
+		return err
+	}
+	if flags.failOn != "" {
+		if code := report.ExitCode(r.report.MaxSeverityRank(), threshold); code != 0 {
+			fmt.Printf("Found violations of severity %s or higher\n", flags.failOn)
+			cmd.SilenceErrors = true
+			cmd.SilenceUsage = true
+			return &report.ExitError{Code: code}
+		}
+	}
+	return nil
+}
+
+// reviewer reviews records as they are read, or collects them to review them together as one
+// inventory.
+type reviewer struct {
+	validator *gcv.Validator
+	summary   *hooks.Summary
+	// report collects the violations to compare against the --fail-on threshold.
+	report    *report.Report
+	inventory bool
+	// trace is the name of the constraint records are explained against, if set.
+	trace   string
+	records []*asset.Record
+}
+
+func (r *reviewer) review(ctx context.Context, record *asset.Record) {
+	if r.inventory {
+		r.records = append(r.records, record)
+		return
+	}
+	if r.trace != "" {
+		r.explain(ctx, record)
+		return
+	}
+	result, err := r.validator.ReviewRecord(ctx, record)
+	if err != nil {
+		logRecordError(ctx, record, err)
+		r.summary.AddError()
+		return
+	}
+	r.add(result)
+}
+
+// logRecordError logs the failed review of record with its source and contents.
+func logRecordError(ctx context.Context, record *asset.Record, err error) {
+	name, _ := record.Asset["name"].(string)
+	logging.FromContext(ctx).Error("failed to review asset", zap.String(logging.AssetKey, name),
+		zap.Stringer("source", record.Source), zap.Int64("offset", record.Source.Offset), zap.Any("value", record.Asset), zap.Error(err))
+}
+
+// explain reviews record against the traced constraint and prints the evaluation trace.
+func (r *reviewer) explain(ctx context.Context, record *asset.Record) {
+	result, trace, err := r.validator.Explain(ctx, r.trace, record.Asset)
+	if err != nil {
+		logRecordError(ctx, record, err)
+		r.summary.AddError()
+		return
+	}
+	fmt.Printf("Trace of %s for %s (offset %d):\n%s\n", r.trace, record.Source, record.Source.Offset, trace)
+	source := record.Source
+	result.Source = &source
+	r.add(result)
+}
+
+// flush reviews the collected records as one inventory.
+func (r *reviewer) flush(ctx context.Context) error {
+	if len(r.records) == 0 {
+		return nil
+	}
+	assets := make([]map[string]interface{}, len(r.records))
+	for idx, record := range r.records {
+		assets[idx] = record.Asset
+	}
+	results, err := r.validator.ReviewInventory(ctx, assets)
+	if err != nil {
+		r.summary.AddError()
+		return err
+	}
+	for idx, result := range results {
+		source := r.records[idx].Source
+		result.Source = &source
+		r.add(result)
+	}
+	return nil
+}
+
+// add records the violations of a reviewed asset in the summary and report.
+func (r *reviewer) add(result *gcv.Result) {
+	r.summary.Add(result)
+	r.report.Add(result)
+}
+
+func debugFile(ctx context.Context, r *reviewer, fileName string) error {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	reader := asset.NewReader(f, fileName)
+	for {
+		record, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if decodeErr, ok := err.(*asset.DecodeError); ok {
+			logging.FromContext(ctx).Error("failed to decode asset",
+				zap.Stringer("source", decodeErr.Source), zap.Int64("offset", decodeErr.Source.Offset), zap.Error(decodeErr.Err))
+			r.summary.AddError()
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		r.review(ctx, record)
+	}
+}
+
+func debugExport(ctx context.Context, r *reviewer, pacer *pacing.Transport, flags *debugFlags) error {
+	client, err := pacing.NewHTTPClient(ctx, pacer)
+	if err != nil {
+		return err
+	}
+	assetService, err := cloudasset.NewService(ctx, option.WithHTTPClient(client))
+	if err != nil {
+		return err
+	}
+	storageService, err := storage.NewService(ctx, option.WithHTTPClient(client))
+	if err != nil {
+		return err
+	}
+	exporter := cai.NewExporter(assetService, storageService)
+	opts := cai.ExportOptions{
+		Parent:       flags.exportScope,
+		AssetTypes:   flags.exportTypes,
+		ContentTypes: flags.exportContent,
+		OutputPrefix: flags.exportTo,
+	}
+	return exporter.Export(ctx, opts, func(record *asset.Record) error {
+		r.review(ctx, record)
+		return nil
+	})
+}
+
+func printAPIStats(pacer *pacing.Transport) {
+	for name, stats := range pacer.Stats() {
+		fmt.Printf("%s API: %d requests, %d throttled, %d retries\n", name, stats.Requests, stats.Throttled, stats.Retries)
+	}
+}
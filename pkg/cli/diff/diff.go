@@ -0,0 +1,101 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"context"
+	"io"
+	"os"
+
+	"github.com/forseti-security/config-validator/pkg/asset"
+	"github.com/forseti-security/config-validator/pkg/gcv"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// NewCmd returns the diff command.
+func NewCmd() *cobra.Command {
+	flags := &diffFlags{}
+	cmd := &cobra.Command{
+		Use:   "diff",
+		Short: "Review CAI data with two revisions of a policy library and print how their violations differ as JSON.",
+		Example: `policy-tool diff --base-policies ./v1/policies --base-libs ./v1/lib ` +
+			`--policies ./v2/policies --libs ./v2/lib --file resources.json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return diffCmd(cmd, flags)
+		},
+	}
+	cmd.Flags().StringSliceVar(&flags.basePolicies, "base-policies", nil, "Path to one or more policies directories of the base revision.")
+	cmd.Flags().StringVar(&flags.baseLibs, "base-libs", "", "Path to the libs directory of the base revision.")
+	cmd.Flags().StringSliceVar(&flags.policies, "policies", nil, "Path to one or more policies directories of the new revision.")
+	cmd.Flags().StringVar(&flags.libs, "libs", "", "Path to the libs directory of the new revision.")
+	cmd.Flags().StringSliceVar(&flags.files, "file", nil, "CAI export files to review, newline delimited JSON or length delimited Asset protos, optionally gzip compressed.")
+	for _, name := range []string{"base-policies", "policies", "file"} {
+		if err := cmd.MarkFlagRequired(name); err != nil {
+			panic(err)
+		}
+	}
+	return cmd
+}
+
+// diffFlags holds the flag values of a diff command.
+type diffFlags struct {
+	basePolicies []string
+	baseLibs     string
+	policies     []string
+	libs         string
+	files        []string
+}
+
+func diffCmd(cmd *cobra.Command, flags *diffFlags) error {
+	ctx := context.Background()
+	base, err := gcv.NewValidator(gcv.WithPolicyPaths(flags.basePolicies...), gcv.WithPolicyLibrary(flags.baseLibs))
+	if err != nil {
+		return errors.Wrapf(err, "base policies")
+	}
+	head, err := gcv.NewValidator(gcv.WithPolicyPaths(flags.policies...), gcv.WithPolicyLibrary(flags.libs))
+	if err != nil {
+		return err
+	}
+	differ := gcv.NewPolicyDiffer(base, head)
+	for _, fileName := range flags.files {
+		if err := diffFile(ctx, differ, fileName); err != nil {
+			return errors.Wrapf(err, "failed to review %s", fileName)
+		}
+	}
+	return differ.Diff().Write(os.Stdout)
+}
+
+func diffFile(ctx context.Context, differ *gcv.PolicyDiffer, fileName string) error {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	reader := asset.NewReader(f, fileName)
+	for {
+		record, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := differ.Review(ctx, record.Asset); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lint
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/forseti-security/config-validator/pkg/gcv"
+	"github.com/forseti-security/config-validator/pkg/lint"
+	"github.com/spf13/cobra"
+)
+
+// NewCmd returns the lint command.
+func NewCmd() *cobra.Command {
+	flags := &lintFlags{}
+	cmd := &cobra.Command{
+		Use:     "lint",
+		Short:   "Lint a directory containing ConstraintTemplates and/or Constraints.",
+		Example: `policy-tool status --policies ./forseti-security/policy-library/policies --libs ./forseti-security/policy-library/libs`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return lintCmd(cmd, flags)
+		},
+	}
+	cmd.Flags().StringSliceVar(&flags.policies, "policies", nil, "Path to one or more policies directories.")
+	cmd.Flags().StringVar(&flags.libs, "libs", "", "Path to the libs directory.")
+	if err := cmd.MarkFlagRequired("policies"); err != nil {
+		panic(err)
+	}
+	return cmd
+}
+
+// lintFlags holds the flag values of a lint command.
+type lintFlags struct {
+	policies []string
+	libs     string
+}
+
+func lintCmd(cmd *cobra.Command, flags *lintFlags) error {
+	diagnostics, err := lint.LintPaths(context.Background(), flags.policies, flags.libs)
+	if err != nil {
+		return err
+	}
+	for _, d := range diagnostics {
+		fmt.Println(d)
+	}
+	if lint.HasErrors(diagnostics) {
+		os.Exit(1)
+	}
+	// Loading catches anything the linter does not check for.
+	if _, err := gcv.NewValidator(gcv.WithPolicyPaths(flags.policies...), gcv.WithPolicyLibrary(flags.libs)); err != nil {
+		fmt.Printf("linter errors:\n%v\n", err)
+		os.Exit(1)
+	}
+	if len(diagnostics) == 0 {
+		fmt.Printf("No lint errors found.\n")
+	}
+	return nil
+}
@@ -22,23 +22,23 @@ import (
 	"github.com/forseti-security/config-validator/pkg/bundlemanager"
 )
 
-var Cmd = &cobra.Command{
-	Use:     "status",
-	Short:   "Print the status of the policy library's constraint templates and bundles.",
-	Example: `policy-tool status --path ./forseti-security/policy-library/policies`,
-	RunE:    statusCmd,
-}
-
-var (
-	path string
-)
-
-func init() {
-	Cmd.Flags().StringVar(&path, "path", "", "Path to the policies directory.")
-	Cmd.MarkFlagRequired("path")
+// NewCmd returns the status command.
+func NewCmd() *cobra.Command {
+	var path string
+	cmd := &cobra.Command{
+		Use:     "status",
+		Short:   "Print the status of the policy library's constraint templates and bundles.",
+		Example: `policy-tool status --path ./forseti-security/policy-library/policies`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return statusCmd(path)
+		},
+	}
+	cmd.Flags().StringVar(&path, "path", "", "Path to the policies directory.")
+	cmd.MarkFlagRequired("path")
+	return cmd
 }
 
-func statusCmd(cmd *cobra.Command, args []string) error {
+func statusCmd(path string) error {
 	bundleManager := bundlemanager.New()
 	if err := bundleManager.Load(path); err != nil {
 		return err
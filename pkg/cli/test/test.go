@@ -0,0 +1,78 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"context"
+	"os"
+
+	"github.com/forseti-security/config-validator/pkg/gcv"
+	"github.com/forseti-security/config-validator/pkg/policytest"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// NewCmd returns the test command.
+func NewCmd() *cobra.Command {
+	flags := &testFlags{}
+	cmd := &cobra.Command{
+		Use:     "test",
+		Short:   "Run the PolicyTest cases found alongside the policies and report which failed.",
+		Example: `policy-tool test --policies ./forseti-security/policy-library/policies --libs ./forseti-security/policy-library/libs`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return testCmd(cmd, flags)
+		},
+	}
+	cmd.Flags().StringSliceVar(&flags.policies, "policies", nil, "Path to one or more policies directories.")
+	cmd.Flags().StringVar(&flags.libs, "libs", "", "Path to the libs directory.")
+	cmd.Flags().BoolVar(&flags.verbose, "verbose", false, "Print passed cases as well as failed ones.")
+	if err := cmd.MarkFlagRequired("policies"); err != nil {
+		panic(err)
+	}
+	return cmd
+}
+
+// testFlags holds the flag values of a test command.
+type testFlags struct {
+	policies []string
+	libs     string
+	verbose  bool
+}
+
+func testCmd(cmd *cobra.Command, flags *testFlags) error {
+	ctx := context.Background()
+	suites, err := policytest.Load(ctx, flags.policies)
+	if err != nil {
+		return err
+	}
+	if len(suites) == 0 {
+		return errors.Errorf("no %s documents found in %v", policytest.Kind, flags.policies)
+	}
+	v, err := gcv.NewValidator(gcv.WithPolicyPaths(flags.policies...), gcv.WithPolicyLibrary(flags.libs))
+	if err != nil {
+		return err
+	}
+	report, err := policytest.Run(ctx, v, suites)
+	if err != nil {
+		return err
+	}
+	if err := report.Write(os.Stdout, flags.verbose); err != nil {
+		return err
+	}
+	if report.Failed() > 0 {
+		os.Exit(1)
+	}
+	return nil
+}
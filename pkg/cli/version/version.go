@@ -0,0 +1,54 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package version
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/forseti-security/config-validator/pkg/gcv"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// NewCmd returns the version command.
+func NewCmd() *cobra.Command {
+	var asJSON bool
+	cmd := &cobra.Command{
+		Use:   "version",
+		Short: "Print the version of the validator, or its capabilities with --json.",
+		Long: "Print the version of the validator. With --json, print the validator, API and OPA versions, the targets, input formats " +
+			"and features of the binary, as the GetCapabilities RPC of the server reports them, for tools to adapt to the installed version.",
+		Example: `policy-tool version --json`,
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return versionCmd(cmd, asJSON)
+		},
+	}
+	cmd.Flags().BoolVar(&asJSON, "json", false, "Print the capabilities of the binary as JSON.")
+	return cmd
+}
+
+func versionCmd(cmd *cobra.Command, asJSON bool) error {
+	c := gcv.NewCapabilities()
+	if !asJSON {
+		_, err := fmt.Fprintf(cmd.OutOrStdout(), "validator %s (OPA %s, targets %s)\n", c.ValidatorVersion, c.OPAVersion, strings.Join(c.Targets, ", "))
+		return err
+	}
+	encoder := json.NewEncoder(cmd.OutOrStdout())
+	encoder.SetIndent("", "  ")
+	return errors.Wrapf(encoder.Encode(c), "failed to encode capabilities")
+}
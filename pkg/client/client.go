@@ -0,0 +1,264 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package client is a Go client of the validator service. It reviews asset batches of any size
+// by splitting them into requests under the message size limit of the server, retries calls the
+// server could not take, and reassembles the violations in the order of the assets.
+package client
+
+import (
+	"context"
+	"time"
+
+	"github.com/forseti-security/config-validator/pkg/api/validator"
+	"github.com/forseti-security/config-validator/pkg/logging"
+	"github.com/forseti-security/config-validator/pkg/ratelimit"
+	"github.com/forseti-security/config-validator/pkg/rpcconfig"
+	"github.com/golang/protobuf/proto"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultMaxRequestSize is the default size limit of review requests, the 4MB gRPC default
+// limit of received messages, which servers with any message size settings accept.
+const DefaultMaxRequestSize = 4 * 1024 * 1024
+
+// Client calls the validator service of a connection. A Client is safe for concurrent use.
+type Client struct {
+	conn           *grpc.ClientConn
+	ownsConn       bool
+	validator      validator.ValidatorClient
+	dialOptions    []grpc.DialOption
+	maxRequestSize int
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	sleep          func(ctx context.Context, d time.Duration) error
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithDialOptions adds options for dialing the server, such as its transport credentials.
+// They only apply to clients returned by Dial.
+func WithDialOptions(opts ...grpc.DialOption) Option {
+	return func(c *Client) {
+		c.dialOptions = append(c.dialOptions, opts...)
+	}
+}
+
+// WithMaxRequestSize sets the size in bytes of the largest review request sent,
+// DefaultMaxRequestSize by default. Review splits larger batches into several requests.
+func WithMaxRequestSize(size int) Option {
+	return func(c *Client) {
+		c.maxRequestSize = size
+	}
+}
+
+// WithRetry sets the number of attempts made for each call and the exponential backoff between
+// attempts, 5 attempts starting at 1s and capped at 30s by default.
+func WithRetry(maxAttempts int, initialBackoff, maxBackoff time.Duration) Option {
+	return func(c *Client) {
+		c.maxAttempts = maxAttempts
+		c.initialBackoff = initialBackoff
+		c.maxBackoff = maxBackoff
+	}
+}
+
+func newClient(opts []Option) (*Client, error) {
+	c := &Client{
+		maxRequestSize: DefaultMaxRequestSize,
+		maxAttempts:    5,
+		initialBackoff: time.Second,
+		maxBackoff:     30 * time.Second,
+		sleep:          sleep,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.maxRequestSize <= 0 {
+		return nil, errors.Errorf("max request size must be positive, got %d", c.maxRequestSize)
+	}
+	if c.maxAttempts < 1 {
+		return nil, errors.Errorf("max attempts must be at least 1, got %d", c.maxAttempts)
+	}
+	return c, nil
+}
+
+// New returns a Client calling the validator service on conn. Closing the Client leaves conn
+// open.
+func New(conn *grpc.ClientConn, opts ...Option) (*Client, error) {
+	c, err := newClient(opts)
+	if err != nil {
+		return nil, err
+	}
+	c.conn = conn
+	c.validator = validator.NewValidatorClient(conn)
+	return c, nil
+}
+
+// Dial returns a Client connected to the server at target, which Close disconnects. The
+// connection accepts responses up to rpcconfig.DefaultMaxMessageSize, and is established in the
+// background unless the dial options block. The transport credentials must be set with
+// WithDialOptions, e.g. grpc.WithInsecure() for servers without TLS.
+func Dial(ctx context.Context, target string, opts ...Option) (*Client, error) {
+	c, err := newClient(opts)
+	if err != nil {
+		return nil, err
+	}
+	dialOpts, err := rpcconfig.DialOptions(rpcconfig.Options{MaxRecvSize: rpcconfig.DefaultMaxMessageSize})
+	if err != nil {
+		return nil, err
+	}
+	conn, err := grpc.DialContext(ctx, target, append(dialOpts, c.dialOptions...)...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to dial %s", target)
+	}
+	c.conn = conn
+	c.ownsConn = true
+	c.validator = validator.NewValidatorClient(conn)
+	return c, nil
+}
+
+// Close closes the connection of a Client returned by Dial.
+func (c *Client) Close() error {
+	if !c.ownsConn {
+		return nil
+	}
+	return c.conn.Close()
+}
+
+// Validator returns the raw client of the validator service, for calls without chunking or
+// retries.
+func (c *Client) Validator() validator.ValidatorClient {
+	return c.validator
+}
+
+// Review reviews the assets of request against its policy set. Batches larger than the max
+// request size are reviewed in several requests, one after the other, and the response holds the
+// violations of all of them in the order of the assets. A single asset larger than the limit is
+// an error. Errors of the server are returned as gRPC status errors.
+func (c *Client) Review(ctx context.Context, request *validator.ReviewRequest, opts ...grpc.CallOption) (*validator.ReviewResponse, error) {
+	chunks, err := c.chunk(request)
+	if err != nil {
+		return nil, err
+	}
+	response := &validator.ReviewResponse{}
+	for idx, chunk := range chunks {
+		var chunkResponse *validator.ReviewResponse
+		err := c.retry(ctx, "Review", func() error {
+			var err error
+			chunkResponse, err = c.validator.Review(ctx, chunk, opts...)
+			return err
+		})
+		if err != nil {
+			if len(chunks) == 1 {
+				return nil, err
+			}
+			return nil, status.Errorf(status.Code(err), "request %d of %d: %s", idx+1, len(chunks), status.Convert(err).Message())
+		}
+		response.Violations = append(response.Violations, chunkResponse.Violations...)
+	}
+	return response, nil
+}
+
+// ListConstraints lists the constraints of a policy set, retrying if the server is unavailable.
+func (c *Client) ListConstraints(ctx context.Context, request *validator.ListConstraintsRequest, opts ...grpc.CallOption) (*validator.ListConstraintsResponse, error) {
+	var response *validator.ListConstraintsResponse
+	err := c.retry(ctx, "ListConstraints", func() error {
+		var err error
+		response, err = c.validator.ListConstraints(ctx, request, opts...)
+		return err
+	})
+	return response, err
+}
+
+// chunk splits the assets of request into requests of at most maxRequestSize bytes, keeping
+// their order. Requests without assets are sent as they are.
+func (c *Client) chunk(request *validator.ReviewRequest) ([]*validator.ReviewRequest, error) {
+	newChunk := func() *validator.ReviewRequest {
+		return &validator.ReviewRequest{PolicySet: request.PolicySet, BatchSize: request.BatchSize}
+	}
+	base := proto.Size(newChunk())
+	chunks := []*validator.ReviewRequest{newChunk()}
+	size := base
+	for _, asset := range request.Assets {
+		// Each asset is encoded as a length-delimited field with a one byte tag.
+		assetSize := proto.Size(asset)
+		assetSize += 1 + proto.SizeVarint(uint64(assetSize))
+		if base+assetSize > c.maxRequestSize {
+			return nil, errors.Errorf("asset %s is %d bytes, over the request size limit of %d bytes", asset.Name, assetSize, c.maxRequestSize)
+		}
+		last := chunks[len(chunks)-1]
+		if size+assetSize > c.maxRequestSize {
+			last = newChunk()
+			chunks = append(chunks, last)
+			size = base
+		}
+		last.Assets = append(last.Assets, asset)
+		size += assetSize
+	}
+	return chunks, nil
+}
+
+// retry calls call until it succeeds, fails with an error that cannot be retried, or the
+// attempts or ctx run out, and returns the error of the last attempt so that callers can inspect
+// its status. Calls are retried with exponential backoff if the server is unavailable, and after
+// the delay the server asks for if it rejected the call with a retry hint, such as when over a
+// rate limit.
+func (c *Client) retry(ctx context.Context, method string, call func() error) error {
+	backoff := c.initialBackoff
+	for attempt := 1; ; attempt++ {
+		err := call()
+		if err == nil {
+			return nil
+		}
+		wait := backoff
+		switch status.Code(err) {
+		case codes.Unavailable:
+		case codes.ResourceExhausted:
+			if wait = ratelimit.RetryDelay(err); wait == 0 {
+				return err
+			}
+		default:
+			return err
+		}
+		if attempt >= c.maxAttempts {
+			return err
+		}
+		logging.FromContext(ctx).Debug("retrying call", zap.String("method", method), zap.Int("attempt", attempt),
+			zap.Int("max_attempts", c.maxAttempts), zap.Duration("backoff", wait), zap.Error(err))
+		if c.sleep(ctx, wait) != nil {
+			return err
+		}
+		backoff *= 2
+		if backoff > c.maxBackoff {
+			backoff = c.maxBackoff
+		}
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,224 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/forseti-security/config-validator/pkg/api/validator"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// fakeServer returns a violation for each asset it reviews, after failing the first calls with
+// errors.
+type fakeServer struct {
+	validator.UnimplementedValidatorServer
+	mu       sync.Mutex
+	errors   []error
+	requests []*validator.ReviewRequest
+}
+
+func (s *fakeServer) Review(ctx context.Context, request *validator.ReviewRequest) (*validator.ReviewResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, request)
+	if len(s.errors) != 0 {
+		err := s.errors[0]
+		s.errors = s.errors[1:]
+		return nil, err
+	}
+	response := &validator.ReviewResponse{}
+	for _, asset := range request.Assets {
+		response.Violations = append(response.Violations, &validator.Violation{Constraint: request.PolicySet, Resource: asset.Name})
+	}
+	return response, nil
+}
+
+// dial serves fake on an in-memory listener and returns a Client connected to it, and a function
+// stopping the server. The client records its backoffs in waits instead of sleeping.
+func dial(t *testing.T, fake *fakeServer, waits *[]time.Duration, opts ...Option) (*Client, func()) {
+	listener := bufconn.Listen(1024 * 1024)
+	grpcServer := grpc.NewServer()
+	validator.RegisterValidatorServer(grpcServer, fake)
+	go grpcServer.Serve(listener)
+	dialer := grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+		return listener.Dial()
+	})
+	opts = append([]Option{WithDialOptions(dialer, grpc.WithInsecure())}, opts...)
+	c, err := Dial(context.Background(), "bufnet", opts...)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	c.sleep = func(ctx context.Context, d time.Duration) error {
+		*waits = append(*waits, d)
+		return ctx.Err()
+	}
+	return c, func() {
+		c.Close()
+		grpcServer.Stop()
+	}
+}
+
+func assets(count int) []*validator.Asset {
+	var assets []*validator.Asset
+	for i := 0; i < count; i++ {
+		assets = append(assets, &validator.Asset{
+			Name:      fmt.Sprintf("//storage.googleapis.com/bucket-%d", i),
+			AssetType: "storage.googleapis.com/Bucket",
+		})
+	}
+	return assets
+}
+
+func TestReviewChunks(t *testing.T) {
+	fake := &fakeServer{}
+	var waits []time.Duration
+	c, cleanup := dial(t, fake, &waits, WithMaxRequestSize(300))
+	defer cleanup()
+
+	request := &validator.ReviewRequest{Assets: assets(20), PolicySet: "sales", BatchSize: 10}
+	response, err := c.Review(context.Background(), request)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var got []string
+	for _, v := range response.Violations {
+		if v.Constraint != "sales" {
+			t.Errorf("violation %v was reviewed against policy set %q, want sales", v, v.Constraint)
+		}
+		got = append(got, v.Resource)
+	}
+	var want []string
+	for _, asset := range request.Assets {
+		want = append(want, asset.Name)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected violations (-want +got):\n%s", diff)
+	}
+	if len(fake.requests) < 2 {
+		t.Errorf("got %d requests, want the assets split across several", len(fake.requests))
+	}
+	for _, r := range fake.requests {
+		if size := proto.Size(r); size > 300 || r.BatchSize != 10 {
+			t.Errorf("got request of %d bytes with batch size %d, want at most 300 bytes with batch size 10", size, r.BatchSize)
+		}
+	}
+}
+
+func TestReviewAssetTooLarge(t *testing.T) {
+	fake := &fakeServer{}
+	var waits []time.Duration
+	c, cleanup := dial(t, fake, &waits, WithMaxRequestSize(50))
+	defer cleanup()
+
+	_, err := c.Review(context.Background(), &validator.ReviewRequest{Assets: assets(1)})
+	if err == nil || !strings.Contains(err.Error(), "over the request size limit") {
+		t.Errorf("got error %v, want asset over the request size limit", err)
+	}
+	if len(fake.requests) != 0 {
+		t.Errorf("got %d requests, want none", len(fake.requests))
+	}
+}
+
+func TestReviewRetries(t *testing.T) {
+	delayed, err := status.New(codes.ResourceExhausted, "over the rate limit").WithDetails(
+		&errdetails.RetryInfo{RetryDelay: ptypes.DurationProto(5 * time.Second)})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	unavailable := status.Error(codes.Unavailable, "unavailable")
+
+	for _, tc := range []struct {
+		name      string
+		errors    []error
+		wantCode  codes.Code
+		wantCalls int
+		wantWaits []time.Duration
+	}{
+		{
+			name:      "unavailable",
+			errors:    []error{unavailable, unavailable},
+			wantCode:  codes.OK,
+			wantCalls: 3,
+			wantWaits: []time.Duration{time.Second, 2 * time.Second},
+		},
+		{
+			name:      "retry hint",
+			errors:    []error{delayed.Err()},
+			wantCode:  codes.OK,
+			wantCalls: 2,
+			wantWaits: []time.Duration{5 * time.Second},
+		},
+		{
+			name:      "exhausted without hint",
+			errors:    []error{status.Error(codes.ResourceExhausted, "quota")},
+			wantCode:  codes.ResourceExhausted,
+			wantCalls: 1,
+		},
+		{
+			name:      "invalid argument",
+			errors:    []error{status.Error(codes.InvalidArgument, "invalid")},
+			wantCode:  codes.InvalidArgument,
+			wantCalls: 1,
+		},
+		{
+			name:      "attempts run out",
+			errors:    []error{unavailable, unavailable, unavailable, unavailable},
+			wantCode:  codes.Unavailable,
+			wantCalls: 3,
+			wantWaits: []time.Duration{time.Second, 2 * time.Second},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := &fakeServer{errors: tc.errors}
+			var waits []time.Duration
+			c, cleanup := dial(t, fake, &waits, WithRetry(3, time.Second, 10*time.Second))
+			defer cleanup()
+
+			_, err := c.Review(context.Background(), &validator.ReviewRequest{Assets: assets(2)})
+			if status.Code(err) != tc.wantCode {
+				t.Errorf("got error %v, want code %s", err, tc.wantCode)
+			}
+			if len(fake.requests) != tc.wantCalls {
+				t.Errorf("got %d calls, want %d", len(fake.requests), tc.wantCalls)
+			}
+			if diff := cmp.Diff(tc.wantWaits, waits); diff != "" {
+				t.Errorf("unexpected backoffs (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNewOptions(t *testing.T) {
+	if _, err := New(nil, WithMaxRequestSize(0)); err == nil {
+		t.Errorf("client with max request size 0 succeeded, want error")
+	}
+	if _, err := New(nil, WithRetry(0, time.Second, time.Second)); err == nil {
+		t.Errorf("client with 0 attempts succeeded, want error")
+	}
+}
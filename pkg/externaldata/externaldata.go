@@ -0,0 +1,225 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package externaldata lets templates look up data that is not part of CAI exports, such as
+// owners from a CMDB or an allowlist service, from registered providers.
+//
+// Templates call the external_data function with the name of a provider and the keys to look up:
+//
+//   response := external_data({"provider": "cmdb", "keys": ["projects/p"]})
+//
+// The response has the same shape as in Gatekeeper:
+//
+//   {
+//     "responses": [["projects/p", {"owner": "alice"}]],
+//     "errors": [["projects/q", "not found"]],
+//     "system_error": ""
+//   }
+//
+// Keys the provider has no value for are listed in errors. If the provider fails, system_error
+// holds the failure and templates decide whether to flag the asset. Values are cached for the
+// duration of a review, so templates looking up the same key repeatedly call the provider once.
+package externaldata
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/topdown"
+	"github.com/open-policy-agent/opa/types"
+	"github.com/pkg/errors"
+)
+
+// BuiltinName is the name of the Rego function calling providers.
+const BuiltinName = "external_data"
+
+// Provider looks up values for keys.
+type Provider interface {
+	// Fetch returns the value of each key. Keys without a value are reported to templates as
+	// errors.
+	Fetch(ctx context.Context, keys []string) (map[string]interface{}, error)
+}
+
+// ProviderFunc adapts a function to Provider.
+type ProviderFunc func(ctx context.Context, keys []string) (map[string]interface{}, error)
+
+// Fetch implements Provider.
+func (f ProviderFunc) Fetch(ctx context.Context, keys []string) (map[string]interface{}, error) {
+	return f(ctx, keys)
+}
+
+// Registry maps provider names to providers.
+type Registry map[string]Provider
+
+type contextKey struct{}
+
+// review holds the providers and the values they returned during one review.
+type review struct {
+	registry Registry
+
+	mu     sync.Mutex
+	values map[string]map[string]cachedValue
+}
+
+type cachedValue struct {
+	value interface{}
+	found bool
+}
+
+// NewContext returns a context making the providers of registry available to external_data
+// calls evaluated with it. Each context has its own cache, so it should be created once per
+// review.
+func NewContext(ctx context.Context, registry Registry) context.Context {
+	return context.WithValue(ctx, contextKey{}, &review{
+		registry: registry,
+		values:   map[string]map[string]cachedValue{},
+	})
+}
+
+func init() {
+	ast.RegisterBuiltin(&ast.Builtin{
+		Name: BuiltinName,
+		Decl: types.NewFunction(
+			types.Args(types.NewObject(nil, types.NewDynamicProperty(types.S, types.A))),
+			types.NewObject(nil, types.NewDynamicProperty(types.S, types.A)),
+		),
+	})
+	topdown.RegisterBuiltinFunc(BuiltinName, builtinExternalData)
+}
+
+// request is the argument of external_data.
+type request struct {
+	provider string
+	keys     []string
+}
+
+func parseRequest(term *ast.Term) (*request, error) {
+	value, err := ast.JSON(term.Value)
+	if err != nil {
+		return nil, err
+	}
+	obj, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, errors.Errorf("request must be an object")
+	}
+	provider, ok := obj["provider"].(string)
+	if !ok || provider == "" {
+		return nil, errors.Errorf("request must have a provider")
+	}
+	keys, ok := obj["keys"].([]interface{})
+	if !ok {
+		return nil, errors.Errorf("request must have a list of keys")
+	}
+	r := &request{provider: provider}
+	for _, key := range keys {
+		s, ok := key.(string)
+		if !ok {
+			return nil, errors.Errorf("keys must be strings, got %v", key)
+		}
+		r.keys = append(r.keys, s)
+	}
+	return r, nil
+}
+
+func builtinExternalData(bctx topdown.BuiltinContext, args []*ast.Term, iter func(*ast.Term) error) error {
+	req, err := parseRequest(args[0])
+	if err != nil {
+		return err
+	}
+	rev, ok := bctx.Context.Value(contextKey{}).(*review)
+	if !ok {
+		return errors.Errorf("no data providers are registered")
+	}
+	provider, ok := rev.registry[req.provider]
+	if !ok {
+		return errors.Errorf("unknown data provider %q", req.provider)
+	}
+
+	response := map[string]interface{}{
+		"responses":    []interface{}{},
+		"errors":       []interface{}{},
+		"system_error": "",
+	}
+	values, err := rev.fetch(bctx.Context, req.provider, provider, req.keys)
+	if err != nil {
+		response["system_error"] = err.Error()
+	} else {
+		var responses, keyErrors []interface{}
+		for _, key := range req.keys {
+			if v := values[key]; v.found {
+				responses = append(responses, []interface{}{key, v.value})
+			} else {
+				keyErrors = append(keyErrors, []interface{}{key, "not found"})
+			}
+		}
+		if responses != nil {
+			response["responses"] = responses
+		}
+		if keyErrors != nil {
+			response["errors"] = keyErrors
+		}
+	}
+
+	value, err := ast.InterfaceToValue(response)
+	if err != nil {
+		return errors.Wrapf(err, "invalid response from data provider %q", req.provider)
+	}
+	return iter(ast.NewTerm(value))
+}
+
+// fetch returns the values of keys, calling provider only for keys not looked up before during
+// this review.
+func (r *review) fetch(ctx context.Context, name string, provider Provider, keys []string) (map[string]cachedValue, error) {
+	r.mu.Lock()
+	cached, ok := r.values[name]
+	if !ok {
+		cached = map[string]cachedValue{}
+		r.values[name] = cached
+	}
+	result := map[string]cachedValue{}
+	missing := map[string]bool{}
+	for _, key := range keys {
+		if v, ok := cached[key]; ok {
+			result[key] = v
+		} else {
+			missing[key] = true
+		}
+	}
+	r.mu.Unlock()
+	if len(missing) == 0 {
+		return result, nil
+	}
+
+	var fetchKeys []string
+	for key := range missing {
+		fetchKeys = append(fetchKeys, key)
+	}
+	sort.Strings(fetchKeys)
+	values, err := provider.Fetch(ctx, fetchKeys)
+	if err != nil {
+		return nil, errors.Wrapf(err, "data provider %q", name)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, key := range fetchKeys {
+		value, found := values[key]
+		v := cachedValue{value: value, found: found}
+		cached[key] = v
+		result[key] = v
+	}
+	return result, nil
+}
@@ -0,0 +1,167 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package externaldata
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/open-policy-agent/opa/rego"
+	"github.com/pkg/errors"
+)
+
+// owners is a provider counting the keys it is asked for.
+type owners struct {
+	values  map[string]interface{}
+	fetched []string
+	err     error
+}
+
+func (o *owners) Fetch(ctx context.Context, keys []string) (map[string]interface{}, error) {
+	o.fetched = append(o.fetched, keys...)
+	if o.err != nil {
+		return nil, o.err
+	}
+	values := map[string]interface{}{}
+	for _, key := range keys {
+		if value, ok := o.values[key]; ok {
+			values[key] = value
+		}
+	}
+	return values, nil
+}
+
+func eval(ctx context.Context, t *testing.T, query string) interface{} {
+	t.Helper()
+	rs, err := rego.New(rego.Query(query)).Eval(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(rs) != 1 || len(rs[0].Expressions) != 1 {
+		t.Fatalf("got results %v, want one", rs)
+	}
+	return rs[0].Expressions[0].Value
+}
+
+func TestExternalData(t *testing.T) {
+	provider := &owners{values: map[string]interface{}{"projects/p": map[string]interface{}{"owner": "alice"}}}
+	ctx := NewContext(context.Background(), Registry{"cmdb": provider})
+
+	got := eval(ctx, t, `external_data({"provider": "cmdb", "keys": ["projects/p", "projects/q"]})`)
+	want := map[string]interface{}{
+		"responses":    []interface{}{[]interface{}{"projects/p", map[string]interface{}{"owner": "alice"}}},
+		"errors":       []interface{}{[]interface{}{"projects/q", "not found"}},
+		"system_error": "",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected response (-want +got):\n%s", diff)
+	}
+
+	// Both keys are cached, including the one without a value.
+	eval(ctx, t, `external_data({"provider": "cmdb", "keys": ["projects/q", "projects/p", "projects/r"]})`)
+	if diff := cmp.Diff([]string{"projects/p", "projects/q", "projects/r"}, provider.fetched); diff != "" {
+		t.Errorf("unexpected fetched keys (-want +got):\n%s", diff)
+	}
+
+	// A new review starts with an empty cache.
+	ctx = NewContext(context.Background(), Registry{"cmdb": provider})
+	eval(ctx, t, `external_data({"provider": "cmdb", "keys": ["projects/p"]})`)
+	if got := len(provider.fetched); got != 4 {
+		t.Errorf("got %d fetched keys, want 4", got)
+	}
+}
+
+func TestExternalDataSystemError(t *testing.T) {
+	provider := &owners{err: errors.New("connection refused")}
+	ctx := NewContext(context.Background(), Registry{"cmdb": provider})
+
+	got := eval(ctx, t, `external_data({"provider": "cmdb", "keys": ["projects/p"]}).system_error`)
+	if s, _ := got.(string); !strings.Contains(s, "connection refused") {
+		t.Errorf("got system_error %v, want provider error", got)
+	}
+}
+
+func TestExternalDataInvalid(t *testing.T) {
+	ctx := NewContext(context.Background(), Registry{"cmdb": &owners{}})
+	for _, query := range []string{
+		`external_data({"provider": "other", "keys": ["k"]})`,
+		`external_data({"keys": ["k"]})`,
+		`external_data({"provider": "cmdb", "keys": [1]})`,
+	} {
+		if _, err := rego.New(rego.Query(query)).Eval(ctx); err == nil {
+			t.Errorf("%s: expected error", query)
+		}
+	}
+	if _, err := rego.New(rego.Query(`external_data({"provider": "cmdb", "keys": []})`)).Eval(context.Background()); err == nil {
+		t.Errorf("expected error without registered providers")
+	}
+}
+
+func TestHTTPProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req httpRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		values := map[string]interface{}{}
+		for _, key := range req.Keys {
+			if key == "fail" {
+				http.Error(w, "backend down", http.StatusServiceUnavailable)
+				return
+			}
+			if key != "unknown" {
+				values[key] = strings.ToUpper(key)
+			}
+		}
+		_ = json.NewEncoder(w).Encode(&httpResponse{Values: values})
+	}))
+	defer server.Close()
+
+	registry, err := ParseHTTPProviders("allowlist="+server.URL, server.Client())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err := registry["allowlist"].Fetch(context.Background(), []string{"a", "unknown"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if diff := cmp.Diff(map[string]interface{}{"a": "A"}, got); diff != "" {
+		t.Errorf("unexpected values (-want +got):\n%s", diff)
+	}
+	if _, err := registry["allowlist"].Fetch(context.Background(), []string{"fail"}); err == nil || !strings.Contains(err.Error(), "backend down") {
+		t.Errorf("got error %v, want server error", err)
+	}
+}
+
+func TestParseHTTPProviders(t *testing.T) {
+	registry, err := ParseHTTPProviders(" cmdb=https://cmdb.example.com/lookup, allowlist=http://localhost:8080 ", http.DefaultClient)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(registry) != 2 || registry["cmdb"] == nil || registry["allowlist"] == nil {
+		t.Errorf("got registry %v, want cmdb and allowlist", registry)
+	}
+	for _, invalid := range []string{"cmdb", "=http://x", "cmdb=", "a=http://x,a=http://y"} {
+		if _, err := ParseHTTPProviders(invalid, http.DefaultClient); err == nil {
+			t.Errorf("%q: expected error", invalid)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package externaldata
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// HTTPProvider looks up keys by posting them to a URL as
+//
+//   {"keys": ["projects/p", "projects/q"]}
+//
+// and expects the values of the keys it knows in the response:
+//
+//   {"values": {"projects/p": {"owner": "alice"}}}
+type HTTPProvider struct {
+	url    string
+	client *http.Client
+}
+
+var _ Provider = &HTTPProvider{}
+
+// NewHTTPProvider returns a provider posting keys to url with client.
+func NewHTTPProvider(url string, client *http.Client) *HTTPProvider {
+	return &HTTPProvider{url: url, client: client}
+}
+
+type httpRequest struct {
+	Keys []string `json:"keys"`
+}
+
+type httpResponse struct {
+	Values map[string]interface{} `json:"values"`
+}
+
+// Fetch implements Provider.
+func (p *HTTPProvider) Fetch(ctx context.Context, keys []string) (map[string]interface{}, error) {
+	body, err := json.Marshal(&httpRequest{Keys: keys})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := p.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return nil, errors.Errorf("%s returned %s: %s", p.url, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	var response httpResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, errors.Wrapf(err, "invalid response from %s", p.url)
+	}
+	return response.Values, nil
+}
+
+// defaultTimeout bounds calls to HTTP providers created without a client.
+const defaultTimeout = 30 * time.Second
+
+// ParseHTTPProviders parses a comma separated list of name=url pairs, e.g.
+// "cmdb=https://cmdb.example.com/lookup", into a registry of HTTP providers using client. A nil
+// client times out calls after 30 seconds.
+func ParseHTTPProviders(s string, client *http.Client) (Registry, error) {
+	if client == nil {
+		client = &http.Client{Timeout: defaultTimeout}
+	}
+	registry := Registry{}
+	if strings.TrimSpace(s) == "" {
+		return registry, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		parts := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, errors.Errorf("invalid data provider %q, want name=url", pair)
+		}
+		if _, ok := registry[parts[0]]; ok {
+			return nil, errors.Errorf("duplicate data provider %q", parts[0])
+		}
+		registry[parts[0]] = NewHTTPProvider(parts[1], client)
+	}
+	return registry, nil
+}
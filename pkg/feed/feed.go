@@ -0,0 +1,193 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package feed reviews Cloud Asset Inventory feed notifications received over Pub/Sub and
+// publishes the resulting violations, turning the validator into a continuous detection pipeline.
+package feed
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"time"
+
+	"github.com/forseti-security/config-validator/pkg/api/validator"
+	"github.com/forseti-security/config-validator/pkg/asset"
+	"github.com/forseti-security/config-validator/pkg/gcv"
+	"github.com/forseti-security/config-validator/pkg/logging"
+	"github.com/forseti-security/config-validator/pkg/notify"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	pubsub "google.golang.org/api/pubsub/v1"
+)
+
+// Subscription is the source of feed notifications.
+type Subscription interface {
+	// Pull returns up to max messages, blocking until at least one is available or ctx is done.
+	Pull(ctx context.Context, max int) ([]*pubsub.ReceivedMessage, error)
+	// Ack acknowledges the messages with the given ack IDs.
+	Ack(ctx context.Context, ackIDs []string) error
+}
+
+// Topic is the destination for violations.
+type Topic interface {
+	Publish(ctx context.Context, messages []*pubsub.PubsubMessage) error
+}
+
+// notification is a CAI feed notification, see
+// https://cloud.google.com/asset-inventory/docs/monitoring-asset-changes.
+type notification struct {
+	Asset   json.RawMessage `json:"asset"`
+	Deleted bool            `json:"deleted"`
+}
+
+// Processor reviews feed notifications and publishes violations.
+type Processor struct {
+	validator    gcv.ConfigValidator
+	subscription Subscription
+	topic        Topic
+	// BatchSize is the maximum number of notifications pulled at once.
+	BatchSize int
+	// RetryDelay is the time waited after a failed pull before pulling again.
+	RetryDelay time.Duration
+	// Notifier, if set, is told about the published violations. Failed notifications are logged
+	// and do not cause redelivery.
+	Notifier notify.Notifier
+}
+
+// NewProcessor returns a Processor reviewing notifications from subscription with v and
+// publishing violations to topic.
+func NewProcessor(v gcv.ConfigValidator, subscription Subscription, topic Topic) *Processor {
+	return &Processor{
+		validator:    v,
+		subscription: subscription,
+		topic:        topic,
+		BatchSize:    100,
+		RetryDelay:   5 * time.Second,
+	}
+}
+
+// Run processes notifications until ctx is done, returning ctx.Err().
+func (p *Processor) Run(ctx context.Context) error {
+	for {
+		if err := p.ProcessBatch(ctx); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			logging.FromContext(ctx).Error("feed processing failed, retrying", zap.Duration("delay", p.RetryDelay), zap.Error(err))
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(p.RetryDelay):
+			}
+		}
+	}
+}
+
+// ProcessBatch pulls, reviews and acknowledges a single batch of notifications. Notifications
+// whose violations could not be published are not acknowledged and will be redelivered.
+// Notifications that cannot be decoded or reviewed are logged and acknowledged, since
+// redelivering them would fail again.
+func (p *Processor) ProcessBatch(ctx context.Context) error {
+	ctx = logging.WithRunID(ctx, logging.NewRunID())
+	messages, err := p.subscription.Pull(ctx, p.BatchSize)
+	if err != nil {
+		return errors.Wrapf(err, "failed to pull notifications")
+	}
+
+	var ackIDs []string
+	for _, message := range messages {
+		violations, err := p.review(ctx, message.Message)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			logging.FromContext(ctx).Error("dropping notification", zap.String("notification", message.Message.MessageId), zap.Error(err))
+			ackIDs = append(ackIDs, message.AckId)
+			continue
+		}
+		if len(violations) != 0 {
+			output, err := toMessages(violations)
+			if err != nil {
+				return err
+			}
+			if err := p.topic.Publish(ctx, output); err != nil {
+				logging.FromContext(ctx).Error("failed to publish violations", zap.String("notification", message.Message.MessageId), zap.Error(err))
+				continue
+			}
+			if p.Notifier != nil {
+				if err := p.Notifier.Notify(ctx, violations); err != nil {
+					logging.FromContext(ctx).Error("failed to notify violations", zap.String("notification", message.Message.MessageId), zap.Error(err))
+				}
+			}
+		}
+		ackIDs = append(ackIDs, message.AckId)
+	}
+
+	if len(ackIDs) == 0 {
+		return nil
+	}
+	return errors.Wrapf(p.subscription.Ack(ctx, ackIDs), "failed to acknowledge notifications")
+}
+
+// review returns the violations for the asset in a feed notification. Deleted assets have no
+// violations.
+func (p *Processor) review(ctx context.Context, message *pubsub.PubsubMessage) ([]*validator.Violation, error) {
+	data, err := base64.StdEncoding.DecodeString(message.Data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid message data")
+	}
+	var n notification
+	if err := json.Unmarshal(data, &n); err != nil {
+		return nil, errors.Wrapf(err, "invalid notification")
+	}
+	if n.Deleted || len(n.Asset) == 0 {
+		return nil, nil
+	}
+
+	pbAsset := &validator.Asset{}
+	unmarshaler := &jsonpb.Unmarshaler{AllowUnknownFields: true}
+	if err := unmarshaler.Unmarshal(bytes.NewReader(n.Asset), pbAsset); err != nil {
+		return nil, errors.Wrapf(err, "invalid asset in notification")
+	}
+	if pbAsset.AncestryPath == "" && len(pbAsset.Ancestors) != 0 {
+		pbAsset.AncestryPath = asset.AncestryPath(pbAsset.Ancestors)
+	}
+	return p.validator.ReviewAsset(ctx, pbAsset)
+}
+
+// toMessages encodes each violation as a JSON message with its constraint and resource as
+// attributes, so subscribers can filter without decoding the payload.
+func toMessages(violations []*validator.Violation) ([]*pubsub.PubsubMessage, error) {
+	marshaler := &jsonpb.Marshaler{OrigName: true}
+	var messages []*pubsub.PubsubMessage
+	for _, v := range violations {
+		payload, err := marshaler.MarshalToString(v)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to marshal violation")
+		}
+		messages = append(messages, &pubsub.PubsubMessage{
+			Data: base64.StdEncoding.EncodeToString([]byte(payload)),
+			Attributes: map[string]string{
+				"constraint":     v.Constraint,
+				"resource":       v.Resource,
+				"severity":       v.Severity,
+				"policy_version": v.PolicyVersion,
+			},
+		})
+	}
+	return messages, nil
+}
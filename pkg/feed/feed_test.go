@@ -0,0 +1,213 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package feed
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"github.com/forseti-security/config-validator/pkg/api/validator"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	pubsub "google.golang.org/api/pubsub/v1"
+)
+
+type fakeSubscription struct {
+	messages []*pubsub.ReceivedMessage
+	acked    []string
+}
+
+func (s *fakeSubscription) Pull(ctx context.Context, max int) ([]*pubsub.ReceivedMessage, error) {
+	if len(s.messages) == 0 {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	if max > len(s.messages) {
+		max = len(s.messages)
+	}
+	batch := s.messages[:max]
+	s.messages = s.messages[max:]
+	return batch, nil
+}
+
+func (s *fakeSubscription) Ack(ctx context.Context, ackIDs []string) error {
+	s.acked = append(s.acked, ackIDs...)
+	return nil
+}
+
+type fakeTopic struct {
+	published []*pubsub.PubsubMessage
+	err       error
+}
+
+func (t *fakeTopic) Publish(ctx context.Context, messages []*pubsub.PubsubMessage) error {
+	if t.err != nil {
+		return t.err
+	}
+	t.published = append(t.published, messages...)
+	return nil
+}
+
+// fakeValidator reports one violation for every asset named in violating.
+type fakeValidator struct {
+	violating map[string]bool
+	reviewed  []*validator.Asset
+}
+
+func (v *fakeValidator) ReviewAsset(ctx context.Context, asset *validator.Asset) ([]*validator.Violation, error) {
+	v.reviewed = append(v.reviewed, asset)
+	if asset.AncestryPath == "" {
+		return nil, errors.Errorf("missing ancestry path")
+	}
+	if !v.violating[asset.Name] {
+		return nil, nil
+	}
+	return []*validator.Violation{{
+		Constraint: "GCPStorageLoggingConstraint.require-storage-logging",
+		Resource:   asset.Name,
+		Message:    "no logging",
+		Severity:   "high",
+	}}, nil
+}
+
+func feedMessage(t *testing.T, ackID string, notification map[string]interface{}) *pubsub.ReceivedMessage {
+	data, err := json.Marshal(notification)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &pubsub.ReceivedMessage{
+		AckId:   ackID,
+		Message: &pubsub.PubsubMessage{MessageId: ackID, Data: base64.StdEncoding.EncodeToString(data)},
+	}
+}
+
+func bucketNotification(name string, deleted bool) map[string]interface{} {
+	return map[string]interface{}{
+		"asset": map[string]interface{}{
+			"name":       name,
+			"assetType":  "storage.googleapis.com/Bucket",
+			"ancestors":  []string{"projects/3", "organizations/1"},
+			"updateTime": "2020-04-01T12:00:00Z",
+			"resource": map[string]interface{}{
+				"version": "v1",
+				"data":    map[string]interface{}{"name": name},
+			},
+		},
+		"window":  map[string]interface{}{"startTime": "2020-04-01T12:00:00Z"},
+		"deleted": deleted,
+	}
+}
+
+func TestProcessBatch(t *testing.T) {
+	subscription := &fakeSubscription{messages: []*pubsub.ReceivedMessage{
+		feedMessage(t, "violating", bucketNotification("//storage.googleapis.com/bad", false)),
+		feedMessage(t, "compliant", bucketNotification("//storage.googleapis.com/good", false)),
+		feedMessage(t, "deleted", bucketNotification("//storage.googleapis.com/bad", true)),
+		{AckId: "garbage", Message: &pubsub.PubsubMessage{Data: "not base64!"}},
+	}}
+	topic := &fakeTopic{}
+	v := &fakeValidator{violating: map[string]bool{"//storage.googleapis.com/bad": true}}
+	p := NewProcessor(v, subscription, topic)
+
+	if err := p.ProcessBatch(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if diff := cmp.Diff(subscription.acked, []string{"violating", "compliant", "deleted", "garbage"}); diff != "" {
+		t.Errorf("acked mismatch, +got -want\n%s", diff)
+	}
+	if len(v.reviewed) != 2 {
+		t.Fatalf("got %d reviews, want 2", len(v.reviewed))
+	}
+	if v.reviewed[0].AncestryPath != "organizations/1/projects/3" || v.reviewed[0].AssetType != "storage.googleapis.com/Bucket" {
+		t.Errorf("unexpected reviewed asset %v", v.reviewed[0])
+	}
+	if len(topic.published) != 1 {
+		t.Fatalf("got %d published violations, want 1", len(topic.published))
+	}
+	published := topic.published[0]
+	if published.Attributes["resource"] != "//storage.googleapis.com/bad" || published.Attributes["severity"] != "high" {
+		t.Errorf("unexpected attributes %v", published.Attributes)
+	}
+	payload, err := base64.StdEncoding.DecodeString(published.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	violation := map[string]interface{}{}
+	if err := json.Unmarshal(payload, &violation); err != nil {
+		t.Fatal(err)
+	}
+	if violation["message"] != "no logging" {
+		t.Errorf("unexpected violation payload %s", payload)
+	}
+}
+
+func TestProcessBatchPublishFailure(t *testing.T) {
+	subscription := &fakeSubscription{messages: []*pubsub.ReceivedMessage{
+		feedMessage(t, "violating", bucketNotification("//storage.googleapis.com/bad", false)),
+	}}
+	topic := &fakeTopic{err: errors.Errorf("unavailable")}
+	v := &fakeValidator{violating: map[string]bool{"//storage.googleapis.com/bad": true}}
+	if err := NewProcessor(v, subscription, topic).ProcessBatch(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(subscription.acked) != 0 {
+		t.Errorf("notification should be redelivered when publishing fails, acked %v", subscription.acked)
+	}
+}
+
+func TestRunCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	p := NewProcessor(&fakeValidator{}, &fakeSubscription{}, &fakeTopic{})
+	if err := p.Run(ctx); err != context.Canceled {
+		t.Errorf("got %v, want context.Canceled", err)
+	}
+}
+
+// fakeNotifier records the resources it is notified about and then fails with err, if set.
+type fakeNotifier struct {
+	notified []string
+	err      error
+}
+
+func (n *fakeNotifier) Notify(ctx context.Context, violations []*validator.Violation) error {
+	for _, v := range violations {
+		n.notified = append(n.notified, v.Resource)
+	}
+	return n.err
+}
+
+func TestProcessBatchNotifies(t *testing.T) {
+	subscription := &fakeSubscription{messages: []*pubsub.ReceivedMessage{
+		feedMessage(t, "violating", bucketNotification("//storage.googleapis.com/bad", false)),
+		feedMessage(t, "compliant", bucketNotification("//storage.googleapis.com/good", false)),
+	}}
+	v := &fakeValidator{violating: map[string]bool{"//storage.googleapis.com/bad": true}}
+	notifier := &fakeNotifier{err: errors.Errorf("webhook unavailable")}
+	p := NewProcessor(v, subscription, &fakeTopic{})
+	p.Notifier = notifier
+	if err := p.ProcessBatch(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if diff := cmp.Diff([]string{"//storage.googleapis.com/bad"}, notifier.notified); diff != "" {
+		t.Errorf("unexpected notifications (-want +got):\n%s", diff)
+	}
+	// Failed notifications do not cause redelivery.
+	if diff := cmp.Diff([]string{"violating", "compliant"}, subscription.acked); diff != "" {
+		t.Errorf("unexpected acks (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package feed
+
+import (
+	"context"
+
+	pubsub "google.golang.org/api/pubsub/v1"
+)
+
+// pubsubSubscription is a Subscription backed by the Pub/Sub REST API.
+type pubsubSubscription struct {
+	service *pubsub.Service
+	name    string
+}
+
+// NewSubscription returns a Subscription for the subscription with the full resource name
+// projects/<project>/subscriptions/<subscription>.
+func NewSubscription(service *pubsub.Service, name string) Subscription {
+	return &pubsubSubscription{service: service, name: name}
+}
+
+func (s *pubsubSubscription) Pull(ctx context.Context, max int) ([]*pubsub.ReceivedMessage, error) {
+	response, err := s.service.Projects.Subscriptions.Pull(s.name, &pubsub.PullRequest{
+		MaxMessages: int64(max),
+	}).Context(ctx).Do()
+	if err != nil {
+		return nil, err
+	}
+	return response.ReceivedMessages, nil
+}
+
+func (s *pubsubSubscription) Ack(ctx context.Context, ackIDs []string) error {
+	_, err := s.service.Projects.Subscriptions.Acknowledge(s.name, &pubsub.AcknowledgeRequest{
+		AckIds: ackIDs,
+	}).Context(ctx).Do()
+	return err
+}
+
+// pubsubTopic is a Topic backed by the Pub/Sub REST API.
+type pubsubTopic struct {
+	service *pubsub.Service
+	name    string
+}
+
+// NewTopic returns a Topic for the topic with the full resource name
+// projects/<project>/topics/<topic>.
+func NewTopic(service *pubsub.Service, name string) Topic {
+	return &pubsubTopic{service: service, name: name}
+}
+
+func (t *pubsubTopic) Publish(ctx context.Context, messages []*pubsub.PubsubMessage) error {
+	_, err := t.service.Projects.Topics.Publish(t.name, &pubsub.PublishRequest{
+		Messages: messages,
+	}).Context(ctx).Do()
+	return err
+}
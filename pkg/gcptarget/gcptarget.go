@@ -65,6 +65,47 @@ func (g *GCPTarget) MatchSchema() apiextensions.JSONSchemaProps {
 					},
 				},
 			},
+			"ancestries": {
+				Type: "array",
+				Items: &apiextensions.JSONSchemaPropsOrArray{
+					Schema: &apiextensions.JSONSchemaProps{
+						Type: "string",
+					},
+				},
+			},
+			"excludedAncestries": {
+				Type: "array",
+				Items: &apiextensions.JSONSchemaPropsOrArray{
+					Schema: &apiextensions.JSONSchemaProps{
+						Type: "string",
+					},
+				},
+			},
+			"nameRegexes": {
+				Type: "array",
+				Items: &apiextensions.JSONSchemaPropsOrArray{
+					Schema: &apiextensions.JSONSchemaProps{
+						Type: "string",
+					},
+				},
+			},
+			"excludedNameRegexes": {
+				Type: "array",
+				Items: &apiextensions.JSONSchemaPropsOrArray{
+					Schema: &apiextensions.JSONSchemaProps{
+						Type: "string",
+					},
+				},
+			},
+			"labels": {
+				Type: "object",
+				AdditionalProperties: &apiextensions.JSONSchemaPropsOrBool{
+					Allows: true,
+					Schema: &apiextensions.JSONSchemaProps{
+						Type: "string",
+					},
+				},
+			},
 		},
 	}
 }
@@ -79,9 +120,53 @@ func (g *GCPTarget) Library() *template.Template {
 	return libraryTemplate
 }
 
-// ProcessData implements client.TargetHandler
+// Inventory holds the assets of a review transaction, which templates can reference as
+// data.inventory[asset_type][name]. Records with the same type and name, such as a resource and
+// its IAM policy, are merged into one asset.
+type Inventory map[string]map[string]map[string]interface{}
+
+// Add adds asset to the inventory.
+func (i Inventory) Add(asset map[string]interface{}) error {
+	name, found, err := unstructured.NestedString(asset, "name")
+	if !found || err != nil {
+		return errors.Errorf("inventory asset has no name")
+	}
+	assetType, found, err := unstructured.NestedString(asset, "asset_type")
+	if !found || err != nil {
+		return errors.Errorf("inventory asset %s has no asset_type", name)
+	}
+	byName, found := i[assetType]
+	if !found {
+		byName = map[string]map[string]interface{}{}
+		i[assetType] = byName
+	}
+	merged, found := byName[name]
+	if !found {
+		merged = map[string]interface{}{}
+		byName[name] = merged
+	}
+	for k, v := range asset {
+		merged[k] = v
+	}
+	return nil
+}
+
+// ProcessData implements client.TargetHandler. Only an Inventory can be stored, it replaces
+// data.inventory as a whole.
 func (g *GCPTarget) ProcessData(obj interface{}) (bool, string, interface{}, error) {
-	return false, "", nil, errors.Errorf("Storing data for referential constraint eval is not supported at this time.")
+	inventory, ok := obj.(Inventory)
+	if !ok {
+		return false, "", nil, errors.Errorf("unsupported data type %T, only gcptarget.Inventory can be stored", obj)
+	}
+	data := map[string]interface{}{}
+	for assetType, byName := range inventory {
+		assets := map[string]interface{}{}
+		for name, asset := range byName {
+			assets[name] = asset
+		}
+		data[assetType] = assets
+	}
+	return true, "", data, nil
 }
 
 // HandleReview implements client.TargetHandler
@@ -107,9 +192,10 @@ func (g *GCPTarget) HandleReview(obj interface{}) (bool, interface{}, error) {
 		if err != nil {
 			return false, nil, err
 		}
-		foundOrgPolicy := false
-		if asset["org_policy"] != nil {
-			foundOrgPolicy = true
+		// CAI exports org policies as a list of the policies set on the resource.
+		_, foundOrgPolicy, err := unstructured.NestedSlice(asset, "org_policy")
+		if err != nil {
+			return false, nil, err
 		}
 		_, foundAccessPolicy, err := unstructured.NestedMap(asset, "access_policy")
 		if err != nil {
@@ -154,12 +240,42 @@ func (g *GCPTarget) HandleReview(obj interface{}) (bool, interface{}, error) {
 	return false, nil, nil
 }
 
+// labelPaths are the fields resource labels are read from, as asset types don't agree on where
+// to put them. Later paths take precedence. The target library reads labels the same way.
+var labelPaths = [][]string{
+	{"resource", "data", "labels"},
+	// Cloud SQL instances
+	{"resource", "data", "settings", "userLabels"},
+	// GKE clusters
+	{"resource", "data", "resourceLabels"},
+}
+
+// Labels returns the labels of the resource of asset, empty if it has none. Labels are read
+// from wherever the asset type stores them, and values that are not strings are ignored.
+func Labels(asset map[string]interface{}) map[string]string {
+	labels := map[string]string{}
+	for _, path := range labelPaths {
+		m, found, err := unstructured.NestedMap(asset, path...)
+		if !found || err != nil {
+			continue
+		}
+		for k, v := range m {
+			if s, ok := v.(string); ok {
+				labels[k] = s
+			}
+		}
+	}
+	return labels
+}
+
 // handleAsset handles input from FCV assets as received via the gRPC interface.
 func (g *GCPTarget) handleAsset(asset *validator.Asset) (bool, interface{}, error) {
-	if asset.Resource == nil {
-		return false, nil, errors.Errorf("forseti asset's resource field is nil %s", asset)
+	if asset.Resource == nil && asset.IamPolicy == nil && len(asset.OrgPolicy) == 0 && asset.AccessContextPolicy == nil {
+		return false, nil, errors.Errorf("forseti asset has none of resource, iam policy, org policy, access context policy %s", asset)
+	}
+	if asset.Resource != nil {
+		asset2.CleanStructValue(asset.Resource.Data)
 	}
-	asset2.CleanStructValue(asset.Resource.Data)
 	m := &jsonpb.Marshaler{
 		OrigName: true,
 	}
@@ -167,7 +283,7 @@ func (g *GCPTarget) handleAsset(asset *validator.Asset) (bool, interface{}, erro
 	if err := m.Marshal(&buf, asset); err != nil {
 		return false, nil, errors.Wrapf(err, "marshalling to json with asset %s: %v", asset.Name, asset)
 	}
-	var f interface{}
+	var f map[string]interface{}
 	err := json.Unmarshal(buf.Bytes(), &f)
 	if err != nil {
 		return false, nil, errors.Wrapf(err, "marshalling from json with asset %s: %v", asset.Name, asset)
@@ -252,23 +368,6 @@ func checkPathGlobs(rs []string) error {
 
 // ValidateConstraint implements client.TargetHandler
 func (g *GCPTarget) ValidateConstraint(constraint *unstructured.Unstructured) error {
-	targets, found, err := unstructured.NestedStringSlice(constraint.Object, "spec", "match", "target")
-	if err != nil {
-		return errors.Errorf("invalid spec.match.target: %s", err)
-	}
-	if found {
-		if err := checkPathGlobs(targets); err != nil {
-			return errors.Wrapf(err, "invalid glob in target")
-		}
-	}
-	excludes, found, err := unstructured.NestedStringSlice(constraint.Object, "spec", "match", "exclude")
-	if err != nil {
-		return errors.Errorf("invalid spec.match.exclude: %s", err)
-	}
-	if found {
-		if err := checkPathGlobs(excludes); err != nil {
-			return errors.Wrapf(err, "invalid glob in exclude")
-		}
-	}
-	return nil
+	_, err := NewScope(constraint)
+	return err
 }
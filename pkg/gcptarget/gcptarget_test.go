@@ -15,13 +15,18 @@
 package gcptarget
 
 import (
+	"encoding/json"
 	"fmt"
 	"testing"
 
 	"github.com/forseti-security/config-validator/pkg/api/validator"
 	gcptest "github.com/forseti-security/config-validator/pkg/gcptarget/testing"
+	structpb "github.com/golang/protobuf/ptypes/struct"
+	"github.com/google/go-cmp/cmp"
 	"github.com/open-policy-agent/frameworks/constraint/pkg/client"
 	v1 "google.golang.org/genproto/googleapis/cloud/asset/v1"
+	orgpolicy "google.golang.org/genproto/googleapis/cloud/orgpolicy/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
 // match creates a match struct as would exist in a FCV constraint
@@ -55,12 +60,62 @@ func exclude(excludes ...string) func(map[string]interface{}) {
 	}
 }
 
-// forsetiAsset creates an FCV asset with the given ancestry path.
-func forsetiAsset(ancestryPath string) func(t *testing.T) interface{} {
+// ancestries populates the ancestries field inside of the match block
+func ancestries(paths ...string) func(map[string]interface{}) {
+	return func(matchBlock map[string]interface{}) {
+		matchBlock["ancestries"] = stringToInterface(paths)
+	}
+}
+
+// excludedAncestries populates the excludedAncestries field inside of the match block
+func excludedAncestries(paths ...string) func(map[string]interface{}) {
+	return func(matchBlock map[string]interface{}) {
+		matchBlock["excludedAncestries"] = stringToInterface(paths)
+	}
+}
+
+// labels populates the labels field inside of the match block
+func labels(kv ...string) func(map[string]interface{}) {
+	return func(matchBlock map[string]interface{}) {
+		m := map[string]interface{}{}
+		for i := 0; i+1 < len(kv); i += 2 {
+			m[kv[i]] = kv[i+1]
+		}
+		matchBlock["labels"] = m
+	}
+}
+
+// nameRegexes populates the nameRegexes field inside of the match block
+func nameRegexes(exprs ...string) func(map[string]interface{}) {
+	return func(matchBlock map[string]interface{}) {
+		matchBlock["nameRegexes"] = stringToInterface(exprs)
+	}
+}
+
+// excludedNameRegexes populates the excludedNameRegexes field inside of the match block
+func excludedNameRegexes(exprs ...string) func(map[string]interface{}) {
+	return func(matchBlock map[string]interface{}) {
+		matchBlock["excludedNameRegexes"] = stringToInterface(exprs)
+	}
+}
+
+// forsetiAsset creates an FCV asset with the given name, ancestry path and resource labels.
+func forsetiAsset(name, ancestryPath string, labels map[string]string) func(t *testing.T) interface{} {
 	return func(t *testing.T) interface{} {
+		resource := &v1.Resource{}
+		if labels != nil {
+			fields := map[string]*structpb.Value{}
+			for k, v := range labels {
+				fields[k] = &structpb.Value{Kind: &structpb.Value_StringValue{StringValue: v}}
+			}
+			resource.Data = &structpb.Struct{Fields: map[string]*structpb.Value{
+				"labels": {Kind: &structpb.Value_StructValue{StructValue: &structpb.Struct{Fields: fields}}},
+			}}
+		}
 		return &validator.Asset{
+			Name:         name,
 			AncestryPath: ancestryPath,
-			Resource:     &v1.Resource{},
+			Resource:     resource,
 		}
 	}
 }
@@ -70,7 +125,9 @@ func forsetiAsset(ancestryPath string) func(t *testing.T) interface{} {
 type reviewTestData struct {
 	name                string
 	match               map[string]interface{}
+	assetName           string
 	ancestryPath        string
+	labels              map[string]string
 	wantMatch           bool
 	wantConstraintError bool
 }
@@ -88,22 +145,38 @@ func (td *reviewTestData) assetTest(nameMod string) *gcptest.ReviewTestcase {
 	return tc
 }
 
+// resourceName returns the name of the test asset.
+func (td *reviewTestData) resourceName() string {
+	if td.assetName == "" {
+		return "test-name"
+	}
+	return td.assetName
+}
+
 func (td *reviewTestData) jsonAssetTestcase() *gcptest.ReviewTestcase {
 	assetTest := td.assetTest("json")
+	resource := "{}"
+	if td.labels != nil {
+		labels, err := json.Marshal(td.labels)
+		if err != nil {
+			panic(err)
+		}
+		resource = fmt.Sprintf(`{"data": {"labels": %s}}`, labels)
+	}
 	assetTest.Object = gcptest.FromJSON(fmt.Sprintf(`
 {
-  "name": "test-name",
+  "name": "%s",
   "asset_type": "test-asset-type",
   "ancestry_path": "%s",
-  "resource": {}
+  "resource": %s
 }
-`, td.ancestryPath))
+`, td.resourceName(), td.ancestryPath, resource))
 	return assetTest
 }
 
 func (td *reviewTestData) forsetiAssetTestcase() *gcptest.ReviewTestcase {
 	assetTest := td.assetTest("forseti")
-	assetTest.Object = forsetiAsset(td.ancestryPath)
+	assetTest.Object = forsetiAsset(td.resourceName(), td.ancestryPath, td.labels)
 	return assetTest
 }
 
@@ -239,6 +312,138 @@ var testData = []reviewTestData{
 		ancestryPath: "organizations/123454321/projects/557385378",
 		wantMatch:    false,
 	},
+	{
+		name:         "Match ancestry",
+		match:        match(ancestries("organizations/123454321/folders/1221214/**")),
+		ancestryPath: "organizations/123454321/folders/1221214/projects/557385378",
+		wantMatch:    true,
+	},
+	{
+		name:         "Does not match outside of ancestries",
+		match:        match(ancestries("organizations/123454321/folders/1221214/**")),
+		ancestryPath: "organizations/123454321/folders/1221215/projects/557385378",
+		wantMatch:    false,
+	},
+	{
+		name:         "Match ancestry or target",
+		match:        match(target("**/folders/1221215/**"), ancestries("**/folders/1221214/**")),
+		ancestryPath: "organizations/123454321/folders/1221215/projects/557385378",
+		wantMatch:    true,
+	},
+	{
+		name:         "Exclude ancestry",
+		match:        match(excludedAncestries("**/folders/1221214/**")),
+		ancestryPath: "organizations/123454321/folders/1221214/projects/557385378",
+		wantMatch:    false,
+	},
+	{
+		name:         "Exclude ancestry within ancestries",
+		match:        match(ancestries("organizations/123454321/**"), excludedAncestries("**/folders/1221214/**")),
+		ancestryPath: "organizations/123454321/folders/1221214/projects/557385378",
+		wantMatch:    false,
+	},
+	{
+		name:         "Match ancestry outside of excluded ancestries",
+		match:        match(ancestries("organizations/123454321/**"), excludedAncestries("**/folders/1221214/**")),
+		ancestryPath: "organizations/123454321/folders/1221215/projects/557385378",
+		wantMatch:    true,
+	},
+	{
+		name:         "Match labels",
+		match:        match(labels("env", "prod", "team", "a")),
+		ancestryPath: "organizations/123454321/projects/557385378",
+		labels:       map[string]string{"env": "prod", "team": "a", "tier": "web"},
+		wantMatch:    true,
+	},
+	{
+		name:         "Does not match label value",
+		match:        match(labels("env", "prod")),
+		ancestryPath: "organizations/123454321/projects/557385378",
+		labels:       map[string]string{"env": "sandbox"},
+		wantMatch:    false,
+	},
+	{
+		name:         "Does not match missing label",
+		match:        match(labels("env", "prod", "team", "a")),
+		ancestryPath: "organizations/123454321/projects/557385378",
+		labels:       map[string]string{"env": "prod"},
+		wantMatch:    false,
+	},
+	{
+		name:         "Does not match without labels",
+		match:        match(labels("env", "prod")),
+		ancestryPath: "organizations/123454321/projects/557385378",
+		wantMatch:    false,
+	},
+	{
+		name:         "Does not match labels outside of ancestries",
+		match:        match(ancestries("**/folders/1221214/**"), labels("env", "prod")),
+		ancestryPath: "organizations/123454321/folders/1221215/projects/557385378",
+		labels:       map[string]string{"env": "prod"},
+		wantMatch:    false,
+	},
+	{
+		name:         "Match name regex",
+		match:        match(nameRegexes("projects/team-a-.*")),
+		assetName:    "//cloudresourcemanager.googleapis.com/projects/team-a-prod",
+		ancestryPath: "organizations/123454321/projects/557385378",
+		wantMatch:    true,
+	},
+	{
+		name:         "Match name regex multiple",
+		match:        match(nameRegexes("projects/team-b-.*", "projects/team-a-.*")),
+		assetName:    "//cloudresourcemanager.googleapis.com/projects/team-a-prod",
+		ancestryPath: "organizations/123454321/projects/557385378",
+		wantMatch:    true,
+	},
+	{
+		name:         "Does not match name regex",
+		match:        match(nameRegexes("projects/team-a-.*")),
+		assetName:    "//cloudresourcemanager.googleapis.com/projects/team-b-prod",
+		ancestryPath: "organizations/123454321/projects/557385378",
+		wantMatch:    false,
+	},
+	{
+		name:         "Does not match anchored name regex",
+		match:        match(nameRegexes("^projects/team-a-.*")),
+		assetName:    "//cloudresourcemanager.googleapis.com/projects/team-a-prod",
+		ancestryPath: "organizations/123454321/projects/557385378",
+		wantMatch:    false,
+	},
+	{
+		name:         "Exclude name regex",
+		match:        match(nameRegexes("projects/team-a-.*"), excludedNameRegexes("-sandbox$")),
+		assetName:    "//cloudresourcemanager.googleapis.com/projects/team-a-sandbox",
+		ancestryPath: "organizations/123454321/projects/557385378",
+		wantMatch:    false,
+	},
+	{
+		name:                "invalid name regex",
+		match:               match(nameRegexes("projects/(team-a")),
+		wantConstraintError: true,
+	},
+	{
+		name:                "invalid excluded name regex",
+		match:               match(excludedNameRegexes("[")),
+		wantConstraintError: true,
+	},
+	{
+		name: "Bad labels type",
+		match: map[string]interface{}{
+			"labels": []interface{}{"env"},
+		},
+		wantConstraintError: true,
+	},
+	{
+		name:                "invalid ancestries CRM type",
+		match:               match(ancestries("flubber/*")),
+		wantConstraintError: true,
+	},
+	{
+		name:                "invalid excluded ancestries CRM type",
+		match:               match(excludedAncestries("projects/123/folders/123")),
+		wantConstraintError: true,
+	},
 	{
 		name:                "invalid target CRM type",
 		match:               match(target("flubber/*")),
@@ -310,3 +515,286 @@ func TestTargetHandler(t *testing.T) {
 	}
 	targetHandlerTest.Test(t)
 }
+
+// TestTargetHandlerLabelFields checks that the target library reads labels from the same fields
+// as Labels, without the asset carrying them.
+func TestTargetHandlerLabelFields(t *testing.T) {
+	var testCases = []struct {
+		name      string
+		resource  string
+		wantMatch bool
+	}{
+		{
+			name:      "Cloud SQL user labels",
+			resource:  `{"data": {"settings": {"userLabels": {"env": "prod"}}}}`,
+			wantMatch: true,
+		},
+		{
+			name:      "GKE resource labels",
+			resource:  `{"data": {"resourceLabels": {"env": "prod"}}}`,
+			wantMatch: true,
+		},
+		{
+			name:     "GKE resource labels take precedence",
+			resource: `{"data": {"labels": {"env": "prod"}, "resourceLabels": {"env": "sandbox"}}}`,
+		},
+		{
+			name:      "non-string labels are ignored",
+			resource:  `{"data": {"labels": {"env": "prod"}, "resourceLabels": {"env": 1}}}`,
+			wantMatch: true,
+		},
+	}
+	targetHandlerTest := gcptest.TargetHandlerTest{
+		NewTargetHandler: func(t *testing.T) client.TargetHandler {
+			return New()
+		},
+	}
+	for _, tc := range testCases {
+		asset := fmt.Sprintf(`{
+  "name": "test-name",
+  "asset_type": "test-asset-type",
+  "ancestry_path": "organizations/123454321/projects/557385378",
+  "resource": %s
+}`, tc.resource)
+		var decoded map[string]interface{}
+		if err := json.Unmarshal([]byte(asset), &decoded); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := Labels(decoded)["env"] == "prod"; got != tc.wantMatch {
+			t.Errorf("%s: Labels got match %v, want %v", tc.name, got, tc.wantMatch)
+		}
+		targetHandlerTest.ReviewTestcases = append(targetHandlerTest.ReviewTestcases, &gcptest.ReviewTestcase{
+			Name:      tc.name,
+			Match:     match(labels("env", "prod")),
+			Object:    gcptest.FromJSON(asset),
+			WantMatch: tc.wantMatch,
+		})
+	}
+	targetHandlerTest.Test(t)
+}
+
+func TestLabels(t *testing.T) {
+	var testCases = []struct {
+		name  string
+		asset string
+		want  map[string]string
+	}{
+		{
+			name:  "resource labels",
+			asset: `{"resource": {"data": {"labels": {"env": "prod", "count": 1}}}}`,
+			want:  map[string]string{"env": "prod"},
+		},
+		{
+			name:  "Cloud SQL user labels",
+			asset: `{"resource": {"data": {"settings": {"userLabels": {"env": "prod"}}}}}`,
+			want:  map[string]string{"env": "prod"},
+		},
+		{
+			name:  "GKE resource labels",
+			asset: `{"resource": {"data": {"resourceLabels": {"env": "prod"}}}}`,
+			want:  map[string]string{"env": "prod"},
+		},
+		{
+			name:  "IAM policy",
+			asset: `{"iam_policy": {"bindings": []}}`,
+			want:  map[string]string{},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var asset map[string]interface{}
+			if err := json.Unmarshal([]byte(tc.asset), &asset); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, Labels(asset)); diff != "" {
+				t.Errorf("unexpected labels (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestScope checks that scopes evaluated by the validator agree with the target library.
+func TestScope(t *testing.T) {
+	for _, tc := range testData {
+		t.Run(tc.name, func(t *testing.T) {
+			constraint := &unstructured.Unstructured{Object: map[string]interface{}{}}
+			if tc.match != nil {
+				constraint.Object["spec"] = map[string]interface{}{"match": tc.match}
+			}
+			scope, err := NewScope(constraint)
+			if (err != nil) != tc.wantConstraintError {
+				t.Fatalf("got error %v, want error %v", err, tc.wantConstraintError)
+			}
+			if err != nil {
+				return
+			}
+			if got := scope.Matches(tc.resourceName(), tc.ancestryPath, tc.labels); got != tc.wantMatch {
+				t.Errorf("got match %v, want %v", got, tc.wantMatch)
+			}
+		})
+	}
+}
+
+func TestScopeInvalidRegex(t *testing.T) {
+	constraint := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{"match": match(nameRegexes("projects/.*", "projects/(team-a"))},
+	}}
+	_, err := NewScope(constraint)
+	want := `invalid regular expression "projects/(team-a" in spec.match.nameRegexes[1]: error parsing regexp: missing closing ): ` + "`projects/(team-a`"
+	if err == nil || err.Error() != want {
+		t.Errorf("got error %v, want %s", err, want)
+	}
+}
+
+func TestScopeDependsOnAncestry(t *testing.T) {
+	var testCases = []struct {
+		name  string
+		match map[string]interface{}
+		want  bool
+	}{
+		{name: "no match block"},
+		{name: "names only", match: match(nameRegexes("projects/.*"))},
+		{name: "whole hierarchy", match: match(target("**"))},
+		{name: "organization", match: match(target("organizations/**")), want: true},
+		{name: "ancestries", match: match(ancestries("organizations/1/**")), want: true},
+		{name: "exclude", match: match(exclude("organizations/1/folders/2/**")), want: true},
+		{name: "excluded ancestries", match: match(excludedAncestries("organizations/1/**")), want: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			constraint := &unstructured.Unstructured{Object: map[string]interface{}{}}
+			if tc.match != nil {
+				constraint.Object["spec"] = map[string]interface{}{"match": tc.match}
+			}
+			scope, err := NewScope(constraint)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := scope.DependsOnAncestry(); got != tc.want {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestHandleReviewPolicyAssets(t *testing.T) {
+	var testCases = []struct {
+		name      string
+		object    func(t *testing.T) interface{}
+		wantMatch bool
+		wantError bool
+	}{
+		{
+			name: "forseti org policy asset",
+			object: func(t *testing.T) interface{} {
+				return &validator.Asset{
+					Name:         "//cloudresourcemanager.googleapis.com/projects/1",
+					AssetType:    "cloudresourcemanager.googleapis.com/Project",
+					AncestryPath: "organizations/2/projects/1",
+					OrgPolicy: []*orgpolicy.Policy{{
+						Constraint: "constraints/compute.vmExternalIpAccess",
+						PolicyType: &orgpolicy.Policy_ListPolicy_{ListPolicy: &orgpolicy.Policy_ListPolicy{
+							AllValues: orgpolicy.Policy_ListPolicy_DENY,
+						}},
+					}},
+				}
+			},
+			wantMatch: true,
+		},
+		{
+			name: "forseti asset without content",
+			object: func(t *testing.T) interface{} {
+				return &validator.Asset{
+					Name:         "//cloudresourcemanager.googleapis.com/projects/1",
+					AssetType:    "cloudresourcemanager.googleapis.com/Project",
+					AncestryPath: "organizations/2/projects/1",
+				}
+			},
+			wantError: true,
+		},
+		{
+			name: "json org policy asset",
+			object: gcptest.FromJSON(`{
+  "name": "//cloudresourcemanager.googleapis.com/projects/1",
+  "asset_type": "cloudresourcemanager.googleapis.com/Project",
+  "ancestry_path": "organizations/2/projects/1",
+  "org_policy": [{"constraint": "constraints/compute.vmExternalIpAccess", "list_policy": {"all_values": "DENY"}}]
+}`),
+			wantMatch: true,
+		},
+		{
+			name: "json org policy not a list",
+			object: gcptest.FromJSON(`{
+  "name": "//cloudresourcemanager.googleapis.com/projects/1",
+  "asset_type": "cloudresourcemanager.googleapis.com/Project",
+  "ancestry_path": "organizations/2/projects/1",
+  "org_policy": {"constraint": "constraints/compute.vmExternalIpAccess"}
+}`),
+			wantError: true,
+		},
+		{
+			name: "json org policy and resource",
+			object: gcptest.FromJSON(`{
+  "name": "//cloudresourcemanager.googleapis.com/projects/1",
+  "asset_type": "cloudresourcemanager.googleapis.com/Project",
+  "ancestry_path": "organizations/2/projects/1",
+  "resource": {},
+  "org_policy": [{"constraint": "constraints/compute.vmExternalIpAccess"}]
+}`),
+			wantError: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			handled, review, err := New().HandleReview(tc.object(t))
+			if tc.wantError {
+				if err == nil {
+					t.Fatalf("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if handled != tc.wantMatch {
+				t.Fatalf("got handled %v, want %v", handled, tc.wantMatch)
+			}
+			policies, found, err := unstructured.NestedSlice(review.(map[string]interface{}), "org_policy")
+			if !found || err != nil || len(policies) != 1 {
+				t.Errorf("got org_policy %v, want one policy", policies)
+			}
+		})
+	}
+}
+
+func TestInventory(t *testing.T) {
+	inventory := Inventory{}
+	for _, asset := range []map[string]interface{}{
+		{"name": "//storage.googleapis.com/b", "asset_type": "storage.googleapis.com/Bucket", "resource": map[string]interface{}{}},
+		{"name": "//storage.googleapis.com/b", "asset_type": "storage.googleapis.com/Bucket", "iam_policy": map[string]interface{}{}},
+		{"name": "//compute.googleapis.com/projects/p/global/networks/n", "asset_type": "compute.googleapis.com/Network", "resource": map[string]interface{}{}},
+	} {
+		if err := inventory.Add(asset); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := inventory.Add(map[string]interface{}{"name": "no-type"}); err == nil {
+		t.Errorf("expected error for asset without asset_type")
+	}
+
+	handled, path, data, err := New().ProcessData(inventory)
+	if err != nil || !handled || path != "" {
+		t.Fatalf("got %v, %q, %v, want inventory stored at the data root", handled, path, err)
+	}
+	bucket, found, err := unstructured.NestedMap(data.(map[string]interface{}), "storage.googleapis.com/Bucket", "//storage.googleapis.com/b")
+	if !found || err != nil {
+		t.Fatalf("bucket not found in %v", data)
+	}
+	if bucket["resource"] == nil || bucket["iam_policy"] == nil {
+		t.Errorf("got bucket %v, want resource and IAM policy merged", bucket)
+	}
+
+	if handled, _, _, err := New().ProcessData(map[string]interface{}{}); err == nil || handled {
+		t.Errorf("expected error for data that is not an inventory")
+	}
+}
@@ -30,12 +30,47 @@ matching_constraints[constraint] {
 	match := get_default(spec, "match", {})
 
 	# Default matcher behavior is to match everything.
-	target := get_default(match, "target", ["**"])
+	target := array.concat(get_default(match, "target", default_target(match)), get_default(match, "ancestries", []))
 	target_match := {asset.ancestry_path | path_matches(asset.ancestry_path, target[_])}
 	count(target_match) != 0
-	exclude := get_default(match, "exclude", [])
+	exclude := array.concat(get_default(match, "exclude", []), get_default(match, "excludedAncestries", []))
 	exclusion_match := {asset.ancestry_path | path_matches(asset.ancestry_path, exclude[_])}
 	count(exclusion_match) == 0
+	name_regexes := get_default(match, "nameRegexes", [".*"])
+	name_match := {asset.name | re_match(name_regexes[_], asset.name)}
+	count(name_match) != 0
+	excluded_name_regexes := get_default(match, "excludedNameRegexes", [])
+	excluded_name_match := {asset.name | re_match(excluded_name_regexes[_], asset.name)}
+	count(excluded_name_match) == 0
+	labels := get_default(match, "labels", {})
+	mismatched_labels := {key | labels[key]; not label_matches(asset, key, labels[key])}
+	count(mismatched_labels) == 0
+}
+
+# Match label of the resource of the asset, read from wherever its asset type stores them with
+# the precedence of labelPaths.
+label_matches(asset, key, value) {
+	asset_label(asset, key) == value
+}
+
+asset_label(asset, key) = value {
+	value := asset.resource.data.resourceLabels[key]
+	is_string(value)
+} else = value {
+	value := asset.resource.data.settings.userLabels[key]
+	is_string(value)
+} else = value {
+	value := asset.resource.data.labels[key]
+	is_string(value)
+}
+
+# Constraints listing ancestries only match those.
+default_target(match) = [] {
+	has_field(match, "ancestries")
+}
+
+default_target(match) = ["**"] {
+	has_field(match, "ancestries") == false
 }
 
 # CAI Resource Types
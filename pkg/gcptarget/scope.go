@@ -0,0 +1,168 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcptarget
+
+import (
+	"regexp"
+
+	"github.com/gobwas/glob"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Scope is the set of assets a constraint applies to, given by its match block. Assets are in
+// scope if their ancestry path matches one of the target or ancestries globs and none of the
+// exclude or excludedAncestries globs, if their name matches one of the nameRegexes and none of
+// the excludedNameRegexes, and if they have all labels with the values listed in labels. A
+// constraint with neither target nor ancestries applies to the whole hierarchy, one without
+// nameRegexes to assets of any name.
+type Scope struct {
+	includes      []glob.Glob
+	excludes      []glob.Glob
+	names         []*regexp.Regexp
+	excludedNames []*regexp.Regexp
+	labels        map[string]string
+	// ancestryBound is set if the scope does not match every ancestry path.
+	ancestryBound bool
+}
+
+// NewScope returns the scope of constraint.
+func NewScope(constraint *unstructured.Unstructured) (*Scope, error) {
+	targets, foundTargets, err := matchGlobs(constraint, "target")
+	if err != nil {
+		return nil, err
+	}
+	ancestries, foundAncestries, err := matchGlobs(constraint, "ancestries")
+	if err != nil {
+		return nil, err
+	}
+	excludes, _, err := matchGlobs(constraint, "exclude")
+	if err != nil {
+		return nil, err
+	}
+	excludedAncestries, _, err := matchGlobs(constraint, "excludedAncestries")
+	if err != nil {
+		return nil, err
+	}
+	labels, _, err := unstructured.NestedStringMap(constraint.Object, "spec", "match", "labels")
+	if err != nil {
+		return nil, errors.Errorf("invalid spec.match.labels: %s", err)
+	}
+	if !foundTargets && !foundAncestries {
+		targets = []string{"**"}
+	}
+	s := &Scope{labels: labels}
+	s.ancestryBound = len(excludes) != 0 || len(excludedAncestries) != 0
+	for _, target := range append(targets, ancestries...) {
+		s.ancestryBound = s.ancestryBound || target != "**"
+	}
+	if s.names, err = matchRegexes(constraint, "nameRegexes"); err != nil {
+		return nil, err
+	}
+	if s.excludedNames, err = matchRegexes(constraint, "excludedNameRegexes"); err != nil {
+		return nil, err
+	}
+	if s.includes, err = compileGlobs(append(targets, ancestries...)); err != nil {
+		return nil, err
+	}
+	if s.excludes, err = compileGlobs(append(excludes, excludedAncestries...)); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Matches returns whether an asset with name, ancestryPath and labels is in scope.
+func (s *Scope) Matches(name, ancestryPath string, labels map[string]string) bool {
+	for key, value := range s.labels {
+		if got, found := labels[key]; !found || got != value {
+			return false
+		}
+	}
+	for _, excluded := range s.excludedNames {
+		if excluded.MatchString(name) {
+			return false
+		}
+	}
+	if len(s.names) != 0 && !anyMatch(s.names, name) {
+		return false
+	}
+	for _, exclude := range s.excludes {
+		if exclude.Match(ancestryPath) {
+			return false
+		}
+	}
+	for _, include := range s.includes {
+		if include.Match(ancestryPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// DependsOnAncestry returns true unless the scope matches assets of any ancestry path.
+func (s *Scope) DependsOnAncestry() bool {
+	return s.ancestryBound
+}
+
+// matchGlobs returns the ancestry globs in field of the match block of constraint.
+func matchGlobs(constraint *unstructured.Unstructured, field string) ([]string, bool, error) {
+	globs, found, err := unstructured.NestedStringSlice(constraint.Object, "spec", "match", field)
+	if err != nil {
+		return nil, false, errors.Errorf("invalid spec.match.%s: %s", field, err)
+	}
+	if found {
+		if err := checkPathGlobs(globs); err != nil {
+			return nil, false, errors.Wrapf(err, "invalid glob in %s", field)
+		}
+	}
+	return globs, found, nil
+}
+
+// matchRegexes returns the compiled name regular expressions in field of the match block of
+// constraint.
+func matchRegexes(constraint *unstructured.Unstructured, field string) ([]*regexp.Regexp, error) {
+	exprs, _, err := unstructured.NestedStringSlice(constraint.Object, "spec", "match", field)
+	if err != nil {
+		return nil, errors.Errorf("invalid spec.match.%s: %s", field, err)
+	}
+	regexes := make([]*regexp.Regexp, len(exprs))
+	for idx, expr := range exprs {
+		if regexes[idx], err = regexp.Compile(expr); err != nil {
+			return nil, errors.Errorf("invalid regular expression %q in spec.match.%s[%d]: %s", expr, field, idx, err)
+		}
+	}
+	return regexes, nil
+}
+
+func anyMatch(regexes []*regexp.Regexp, s string) bool {
+	for _, re := range regexes {
+		if re.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+func compileGlobs(patterns []string) ([]glob.Glob, error) {
+	globs := make([]glob.Glob, len(patterns))
+	for idx, pattern := range patterns {
+		g, err := glob.Compile(pattern, '/')
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid glob %s", pattern)
+		}
+		globs[idx] = g
+	}
+	return globs, nil
+}
@@ -0,0 +1,182 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"runtime"
+	"testing"
+
+	"github.com/forseti-security/config-validator/pkg/api/validator"
+	"github.com/forseti-security/config-validator/pkg/asset"
+	"github.com/forseti-security/config-validator/pkg/gcptarget"
+	"github.com/golang/protobuf/jsonpb"
+	cftypes "github.com/open-policy-agent/frameworks/constraint/pkg/types"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// benchmarkExport is a newline delimited CAI export of typical GCP and K8S assets.
+const benchmarkExport = "../../test/cai/assets.json"
+
+func benchmarkRecords(b *testing.B) []*asset.Record {
+	f, err := os.Open(benchmarkExport)
+	if err != nil {
+		b.Fatal("unexpected error", err)
+	}
+	defer f.Close()
+	var records []*asset.Record
+	reader := asset.NewReader(f, benchmarkExport)
+	for {
+		record, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			b.Fatal("unexpected error", err)
+		}
+		records = append(records, record)
+	}
+	if len(records) == 0 {
+		b.Fatalf("no records in %s", benchmarkExport)
+	}
+	return records
+}
+
+func benchmarkAssets(b *testing.B) []*validator.Asset {
+	var assets []*validator.Asset
+	for _, record := range benchmarkRecords(b) {
+		assetJSON, err := json.Marshal(record.Asset)
+		if err != nil {
+			b.Fatal("unexpected error", err)
+		}
+		pbAsset := &validator.Asset{}
+		if err := jsonpb.UnmarshalString(string(assetJSON), pbAsset); err != nil {
+			b.Fatal("unexpected error", err)
+		}
+		assets = append(assets, pbAsset)
+	}
+	return assets
+}
+
+func newBenchmarkValidator(b *testing.B, opts ...Option) *Validator {
+	v, err := NewValidator(append(testOptions(), opts...)...)
+	if err != nil {
+		b.Fatal("unexpected error", err)
+	}
+	return v
+}
+
+func BenchmarkReviewRecords(b *testing.B) {
+	v := newBenchmarkValidator(b)
+	records := benchmarkRecords(b)
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, record := range records {
+			if _, err := v.ReviewRecord(ctx, record); err != nil {
+				b.Fatal("unexpected error", err)
+			}
+		}
+	}
+}
+
+func BenchmarkParallelReview(b *testing.B) {
+	benchmarkParallelReview(b)
+}
+
+func BenchmarkParallelReviewClientPool(b *testing.B) {
+	benchmarkParallelReview(b, WithClientPool(runtime.NumCPU()))
+}
+
+func benchmarkParallelReview(b *testing.B, opts ...Option) {
+	stopChannel := make(chan struct{})
+	defer close(stopChannel)
+	pv := NewParallelValidator(stopChannel, newBenchmarkValidator(b, opts...))
+	request := &validator.ReviewRequest{Assets: benchmarkAssets(b)}
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := pv.Review(ctx, request); err != nil {
+			b.Fatal("unexpected error", err)
+		}
+	}
+}
+
+func benchmarkResponses() *cftypes.Responses {
+	constraint := benchmarkViolation().Constraint
+	var results []*cftypes.Result
+	for i := 0; i < 4; i++ {
+		results = append(results, &cftypes.Result{
+			Msg:        "location not allowed",
+			Metadata:   benchmarkViolation().Metadata,
+			Constraint: constraint,
+		})
+	}
+	return &cftypes.Responses{
+		ByTarget: map[string]*cftypes.Response{
+			gcptarget.Name: {Target: gcptarget.Name, Results: results},
+		},
+	}
+}
+
+func BenchmarkNewResult(b *testing.B) {
+	records := benchmarkRecords(b)
+	responses := benchmarkResponses()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, record := range records {
+			if _, err := NewResult(gcptarget.Name, record.Asset, record.Asset, responses); err != nil {
+				b.Fatal("unexpected error", err)
+			}
+		}
+	}
+}
+
+func BenchmarkResultToViolations(b *testing.B) {
+	result, err := NewResult(gcptarget.Name, benchmarkRecords(b)[0].Asset, nil, benchmarkResponses())
+	if err != nil {
+		b.Fatal("unexpected error", err)
+	}
+	if _, found, _ := unstructured.NestedString(result.CAIResource, ancestryPathKey); !found {
+		b.Fatalf("benchmark asset has no %s", ancestryPathKey)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := result.ToViolations(); err != nil {
+			b.Fatal("unexpected error", err)
+		}
+	}
+}
+
+func BenchmarkResultToInsights(b *testing.B) {
+	result, err := NewResult(gcptarget.Name, benchmarkRecords(b)[0].Asset, nil, benchmarkResponses())
+	if err != nil {
+		b.Fatal("unexpected error", err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if insights := result.ToInsights(); len(insights) == 0 {
+			b.Fatal("no insights")
+		}
+	}
+}
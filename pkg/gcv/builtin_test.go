@@ -0,0 +1,131 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+	"testing"
+
+	"github.com/forseti-security/config-validator/pkg/gcv/configs"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestBuiltinCIS(t *testing.T) {
+	v, err := NewValidator(WithPolicyPaths(configs.BuiltinCIS))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	asset := func(assetType, field, content string) string {
+		return `{"name": "//example/x", "asset_type": "` + assetType + `", "ancestors": ["projects/1", "organizations/2"], "` + field + `": ` + content + `}`
+	}
+	firewall := func(data string) string {
+		return asset("compute.googleapis.com/Firewall", "resource", `{"version": "v1", "data": `+data+`}`)
+	}
+	var testCases = []struct {
+		name  string
+		asset string
+		want  []string
+	}{
+		{
+			name:  "ssh and rdp open",
+			asset: firewall(`{"direction": "INGRESS", "sourceRanges": ["0.0.0.0/0"], "allowed": [{"IPProtocol": "tcp", "ports": ["22", "3000-4000"]}]}`),
+			want:  []string{"CISGCPFirewallOpenPortConstraint.cis-3-6-no-ssh-from-internet", "CISGCPFirewallOpenPortConstraint.cis-3-7-no-rdp-from-internet"},
+		},
+		{
+			name:  "all ports open",
+			asset: firewall(`{"sourceRanges": ["0.0.0.0/0"], "allowed": [{"IPProtocol": "all"}]}`),
+			want:  []string{"CISGCPFirewallOpenPortConstraint.cis-3-6-no-ssh-from-internet", "CISGCPFirewallOpenPortConstraint.cis-3-7-no-rdp-from-internet"},
+		},
+		{
+			name:  "other ports open",
+			asset: firewall(`{"sourceRanges": ["0.0.0.0/0"], "allowed": [{"IPProtocol": "tcp", "ports": ["443"]}, {"IPProtocol": "udp"}]}`),
+		},
+		{
+			name:  "disabled rule",
+			asset: firewall(`{"disabled": true, "sourceRanges": ["0.0.0.0/0"], "allowed": [{"IPProtocol": "tcp"}]}`),
+		},
+		{
+			name:  "internal ssh",
+			asset: firewall(`{"sourceRanges": ["10.0.0.0/8"], "allowed": [{"IPProtocol": "tcp", "ports": ["22"]}]}`),
+		},
+		{
+			name:  "default network",
+			asset: asset("compute.googleapis.com/Network", "resource", `{"version": "v1", "data": {"name": "default"}}`),
+			want:  []string{"CISGCPDefaultNetworkConstraint.cis-3-1-no-default-network"},
+		},
+		{
+			name:  "public bucket",
+			asset: asset("storage.googleapis.com/Bucket", "iam_policy", `{"bindings": [{"role": "roles/storage.objectViewer", "members": ["allUsers", "user:a@example.com"]}]}`),
+			want:  []string{"CISGCPPublicIAMConstraint.cis-5-1-no-public-buckets"},
+		},
+		{
+			name:  "public dataset",
+			asset: asset("bigquery.googleapis.com/Dataset", "iam_policy", `{"bindings": [{"role": "roles/bigquery.dataViewer", "members": ["allAuthenticatedUsers"]}]}`),
+			want:  []string{"CISGCPPublicIAMConstraint.cis-7-1-no-public-datasets"},
+		},
+		{
+			name:  "bucket without uniform access",
+			asset: asset("storage.googleapis.com/Bucket", "resource", `{"version": "v1", "data": {"iamConfiguration": {"uniformBucketLevelAccess": {"enabled": false}}}}`),
+			want:  []string{"CISGCPBucketUniformAccessConstraint.cis-5-2-bucket-uniform-access"},
+		},
+		{
+			name:  "bucket with bucket policy only",
+			asset: asset("storage.googleapis.com/Bucket", "resource", `{"version": "v1", "data": {"iamConfiguration": {"bucketPolicyOnly": {"enabled": true}}}}`),
+		},
+		{
+			name:  "public sql instance",
+			asset: asset("sqladmin.googleapis.com/Instance", "resource", `{"version": "v1", "data": {"settings": {"ipConfiguration": {"authorizedNetworks": [{"value": "0.0.0.0/0"}]}}}}`),
+			want:  []string{"CISGCPSQLPublicNetworkConstraint.cis-6-5-no-sql-public-network", "CISGCPSQLRequireSSLConstraint.cis-6-4-sql-require-ssl"},
+		},
+		{
+			name:  "sql instance requiring ssl",
+			asset: asset("sqladmin.googleapis.com/Instance", "resource", `{"version": "v1", "data": {"settings": {"ipConfiguration": {"sslMode": "ENCRYPTED_ONLY"}}}}`),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := v.ReviewJSON(context.Background(), tc.asset)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var got []string
+			for _, cv := range result.ConstraintViolations {
+				got = append(got, cv.ConstraintName())
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected violations (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestWithBundles(t *testing.T) {
+	all, err := NewValidator(WithPolicyPaths(configs.BuiltinCIS))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	v, err := NewValidator(WithPolicyPaths(configs.BuiltinCIS), WithBundles("cis-v1.1"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// All constraints of the set belong to the CIS bundle.
+	if len(v.Constraints()) != len(all.Constraints()) || v.PolicyVersion() != all.PolicyVersion() {
+		t.Errorf("got %d constraints with policy version %s, want %d with %s",
+			len(v.Constraints()), v.PolicyVersion(), len(all.Constraints()), all.PolicyVersion())
+	}
+	if _, err := NewValidator(WithPolicyPaths(configs.BuiltinCIS), WithBundles("scorecard-v1")); err == nil {
+		t.Errorf("expected error for bundle without constraints")
+	}
+}
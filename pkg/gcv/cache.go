@@ -0,0 +1,112 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"container/list"
+	"sync"
+)
+
+// CacheStats reports the effectiveness of the result cache.
+type CacheStats struct {
+	// Hits is the number of reviews that were answered from the cache.
+	Hits uint64
+	// Misses is the number of reviews that required constraint evaluation.
+	Misses uint64
+	// Entries is the number of results currently held in the cache.
+	Entries int
+}
+
+// resultCache is a fixed size LRU cache of review results.
+type resultCache struct {
+	mu      sync.Mutex
+	size    int
+	lru     *list.List
+	entries map[string]*list.Element
+	hits    uint64
+	misses  uint64
+}
+
+type cacheEntry struct {
+	key    string
+	result *Result
+}
+
+func newResultCache(size int) *resultCache {
+	return &resultCache{
+		size:    size,
+		lru:     list.New(),
+		entries: map[string]*list.Element{},
+	}
+}
+
+// get returns the cached result for key and records a hit or miss.
+func (c *resultCache) get(key string) (*Result, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, found := c.entries[key]
+	if !found {
+		c.misses++
+		return nil, false
+	}
+	c.hits++
+	c.lru.MoveToFront(elem)
+	return elem.Value.(*cacheEntry).result, true
+}
+
+// add stores result under key, evicting the least recently used entry if the cache is full.
+func (c *resultCache) add(key string, result *Result) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, found := c.entries[key]; found {
+		elem.Value.(*cacheEntry).result = result
+		c.lru.MoveToFront(elem)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(&cacheEntry{key: key, result: result})
+	if c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+func (c *resultCache) stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return CacheStats{Hits: c.hits, Misses: c.misses, Entries: c.lru.Len()}
+}
+
+// cacheKey returns the key for an asset reviewed against the policy set identified by policyVersion.
+func cacheKey(policyVersion string, asset map[string]interface{}) (string, error) {
+	hash, err := hashAsset(asset)
+	if err != nil {
+		return "", err
+	}
+	return policyVersion + ":" + hash, nil
+}
+
+// forAsset returns a copy of a cached result that refers to asset rather than to the asset the
+// result was originally computed for. If reviewedAsIs is set, asset is also the review resource.
+func (r *Result) forAsset(asset map[string]interface{}, reviewedAsIs bool) *Result {
+	result := *r
+	result.CAIResource = asset
+	result.Source = nil
+	if reviewedAsIs {
+		result.ReviewResource = asset
+	}
+	result.ConstraintViolations = append([]ConstraintViolation(nil), r.ConstraintViolations...)
+	return &result
+}
@@ -0,0 +1,71 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+	"testing"
+)
+
+func TestResultCacheEviction(t *testing.T) {
+	c := newResultCache(2)
+	c.add("a", &Result{Name: "a"})
+	c.add("b", &Result{Name: "b"})
+	if _, found := c.get("a"); !found {
+		t.Fatalf("expected a to be cached")
+	}
+	// b is now least recently used and gets evicted.
+	c.add("c", &Result{Name: "c"})
+	if _, found := c.get("b"); found {
+		t.Errorf("expected b to be evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if r, found := c.get(key); !found || r.Name != key {
+			t.Errorf("expected %s to be cached, got %v", key, r)
+		}
+	}
+	want := CacheStats{Hits: 3, Misses: 1, Entries: 2}
+	if got := c.stats(); got != want {
+		t.Errorf("got stats %+v, want %+v", got, want)
+	}
+}
+
+func TestReviewWithResultCache(t *testing.T) {
+	v, err := NewValidator(append(testOptions(), WithResultCache(16))...)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		for _, assetJSON := range []string{storageAssetNoLoggingJSON, namespaceAssetWithNoLabelJSON} {
+			result, err := v.ReviewJSON(context.Background(), assetJSON)
+			if err != nil {
+				t.Fatal("unexpected error", err)
+			}
+			violations, err := result.ToViolations()
+			if err != nil {
+				t.Fatal("unexpected error", err)
+			}
+			if len(violations) == 0 {
+				t.Errorf("iteration %d: expected violations for %s", i, result.Name)
+			}
+		}
+	}
+
+	want := CacheStats{Hits: 4, Misses: 2, Entries: 2}
+	if got := v.CacheStats(); got != want {
+		t.Errorf("got stats %+v, want %+v", got, want)
+	}
+}
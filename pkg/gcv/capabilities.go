@@ -0,0 +1,146 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"runtime/debug"
+	"sort"
+
+	"github.com/forseti-security/config-validator/pkg/gcptarget"
+	"github.com/forseti-security/config-validator/pkg/gcv/configs"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// opaModule is the module path of Open Policy Agent.
+const opaModule = "github.com/open-policy-agent/opa"
+
+// InputFormats are the formats of the CAI exports read by asset.Reader: newline delimited JSON and
+// length delimited binary Asset protos, either of which may be gzip compressed.
+var InputFormats = []string{"json", "proto", "json+gzip", "proto+gzip"}
+
+// Features are the optional features supported by the validator, named so that orchestrating
+// systems can tell deployments of different versions apart.
+var Features = []string{
+	"asset-transforms",
+	"cel-templates",
+	"explain",
+	"external-data",
+	"incremental-review",
+	"multi-target-review",
+	"registered-targets",
+	"remediation-snippets",
+	"result-cache",
+	"stream-review",
+}
+
+// Capabilities describes what the validator binary supports.
+type Capabilities struct {
+	ValidatorVersion string `json:"validator_version"`
+	// ProtoVersion is the content hash of the validator RPC API definition, which changes with
+	// every change of the API.
+	ProtoVersion string `json:"proto_version"`
+	// Methods are the RPC methods of the Validator service.
+	Methods    []string `json:"methods"`
+	OPAVersion string   `json:"opa_version"`
+	// Targets are the names of the Constraint Framework targets: the GCP and Kubernetes targets,
+	// then those registered with RegisterTarget by name.
+	Targets      []string `json:"targets"`
+	InputFormats []string `json:"input_formats"`
+	Features     []string `json:"features"`
+}
+
+// NewCapabilities returns the capabilities of the validator binary.
+func NewCapabilities() *Capabilities {
+	protoVersion, methods := describeAPI()
+	return &Capabilities{
+		ValidatorVersion: ValidatorVersion(),
+		ProtoVersion:     protoVersion,
+		Methods:          methods,
+		OPAVersion:       moduleVersion(opaModule),
+		Targets:          targetNames(),
+		InputFormats:     append([]string{}, InputFormats...),
+		Features:         append([]string{}, Features...),
+	}
+}
+
+// describeAPI returns the content hash of the compiled validator.proto and the methods of its
+// Validator service, "unknown" and none if it cannot be decoded.
+func describeAPI() (string, []string) {
+	compressed := proto.FileDescriptor("validator.proto")
+	if compressed == nil {
+		return "unknown", nil
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return "unknown", nil
+	}
+	content, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return "unknown", nil
+	}
+	file := &descriptor.FileDescriptorProto{}
+	if err := proto.Unmarshal(content, file); err != nil {
+		return "unknown", nil
+	}
+	var methods []string
+	for _, service := range file.Service {
+		if service.GetName() != "Validator" {
+			continue
+		}
+		for _, method := range service.Method {
+			methods = append(methods, method.GetName())
+		}
+	}
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:8]), methods
+}
+
+// moduleVersion returns the version of module the binary was built with, "unknown" if the binary
+// has no module information.
+func moduleVersion(module string) string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	for _, dep := range info.Deps {
+		if dep.Path != module {
+			continue
+		}
+		if dep.Replace != nil {
+			return dep.Replace.Version
+		}
+		return dep.Version
+	}
+	return "unknown"
+}
+
+// targetNames returns the names of the GCP and Kubernetes targets, then those of the targets
+// registered with RegisterTarget by name.
+func targetNames() []string {
+	targetsMu.RLock()
+	defer targetsMu.RUnlock()
+	var registered []string
+	for name := range targets {
+		registered = append(registered, name)
+	}
+	sort.Strings(registered)
+	return append([]string{gcptarget.Name, configs.K8STargetName}, registered...)
+}
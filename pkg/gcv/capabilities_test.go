@@ -0,0 +1,45 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"testing"
+
+	"github.com/forseti-security/config-validator/pkg/gcptarget"
+	"github.com/forseti-security/config-validator/pkg/gcv/configs"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNewCapabilities(t *testing.T) {
+	c := NewCapabilities()
+	// The inventory target is registered by the tests.
+	wantTargets := []string{gcptarget.Name, configs.K8STargetName, "validation.inventory.example.com"}
+	if diff := cmp.Diff(wantTargets, c.Targets); diff != "" {
+		t.Errorf("unexpected targets (-want +got):\n%s", diff)
+	}
+	methods := map[string]bool{}
+	for _, method := range c.Methods {
+		methods[method] = true
+	}
+	if !methods["Review"] || !methods["GetCapabilities"] {
+		t.Errorf("got methods %v, want the Validator service methods", c.Methods)
+	}
+	if len(c.ProtoVersion) != 16 {
+		t.Errorf("got proto version %q, want a content hash", c.ProtoVersion)
+	}
+	if c.OPAVersion == "" || c.ValidatorVersion == "" {
+		t.Errorf("got OPA version %q and validator version %q, want versions", c.OPAVersion, c.ValidatorVersion)
+	}
+}
@@ -20,12 +20,12 @@ import (
 
 	"github.com/forseti-security/config-validator/pkg/api/validator"
 	"github.com/forseti-security/config-validator/pkg/gcv/oldconfigs"
-	"github.com/golang/glog"
 	"github.com/open-policy-agent/opa/ast"
 	"github.com/open-policy-agent/opa/rego"
 	"github.com/open-policy-agent/opa/storage"
 	"github.com/open-policy-agent/opa/storage/inmem"
 	"github.com/pkg/errors"
+	"go.uber.org/zap"
 )
 
 // ConstraintFramework organizes constraints/templates/data and handles evaluation.
@@ -87,7 +87,7 @@ func (cf *ConstraintFramework) validateTemplate(t *oldconfigs.ConstraintTemplate
 
 // Configure will set the constraint templates and constraints for ConstraintFramework
 func (cf *ConstraintFramework) Configure(templates []*oldconfigs.ConstraintTemplate, constraints []*oldconfigs.Constraint) error {
-	glog.Infof("configuring cf with %d templates and %d constraints", len(templates), len(constraints))
+	zap.L().Info("configuring cf", zap.Int("templates", len(templates)), zap.Int("constraints", len(constraints)))
 
 	// create compiler from templates, other rego sources
 	templateMap := make(map[string]*oldconfigs.ConstraintTemplate)
@@ -0,0 +1,102 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Checkpoint records the asset records processed by a long running review, so that an
+// interrupted review can resume where it left off rather than starting over. Records are
+// identified by their AssetKey and content hash, so a record that changed since it was processed
+// is reviewed again, regardless of its position in the export. A Checkpoint is safe for
+// concurrent use.
+type Checkpoint struct {
+	mu sync.Mutex
+	// PolicyVersion is the content hash of the policies of the review. Checkpoints of reviews with
+	// other policies cannot be resumed.
+	PolicyVersion string `json:"policy_version"`
+	// Processed holds the content hash of each processed record by AssetKey.
+	Processed map[string]string `json:"processed"`
+	// State is saved with the checkpoint on behalf of the caller, such as the partial report of
+	// the review.
+	State json.RawMessage `json:"state,omitempty"`
+}
+
+// NewCheckpoint returns an empty checkpoint for a review with v.
+func NewCheckpoint(v *Validator) *Checkpoint {
+	return &Checkpoint{PolicyVersion: v.policyVersion, Processed: map[string]string{}}
+}
+
+// ReadCheckpoint reads a JSON encoded checkpoint.
+func ReadCheckpoint(r io.Reader) (*Checkpoint, error) {
+	c := &Checkpoint{}
+	if err := json.NewDecoder(r).Decode(c); err != nil {
+		return nil, errors.Wrapf(err, "failed to decode checkpoint")
+	}
+	if c.Processed == nil {
+		c.Processed = map[string]string{}
+	}
+	return c, nil
+}
+
+// Write writes the checkpoint as JSON.
+func (c *Checkpoint) Write(w io.Writer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return errors.Wrapf(json.NewEncoder(w).Encode(c), "failed to encode checkpoint")
+}
+
+// Resumable returns true if the review of v can resume from c, which requires the same policies.
+func (c *Checkpoint) Resumable(v *Validator) bool {
+	return c.PolicyVersion == v.policyVersion
+}
+
+// CheckpointKey returns the key and content hash identifying asset in a Checkpoint. It must be
+// called before the asset is reviewed, as reviews normalize the asset.
+func CheckpointKey(asset map[string]interface{}) (string, string, error) {
+	key := AssetKey(asset)
+	hash, err := hashAsset(asset)
+	if err != nil {
+		return "", "", errors.Wrapf(err, "asset %s", key)
+	}
+	return key, hash, nil
+}
+
+// Done returns true if the record with key was processed with content hash.
+func (c *Checkpoint) Done(key, hash string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	prev, found := c.Processed[key]
+	return found && prev == hash
+}
+
+// Mark records the record with key and content hash as processed.
+func (c *Checkpoint) Mark(key, hash string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Processed[key] = hash
+}
+
+// SetState replaces the state saved with the checkpoint.
+func (c *Checkpoint) SetState(state json.RawMessage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.State = state
+}
@@ -0,0 +1,77 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestCheckpoint(t *testing.T) {
+	v, err := NewValidator(testOptions()...)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	var asset map[string]interface{}
+	if err := json.Unmarshal([]byte(storageAssetNoLoggingJSON), &asset); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	key, hash, err := CheckpointKey(asset)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if key != AssetKey(asset) || hash == "" {
+		t.Errorf("got key %q and hash %q", key, hash)
+	}
+
+	c := NewCheckpoint(v)
+	if c.Done(key, hash) {
+		t.Error("record done before it was marked")
+	}
+	c.Mark(key, hash)
+	c.SetState(json.RawMessage(`{"assets":1}`))
+	var buf bytes.Buffer
+	if err := c.Write(&buf); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	got, err := ReadCheckpoint(&buf)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if !got.Resumable(v) {
+		t.Error("checkpoint not resumable with the same policies")
+	}
+	if !got.Done(key, hash) {
+		t.Error("marked record not done after reading the checkpoint")
+	}
+	// A record whose content changed is reviewed again.
+	asset["ancestry_path"] = "organization/1/folder/2/project/4"
+	_, changed, err := CheckpointKey(asset)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if got.Done(key, changed) {
+		t.Error("changed record done")
+	}
+	if string(got.State) != `{"assets":1}` {
+		t.Errorf("got state %s", got.State)
+	}
+
+	got.PolicyVersion = "other"
+	if got.Resumable(v) {
+		t.Error("checkpoint resumable with other policies")
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/forseti-security/config-validator/pkg/multierror"
+	cfclient "github.com/open-policy-agent/frameworks/constraint/pkg/client"
+	cftemplates "github.com/open-policy-agent/frameworks/constraint/pkg/core/templates"
+	cftypes "github.com/open-policy-agent/frameworks/constraint/pkg/types"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// clientPool round-robins reviews across Constraint Framework clients loaded with the same
+// templates and constraints, so that concurrent reviews do not contend on the locks of a single
+// client and its Rego store.
+type clientPool struct {
+	clients []*cfclient.Client
+	next    uint32
+}
+
+// newClientPool returns a pool of size clients for the target returned by newTarget. The clients
+// share the parsed templates and constraints, but each compiles the Rego of the templates, so
+// they are set up in parallel.
+func newClientPool(
+	size int,
+	newTarget func() cfclient.TargetHandler,
+	templates []*cftemplates.ConstraintTemplate,
+	constraints []*unstructured.Unstructured) (*clientPool, error) {
+	if size < 1 {
+		size = 1
+	}
+	pool := &clientPool{clients: make([]*cfclient.Client, size)}
+	errs := make([]error, size)
+	var wg sync.WaitGroup
+	for idx := range pool.clients {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			pool.clients[idx], errs[idx] = newCFClient(newTarget(), templates, constraints)
+		}(idx)
+	}
+	wg.Wait()
+	// The clients fail alike, the error of the first is enough.
+	if errs[0] != nil {
+		return nil, errs[0]
+	}
+	return pool, nil
+}
+
+// Review reviews obj with the next client of the pool.
+func (p *clientPool) Review(ctx context.Context, obj interface{}) (*cftypes.Responses, error) {
+	idx := atomic.AddUint32(&p.next, 1) % uint32(len(p.clients))
+	return p.clients[idx].Review(ctx, obj)
+}
+
+// AddData adds data to every client of the pool.
+func (p *clientPool) AddData(ctx context.Context, data interface{}) error {
+	var errs multierror.Errors
+	for _, client := range p.clients {
+		_, err := client.AddData(ctx, data)
+		errs.Add(err)
+	}
+	return errs.ToError()
+}
+
+// RemoveData removes data from every client of the pool.
+func (p *clientPool) RemoveData(ctx context.Context, data interface{}) error {
+	var errs multierror.Errors
+	for _, client := range p.clients {
+		_, err := client.RemoveData(ctx, data)
+		errs.Add(err)
+	}
+	return errs.ToError()
+}
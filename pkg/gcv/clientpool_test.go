@@ -0,0 +1,77 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+func TestClientPool(t *testing.T) {
+	ctx := context.Background()
+	v, err := NewValidator(append(featureTestOptions(), WithClientPool(3))...)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if len(v.gcpCFClient.clients) != 3 || len(v.k8sCFClient.clients) != 3 {
+		t.Fatalf("got %d GCP and %d K8S clients, want 3", len(v.gcpCFClient.clients), len(v.k8sCFClient.clients))
+	}
+
+	// Concurrent reviews are spread across the clients, which all find the same violations.
+	var wg sync.WaitGroup
+	counts := make([]int, 12)
+	errs := make([]error, len(counts))
+	for idx := range counts {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			result, err := v.ReviewJSON(ctx, storageAssetNoLoggingJSON)
+			if err != nil {
+				errs[idx] = err
+				return
+			}
+			counts[idx] = len(result.ConstraintViolations)
+		}(idx)
+	}
+	wg.Wait()
+	for idx := range counts {
+		if errs[idx] != nil {
+			t.Fatalf("unexpected error: %v", errs[idx])
+		}
+		if counts[idx] == 0 || counts[idx] != counts[0] {
+			t.Errorf("review %d got %d violations, want %d", idx, counts[idx], counts[0])
+		}
+	}
+
+	// The inventory is added to and removed from every client.
+	assets := unmarshalAssets(t, openFirewallJSON, instanceJSON("web", testNetwork, true))
+	results, err := v.ReviewInventory(ctx, assets)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results[0].ConstraintViolations) != 1 {
+		t.Errorf("got violations %v, want 1", results[0].ConstraintViolations)
+	}
+	for i := 0; i < 3; i++ {
+		result, err := v.ReviewJSON(ctx, openFirewallJSON)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(result.ConstraintViolations) != 0 {
+			t.Errorf("got violations %v after the inventory was removed", result.ConstraintViolations)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configs
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	// Aliases is the annotation listing, comma separated, the previous names of a renamed
+	// constraint. Violations of the constraint are also reported under each alias so downstream
+	// consumers keyed by constraint name keep working during the transition.
+	Aliases = expectedTarget + "/aliases"
+	// AliasesExpire is the optional annotation holding the time, in RFC 3339 or YYYY-MM-DD format,
+	// after which the aliases are no longer reported.
+	AliasesExpire = expectedTarget + "/aliasesExpire"
+)
+
+// aliasDateFormat is the short form accepted for AliasesExpire, interpreted as midnight UTC.
+const aliasDateFormat = "2006-01-02"
+
+// ConstraintAliases returns the aliases of the constraint that are still active at time now.
+func ConstraintAliases(u *unstructured.Unstructured, now time.Time) ([]string, error) {
+	value := strings.TrimSpace(Annotation(u, Aliases))
+	if value == "" {
+		return nil, nil
+	}
+
+	if expire := strings.TrimSpace(Annotation(u, AliasesExpire)); expire != "" {
+		expireTime, err := time.Parse(time.RFC3339, expire)
+		if err != nil {
+			if expireTime, err = time.Parse(aliasDateFormat, expire); err != nil {
+				return nil, errors.Errorf(
+					"constraint %s has invalid %s annotation %q, expected RFC 3339 or YYYY-MM-DD",
+					u.GetName(), AliasesExpire, expire)
+			}
+		}
+		if !now.Before(expireTime) {
+			return nil, nil
+		}
+	}
+
+	var aliases []string
+	for _, alias := range strings.Split(value, ",") {
+		alias = strings.TrimSpace(alias)
+		if alias == "" {
+			continue
+		}
+		if alias == reportedName(u) {
+			return nil, errors.Errorf("constraint %s lists its own name as an alias", reportedName(u))
+		}
+		aliases = append(aliases, alias)
+	}
+	return aliases, nil
+}
+
+// reportedName returns the name of the constraint as declared in the policy files, under which
+// its violations are reported.
+func reportedName(u *unstructured.Unstructured) string {
+	if name := Annotation(u, OriginalName); name != "" {
+		return name
+	}
+	return u.GetName()
+}
+
+// validateAliases checks the alias annotations of all constraints and ensures that no alias
+// collides with the name of another constraint of the same kind, or with an alias of another
+// constraint, either of which would report violations of different constraints under the same
+// name.
+func validateAliases(constraints []*unstructured.Unstructured) error {
+	names := map[string]bool{}
+	for _, constraint := range constraints {
+		names[constraint.GetKind()+"."+reportedName(constraint)] = true
+	}
+	aliased := map[string]string{}
+	for _, constraint := range constraints {
+		// Aliases are validated regardless of expiry, so a typo does not go unnoticed.
+		aliases, err := ConstraintAliases(constraint, time.Time{})
+		if err != nil {
+			return err
+		}
+		name := reportedName(constraint)
+		for _, alias := range aliases {
+			key := constraint.GetKind() + "." + alias
+			if names[key] {
+				return errors.Errorf(
+					"constraint %s alias %s conflicts with an existing constraint of kind %s",
+					name, alias, constraint.GetKind())
+			}
+			if other, found := aliased[key]; found && other != name {
+				return errors.Errorf(
+					"constraint %s alias %s is also an alias of constraint %s of kind %s",
+					name, alias, other, constraint.GetKind())
+			}
+			aliased[key] = name
+		}
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func aliasedConstraint(name string, annotations map[string]string) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetKind("GCPStorageLoggingConstraintV1")
+	u.SetName(name)
+	u.SetAnnotations(annotations)
+	return u
+}
+
+func TestConstraintAliases(t *testing.T) {
+	now := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+	var testCases = []struct {
+		name        string
+		annotations map[string]string
+		want        []string
+		wantErr     bool
+	}{
+		{
+			name: "no aliases",
+		},
+		{
+			name:        "aliases without expiry",
+			annotations: map[string]string{Aliases: "old-name, older-name,"},
+			want:        []string{"old-name", "older-name"},
+		},
+		{
+			name:        "aliases before expiry date",
+			annotations: map[string]string{Aliases: "old-name", AliasesExpire: "2020-06-02"},
+			want:        []string{"old-name"},
+		},
+		{
+			name:        "aliases after expiry",
+			annotations: map[string]string{Aliases: "old-name", AliasesExpire: "2020-05-31T12:00:00Z"},
+		},
+		{
+			name:        "invalid expiry",
+			annotations: map[string]string{Aliases: "old-name", AliasesExpire: "next week"},
+			wantErr:     true,
+		},
+		{
+			name:        "own name",
+			annotations: map[string]string{Aliases: "new-name"},
+			wantErr:     true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ConstraintAliases(aliasedConstraint("new-name", tc.annotations), now)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(got, tc.want); diff != "" {
+				t.Errorf("aliases mismatch, +got -want\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestValidateAliases(t *testing.T) {
+	renamed := aliasedConstraint("new-name", map[string]string{Aliases: "old-name"})
+	if err := validateAliases([]*unstructured.Unstructured{renamed}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	conflicting := aliasedConstraint("old-name", nil)
+	if err := validateAliases([]*unstructured.Unstructured{renamed, conflicting}); err == nil {
+		t.Errorf("expected error for alias conflicting with existing constraint")
+	}
+
+	// Violations are reported under the name declared in the policy files.
+	legacy := aliasedConstraint("gcp-old-name", map[string]string{OriginalName: "GCP_Old_Name"})
+	renamedLegacy := aliasedConstraint("new-name", map[string]string{Aliases: "GCP_Old_Name"})
+	if err := validateAliases([]*unstructured.Unstructured{renamedLegacy, legacy}); err == nil {
+		t.Errorf("expected error for alias conflicting with the original name of a constraint")
+	}
+
+	duplicate := aliasedConstraint("other-name", map[string]string{Aliases: "old-name"})
+	if err := validateAliases([]*unstructured.Unstructured{renamed, duplicate}); err == nil {
+		t.Errorf("expected error for alias of two constraints")
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configs
+
+import (
+	"context"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/forseti-security/config-validator/pkg/cis"
+	"github.com/pkg/errors"
+)
+
+// BuiltinScheme is the scheme of policy paths naming a policy set built into the validator.
+const BuiltinScheme = "builtin"
+
+// BuiltinCIS is the policy path of the built in CIS GCP Foundation Benchmark policy set.
+const BuiltinCIS = BuiltinScheme + ":" + cis.Name
+
+// builtinSets maps the names of the built in policy sets to their files.
+var builtinSets = map[string]func() map[string]string{
+	cis.Name: cis.Files,
+}
+
+// IsBuiltin returns true if path names a built in policy set. The templates of built in sets
+// use no Rego library.
+func IsBuiltin(path string) bool {
+	return strings.HasPrefix(path, BuiltinScheme+":")
+}
+
+// builtinPath is a policy set built into the validator.
+type builtinPath struct {
+	name  string
+	files map[string]string
+}
+
+func newBuiltinPath(name string) (*builtinPath, error) {
+	files, found := builtinSets[name]
+	if !found {
+		var names []string
+		for name := range builtinSets {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, errors.Errorf("unknown built in policy set %q, want one of %s", name, strings.Join(names, ", "))
+	}
+	return &builtinPath{name: name, files: files()}, nil
+}
+
+// paths returns the paths of the files of the set in order.
+func (p *builtinPath) paths() []string {
+	var paths []string
+	for path := range p.files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// ReadAll implements Path
+func (p *builtinPath) ReadAll(ctx context.Context, predicates ...readPredicate) ([]File, error) {
+	var files []File
+	for _, path := range p.paths() {
+		fullPath := BuiltinScheme + ":" + p.name + "/" + path
+		if matchesPredicates(fullPath, predicates) {
+			files = append(files, File{Path: fullPath, Content: []byte(p.files[path])})
+		}
+	}
+	return files, nil
+}
+
+// Walk implements Path
+func (p *builtinPath) Walk(ctx context.Context, fn func(path string, r io.Reader) error, predicates ...readPredicate) error {
+	files, err := p.ReadAll(ctx, predicates...)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if err := fn(file.Path, strings.NewReader(string(file.Content))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configs
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// BundleAnnotationPrefix prefixes the annotations that assign constraints to bundles of the
+// policy library, such as bundles.validator.forsetisecurity.org/cis-v1.1. The annotation value
+// is the control of the bundle the constraint implements.
+const BundleAnnotationPrefix = "bundles.validator.forsetisecurity.org/"
+
+// bundleAnnotation returns the annotation assigning constraints to bundle, which is given by name
+// such as cis-v1.1 or as the annotation itself.
+func bundleAnnotation(bundle string) string {
+	if strings.HasPrefix(bundle, BundleAnnotationPrefix) {
+		return bundle
+	}
+	return BundleAnnotationPrefix + bundle
+}
+
+// InBundles returns true if constraint belongs to at least one of bundles, given by name such as
+// cis-v1.1 or as annotation.
+func InBundles(constraint *unstructured.Unstructured, bundles []string) bool {
+	annotations := constraint.GetAnnotations()
+	for _, bundle := range bundles {
+		if _, found := annotations[bundleAnnotation(bundle)]; found {
+			return true
+		}
+	}
+	return false
+}
+
+// SelectBundles returns a configuration with the templates of c and only those constraints of c
+// that belong to at least one of bundles, given by name such as cis-v1.1 or as annotation. This
+// lets one checkout of the policy library drive validators of different scope. All constraints
+// have been validated while c was loaded, c is not modified. It is an error if a bundle has no
+// constraints, which is most likely a misspelled bundle name.
+func (c *Configuration) SelectBundles(bundles []string) (*Configuration, error) {
+	selected := *c
+	selected.GCPConstraints = selectBundles(c.GCPConstraints, bundles)
+	selected.K8SConstraints = selectBundles(c.K8SConstraints, bundles)
+	if c.TargetConstraints != nil {
+		selected.TargetConstraints = map[string][]*unstructured.Unstructured{}
+		for target, constraints := range c.TargetConstraints {
+			selected.TargetConstraints[target] = selectBundles(constraints, bundles)
+		}
+	}
+
+	var empty []string
+	all := selected.AllConstraints()
+	for _, bundle := range bundles {
+		if !hasBundle(all, bundle) {
+			empty = append(empty, bundle)
+		}
+	}
+	if len(empty) != 0 {
+		sort.Strings(empty)
+		return nil, errors.Errorf("no constraints belong to bundles %s", strings.Join(empty, ", "))
+	}
+	return &selected, nil
+}
+
+// selectBundles returns the constraints that belong to at least one of bundles.
+func selectBundles(constraints []*unstructured.Unstructured, bundles []string) []*unstructured.Unstructured {
+	var selected []*unstructured.Unstructured
+	for _, constraint := range constraints {
+		if InBundles(constraint, bundles) {
+			selected = append(selected, constraint)
+		}
+	}
+	return selected
+}
+
+// hasBundle returns true if one of constraints belongs to bundle.
+func hasBundle(constraints []*unstructured.Unstructured, bundle string) bool {
+	for _, constraint := range constraints {
+		if InBundles(constraint, []string{bundle}) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configs
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestSelectBundles(t *testing.T) {
+	constraint := func(name string, bundles ...string) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{}
+		u.SetName(name)
+		annotations := map[string]string{"benchmark": "CIS11_1.01"}
+		for _, bundle := range bundles {
+			annotations[BundleAnnotationPrefix+bundle] = "1.01"
+		}
+		u.SetAnnotations(annotations)
+		return u
+	}
+	c := &Configuration{
+		GCPConstraints: []*unstructured.Unstructured{
+			constraint("cis", "cis-v1.1"),
+			constraint("both", "cis-v1.1", "scorecard-v1"),
+			constraint("none"),
+		},
+		K8SConstraints: []*unstructured.Unstructured{constraint("k8s-scorecard", "scorecard-v1")},
+	}
+	names := func(constraints []*unstructured.Unstructured) []string {
+		var names []string
+		for _, u := range constraints {
+			names = append(names, u.GetName())
+		}
+		return names
+	}
+	var testCases = []struct {
+		name    string
+		bundles []string
+		wantGCP []string
+		wantK8S []string
+	}{
+		{
+			name:    "one bundle",
+			bundles: []string{"cis-v1.1"},
+			wantGCP: []string{"cis", "both"},
+		},
+		{
+			name:    "several bundles",
+			bundles: []string{"cis-v1.1", "scorecard-v1"},
+			wantGCP: []string{"cis", "both"},
+			wantK8S: []string{"k8s-scorecard"},
+		},
+		{
+			name:    "annotation",
+			bundles: []string{BundleAnnotationPrefix + "scorecard-v1"},
+			wantGCP: []string{"both"},
+			wantK8S: []string{"k8s-scorecard"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			selected, err := c.SelectBundles(tc.bundles)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tc.wantGCP, names(selected.GCPConstraints)); diff != "" {
+				t.Errorf("unexpected GCP constraints (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantK8S, names(selected.K8SConstraints)); diff != "" {
+				t.Errorf("unexpected K8S constraints (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	if _, err := c.SelectBundles([]string{"cis-v1.1", "cis-v1.3", "nist"}); err == nil || err.Error() != "no constraints belong to bundles cis-v1.3, nist" {
+		t.Errorf("got error %v for unknown bundles", err)
+	}
+	// The configuration is left as loaded.
+	if got := append(names(c.GCPConstraints), names(c.K8SConstraints)...); len(got) != 4 {
+		t.Errorf("configuration was modified: %v", got)
+	}
+}
@@ -17,18 +17,22 @@ package configs
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
-	"github.com/golang/glog"
 	"regexp"
 	"sort"
 	"strings"
 
+	"github.com/forseti-security/config-validator/pkg/celtemplate"
 	"github.com/forseti-security/config-validator/pkg/multierror"
 	cfapis "github.com/open-policy-agent/frameworks/constraint/pkg/apis"
 	cfv1alpha1 "github.com/open-policy-agent/frameworks/constraint/pkg/apis/templates/v1alpha1"
 	cftemplates "github.com/open-policy-agent/frameworks/constraint/pkg/core/templates"
 	"github.com/open-policy-agent/frameworks/constraint/pkg/regorewriter"
 	"github.com/pkg/errors"
+	"go.uber.org/zap"
 	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions"
 	apiextensionsv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
@@ -55,11 +59,6 @@ const (
 	OriginalName    = expectedTarget + "/originalName"
 )
 
-const (
-	gcpConstraint = "gcp"
-	k8sConstraint = "k8s"
-)
-
 var (
 	// templateGK is the GroupKind for ConstraintTemplate types.
 	TemplateGK = schema.GroupKind{Group: cfv1alpha1.SchemeGroupVersion.Group, Kind: "ConstraintTemplate"}
@@ -84,6 +83,20 @@ func setAnnotation(u *unstructured.Unstructured, key, value string) {
 	u.SetAnnotations(annotations)
 }
 
+// DeclaredPath returns the path of the file a loaded template or constraint was declared in.
+func DeclaredPath(u *unstructured.Unstructured) string {
+	return Annotation(u, yamlPath)
+}
+
+// Annotation returns the annotation of u with key, empty if there is none. Unlike GetAnnotations
+// it does not copy the annotations, which matters on the review path.
+func Annotation(u *unstructured.Unstructured, key string) string {
+	annotations, _, _ := unstructured.NestedFieldNoCopy(u.Object, "metadata", "annotations")
+	annotationsMap, _ := annotations.(map[string]interface{})
+	value, _ := annotationsMap[key].(string)
+	return value
+}
+
 // LoadUnstructured loads .yaml files from the provided directories as k8s
 // unstructured.Unstructured types.
 func LoadUnstructured(dirs []string) ([]*unstructured.Unstructured, error) {
@@ -135,6 +148,40 @@ func injectRegoAdapter(rego string) string {
 	return rego + "\n" + regoAdapter
 }
 
+// legacyTargetRego returns the rego of a legacy target, which is either given in rego or
+// generated from the CEL validations in cel.
+func legacyTargetRego(kind string, legacyTarget map[string]interface{}) (string, error) {
+	celIface, found := legacyTarget["cel"]
+	if !found {
+		regoIface, found := legacyTarget["rego"]
+		if !found {
+			return "", errors.Errorf("no rego specified in template")
+		}
+		rego, ok := regoIface.(string)
+		if !ok {
+			return "", errors.Errorf("failed to get rego from template")
+		}
+		return rego, nil
+	}
+	if _, found := legacyTarget["rego"]; found {
+		return "", errors.Errorf("template must specify only one of rego and cel")
+	}
+
+	encoded, err := json.Marshal(celIface)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to encode cel")
+	}
+	var cel celtemplate.Config
+	if err := json.Unmarshal(encoded, &cel); err != nil {
+		return "", errors.Wrapf(err, "invalid cel")
+	}
+	rego, err := celtemplate.Rego(kind, cel)
+	if err != nil {
+		return "", errors.Wrapf(err, "invalid cel")
+	}
+	return rego, nil
+}
+
 // convertLegacyConstraintTemplate handles converting a legacy forseti v1alpha1 ConstraintTemplate
 // to a constraint framework v1alpha1 ConstraintTemplate.
 func convertLegacyConstraintTemplate(u *unstructured.Unstructured, regoLib []string) error {
@@ -169,16 +216,13 @@ func convertLegacyConstraintTemplate(u *unstructured.Unstructured, regoLib []str
 		}
 
 		target := map[string]interface{}{}
-		regoIface, found := legacyTarget["rego"]
-		if !found {
-			return errors.Errorf("no rego specified in template")
-		}
-		rego, ok := regoIface.(string)
-		if !ok {
-			return errors.Errorf("failed to get rego from template")
+		rego, err := legacyTargetRego(ctKind, legacyTarget)
+		if err != nil {
+			return err
 		}
 
-		rr, err := regorewriter.New(regorewriter.NewPackagePrefixer("lib"), []string{"data.validator"}, nil)
+		rr, err := regorewriter.New(
+			regorewriter.NewPackagePrefixer("lib"), []string{"data.validator"}, []string{"data.inventory"})
 		if err != nil {
 			return errors.Wrapf(err, "failed to create rego rewriter")
 		}
@@ -293,6 +337,10 @@ type Configuration struct {
 	GCPConstraints []*unstructured.Unstructured      // Constraints for GCP
 	K8STemplates   []*cftemplates.ConstraintTemplate // Constraint Templates for GKE
 	K8SConstraints []*unstructured.Unstructured      // Constraints for GKE
+	// TargetTemplates and TargetConstraints hold the templates and constraints of the targets
+	// registered with RegisterTarget by target name.
+	TargetTemplates   map[string][]*cftemplates.ConstraintTemplate
+	TargetConstraints map[string][]*unstructured.Unstructured
 
 	// regoLib contains the set of rego libraries, it is only used during construction of Configuration
 	regoLib []string
@@ -302,12 +350,19 @@ type Configuration struct {
 	templateNames map[string]*cftemplates.ConstraintTemplate
 	// templateNames is a set of the kinds of all templates for checking exclusivity.
 	templateKinds map[string]*cftemplates.ConstraintTemplate
+	// parameterSchemas maps template kinds to the openAPIV3Schema of their parameters.
+	parameterSchemas map[string]map[string]interface{}
+	// templateObjects holds the loaded templates, after legacy conversion, for WriteGatekeeper.
+	templateObjects []*unstructured.Unstructured
+	// sources are the paths the policies were loaded from.
+	sources []PolicySource
 }
 
 func newConfiguration() *Configuration {
 	return &Configuration{
-		templateNames: map[string]*cftemplates.ConstraintTemplate{},
-		templateKinds: map[string]*cftemplates.ConstraintTemplate{},
+		templateNames:    map[string]*cftemplates.ConstraintTemplate{},
+		templateKinds:    map[string]*cftemplates.ConstraintTemplate{},
+		parameterSchemas: map[string]map[string]interface{}{},
 	}
 }
 
@@ -353,6 +408,15 @@ func (c *Configuration) loadUnstructured(u *unstructured.Unstructured) error {
 			return errors.Errorf("unrecognized ConstraintTemplate version %s", u.GroupVersionKind().Version)
 		}
 
+		// The schema is kept as written since the versioned template does not encode to JSON.
+		parameterSchema, err := TemplateParameterSchema(u)
+		if err != nil {
+			return err
+		}
+		if err := removeParameterDefaults(u); err != nil {
+			return err
+		}
+
 		groupVersioner := runtime.GroupVersioner(schema.GroupVersions(scheme.Scheme.PrioritizedVersionsAllGroups()))
 		obj, err := scheme.Scheme.ConvertToVersion(u, groupVersioner)
 		if err != nil {
@@ -376,6 +440,8 @@ func (c *Configuration) loadUnstructured(u *unstructured.Unstructured) error {
 				ct.Name, ct.Spec.CRD.Spec.Names.Kind, ct.GetAnnotations()[yamlPath], dup.GetAnnotations()[yamlPath])
 		}
 		c.templateKinds[ct.Name] = &ct
+		c.parameterSchemas[ct.Spec.CRD.Spec.Names.Kind] = parameterSchema
+		c.templateObjects = append(c.templateObjects, u)
 
 		for _, target := range ct.Spec.Targets {
 			switch target.Target {
@@ -385,7 +451,13 @@ func (c *Configuration) loadUnstructured(u *unstructured.Unstructured) error {
 			case K8STargetName:
 				c.K8STemplates = append(c.K8STemplates, &ct)
 			default:
-				return errors.Errorf("")
+				if !IsRegisteredTarget(target.Target) {
+					return errors.Errorf("unknown target %q", target.Target)
+				}
+				if c.TargetTemplates == nil {
+					c.TargetTemplates = map[string][]*cftemplates.ConstraintTemplate{}
+				}
+				c.TargetTemplates[target.Target] = append(c.TargetTemplates[target.Target], &ct)
 			}
 		}
 
@@ -396,21 +468,28 @@ func (c *Configuration) loadUnstructured(u *unstructured.Unstructured) error {
 		return errors.Errorf("unexpected data type %s in group %s", u.GroupVersionKind(), cfv1alpha1.SchemeGroupVersion.Group)
 
 	default:
-		glog.V(1).Infof("Ignoring %s %s", u.GroupVersionKind(), u.GetName())
+		zap.L().Debug("ignoring object", zap.String("kind", u.GroupVersionKind().String()), zap.String("name", u.GetName()))
 	}
 	return nil
 }
 
 func (c *Configuration) finishLoad() error {
+	// templates maps the kinds of the templates to their targets.
 	templates := map[string]string{}
 	for _, t := range c.GCPTemplates {
-		templates[t.Spec.CRD.Spec.Names.Kind] = gcpConstraint
+		templates[t.Spec.CRD.Spec.Names.Kind] = expectedTarget
 	}
 	for _, t := range c.K8STemplates {
-		templates[t.Spec.CRD.Spec.Names.Kind] = k8sConstraint
+		templates[t.Spec.CRD.Spec.Names.Kind] = K8STargetName
+	}
+	for target, targetTemplates := range c.TargetTemplates {
+		for _, t := range targetTemplates {
+			templates[t.Spec.CRD.Spec.Names.Kind] = target
+		}
 	}
 
 	byTemplate := map[string]map[string]*unstructured.Unstructured{}
+	var paramErrs multierror.Errors
 	allConstraints := c.allConstraints
 	c.allConstraints = nil
 	for _, constraint := range allConstraints {
@@ -432,28 +511,109 @@ func (c *Configuration) finishLoad() error {
 				dup.GetName(), dup.GetAnnotations()[yamlPath], constraint.GetAnnotations()[yamlPath])
 		}
 
-		switch templates[gvk.Kind] {
-		case gcpConstraint:
+		switch target := templates[gvk.Kind]; target {
+		case expectedTarget:
 			c.GCPConstraints = append(c.GCPConstraints, constraint)
-		case k8sConstraint:
+		case K8STargetName:
 			c.K8SConstraints = append(c.K8SConstraints, constraint)
-		default:
+		case "":
 			return errors.Errorf("constraint %s does not correspond to any templates", gvk)
+		default:
+			if c.TargetConstraints == nil {
+				c.TargetConstraints = map[string][]*unstructured.Unstructured{}
+			}
+			c.TargetConstraints[target] = append(c.TargetConstraints[target], constraint)
 		}
+		applyParameterDefaults(constraint, c.parameterSchemas[gvk.Kind])
+		// Report all constraints with parameter errors rather than just the first.
+		paramErrs.Add(validateConstraintParameters(constraint, c.parameterSchemas[gvk.Kind]))
 	}
-	return nil
+	if !paramErrs.Empty() {
+		return paramErrs.ToError()
+	}
+	if err := validateAliases(allConstraints); err != nil {
+		return err
+	}
+	return validateRemediations(allConstraints)
+}
+
+// WithConstraints returns a configuration with the templates of c and constraints in place of
+// the constraints of c. Constraints are converted and validated as if they were loaded from
+// files, c is not modified.
+func (c *Configuration) WithConstraints(constraints []*unstructured.Unstructured) (*Configuration, error) {
+	candidate := newConfiguration()
+	candidate.GCPTemplates = c.GCPTemplates
+	candidate.K8STemplates = c.K8STemplates
+	candidate.TargetTemplates = c.TargetTemplates
+	candidate.parameterSchemas = c.parameterSchemas
+	candidate.sources = c.sources
+	for _, constraint := range constraints {
+		if constraint.GroupVersionKind().Group != constraintGroup {
+			return nil, errors.Errorf("%s %s is not a constraint", constraint.GroupVersionKind(), constraint.GetName())
+		}
+		candidate.allConstraints = append(candidate.allConstraints, constraint.DeepCopy())
+	}
+	if err := candidate.finishLoad(); err != nil {
+		return nil, errors.Wrapf(err, "config error")
+	}
+	return candidate, nil
 }
 
-// NewConfiguration returns the configuration from the list of provided directories.
+// ContentHash returns a hex encoded SHA-256 digest over all templates and constraints in the
+// configuration. The digest depends neither on the order in which files were loaded nor on the
+// paths they were loaded from, so two configurations with the same policy content produce the
+// same hash.
+func (c *Configuration) ContentHash() (string, error) {
+	var objs []interface{}
+	for _, t := range c.AllTemplates() {
+		t = t.DeepCopy()
+		delete(t.Annotations, yamlPath)
+		objs = append(objs, t)
+	}
+	for _, u := range c.AllConstraints() {
+		u = u.DeepCopy()
+		annotations := u.GetAnnotations()
+		delete(annotations, yamlPath)
+		u.SetAnnotations(annotations)
+		objs = append(objs, u.Object)
+	}
+
+	var digests []string
+	for _, obj := range objs {
+		objBytes, err := json.Marshal(obj)
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to marshal policy object for hashing")
+		}
+		digest := sha256.Sum256(objBytes)
+		digests = append(digests, hex.EncodeToString(digest[:]))
+	}
+	sort.Strings(digests)
+
+	h := sha256.New()
+	for _, digest := range digests {
+		_, _ = h.Write([]byte(digest))
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// NewConfiguration returns the configuration from the list of provided directories, which may
+// include built in policy sets such as BuiltinCIS and PolicySet manifests. Without libDir the
+// library declared by the manifests is used.
 func NewConfiguration(dirs []string, libDir string) (*Configuration, error) {
-	unstructuredObjects, err := LoadUnstructured(dirs)
+	unstructuredObjects, setLibrary, err := loadPolicies(context.Background(), dirs, nil)
 	if err != nil {
 		return nil, err
 	}
+	if libDir == "" {
+		libDir = setLibrary
+	}
 
-	regoLib, err := loadRegoFiles(libDir)
-	if err != nil {
-		return nil, err
+	// Built in policy sets use no library.
+	var regoLib []string
+	if libDir != "" {
+		if regoLib, err = loadRegoFiles(libDir); err != nil {
+			return nil, err
+		}
 	}
 
 	configuration := newConfiguration()
@@ -474,6 +634,7 @@ func NewConfiguration(dirs []string, libDir string) (*Configuration, error) {
 	if err := configuration.finishLoad(); err != nil {
 		return nil, errors.Wrapf(err, "config error")
 	}
+	configuration.sources = policySources(context.Background(), dirs, libDir)
 
 	return configuration, nil
 }
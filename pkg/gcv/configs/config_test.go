@@ -15,7 +15,10 @@
 package configs
 
 import (
+	"io/ioutil"
+	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
@@ -90,6 +93,39 @@ func TestNewConfiguration(t *testing.T) {
 	}
 }
 
+func TestContentHash(t *testing.T) {
+	config, err := NewConfiguration([]string{"../../../test/cf"}, "../../../test/cf/library")
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	// Loading the same files via a different path and order must not change the hash.
+	reordered, err := NewConfiguration(
+		[]string{"../../../test/cf/constraints", "../../../test/cf/templates/../templates"}, "../../../test/cf/library")
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	hash, err := config.ContentHash()
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	reorderedHash, err := reordered.ContentHash()
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if hash != reorderedHash {
+		t.Errorf("hash changed with load order: %s != %s", hash, reorderedHash)
+	}
+
+	config.GCPConstraints = config.GCPConstraints[1:]
+	modifiedHash, err := config.ContentHash()
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if hash == modifiedHash {
+		t.Errorf("hash did not change after removing a constraint")
+	}
+}
+
 func TestLegacyTemplateConversion(t *testing.T) {
 	var testCases = []struct {
 		name  string
@@ -103,6 +139,10 @@ func TestLegacyTemplateConversion(t *testing.T) {
 			name:  "legacy template no schema",
 			input: "test/cf/templates/gcp_storage_logging_template.yaml",
 		},
+		{
+			name:  "legacy CEL template",
+			input: "pkg/gcv/testdata/cel/gcp_sql_public_ip_cel_template.yaml",
+		},
 	}
 
 	for _, tc := range testCases {
@@ -138,6 +178,160 @@ func TestLegacyTemplateConversion(t *testing.T) {
 	}
 }
 
+func TestLegacyTargetRego(t *testing.T) {
+	cel := map[string]interface{}{
+		"validations": []interface{}{map[string]interface{}{"expression": "has(asset.resource)"}},
+	}
+	rego, err := legacyTargetRego("TestConstraint", map[string]interface{}{"cel": cel})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !strings.Contains(rego, "package templates.gcp.TestConstraint") {
+		t.Errorf("got rego %s, want template package", rego)
+	}
+
+	for name, target := range map[string]map[string]interface{}{
+		"rego and cel":       {"rego": "package x", "cel": cel},
+		"invalid expression": {"cel": map[string]interface{}{"validations": []interface{}{map[string]interface{}{"expression": "("}}}},
+		"no validations":     {"cel": map[string]interface{}{}},
+		"neither":            {},
+	} {
+		if _, err := legacyTargetRego("TestConstraint", target); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
 func TestLegacyConstraintConversion(t *testing.T) {
 
 }
+
+const locationTemplate = `apiVersion: templates.gatekeeper.sh/v1alpha1
+kind: ConstraintTemplate
+metadata:
+  name: gcp-location
+spec:
+  crd:
+    spec:
+      names:
+        kind: GCPLocationConstraint
+      validation:
+        openAPIV3Schema:
+          properties:
+            locations:
+              type: array
+              items:
+                type: string
+  targets:
+    validation.gcp.forsetisecurity.org:
+      rego: |
+        package templates.gcp.GCPLocationConstraint
+
+        deny[{"msg": "wrong location", "details": {}}] {
+        	input.asset.resource.data.location != input.parameters.locations[_]
+        }
+`
+
+func TestConstraintParameterValidation(t *testing.T) {
+	var testCases = []struct {
+		name       string
+		parameters string
+		wantErrs   []string
+	}{
+		{
+			name:       "valid",
+			parameters: "locations: [EU]",
+		},
+		{
+			name:       "unknown parameter",
+			parameters: "loactions: [EU]",
+			wantErrs:   []string{"location.yaml", "spec.parameters.loactions: unknown parameter"},
+		},
+		{
+			name:       "wrong type",
+			parameters: "locations: EU",
+			wantErrs:   []string{"location.yaml", `spec.parameters.locations: must be of type array: "string"`},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			policyDir, err := ioutil.TempDir("", "ParameterTest")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(policyDir)
+			constraint := `apiVersion: constraints.gatekeeper.sh/v1alpha1
+kind: GCPLocationConstraint
+metadata:
+  name: eu-only
+spec:
+  parameters:
+    ` + tc.parameters + "\n"
+			files := map[string]string{"template.yaml": locationTemplate, "location.yaml": constraint}
+			for name, content := range files {
+				if err := ioutil.WriteFile(filepath.Join(policyDir, name), []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			_, err = NewConfiguration([]string{policyDir}, "../../../test/cf/library")
+			if len(tc.wantErrs) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error %s", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("expected error")
+			}
+			for _, want := range tc.wantErrs {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("got error %q, want it to contain %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestWithConstraints(t *testing.T) {
+	config, err := NewConfiguration([]string{"../../../test/cf"}, "../../../test/cf/library")
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	candidates := append([]*unstructured.Unstructured{}, config.GCPConstraints[1:]...)
+	legacy := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "constraints.gatekeeper.sh/v1alpha1",
+		"kind":       "GCPStorageLoggingConstraint",
+		"metadata":   map[string]interface{}{"name": "Candidate_Logging"},
+		"spec":       map[string]interface{}{"match": map[string]interface{}{"target": []interface{}{"organization/*"}}},
+	}}
+	candidates = append(candidates, legacy)
+
+	candidate, err := config.WithConstraints(candidates)
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if got, want := len(candidate.GCPConstraints), len(config.GCPConstraints); got != want {
+		t.Errorf("got %d candidate constraints, want %d", got, want)
+	}
+	if got, want := len(candidate.GCPTemplates), len(config.GCPTemplates); got != want {
+		t.Errorf("got %d candidate templates, want %d", got, want)
+	}
+	if got := len(candidate.K8SConstraints); got != 0 {
+		t.Errorf("got %d k8s constraints, want 0", got)
+	}
+	added := candidate.GCPConstraints[len(candidate.GCPConstraints)-1]
+	if added.GetName() != "candidate-logging" {
+		t.Errorf("got name %s, want converted name", added.GetName())
+	}
+	if legacy.GetName() != "Candidate_Logging" {
+		t.Errorf("candidate constraint was modified")
+	}
+
+	invalid := legacy.DeepCopy()
+	invalid.SetKind("GCPBigQueryDatasetLocationConstraintV1")
+	invalid.Object["spec"] = map[string]interface{}{"parameters": map[string]interface{}{"unknown": true}}
+	if _, err := config.WithConstraints([]*unstructured.Unstructured{invalid}); err == nil {
+		t.Errorf("expected error for unknown parameter")
+	}
+}
@@ -18,6 +18,7 @@ package configs
 import (
 	"context"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"net/url"
@@ -27,8 +28,9 @@ import (
 	"sync"
 
 	"cloud.google.com/go/storage"
-	"github.com/golang/glog"
+	"github.com/forseti-security/config-validator/pkg/logging"
 	"github.com/pkg/errors"
+	"go.uber.org/zap"
 	"google.golang.org/api/iterator"
 )
 
@@ -51,13 +53,17 @@ func configGCSClient() {
 	}
 }
 
-// NewPath returns a new Path to a local or gcs file.
+// NewPath returns a new Path to a local or gcs file, or to a built in policy set.
 func NewPath(path string) (Path, error) {
 	fileURL, err := url.Parse(path)
 	if err != nil {
 		return nil, err
 	}
 
+	if fileURL.Scheme == BuiltinScheme {
+		return newBuiltinPath(fileURL.Opaque)
+	}
+
 	if fileURL.Scheme == "gs" {
 		globals.once.Do(configGCSClient)
 		return &gcsPath{
@@ -101,6 +107,9 @@ func matchesPredicates(path string, predicates []readPredicate) bool {
 type Path interface {
 	// ReadAll will read the given file, or recursively read all files under the specified directory.
 	ReadAll(ctx context.Context, predicates ...readPredicate) ([]File, error)
+	// Walk calls fn with a reader of the given file, or of each file under the specified directory,
+	// in turn. Unlike ReadAll it does not hold the content of the files in memory.
+	Walk(ctx context.Context, fn func(path string, r io.Reader) error, predicates ...readPredicate) error
 }
 
 // localPath handles local file paths.
@@ -136,6 +145,25 @@ func (p *localPath) ReadAll(ctx context.Context, predicates ...readPredicate) ([
 	return files, nil
 }
 
+// Walk implements Path
+func (p *localPath) Walk(ctx context.Context, fn func(path string, r io.Reader) error, predicates ...readPredicate) error {
+	visit := func(path string, f os.FileInfo, err error) error {
+		if err != nil {
+			return errors.Wrapf(err, "error visiting path %s", path)
+		}
+		if f.IsDir() || !matchesPredicates(path, predicates) {
+			return nil
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to read %s", path)
+		}
+		defer file.Close()
+		return fn(path, file)
+	}
+	return filepath.Walk(p.path, visit)
+}
+
 // gcsPath represents an object or prefix on GCS.
 type gcsPath struct {
 	bucket string
@@ -145,7 +173,7 @@ type gcsPath struct {
 // read reads an object from GCS
 func (p *gcsPath) read(ctx context.Context, bucket *storage.BucketHandle, name string) (File, error) {
 	fileName := fmt.Sprintf("gs://%s/%s", p.bucket, name)
-	glog.V(2).Infof("Listing GCS Object %s", fileName)
+	logging.FromContext(ctx).Debug("reading GCS object", zap.String("uri", fileName))
 
 	reader, err := bucket.Object(name).NewReader(ctx)
 	if err != nil {
@@ -153,7 +181,7 @@ func (p *gcsPath) read(ctx context.Context, bucket *storage.BucketHandle, name s
 	}
 	defer func() {
 		if err := reader.Close(); err != nil {
-			glog.Warningf("failed to close %s: %s", fileName, err)
+			logging.FromContext(ctx).Warn("failed to close GCS object", zap.String("uri", fileName), zap.Error(err))
 		}
 	}()
 
@@ -175,7 +203,7 @@ func (p *gcsPath) ReadAll(ctx context.Context, predicates ...readPredicate) ([]F
 	it := bucket.Objects(ctx, &storage.Query{
 		Prefix: p.path,
 	})
-	glog.V(2).Infof("Listing files in GCS at host %s and path %s", p.bucket, p.path)
+	logging.FromContext(ctx).Debug("listing GCS objects", zap.String("bucket", p.bucket), zap.String("prefix", p.path))
 	for {
 		attrs, err := it.Next()
 		if err != nil {
@@ -201,3 +229,129 @@ func (p *gcsPath) ReadAll(ctx context.Context, predicates ...readPredicate) ([]F
 	}
 	return files, nil
 }
+
+// Walk implements Path
+func (p *gcsPath) Walk(ctx context.Context, fn func(path string, r io.Reader) error, predicates ...readPredicate) error {
+	bucket := globals.client.Bucket(p.bucket)
+	it := bucket.Objects(ctx, &storage.Query{
+		Prefix: p.path,
+	})
+	found := false
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if !matchesPredicates(attrs.Name, predicates) {
+			continue
+		}
+		found = true
+		fileName := fmt.Sprintf("gs://%s/%s", p.bucket, attrs.Name)
+		logging.FromContext(ctx).Debug("reading GCS object", zap.String("uri", fileName))
+		reader, err := bucket.Object(attrs.Name).NewReader(ctx)
+		if err != nil {
+			return errors.Wrapf(err, "failed to read object %s", fileName)
+		}
+		err = fn(fileName, reader)
+		reader.Close()
+		if err != nil {
+			return err
+		}
+	}
+	if !found {
+		return errors.Errorf("no objects found at gs://%s/%s", p.bucket, p.path)
+	}
+	return nil
+}
+
+// gcsObject returns the object at path if it is a gs:// URL, false if path is a local path.
+func gcsObject(path string) (*storage.ObjectHandle, bool, error) {
+	fileURL, err := url.Parse(path)
+	if err != nil || fileURL.Scheme != "gs" {
+		return nil, false, nil
+	}
+	name := strings.TrimLeft(fileURL.Path, "/")
+	if fileURL.Host == "" || name == "" {
+		return nil, true, errors.Errorf("invalid object URL %s, want gs://bucket/object", path)
+	}
+	globals.once.Do(configGCSClient)
+	return globals.client.Bucket(fileURL.Host).Object(name), true, nil
+}
+
+// RelativePath returns rel relative to the directory of the file base, which may be a gs:// path.
+// Absolute paths and URLs are returned as given.
+func RelativePath(base, rel string) string {
+	if strings.HasPrefix(rel, "/") || strings.Contains(rel, "://") {
+		return rel
+	}
+	return base[:strings.LastIndex(base, "/")+1] + rel
+}
+
+// ReadFile reads the local file or gs:// object at path. It returns false if it does not exist.
+func ReadFile(ctx context.Context, path string) ([]byte, bool, error) {
+	object, isGCS, err := gcsObject(path)
+	if err != nil {
+		return nil, false, err
+	}
+	if !isGCS {
+		content, err := ioutil.ReadFile(path)
+		if os.IsNotExist(err) {
+			return nil, false, nil
+		}
+		return content, err == nil, errors.Wrapf(err, "failed to read %s", path)
+	}
+	reader, err := object.NewReader(ctx)
+	if err == storage.ErrObjectNotExist {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, errors.Wrapf(err, "failed to read %s", path)
+	}
+	defer reader.Close()
+	content, err := ioutil.ReadAll(reader)
+	return content, err == nil, errors.Wrapf(err, "failed to read %s", path)
+}
+
+// WriteFile replaces the local file or gs:// object at path with content. Readers never see a
+// partially written file: local files are replaced by renaming a temporary file, objects are only
+// replaced once their upload completes.
+func WriteFile(ctx context.Context, path string, content []byte) error {
+	object, isGCS, err := gcsObject(path)
+	if err != nil {
+		return err
+	}
+	if !isGCS {
+		tmp := path + ".tmp"
+		if err := ioutil.WriteFile(tmp, content, 0644); err != nil {
+			return errors.Wrapf(err, "failed to write %s", tmp)
+		}
+		return errors.Wrapf(os.Rename(tmp, path), "failed to replace %s", path)
+	}
+	writer := object.NewWriter(ctx)
+	if _, err := writer.Write(content); err != nil {
+		_ = writer.Close()
+		return errors.Wrapf(err, "failed to write %s", path)
+	}
+	return errors.Wrapf(writer.Close(), "failed to write %s", path)
+}
+
+// RemoveFile removes the local file or gs:// object at path, if it exists.
+func RemoveFile(ctx context.Context, path string) error {
+	object, isGCS, err := gcsObject(path)
+	if err != nil {
+		return err
+	}
+	if !isGCS {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return errors.Wrapf(err, "failed to remove %s", path)
+		}
+		return nil
+	}
+	if err := object.Delete(ctx); err != nil && err != storage.ErrObjectNotExist {
+		return errors.Wrapf(err, "failed to remove %s", path)
+	}
+	return nil
+}
@@ -17,6 +17,10 @@ package configs
 import (
 	"context"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"sort"
 	"strings"
 	"testing"
@@ -155,3 +159,97 @@ func TestGCSPath(t *testing.T) {
 		t.Run(tc.name, tc.Run)
 	}
 }
+
+func TestLocalFile(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "LocalFileTest")
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "checkpoint.json")
+
+	if _, found, err := ReadFile(ctx, path); err != nil || found {
+		t.Fatalf("got found %v, error %v reading missing file", found, err)
+	}
+	if err := WriteFile(ctx, path, []byte("content")); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	content, found, err := ReadFile(ctx, path)
+	if err != nil || !found || string(content) != "content" {
+		t.Errorf("got %q, found %v, error %v", content, found, err)
+	}
+	if err := RemoveFile(ctx, path); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	// Removing a missing file is not an error.
+	if err := RemoveFile(ctx, path); err != nil {
+		t.Errorf("unexpected error %s", err)
+	}
+}
+
+func TestLocalPathWalk(t *testing.T) {
+	p, err := NewPath("../../../test/cf/templates")
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	files, err := p.ReadAll(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	want := map[string]string{}
+	for _, f := range files {
+		want[f.Path] = string(f.Content)
+	}
+	got := map[string]string{}
+	err = p.Walk(context.Background(), func(path string, r io.Reader) error {
+		content, err := ioutil.ReadAll(r)
+		got[path] = string(content)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected files (-want +got):\n%s", diff)
+	}
+}
+
+func TestBuiltinPath(t *testing.T) {
+	p, err := NewPath(BuiltinCIS)
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	files, err := p.ReadAll(context.Background(), SuffixPredicate(".yaml"))
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	var paths []string
+	for _, f := range files {
+		paths = append(paths, f.Path)
+	}
+	if len(paths) == 0 || !sort.StringsAreSorted(paths) || !strings.HasPrefix(paths[0], BuiltinCIS+"/") {
+		t.Errorf("unexpected paths %v", paths)
+	}
+	if _, err := NewPath("builtin:unknown"); err == nil {
+		t.Errorf("expected error for unknown policy set")
+	}
+}
+
+func TestRelativePath(t *testing.T) {
+	for _, tc := range []struct {
+		base string
+		rel  string
+		want string
+	}{
+		{base: "policies/set.yaml", rel: "cis", want: "policies/cis"},
+		{base: "gs://bucket/sets/set.yaml", rel: "../lib", want: "gs://bucket/sets/../lib"},
+		{base: "set.yaml", rel: "cis", want: "cis"},
+		{base: "policies/set.yaml", rel: "/abs/cis", want: "/abs/cis"},
+		{base: "policies/set.yaml", rel: "gs://other/cis", want: "gs://other/cis"},
+	} {
+		if got := RelativePath(tc.base, tc.rel); got != tc.want {
+			t.Errorf("RelativePath(%q, %q) = %q, want %q", tc.base, tc.rel, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configs
+
+import (
+	"io"
+	"sort"
+
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	// gatekeeperTemplateVersion is the apiVersion of the ConstraintTemplates written by
+	// WriteGatekeeper.
+	gatekeeperTemplateVersion = "templates.gatekeeper.sh/v1"
+	// gatekeeperConstraintVersion is the apiVersion of the constraints written by
+	// WriteGatekeeper.
+	gatekeeperConstraintVersion = constraintGroup + "/v1beta1"
+)
+
+// GatekeeperExport summarizes the policies written by WriteGatekeeper.
+type GatekeeperExport struct {
+	// Templates and Constraints are the number of written templates and constraints.
+	Templates   int
+	Constraints int
+	// Skipped names the templates without a Kubernetes target, which are not written with their
+	// constraints, as Gatekeeper cannot evaluate templates reviewing CAI assets.
+	Skipped []string
+}
+
+// WriteGatekeeper writes the templates and constraints of the Kubernetes target as YAML documents
+// that Gatekeeper accepts, so that one policy library serves both CAI reviews and admission
+// control on clusters. Templates are written as v1 ConstraintTemplates with their parameter
+// schema typed as an object, as v1 requires structural schemas. The annotations added when
+// loading policy files are removed, except for the original name of renamed constraints.
+func (c *Configuration) WriteGatekeeper(w io.Writer) (*GatekeeperExport, error) {
+	export := &GatekeeperExport{}
+	var objs []*unstructured.Unstructured
+	templates := append([]*unstructured.Unstructured{}, c.templateObjects...)
+	sort.Slice(templates, func(i, j int) bool {
+		return templates[i].GetName() < templates[j].GetName()
+	})
+	for _, template := range templates {
+		gkTemplate, err := gatekeeperTemplate(template)
+		if err != nil {
+			return nil, errors.Wrapf(err, "template %s", template.GetName())
+		}
+		if gkTemplate == nil {
+			export.Skipped = append(export.Skipped, template.GetName())
+			continue
+		}
+		objs = append(objs, gkTemplate)
+		export.Templates++
+	}
+	constraints := append([]*unstructured.Unstructured{}, c.K8SConstraints...)
+	sort.Slice(constraints, func(i, j int) bool {
+		if constraints[i].GetKind() != constraints[j].GetKind() {
+			return constraints[i].GetKind() < constraints[j].GetKind()
+		}
+		return constraints[i].GetName() < constraints[j].GetName()
+	})
+	for _, constraint := range constraints {
+		constraint = constraint.DeepCopy()
+		constraint.SetAPIVersion(gatekeeperConstraintVersion)
+		removeLoadAnnotations(constraint)
+		objs = append(objs, constraint)
+		export.Constraints++
+	}
+
+	for idx, obj := range objs {
+		out, err := yaml.Marshal(obj.Object)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to encode %s %s", obj.GetKind(), obj.GetName())
+		}
+		if idx != 0 {
+			out = append([]byte("---\n"), out...)
+		}
+		if _, err := w.Write(out); err != nil {
+			return nil, err
+		}
+	}
+	return export, nil
+}
+
+// gatekeeperTemplate returns the v1 ConstraintTemplate for the Kubernetes target of template, or
+// nil if template has no Kubernetes target.
+func gatekeeperTemplate(template *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	targets, _, err := unstructured.NestedSlice(template.Object, "spec", "targets")
+	if err != nil {
+		return nil, err
+	}
+	var k8sTargets []interface{}
+	for _, target := range targets {
+		if targetMap, ok := target.(map[string]interface{}); ok && targetMap["target"] == K8STargetName {
+			k8sTargets = append(k8sTargets, target)
+		}
+	}
+	if len(k8sTargets) == 0 {
+		return nil, nil
+	}
+
+	template = template.DeepCopy()
+	template.SetAPIVersion(gatekeeperTemplateVersion)
+	removeLoadAnnotations(template)
+	if err := unstructured.SetNestedSlice(template.Object, k8sTargets, "spec", "targets"); err != nil {
+		return nil, err
+	}
+	schemaPath := []string{"spec", "crd", "spec", "validation", "openAPIV3Schema"}
+	schema, found, err := unstructured.NestedMap(template.Object, schemaPath...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid parameter schema")
+	}
+	if found {
+		if _, typed := schema["type"]; !typed {
+			schema["type"] = "object"
+		}
+		if err := unstructured.SetNestedMap(template.Object, schema, schemaPath...); err != nil {
+			return nil, err
+		}
+	}
+	return template, nil
+}
+
+// removeLoadAnnotations removes the path annotation set when loading policy files from u.
+func removeLoadAnnotations(u *unstructured.Unstructured) {
+	annotations := u.GetAnnotations()
+	delete(annotations, yamlPath)
+	if len(annotations) == 0 {
+		unstructured.RemoveNestedField(u.Object, "metadata", "annotations")
+		return
+	}
+	u.SetAnnotations(annotations)
+}
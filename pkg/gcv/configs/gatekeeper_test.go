@@ -0,0 +1,71 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configs
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/ghodss/yaml"
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestWriteGatekeeper(t *testing.T) {
+	config, err := NewConfiguration([]string{"../../../test/cf"}, "../../../test/cf/library")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var buf bytes.Buffer
+	export, err := config.WriteGatekeeper(&buf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if export.Templates != 1 || export.Constraints != 1 || len(export.Skipped) != len(config.GCPTemplates) {
+		t.Errorf("got export %+v, want 1 template, 1 constraint and %d skipped", export, len(config.GCPTemplates))
+	}
+
+	var got []string
+	var objs []*unstructured.Unstructured
+	for _, document := range strings.Split(buf.String(), "\n---\n") {
+		u := &unstructured.Unstructured{}
+		if err := yaml.Unmarshal([]byte(document), &u.Object); err != nil {
+			t.Fatalf("invalid document %s: %v", document, err)
+		}
+		got = append(got, u.GetAPIVersion()+" "+u.GetKind()+" "+u.GetName())
+		objs = append(objs, u)
+	}
+	want := []string{
+		"templates.gatekeeper.sh/v1 ConstraintTemplate k8srequiredlabels",
+		"constraints.gatekeeper.sh/v1beta1 K8sRequiredLabels namespace-cost-center-label",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("unexpected objects (-want +got):\n%s", diff)
+	}
+	schemaType, _, _ := unstructured.NestedString(objs[0].Object, "spec", "crd", "spec", "validation", "openAPIV3Schema", "type")
+	if schemaType != "object" {
+		t.Errorf("got parameter schema type %q, want object", schemaType)
+	}
+	for _, obj := range objs {
+		if _, found := obj.GetAnnotations()[yamlPath]; found {
+			t.Errorf("%s %s has the path annotation", obj.GetKind(), obj.GetName())
+		}
+	}
+	// The loaded configuration is left as is.
+	if DeclaredPath(config.K8SConstraints[0]) == "" {
+		t.Errorf("constraint lost its path annotation")
+	}
+}
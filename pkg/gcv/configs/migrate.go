@@ -0,0 +1,195 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configs
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	cfv1beta1 "github.com/open-policy-agent/frameworks/constraint/pkg/apis/templates/v1beta1"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/kubectl/pkg/scheme"
+)
+
+// MigratedFile is a policy file with legacy documents rewritten by Migrate.
+type MigratedFile struct {
+	// Path is the path the file was read from.
+	Path string
+	// Content is the migrated content of the file. Documents that were not migrated are kept
+	// as written, comments within migrated documents are lost.
+	Content []byte
+	// Templates and Constraints are the number of migrated documents of each type.
+	Templates   int
+	Constraints int
+}
+
+// MigrationProblem is a legacy document Migrate could not rewrite, which is left as written.
+type MigrationProblem struct {
+	Path string
+	Kind string
+	Name string
+	// Reason explains why the document was not rewritten.
+	Reason string
+}
+
+func (p *MigrationProblem) String() string {
+	return fmt.Sprintf("%s: %s %s: %s", p.Path, p.Kind, p.Name, p.Reason)
+}
+
+// Migration is the outcome of Migrate.
+type Migration struct {
+	// Files are the files with migrated documents.
+	Files    []*MigratedFile
+	Problems []*MigrationProblem
+}
+
+// Migrate rewrites the legacy v1alpha1 templates and constraints in the YAML files of dirs into
+// the v1beta1 format, the way they are converted when loaded. The Rego of templates is rewritten
+// with the library in libDir inlined, so migrated templates no longer depend on the library. Migrated constraints keep the name they are reported with, the
+// policy version changes with their version only.
+//
+// Templates written in CEL are not migrated, as v1beta1 templates only hold Rego, nor are legacy
+// documents that fail to convert. They are reported as problems. The files are not modified,
+// write the content of the returned files to apply the migration.
+func Migrate(ctx context.Context, dirs []string, libDir string) (*Migration, error) {
+	regoLib, err := loadRegoFiles(libDir)
+	if err != nil {
+		return nil, err
+	}
+	migration := &Migration{}
+	for _, dir := range dirs {
+		dirPath, err := NewPath(dir)
+		if err != nil {
+			return nil, err
+		}
+		files, err := dirPath.ReadAll(ctx, SuffixPredicate(".yaml"))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			migrated, err := migration.migrateFile(file, regoLib)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to migrate %s", file.Path)
+			}
+			if migrated != nil {
+				migration.Files = append(migration.Files, migrated)
+			}
+		}
+	}
+	return migration, nil
+}
+
+// migrateFile returns file with its legacy documents migrated, or nil if it has none.
+func (m *Migration) migrateFile(file File, regoLib []string) (*MigratedFile, error) {
+	migrated := &MigratedFile{Path: file.Path}
+	documents := strings.Split(string(file.Content), "\n---")
+	for idx, rawDoc := range documents {
+		document := strings.TrimLeft(rawDoc, "\n ")
+		if len(document) == 0 {
+			continue
+		}
+		var u unstructured.Unstructured
+		if _, _, err := scheme.Codecs.UniversalDeserializer().Decode([]byte(document), nil, &u); err != nil {
+			return nil, errors.Wrapf(err, "failed to decode document %d", idx)
+		}
+		if u.GroupVersionKind().Version != "v1alpha1" {
+			continue
+		}
+		var reason string
+		switch {
+		case u.GroupVersionKind().GroupKind() == TemplateGK:
+			reason = migrateTemplate(&u, regoLib)
+			if reason == "" {
+				migrated.Templates++
+			}
+		case u.GroupVersionKind().Group == constraintGroup:
+			reason = migrateConstraint(&u)
+			if reason == "" {
+				migrated.Constraints++
+			}
+		default:
+			continue
+		}
+		if reason != "" {
+			m.Problems = append(m.Problems, &MigrationProblem{Path: file.Path, Kind: u.GetKind(), Name: u.GetName(), Reason: reason})
+			continue
+		}
+		out, err := yaml.Marshal(u.Object)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to encode %s %s", u.GetKind(), u.GetName())
+		}
+		documents[idx] = leadingComments(rawDoc) + strings.TrimSuffix(string(out), "\n") + trailingNewlines(rawDoc)
+	}
+	if migrated.Templates == 0 && migrated.Constraints == 0 {
+		return nil, nil
+	}
+	migrated.Content = []byte(strings.Join(documents, "\n---"))
+	return migrated, nil
+}
+
+// migrateTemplate converts the legacy template u in place, returning why it was not converted
+// if it was not.
+func migrateTemplate(u *unstructured.Unstructured, regoLib []string) string {
+	targets, _, _ := unstructured.NestedMap(u.Object, "spec", "targets")
+	for _, target := range targets {
+		if targetMap, ok := target.(map[string]interface{}); ok {
+			if _, found := targetMap["cel"]; found {
+				return "CEL templates are only supported as v1alpha1 templates"
+			}
+		}
+	}
+	converted := u.DeepCopy()
+	if err := convertLegacyConstraintTemplate(converted, regoLib); err != nil {
+		return err.Error()
+	}
+	converted.SetAPIVersion(cfv1beta1.SchemeGroupVersion.String())
+	if result := configValidatorV1Beta1SchemaValidator.Validate(converted.Object); result.HasErrorsOrWarnings() {
+		return "converted template is invalid: " + result.AsError().Error()
+	}
+	*u = *converted
+	return ""
+}
+
+// migrateConstraint converts the legacy constraint u in place, returning why it was not
+// converted if it was not.
+func migrateConstraint(u *unstructured.Unstructured) string {
+	converted := u.DeepCopy()
+	if err := convertLegacyConstraint(converted); err != nil {
+		return err.Error()
+	}
+	converted.SetAPIVersion(constraintGroup + "/v1beta1")
+	*u = *converted
+	return ""
+}
+
+// leadingComments returns the blank and comment lines starting rawDoc, such as license headers.
+func leadingComments(rawDoc string) string {
+	var prefix strings.Builder
+	for _, line := range strings.SplitAfter(rawDoc, "\n") {
+		if trimmed := strings.TrimSpace(line); trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+			break
+		}
+		prefix.WriteString(line)
+	}
+	return prefix.String()
+}
+
+// trailingNewlines returns the newlines ending rawDoc.
+func trailingNewlines(rawDoc string) string {
+	return rawDoc[len(strings.TrimRight(rawDoc, "\n")):]
+}
@@ -0,0 +1,115 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configs
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	cftemplates "github.com/open-policy-agent/frameworks/constraint/pkg/core/templates"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestMigrate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "migrate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if out, err := exec.Command("cp", "-r", "../../../test/cf", dir).CombinedOutput(); err != nil {
+		t.Fatalf("failed to copy policies: %v: %s", err, out)
+	}
+	// The CEL template cannot be migrated.
+	if out, err := exec.Command("cp", "-r", "../testdata/cel", filepath.Join(dir, "cf")).CombinedOutput(); err != nil {
+		t.Fatalf("failed to copy policies: %v: %s", err, out)
+	}
+	policies, libs := filepath.Join(dir, "cf"), filepath.Join(dir, "cf", "library")
+	want, err := NewConfiguration([]string{policies}, libs)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	migration, err := Migrate(context.Background(), []string{policies}, libs)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(migration.Problems) != 1 || migration.Problems[0].Name != "gcp-sql-public-ip-cel" {
+		t.Errorf("got problems %v, want the CEL template", migration.Problems)
+	}
+	for _, file := range migration.Files {
+		if err := ioutil.WriteFile(file.Path, file.Content, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(file.Content), "v1alpha1") {
+			t.Errorf("%s has legacy documents:\n%s", file.Path, file.Content)
+		}
+		if !strings.HasPrefix(string(file.Content), "# Copyright") {
+			t.Errorf("%s lost its license header:\n%s", file.Path, file.Content)
+		}
+	}
+
+	// The migrated policies load as the same templates and constraints, apart from the version
+	// of the constraints.
+	got, err := NewConfiguration([]string{policies}, libs)
+	if err != nil {
+		t.Fatalf("unexpected error loading migrated policies: %v", err)
+	}
+	if diff := cmp.Diff(loadedPolicies(t, want), loadedPolicies(t, got)); diff != "" {
+		t.Errorf("unexpected migrated policies (-want +got):\n%s", diff)
+	}
+
+	again, err := Migrate(context.Background(), []string{policies}, libs)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(again.Files) != 0 {
+		t.Errorf("migrated %d files again", len(again.Files))
+	}
+}
+
+// loadedPolicies returns the JSON encoded templates and constraints of c, without the paths they
+// were loaded from and the versions of the constraints.
+func loadedPolicies(t *testing.T, c *Configuration) []string {
+	var objs []interface{}
+	for _, template := range append(append([]*cftemplates.ConstraintTemplate{}, c.GCPTemplates...), c.K8STemplates...) {
+		template = template.DeepCopy()
+		delete(template.Annotations, yamlPath)
+		objs = append(objs, template)
+	}
+	for _, constraint := range append(append([]*unstructured.Unstructured{}, c.GCPConstraints...), c.K8SConstraints...) {
+		constraint = constraint.DeepCopy()
+		removeLoadAnnotations(constraint)
+		delete(constraint.Object, "apiVersion")
+		objs = append(objs, constraint.Object)
+	}
+	var encoded []string
+	for _, obj := range objs {
+		objJSON, err := json.Marshal(obj)
+		if err != nil {
+			t.Fatal(err)
+		}
+		encoded = append(encoded, string(objJSON))
+	}
+	sort.Strings(encoded)
+	return encoded
+}
@@ -0,0 +1,248 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configs
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	openapierrors "github.com/go-openapi/errors"
+	"github.com/go-openapi/spec"
+	"github.com/go-openapi/strfmt"
+	"github.com/go-openapi/validate"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// ParameterError is a constraint parameter that does not match the openAPIV3Schema of its
+// template.
+type ParameterError struct {
+	// Field is the dot separated path of the parameter below spec.parameters.
+	Field string
+	// Unknown is set if the schema does not declare the parameter.
+	Unknown bool
+	Message string
+}
+
+func (e ParameterError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("spec.parameters: %s", e.Message)
+	}
+	return fmt.Sprintf("spec.parameters.%s: %s", e.Field, e.Message)
+}
+
+// TemplateParameterSchema returns the openAPIV3Schema of the parameters of a template, nil if
+// the template does not declare one.
+func TemplateParameterSchema(template *unstructured.Unstructured) (map[string]interface{}, error) {
+	schema, found, err := unstructured.NestedMap(template.Object, "spec", "crd", "spec", "validation", "openAPIV3Schema")
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid spec.crd.spec.validation.openAPIV3Schema")
+	}
+	if !found {
+		return nil, nil
+	}
+	return schema, nil
+}
+
+// removeParameterDefaults removes the defaults from the openAPIV3Schema of template, which the
+// Constraint Framework rejects. Defaults are applied to the constraints instead.
+func removeParameterDefaults(template *unstructured.Unstructured) error {
+	schema, found, err := unstructured.NestedFieldNoCopy(template.Object, "spec", "crd", "spec", "validation", "openAPIV3Schema")
+	if err != nil {
+		return errors.Wrapf(err, "invalid spec.crd.spec.validation.openAPIV3Schema")
+	}
+	if found {
+		removeDefaults(schema)
+	}
+	return nil
+}
+
+// removeDefaults removes the defaults of schema and its subschemas.
+func removeDefaults(schema interface{}) {
+	switch s := schema.(type) {
+	case map[string]interface{}:
+		delete(s, "default")
+		for _, key := range []string{"items", "additionalProperties", "not", "allOf", "anyOf", "oneOf"} {
+			removeDefaults(s[key])
+		}
+		for _, key := range []string{"properties", "patternProperties"} {
+			properties, _ := s[key].(map[string]interface{})
+			for _, property := range properties {
+				removeDefaults(property)
+			}
+		}
+	case []interface{}:
+		for _, item := range s {
+			removeDefaults(item)
+		}
+	}
+}
+
+// applyParameterDefaults sets the parameters constraint omits to the defaults declared by schema,
+// the openAPIV3Schema of its template, so that templates see the effective parameters rather
+// than failing on or silently skipping missing ones. As for Kubernetes structural schemas, the
+// properties of objects are only defaulted if the object is set or has a default itself.
+func applyParameterDefaults(constraint *unstructured.Unstructured, schema map[string]interface{}) {
+	if schema == nil {
+		return
+	}
+	params, found, err := unstructured.NestedFieldNoCopy(constraint.Object, "spec", "parameters")
+	if err != nil {
+		// Reported by validateConstraintParameters.
+		return
+	}
+	if !found {
+		params = map[string]interface{}{}
+	}
+	paramsMap, ok := params.(map[string]interface{})
+	if !ok {
+		return
+	}
+	defaultObject(paramsMap, schema)
+	if !found && len(paramsMap) != 0 {
+		// This only fails if spec is not an object, which the Constraint Framework rejects.
+		_ = unstructured.SetNestedField(constraint.Object, paramsMap, "spec", "parameters")
+	}
+}
+
+// defaultObject sets the properties obj omits to their defaults in schema, and defaults the
+// properties of the values it sets.
+func defaultObject(obj map[string]interface{}, schema map[string]interface{}) {
+	properties, _ := schema["properties"].(map[string]interface{})
+	for name, property := range properties {
+		propertySchema, _ := property.(map[string]interface{})
+		value, found := obj[name]
+		if !found {
+			defaultValue, hasDefault := propertySchema["default"]
+			if !hasDefault {
+				continue
+			}
+			value = runtime.DeepCopyJSONValue(defaultValue)
+			obj[name] = value
+		}
+		defaultNested(value, propertySchema)
+	}
+}
+
+// defaultNested defaults the properties of the objects within value, which has schema.
+func defaultNested(value interface{}, schema map[string]interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		defaultObject(v, schema)
+	case []interface{}:
+		items, _ := schema["items"].(map[string]interface{})
+		for _, item := range v {
+			defaultNested(item, items)
+		}
+	}
+}
+
+// validateConstraintParameters checks the spec.parameters of constraint against schema, the
+// openAPIV3Schema of its template, returning an error naming the file of the constraint and
+// each offending field.
+func validateConstraintParameters(constraint *unstructured.Unstructured, schema map[string]interface{}) error {
+	if schema == nil {
+		return nil
+	}
+	params, _, err := unstructured.NestedMap(constraint.Object, "spec", "parameters")
+	if err != nil {
+		return errors.Errorf("constraint %q declared at path %q has invalid spec.parameters: %s",
+			constraint.GetName(), constraint.GetAnnotations()[yamlPath], err)
+	}
+	if params == nil {
+		return nil
+	}
+	paramErrs, err := ValidateParameters(schema, params)
+	if err != nil {
+		return errors.Wrapf(err, "template of kind %s", constraint.GetKind())
+	}
+	if len(paramErrs) == 0 {
+		return nil
+	}
+	var msgs []string
+	for _, paramErr := range paramErrs {
+		msgs = append(msgs, paramErr.Error())
+	}
+	return errors.Errorf("constraint %q declared at path %q does not match the parameters of %s: %s",
+		constraint.GetName(), constraint.GetAnnotations()[yamlPath], constraint.GetKind(), strings.Join(msgs, "; "))
+}
+
+// ValidateParameters checks params, the spec.parameters of a constraint, against schema, the
+// openAPIV3Schema of its template. Parameters are unknown if they are not listed in the
+// properties of an object schema that does not allow additional properties. Errors are sorted
+// by field.
+func ValidateParameters(schema, params map[string]interface{}) ([]ParameterError, error) {
+	encoded, err := json.Marshal(schema)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to encode parameter schema")
+	}
+	var s spec.Schema
+	if err := json.Unmarshal(encoded, &s); err != nil {
+		return nil, errors.Wrapf(err, "invalid parameter schema")
+	}
+
+	var paramErrs []ParameterError
+	unknownParameters(&s, params, nil, &paramErrs)
+	result := validate.NewSchemaValidator(&s, nil, "", strfmt.Default).Validate(params)
+	for _, err := range result.Errors {
+		paramErr := ParameterError{Message: err.Error()}
+		if validation, ok := err.(*openapierrors.Validation); ok {
+			paramErr.Field = validation.Name
+			// Messages read "<name> in body must ...".
+			msg := strings.TrimPrefix(validation.Error(), validation.Name)
+			paramErr.Message = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(msg), "in body"))
+		}
+		paramErrs = append(paramErrs, paramErr)
+	}
+	sort.SliceStable(paramErrs, func(i, j int) bool { return paramErrs[i].Field < paramErrs[j].Field })
+	return paramErrs, nil
+}
+
+// unknownParameters adds an error for each key of value not declared by s.
+func unknownParameters(s *spec.Schema, value interface{}, path []string, paramErrs *[]ParameterError) {
+	if s == nil {
+		return
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if len(s.Properties) == 0 || s.AdditionalProperties != nil {
+			return
+		}
+		for key, child := range v {
+			field := append(append([]string{}, path...), key)
+			prop, found := s.Properties[key]
+			if !found {
+				*paramErrs = append(*paramErrs, ParameterError{
+					Field:   strings.Join(field, "."),
+					Unknown: true,
+					Message: "unknown parameter",
+				})
+				continue
+			}
+			unknownParameters(&prop, child, field, paramErrs)
+		}
+	case []interface{}:
+		if s.Items == nil || s.Items.Schema == nil {
+			return
+		}
+		for idx, item := range v {
+			field := append(append([]string{}, path...), fmt.Sprint(idx))
+			unknownParameters(s.Items.Schema, item, field, paramErrs)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configs
+
+import (
+	"testing"
+
+	"github.com/ghodss/yaml"
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const testParameterSchema = `
+properties:
+  mode:
+    type: string
+    enum: [allowlist, denylist]
+  locations:
+    type: array
+    items:
+      type: string
+  exemptions:
+    type: array
+    items:
+      type: object
+      properties:
+        name:
+          type: string
+`
+
+func TestValidateParameters(t *testing.T) {
+	var schema map[string]interface{}
+	if err := yaml.Unmarshal([]byte(testParameterSchema), &schema); err != nil {
+		t.Fatal(err)
+	}
+	var testCases = []struct {
+		name   string
+		params string
+		want   []ParameterError
+	}{
+		{
+			name:   "valid",
+			params: `{"mode": "allowlist", "locations": ["EU"], "exemptions": [{"name": "b"}]}`,
+		},
+		{
+			name:   "unknown parameters",
+			params: `{"mdoe": "allowlist", "exemptions": [{"nmae": "b"}]}`,
+			want: []ParameterError{
+				{Field: "exemptions.0.nmae", Unknown: true, Message: "unknown parameter"},
+				{Field: "mdoe", Unknown: true, Message: "unknown parameter"},
+			},
+		},
+		{
+			name:   "wrong types",
+			params: `{"mode": "other", "locations": "EU"}`,
+			want: []ParameterError{
+				{Field: "locations", Message: `must be of type array: "string"`},
+				{Field: "mode", Message: "should be one of [allowlist denylist]"},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var params map[string]interface{}
+			if err := yaml.Unmarshal([]byte(tc.params), &params); err != nil {
+				t.Fatal(err)
+			}
+			got, err := ValidateParameters(schema, params)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected errors (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+const testDefaultsSchema = `
+properties:
+  mode:
+    type: string
+    default: allowlist
+  locations:
+    type: array
+    items:
+      type: string
+    default: [EU]
+  logging:
+    type: object
+    default: {}
+    properties:
+      bucket:
+        type: string
+        default: logs
+  network:
+    type: object
+    properties:
+      name:
+        type: string
+        default: default
+  exemptions:
+    type: array
+    items:
+      type: object
+      properties:
+        expires:
+          type: string
+          default: never
+`
+
+func TestApplyParameterDefaults(t *testing.T) {
+	var schema map[string]interface{}
+	if err := yaml.Unmarshal([]byte(testDefaultsSchema), &schema); err != nil {
+		t.Fatal(err)
+	}
+	var testCases = []struct {
+		name string
+		spec string
+		want string
+	}{
+		{
+			name: "no parameters",
+			spec: `{}`,
+			want: `{"parameters": {"mode": "allowlist", "locations": ["EU"], "logging": {"bucket": "logs"}}}`,
+		},
+		{
+			name: "set parameters",
+			spec: `{"parameters": {"mode": "denylist", "locations": [], "logging": {"bucket": "audit"}}}`,
+			want: `{"parameters": {"mode": "denylist", "locations": [], "logging": {"bucket": "audit"}}}`,
+		},
+		{
+			name: "nested objects",
+			spec: `{"parameters": {"network": {}, "exemptions": [{"name": "a"}, {"name": "b", "expires": "2021-01-01"}]}}`,
+			want: `{"parameters": {"mode": "allowlist", "locations": ["EU"], "logging": {"bucket": "logs"}, "network": {"name": "default"},
+				"exemptions": [{"name": "a", "expires": "never"}, {"name": "b", "expires": "2021-01-01"}]}}`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			constraint := &unstructured.Unstructured{Object: map[string]interface{}{}}
+			var spec, want map[string]interface{}
+			if err := yaml.Unmarshal([]byte(tc.spec), &spec); err != nil {
+				t.Fatal(err)
+			}
+			if err := yaml.Unmarshal([]byte(tc.want), &want); err != nil {
+				t.Fatal(err)
+			}
+			constraint.Object["spec"] = spec
+			applyParameterDefaults(constraint, schema)
+			if diff := cmp.Diff(want, constraint.Object["spec"]); diff != "" {
+				t.Errorf("unexpected spec (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	// Defaults are copied rather than shared between constraints.
+	first := &unstructured.Unstructured{Object: map[string]interface{}{"spec": map[string]interface{}{}}}
+	applyParameterDefaults(first, schema)
+	first.Object["spec"].(map[string]interface{})["parameters"].(map[string]interface{})["logging"].(map[string]interface{})["bucket"] = "changed"
+	second := &unstructured.Unstructured{Object: map[string]interface{}{"spec": map[string]interface{}{}}}
+	applyParameterDefaults(second, schema)
+	if bucket, _, _ := unstructured.NestedString(second.Object, "spec", "parameters", "logging", "bucket"); bucket != "logs" {
+		t.Errorf("got default bucket %q, want logs", bucket)
+	}
+}
@@ -0,0 +1,310 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configs
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	// PolicySetAPIVersion is the apiVersion of PolicySet manifests.
+	PolicySetAPIVersion = "policyset.forsetisecurity.org/v1alpha1"
+	// PolicySetKind is the kind of PolicySet manifests.
+	PolicySetKind = "PolicySet"
+	// PolicySetSuffix is the suffix of the paths of PolicySet manifests.
+	PolicySetSuffix = ".policyset.yaml"
+)
+
+// PolicySet is a manifest composing a policy set from policy directories, built in policy sets
+// and other PolicySet manifests, without forking them:
+//
+//	apiVersion: policyset.forsetisecurity.org/v1alpha1
+//	kind: PolicySet
+//	metadata:
+//	  name: prod
+//	spec:
+//	  # Paths are relative to the manifest. Included manifests are evaluated first, so a manifest
+//	  # can build on another one and adjust it further.
+//	  policies: [../policy-library/policies, builtin:cis, base.policyset.yaml]
+//	  # Optional library of the policies, defaults to the library of the included manifests.
+//	  library: ../policy-library/lib
+//	  # Only constraints of these bundles are kept, defaults to all constraints.
+//	  bundles: [cis-v1.1]
+//	  # Constraints to drop, by name or in [Kind].[Name] format.
+//	  disabled: [cis-3-1-no-default-network]
+//	  overrides:
+//	  - constraint: cis-3-6-no-ssh-from-internet
+//	    # Replaces the parameters of the same name, null removes a parameter.
+//	    parameters:
+//	      ports: ["22", "2222"]
+//	    severity: critical
+//
+// Policy paths ending in PolicySetSuffix name PolicySet manifests. Loading policy directories
+// ignores PolicySet documents.
+type PolicySet struct {
+	// Name is the name of the PolicySet.
+	Name string
+	// Path is the file the PolicySet was read from.
+	Path string
+	// Policies are the included paths, resolved relative to Path.
+	Policies []string
+	// Library is the library path, resolved relative to Path, if declared.
+	Library   string
+	Bundles   []string
+	Disabled  []string
+	Overrides []ConstraintOverride
+}
+
+// ConstraintOverride changes a constraint of a PolicySet.
+type ConstraintOverride struct {
+	// Constraint is the name of the constraint, by name or in [Kind].[Name] format.
+	Constraint string `json:"constraint"`
+	// Parameters replace the parameters of the constraint with the same name, nil values remove
+	// the parameter.
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+	// Severity replaces the severity of the constraint if set.
+	Severity string `json:"severity,omitempty"`
+}
+
+type policySetManifest struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Metadata   struct {
+		Name string `json:"name"`
+	} `json:"metadata"`
+	Spec struct {
+		Policies  []string             `json:"policies"`
+		Library   string               `json:"library"`
+		Bundles   []string             `json:"bundles"`
+		Disabled  []string             `json:"disabled"`
+		Overrides []ConstraintOverride `json:"overrides"`
+	} `json:"spec"`
+}
+
+// IsPolicySet returns true if path names a PolicySet manifest.
+func IsPolicySet(path string) bool {
+	return strings.HasSuffix(path, PolicySetSuffix)
+}
+
+// ReadPolicySet reads the PolicySet manifest at path, a local file or gs:// object.
+func ReadPolicySet(ctx context.Context, path string) (*PolicySet, error) {
+	content, found, err := ReadFile(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, errors.Errorf("policy set %s does not exist", path)
+	}
+	var manifest policySetManifest
+	if err := yaml.Unmarshal(content, &manifest); err != nil {
+		return nil, errors.Wrapf(err, "invalid policy set %s", path)
+	}
+	if manifest.APIVersion != PolicySetAPIVersion || manifest.Kind != PolicySetKind {
+		return nil, errors.Errorf("policy set %s is a %s %s, want %s %s",
+			path, manifest.APIVersion, manifest.Kind, PolicySetAPIVersion, PolicySetKind)
+	}
+	if len(manifest.Spec.Policies) == 0 {
+		return nil, errors.Errorf("policy set %s includes no policies", path)
+	}
+	set := &PolicySet{
+		Name:      manifest.Metadata.Name,
+		Path:      path,
+		Bundles:   manifest.Spec.Bundles,
+		Disabled:  manifest.Spec.Disabled,
+		Overrides: manifest.Spec.Overrides,
+	}
+	for _, policies := range manifest.Spec.Policies {
+		set.Policies = append(set.Policies, relativePath(path, policies))
+	}
+	if manifest.Spec.Library != "" {
+		set.Library = relativePath(path, manifest.Spec.Library)
+	}
+	for _, override := range set.Overrides {
+		if override.Constraint == "" {
+			return nil, errors.Errorf("policy set %s overrides a constraint without name", path)
+		}
+	}
+	return set, nil
+}
+
+// relativePath returns the policy path rel relative to the manifest at base, see RelativePath.
+// Built in policy sets are not relative to any manifest.
+func relativePath(base, rel string) string {
+	if IsBuiltin(rel) {
+		return rel
+	}
+	return RelativePath(base, rel)
+}
+
+// loadPolicies loads the templates and constraints from paths, evaluating PolicySet manifests,
+// and returns them with the library declared by the manifests. included holds the manifests
+// being evaluated, to detect cycles.
+func loadPolicies(ctx context.Context, paths []string, included []string) ([]*unstructured.Unstructured, string, error) {
+	var dirs, sets []string
+	for _, path := range paths {
+		switch {
+		case IsPolicySet(path):
+			sets = append(sets, path)
+		default:
+			dirs = append(dirs, path)
+		}
+	}
+
+	var objs []*unstructured.Unstructured
+	if len(dirs) != 0 {
+		dirObjs, err := LoadUnstructured(dirs)
+		if err != nil {
+			return nil, "", err
+		}
+		objs = dirObjs
+	}
+	var library string
+	for _, path := range sets {
+		setObjs, setLibrary, err := loadPolicySet(ctx, path, included)
+		if err != nil {
+			return nil, "", err
+		}
+		objs = append(objs, setObjs...)
+		if setLibrary == "" {
+			continue
+		}
+		if library != "" && library != setLibrary {
+			return nil, "", errors.Errorf("policy sets declare different libraries %s and %s", library, setLibrary)
+		}
+		library = setLibrary
+	}
+	return objs, library, nil
+}
+
+// loadPolicySet loads the templates and constraints of the PolicySet manifest at path.
+func loadPolicySet(ctx context.Context, path string, included []string) ([]*unstructured.Unstructured, string, error) {
+	for _, prev := range included {
+		if prev == path {
+			return nil, "", errors.Errorf("policy set %s includes itself through %s", path, strings.Join(included, ", "))
+		}
+	}
+	set, err := ReadPolicySet(ctx, path)
+	if err != nil {
+		return nil, "", err
+	}
+	objs, library, err := loadPolicies(ctx, set.Policies, append(append([]string{}, included...), path))
+	if err != nil {
+		return nil, "", errors.Wrapf(err, "policy set %s", path)
+	}
+	if set.Library != "" {
+		library = set.Library
+	}
+	if objs, err = set.apply(objs); err != nil {
+		return nil, "", errors.Wrapf(err, "policy set %s", path)
+	}
+	return objs, library, nil
+}
+
+// apply returns objs with the bundles, disabled constraints and overrides of s applied. Templates
+// are kept as they are.
+func (s *PolicySet) apply(objs []*unstructured.Unstructured) ([]*unstructured.Unstructured, error) {
+	var templates, constraints []*unstructured.Unstructured
+	for _, u := range objs {
+		if u.GroupVersionKind().Group == constraintGroup {
+			constraints = append(constraints, u)
+		} else {
+			templates = append(templates, u)
+		}
+	}
+
+	if len(s.Bundles) != 0 {
+		all := constraints
+		constraints = selectBundles(constraints, s.Bundles)
+		var empty []string
+		for _, bundle := range s.Bundles {
+			if !hasBundle(all, bundle) {
+				empty = append(empty, bundle)
+			}
+		}
+		if len(empty) != 0 {
+			sort.Strings(empty)
+			return nil, errors.Errorf("no constraints belong to bundles %s", strings.Join(empty, ", "))
+		}
+	}
+
+	disabled := map[string]bool{}
+	for _, name := range s.Disabled {
+		if findConstraint(constraints, name) == nil {
+			return nil, errors.Errorf("cannot disable unknown constraint %s", name)
+		}
+		disabled[name] = true
+	}
+	var enabled []*unstructured.Unstructured
+	for _, u := range constraints {
+		if !disabled[u.GetName()] && !disabled[u.GetKind()+"."+u.GetName()] {
+			enabled = append(enabled, u)
+		}
+	}
+
+	for _, override := range s.Overrides {
+		u := findConstraint(enabled, override.Constraint)
+		if u == nil {
+			return nil, errors.Errorf("cannot override unknown constraint %s", override.Constraint)
+		}
+		if err := override.apply(u); err != nil {
+			return nil, errors.Wrapf(err, "failed to override constraint %s", override.Constraint)
+		}
+	}
+	return append(templates, enabled...), nil
+}
+
+// findConstraint returns the constraint of constraints named name, by name or in [Kind].[Name]
+// format, nil if there is none.
+func findConstraint(constraints []*unstructured.Unstructured, name string) *unstructured.Unstructured {
+	for _, u := range constraints {
+		if u.GetName() == name || u.GetKind()+"."+u.GetName() == name {
+			return u
+		}
+	}
+	return nil
+}
+
+// apply changes the parameters and severity of constraint u.
+func (o *ConstraintOverride) apply(u *unstructured.Unstructured) error {
+	if len(o.Parameters) != 0 {
+		parameters, _, err := unstructured.NestedMap(u.Object, "spec", "parameters")
+		if err != nil {
+			return err
+		}
+		if parameters == nil {
+			parameters = map[string]interface{}{}
+		}
+		for name, value := range o.Parameters {
+			if value == nil {
+				delete(parameters, name)
+				continue
+			}
+			parameters[name] = value
+		}
+		if err := unstructured.SetNestedMap(u.Object, parameters, "spec", "parameters"); err != nil {
+			return err
+		}
+	}
+	if o.Severity != "" {
+		return unstructured.SetNestedField(u.Object, o.Severity, "spec", "severity")
+	}
+	return nil
+}
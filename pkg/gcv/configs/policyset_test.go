@@ -0,0 +1,172 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// writePolicySets writes the manifests by file name to a temporary directory and returns it.
+func writePolicySets(t *testing.T, manifests map[string]string) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "PolicySetTest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range manifests {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestPolicySet(t *testing.T) {
+	testPolicies, err := filepath.Abs("../../../test/cf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := writePolicySets(t, map[string]string{
+		"base" + PolicySetSuffix: `apiVersion: policyset.forsetisecurity.org/v1alpha1
+kind: PolicySet
+metadata:
+  name: base
+spec:
+  policies: [builtin:cis, ` + testPolicies + `]
+  library: ` + testPolicies + `/library
+  disabled: [cis-3-1-no-default-network]
+  overrides:
+  - constraint: cis-3-6-no-ssh-from-internet
+    parameters:
+      ports: ["22", "2222"]
+    severity: critical
+`,
+		"prod" + PolicySetSuffix: `apiVersion: policyset.forsetisecurity.org/v1alpha1
+kind: PolicySet
+metadata:
+  name: prod
+spec:
+  policies: [base.policyset.yaml]
+  bundles: [cis-v1.1]
+  disabled: [CISGCPFirewallOpenPortConstraint.cis-3-7-no-rdp-from-internet]
+`,
+	})
+	defer os.RemoveAll(dir)
+
+	// The library is declared by the included manifest.
+	config, err := NewConfiguration([]string{filepath.Join(dir, "prod"+PolicySetSuffix)}, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var names []string
+	var ssh *unstructured.Unstructured
+	for _, u := range append(config.GCPConstraints, config.K8SConstraints...) {
+		names = append(names, u.GetName())
+		if u.GetName() == "cis-3-6-no-ssh-from-internet" {
+			ssh = u
+		}
+	}
+	sort.Strings(names)
+	want := []string{
+		"cis-3-6-no-ssh-from-internet",
+		"cis-5-1-no-public-buckets",
+		"cis-5-2-bucket-uniform-access",
+		"cis-6-4-sql-require-ssl",
+		"cis-6-5-no-sql-public-network",
+		"cis-7-1-no-public-datasets",
+	}
+	if diff := cmp.Diff(want, names); diff != "" {
+		t.Errorf("unexpected constraints (-want +got):\n%s", diff)
+	}
+	if ssh == nil {
+		t.Fatal("constraint cis-3-6-no-ssh-from-internet not loaded")
+	}
+	wantSpec := map[string]interface{}{
+		"severity":   "critical",
+		"parameters": map[string]interface{}{"ports": []interface{}{"22", "2222"}},
+	}
+	if diff := cmp.Diff(wantSpec, ssh.Object["spec"]); diff != "" {
+		t.Errorf("unexpected overridden spec (-want +got):\n%s", diff)
+	}
+	// Templates of the included directories are kept.
+	if len(config.K8STemplates) == 0 {
+		t.Errorf("templates of %s were not loaded", testPolicies)
+	}
+}
+
+func TestPolicySetErrors(t *testing.T) {
+	manifest := func(spec string) string {
+		return "apiVersion: policyset.forsetisecurity.org/v1alpha1\nkind: PolicySet\nmetadata:\n  name: test\nspec:\n" + spec
+	}
+	var testCases = []struct {
+		name     string
+		manifest string
+		want     string
+	}{
+		{
+			name:     "wrong kind",
+			manifest: "apiVersion: policytest.forsetisecurity.org/v1alpha1\nkind: PolicyTest\n",
+			want:     "is a policytest.forsetisecurity.org/v1alpha1 PolicyTest",
+		},
+		{
+			name:     "no policies",
+			manifest: manifest("  disabled: [a]\n"),
+			want:     "includes no policies",
+		},
+		{
+			name:     "cycle",
+			manifest: manifest("  policies: [main.policyset.yaml]\n"),
+			want:     "includes itself",
+		},
+		{
+			name:     "unknown bundle",
+			manifest: manifest("  policies: [builtin:cis]\n  bundles: [cis-v1.3]\n"),
+			want:     "no constraints belong to bundles cis-v1.3",
+		},
+		{
+			name:     "unknown disabled constraint",
+			manifest: manifest("  policies: [builtin:cis]\n  disabled: [cis-3-2]\n"),
+			want:     "cannot disable unknown constraint cis-3-2",
+		},
+		{
+			name:     "unknown overridden constraint",
+			manifest: manifest("  policies: [builtin:cis]\n  overrides:\n  - constraint: cis-3-2\n    severity: low\n"),
+			want:     "cannot override unknown constraint cis-3-2",
+		},
+		{
+			name:     "invalid parameter",
+			manifest: manifest("  policies: [builtin:cis]\n  overrides:\n  - constraint: cis-3-6-no-ssh-from-internet\n    parameters:\n      ports: 22\n"),
+			want:     `"cis-3-6-no-ssh-from-internet" declared at path "builtin:cis/constraints/cis_gcp.yaml" does not match the parameters`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := writePolicySets(t, map[string]string{"main" + PolicySetSuffix: tc.manifest})
+			defer os.RemoveAll(dir)
+			_, err := NewConfiguration([]string{filepath.Join(dir, "main"+PolicySetSuffix)}, "")
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("got error %v, want error containing %q", err, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configs
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PolicySource identifies a path the policies of a Configuration were loaded from, so that a
+// review can be traced to the exact policy code that produced it.
+type PolicySource struct {
+	Path string `json:"path"`
+	// Library is set if the path is the library of the policies.
+	Library bool `json:"library,omitempty"`
+	// GitCommit is the commit checked out in the git repository holding a local path, if any.
+	// Changes that are not committed are not reflected.
+	GitCommit string `json:"git_commit,omitempty"`
+	// Digest is the hex encoded SHA-256 digest of the file at path, for PolicySet manifests.
+	Digest string `json:"digest,omitempty"`
+}
+
+// Sources returns the paths the policies of c were loaded from.
+func (c *Configuration) Sources() []PolicySource {
+	return c.sources
+}
+
+// newPolicySource returns the source of the policies at path, with the digest of content if the
+// policies were read from a single file.
+func newPolicySource(path string, content []byte) PolicySource {
+	source := PolicySource{Path: path}
+	if content != nil {
+		digest := sha256.Sum256(content)
+		source.Digest = hex.EncodeToString(digest[:])
+	}
+	if !IsBuiltin(path) && !strings.Contains(path, "://") {
+		source.GitCommit = gitCommit(path)
+	}
+	return source
+}
+
+// policySources returns the sources of the policies at paths and the library at libDir.
+func policySources(ctx context.Context, paths []string, libDir string) []PolicySource {
+	var sources []PolicySource
+	for _, path := range paths {
+		var content []byte
+		if IsPolicySet(path) {
+			// The manifest was read successfully when it was loaded.
+			content, _, _ = ReadFile(ctx, path)
+		}
+		sources = append(sources, newPolicySource(path, content))
+	}
+	if libDir != "" {
+		source := newPolicySource(libDir, nil)
+		source.Library = true
+		sources = append(sources, source)
+	}
+	return sources
+}
+
+// gitCommit returns the commit checked out in the git repository holding the local path, empty
+// if there is none.
+func gitCommit(path string) string {
+	dir, err := filepath.Abs(path)
+	if err != nil {
+		return ""
+	}
+	for {
+		gitDir := filepath.Join(dir, ".git")
+		if info, err := os.Stat(gitDir); err == nil {
+			if !info.IsDir() {
+				// Worktrees and submodules point to their git directory.
+				content, err := ioutil.ReadFile(gitDir)
+				if err != nil || !strings.HasPrefix(string(content), "gitdir:") {
+					return ""
+				}
+				gitDir = strings.TrimSpace(strings.TrimPrefix(string(content), "gitdir:"))
+				if !filepath.IsAbs(gitDir) {
+					gitDir = filepath.Join(dir, gitDir)
+				}
+			}
+			return headCommit(gitDir)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// headCommit returns the commit HEAD of the git directory refers to, empty if it cannot be
+// resolved.
+func headCommit(gitDir string) string {
+	content, err := ioutil.ReadFile(filepath.Join(gitDir, "HEAD"))
+	if err != nil {
+		return ""
+	}
+	head := strings.TrimSpace(string(content))
+	if !strings.HasPrefix(head, "ref: ") {
+		// A detached HEAD holds the commit itself.
+		return head
+	}
+	ref := strings.TrimPrefix(head, "ref: ")
+	// Worktrees keep the refs of branches in the common git directory.
+	dirs := []string{gitDir}
+	if commonDir, err := ioutil.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		common := strings.TrimSpace(string(commonDir))
+		if !filepath.IsAbs(common) {
+			common = filepath.Join(gitDir, common)
+		}
+		dirs = append(dirs, common)
+	}
+	for _, dir := range dirs {
+		if content, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(ref))); err == nil {
+			return strings.TrimSpace(string(content))
+		}
+		packed, err := ioutil.ReadFile(filepath.Join(dir, "packed-refs"))
+		if err != nil {
+			continue
+		}
+		for _, line := range strings.Split(string(packed), "\n") {
+			if fields := strings.Fields(line); len(fields) == 2 && fields[1] == ref {
+				return fields[0]
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,113 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGitCommit(t *testing.T) {
+	const (
+		loose  = "1111111111111111111111111111111111111111"
+		packed = "2222222222222222222222222222222222222222"
+	)
+	testCases := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{
+			name: "loose ref",
+			files: map[string]string{
+				".git/HEAD":            "ref: refs/heads/main\n",
+				".git/refs/heads/main": loose + "\n",
+				".git/packed-refs":     packed + " refs/heads/main\n",
+			},
+			want: loose,
+		},
+		{
+			name: "packed ref",
+			files: map[string]string{
+				".git/HEAD":        "ref: refs/heads/main\n",
+				".git/packed-refs": "# pack-refs with: peeled\n" + packed + " refs/heads/main\n",
+			},
+			want: packed,
+		},
+		{
+			name: "detached head",
+			files: map[string]string{
+				".git/HEAD": loose + "\n",
+			},
+			want: loose,
+		},
+		{
+			name: "worktree",
+			files: map[string]string{
+				".git":                        "gitdir: repo/worktrees/wt\n",
+				"repo/worktrees/wt/HEAD":      "ref: refs/heads/feature\n",
+				"repo/worktrees/wt/commondir": "../..\n",
+				"repo/refs/heads/feature":     packed + "\n",
+			},
+			want: packed,
+		},
+		{
+			name: "no repository",
+			files: map[string]string{
+				"policies/x.yaml": "",
+			},
+		},
+		{
+			name: "unknown ref",
+			files: map[string]string{
+				".git/HEAD": "ref: refs/heads/main\n",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "gitcommit")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer os.RemoveAll(dir)
+			for name, content := range tc.files {
+				path := filepath.Join(dir, filepath.FromSlash(name))
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+			if got := gitCommit(filepath.Join(dir, "policies")); got != tc.want {
+				t.Errorf("got commit %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestPolicySourceDigest(t *testing.T) {
+	source := newPolicySource("builtin:cis", []byte("policies"))
+	want := PolicySource{
+		Path:   "builtin:cis",
+		Digest: "5b3a02e57af9b55aae545b599baa6a6798a364d8d2798a38536249f77e30fe5f",
+	}
+	if source != want {
+		t.Errorf("got source %+v, want %+v", source, want)
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configs
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/forseti-security/config-validator/pkg/multierror"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	// RemediationText is the annotation holding human readable guidance on fixing violations of
+	// the constraint. It is reported with every violation of the constraint.
+	RemediationText = expectedTarget + "/remediation"
+	// RemediationURL is the annotation holding the http or https URL of documentation on fixing
+	// violations of the constraint.
+	RemediationURL = expectedTarget + "/remediationURL"
+)
+
+// Remediation is the guidance a constraint declares on fixing its violations.
+type Remediation struct {
+	Text string
+	URL  string
+}
+
+// ConstraintRemediation returns the remediation declared in the annotations of the constraint,
+// empty if there is none.
+func ConstraintRemediation(u *unstructured.Unstructured) (Remediation, error) {
+	remediation := Remediation{
+		Text: strings.TrimSpace(Annotation(u, RemediationText)),
+		URL:  strings.TrimSpace(Annotation(u, RemediationURL)),
+	}
+	if remediation.URL != "" {
+		parsed, err := url.Parse(remediation.URL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return Remediation{}, errors.Errorf(
+				"constraint %s has invalid %s annotation %q, expected an http or https URL",
+				u.GetName(), RemediationURL, remediation.URL)
+		}
+	}
+	return remediation, nil
+}
+
+// validateRemediations checks the remediation annotations of all constraints.
+func validateRemediations(constraints []*unstructured.Unstructured) error {
+	var errs multierror.Errors
+	for _, constraint := range constraints {
+		_, err := ConstraintRemediation(constraint)
+		errs.Add(err)
+	}
+	return errs.ToError()
+}
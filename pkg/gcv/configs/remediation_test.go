@@ -0,0 +1,76 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configs
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestConstraintRemediation(t *testing.T) {
+	var testCases = []struct {
+		name        string
+		annotations map[string]string
+		want        Remediation
+		wantErr     bool
+	}{
+		{
+			name: "no remediation",
+		},
+		{
+			name: "text and url",
+			annotations: map[string]string{
+				RemediationText: " Enable access logging on the bucket. ",
+				RemediationURL:  "https://cloud.google.com/storage/docs/access-logs",
+			},
+			want: Remediation{
+				Text: "Enable access logging on the bucket.",
+				URL:  "https://cloud.google.com/storage/docs/access-logs",
+			},
+		},
+		{
+			name:        "relative url",
+			annotations: map[string]string{RemediationURL: "docs/access-logs"},
+			wantErr:     true,
+		},
+		{
+			name:        "unsupported scheme",
+			annotations: map[string]string{RemediationURL: "javascript:alert(1)"},
+			wantErr:     true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ConstraintRemediation(aliasedConstraint("require-logging", tc.annotations))
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected remediation (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	valid := aliasedConstraint("valid", map[string]string{RemediationURL: "https://example.com/fix"})
+	invalid := aliasedConstraint("invalid", map[string]string{RemediationURL: "example.com/fix"})
+	if err := validateRemediations([]*unstructured.Unstructured{valid}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := validateRemediations([]*unstructured.Unstructured{valid, invalid}); err == nil {
+		t.Errorf("expected error for invalid remediation URL")
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configs
+
+import (
+	"sort"
+	"sync"
+
+	cftemplates "github.com/open-policy-agent/frameworks/constraint/pkg/core/templates"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var (
+	targetsMu sync.RWMutex
+	// targets holds the names of the targets registered with RegisterTarget.
+	targets = map[string]bool{}
+)
+
+// RegisterTarget lets configurations load templates for the Constraint Framework target name,
+// besides the GCP and Kubernetes targets. The templates and constraints of the target are kept
+// in TargetTemplates and TargetConstraints. Targets must be registered before the policies
+// using them are loaded, usually through gcv.RegisterTarget from an init function.
+func RegisterTarget(name string) {
+	targetsMu.Lock()
+	defer targetsMu.Unlock()
+	targets[name] = true
+}
+
+// IsRegisteredTarget returns true if name was registered with RegisterTarget.
+func IsRegisteredTarget(name string) bool {
+	targetsMu.RLock()
+	defer targetsMu.RUnlock()
+	return targets[name]
+}
+
+// targetNames returns the names of the targets of c registered with RegisterTarget, sorted.
+func (c *Configuration) targetNames() []string {
+	var names []string
+	for name := range c.TargetTemplates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// AllTemplates returns the GCP templates followed by the Kubernetes templates and the templates
+// of registered targets, in the order of their target names.
+func (c *Configuration) AllTemplates() []*cftemplates.ConstraintTemplate {
+	templates := append(append([]*cftemplates.ConstraintTemplate{}, c.GCPTemplates...), c.K8STemplates...)
+	for _, name := range c.targetNames() {
+		templates = append(templates, c.TargetTemplates[name]...)
+	}
+	return templates
+}
+
+// AllConstraints returns the GCP constraints followed by the Kubernetes constraints and the
+// constraints of registered targets, in the order of their target names.
+func (c *Configuration) AllConstraints() []*unstructured.Unstructured {
+	constraints := append(append([]*unstructured.Unstructured{}, c.GCPConstraints...), c.K8SConstraints...)
+	for _, name := range c.targetNames() {
+		constraints = append(constraints, c.TargetConstraints[name]...)
+	}
+	return constraints
+}
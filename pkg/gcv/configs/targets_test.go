@@ -0,0 +1,89 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const inventoryTemplate = `apiVersion: templates.gatekeeper.sh/v1beta1
+kind: ConstraintTemplate
+metadata:
+  name: inventorybucketencryption
+spec:
+  crd:
+    spec:
+      names:
+        kind: InventoryBucketEncryption
+  targets:
+    - target: validation.configtest.example.com
+      rego: |
+        package inventorybucketencryption
+
+        violation[{"msg": msg}] {
+          not input.review.encrypted
+          msg := "not encrypted"
+        }
+`
+
+const inventoryConstraint = `apiVersion: constraints.gatekeeper.sh/v1beta1
+kind: InventoryBucketEncryption
+metadata:
+  name: encrypted-buckets
+spec: {}
+`
+
+func TestRegisterTarget(t *testing.T) {
+	policyDir, err := ioutil.TempDir("", "RegisterTargetTest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(policyDir)
+	files := map[string]string{"template.yaml": inventoryTemplate, "constraint.yaml": inventoryConstraint}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(policyDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Templates of unknown targets are rejected.
+	if _, err := NewConfiguration([]string{policyDir}, "../../../test/cf/library"); err == nil ||
+		!strings.Contains(err.Error(), `unknown target "validation.configtest.example.com"`) {
+		t.Fatalf("got error %v, want unknown target", err)
+	}
+
+	RegisterTarget("validation.configtest.example.com")
+	config, err := NewConfiguration([]string{policyDir, "../../../test/cf"}, "../../../test/cf/library")
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	templates := config.TargetTemplates["validation.configtest.example.com"]
+	constraints := config.TargetConstraints["validation.configtest.example.com"]
+	if len(templates) != 1 || len(constraints) != 1 || constraints[0].GetName() != "encrypted-buckets" {
+		t.Fatalf("got templates %v and constraints %v of registered target", templates, constraints)
+	}
+	// The policies of registered targets follow the GCP and Kubernetes policies.
+	all := config.AllConstraints()
+	if len(all) != len(config.GCPConstraints)+len(config.K8SConstraints)+1 || all[len(all)-1] != constraints[0] {
+		t.Errorf("registered target constraints missing from AllConstraints")
+	}
+	if got, want := len(config.AllTemplates()), len(config.GCPTemplates)+len(config.K8STemplates)+1; got != want {
+		t.Errorf("got %d templates, want %d", got, want)
+	}
+}
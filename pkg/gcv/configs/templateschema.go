@@ -97,9 +97,9 @@ var constraintDefinitions = map[string]spec.Schema{
 					SchemaProps: spec.SchemaProps{
 						Type:                 objectType,
 						AdditionalProperties: &spec.SchemaOrBool{Allows: false},
-						Required:             []string{"rego"},
 						Properties: map[string]spec.Schema{
 							"rego": *spec.StringProperty(),
+							"cel":  *refProperty("#/definitions/celtarget"),
 							"libs": *spec.ArrayProperty(spec.StringProperty()),
 						},
 					},
@@ -107,6 +107,29 @@ var constraintDefinitions = map[string]spec.Schema{
 			},
 		},
 	},
+	// celtarget holds the CEL validations of a legacy target written in CEL rather than rego.
+	"celtarget": {
+		SchemaProps: spec.SchemaProps{
+			Type:                 objectType,
+			AdditionalProperties: &spec.SchemaOrBool{Allows: false},
+			Required:             []string{"validations"},
+			Properties: map[string]spec.Schema{
+				"assetTypes": *spec.ArrayProperty(spec.StringProperty()),
+				"validations": *spec.ArrayProperty(&spec.Schema{
+					SchemaProps: spec.SchemaProps{
+						Type:                 objectType,
+						AdditionalProperties: &spec.SchemaOrBool{Allows: false},
+						Required:             []string{"expression"},
+						Properties: map[string]spec.Schema{
+							"expression":        *spec.StringProperty(),
+							"message":           *spec.StringProperty(),
+							"messageExpression": *spec.StringProperty(),
+						},
+					},
+				}),
+			},
+		},
+	},
 	"betav1spec": {
 		SchemaProps: spec.SchemaProps{
 			Type:                 objectType,
@@ -0,0 +1,81 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Keys identifying duplicate assets for NewDeduplicator.
+const (
+	// DedupByName treats records with the same asset name as duplicates.
+	DedupByName = "name"
+	// DedupByNameAndType treats records with the same asset name and type as duplicates.
+	DedupByNameAndType = "name+asset_type"
+)
+
+// DedupKeys are the keys supported by NewDeduplicator.
+var DedupKeys = []string{DedupByName, DedupByNameAndType}
+
+// Deduplicator drops the assets that overlapping exports, such as an organization export and the
+// exports of its projects, contain more than once. Only the first record of an asset is kept.
+// Records of different content types of an asset, such as its resource and its IAM policy, are
+// not duplicates of each other. A Deduplicator holds the key of every asset it has seen and is
+// not safe for concurrent use.
+type Deduplicator struct {
+	withType bool
+	seen     map[string]struct{}
+	dropped  int
+}
+
+// NewDeduplicator returns a Deduplicator identifying assets by key, one of DedupKeys.
+func NewDeduplicator(key string) (*Deduplicator, error) {
+	switch key {
+	case DedupByName, DedupByNameAndType:
+		return &Deduplicator{withType: key == DedupByNameAndType, seen: map[string]struct{}{}}, nil
+	}
+	return nil, errors.Errorf("unknown dedup key %q, want one of %s", key, strings.Join(DedupKeys, ", "))
+}
+
+// Duplicate returns true if a record of asset was seen before, and counts it as dropped.
+// Otherwise the asset is recorded as seen.
+func (d *Deduplicator) Duplicate(asset map[string]interface{}) bool {
+	name, _, _ := unstructured.NestedString(asset, "name")
+	parts := []string{name}
+	if d.withType {
+		assetType, _, _ := unstructured.NestedString(asset, "asset_type")
+		parts = append(parts, assetType)
+	}
+	for _, field := range assetContentFields {
+		if asset[field] != nil {
+			parts = append(parts, field)
+		}
+	}
+	key := strings.Join(parts, "|")
+	if _, found := d.seen[key]; found {
+		d.dropped++
+		return true
+	}
+	d.seen[key] = struct{}{}
+	return false
+}
+
+// Dropped returns the number of duplicates found.
+func (d *Deduplicator) Dropped() int {
+	return d.dropped
+}
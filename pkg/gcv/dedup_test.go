@@ -0,0 +1,68 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"testing"
+)
+
+func TestDeduplicator(t *testing.T) {
+	bucket := map[string]interface{}{
+		"name":       "//storage.googleapis.com/b",
+		"asset_type": "storage.googleapis.com/Bucket",
+		"resource":   map[string]interface{}{},
+	}
+	bucketPolicy := map[string]interface{}{
+		"name":       "//storage.googleapis.com/b",
+		"asset_type": "storage.googleapis.com/Bucket",
+		"iam_policy": map[string]interface{}{},
+	}
+	// Not a real case, but tells the keys apart.
+	retyped := map[string]interface{}{
+		"name":       "//storage.googleapis.com/b",
+		"asset_type": "storage.googleapis.com/Other",
+		"resource":   map[string]interface{}{},
+	}
+	var testCases = []struct {
+		key  string
+		want []bool
+	}{
+		{key: DedupByName, want: []bool{false, false, true, true}},
+		{key: DedupByNameAndType, want: []bool{false, false, false, true}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.key, func(t *testing.T) {
+			d, err := NewDeduplicator(tc.key)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			wantDropped := 0
+			for idx, asset := range []map[string]interface{}{bucket, bucketPolicy, retyped, bucket} {
+				if got := d.Duplicate(asset); got != tc.want[idx] {
+					t.Errorf("asset %d: got duplicate %v, want %v", idx, got, tc.want[idx])
+				}
+				if tc.want[idx] {
+					wantDropped++
+				}
+			}
+			if d.Dropped() != wantDropped {
+				t.Errorf("got %d dropped, want %d", d.Dropped(), wantDropped)
+			}
+		})
+	}
+	if _, err := NewDeduplicator("type"); err == nil {
+		t.Error("expected error for unknown key")
+	}
+}
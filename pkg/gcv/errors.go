@@ -0,0 +1,99 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import "github.com/pkg/errors"
+
+// Error classes of the gcv package. errors.Cause returns the class of an error returned by the
+// package, so callers can branch on it with
+//
+//	switch errors.Cause(err) {
+//	case gcv.ErrInvalidAsset:
+//
+// or test for one class with errors.Is(err, gcv.ErrInvalidAsset) of the standard library.
+//
+// AsError returns the classified error itself, for example to inspect the
+// *asset.ValidationError of an invalid asset.
+var (
+	// ErrInvalidAsset is the class of errors for assets that fail validation.
+	ErrInvalidAsset = errors.New("invalid asset")
+	// ErrPolicyCompile is the class of errors for templates and constraints that cannot be loaded
+	// into the Constraint Framework.
+	ErrPolicyCompile = errors.New("policy compile error")
+	// ErrNoTargetResponse is the class of errors for assets that no target returned a response for.
+	ErrNoTargetResponse = errors.New("no target response")
+	// ErrConversion is the class of errors for assets and results that cannot be converted
+	// between their proto, JSON and Constraint Framework forms.
+	ErrConversion = errors.New("conversion error")
+	// ErrUnknownAncestry is the class of errors for assets whose ancestry could not be resolved,
+	// while constraints match assets by their ancestry.
+	ErrUnknownAncestry = errors.New("unknown ancestry")
+)
+
+// Error is an error of one of the classes ErrInvalidAsset, ErrPolicyCompile,
+// ErrNoTargetResponse, ErrConversion or ErrUnknownAncestry.
+type Error struct {
+	// Class is the class of the error.
+	Class error
+	// Err is the error itself.
+	Err error
+}
+
+// Error implements error.
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+// Cause returns the class of e, as used by errors.Cause.
+func (e *Error) Cause() error {
+	return e.Class
+}
+
+// Unwrap returns the error itself.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is the class of e, so that errors.Is matches classes like errors.Cause.
+func (e *Error) Is(target error) bool {
+	return target == e.Class
+}
+
+// AsError returns the classified error in the chain of causes of err, if any.
+func AsError(err error) (*Error, bool) {
+	for err != nil {
+		if e, ok := err.(*Error); ok {
+			return e, true
+		}
+		cause, ok := err.(interface{ Cause() error })
+		if !ok {
+			return nil, false
+		}
+		err = cause.Cause()
+	}
+	return nil, false
+}
+
+// classify returns err as an error of class, nil if err is nil. Errors that are already
+// classified keep their class.
+func classify(class error, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := AsError(err); ok {
+		return err
+	}
+	return &Error{Class: class, Err: err}
+}
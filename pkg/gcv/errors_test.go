@@ -0,0 +1,78 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+	goerrors "errors"
+	"testing"
+
+	"github.com/forseti-security/config-validator/pkg/asset"
+	"github.com/forseti-security/config-validator/pkg/gcptarget"
+	cftypes "github.com/open-policy-agent/frameworks/constraint/pkg/types"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestErrorClasses(t *testing.T) {
+	v, err := NewValidator(testOptions()...)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	ctx := context.Background()
+	_, invalidErr := v.ReviewJSON(ctx, `{"asset_type": "storage.googleapis.com/Bucket", "ancestry_path": "organizations/1", "resource": {}}`)
+	_, conversionErr := v.ReviewJSON(ctx, `{"name": `)
+	_, noResponseErr := NewResult(gcptarget.Name, map[string]interface{}{"name": "//compute.googleapis.com/i"}, nil, &cftypes.Responses{})
+	orphan := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "constraints.gatekeeper.sh/v1alpha1",
+		"kind":       "NoSuchConstraint",
+		"metadata":   map[string]interface{}{"name": "orphan"},
+	}}
+	_, compileErr := newCFClient(gcptarget.New(), nil, []*unstructured.Unstructured{orphan})
+
+	for _, tc := range []struct {
+		name  string
+		err   error
+		class error
+	}{
+		{name: "invalid asset", err: invalidErr, class: ErrInvalidAsset},
+		{name: "conversion", err: conversionErr, class: ErrConversion},
+		{name: "no target response", err: noResponseErr, class: ErrNoTargetResponse},
+		{name: "policy compile", err: errors.Wrap(compileErr, "context"), class: ErrPolicyCompile},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := errors.Cause(tc.err); got != tc.class {
+				t.Errorf("got class %v of error %v, want %v", got, tc.err, tc.class)
+			}
+			if e, ok := AsError(tc.err); !ok || e.Class != tc.class {
+				t.Errorf("AsError(%v) = %v, %v", tc.err, e, ok)
+			}
+			if !goerrors.Is(tc.err, tc.class) {
+				t.Errorf("errors.Is(%v, %v) = false, want true", tc.err, tc.class)
+			}
+			if goerrors.Is(tc.err, ErrUnknownAncestry) {
+				t.Errorf("errors.Is(%v, %v) = true, want false", tc.err, ErrUnknownAncestry)
+			}
+		})
+	}
+	if e, _ := AsError(invalidErr); e != nil {
+		if _, ok := e.Err.(*asset.ValidationError); !ok {
+			t.Errorf("got invalid asset error %T, want *asset.ValidationError", e.Err)
+		}
+	}
+	if _, ok := AsError(errors.New("unclassified")); ok {
+		t.Errorf("unclassified error has a class")
+	}
+}
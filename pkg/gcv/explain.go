@@ -0,0 +1,133 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+	"strings"
+
+	"github.com/forseti-security/config-validator/pkg/externaldata"
+	"github.com/forseti-security/config-validator/pkg/gcptarget"
+	"github.com/forseti-security/config-validator/pkg/gcv/configs"
+	"github.com/forseti-security/config-validator/pkg/k8sunwrap"
+	cfclient "github.com/open-policy-agent/frameworks/constraint/pkg/client"
+	cftemplates "github.com/open-policy-agent/frameworks/constraint/pkg/core/templates"
+	cftypes "github.com/open-policy-agent/frameworks/constraint/pkg/types"
+	k8starget "github.com/open-policy-agent/gatekeeper/pkg/target"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Explain reviews asset against the constraint named constraintName only and returns the result
+// together with the Rego evaluation trace, which shows why the constraint does or does not fire.
+// The constraint is matched by its name as written in the policy files or as converted for the
+// Constraint Framework.
+//
+// Explain loads the constraint and its template into a Constraint Framework client of its own,
+// so it is much slower than a review and meant for debugging. The result cache is not used, and
+// the asset does not see the inventory of a running ReviewInventory.
+func (v *Validator) Explain(ctx context.Context, constraintName string, asset map[string]interface{}) (*Result, string, error) {
+	if err := v.normalize(asset); err != nil {
+		return nil, "", err
+	}
+	v.inventoryMu.RLock()
+	defer v.inventoryMu.RUnlock()
+	ctx = externaldata.NewContext(ctx, v.providers)
+
+	isK8S := k8sunwrap.IsK8S(asset)
+	var handler cfclient.TargetHandler = gcptarget.New()
+	templates, constraints := v.config.GCPTemplates, v.config.GCPConstraints
+	if isK8S {
+		handler = &k8starget.K8sValidationTarget{}
+		templates, constraints = v.config.K8STemplates, v.config.K8SConstraints
+	}
+	constraint := findConstraint(constraints, constraintName)
+	if constraint == nil {
+		return nil, "", errors.Errorf("no constraint %q applies to %s", constraintName, AssetKey(asset))
+	}
+	template := findTemplate(templates, constraint.GetKind())
+	if template == nil {
+		return nil, "", errors.Errorf("no template for constraint %q of kind %s", constraintName, constraint.GetKind())
+	}
+	client, err := newCFClient(handler, []*cftemplates.ConstraintTemplate{template}, []*unstructured.Unstructured{constraint})
+	if err != nil {
+		return nil, "", err
+	}
+
+	reviewAsset, err := v.prepare(ctx, asset)
+	if err != nil {
+		return nil, "", err
+	}
+	var result *Result
+	var trace string
+	if isK8S {
+		k8sResource, err := k8sunwrap.Unwrap(reviewAsset)
+		if err != nil {
+			return nil, "", errors.Wrapf(err, "failed to convert asset to admission request")
+		}
+		// The items of lists are reviewed one by one, each with its own trace.
+		var traces []string
+		review := func(ctx context.Context, obj interface{}) (*cftypes.Responses, error) {
+			responses, err := client.Review(ctx, obj, cfclient.Tracing(true))
+			if err == nil {
+				traces = append(traces, responses.TraceDump())
+			}
+			return responses, err
+		}
+		responses, err := reviewK8SObject(ctx, k8sResource, review)
+		if err != nil {
+			return nil, "", err
+		}
+		trace = strings.Join(traces, "\n")
+		result, err = NewResult(configs.K8STargetName, reviewAsset, k8sResource.Object, responses)
+		if err != nil {
+			return nil, "", err
+		}
+	} else {
+		responses, err := client.Review(ctx, reviewAsset, cfclient.Tracing(true))
+		if err != nil {
+			return nil, "", errors.Wrapf(err, "GCP target Constraint Framework review call failed")
+		}
+		trace = responses.TraceDump()
+		result, err = NewResult(gcptarget.Name, reviewAsset, reviewAsset, responses)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	result.CAIResource = asset
+	result.PolicyVersion = v.PolicyVersion()
+	return result, trace, nil
+}
+
+// findConstraint returns the constraint with the given converted or original name, nil if there
+// is none.
+func findConstraint(constraints []*unstructured.Unstructured, name string) *unstructured.Unstructured {
+	for _, constraint := range constraints {
+		if constraint.GetName() == name || constraint.GetAnnotations()[configs.OriginalName] == name {
+			return constraint
+		}
+	}
+	return nil
+}
+
+// findTemplate returns the template defining kind, nil if there is none.
+func findTemplate(templates []*cftemplates.ConstraintTemplate, kind string) *cftemplates.ConstraintTemplate {
+	for _, template := range templates {
+		if template.Spec.CRD.Spec.Names.Kind == kind {
+			return template
+		}
+	}
+	return nil
+}
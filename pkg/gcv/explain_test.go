@@ -0,0 +1,82 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	var testCases = []struct {
+		name           string
+		constraint     string
+		assetJSON      string
+		wantViolations int
+	}{
+		{
+			name:           "original name",
+			constraint:     "require_storage_logging_XX",
+			assetJSON:      storageAssetNoLoggingJSON,
+			wantViolations: 1,
+		},
+		{
+			name:           "converted name",
+			constraint:     "require-storage-logging-xx",
+			assetJSON:      storageAssetWithLoggingJSON,
+			wantViolations: 0,
+		},
+		{
+			name:           "k8s constraint",
+			constraint:     "namespace-cost-center-label",
+			assetJSON:      namespaceAssetWithNoLabelJSON,
+			wantViolations: 1,
+		},
+	}
+	v, err := NewValidator(testOptions()...)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			asset := unmarshalAssets(t, tc.assetJSON)[0]
+			result, trace, err := v.Explain(context.Background(), tc.constraint, asset)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := len(result.ConstraintViolations); got != tc.wantViolations {
+				t.Errorf("got %d violations, want %d: %v", got, tc.wantViolations, result.ConstraintViolations)
+			}
+			if !strings.Contains(trace, "Enter") {
+				t.Errorf("got trace %q, want evaluation trace", trace)
+			}
+		})
+	}
+}
+
+func TestExplainUnknownConstraint(t *testing.T) {
+	v, err := NewValidator(testOptions()...)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	asset := unmarshalAssets(t, storageAssetNoLoggingJSON)[0]
+	// Kubernetes constraints do not apply to GCP assets.
+	for _, name := range []string{"no-such-constraint", "namespace-cost-center-label"} {
+		if _, _, err := v.Explain(context.Background(), name, asset); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
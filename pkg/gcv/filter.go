@@ -0,0 +1,133 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// FilterOptions selects the results and violations kept by FilterResults. Every field is a list of
+// accepted values, an empty list accepts everything. A result or violation is kept if it matches
+// all of the non-empty fields.
+type FilterOptions struct {
+	// Constraints are constraint names, either in "[Kind].[Name]" format or the name alone.
+	// Aliases of a constraint match as well.
+	Constraints []string
+	// ConstraintKinds are the kinds of constraints, the names of their templates.
+	ConstraintKinds []string
+	// Severities are constraint severities, compared case insensitively.
+	Severities []string
+	// AssetTypes are CAI asset types, such as storage.googleapis.com/Bucket.
+	AssetTypes []string
+	// AncestryPrefixes are prefixes of the ancestry path of an asset, matched on whole path
+	// segments so organizations/1 matches organizations/1/projects/2 but not organizations/12.
+	AncestryPrefixes []string
+}
+
+// filtersViolations returns true if opts selects violations as well as results.
+func (opts FilterOptions) filtersViolations() bool {
+	return len(opts.Constraints) != 0 || len(opts.ConstraintKinds) != 0 || len(opts.Severities) != 0
+}
+
+// FilterResults returns the results matching opts in a new slice. Results are kept if their asset
+// matches AssetTypes and AncestryPrefixes, with only the violations matching Constraints,
+// ConstraintKinds and Severities. If any of those is set, results left without violations are
+// dropped. Kept results are shallow copies, results is not modified.
+func FilterResults(results []*Result, opts FilterOptions) []*Result {
+	filtered := []*Result{}
+	for _, result := range results {
+		if !opts.matchesAsset(result) {
+			continue
+		}
+		kept := *result
+		if opts.filtersViolations() {
+			kept.ConstraintViolations = nil
+			for _, cv := range result.ConstraintViolations {
+				if opts.matchesViolation(&cv) {
+					kept.ConstraintViolations = append(kept.ConstraintViolations, cv)
+				}
+			}
+			if len(kept.ConstraintViolations) == 0 {
+				continue
+			}
+		}
+		filtered = append(filtered, &kept)
+	}
+	return filtered
+}
+
+func (opts FilterOptions) matchesAsset(result *Result) bool {
+	if len(opts.AssetTypes) != 0 {
+		assetType, _, _ := unstructured.NestedString(result.CAIResource, "asset_type")
+		if !containsString(opts.AssetTypes, assetType) {
+			return false
+		}
+	}
+	if len(opts.AncestryPrefixes) != 0 {
+		ancestryPath, _, _ := unstructured.NestedString(result.CAIResource, ancestryPathKey)
+		for _, prefix := range opts.AncestryPrefixes {
+			if hasAncestryPrefix(ancestryPath, prefix) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func (opts FilterOptions) matchesViolation(cv *ConstraintViolation) bool {
+	if len(opts.Constraints) != 0 && !opts.matchesConstraint(cv) {
+		return false
+	}
+	if len(opts.ConstraintKinds) != 0 && !containsString(opts.ConstraintKinds, cv.Constraint.GetKind()) {
+		return false
+	}
+	if len(opts.Severities) != 0 {
+		for _, severity := range opts.Severities {
+			if strings.EqualFold(severity, cv.Severity) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func (opts FilterOptions) matchesConstraint(cv *ConstraintViolation) bool {
+	prefix := cv.Constraint.GetKind() + "."
+	for _, name := range cv.names() {
+		if containsString(opts.Constraints, name) || containsString(opts.Constraints, strings.TrimPrefix(name, prefix)) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasAncestryPrefix returns true if the segments of prefix start ancestryPath.
+func hasAncestryPrefix(ancestryPath, prefix string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	return ancestryPath == prefix || strings.HasPrefix(ancestryPath, prefix+"/")
+}
+
+func containsString(values []string, s string) bool {
+	for _, value := range values {
+		if value == s {
+			return true
+		}
+	}
+	return false
+}
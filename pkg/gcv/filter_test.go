@@ -0,0 +1,155 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func filterViolation(kind, name, severity string) ConstraintViolation {
+	return ConstraintViolation{
+		Message:  name,
+		Severity: severity,
+		Constraint: &unstructured.Unstructured{Object: map[string]interface{}{
+			"kind":     kind,
+			"metadata": map[string]interface{}{"name": name},
+		}},
+	}
+}
+
+func TestFilterResults(t *testing.T) {
+	results := []*Result{
+		{
+			Name: "//storage.googleapis.com/logs",
+			CAIResource: map[string]interface{}{
+				"asset_type":    "storage.googleapis.com/Bucket",
+				ancestryPathKey: "organizations/1/projects/2",
+			},
+			ConstraintViolations: []ConstraintViolation{
+				filterViolation("GCPStorageLoggingConstraint", "require-logging", "high"),
+				filterViolation("GCPStorageLocationConstraint", "allowed-locations", "low"),
+			},
+		},
+		{
+			Name: "//compute.googleapis.com/vm",
+			CAIResource: map[string]interface{}{
+				"asset_type":    "compute.googleapis.com/Instance",
+				ancestryPathKey: "organizations/12/projects/3",
+			},
+			ConstraintViolations: []ConstraintViolation{
+				filterViolation("GCPComputeLocationConstraint", "allowed-locations", "Medium"),
+			},
+		},
+		{
+			Name: "//storage.googleapis.com/clean",
+			CAIResource: map[string]interface{}{
+				"asset_type":    "storage.googleapis.com/Bucket",
+				ancestryPathKey: "organizations/1/projects/4",
+			},
+		},
+	}
+
+	testCases := []struct {
+		name string
+		opts FilterOptions
+		// want maps the kept results to the messages of their violations.
+		want map[string][]string
+	}{
+		{
+			name: "no filters",
+			want: map[string][]string{
+				"//storage.googleapis.com/logs":  {"require-logging", "allowed-locations"},
+				"//compute.googleapis.com/vm":    {"allowed-locations"},
+				"//storage.googleapis.com/clean": nil,
+			},
+		},
+		{
+			name: "constraint name",
+			opts: FilterOptions{Constraints: []string{"allowed-locations"}},
+			want: map[string][]string{
+				"//storage.googleapis.com/logs": {"allowed-locations"},
+				"//compute.googleapis.com/vm":   {"allowed-locations"},
+			},
+		},
+		{
+			name: "constraint kind and name",
+			opts: FilterOptions{Constraints: []string{"GCPComputeLocationConstraint.allowed-locations"}},
+			want: map[string][]string{
+				"//compute.googleapis.com/vm": {"allowed-locations"},
+			},
+		},
+		{
+			name: "constraint kind",
+			opts: FilterOptions{ConstraintKinds: []string{"GCPStorageLoggingConstraint"}},
+			want: map[string][]string{
+				"//storage.googleapis.com/logs": {"require-logging"},
+			},
+		},
+		{
+			name: "severity",
+			opts: FilterOptions{Severities: []string{"medium", "high"}},
+			want: map[string][]string{
+				"//storage.googleapis.com/logs": {"require-logging"},
+				"//compute.googleapis.com/vm":   {"allowed-locations"},
+			},
+		},
+		{
+			name: "asset type",
+			opts: FilterOptions{AssetTypes: []string{"storage.googleapis.com/Bucket"}},
+			want: map[string][]string{
+				"//storage.googleapis.com/logs":  {"require-logging", "allowed-locations"},
+				"//storage.googleapis.com/clean": nil,
+			},
+		},
+		{
+			name: "ancestry prefix",
+			opts: FilterOptions{AncestryPrefixes: []string{"organizations/1/"}},
+			want: map[string][]string{
+				"//storage.googleapis.com/logs":  {"require-logging", "allowed-locations"},
+				"//storage.googleapis.com/clean": nil,
+			},
+		},
+		{
+			name: "combined",
+			opts: FilterOptions{AncestryPrefixes: []string{"organizations/1"}, Severities: []string{"low"}},
+			want: map[string][]string{
+				"//storage.googleapis.com/logs": {"allowed-locations"},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := map[string][]string{}
+			for _, result := range FilterResults(results, tc.opts) {
+				var messages []string
+				for _, cv := range result.ConstraintViolations {
+					messages = append(messages, cv.Message)
+				}
+				got[result.Name] = messages
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected results (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	// The input is left as is.
+	if len(results[0].ConstraintViolations) != 2 {
+		t.Errorf("results were modified: %v", results[0].ConstraintViolations)
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// assetContentFields are the mutually exclusive fields of a CAI record holding the asset content.
+// CAI exports the resource and its IAM policy as separate records with the same name, so the
+// present content field is part of the identity of a record.
+var assetContentFields = []string{
+	"resource", "iam_policy", "org_policy", "access_policy", "access_level", "service_perimeter",
+}
+
+// Manifest records the outcome of a review run so that a later run can skip assets that did not
+// change.
+type Manifest struct {
+	// PolicyVersion is the content hash of the policies used for the review. Results are only
+	// reused if the policies did not change.
+	PolicyVersion string `json:"policy_version"`
+	// Assets holds one entry per reviewed asset record, keyed by AssetKey.
+	Assets map[string]*ManifestAsset `json:"assets"`
+}
+
+// ManifestAsset is the manifest entry for a single asset record.
+type ManifestAsset struct {
+	// Hash is the content hash of the asset as it was provided for review.
+	Hash string `json:"hash"`
+	// Violations are the fingerprints of the violations found for the asset.
+	Violations []string `json:"violations,omitempty"`
+}
+
+// ReadManifest reads a JSON encoded manifest.
+func ReadManifest(r io.Reader) (*Manifest, error) {
+	m := &Manifest{}
+	if err := json.NewDecoder(r).Decode(m); err != nil {
+		return nil, errors.Wrapf(err, "failed to decode manifest")
+	}
+	if m.Assets == nil {
+		m.Assets = map[string]*ManifestAsset{}
+	}
+	return m, nil
+}
+
+// Write writes the manifest as JSON.
+func (m *Manifest) Write(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return errors.Wrapf(encoder.Encode(m), "failed to encode manifest")
+}
+
+// IncrementalResult is the outcome of ReviewIncremental.
+type IncrementalResult struct {
+	// Results are the results for assets that were new or changed since the previous run.
+	Results []*Result
+	// Unchanged are the keys of assets whose previous results were carried over.
+	Unchanged []string
+	// Removed are the keys of assets present in the previous manifest but not in this run.
+	Removed []string
+	// Manifest describes this run, merging the carried over and the newly computed violations.
+	Manifest *Manifest
+}
+
+// AssetKey returns the key identifying a CAI asset record in a Manifest.
+func AssetKey(asset map[string]interface{}) string {
+	name, _, _ := unstructured.NestedString(asset, "name")
+	assetType, _, _ := unstructured.NestedString(asset, "asset_type")
+	parts := []string{name, assetType}
+	for _, field := range assetContentFields {
+		if asset[field] != nil {
+			parts = append(parts, field)
+		}
+	}
+	return strings.Join(parts, "|")
+}
+
+// hashAsset returns the hex encoded SHA-256 digest of the JSON representation of asset.
+func hashAsset(asset map[string]interface{}) (string, error) {
+	assetBytes, err := json.Marshal(asset)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to marshal asset for hashing")
+	}
+	digest := sha256.Sum256(assetBytes)
+	return hex.EncodeToString(digest[:]), nil
+}
+
+// ReviewIncremental reviews assets, reusing the outcome recorded in previous for assets that have
+// not changed since the previous run. If previous is nil or was produced with a different policy
+// set, all assets are reviewed.
+func (v *Validator) ReviewIncremental(
+	ctx context.Context, previous *Manifest, assets []map[string]interface{}) (*IncrementalResult, error) {
+	if previous != nil && previous.PolicyVersion != v.policyVersion {
+		previous = nil
+	}
+
+	incremental := &IncrementalResult{
+		Manifest: &Manifest{
+			PolicyVersion: v.policyVersion,
+			Assets:        map[string]*ManifestAsset{},
+		},
+	}
+	for _, asset := range assets {
+		key := AssetKey(asset)
+		hash, err := hashAsset(asset)
+		if err != nil {
+			return nil, errors.Wrapf(err, "asset %s", key)
+		}
+
+		if previous != nil {
+			if prev, found := previous.Assets[key]; found && prev.Hash == hash {
+				incremental.Manifest.Assets[key] = prev
+				incremental.Unchanged = append(incremental.Unchanged, key)
+				continue
+			}
+		}
+
+		result, err := v.ReviewUnmarshalledJSON(ctx, asset)
+		if err != nil {
+			return nil, errors.Wrapf(err, "asset %s", key)
+		}
+		entry := &ManifestAsset{Hash: hash}
+		for idx := range result.ConstraintViolations {
+			entry.Violations = append(entry.Violations, result.ConstraintViolations[idx].Fingerprint(result.Name))
+		}
+		incremental.Manifest.Assets[key] = entry
+		incremental.Results = append(incremental.Results, result)
+	}
+
+	if previous != nil {
+		for key := range previous.Assets {
+			if _, found := incremental.Manifest.Assets[key]; !found {
+				incremental.Removed = append(incremental.Removed, key)
+			}
+		}
+		sort.Strings(incremental.Removed)
+	}
+	return incremental, nil
+}
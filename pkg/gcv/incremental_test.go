@@ -0,0 +1,130 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func mustUnmarshalAssets(t *testing.T, assetJSONs ...string) []map[string]interface{} {
+	var assets []map[string]interface{}
+	for _, assetJSON := range assetJSONs {
+		asset := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(assetJSON), &asset); err != nil {
+			t.Fatal("unexpected error", err)
+		}
+		assets = append(assets, asset)
+	}
+	return assets
+}
+
+func TestReviewIncremental(t *testing.T) {
+	v, err := NewValidator(testOptions()...)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	ctx := context.Background()
+
+	first, err := v.ReviewIncremental(ctx, nil, mustUnmarshalAssets(t,
+		storageAssetNoLoggingJSON, storageAssetWithLoggingJSON, namespaceAssetWithNoLabelJSON))
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if len(first.Results) != 3 || len(first.Unchanged) != 0 {
+		t.Fatalf("first run got %d results %d unchanged, want 3, 0", len(first.Results), len(first.Unchanged))
+	}
+
+	// Round trip the manifest to make sure it survives serialization.
+	var buf bytes.Buffer
+	if err := first.Manifest.Write(&buf); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	previous, err := ReadManifest(&buf)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if diff := cmp.Diff(previous, first.Manifest); diff != "" {
+		t.Errorf("manifest round trip mismatch, +got -want\n%s", diff)
+	}
+
+	// Change the logging bucket so it now violates, keep the namespace and drop the other bucket.
+	changed := mustUnmarshalAssets(t, storageAssetWithLoggingJSON, namespaceAssetWithNoLabelJSON)
+	data := changed[0]["resource"].(map[string]interface{})["data"].(map[string]interface{})
+	data["logging"] = map[string]interface{}{}
+	second, err := v.ReviewIncremental(ctx, previous, changed)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	if len(second.Results) != 1 || second.Results[0].Name != "//storage.googleapis.com/my-storage-bucket-with-logging" {
+		t.Errorf("expected only the changed bucket to be reviewed, got %v", second.Results)
+	}
+	wantUnchanged := []string{AssetKey(changed[1])}
+	if diff := cmp.Diff(second.Unchanged, wantUnchanged); diff != "" {
+		t.Errorf("unchanged mismatch, +got -want\n%s", diff)
+	}
+	wantRemoved := []string{AssetKey(mustUnmarshalAssets(t, storageAssetNoLoggingJSON)[0])}
+	if diff := cmp.Diff(second.Removed, wantRemoved); diff != "" {
+		t.Errorf("removed mismatch, +got -want\n%s", diff)
+	}
+	if got := len(second.Manifest.Assets[AssetKey(changed[0])].Violations); got != 2 {
+		t.Errorf("got %d violations for changed bucket, want 2", got)
+	}
+	if got := len(second.Manifest.Assets[AssetKey(changed[1])].Violations); got != 1 {
+		t.Errorf("got %d carried over violations for namespace, want 1", got)
+	}
+
+	// A manifest from a different policy set forces a full review.
+	previous.PolicyVersion = "other"
+	third, err := v.ReviewIncremental(ctx, previous, mustUnmarshalAssets(t, namespaceAssetWithNoLabelJSON))
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if len(third.Results) != 1 || len(third.Unchanged) != 0 || len(third.Removed) != 0 {
+		t.Errorf("expected full review on policy change, got %d results %d unchanged %d removed",
+			len(third.Results), len(third.Unchanged), len(third.Removed))
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	v, err := NewValidator(testOptions()...)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	result, err := v.ReviewJSON(context.Background(), storageAssetNoLoggingJSON)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	violations, err := result.ToViolations()
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	seen := map[string]bool{}
+	for idx, violation := range violations {
+		got := ViolationFingerprint(violation)
+		if want := result.ConstraintViolations[idx].Fingerprint(result.Name); got != want {
+			t.Errorf("violation fingerprint %s does not match constraint violation fingerprint %s", got, want)
+		}
+		seen[got] = true
+	}
+	if len(seen) != len(violations) {
+		t.Errorf("expected distinct fingerprints, got %v", seen)
+	}
+}
@@ -14,7 +14,15 @@
 
 package gcv
 
-import "time"
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// DefaultMaxTargetResources is the number of target resources AggregateInsights puts in one
+// insight by default, which keeps insights well within the limits of the Recommender API.
+const DefaultMaxTargetResources = 100
 
 // Insight is modeled after the cloud recommender insight.
 type Insight struct {
@@ -59,13 +67,101 @@ type Insight struct {
 	// Category for the insight, scanners may populate this member.
 	// One of: COST, SECURITY, PERFORMANCE, MANAGEABILITY
 	Category string `json:"category,omitempty"`
+
+	// PolicyVersion is the version of the policy set the insight was generated with.
+	PolicyVersion string `json:"policy_version,omitempty"`
+
+	// Etag is the fingerprint of the stored insight, used for optimistic concurrency control when
+	// updating it.  Scanners must not populate this member.
+	Etag string `json:"etag,omitempty"`
 }
 
 // StateInfo is the state of the data.
 type StateInfo struct {
-	// State is the name of the insight state, one of ACTIVE, ACCEPTED, DISMISSED
+	// State is the name of the insight state, one of ACTIVE, ACCEPTED, DISMISSED, INACTIVE
 	State string `json:"state,omitempty"`
 
 	// StateMetadata is a user-extensible key-value map for holding arbitrary data.
 	StateMetadata map[string]string `json:"state_metadata,omitempty"`
 }
+
+// AggregateInsights returns the violations of results as insights with one insight per
+// constraint rather than one per violation, so a constraint matching thousands of resources does
+// not produce thousands of insights. Each insight targets at most maxTargets resources, or
+// DefaultMaxTargetResources if maxTargets is not positive. Constraints violated by more resources
+// are reported in several insights. Resources violating a constraint more than once are targeted
+// once, with all of their violations listed in the content. Insights are ordered by subtype, and
+// targets by name.
+func AggregateInsights(results []*Result, maxTargets int) []*Insight {
+	if maxTargets <= 0 {
+		maxTargets = DefaultMaxTargetResources
+	}
+	type group struct {
+		cv         *ConstraintViolation
+		violations map[string][]interface{}
+		version    string
+	}
+	groups := map[string]*group{}
+	for _, r := range results {
+		for idx := range r.ConstraintViolations {
+			cv := &r.ConstraintViolations[idx]
+			for _, name := range cv.names() {
+				g, found := groups[name]
+				if !found {
+					g = &group{cv: cv, violations: map[string][]interface{}{}, version: r.PolicyVersion}
+					groups[name] = g
+				}
+				g.violations[r.Name] = append(g.violations[r.Name], map[string]interface{}{
+					"message": cv.Message,
+					"details": cv.Metadata[DetailsKey],
+				})
+			}
+		}
+	}
+
+	var subtypes []string
+	for subtype := range groups {
+		subtypes = append(subtypes, subtype)
+	}
+	sort.Strings(subtypes)
+	var insights []*Insight
+	for _, subtype := range subtypes {
+		g := groups[subtype]
+		var targets []string
+		for target := range g.violations {
+			targets = append(targets, target)
+		}
+		sort.Strings(targets)
+		for start := 0; start < len(targets); start += maxTargets {
+			end := start + maxTargets
+			if end > len(targets) {
+				end = len(targets)
+			}
+			chunk := targets[start:end]
+			violations := map[string]interface{}{}
+			for _, target := range chunk {
+				violations[target] = g.violations[target]
+			}
+			content := map[string]interface{}{
+				"constraint": g.cv.metadata(nil)[ConstraintKey],
+				"violations": violations,
+			}
+			if remediation := g.cv.remediationContent(); remediation != nil {
+				content["remediation"] = remediation
+			}
+			description := fmt.Sprintf("%d resources violate %s", len(targets), subtype)
+			if len(targets) == 1 {
+				description = fmt.Sprintf("1 resource violates %s", subtype)
+			}
+			insights = append(insights, &Insight{
+				Description:     description,
+				TargetResources: append([]string(nil), chunk...),
+				InsightSubtype:  subtype,
+				Content:         content,
+				Category:        "SECURITY",
+				PolicyVersion:   g.version,
+			})
+		}
+	}
+	return insights
+}
@@ -0,0 +1,81 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestAggregateInsights(t *testing.T) {
+	var results []*Result
+	for idx := 0; idx < 5; idx++ {
+		results = append(results, &Result{
+			Name:          fmt.Sprintf("//storage.googleapis.com/bucket-%d", idx),
+			PolicyVersion: "1.0.0",
+			ConstraintViolations: []ConstraintViolation{
+				filterViolation("GCPStorageLoggingConstraint", "require-logging", "high"),
+			},
+		})
+	}
+	// The second violation of the same constraint on bucket-0 does not add a target.
+	second := filterViolation("GCPStorageLoggingConstraint", "require-logging", "high")
+	second.Message = "second"
+	results[0].ConstraintViolations = append(results[0].ConstraintViolations, second)
+	results[1].ConstraintViolations = append(results[1].ConstraintViolations,
+		filterViolation("GCPStorageLocationConstraint", "allowed-locations", "low"))
+
+	insights := AggregateInsights(results, 2)
+	type summary struct {
+		Subtype     string
+		Description string
+		Targets     []string
+	}
+	var got []summary
+	for _, insight := range insights {
+		got = append(got, summary{insight.InsightSubtype, insight.Description, insight.TargetResources})
+	}
+	want := []summary{
+		{"GCPStorageLocationConstraint.allowed-locations", "1 resource violates GCPStorageLocationConstraint.allowed-locations",
+			[]string{"//storage.googleapis.com/bucket-1"}},
+		{"GCPStorageLoggingConstraint.require-logging", "5 resources violate GCPStorageLoggingConstraint.require-logging",
+			[]string{"//storage.googleapis.com/bucket-0", "//storage.googleapis.com/bucket-1"}},
+		{"GCPStorageLoggingConstraint.require-logging", "5 resources violate GCPStorageLoggingConstraint.require-logging",
+			[]string{"//storage.googleapis.com/bucket-2", "//storage.googleapis.com/bucket-3"}},
+		{"GCPStorageLoggingConstraint.require-logging", "5 resources violate GCPStorageLoggingConstraint.require-logging",
+			[]string{"//storage.googleapis.com/bucket-4"}},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected insights (-want +got):\n%s", diff)
+	}
+
+	violations := insights[1].Content.(map[string]interface{})["violations"].(map[string]interface{})
+	wantViolations := []interface{}{
+		map[string]interface{}{"message": "require-logging", "details": nil},
+		map[string]interface{}{"message": "second", "details": nil},
+	}
+	if diff := cmp.Diff(wantViolations, violations["//storage.googleapis.com/bucket-0"]); diff != "" {
+		t.Errorf("unexpected violations (-want +got):\n%s", diff)
+	}
+	if insights[0].PolicyVersion != "1.0.0" || insights[0].Category != "SECURITY" {
+		t.Errorf("got insight %+v", insights[0])
+	}
+
+	if got := len(AggregateInsights(results, 0)); got != 2 {
+		t.Errorf("got %d insights with the default limit, want 2", got)
+	}
+}
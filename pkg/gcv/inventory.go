@@ -0,0 +1,88 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+
+	"github.com/forseti-security/config-validator/pkg/externaldata"
+	"github.com/forseti-security/config-validator/pkg/gcptarget"
+	"github.com/forseti-security/config-validator/pkg/logging"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// ReviewInventory reviews assets as one transaction. All assets are loaded into the inventory of
+// the GCP target before the first one is reviewed, so templates can reference other assets as
+// data.inventory[asset_type][name]. The inventory holds the assets after enrichment and
+// transforms. Kubernetes assets are part of the inventory, but are reviewed by the Kubernetes
+// target, which does not see it.
+//
+// Other reviews wait until the transaction is done and the result cache is not used, as results
+// depend on the whole inventory. Data providers are called at most once per key for the whole
+// transaction. Results are in the order of assets.
+func (v *Validator) ReviewInventory(ctx context.Context, assets []map[string]interface{}) ([]*Result, error) {
+	v.inventoryMu.Lock()
+	defer v.inventoryMu.Unlock()
+	ctx = externaldata.NewContext(ctx, v.providers)
+
+	inventory := gcptarget.Inventory{}
+	reviewAssets := make([]map[string]interface{}, len(assets))
+	assetTargets := make([]string, len(assets))
+	for idx, asset := range assets {
+		target, err := v.targetOf(asset)
+		if err != nil {
+			return nil, errors.Wrapf(err, "asset %s", AssetKey(asset))
+		}
+		if isRegisteredTarget(target) {
+			return nil, errors.Errorf("asset %s is handled by target %s, which does not support inventory reviews", AssetKey(asset), target)
+		}
+		assetTargets[idx] = target
+		if err := v.normalize(asset); err != nil {
+			return nil, errors.Wrapf(err, "asset %s", AssetKey(asset))
+		}
+		reviewAsset, err := v.prepare(ctx, asset)
+		if err != nil {
+			return nil, errors.Wrapf(err, "asset %s", AssetKey(asset))
+		}
+		if err := inventory.Add(reviewAsset); err != nil {
+			return nil, err
+		}
+		reviewAssets[idx] = reviewAsset
+	}
+
+	if err := v.gcpCFClient.AddData(ctx, inventory); err != nil {
+		return nil, errors.Wrapf(err, "failed to load inventory")
+	}
+	defer func() {
+		// The inventory must not leak into later reviews.
+		if err := v.gcpCFClient.RemoveData(context.Background(), inventory); err != nil {
+			logging.FromContext(ctx).Error("failed to remove inventory", zap.Error(err))
+		}
+	}()
+
+	results := make([]*Result, len(assets))
+	for idx, asset := range assets {
+		if err := ctx.Err(); err != nil {
+			return nil, errors.Wrapf(err, "review cancelled")
+		}
+		result, err := v.reviewPrepared(ctx, asset, reviewAssets[idx], assetTargets[idx])
+		if err != nil {
+			return nil, errors.Wrapf(err, "asset %s", AssetKey(asset))
+		}
+		results[idx] = result
+	}
+	return results, nil
+}
@@ -0,0 +1,148 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	asset2 "github.com/forseti-security/config-validator/pkg/asset"
+)
+
+const testNetwork = "https://www.googleapis.com/compute/v1/projects/p/global/networks/default"
+
+// openFirewallJSON is a firewall rule allowing ingress from anywhere to testNetwork.
+const openFirewallJSON = `{
+  "name": "//compute.googleapis.com/projects/p/global/firewalls/allow-all",
+  "asset_type": "compute.googleapis.com/Firewall",
+  "ancestors": ["projects/1", "organizations/2"],
+  "resource": {
+    "version": "v1",
+    "data": {
+      "name": "allow-all",
+      "direction": "INGRESS",
+      "network": "` + testNetwork + `",
+      "sourceRanges": ["0.0.0.0/0"]
+    }
+  }
+}`
+
+// instanceJSON is an instance on network, with a public IP if public is set.
+func instanceJSON(name, network string, public bool) string {
+	accessConfigs := "[]"
+	if public {
+		accessConfigs = `[{"name": "External NAT", "natIP": "203.0.113.1"}]`
+	}
+	return fmt.Sprintf(`{
+  "name": "//compute.googleapis.com/projects/p/zones/us-central1-a/instances/%s",
+  "asset_type": "compute.googleapis.com/Instance",
+  "ancestors": ["projects/1", "organizations/2"],
+  "resource": {
+    "version": "v1",
+    "data": {
+      "name": "%s",
+      "networkInterfaces": [{"network": "%s", "accessConfigs": %s}]
+    }
+  }
+}`, name, name, network, accessConfigs)
+}
+
+func unmarshalAssets(t *testing.T, assetJSONs ...string) []map[string]interface{} {
+	var assets []map[string]interface{}
+	for _, assetJSON := range assetJSONs {
+		asset := map[string]interface{}{}
+		if err := asset2.UnmarshalJSON([]byte(assetJSON), &asset); err != nil {
+			t.Fatal(err)
+		}
+		assets = append(assets, asset)
+	}
+	return assets
+}
+
+func TestReviewInventory(t *testing.T) {
+	var testCases = []struct {
+		name    string
+		assets  []string
+		wantFor map[int]int
+	}{
+		{
+			name:    "public instance on open network",
+			assets:  []string{openFirewallJSON, instanceJSON("web", testNetwork, true), instanceJSON("db", testNetwork, false)},
+			wantFor: map[int]int{0: 1},
+		},
+		{
+			name:    "no public instance",
+			assets:  []string{openFirewallJSON, instanceJSON("db", testNetwork, false)},
+			wantFor: map[int]int{},
+		},
+		{
+			name:    "public instance on other network",
+			assets:  []string{openFirewallJSON, instanceJSON("web", "other", true)},
+			wantFor: map[int]int{},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			v, err := NewValidator(featureTestOptions()...)
+			if err != nil {
+				t.Fatal("unexpected error", err)
+			}
+			results, err := v.ReviewInventory(context.Background(), unmarshalAssets(t, tc.assets...))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(results) != len(tc.assets) {
+				t.Fatalf("got %d results, want %d", len(results), len(tc.assets))
+			}
+			for idx, result := range results {
+				if got := len(result.ConstraintViolations); got != tc.wantFor[idx] {
+					t.Errorf("asset %d: got %d violations, want %d: %v", idx, got, tc.wantFor[idx], result.ConstraintViolations)
+				}
+			}
+		})
+	}
+}
+
+func TestReviewInventoryIsRemoved(t *testing.T) {
+	v, err := NewValidator(featureTestOptions()...)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	assets := unmarshalAssets(t, openFirewallJSON, instanceJSON("web", testNetwork, true))
+	if _, err := v.ReviewInventory(context.Background(), assets); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Reviewed on its own, the firewall does not see the instance anymore.
+	result, err := v.ReviewJSON(context.Background(), openFirewallJSON)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(result.ConstraintViolations) != 0 {
+		t.Errorf("got violations %v after the inventory was removed", result.ConstraintViolations)
+	}
+}
+
+func TestReviewInventoryError(t *testing.T) {
+	v, err := NewValidator(featureTestOptions()...)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	assets := unmarshalAssets(t, openFirewallJSON, `{"name": "no-ancestry", "asset_type": "t", "resource": {}}`)
+	if _, err := v.ReviewInventory(context.Background(), assets); err == nil {
+		t.Errorf("expected error for asset without ancestry")
+	}
+}
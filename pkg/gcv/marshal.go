@@ -0,0 +1,126 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"encoding/json"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ResultSchemaVersion is the version of the JSON and YAML form of results. The version changes
+// only with incompatible changes to ResultOutput and ViolationOutput, new fields may be added
+// within a version.
+const ResultSchemaVersion = "v1"
+
+// ResultOutput is the JSON and YAML form of a Result.
+type ResultOutput struct {
+	// SchemaVersion is ResultSchemaVersion.
+	SchemaVersion string `json:"schema_version" yaml:"schema_version"`
+	// Name is the name of the asset.
+	Name string `json:"name" yaml:"name"`
+	// AssetType is the CAI type of the asset.
+	AssetType string `json:"asset_type,omitempty" yaml:"asset_type,omitempty"`
+	// AncestryPath is the normalized ancestry path of the asset.
+	AncestryPath string `json:"ancestry_path,omitempty" yaml:"ancestry_path,omitempty"`
+	// PolicyVersion is the version of the policy set the asset was reviewed with.
+	PolicyVersion string `json:"policy_version,omitempty" yaml:"policy_version,omitempty"`
+	// Skipped is set if no target handles the asset.
+	Skipped bool `json:"skipped,omitempty" yaml:"skipped,omitempty"`
+	// Source is the location of the asset in an export, if known.
+	Source *SourceOutput `json:"source,omitempty" yaml:"source,omitempty"`
+	// Violations are the violations of the asset, empty if there are none.
+	Violations []ViolationOutput `json:"violations" yaml:"violations"`
+}
+
+// SourceOutput is the JSON and YAML form of the location of an asset in an export.
+type SourceOutput struct {
+	File string `json:"file,omitempty" yaml:"file,omitempty"`
+	Line int    `json:"line" yaml:"line"`
+}
+
+// ViolationOutput is the JSON and YAML form of a ConstraintViolation.
+type ViolationOutput struct {
+	// Constraint is the name of the constraint in "[Kind].[Name]" format.
+	Constraint string `json:"constraint" yaml:"constraint"`
+	// ConstraintKind is the kind of the constraint, the name of its template.
+	ConstraintKind string `json:"constraint_kind" yaml:"constraint_kind"`
+	// Severity is the severity of the constraint, if set.
+	Severity string `json:"severity,omitempty" yaml:"severity,omitempty"`
+	Message  string `json:"message" yaml:"message"`
+	// Metadata is the metadata returned by the template, such as details.
+	Metadata map[string]interface{} `json:"metadata,omitempty" yaml:"metadata,omitempty"`
+	// Remediation is the guidance the constraint declares on fixing the violation, if any.
+	Remediation string `json:"remediation,omitempty" yaml:"remediation,omitempty"`
+	// RemediationURL links to documentation on fixing the violation, if declared.
+	RemediationURL string `json:"remediation_url,omitempty" yaml:"remediation_url,omitempty"`
+	// Snippets are concrete changes fixing the violation, if rendered.
+	Snippets []Snippet `json:"snippets,omitempty" yaml:"snippets,omitempty"`
+}
+
+// Output returns the JSON and YAML form of r.
+func (r Result) Output() *ResultOutput {
+	out := &ResultOutput{
+		SchemaVersion: ResultSchemaVersion,
+		Name:          r.Name,
+		PolicyVersion: r.PolicyVersion,
+		Skipped:       r.Skipped,
+		Violations:    make([]ViolationOutput, len(r.ConstraintViolations)),
+	}
+	out.AssetType, _, _ = unstructured.NestedString(r.CAIResource, "asset_type")
+	out.AncestryPath, _, _ = unstructured.NestedString(r.CAIResource, ancestryPathKey)
+	if r.Source != nil {
+		out.Source = &SourceOutput{File: r.Source.File, Line: r.Source.Line}
+	}
+	for idx := range r.ConstraintViolations {
+		out.Violations[idx] = *r.ConstraintViolations[idx].Output()
+	}
+	return out
+}
+
+// MarshalJSON implements json.Marshaler with the schema of ResultOutput.
+func (r Result) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.Output())
+}
+
+// MarshalYAML implements yaml.Marshaler of gopkg.in/yaml.v2 with the schema of ResultOutput.
+// Packages that marshal YAML through JSON, such as github.com/ghodss/yaml, use MarshalJSON.
+func (r Result) MarshalYAML() (interface{}, error) {
+	return r.Output(), nil
+}
+
+// Output returns the JSON and YAML form of cv.
+func (cv ConstraintViolation) Output() *ViolationOutput {
+	return &ViolationOutput{
+		Constraint:     cv.ConstraintName(),
+		ConstraintKind: cv.Constraint.GetKind(),
+		Severity:       cv.Severity,
+		Message:        cv.Message,
+		Metadata:       cv.Metadata,
+		Remediation:    cv.Remediation,
+		RemediationURL: cv.RemediationURL,
+		Snippets:       cv.Snippets,
+	}
+}
+
+// MarshalJSON implements json.Marshaler with the schema of ViolationOutput.
+func (cv ConstraintViolation) MarshalJSON() ([]byte, error) {
+	return json.Marshal(cv.Output())
+}
+
+// MarshalYAML implements yaml.Marshaler of gopkg.in/yaml.v2 with the schema of ViolationOutput.
+func (cv ConstraintViolation) MarshalYAML() (interface{}, error) {
+	return cv.Output(), nil
+}
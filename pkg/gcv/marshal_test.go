@@ -0,0 +1,111 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/forseti-security/config-validator/pkg/asset"
+	"github.com/ghodss/yaml"
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestMarshalResult(t *testing.T) {
+	result := &Result{
+		Name: "//storage.googleapis.com/b",
+		CAIResource: map[string]interface{}{
+			"asset_type":    "storage.googleapis.com/Bucket",
+			ancestryPathKey: "organizations/1/projects/3",
+		},
+		PolicyVersion: "1.2.0",
+		Source:        &asset.Source{File: "assets.json", Line: 4, Offset: 812},
+		ConstraintViolations: []ConstraintViolation{
+			{
+				Message:  "no logging",
+				Metadata: map[string]interface{}{"details": map[string]interface{}{"bucket": "b"}},
+				Constraint: &unstructured.Unstructured{Object: map[string]interface{}{
+					"kind":     "GCPStorageLoggingConstraint",
+					"metadata": map[string]interface{}{"name": "require-logging"},
+				}},
+				Severity: "high",
+			},
+		},
+	}
+	want := `{
+  "schema_version": "v1",
+  "name": "//storage.googleapis.com/b",
+  "asset_type": "storage.googleapis.com/Bucket",
+  "ancestry_path": "organizations/1/projects/3",
+  "policy_version": "1.2.0",
+  "source": {
+    "file": "assets.json",
+    "line": 4
+  },
+  "violations": [
+    {
+      "constraint": "GCPStorageLoggingConstraint.require-logging",
+      "constraint_kind": "GCPStorageLoggingConstraint",
+      "severity": "high",
+      "message": "no logging",
+      "metadata": {
+        "details": {
+          "bucket": "b"
+        }
+      }
+    }
+  ]
+}`
+	got, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Errorf("unexpected JSON (-want +got):\n%s", diff)
+	}
+
+	// Results marshal the same by value, and violations on their own.
+	byValue, err := json.MarshalIndent(*result, "", "  ")
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if string(byValue) != string(got) {
+		t.Errorf("result marshalled by value differs:\n%s", byValue)
+	}
+	violation, err := json.Marshal(result.ConstraintViolations[0])
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if want := `{"constraint":"GCPStorageLoggingConstraint.require-logging","constraint_kind":"GCPStorageLoggingConstraint",` +
+		`"severity":"high","message":"no logging","metadata":{"details":{"bucket":"b"}}}`; string(violation) != want {
+		t.Errorf("got violation %s, want %s", violation, want)
+	}
+
+	yamlOut, err := yaml.Marshal(result)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	var fromYAML, fromJSON map[string]interface{}
+	if err := yaml.Unmarshal(yamlOut, &fromYAML); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if err := json.Unmarshal(got, &fromJSON); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if diff := cmp.Diff(fromJSON, fromYAML); diff != "" {
+		t.Errorf("YAML differs from JSON (-json +yaml):\n%s", diff)
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStats reports the memory held by the reviews of a ParallelValidator, estimated by the
+// encoded size of the assets under review and of the results not yet returned to the caller.
+type MemoryStats struct {
+	// MaxInFlightBytes is the configured bound, 0 if unbounded.
+	MaxInFlightBytes int64 `json:"max_in_flight_bytes"`
+	// InFlightBytes is the size of the assets and results currently held.
+	InFlightBytes int64 `json:"in_flight_bytes"`
+	// PeakInFlightBytes is the highest InFlightBytes seen.
+	PeakInFlightBytes int64 `json:"peak_in_flight_bytes"`
+	// InFlightAssets is the number of assets dispatched to the workers whose results were not yet
+	// returned.
+	InFlightAssets int64 `json:"in_flight_assets"`
+	// Throttled is the number of assets whose dispatch waited for memory to be released.
+	Throttled uint64 `json:"throttled"`
+	// ThrottledSeconds is the total time dispatches waited.
+	ThrottledSeconds float64 `json:"throttled_seconds"`
+}
+
+// memoryLimiter bounds the bytes held by reviews in flight, delaying the dispatch of assets
+// until enough is released. An asset larger than the bound is dispatched alone rather than never.
+type memoryLimiter struct {
+	mu    sync.Mutex
+	stats MemoryStats
+	// released is closed and replaced whenever memory is released, waking the waiting dispatches.
+	released chan struct{}
+}
+
+func newMemoryLimiter(maxBytes int64) *memoryLimiter {
+	return &memoryLimiter{stats: MemoryStats{MaxInFlightBytes: maxBytes}, released: make(chan struct{})}
+}
+
+// acquire reserves n bytes for an asset, waiting until they are available or ctx is done.
+func (l *memoryLimiter) acquire(ctx context.Context, n int64) error {
+	var start time.Time
+	for {
+		l.mu.Lock()
+		max := l.stats.MaxInFlightBytes
+		if max == 0 || l.stats.InFlightBytes == 0 || l.stats.InFlightBytes+n <= max {
+			l.stats.InFlightAssets++
+			if !start.IsZero() {
+				l.stats.Throttled++
+				l.stats.ThrottledSeconds += time.Since(start).Seconds()
+			}
+			l.addLocked(n)
+			l.mu.Unlock()
+			return nil
+		}
+		released := l.released
+		l.mu.Unlock()
+		if start.IsZero() {
+			start = time.Now()
+		}
+		select {
+		case <-released:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// add accounts for n more bytes of results without waiting, as the workers holding them must
+// not block. Dispatches wait until they are released.
+func (l *memoryLimiter) add(n int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.addLocked(n)
+}
+
+func (l *memoryLimiter) addLocked(n int64) {
+	l.stats.InFlightBytes += n
+	if l.stats.InFlightBytes > l.stats.PeakInFlightBytes {
+		l.stats.PeakInFlightBytes = l.stats.InFlightBytes
+	}
+}
+
+// release returns the n bytes held by the asset and result of a completed review.
+func (l *memoryLimiter) release(n int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.stats.InFlightAssets--
+	l.stats.InFlightBytes -= n
+	close(l.released)
+	l.released = make(chan struct{})
+}
+
+func (l *memoryLimiter) snapshot() MemoryStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.stats
+}
@@ -0,0 +1,60 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryLimiter(t *testing.T) {
+	ctx := context.Background()
+	l := newMemoryLimiter(100)
+	if err := l.acquire(ctx, 60); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	acquired := make(chan error)
+	go func() {
+		acquired <- l.acquire(ctx, 60)
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("acquired more than the bound")
+	case <-time.After(50 * time.Millisecond):
+	}
+	l.release(60)
+	if err := <-acquired; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	l.release(60)
+
+	// An asset larger than the bound is dispatched alone.
+	if err := l.acquire(ctx, 200); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cancelled, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := l.acquire(cancelled, 1); err != context.DeadlineExceeded {
+		t.Errorf("got error %v, want %v", err, context.DeadlineExceeded)
+	}
+	l.release(200)
+
+	stats := l.snapshot()
+	if stats.InFlightBytes != 0 || stats.InFlightAssets != 0 || stats.PeakInFlightBytes != 200 || stats.Throttled != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
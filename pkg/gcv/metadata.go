@@ -0,0 +1,113 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// MetadataAs decodes the violation metadata found at path into out, which must be a pointer.
+// Path is a dot separated list of keys into the metadata as returned in validator.Violation, for
+// example "details" or "constraint.parameters"; an empty path decodes the entire metadata. The
+// value is decoded using the encoding/json rules, so struct fields are matched by their json tags.
+func MetadataAs(cv *ConstraintViolation, path string, out interface{}) error {
+	value, found, err := cv.metadataField(path)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return errors.Errorf("metadata %s not found", path)
+	}
+
+	valueJSON, err := json.Marshal(value)
+	if err != nil {
+		return errors.Wrapf(err, "failed to marshal metadata %s", path)
+	}
+	if err := json.Unmarshal(valueJSON, out); err != nil {
+		return errors.Wrapf(err, "failed to decode metadata %s into %T", path, out)
+	}
+	return nil
+}
+
+// Details returns the details the template reported for the violation, such as the violating
+// resource, role or member. It returns false if the template reported no details or they are not
+// an object.
+func (cv *ConstraintViolation) Details() (map[string]interface{}, bool) {
+	details, ok := cv.Metadata[DetailsKey].(map[string]interface{})
+	return details, ok
+}
+
+// Field returns the violation metadata found at path, in the format of MetadataAs, for example
+// "details.member". It returns false if there is no value at path.
+func (cv *ConstraintViolation) Field(path string) (interface{}, bool) {
+	value, found, err := cv.metadataField(path)
+	if err != nil {
+		return nil, false
+	}
+	return value, found
+}
+
+// StringField returns the string found at path in the violation metadata, in the format of
+// MetadataAs, for example "details.resource". It returns false if there is no value at path or it
+// is not a string.
+func (cv *ConstraintViolation) StringField(path string) (string, bool) {
+	value, _ := cv.Field(path)
+	s, ok := value.(string)
+	return s, ok
+}
+
+// StringSliceField returns the list of strings found at path in the violation metadata, in the
+// format of MetadataAs. It returns false if there is no value at path or it is not a list of
+// strings.
+func (cv *ConstraintViolation) StringSliceField(path string) ([]string, bool) {
+	value, _ := cv.Field(path)
+	switch t := value.(type) {
+	case []string:
+		return t, true
+	case []interface{}:
+		out := make([]string, len(t))
+		for idx, item := range t {
+			s, ok := item.(string)
+			if !ok {
+				return nil, false
+			}
+			out[idx] = s
+		}
+		return out, true
+	}
+	return nil, false
+}
+
+// metadataField returns the value found at path in the violation metadata. Paths outside of the
+// constraint key are looked up in the returned metadata only, so they do not require a constraint.
+func (cv *ConstraintViolation) metadataField(path string) (interface{}, bool, error) {
+	if path == "" {
+		return cv.metadata(nil), true, nil
+	}
+	fields := strings.Split(path, ".")
+	metadata := cv.Metadata
+	if fields[0] == ConstraintKey {
+		metadata = cv.metadata(nil)
+	}
+	value, found, err := unstructured.NestedFieldNoCopy(metadata, fields...)
+	if err != nil {
+		return nil, false, errors.Wrapf(err, "failed to access metadata %s", path)
+	}
+	return value, found, nil
+}
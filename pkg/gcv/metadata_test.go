@@ -0,0 +1,112 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type locationDetails struct {
+	Location   string `json:"location"`
+	InstanceID string `json:"instance_id"`
+	Disks      []struct {
+		SizeGB int `json:"size_gb"`
+	} `json:"disks"`
+}
+
+type locationParameters struct {
+	Mode    string   `json:"mode"`
+	Allowed []string `json:"allowed"`
+}
+
+func TestMetadataAs(t *testing.T) {
+	cv := benchmarkViolation()
+	cv.Metadata["details"].(map[string]interface{})["instance_id"] = "7523859814704497496"
+
+	var details locationDetails
+	if err := MetadataAs(cv, "details", &details); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if details.Location != "asia-east1" || details.InstanceID != "7523859814704497496" {
+		t.Errorf("unexpected details %+v", details)
+	}
+	if len(details.Disks) != 1 || details.Disks[0].SizeGB != 100 {
+		t.Errorf("unexpected disks %+v", details.Disks)
+	}
+
+	var params locationParameters
+	if err := MetadataAs(cv, "constraint.parameters", &params); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := locationParameters{Mode: "allowlist", Allowed: []string{"us-central1", "us-east1", "europe-west1"}}
+	if diff := cmp.Diff(params, want); diff != "" {
+		t.Errorf("parameters mismatch, +got -want\n%s", diff)
+	}
+
+	var size json.Number
+	if err := MetadataAs(cv, "details.disks", &size); err == nil {
+		t.Errorf("expected error decoding list into number")
+	}
+	if err := MetadataAs(cv, "details.missing", &details); err == nil {
+		t.Errorf("expected error for missing path")
+	}
+
+	all := map[string]interface{}{}
+	if err := MetadataAs(cv, "", &all); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, found := all[ConstraintKey]; !found {
+		t.Errorf("expected %s in full metadata, got %v", ConstraintKey, all)
+	}
+}
+
+func TestMetadataAccessors(t *testing.T) {
+	cv := benchmarkViolation()
+	details, ok := cv.Details()
+	if !ok || details["location"] != "asia-east1" {
+		t.Errorf("got details %v, %v", details, ok)
+	}
+	if location, ok := cv.StringField("details.location"); !ok || location != "asia-east1" {
+		t.Errorf("got location %q, %v", location, ok)
+	}
+	if mode, ok := cv.StringField("constraint.parameters.mode"); !ok || mode != "allowlist" {
+		t.Errorf("got mode %q, %v", mode, ok)
+	}
+	allowed, ok := cv.StringSliceField("constraint.parameters.allowed")
+	if diff := cmp.Diff([]string{"us-central1", "us-east1", "europe-west1"}, allowed); !ok || diff != "" {
+		t.Errorf("unexpected allowed (-want +got):\n%s", diff)
+	}
+	if disks, ok := cv.Field("details.disks"); !ok || len(disks.([]interface{})) != 1 {
+		t.Errorf("got disks %v, %v", disks, ok)
+	}
+
+	// Missing values, values of other types and paths through non-objects are not found.
+	for _, path := range []string{"details.missing", "details.instance_id", "details.disks", "details.location.name", "missing.member"} {
+		if value, ok := cv.StringField(path); ok {
+			t.Errorf("got string %q at %s, want none", value, path)
+		}
+	}
+	if value, ok := cv.StringSliceField("details.disks"); ok {
+		t.Errorf("got strings %v at details.disks, want none", value)
+	}
+
+	cv.Metadata = nil
+	if details, ok := cv.Details(); ok {
+		t.Errorf("got details %v without metadata", details)
+	}
+}
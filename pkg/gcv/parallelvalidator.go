@@ -18,15 +18,21 @@ import (
 	"context"
 	"flag"
 	"runtime"
+	"runtime/debug"
+	"time"
 
 	"github.com/forseti-security/config-validator/pkg/api/validator"
+	"github.com/forseti-security/config-validator/pkg/logging"
 	"github.com/forseti-security/config-validator/pkg/multierror"
-	"github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
 	"github.com/pkg/errors"
+	"go.uber.org/zap"
 )
 
 var flags struct {
-	workerCount int
+	workerCount        int
+	assetReviewTimeout time.Duration
+	maxInFlightBytes   int64
 }
 
 func init() {
@@ -35,37 +41,99 @@ func init() {
 		"workerCount",
 		runtime.NumCPU(),
 		"Number of workers that Validator will spawn to handle validate calls, this defaults to core count on the host")
+	flag.DurationVar(
+		&flags.assetReviewTimeout,
+		"assetReviewTimeout",
+		0,
+		"Maximum time spent reviewing a single asset before it is cancelled and reported as an error, 0 for no limit")
+	flag.Int64Var(
+		&flags.maxInFlightBytes,
+		"maxInFlightBytes",
+		0,
+		"Maximum encoded size of the assets under review and their buffered results across all requests, 0 for no limit. "+
+			"Requests wait for reviews to complete rather than exceed it")
 }
 
 // ParallelValidator handles making parallel calls to Validator during a Review call.
 type ParallelValidator struct {
-	cv   ConfigValidator
-	work chan func()
+	cv          ConfigValidator
+	work        chan func()
+	workerCount int
+	// assetReviewTimeout bounds the review of each asset, 0 for no limit.
+	assetReviewTimeout time.Duration
+	memory             *memoryLimiter
 }
 
 type assetResult struct {
+	// idx is the index of the asset in the review request.
+	idx        int
 	violations []*validator.Violation
 	err        error
+	// dispatched is set if the asset was reviewed by a worker, in which case bytes is the memory
+	// held for the asset and its violations until the result is consumed.
+	dispatched bool
+	bytes      int64
+}
+
+// ParallelOption configures a ParallelValidator.
+type ParallelOption func(*ParallelValidator)
+
+// WithMaxInFlightBytes bounds the memory held by reviews, estimated by the encoded size of the
+// assets under review and of their violations until they are returned, or sent by
+// StreamReviewWith. Once maxBytes are held, assets wait to be dispatched to the workers until
+// reviews complete, so that a slow consumer or a flood of large requests applies backpressure
+// instead of exhausting memory. Violations are only known once reviewed, so the reviews already
+// dispatched can take the total over maxBytes by the size of their violations. It defaults to
+// the -maxInFlightBytes flag, 0 for no limit.
+func WithMaxInFlightBytes(maxBytes int64) ParallelOption {
+	return func(v *ParallelValidator) {
+		v.memory = newMemoryLimiter(maxBytes)
+	}
+}
+
+// WithWorkerCount sets the number of workers reviewing assets, which defaults to the -workerCount
+// flag, the core count of the host.
+func WithWorkerCount(count int) ParallelOption {
+	return func(v *ParallelValidator) {
+		v.workerCount = count
+	}
+}
+
+// WithAssetReviewTimeout cancels the review of an asset after timeout and reports it as an error
+// of the asset, so that a pathological asset cannot hold a worker. It defaults to the
+// -assetReviewTimeout flag, 0 for no limit.
+func WithAssetReviewTimeout(timeout time.Duration) ParallelOption {
+	return func(v *ParallelValidator) {
+		v.assetReviewTimeout = timeout
+	}
 }
 
 // NewParallelValidator creates a new instance with the given stop channel and validator
-func NewParallelValidator(stopChannel <-chan struct{}, cv ConfigValidator) *ParallelValidator {
+func NewParallelValidator(stopChannel <-chan struct{}, cv ConfigValidator, opts ...ParallelOption) *ParallelValidator {
 	pv := &ParallelValidator{
-		// channel size of number of workers seems sufficient to prevent blocking,
-		// this is really just an assumption with no actual perf benchmarking.
-		work: make(chan func(), flags.workerCount),
-		cv:   cv,
+		cv:                 cv,
+		workerCount:        flags.workerCount,
+		assetReviewTimeout: flags.assetReviewTimeout,
+		memory:             newMemoryLimiter(flags.maxInFlightBytes),
+	}
+	for _, opt := range opts {
+		opt(pv)
 	}
+	if pv.workerCount < 1 {
+		pv.workerCount = 1
+	}
+	// channel size of number of workers seems sufficient to prevent blocking,
+	// this is really just an assumption with no actual perf benchmarking.
+	pv.work = make(chan func(), pv.workerCount)
 
 	go func() {
 		<-stopChannel
-		glog.Infof("validator shutdown requested via stopChannel close")
+		zap.L().Info("validator shutdown requested via stopChannel close")
 		close(pv.work)
 	}()
 
-	workerCount := flags.workerCount
-	glog.Infof("validator starting %d workers", workerCount)
-	for i := 0; i < workerCount; i++ {
+	zap.L().Info("validator starting workers", zap.Int("workers", pv.workerCount))
+	for i := 0; i < pv.workerCount; i++ {
 		go pv.reviewWorker(i)
 	}
 
@@ -74,54 +142,177 @@ func NewParallelValidator(stopChannel <-chan struct{}, cv ConfigValidator) *Para
 
 // reviewWorker is the function that each worker goroutine will use
 func (v *ParallelValidator) reviewWorker(idx int) {
-	glog.V(1).Infof("worker %d starting", idx)
+	zap.L().Debug("worker starting", zap.Int("worker", idx))
 	for f := range v.work {
 		f()
 	}
-	glog.V(1).Infof("worker %d terminated", idx)
+	zap.L().Debug("worker terminated", zap.Int("worker", idx))
 }
 
-// handleReview is the wrapper function for individual asset reviews.
-func (v *ParallelValidator) handleReview(ctx context.Context, idx int, asset *validator.Asset, resultChan chan<- *assetResult) func() {
+// MemoryStats returns the memory held by the reviews in flight.
+func (v *ParallelValidator) MemoryStats() MemoryStats {
+	return v.memory.snapshot()
+}
+
+// handleReview is the wrapper function for individual asset reviews. The review holds size bytes
+// of memory for the asset, to which the size of the violations is added.
+func (v *ParallelValidator) handleReview(
+	ctx context.Context, cv ConfigValidator, idx int, asset *validator.Asset, size int64, resultChan chan<- *assetResult) func() {
 	return func() {
-		resultChan <- func() *assetResult {
-			violations, err := v.cv.ReviewAsset(ctx, asset)
+		result := func() *assetResult {
+			if v.assetReviewTimeout != 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, v.assetReviewTimeout)
+				defer cancel()
+			}
+			violations, err := reviewAsset(ctx, cv, asset)
 			if err != nil {
+				logging.FromContext(ctx).Error("asset review failed",
+					zap.String(logging.AssetKey, asset.GetName()), zap.Int("index", idx), zap.Error(err))
 				return &assetResult{err: errors.Wrapf(err, "index %d", idx)}
 			}
 			return &assetResult{violations: violations}
 		}()
+		result.idx = idx
+		result.dispatched = true
+		result.bytes = size
+		var violationBytes int64
+		for _, violation := range result.violations {
+			violationBytes += int64(proto.Size(violation))
+		}
+		v.memory.add(violationBytes)
+		result.bytes += violationBytes
+		resultChan <- result
 	}
 }
 
+// reviewAsset reviews asset with cv, returning a panic of cv as a *PanicError so that a single
+// asset cannot take down the workers.
+func reviewAsset(ctx context.Context, cv ConfigValidator, asset *validator.Asset) (violations []*validator.Violation, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{Value: r, Stack: debug.Stack()}
+		}
+	}()
+	return cv.ReviewAsset(ctx, asset)
+}
+
 // Review evaluates each asset in the review request in parallel and returns any
 // violations found.
 func (v *ParallelValidator) Review(ctx context.Context, request *validator.ReviewRequest) (*validator.ReviewResponse, error) {
-	assetCount := len(request.Assets)
-	// channel size of number of workers seems sufficient to prevent blocking,
-	// this is really just an assumption with no actual perf benchmarking.
-	resultChan := make(chan *assetResult, flags.workerCount)
-	defer close(resultChan)
-
-	go func() {
-		for idx, asset := range request.Assets {
-			v.work <- v.handleReview(ctx, idx, asset, resultChan)
-		}
-	}()
+	return v.ReviewWith(ctx, v.cv, request)
+}
 
+// ReviewWith is Review with cv in place of the validator of v, so that reviews with several
+// validators share the workers.
+func (v *ParallelValidator) ReviewWith(ctx context.Context, cv ConfigValidator, request *validator.ReviewRequest) (*validator.ReviewResponse, error) {
 	response := &validator.ReviewResponse{}
 	var errs multierror.Errors
-	for i := 0; i < assetCount; i++ {
-		result := <-resultChan
+	v.reviewAssets(ctx, cv, request, func(result *assetResult) {
+		v.release(result)
 		if result.err != nil {
 			errs.Add(result.err)
-			continue
+			return
 		}
 		response.Violations = append(response.Violations, result.violations...)
-	}
+	})
+	SortViolations(response.Violations)
 
 	if !errs.Empty() {
 		return response, errs.ToError()
 	}
 	return response, nil
 }
+
+// StreamReviewWith reviews the assets of request with cv like ReviewWith, calling send with
+// batches of at most batchSize violations as soon as they are complete rather than returning all
+// violations at once. Violations are sent in the order of the assets in request, the violations
+// of each asset sorted by constraint. Errors reviewing assets are returned after all other
+// violations were sent. If send fails, the remaining reviews are cancelled and its error is
+// returned. Results waiting for those of earlier assets count against WithMaxInFlightBytes, so a
+// slow send delays the dispatch of further assets.
+func (v *ParallelValidator) StreamReviewWith(
+	ctx context.Context, cv ConfigValidator, request *validator.ReviewRequest, batchSize int,
+	send func([]*validator.Violation) error) error {
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Results that complete before the results of earlier assets wait in pending.
+	pending := map[int]*assetResult{}
+	next := 0
+	var batch []*validator.Violation
+	var errs multierror.Errors
+	var sendErr error
+	v.reviewAssets(ctx, cv, request, func(result *assetResult) {
+		pending[result.idx] = result
+		for ; pending[next] != nil; next++ {
+			ready := pending[next]
+			delete(pending, next)
+			v.release(ready)
+			if ready.err != nil {
+				errs.Add(ready.err)
+				continue
+			}
+			violations := append([]*validator.Violation(nil), ready.violations...)
+			SortViolations(violations)
+			batch = append(batch, violations...)
+			for sendErr == nil && len(batch) >= batchSize {
+				if sendErr = send(batch[:batchSize]); sendErr != nil {
+					cancel()
+				}
+				batch = batch[batchSize:]
+			}
+		}
+	})
+	if sendErr == nil && len(batch) != 0 {
+		sendErr = send(batch)
+	}
+	if sendErr != nil {
+		return sendErr
+	}
+	return errs.ToError()
+}
+
+// release returns the memory held for result.
+func (v *ParallelValidator) release(result *assetResult) {
+	if result.dispatched {
+		v.memory.release(result.bytes)
+	}
+}
+
+// reviewAssets reviews the assets of request with cv on the workers of v and calls handle with
+// the result of each asset, in the order the reviews complete. Assets are dispatched once the
+// memory they need is available, handle must release the memory held for each result.
+func (v *ParallelValidator) reviewAssets(
+	ctx context.Context, cv ConfigValidator, request *validator.ReviewRequest, handle func(*assetResult)) {
+	assetCount := len(request.Assets)
+	// channel size of number of workers seems sufficient to prevent blocking,
+	// this is really just an assumption with no actual perf benchmarking.
+	resultChan := make(chan *assetResult, v.workerCount)
+	defer close(resultChan)
+
+	go func() {
+		for idx, asset := range request.Assets {
+			size := int64(proto.Size(asset))
+			if err := v.memory.acquire(ctx, size); err != nil {
+				resultChan <- &assetResult{idx: idx, err: errors.Wrapf(err, "index %d", idx)}
+				continue
+			}
+			select {
+			case v.work <- v.handleReview(ctx, cv, idx, asset, size, resultChan):
+			case <-ctx.Done():
+				v.memory.release(size)
+				// Assets that were never dispatched still need a result so the collection loop
+				// below terminates.
+				resultChan <- &assetResult{idx: idx, err: errors.Wrapf(ctx.Err(), "index %d", idx)}
+			}
+		}
+	}()
+
+	for i := 0; i < assetCount; i++ {
+		handle(<-resultChan)
+	}
+}
@@ -15,13 +15,20 @@
 package gcv
 
 import (
+	"bytes"
 	"context"
+	"fmt"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 
+	"github.com/golang/protobuf/proto"
+	"github.com/google/go-cmp/cmp"
 	"github.com/pkg/errors"
 
 	"github.com/forseti-security/config-validator/pkg/api/validator"
+	"github.com/forseti-security/config-validator/pkg/logging"
 )
 
 type reviewTestcase struct {
@@ -134,12 +141,6 @@ func TestReview(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			oldWorkerCount := flags.workerCount
-			defer func() {
-				flags.workerCount = oldWorkerCount
-			}()
-			flags.workerCount = tc.workerCount
-
 			stopChannel := make(chan struct{})
 			defer close(stopChannel)
 			cv := NewFakeConfigValidator(
@@ -164,7 +165,7 @@ func TestReview(t *testing.T) {
 					},
 				},
 			)
-			v := NewParallelValidator(stopChannel, cv)
+			v := NewParallelValidator(stopChannel, cv, WithWorkerCount(tc.workerCount))
 
 			var groupDone sync.WaitGroup
 			for callIdx, call := range tc.calls {
@@ -206,3 +207,168 @@ func TestReview(t *testing.T) {
 		})
 	}
 }
+
+// blockingConfigValidator blocks reviews of the named asset until the context is done.
+type blockingConfigValidator struct {
+	blockName string
+}
+
+func (v *blockingConfigValidator) ReviewAsset(ctx context.Context, asset *validator.Asset) ([]*validator.Violation, error) {
+	if asset.Name != v.blockName {
+		return []*validator.Violation{{Resource: asset.Name}}, nil
+	}
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestReviewAssetTimeout(t *testing.T) {
+	stopChannel := make(chan struct{})
+	defer close(stopChannel)
+	v := NewParallelValidator(stopChannel, &blockingConfigValidator{blockName: "pathological"}, WithAssetReviewTimeout(10*time.Millisecond))
+
+	var logs bytes.Buffer
+	logger, err := logging.New(&logs, logging.JSON, "info")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ctx := logging.WithRunID(logging.NewContext(context.Background(), logger), "run-1")
+	result, err := v.Review(ctx, &validator.ReviewRequest{
+		Assets: []*validator.Asset{{Name: "first"}, {Name: "pathological"}, {Name: "last"}},
+	})
+	if err == nil {
+		t.Fatalf("expected timeout error")
+	}
+	if len(result.Violations) != 2 {
+		t.Errorf("wanted violations for the 2 other assets, got %d", len(result.Violations))
+	}
+	// The failed review is logged with the run and the asset.
+	if line := logs.String(); !strings.Contains(line, `"run_id":"run-1","asset":"pathological"`) {
+		t.Errorf("unexpected logs %q", line)
+	}
+}
+
+func TestReviewCancelled(t *testing.T) {
+	stopChannel := make(chan struct{})
+	defer close(stopChannel)
+	v := NewParallelValidator(stopChannel, &blockingConfigValidator{blockName: "pathological"}, WithWorkerCount(1))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var assets []*validator.Asset
+	for i := 0; i < 16; i++ {
+		assets = append(assets, &validator.Asset{Name: "pathological"})
+	}
+	time.AfterFunc(10*time.Millisecond, cancel)
+	if _, err := v.Review(ctx, &validator.ReviewRequest{Assets: assets}); err == nil {
+		t.Fatalf("expected cancellation error")
+	}
+}
+
+func TestReviewWith(t *testing.T) {
+	stopChannel := make(chan struct{})
+	defer close(stopChannel)
+	v := NewParallelValidator(stopChannel, NewFakeConfigValidator(map[string][]*validator.Violation{}))
+	other := NewFakeConfigValidator(map[string][]*validator.Violation{
+		"bucket": {{Constraint: "require-storage-logging"}},
+	})
+	response, err := v.ReviewWith(context.Background(), other, &validator.ReviewRequest{Assets: []*validator.Asset{{Name: "bucket"}}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(response.Violations) != 1 || response.Violations[0].Resource != "bucket" {
+		t.Errorf("unexpected violations %v", response.Violations)
+	}
+	if _, err := v.Review(context.Background(), &validator.ReviewRequest{Assets: []*validator.Asset{{Name: "bucket"}}}); err == nil {
+		t.Errorf("expected the validator of the parallel validator to fail the review")
+	}
+}
+
+func TestStreamReviewWith(t *testing.T) {
+	stopChannel := make(chan struct{})
+	defer close(stopChannel)
+	cv := NewFakeConfigValidator(map[string][]*validator.Violation{
+		"a": {{Constraint: "c2"}, {Constraint: "c1"}, {Constraint: "c3"}},
+		"b": {},
+		"c": {{Constraint: "c2"}, {Constraint: "c1"}},
+	})
+	v := NewParallelValidator(stopChannel, cv)
+	request := &validator.ReviewRequest{
+		Assets: []*validator.Asset{{Name: "c"}, {Name: "missing"}, {Name: "a"}, {Name: "b"}},
+	}
+
+	var batches [][]string
+	err := v.StreamReviewWith(context.Background(), cv, request, 2, func(violations []*validator.Violation) error {
+		var batch []string
+		for _, violation := range violations {
+			batch = append(batch, violation.Resource+" "+violation.Constraint)
+		}
+		batches = append(batches, batch)
+		return nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "name missing not found") {
+		t.Errorf("got error %v, want error for missing asset", err)
+	}
+	// Violations are sent in the order of the assets, sorted by constraint within an asset.
+	want := [][]string{{"c c1", "c c2"}, {"a c1", "a c2"}, {"a c3"}}
+	if diff := cmp.Diff(want, batches); diff != "" {
+		t.Errorf("unexpected batches (-want +got):\n%s", diff)
+	}
+
+	sendErr := errors.New("client went away")
+	err = v.StreamReviewWith(context.Background(), cv, request, 1, func([]*validator.Violation) error {
+		return sendErr
+	})
+	if err != sendErr {
+		t.Errorf("got error %v, want %v", err, sendErr)
+	}
+}
+
+func TestReviewWithMaxInFlightBytes(t *testing.T) {
+	stopChannel := make(chan struct{})
+	defer close(stopChannel)
+	violations := map[string][]*validator.Violation{}
+	request := &validator.ReviewRequest{}
+	for i := 0; i < 50; i++ {
+		name := fmt.Sprintf("bucket-%d", i)
+		violations[name] = []*validator.Violation{{Constraint: "require-storage-logging", Message: strings.Repeat("x", 100)}}
+		request.Assets = append(request.Assets, &validator.Asset{Name: name})
+	}
+	cv := NewFakeConfigValidator(violations)
+	// Room for one asset and its violation only.
+	const maxBytes = 200
+	v := NewParallelValidator(stopChannel, cv, WithMaxInFlightBytes(maxBytes))
+
+	response, err := v.Review(context.Background(), request)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(response.Violations) != 50 {
+		t.Errorf("got %d violations, want 50", len(response.Violations))
+	}
+	// A slow client holds the results of the reviews, delaying the dispatch of further assets.
+	var sent int
+	err = v.StreamReviewWith(context.Background(), cv, request, 1, func(batch []*validator.Violation) error {
+		time.Sleep(time.Millisecond)
+		sent += len(batch)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sent != 50 {
+		t.Errorf("sent %d violations, want 50", sent)
+	}
+
+	stats := v.MemoryStats()
+	if stats.InFlightBytes != 0 || stats.InFlightAssets != 0 {
+		t.Errorf("memory still held after the reviews: %+v", stats)
+	}
+	// The violations of the reviews queued for or running on the workers may take the total over
+	// the bound.
+	result := int64(proto.Size(request.Assets[0]) + proto.Size(violations["bucket-0"][0]))
+	if limit := maxBytes + 2*int64(v.workerCount)*result; stats.PeakInFlightBytes == 0 || stats.PeakInFlightBytes > limit {
+		t.Errorf("got peak of %d bytes, want at most %d", stats.PeakInFlightBytes, limit)
+	}
+	if stats.Throttled == 0 {
+		t.Errorf("expected dispatches to wait for memory: %+v", stats)
+	}
+}
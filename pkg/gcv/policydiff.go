@@ -0,0 +1,147 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// PolicyDiff is the difference between the violations found by two policy sets for the same
+// assets.
+type PolicyDiff struct {
+	// BaseVersion and HeadVersion are the policy versions of the compared policy sets.
+	BaseVersion string `json:"base_version"`
+	HeadVersion string `json:"head_version"`
+	// Assets is the number of reviewed assets.
+	Assets int `json:"assets"`
+	// Constraints holds the constraints with violations in either policy set, sorted by name.
+	Constraints []*ConstraintDiff `json:"constraints"`
+}
+
+// ConstraintDiff is the difference in the violations of a single constraint.
+type ConstraintDiff struct {
+	// Constraint is the name of the constraint in "[Kind].[Name]" format.
+	Constraint string `json:"constraint"`
+	// Added are the violations only found by the head policy set.
+	Added []*DiffViolation `json:"added,omitempty"`
+	// Removed are the violations only found by the base policy set.
+	Removed []*DiffViolation `json:"removed,omitempty"`
+	// Unchanged is the number of violations found by both policy sets.
+	Unchanged int `json:"unchanged"`
+}
+
+// DiffViolation is a violation that was added or removed.
+type DiffViolation struct {
+	Resource string `json:"resource"`
+	Message  string `json:"message"`
+}
+
+// Write writes the diff as JSON.
+func (d *PolicyDiff) Write(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return errors.Wrapf(encoder.Encode(d), "failed to encode policy diff")
+}
+
+// PolicyDiffer reviews assets with two policy sets, such as two revisions of a policy library,
+// and collects how their violations differ.
+type PolicyDiffer struct {
+	base, head  *Validator
+	assets      int
+	constraints map[string]*ConstraintDiff
+}
+
+// NewPolicyDiffer returns a PolicyDiffer comparing the violations found by head to those found
+// by base.
+func NewPolicyDiffer(base, head *Validator) *PolicyDiffer {
+	return &PolicyDiffer{base: base, head: head, constraints: map[string]*ConstraintDiff{}}
+}
+
+// Review reviews asset with both policy sets and adds the difference to the diff.
+func (d *PolicyDiffer) Review(ctx context.Context, asset map[string]interface{}) error {
+	// Reviews add the ancestry path, each review gets its own copy.
+	baseAsset, err := copyAsset(asset)
+	if err != nil {
+		return err
+	}
+	baseResult, err := d.base.ReviewUnmarshalledJSON(ctx, baseAsset)
+	if err != nil {
+		return errors.Wrapf(err, "asset %s with base policies", AssetKey(asset))
+	}
+	headAsset, err := copyAsset(asset)
+	if err != nil {
+		return err
+	}
+	headResult, err := d.head.ReviewUnmarshalledJSON(ctx, headAsset)
+	if err != nil {
+		return errors.Wrapf(err, "asset %s with head policies", AssetKey(asset))
+	}
+
+	d.assets++
+	added := violationsNotIn(headResult, baseResult)
+	for idx := range added {
+		diff := d.constraint(added[idx].ConstraintName())
+		diff.Added = append(diff.Added, &DiffViolation{Resource: headResult.Name, Message: added[idx].Message})
+	}
+	removed := violationsNotIn(baseResult, headResult)
+	for idx := range removed {
+		diff := d.constraint(removed[idx].ConstraintName())
+		diff.Removed = append(diff.Removed, &DiffViolation{Resource: baseResult.Name, Message: removed[idx].Message})
+	}
+	unchanged := map[string]int{}
+	for idx := range headResult.ConstraintViolations {
+		unchanged[headResult.ConstraintViolations[idx].ConstraintName()]++
+	}
+	for idx := range added {
+		unchanged[added[idx].ConstraintName()]--
+	}
+	for name, count := range unchanged {
+		if count > 0 {
+			d.constraint(name).Unchanged += count
+		}
+	}
+	return nil
+}
+
+func (d *PolicyDiffer) constraint(name string) *ConstraintDiff {
+	diff, found := d.constraints[name]
+	if !found {
+		diff = &ConstraintDiff{Constraint: name}
+		d.constraints[name] = diff
+	}
+	return diff
+}
+
+// Diff returns the difference collected so far.
+func (d *PolicyDiffer) Diff() *PolicyDiff {
+	diff := &PolicyDiff{
+		BaseVersion: d.base.PolicyVersion(),
+		HeadVersion: d.head.PolicyVersion(),
+		Assets:      d.assets,
+		Constraints: []*ConstraintDiff{},
+	}
+	for _, constraint := range d.constraints {
+		diff.Constraints = append(diff.Constraints, constraint)
+	}
+	sort.Slice(diff.Constraints, func(i, j int) bool {
+		return diff.Constraints[i].Constraint < diff.Constraints[j].Constraint
+	})
+	return diff
+}
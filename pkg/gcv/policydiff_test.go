@@ -0,0 +1,81 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestPolicyDiffer(t *testing.T) {
+	base, err := NewValidator(testOptions()...)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	var constraints []*unstructured.Unstructured
+	for _, constraint := range append(append([]*unstructured.Unstructured{}, base.config.GCPConstraints...), base.config.K8SConstraints...) {
+		if constraint.GetName() != "require-storage-logging" {
+			constraints = append(constraints, constraint)
+		}
+	}
+	constraints = append(constraints, &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "constraints.gatekeeper.sh/v1alpha1",
+		"kind":       "GCPStorageLoggingConstraint",
+		"metadata":   map[string]interface{}{"name": "candidate_logging"},
+		"spec":       map[string]interface{}{"match": map[string]interface{}{"target": []interface{}{"organization/*"}}},
+	}})
+	headConfig, err := base.config.WithConstraints(constraints)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	head, err := NewValidatorFromConfig(headConfig, WithPolicyVersion("2.0.0"))
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	differ := NewPolicyDiffer(base, head)
+	for _, asset := range unmarshalAssets(t, storageAssetNoLoggingJSON, storageAssetWithLoggingJSON) {
+		if err := differ.Review(context.Background(), asset); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	bucket := "//storage.googleapis.com/my-storage-bucket"
+	message := bucket + " does not have the required logging destination."
+	want := &PolicyDiff{
+		BaseVersion: base.PolicyVersion(),
+		HeadVersion: "2.0.0",
+		Assets:      2,
+		Constraints: []*ConstraintDiff{
+			{
+				Constraint: "CFGCPStorageLoggingConstraint.require-storage-logging",
+				Removed:    []*DiffViolation{{Resource: bucket, Message: message}},
+			},
+			{
+				Constraint: "GCPStorageLoggingConstraint.candidate_logging",
+				Added:      []*DiffViolation{{Resource: bucket, Message: message}},
+			},
+			{
+				Constraint: "GCPStorageLoggingConstraint.require_storage_logging_XX",
+				Unchanged:  1,
+			},
+		},
+	}
+	if diff := cmp.Diff(want, differ.Diff()); diff != "" {
+		t.Errorf("unexpected policy diff (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"runtime/debug"
+
+	"github.com/forseti-security/config-validator/pkg/gcv/configs"
+)
+
+// Version is the version of the validator, set at build time with
+// -ldflags "-X github.com/forseti-security/config-validator/pkg/gcv.Version=v1.2.3". If it is not
+// set, ValidatorVersion falls back to the module version recorded in the binary.
+var Version = ""
+
+// ValidatorVersion returns the version of the validator binary, "unknown" if it was built
+// without version information.
+func ValidatorVersion() string {
+	if Version != "" {
+		return Version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "unknown"
+}
+
+// Provenance identifies the policy code of a Validator, so that auditors can trace a finding to
+// the exact policies that produced it.
+type Provenance struct {
+	// PolicyVersion is the version stamped on review outputs, see Validator.PolicyVersion.
+	PolicyVersion string `json:"policy_version"`
+	// ContentHash is the content hash of the loaded templates and constraints.
+	ContentHash string                 `json:"content_hash"`
+	Sources     []configs.PolicySource `json:"sources,omitempty"`
+	Templates   int                    `json:"templates"`
+	Constraints int                    `json:"constraints"`
+}
+
+// Provenance returns the provenance of the policies of v.
+func (v *Validator) Provenance() *Provenance {
+	templates := map[string]bool{}
+	for _, t := range v.config.AllTemplates() {
+		templates[t.Name] = true
+	}
+	return &Provenance{
+		PolicyVersion: v.PolicyVersion(),
+		ContentHash:   v.policyVersion,
+		Sources:       v.config.Sources(),
+		Templates:     len(templates),
+		Constraints:   len(v.Constraints()),
+	}
+}
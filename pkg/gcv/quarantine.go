@@ -0,0 +1,86 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"runtime/debug"
+	"sync"
+
+	"github.com/forseti-security/config-validator/pkg/logging"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// PanicError is returned for the review of an asset that panicked, other reviews are not
+// affected.
+type PanicError struct {
+	// Value is the value passed to panic.
+	Value interface{}
+	// Stack is the stack trace of the panic.
+	Stack []byte
+}
+
+// Error implements error.
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("review panicked: %v", e.Value)
+}
+
+// Quarantine records the assets whose review panicked as a newline delimited export, which can
+// be reviewed again to reproduce the panic. It is safe for concurrent use.
+type Quarantine struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+}
+
+// NewQuarantine returns a quarantine writing assets to w.
+func NewQuarantine(w io.Writer) *Quarantine {
+	return &Quarantine{encoder: json.NewEncoder(w)}
+}
+
+// Add writes asset to the quarantine.
+func (q *Quarantine) Add(asset map[string]interface{}) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return errors.Wrapf(q.encoder.Encode(asset), "failed to quarantine asset %s", AssetKey(asset))
+}
+
+// WithQuarantine writes assets whose review panicked to q.
+func WithQuarantine(q *Quarantine) Option {
+	return func(v *Validator) {
+		v.quarantine = q
+	}
+}
+
+// recoverReview is deferred by reviews of asset. It converts a panic into a *PanicError in
+// *err, and quarantines the asset if a quarantine is configured.
+func (v *Validator) recoverReview(ctx context.Context, asset map[string]interface{}, err *error) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	panicErr := &PanicError{Value: r, Stack: debug.Stack()}
+	logger := logging.FromContext(ctx).With(zap.String(logging.AssetKey, AssetKey(asset)))
+	logger.Error("asset review panicked", zap.Any("panic", r), zap.ByteString("stack", panicErr.Stack))
+	if v.quarantine != nil {
+		if qErr := v.quarantine.Add(asset); qErr != nil {
+			logger.Error("failed to quarantine asset", zap.Error(qErr))
+		}
+	}
+	*err = panicErr
+}
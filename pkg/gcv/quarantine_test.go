@@ -0,0 +1,79 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/forseti-security/config-validator/pkg/api/validator"
+)
+
+func TestReviewPanicQuarantined(t *testing.T) {
+	enricher := enricherFunc(func(ctx context.Context, asset map[string]interface{}) (map[string]interface{}, error) {
+		if asset["name"] == "//storage.googleapis.com/my-storage-bucket" {
+			var data map[string]interface{}
+			data["boom"] = true
+		}
+		return asset, nil
+	})
+	var quarantined bytes.Buffer
+	v, err := NewValidator(append(testOptions(), WithEnricher(enricher), WithQuarantine(NewQuarantine(&quarantined)))...)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	_, err = v.ReviewJSON(context.Background(), storageAssetNoLoggingJSON)
+	if _, ok := err.(*PanicError); !ok || !strings.Contains(err.Error(), "assignment to entry in nil map") {
+		t.Fatalf("got error %v, want *PanicError", err)
+	}
+	assets := unmarshalAssets(t, strings.TrimSpace(quarantined.String()))
+	if len(assets) != 1 || assets[0]["name"] != "//storage.googleapis.com/my-storage-bucket" {
+		t.Errorf("unexpected quarantine %s", quarantined.String())
+	}
+	// Other assets are still reviewed.
+	if _, err := v.ReviewJSON(context.Background(), storageAssetWithLoggingJSON); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+// panickingConfigValidator panics on reviews of the named asset.
+type panickingConfigValidator struct {
+	panicName string
+}
+
+func (v *panickingConfigValidator) ReviewAsset(ctx context.Context, asset *validator.Asset) ([]*validator.Violation, error) {
+	if asset.Name == v.panicName {
+		panic("malformed asset")
+	}
+	return []*validator.Violation{{Resource: asset.Name}}, nil
+}
+
+func TestParallelReviewPanic(t *testing.T) {
+	stopChannel := make(chan struct{})
+	defer close(stopChannel)
+	v := NewParallelValidator(stopChannel, &panickingConfigValidator{panicName: "malformed"})
+	response, err := v.Review(context.Background(), &validator.ReviewRequest{
+		Assets: []*validator.Asset{{Name: "first"}, {Name: "malformed"}, {Name: "last"}},
+	})
+	if err == nil || !strings.Contains(err.Error(), "index 1: review panicked: malformed asset") {
+		t.Errorf("got error %v, want panic of index 1", err)
+	}
+	if len(response.Violations) != 2 {
+		t.Errorf("wanted violations for the 2 other assets, got %d", len(response.Violations))
+	}
+}
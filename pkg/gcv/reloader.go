@@ -0,0 +1,86 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+	"sync"
+
+	"github.com/forseti-security/config-validator/pkg/api/validator"
+	"github.com/forseti-security/config-validator/pkg/logging"
+	"go.uber.org/zap"
+)
+
+// Reloader holds the Validator of a policy set that can be reloaded from its sources while
+// reviews are in progress. Reviews started before a reload complete with the previous Validator.
+type Reloader struct {
+	load func() (*Validator, error)
+	// reloadMu serializes reloads.
+	reloadMu sync.Mutex
+
+	mu      sync.RWMutex
+	current *Validator
+}
+
+var _ ConfigValidator = &Reloader{}
+
+// NewReloader returns a Reloader holding the Validator returned by load, which is called again on
+// each reload.
+func NewReloader(load func() (*Validator, error)) (*Reloader, error) {
+	v, err := load()
+	if err != nil {
+		return nil, err
+	}
+	return &Reloader{load: load, current: v}, nil
+}
+
+// NewPolicyReloader returns a Reloader loading the policies from policyPaths and policyLibraryPath
+// into a Validator configured with opts.
+func NewPolicyReloader(policyPaths []string, policyLibraryPath string, opts ...Option) (*Reloader, error) {
+	opts = append([]Option{WithPolicyPaths(policyPaths...), WithPolicyLibrary(policyLibraryPath)}, opts...)
+	return NewReloader(func() (*Validator, error) {
+		return NewValidator(opts...)
+	})
+}
+
+// Validator returns the current Validator.
+func (r *Reloader) Validator() *Validator {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.current
+}
+
+// Reload loads the policy set again and swaps it in for the current one, returning the new
+// Validator. If the policies fail to load the current Validator is kept.
+func (r *Reloader) Reload(ctx context.Context) (*Validator, error) {
+	r.reloadMu.Lock()
+	defer r.reloadMu.Unlock()
+	v, err := r.load()
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	previous := r.current
+	r.current = v
+	r.mu.Unlock()
+	logging.FromContext(ctx).Info("reloaded policies",
+		zap.String("previous_policy_version", previous.PolicyVersion()), zap.String("policy_version", v.PolicyVersion()))
+	return v, nil
+}
+
+// ReviewAsset reviews asset with the current Validator.
+func (r *Reloader) ReviewAsset(ctx context.Context, asset *validator.Asset) ([]*validator.Violation, error) {
+	return r.Validator().ReviewAsset(ctx, asset)
+}
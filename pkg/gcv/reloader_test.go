@@ -0,0 +1,65 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+	"testing"
+)
+
+func TestReloader(t *testing.T) {
+	ctx := context.Background()
+	// Each load declares the next version, an invalid version fails the load.
+	versions := []string{"1.0.0", "not a version", "2.0.0"}
+	r, err := NewReloader(func() (*Validator, error) {
+		version := versions[0]
+		versions = versions[1:]
+		return NewValidator(append(testOptions(), WithPolicyVersion(version))...)
+	})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	reviewedVersion := func() string {
+		violations, err := r.ReviewAsset(ctx, storageAssetNoLogging())
+		if err != nil {
+			t.Fatal("unexpected error", err)
+		}
+		if len(violations) == 0 {
+			t.Fatal("expected violations")
+		}
+		return violations[0].PolicyVersion
+	}
+	if got := reviewedVersion(); got != "1.0.0" {
+		t.Errorf("got policy version %q, want 1.0.0", got)
+	}
+	if _, err := r.Reload(ctx); err == nil {
+		t.Errorf("expected reload error")
+	}
+	// The failed reload keeps the loaded policies.
+	if got := reviewedVersion(); got != "1.0.0" {
+		t.Errorf("got policy version %q after failed reload, want 1.0.0", got)
+	}
+	v, err := r.Reload(ctx)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if v != r.Validator() || v.PolicyVersion() != "2.0.0" {
+		t.Errorf("got validator with policy version %q, want 2.0.0", r.Validator().PolicyVersion())
+	}
+	if got := reviewedVersion(); got != "2.0.0" {
+		t.Errorf("got policy version %q after reload, want 2.0.0", got)
+	}
+}
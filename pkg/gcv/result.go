@@ -15,20 +15,31 @@
 package gcv
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/forseti-security/config-validator/pkg/api/validator"
+	"github.com/forseti-security/config-validator/pkg/asset"
 	"github.com/forseti-security/config-validator/pkg/gcv/configs"
-	"github.com/golang/protobuf/jsonpb"
 	structpb "github.com/golang/protobuf/ptypes/struct"
 	cftypes "github.com/open-policy-agent/frameworks/constraint/pkg/types"
 	"github.com/pkg/errors"
+	"go.uber.org/zap"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
 const (
 	ConstraintKey = "constraint"
+	// DetailsKey is the metadata key of the details templates report with a violation.
+	DetailsKey = "details"
 )
 
 // Result is the result of reviewing an individual resource
@@ -43,6 +54,14 @@ type Result struct {
 	ReviewResource map[string]interface{}
 	// ConstraintViolations are the constraints that were not satisfied during review.
 	ConstraintViolations []ConstraintViolation
+	// Source optionally identifies the export record the resource was read from.
+	Source *asset.Source
+	// PolicyVersion is the version of the policy set the resource was reviewed with, as returned
+	// by Validator.PolicyVersion.
+	PolicyVersion string
+	// Skipped is set if no target handles the resource, which was not reviewed. Only reported
+	// with WithSkippedAssets.
+	Skipped bool
 }
 
 // NewResult creates a Result from the provided CF Response.
@@ -51,9 +70,23 @@ func NewResult(
 	caiResource map[string]interface{},
 	reviewResource map[string]interface{},
 	responses *cftypes.Responses) (*Result, error) {
-	cfResponse, found := responses.ByTarget[target]
-	if !found {
-		return nil, errors.Errorf("No response for target %s", target)
+	return NewMultiTargetResult(caiResource, reviewResource, map[string]*cftypes.Responses{target: responses})
+}
+
+// NewMultiTargetResult creates a Result merging the violations found by several targets
+// reviewing the same resource, given the CF Responses of each review by target name. Every
+// target must have responded.
+func NewMultiTargetResult(
+	caiResource map[string]interface{},
+	reviewResource map[string]interface{},
+	responses map[string]*cftypes.Responses) (*Result, error) {
+	var cfResults []*cftypes.Result
+	for target, targetResponses := range responses {
+		cfResponse, found := targetResponses.ByTarget[target]
+		if !found {
+			return nil, classify(ErrNoTargetResponse, errors.Errorf("No response for target %s", target))
+		}
+		cfResults = append(cfResults, cfResponse.Results...)
 	}
 
 	resNameIface, found := caiResource["name"]
@@ -65,13 +98,16 @@ func NewResult(
 		return nil, errors.Errorf("failed to convert resource name to string %v", resNameIface)
 	}
 
+	// The result refers to the resources rather than copying them, so it holds on to no more
+	// memory than the review does.
 	result := &Result{
 		Name:                 name,
 		CAIResource:          caiResource,
 		ReviewResource:       reviewResource,
-		ConstraintViolations: make([]ConstraintViolation, len(cfResponse.Results)),
+		ConstraintViolations: make([]ConstraintViolation, len(cfResults)),
 	}
-	for idx, cfResult := range cfResponse.Results {
+	names := make([]string, len(cfResults))
+	for idx, cfResult := range cfResults {
 		for k, _ := range cfResult.Metadata {
 			if k == ConstraintKey {
 				return nil, errors.Errorf("constraint template metadata contains reserved key %s", ConstraintKey)
@@ -81,16 +117,47 @@ func NewResult(
 		if err != nil || !found {
 			severity = ""
 		}
+		// Remediation annotations are validated when the configuration is loaded.
+		remediation, _ := configs.ConstraintRemediation(cfResult.Constraint)
 		result.ConstraintViolations[idx] = ConstraintViolation{
-			Message:    cfResult.Msg,
-			Metadata:   cfResult.Metadata,
-			Constraint: cfResult.Constraint,
-			Severity:   severity,
+			Message:        cfResult.Msg,
+			Metadata:       cfResult.Metadata,
+			Constraint:     cfResult.Constraint,
+			Severity:       severity,
+			Remediation:    remediation.Text,
+			RemediationURL: remediation.URL,
 		}
+		names[idx] = ConstraintName(cfResult.Constraint)
 	}
+	// The Constraint Framework returns results in no particular order, nor are the targets
+	// ordered.
+	sort.Stable(&violationsByName{violations: result.ConstraintViolations, names: names})
 	return result, nil
 }
 
+// violationsByName sorts violations by constraint name and message, with the names of the
+// constraints computed once rather than on every comparison.
+type violationsByName struct {
+	violations []ConstraintViolation
+	names      []string
+}
+
+func (s *violationsByName) Len() int {
+	return len(s.violations)
+}
+
+func (s *violationsByName) Less(i, j int) bool {
+	if s.names[i] != s.names[j] {
+		return s.names[i] < s.names[j]
+	}
+	return s.violations[i].Message < s.violations[j].Message
+}
+
+func (s *violationsByName) Swap(i, j int) {
+	s.violations[i], s.violations[j] = s.violations[j], s.violations[i]
+	s.names[i], s.names[j] = s.names[j], s.names[i]
+}
+
 // ConstraintViolations represents an unsatisfied constraint
 type ConstraintViolation struct {
 	// Message is a human readable message for the violation
@@ -101,31 +168,61 @@ type ConstraintViolation struct {
 	Constraint *unstructured.Unstructured
 	// Constraint Severity
 	Severity string
+	// Remediation is the guidance the constraint declares on fixing the violation, if any.
+	Remediation string
+	// RemediationURL links to documentation on fixing the violation, if the constraint declares
+	// one.
+	RemediationURL string
+	// Snippets are concrete changes fixing the violation, rendered by the Remediator set with
+	// WithRemediator.
+	Snippets []Snippet
 }
 
-// ToInsights returns the result represented as a slice of insights.
+// ToInsights returns the result represented as a slice of insights. The content of the insights
+// refers to the resource and to the parameters of the constraints rather than copying them, and
+// the insights of a constraint and its aliases share their content, so it must not be modified.
 func (r *Result) ToInsights() []*Insight {
 	if len(r.ConstraintViolations) == 0 {
 		return nil
 	}
 
-	insights := make([]*Insight, len(r.ConstraintViolations))
-	for idx, cv := range r.ConstraintViolations {
-		i := &Insight{
-			Description:     cv.Message,
-			TargetResources: []string{r.Name},
-			InsightSubtype:  cv.name(),
-			Content: map[string]interface{}{
-				"resource": r.CAIResource,
-				"metadata": cv.metadata(nil),
-			},
-			Category: "SECURITY",
+	insights := make([]*Insight, 0, len(r.ConstraintViolations))
+	for idx := range r.ConstraintViolations {
+		cv := &r.ConstraintViolations[idx]
+		content := map[string]interface{}{
+			"resource": r.CAIResource,
+			"metadata": cv.metadata(nil),
+		}
+		if remediation := cv.remediationContent(); remediation != nil {
+			content["remediation"] = remediation
+		}
+		for _, name := range cv.names() {
+			i := &Insight{
+				Description:     cv.Message,
+				TargetResources: []string{r.Name},
+				InsightSubtype:  name,
+				Content:         content,
+				Category:        "SECURITY",
+				PolicyVersion:   r.PolicyVersion,
+			}
+			insights = append(insights, i)
 		}
-		insights[idx] = i
 	}
 	return insights
 }
 
+// remediationContent returns the remediation of cv as reported in insight content, nil if the
+// constraint declares none.
+func (cv *ConstraintViolation) remediationContent() map[string]interface{} {
+	if cv.Remediation == "" && cv.RemediationURL == "" {
+		return nil
+	}
+	return map[string]interface{}{
+		"text": cv.Remediation,
+		"url":  cv.RemediationURL,
+	}
+}
+
 func (r *Result) ToViolations() ([]*validator.Violation, error) {
 	ancestryPath, found, err := unstructured.NestedString(r.CAIResource, ancestryPathKey)
 	if err != nil {
@@ -142,11 +239,33 @@ func (r *Result) ToViolations() ([]*validator.Violation, error) {
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to convert result")
 		}
+		violation.PolicyVersion = r.PolicyVersion
 		violations = append(violations, violation)
+		for _, alias := range rv.names()[1:] {
+			aliasViolation := *violation
+			aliasViolation.Constraint = alias
+			violations = append(violations, &aliasViolation)
+		}
 	}
+	SortViolations(violations)
 	return violations, nil
 }
 
+// SortViolations sorts violations by resource, constraint and message, so that outputs do not
+// depend on the order assets were reviewed in.
+func SortViolations(violations []*validator.Violation) {
+	sort.SliceStable(violations, func(i, j int) bool {
+		a, b := violations[i], violations[j]
+		if a.Resource != b.Resource {
+			return a.Resource < b.Resource
+		}
+		if a.Constraint != b.Constraint {
+			return a.Constraint < b.Constraint
+		}
+		return a.Message < b.Message
+	})
+}
+
 func (cv *ConstraintViolation) metadata(auxMetadata map[string]interface{}) map[string]interface{} {
 	labels := cv.Constraint.GetLabels()
 	if labels == nil {
@@ -156,20 +275,11 @@ func (cv *ConstraintViolation) metadata(auxMetadata map[string]interface{}) map[
 	if annotations == nil {
 		annotations = map[string]string{}
 	}
-	params, found, err := unstructured.NestedMap(cv.Constraint.Object, "spec", "parameters")
-	if err != nil {
-		panic(fmt.Sprintf(
-			"constraint has invalid schema (%#v), should have already been validated, "+
-				" .spec.parameters got schema error on access: %s", cv.Constraint.Object, err))
-	}
-	if !found {
-		params = map[string]interface{}{}
-	}
 	metadata := map[string]interface{}{
 		ConstraintKey: map[string]interface{}{
 			"labels":      labels,
 			"annotations": annotations,
-			"parameters":  params,
+			"parameters":  cv.parameters(),
 		},
 	}
 	for k, v := range auxMetadata {
@@ -181,36 +291,258 @@ func (cv *ConstraintViolation) metadata(auxMetadata map[string]interface{}) map[
 	return metadata
 }
 
+// parameters returns the parameters of the constraint, which are shared with the constraint
+// rather than copied.
+func (cv *ConstraintViolation) parameters() map[string]interface{} {
+	params, found, err := unstructured.NestedFieldNoCopy(cv.Constraint.Object, "spec", "parameters")
+	if !found && err == nil {
+		return map[string]interface{}{}
+	}
+	paramsMap, ok := params.(map[string]interface{})
+	if err != nil || !ok {
+		panic(fmt.Sprintf(
+			"constraint has invalid schema (%#v), should have already been validated, "+
+				" .spec.parameters got schema error on access: %v", cv.Constraint.Object, err))
+	}
+	return paramsMap
+}
+
+// metadataScratch holds the maps toViolation builds the metadata of a violation in before
+// converting it to a structpb value. They are reused through metadataPool, as reviews with many
+// violations would otherwise allocate and discard several maps per violation.
+type metadataScratch struct {
+	metadata   map[string]interface{}
+	constraint map[string]interface{}
+}
+
+var metadataPool = sync.Pool{
+	New: func() interface{} {
+		return &metadataScratch{metadata: map[string]interface{}{}, constraint: map[string]interface{}{}}
+	},
+}
+
+// fill sets the scratch maps to the metadata of cv, as returned by cv.metadata with the ancestry
+// path as auxiliary metadata. The labels, annotations and parameters are those of the constraint
+// rather than copies, as they are only read by the conversion.
+func (s *metadataScratch) fill(cv *ConstraintViolation, ancestryPath string) map[string]interface{} {
+	var labels, annotations map[string]interface{}
+	if metadata, ok := cv.Constraint.Object["metadata"].(map[string]interface{}); ok {
+		labels, _ = metadata["labels"].(map[string]interface{})
+		annotations, _ = metadata["annotations"].(map[string]interface{})
+	}
+	s.constraint["labels"] = labels
+	s.constraint["annotations"] = annotations
+	s.constraint["parameters"] = cv.parameters()
+	s.metadata[ConstraintKey] = s.constraint
+	s.metadata[ancestryPathKey] = ancestryPath
+	for k, v := range cv.Metadata {
+		s.metadata[k] = v
+	}
+	return s.metadata
+}
+
+// release clears the scratch maps, so they hold on to no violation, and returns them to the pool.
+func (s *metadataScratch) release() {
+	for k := range s.metadata {
+		delete(s.metadata, k)
+	}
+	for k := range s.constraint {
+		delete(s.constraint, k)
+	}
+	metadataPool.Put(s)
+}
+
+// ConstraintName returns the name of the violated constraint as reported in violations and
+// insights, in "[Kind].[Name]" format.
+func (cv *ConstraintViolation) ConstraintName() string {
+	return cv.name()
+}
+
 // name returns the name for the constraint, this is given as "[Kind].[Name]" to uniquely identify which template and
 // constraint the violation came from.
 func (cv *ConstraintViolation) name() string {
-	name := cv.Constraint.GetName()
-	ans := cv.Constraint.GetAnnotations()
-	if ans != nil {
-		if originalName, ok := ans[configs.OriginalName]; ok {
-			name = originalName
+	return ConstraintName(cv.Constraint)
+}
+
+// ConstraintName returns the name of a loaded constraint as reported in violations, in
+// "[Kind].[Name]" format with the name as declared in the policy files.
+func ConstraintName(constraint *unstructured.Unstructured) string {
+	name := configs.Annotation(constraint, configs.OriginalName)
+	if name == "" {
+		name = constraint.GetName()
+	}
+	return constraint.GetKind() + "." + name
+}
+
+// timeNow is replaced in tests to control alias expiry.
+var timeNow = time.Now
+
+// names returns the name of the constraint followed by the names of its active aliases, under
+// which violations are reported as well while the constraint is being renamed.
+func (cv *ConstraintViolation) names() []string {
+	names := []string{cv.name()}
+	aliases, err := configs.ConstraintAliases(cv.Constraint, timeNow())
+	if err != nil {
+		// Aliases are validated when the configuration is loaded.
+		zap.L().Warn("ignoring aliases", zap.Error(err))
+		return names
+	}
+	for _, alias := range aliases {
+		names = append(names, fmt.Sprintf("%s.%s", cv.Constraint.GetKind(), alias))
+	}
+	return names
+}
+
+// maxExactFloatInt is the largest magnitude integer that a float64 (and therefore a structpb
+// number value) can represent exactly.
+const maxExactFloatInt = 1 << 53
+
+// intValue returns the structpb value for an integer: integers that are exactly representable as
+// a float64 are kept as numbers, larger ones are returned as decimal strings following the proto3
+// JSON mapping for int64.
+func intValue(i int64) *structpb.Value {
+	if -maxExactFloatInt <= i && i <= maxExactFloatInt {
+		return &structpb.Value{Kind: &structpb.Value_NumberValue{NumberValue: float64(i)}}
+	}
+	return &structpb.Value{Kind: &structpb.Value_StringValue{StringValue: strconv.FormatInt(i, 10)}}
+}
+
+// floatValue returns the structpb value for a float, rejecting values that have no JSON
+// representation.
+func floatValue(f float64) (*structpb.Value, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil, errors.Errorf("unsupported number %v", f)
+	}
+	return &structpb.Value{Kind: &structpb.Value_NumberValue{NumberValue: f}}, nil
+}
+
+// numberValue returns the structpb value for a JSON number.
+func numberValue(number json.Number) (*structpb.Value, error) {
+	isInt := !strings.ContainsAny(string(number), ".eE")
+	if isInt {
+		if i, err := strconv.ParseInt(string(number), 10, 64); err == nil {
+			return intValue(i), nil
 		}
 	}
-	return fmt.Sprintf("%s.%s", cv.Constraint.GetKind(), name)
+	f, err := strconv.ParseFloat(string(number), 64)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid number %s", number)
+	}
+	if isInt {
+		return &structpb.Value{Kind: &structpb.Value_StringValue{StringValue: string(number)}}, nil
+	}
+	return floatValue(f)
+}
+
+// toValue converts a value decoded from JSON to a structpb value without a JSON round trip.
+// Integers that would lose precision as a structpb number are converted to their decimal string
+// representation. Types that do not originate from JSON decoding fall back to a JSON round trip.
+func toValue(v interface{}) (*structpb.Value, error) {
+	switch t := v.(type) {
+	case nil:
+		return &structpb.Value{Kind: &structpb.Value_NullValue{}}, nil
+	case bool:
+		return &structpb.Value{Kind: &structpb.Value_BoolValue{BoolValue: t}}, nil
+	case string:
+		return &structpb.Value{Kind: &structpb.Value_StringValue{StringValue: t}}, nil
+	case json.Number:
+		return numberValue(t)
+	case float64:
+		return floatValue(t)
+	case float32:
+		return floatValue(float64(t))
+	case int:
+		return intValue(int64(t)), nil
+	case int32:
+		return intValue(int64(t)), nil
+	case int64:
+		return intValue(t), nil
+	case uint64:
+		return numberValue(json.Number(strconv.FormatUint(t, 10)))
+	case map[string]interface{}:
+		fields := make(map[string]*structpb.Value, len(t))
+		for key, value := range t {
+			fieldValue, err := toValue(value)
+			if err != nil {
+				return nil, errors.Wrapf(err, "field %s", key)
+			}
+			fields[key] = fieldValue
+		}
+		return &structpb.Value{Kind: &structpb.Value_StructValue{StructValue: &structpb.Struct{Fields: fields}}}, nil
+	case map[string]string:
+		fields := make(map[string]*structpb.Value, len(t))
+		for key, value := range t {
+			fields[key] = &structpb.Value{Kind: &structpb.Value_StringValue{StringValue: value}}
+		}
+		return &structpb.Value{Kind: &structpb.Value_StructValue{StructValue: &structpb.Struct{Fields: fields}}}, nil
+	case []interface{}:
+		values := make([]*structpb.Value, len(t))
+		for idx, value := range t {
+			listValue, err := toValue(value)
+			if err != nil {
+				return nil, errors.Wrapf(err, "index %d", idx)
+			}
+			values[idx] = listValue
+		}
+		return &structpb.Value{Kind: &structpb.Value_ListValue{ListValue: &structpb.ListValue{Values: values}}}, nil
+	case []string:
+		values := make([]*structpb.Value, len(t))
+		for idx, value := range t {
+			values[idx] = &structpb.Value{Kind: &structpb.Value_StringValue{StringValue: value}}
+		}
+		return &structpb.Value{Kind: &structpb.Value_ListValue{ListValue: &structpb.ListValue{Values: values}}}, nil
+	}
+
+	jsonBytes, err := json.Marshal(v)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to marshal %T to json", v)
+	}
+	var decoded interface{}
+	if err := asset.UnmarshalJSON(jsonBytes, &decoded); err != nil {
+		return nil, errors.Wrapf(err, "failed to unmarshal json %s", string(jsonBytes))
+	}
+	return toValue(decoded)
+}
+
+// Fingerprint returns a stable identifier for the violation of this constraint by the named
+// resource. It matches ViolationFingerprint for the corresponding validator.Violation.
+func (cv *ConstraintViolation) Fingerprint(resource string) string {
+	return Fingerprint(cv.name(), resource, cv.Message)
+}
+
+// ViolationFingerprint returns a stable identifier for a violation which can be used to correlate
+// findings across review runs.
+func ViolationFingerprint(v *validator.Violation) string {
+	return Fingerprint(v.Constraint, v.Resource, v.Message)
+}
+
+// Fingerprint returns the fingerprint of a violation of the named constraint, in "[Kind].[Name]"
+// format, by the named resource with message, for violations found outside a review.
+func Fingerprint(constraint, resource, message string) string {
+	h := sha256.New()
+	for _, part := range []string{constraint, resource, message} {
+		_, _ = h.Write([]byte(part))
+		_, _ = h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil)[:16])
 }
 
 // toViolation converts the constriant to a violation.
 func (cv *ConstraintViolation) toViolation(name string, ancestryPath string) (*validator.Violation, error) {
-	metadataJson, err := json.Marshal(cv.metadata(map[string]interface{}{ancestryPathKey: ancestryPath}))
+	scratch := metadataPool.Get().(*metadataScratch)
+	metadata, err := toValue(scratch.fill(cv, ancestryPath))
+	scratch.release()
 	if err != nil {
-		return nil, errors.Wrapf(
-			err, "failed to marshal result metadata %v to json", cv.Metadata)
-	}
-	metadata := &structpb.Value{}
-	if err := jsonpb.UnmarshalString(string(metadataJson), metadata); err != nil {
-		return nil, errors.Wrapf(err, "failed to unmarshal json %s into structpb", string(metadataJson))
+		return nil, errors.Wrapf(err, "failed to convert result metadata %v to structpb", cv.Metadata)
 	}
 
 	return &validator.Violation{
-		Constraint: cv.name(),
-		Resource:   name,
-		Message:    cv.Message,
-		Metadata:   metadata,
-		Severity:   cv.Severity,
+		Constraint:     cv.name(),
+		Resource:       name,
+		Message:        cv.Message,
+		Metadata:       metadata,
+		Severity:       cv.Severity,
+		Remediation:    cv.Remediation,
+		RemediationUrl: cv.RemediationURL,
 	}, nil
 }
@@ -16,13 +16,20 @@ package gcv
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"sort"
 	"testing"
+	"time"
 
 	"github.com/forseti-security/config-validator/pkg/api/validator"
+	"github.com/forseti-security/config-validator/pkg/gcv/configs"
+	"github.com/golang/protobuf/jsonpb"
 	structpb "github.com/golang/protobuf/ptypes/struct"
 	"github.com/google/go-cmp/cmp"
+	cftypes "github.com/open-policy-agent/frameworks/constraint/pkg/types"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
 type ConversionTestCase struct {
@@ -75,10 +82,10 @@ var conversionTestCases = []ConversionTestCase{
 								"lifecycle":        map[string]interface{}{"rule": []interface{}{}},
 								"location":         string("US-CENTRAL1"),
 								"logging":          map[string]interface{}{},
-								"metageneration":   float64(2),
+								"metageneration":   json.Number("2"),
 								"name":             string("my-storage-bucket"),
 								"owner":            map[string]interface{}{},
-								"projectNumber":    float64(6.8478495408e+10),
+								"projectNumber":    json.Number("68478495408"),
 								"retentionPolicy":  map[string]interface{}{},
 								"selfLink":         string("https://www.googleapis.com/storage/v1/b/my-storage-bucket"),
 								"storageClass":     string("STANDARD"),
@@ -94,7 +101,8 @@ var conversionTestCases = []ConversionTestCase{
 						},
 					},
 				},
-				Category: "SECURITY",
+				Category:      "SECURITY",
+				PolicyVersion: "1.2.3",
 			},
 			{
 				Description:     "//storage.googleapis.com/my-storage-bucket does not have the required logging destination.",
@@ -135,10 +143,10 @@ var conversionTestCases = []ConversionTestCase{
 								"lifecycle":        map[string]interface{}{"rule": []interface{}{}},
 								"location":         string("US-CENTRAL1"),
 								"logging":          map[string]interface{}{},
-								"metageneration":   float64(2),
+								"metageneration":   json.Number("2"),
 								"name":             string("my-storage-bucket"),
 								"owner":            map[string]interface{}{},
-								"projectNumber":    float64(6.8478495408e+10),
+								"projectNumber":    json.Number("68478495408"),
 								"retentionPolicy":  map[string]interface{}{},
 								"selfLink":         string("https://www.googleapis.com/storage/v1/b/my-storage-bucket"),
 								"storageClass":     string("STANDARD"),
@@ -154,7 +162,8 @@ var conversionTestCases = []ConversionTestCase{
 						},
 					},
 				},
-				Category: "SECURITY",
+				Category:      "SECURITY",
+				PolicyVersion: "1.2.3",
 			},
 		},
 		wantViolations: []*validator.Violation{
@@ -177,7 +186,8 @@ var conversionTestCases = []ConversionTestCase{
 						"parameters": map[string]interface{}{},
 					},
 				}),
-				Severity: "high",
+				Severity:      "high",
+				PolicyVersion: "1.2.3",
 			},
 			{
 				Constraint: "GCPStorageLoggingConstraint.require_storage_logging_XX",
@@ -199,7 +209,8 @@ var conversionTestCases = []ConversionTestCase{
 						"parameters": map[string]interface{}{},
 					},
 				}),
-				Severity: "medium",
+				Severity:      "medium",
+				PolicyVersion: "1.2.3",
 			},
 		},
 	},
@@ -222,12 +233,14 @@ func mustAsStruct(v interface{}) *structpb.Value {
 
 	case string:
 		return &structpb.Value{Kind: &structpb.Value_StringValue{StringValue: vv}}
+	case float64:
+		return &structpb.Value{Kind: &structpb.Value_NumberValue{NumberValue: vv}}
 	}
 	panic(fmt.Sprintf("unhandled: %v", v))
 }
 
 func TestConversion(t *testing.T) {
-	v, err := NewValidator(testOptions())
+	v, err := NewValidator(append(testOptions(), WithPolicyVersion("1.2.3"))...)
 	if err != nil {
 		t.Fatal("fatal error:", err)
 	}
@@ -259,3 +272,397 @@ func TestConversion(t *testing.T) {
 		})
 	}
 }
+
+func TestLargeIntegerMetadata(t *testing.T) {
+	constraint := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "constraints.gatekeeper.sh/v1alpha1",
+		"kind":       "GCPTestConstraint",
+		"metadata": map[string]interface{}{
+			"name": "large-numbers",
+		},
+		"spec": map[string]interface{}{
+			"parameters": map[string]interface{}{
+				"max_bytes": int64(9007199254740993),
+				"min_bytes": int64(1024),
+			},
+		},
+	}}
+	cv := &ConstraintViolation{
+		Message:    "too big",
+		Constraint: constraint,
+		Metadata: map[string]interface{}{
+			"details": map[string]interface{}{
+				"instance_id": json.Number("7523859814704497496"),
+				"overflow":    json.Number("123456789012345678901234"),
+				"count":       json.Number("42"),
+				"ratio":       json.Number("0.25"),
+			},
+		},
+	}
+	violation, err := cv.toViolation("//compute.googleapis.com/projects/p/instances/i", "organizations/1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := mustAsStruct(map[string]interface{}{
+		"ancestry_path": "organizations/1",
+		"details": map[string]interface{}{
+			"instance_id": "7523859814704497496",
+			"overflow":    "123456789012345678901234",
+			"count":       float64(42),
+			"ratio":       float64(0.25),
+		},
+		"constraint": map[string]interface{}{
+			"annotations": map[string]interface{}{},
+			"labels":      map[string]interface{}{},
+			"parameters": map[string]interface{}{
+				"max_bytes": "9007199254740993",
+				"min_bytes": float64(1024),
+			},
+		},
+	})
+	if diff := cmp.Diff(violation.Metadata, want); diff != "" {
+		t.Errorf("metadata mismatch, +got -want\n%s", diff)
+	}
+}
+
+func TestToViolationMetadataReuse(t *testing.T) {
+	plain := benchmarkViolation()
+	plain.Metadata = nil
+	// The metadata maps are reused across conversions, so no violation may see the metadata of
+	// another.
+	for _, cv := range []*ConstraintViolation{benchmarkViolation(), plain, benchmarkViolation()} {
+		violation, err := cv.toViolation("//compute.googleapis.com/projects/p/instances/i", "organizations/1")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want, err := toValue(cv.metadata(map[string]interface{}{ancestryPathKey: "organizations/1"}))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if diff := cmp.Diff(want, violation.Metadata); diff != "" {
+			t.Errorf("metadata mismatch (-want +got):\n%s", diff)
+		}
+	}
+}
+
+func TestReviewJSONLargeIntegers(t *testing.T) {
+	v, err := NewValidator(testOptions()...)
+	if err != nil {
+		t.Fatal("fatal error:", err)
+	}
+	input := `{
+  "name": "//storage.googleapis.com/large-numbers",
+  "ancestry_path": "organizations/1/projects/3",
+  "asset_type": "storage.googleapis.com/Bucket",
+  "resource": {"version": "v1", "data": {"projectNumber": 7523859814704497496}}
+}`
+	result, err := v.ReviewJSON(context.Background(), input)
+	if err != nil {
+		t.Fatal("fatal error:", err)
+	}
+	got, _, err := unstructured.NestedFieldNoCopy(result.CAIResource, "resource", "data", "projectNumber")
+	if err != nil {
+		t.Fatal("fatal error:", err)
+	}
+	if got != json.Number("7523859814704497496") {
+		t.Errorf("got projectNumber %#v, want exact json.Number", got)
+	}
+}
+
+// jsonRoundTripValue is the previous JSON round trip implementation of toValue, which is kept as
+// the reference for values that are exactly representable as JSON numbers.
+func jsonRoundTripValue(v interface{}) (*structpb.Value, error) {
+	jsonBytes, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	value := &structpb.Value{}
+	if err := jsonpb.UnmarshalString(string(jsonBytes), value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+type customMetadata struct {
+	Zone  string `json:"zone"`
+	Count int    `json:"count"`
+}
+
+func TestToValue(t *testing.T) {
+	var testCases = []struct {
+		name  string
+		input interface{}
+	}{
+		{name: "nil", input: nil},
+		{name: "bool", input: true},
+		{name: "string", input: "value"},
+		{name: "float", input: 1.5},
+		{name: "json number", input: json.Number("-12")},
+		{name: "json float", input: json.Number("1e3")},
+		{name: "int", input: 7},
+		{name: "string map", input: map[string]string{"env": "prod"}},
+		{name: "string list", input: []string{"a", "b"}},
+		{name: "struct fallback", input: customMetadata{Zone: "us-central1-a", Count: 3}},
+		{
+			name: "nested",
+			input: map[string]interface{}{
+				"list":  []interface{}{json.Number("1"), "two", nil, false},
+				"inner": map[string]interface{}{"labels": map[string]string{}},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := toValue(tc.input)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			want, err := jsonRoundTripValue(tc.input)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(got, want); diff != "" {
+				t.Errorf("value mismatch, +got -want\n%s", diff)
+			}
+		})
+	}
+}
+
+func benchmarkViolation() *ConstraintViolation {
+	constraint := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "constraints.gatekeeper.sh/v1alpha1",
+		"kind":       "GCPTestConstraint",
+		"metadata": map[string]interface{}{
+			"name":        "benchmark",
+			"labels":      map[string]interface{}{"env": "prod"},
+			"annotations": map[string]interface{}{"owner": "security"},
+		},
+		"spec": map[string]interface{}{
+			"parameters": map[string]interface{}{
+				"allowed": []interface{}{"us-central1", "us-east1", "europe-west1"},
+				"mode":    "allowlist",
+			},
+		},
+	}}
+	return &ConstraintViolation{
+		Message:    "location not allowed",
+		Constraint: constraint,
+		Metadata: map[string]interface{}{
+			"details": map[string]interface{}{
+				"location":    "asia-east1",
+				"instance_id": json.Number("7523859814704497496"),
+				"disks":       []interface{}{map[string]interface{}{"size_gb": json.Number("100")}},
+			},
+		},
+	}
+}
+
+func BenchmarkToViolation(b *testing.B) {
+	cv := benchmarkViolation()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := cv.toViolation("//compute.googleapis.com/projects/p/instances/i", "organizations/1"); err != nil {
+			b.Fatalf("unexpected error %s", err)
+		}
+	}
+}
+
+func BenchmarkToViolationJSONRoundTrip(b *testing.B) {
+	cv := benchmarkViolation()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := jsonRoundTripValue(cv.metadata(map[string]interface{}{ancestryPathKey: "organizations/1"})); err != nil {
+			b.Fatalf("unexpected error %s", err)
+		}
+	}
+}
+
+func TestConstraintAliases(t *testing.T) {
+	defer func() { timeNow = time.Now }()
+	constraint := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "constraints.gatekeeper.sh/v1alpha1",
+		"kind":       "GCPTestConstraint",
+		"metadata": map[string]interface{}{
+			"name": "new-name",
+			"annotations": map[string]interface{}{
+				configs.Aliases:       "old-name",
+				configs.AliasesExpire: "2020-07-01",
+			},
+		},
+	}}
+	result := &Result{
+		Name:        "//compute.googleapis.com/projects/p/instances/i",
+		CAIResource: map[string]interface{}{ancestryPathKey: "organizations/1"},
+		ConstraintViolations: []ConstraintViolation{
+			{Message: "renamed", Constraint: constraint},
+		},
+	}
+
+	var testCases = []struct {
+		name string
+		now  time.Time
+		want []string
+	}{
+		{
+			name: "during transition",
+			now:  time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC),
+			want: []string{"GCPTestConstraint.new-name", "GCPTestConstraint.old-name"},
+		},
+		{
+			name: "after transition",
+			now:  time.Date(2020, 7, 1, 0, 0, 0, 0, time.UTC),
+			want: []string{"GCPTestConstraint.new-name"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			timeNow = func() time.Time { return tc.now }
+			violations, err := result.ToViolations()
+			if err != nil {
+				t.Fatal("fatal error:", err)
+			}
+			var got []string
+			for _, violation := range violations {
+				got = append(got, violation.Constraint)
+				if violation.Message != "renamed" {
+					t.Errorf("got message %q for %s, want renamed", violation.Message, violation.Constraint)
+				}
+			}
+			if diff := cmp.Diff(got, tc.want); diff != "" {
+				t.Errorf("violation constraints mismatch, +got -want\n%s", diff)
+			}
+
+			got = nil
+			for _, insight := range result.ToInsights() {
+				got = append(got, insight.InsightSubtype)
+			}
+			if diff := cmp.Diff(got, tc.want); diff != "" {
+				t.Errorf("insight subtypes mismatch, +got -want\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNewResultOrder(t *testing.T) {
+	constraint := func(kind, name string) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "constraints.gatekeeper.sh/v1alpha1",
+			"kind":       kind,
+			"metadata":   map[string]interface{}{"name": name},
+		}}
+	}
+	responses := &cftypes.Responses{ByTarget: map[string]*cftypes.Response{
+		"target": {Results: []*cftypes.Result{
+			{Msg: "b", Constraint: constraint("GCPZConstraint", "z")},
+			{Msg: "b", Constraint: constraint("GCPAConstraint", "a")},
+			{Msg: "a", Constraint: constraint("GCPZConstraint", "z")},
+		}},
+	}}
+	result, err := NewResult("target", map[string]interface{}{"name": "n", ancestryPathKey: "organizations/1"}, nil, responses)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	var got []string
+	for _, cv := range result.ConstraintViolations {
+		got = append(got, cv.ConstraintName()+" "+cv.Message)
+	}
+	want := []string{"GCPAConstraint.a b", "GCPZConstraint.z a", "GCPZConstraint.z b"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected order (-want +got):\n%s", diff)
+	}
+
+	violations := []*validator.Violation{
+		{Resource: "b", Constraint: "c1", Message: "m"},
+		{Resource: "a", Constraint: "c2", Message: "m"},
+		{Resource: "a", Constraint: "c1", Message: "n"},
+		{Resource: "a", Constraint: "c1", Message: "m"},
+	}
+	SortViolations(violations)
+	got = nil
+	for _, v := range violations {
+		got = append(got, v.Resource+" "+v.Constraint+" "+v.Message)
+	}
+	want = []string{"a c1 m", "a c1 n", "a c2 m", "b c1 m"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected order (-want +got):\n%s", diff)
+	}
+}
+
+func TestNewMultiTargetResult(t *testing.T) {
+	constraint := func(kind, name string) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "constraints.gatekeeper.sh/v1alpha1",
+			"kind":       kind,
+			"metadata":   map[string]interface{}{"name": name},
+		}}
+	}
+	responses := map[string]*cftypes.Responses{
+		"gcp": {ByTarget: map[string]*cftypes.Response{
+			"gcp": {Results: []*cftypes.Result{{Msg: "no labels", Constraint: constraint("GCPLabelsConstraint", "labels")}}},
+		}},
+		"k8s": {ByTarget: map[string]*cftypes.Response{
+			"k8s": {Results: []*cftypes.Result{{Msg: "no cost center", Constraint: constraint("K8sRequiredLabels", "cost-center")}}},
+		}},
+	}
+	caiResource := map[string]interface{}{"name": "n", ancestryPathKey: "organizations/1"}
+	result, err := NewMultiTargetResult(caiResource, nil, responses)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	var got []string
+	for _, cv := range result.ConstraintViolations {
+		got = append(got, cv.ConstraintName()+" "+cv.Message)
+	}
+	want := []string{"GCPLabelsConstraint.labels no labels", "K8sRequiredLabels.cost-center no cost center"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected violations (-want +got):\n%s", diff)
+	}
+
+	// Every target must have responded to its review.
+	responses["other"] = responses["gcp"]
+	if _, err := NewMultiTargetResult(caiResource, nil, responses); errors.Cause(err) != ErrNoTargetResponse {
+		t.Errorf("got error %v, want no target response", err)
+	}
+}
+
+func TestRemediation(t *testing.T) {
+	constraint := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "constraints.gatekeeper.sh/v1alpha1",
+		"kind":       "GCPStorageLoggingConstraint",
+		"metadata": map[string]interface{}{
+			"name": "require-logging",
+			"annotations": map[string]interface{}{
+				configs.RemediationText: "Enable access logging on the bucket.",
+				configs.RemediationURL:  "https://cloud.google.com/storage/docs/access-logs",
+			},
+		},
+	}}
+	responses := &cftypes.Responses{ByTarget: map[string]*cftypes.Response{
+		"target": {Results: []*cftypes.Result{{Msg: "no logging", Constraint: constraint}}},
+	}}
+	result, err := NewResult("target", map[string]interface{}{"name": "n", ancestryPathKey: "organizations/1"}, nil, responses)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	cv := result.ConstraintViolations[0]
+	if cv.Remediation != "Enable access logging on the bucket." || cv.RemediationURL != "https://cloud.google.com/storage/docs/access-logs" {
+		t.Errorf("got remediation %q, %q", cv.Remediation, cv.RemediationURL)
+	}
+
+	violations, err := result.ToViolations()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if v := violations[0]; v.Remediation != cv.Remediation || v.RemediationUrl != cv.RemediationURL {
+		t.Errorf("got violation remediation %q, %q", v.Remediation, v.RemediationUrl)
+	}
+
+	content := result.ToInsights()[0].Content.(map[string]interface{})
+	want := map[string]interface{}{"text": cv.Remediation, "url": cv.RemediationURL}
+	if diff := cmp.Diff(want, content["remediation"]); diff != "" {
+		t.Errorf("unexpected insight remediation (-want +got):\n%s", diff)
+	}
+}
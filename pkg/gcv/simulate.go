@@ -0,0 +1,154 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+	"encoding/json"
+
+	asset2 "github.com/forseti-security/config-validator/pkg/asset"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ViolationDelta is the difference between the violations of an asset under the loaded
+// constraints and under candidate constraints.
+type ViolationDelta struct {
+	// Name is the name of the asset as given by CAI.
+	Name string
+	// Added are the violations found only with the candidate constraints.
+	Added []ConstraintViolation
+	// Removed are the violations found only with the loaded constraints.
+	Removed []ConstraintViolation
+}
+
+// SimulationResult is the outcome of Simulate.
+type SimulationResult struct {
+	// Deltas holds the assets whose violations differ, in the order of the assets.
+	Deltas []*ViolationDelta
+	// Current and Candidate are the total number of violations with the loaded and the candidate
+	// constraints.
+	Current, Candidate int
+}
+
+// Simulate reviews assets against the loaded constraints and against candidateConstraints, which
+// replace all loaded constraints in a Constraint Framework client of their own, and returns how
+// the violations differ. Candidate constraints are converted and validated like constraints
+// loaded from files, and must be for the kinds of the loaded templates.
+//
+// The loaded policies are not affected, so Simulate can preview the blast radius of a new policy
+// set while the validator serves reviews. Setting up the candidate client compiles all templates
+// again, which makes Simulate expensive for a small number of assets.
+func (v *Validator) Simulate(
+	ctx context.Context, assets []map[string]interface{}, candidateConstraints []*unstructured.Unstructured) (*SimulationResult, error) {
+	candidateConfig, err := v.config.WithConstraints(candidateConstraints)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid candidate constraints")
+	}
+	gcpCFClient, err := newClientPool(1, newGCPTarget, candidateConfig.GCPTemplates, candidateConfig.GCPConstraints)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to set up candidate GCP Constraint Framework client")
+	}
+	gcpScopes, err := newScopes(candidateConfig.GCPConstraints)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid candidate constraints")
+	}
+	k8sCFClient, err := newClientPool(1, newK8STarget, candidateConfig.K8STemplates, candidateConfig.K8SConstraints)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to set up candidate K8S Constraint Framework client")
+	}
+	targets, err := newRegisteredTargets(1, candidateConfig)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to set up candidate Constraint Framework clients")
+	}
+	// The candidate shares the asset pipeline of v, but not its cache.
+	candidate := &Validator{
+		gcpCFClient:     gcpCFClient,
+		k8sCFClient:     k8sCFClient,
+		targets:         targets,
+		gcpScopes:       gcpScopes,
+		config:          candidateConfig,
+		policyVersion:   v.policyVersion,
+		declaredVersion: v.declaredVersion,
+		transformer:     v.transformer,
+		enricher:        v.enricher,
+		providers:       v.providers,
+	}
+
+	result := &SimulationResult{}
+	for _, asset := range assets {
+		// Reviews add the ancestry path, each review gets its own copy.
+		currentAsset, err := copyAsset(asset)
+		if err != nil {
+			return nil, err
+		}
+		current, err := v.ReviewUnmarshalledJSON(ctx, currentAsset)
+		if err != nil {
+			return nil, errors.Wrapf(err, "asset %s", AssetKey(asset))
+		}
+		candidateAsset, err := copyAsset(asset)
+		if err != nil {
+			return nil, err
+		}
+		simulated, err := candidate.ReviewUnmarshalledJSON(ctx, candidateAsset)
+		if err != nil {
+			return nil, errors.Wrapf(err, "asset %s with candidate constraints", AssetKey(asset))
+		}
+
+		result.Current += len(current.ConstraintViolations)
+		result.Candidate += len(simulated.ConstraintViolations)
+		delta := &ViolationDelta{
+			Name:    current.Name,
+			Added:   violationsNotIn(simulated, current),
+			Removed: violationsNotIn(current, simulated),
+		}
+		if len(delta.Added) != 0 || len(delta.Removed) != 0 {
+			result.Deltas = append(result.Deltas, delta)
+		}
+	}
+	return result, nil
+}
+
+// violationsNotIn returns the violations of result that other does not have, comparing
+// violations by their fingerprint.
+func violationsNotIn(result, other *Result) []ConstraintViolation {
+	fingerprints := map[string]int{}
+	for idx := range other.ConstraintViolations {
+		fingerprints[other.ConstraintViolations[idx].Fingerprint(other.Name)]++
+	}
+	var missing []ConstraintViolation
+	for idx := range result.ConstraintViolations {
+		fingerprint := result.ConstraintViolations[idx].Fingerprint(result.Name)
+		if fingerprints[fingerprint] > 0 {
+			fingerprints[fingerprint]--
+			continue
+		}
+		missing = append(missing, result.ConstraintViolations[idx])
+	}
+	return missing
+}
+
+// copyAsset returns a deep copy of asset, keeping numbers as json.Number.
+func copyAsset(asset map[string]interface{}) (map[string]interface{}, error) {
+	assetBytes, err := json.Marshal(asset)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to copy asset")
+	}
+	copied := map[string]interface{}{}
+	if err := asset2.UnmarshalJSON(assetBytes, &copied); err != nil {
+		return nil, errors.Wrapf(err, "failed to copy asset")
+	}
+	return copied, nil
+}
@@ -0,0 +1,129 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestSimulate(t *testing.T) {
+	v, err := NewValidator(testOptions()...)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	before, err := v.ReviewJSON(context.Background(), storageAssetNoLoggingJSON)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	loaded := append(append([]*unstructured.Unstructured{}, v.config.GCPConstraints...), v.config.K8SConstraints...)
+	var kept []*unstructured.Unstructured
+	for _, constraint := range loaded {
+		if constraint.GetName() != "require-storage-logging" {
+			kept = append(kept, constraint)
+		}
+	}
+	added := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "constraints.gatekeeper.sh/v1alpha1",
+		"kind":       "GCPStorageLoggingConstraint",
+		"metadata":   map[string]interface{}{"name": "candidate_logging"},
+		"spec":       map[string]interface{}{"match": map[string]interface{}{"target": []interface{}{"organization/*"}}},
+	}}
+
+	var testCases = []struct {
+		name        string
+		constraints []*unstructured.Unstructured
+		wantAdded   []string
+		wantRemoved []string
+	}{
+		{
+			name:        "unchanged",
+			constraints: loaded,
+		},
+		{
+			name:        "removed constraint",
+			constraints: kept,
+			wantRemoved: []string{"CFGCPStorageLoggingConstraint.require-storage-logging"},
+		},
+		{
+			name:        "added constraint",
+			constraints: append(append([]*unstructured.Unstructured{}, kept...), added),
+			wantAdded:   []string{"GCPStorageLoggingConstraint.candidate_logging"},
+			wantRemoved: []string{"CFGCPStorageLoggingConstraint.require-storage-logging"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assets := unmarshalAssets(t, storageAssetNoLoggingJSON, storageAssetWithLoggingJSON)
+			result, err := v.Simulate(context.Background(), assets, tc.constraints)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var gotAdded, gotRemoved []string
+			for _, delta := range result.Deltas {
+				if delta.Name != "//storage.googleapis.com/my-storage-bucket" {
+					t.Errorf("unexpected delta for %s", delta.Name)
+				}
+				for idx := range delta.Added {
+					gotAdded = append(gotAdded, delta.Added[idx].ConstraintName())
+				}
+				for idx := range delta.Removed {
+					gotRemoved = append(gotRemoved, delta.Removed[idx].ConstraintName())
+				}
+			}
+			if diff := cmp.Diff(tc.wantAdded, gotAdded); diff != "" {
+				t.Errorf("unexpected added violations (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantRemoved, gotRemoved); diff != "" {
+				t.Errorf("unexpected removed violations (-want +got):\n%s", diff)
+			}
+			if got, want := result.Candidate-result.Current, len(tc.wantAdded)-len(tc.wantRemoved); got != want {
+				t.Errorf("got candidate - current = %d, want %d", got, want)
+			}
+			if _, found := assets[0]["ancestry_path"]; !found || len(assets[0]) != 4 {
+				t.Errorf("asset was modified: %v", assets[0])
+			}
+		})
+	}
+
+	// The loaded constraints are not affected.
+	after, err := v.ReviewJSON(context.Background(), storageAssetNoLoggingJSON)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := len(after.ConstraintViolations), len(before.ConstraintViolations); got != want {
+		t.Errorf("got %d violations after simulating, want %d", got, want)
+	}
+}
+
+func TestSimulateInvalidConstraint(t *testing.T) {
+	v, err := NewValidator(testOptions()...)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	orphan := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "constraints.gatekeeper.sh/v1alpha1",
+		"kind":       "NoSuchConstraint",
+		"metadata":   map[string]interface{}{"name": "orphan"},
+	}}
+	assets := unmarshalAssets(t, storageAssetNoLoggingJSON)
+	if _, err := v.Simulate(context.Background(), assets, []*unstructured.Unstructured{orphan}); err == nil {
+		t.Errorf("expected error")
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+// Formats of remediation snippets.
+const (
+	// SnippetGcloud snippets are gcloud or gsutil commands fixing the violation.
+	SnippetGcloud = "gcloud"
+	// SnippetTerraform snippets are changes to the Terraform configuration of the resource, in
+	// unified diff notation.
+	SnippetTerraform = "terraform"
+)
+
+// Snippet is a concrete change fixing a violation, rendered from the violation and its asset.
+type Snippet struct {
+	// Format is the format of Text, such as SnippetGcloud or SnippetTerraform.
+	Format string `json:"format" yaml:"format"`
+	Text   string `json:"text" yaml:"text"`
+}
+
+// Remediator renders remediation snippets for violations.
+type Remediator interface {
+	// Snippets returns the snippets fixing cv, a violation of result, or nil if it has none. It
+	// must not modify result.
+	Snippets(result *Result, cv *ConstraintViolation) []Snippet
+}
+
+// WithRemediator attaches the snippets r renders to the violations of every review result.
+func WithRemediator(r Remediator) Option {
+	return func(v *Validator) {
+		v.remediator = r
+	}
+}
+
+// remediate sets the snippets of the violations of result.
+func (v *Validator) remediate(result *Result) {
+	if v.remediator == nil {
+		return
+	}
+	for idx := range result.ConstraintViolations {
+		cv := &result.ConstraintViolations[idx]
+		cv.Snippets = v.remediator.Snippets(result, cv)
+	}
+}
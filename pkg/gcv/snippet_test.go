@@ -0,0 +1,49 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// fakeRemediator renders one snippet naming the violation.
+type fakeRemediator struct{}
+
+func (fakeRemediator) Snippets(result *Result, cv *ConstraintViolation) []Snippet {
+	return []Snippet{{Format: SnippetGcloud, Text: "fix " + cv.ConstraintName() + " on " + result.Name}}
+}
+
+func TestReviewWithRemediator(t *testing.T) {
+	v, err := NewValidator(append(testOptions(), WithRemediator(fakeRemediator{}))...)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	result, err := v.ReviewJSON(context.Background(), storageAssetNoLoggingJSON)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if len(result.ConstraintViolations) == 0 {
+		t.Fatal("expected violations")
+	}
+	for _, cv := range result.ConstraintViolations {
+		want := []Snippet{{Format: SnippetGcloud, Text: "fix " + cv.ConstraintName() + " on " + result.Name}}
+		if diff := cmp.Diff(want, cv.Snippets); diff != "" {
+			t.Errorf("unexpected snippets (-want +got):\n%s", diff)
+		}
+	}
+}
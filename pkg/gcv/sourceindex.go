@@ -0,0 +1,73 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+
+	"github.com/forseti-security/config-validator/pkg/asset"
+)
+
+// SourceIndex maps violation fingerprints back to the export record of the asset that produced
+// them, so a finding can be traced to the raw input for debugging.
+type SourceIndex struct {
+	sources map[string]asset.Source
+}
+
+// NewSourceIndex returns an index over the violations of all results that have a Source.
+func NewSourceIndex(results []*Result) *SourceIndex {
+	idx := &SourceIndex{sources: map[string]asset.Source{}}
+	for _, result := range results {
+		idx.Add(result)
+	}
+	return idx
+}
+
+// Add indexes the violations of result, it is a no-op if the result has no Source.
+func (s *SourceIndex) Add(result *Result) {
+	if result.Source == nil {
+		return
+	}
+	for idx := range result.ConstraintViolations {
+		s.sources[result.ConstraintViolations[idx].Fingerprint(result.Name)] = *result.Source
+	}
+}
+
+// Lookup returns the source of the asset that produced the violation with the given fingerprint.
+func (s *SourceIndex) Lookup(fingerprint string) (asset.Source, bool) {
+	source, found := s.sources[fingerprint]
+	return source, found
+}
+
+// Len returns the number of indexed violations.
+func (s *SourceIndex) Len() int {
+	return len(s.sources)
+}
+
+// ReviewRecord reviews an asset read from an export and records its source on the result.
+func (v *Validator) ReviewRecord(ctx context.Context, record *asset.Record) (*Result, error) {
+	source := record.Source
+	result, err := v.ReviewUnmarshalledJSON(ctx, record.Asset)
+	if e, ok := AsError(err); ok {
+		if verr, ok := e.Err.(*asset.ValidationError); ok {
+			verr.Source = &source
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	result.Source = &source
+	return result, nil
+}
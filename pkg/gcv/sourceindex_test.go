@@ -0,0 +1,118 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/forseti-security/config-validator/pkg/asset"
+	"github.com/pkg/errors"
+)
+
+// newExport returns the assets as a newline delimited export.
+func newExport(t *testing.T, assetJSONs ...string) *bytes.Buffer {
+	var export bytes.Buffer
+	for _, assetJSON := range assetJSONs {
+		if err := json.Compact(&export, []byte(assetJSON)); err != nil {
+			t.Fatal("unexpected error", err)
+		}
+		export.WriteString("\n")
+	}
+	return &export
+}
+
+func TestSourceIndex(t *testing.T) {
+	v, err := NewValidator(append(testOptions(), WithResultCache(16))...)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	ctx := context.Background()
+
+	// Reviewing the same bucket twice exercises the cached path, which must not reuse the source
+	// of the first record.
+	export := newExport(t, storageAssetWithLoggingJSON, storageAssetNoLoggingJSON, storageAssetNoLoggingJSON)
+	reader := asset.NewReader(export, "export.json")
+	var results []*Result
+	for {
+		record, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal("unexpected error", err)
+		}
+		result, err := v.ReviewRecord(ctx, record)
+		if err != nil {
+			t.Fatal("unexpected error", err)
+		}
+		if result.Source == nil || *result.Source != record.Source {
+			t.Errorf("got source %v, want %v", result.Source, record.Source)
+		}
+		results = append(results, result)
+	}
+	if len(results) != 3 {
+		t.Fatalf("got %d results, want 3", len(results))
+	}
+
+	idx := NewSourceIndex(results[:2])
+	wantLen := len(results[0].ConstraintViolations) + len(results[1].ConstraintViolations)
+	if idx.Len() != wantLen {
+		t.Fatalf("got %d indexed violations, want %d", idx.Len(), wantLen)
+	}
+	violations, err := results[1].ToViolations()
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if len(violations) == 0 {
+		t.Fatalf("expected violations for bucket without logging")
+	}
+	for _, violation := range violations {
+		source, found := idx.Lookup(ViolationFingerprint(violation))
+		if !found {
+			t.Fatalf("violation %v not found in index", violation)
+		}
+		if source != *results[1].Source || source.Line != 2 {
+			t.Errorf("got source %v, want line 2 (%v)", source, *results[1].Source)
+		}
+	}
+	if _, found := idx.Lookup("unknown"); found {
+		t.Errorf("unexpected lookup result for unknown fingerprint")
+	}
+
+	if NewSourceIndex([]*Result{{ConstraintViolations: results[1].ConstraintViolations}}).Len() != 0 {
+		t.Errorf("results without a source should not be indexed")
+	}
+}
+
+func TestReviewRecordInvalidAsset(t *testing.T) {
+	v, err := NewValidator(testOptions()...)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	record := &asset.Record{
+		Asset:  map[string]interface{}{"asset_type": "storage.googleapis.com/Bucket", "ancestry_path": "organizations/1"},
+		Source: asset.Source{File: "assets.json", Line: 3},
+	}
+	_, err = v.ReviewRecord(context.Background(), record)
+	want := "invalid asset with no name of type storage.googleapis.com/Bucket at assets.json:3: name: missing; " +
+		"resource: missing all of these: resource, iam_policy, org_policy, access_policy, access_level, service_perimeter"
+	if errors.Cause(err) != ErrInvalidAsset || err.Error() != want {
+		t.Errorf("got error %v, want %s", err, want)
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/forseti-security/config-validator/pkg/gcptarget"
+	"github.com/forseti-security/config-validator/pkg/gcv/configs"
+	"github.com/forseti-security/config-validator/pkg/k8sunwrap"
+	cfclient "github.com/open-policy-agent/frameworks/constraint/pkg/client"
+	"github.com/pkg/errors"
+)
+
+var (
+	targetsMu sync.RWMutex
+	// targets holds the constructors of the targets registered with RegisterTarget by name.
+	targets = map[string]func() cfclient.TargetHandler{}
+)
+
+// RegisterTarget registers a Constraint Framework target with the policy loader and with the
+// review path of validators created afterwards, so that templates can be written for inventories
+// other than CAI, such as those of other clouds or of an internal CMDB. newTarget returns a new
+// handler of the target, each client of the target gets its own.
+//
+// Assets the target handles, as told by its HandleReview, are reviewed against the constraints of
+// its templates only. They must have a name, and are reviewed as given: ancestry resolution, the
+// GCP constraint scopes and ReviewInventory do not apply to them. Targets are asked in the order
+// of their names, before the Kubernetes and GCP targets, so they must not handle CAI assets.
+//
+// RegisterTarget is meant to be called from an init function. It panics if a target of the same
+// name is registered, which includes the GCP and Kubernetes targets.
+func RegisterTarget(newTarget func() cfclient.TargetHandler) {
+	name := newTarget().GetName()
+	targetsMu.Lock()
+	defer targetsMu.Unlock()
+	if _, found := targets[name]; found || name == gcptarget.Name || name == configs.K8STargetName {
+		panic(fmt.Sprintf("target %s is already registered", name))
+	}
+	targets[name] = newTarget
+	configs.RegisterTarget(name)
+}
+
+// registeredTarget is a target registered with RegisterTarget, as set up for a validator.
+type registeredTarget struct {
+	name string
+	// handler tells which assets the target handles.
+	handler cfclient.TargetHandler
+	client  *clientPool
+}
+
+// newRegisteredTargets returns the targets registered with RegisterTarget in the order of their
+// names, with clients of size loaded with the templates and constraints of config.
+func newRegisteredTargets(size int, config *configs.Configuration) ([]*registeredTarget, error) {
+	targetsMu.RLock()
+	defer targetsMu.RUnlock()
+	var names []string
+	for name := range targets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	registered := make([]*registeredTarget, len(names))
+	for idx, name := range names {
+		client, err := newClientPool(size, targets[name], config.TargetTemplates[name], config.TargetConstraints[name])
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to set up %s Constraint Framework client", name)
+		}
+		registered[idx] = &registeredTarget{name: name, handler: targets[name](), client: client}
+	}
+	return registered, nil
+}
+
+// targetOf returns the name of the target reviewing asset: the first registered target handling
+// it, otherwise the Kubernetes target for Kubernetes resources and the GCP target for all other
+// assets.
+func (v *Validator) targetOf(asset map[string]interface{}) (string, error) {
+	for _, target := range v.targets {
+		handled, _, err := target.handler.HandleReview(asset)
+		if err != nil {
+			return "", classify(ErrInvalidAsset, errors.Wrapf(err, "target %s", target.name))
+		}
+		if handled {
+			return target.name, nil
+		}
+	}
+	if k8sunwrap.IsK8S(asset) {
+		return configs.K8STargetName, nil
+	}
+	return gcptarget.Name, nil
+}
+
+// isRegisteredTarget returns true if target is not one of the GCP and Kubernetes targets.
+func isRegisteredTarget(target string) bool {
+	return target != gcptarget.Name && target != configs.K8STargetName
+}
+
+// reviewRegisteredTarget reviews asset with the registered target named name.
+func (v *Validator) reviewRegisteredTarget(ctx context.Context, name string, asset map[string]interface{}) (*Result, error) {
+	var target *registeredTarget
+	for _, t := range v.targets {
+		if t.name == name {
+			target = t
+		}
+	}
+	if target == nil {
+		return nil, errors.Errorf("target %s is not registered", name)
+	}
+	responses, err := target.client.Review(ctx, asset)
+	if err != nil {
+		return nil, errors.Wrapf(err, "%s target Constraint Framework review call failed", target.name)
+	}
+	return NewResult(target.name, asset, asset, responses)
+}
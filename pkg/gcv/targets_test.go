@@ -0,0 +1,210 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"text/template"
+
+	"github.com/forseti-security/config-validator/pkg/gcptarget"
+	"github.com/google/go-cmp/cmp"
+	cfclient "github.com/open-policy-agent/frameworks/constraint/pkg/client"
+	cftypes "github.com/open-policy-agent/frameworks/constraint/pkg/types"
+	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const inventoryTargetName = "validation.inventory.example.com"
+
+// inventoryTarget is a target for the buckets of an inventory other than CAI, identified by
+// their arn.
+type inventoryTarget struct{}
+
+var _ cfclient.TargetHandler = inventoryTarget{}
+
+func (inventoryTarget) MatchSchema() apiextensions.JSONSchemaProps {
+	return apiextensions.JSONSchemaProps{}
+}
+
+func (inventoryTarget) GetName() string {
+	return inventoryTargetName
+}
+
+var inventoryLibrary = template.Must(template.New("target").Parse(`package target
+
+matching_constraints[constraint] {
+	constraint := {{.ConstraintsRoot}}[_][_]
+}
+
+matching_reviews_and_constraints[[review, constraint]] {
+	review := {"msg": "unsupported operation"}
+	constraint := {
+		"msg": "unsupported operation",
+		"kind": "invalid",
+	}
+}
+
+autoreject_review[rejection] {
+	false
+	rejection := {
+		"msg": "should not reach this",
+	}
+}
+`))
+
+func (inventoryTarget) Library() *template.Template {
+	return inventoryLibrary
+}
+
+func (inventoryTarget) ProcessData(obj interface{}) (bool, string, interface{}, error) {
+	return false, "", nil, nil
+}
+
+func (inventoryTarget) HandleReview(obj interface{}) (bool, interface{}, error) {
+	asset, ok := obj.(map[string]interface{})
+	if !ok {
+		return false, nil, nil
+	}
+	_, found, err := unstructured.NestedString(asset, "arn")
+	if !found || err != nil {
+		return false, nil, err
+	}
+	return true, asset, nil
+}
+
+func (inventoryTarget) HandleViolation(result *cftypes.Result) error {
+	return nil
+}
+
+func (inventoryTarget) ValidateConstraint(constraint *unstructured.Unstructured) error {
+	return nil
+}
+
+func init() {
+	RegisterTarget(func() cfclient.TargetHandler { return inventoryTarget{} })
+}
+
+const inventoryTemplate = `apiVersion: templates.gatekeeper.sh/v1beta1
+kind: ConstraintTemplate
+metadata:
+  name: inventorybucketencryption
+spec:
+  crd:
+    spec:
+      names:
+        kind: InventoryBucketEncryption
+  targets:
+    - target: validation.inventory.example.com
+      rego: |
+        package inventorybucketencryption
+
+        violation[{"msg": msg}] {
+          not input.review.encrypted
+          msg := sprintf("%v is not encrypted", [input.review.arn])
+        }
+`
+
+const inventoryConstraint = `apiVersion: constraints.gatekeeper.sh/v1beta1
+kind: InventoryBucketEncryption
+metadata:
+  name: encrypted-buckets
+spec: {}
+`
+
+func TestRegisterTarget(t *testing.T) {
+	policyDir, err := ioutil.TempDir("", "RegisterTargetTest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(policyDir)
+	files := map[string]string{"template.yaml": inventoryTemplate, "constraint.yaml": inventoryConstraint}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(policyDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	v, err := NewValidator(WithPolicyPaths(policyDir), WithPolicyLibrary(localPolicyDepDir))
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	var constraints []string
+	for _, constraint := range v.Constraints() {
+		constraints = append(constraints, ConstraintName(constraint))
+	}
+	if diff := cmp.Diff([]string{"InventoryBucketEncryption.encrypted-buckets"}, constraints); diff != "" {
+		t.Errorf("unexpected constraints (-want +got):\n%s", diff)
+	}
+
+	for _, tc := range []struct {
+		name  string
+		asset string
+		want  []string
+	}{
+		{
+			name:  "violation",
+			asset: `{"name": "arn:aws:s3:::my-bucket", "arn": "arn:aws:s3:::my-bucket", "encrypted": false}`,
+			want:  []string{"arn:aws:s3:::my-bucket is not encrypted"},
+		},
+		{
+			name:  "no violation",
+			asset: `{"name": "arn:aws:s3:::my-bucket", "arn": "arn:aws:s3:::my-bucket", "encrypted": true}`,
+		},
+		{
+			// CAI assets are left to the GCP target, which has no constraints.
+			name:  "gcp asset",
+			asset: storageAssetNoLoggingJSON,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := v.ReviewJSON(context.Background(), tc.asset)
+			if err != nil {
+				t.Fatal("unexpected error", err)
+			}
+			var got []string
+			for _, cv := range result.ConstraintViolations {
+				got = append(got, cv.Message)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected violations (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	// Assets of registered targets do not take part in inventory reviews.
+	_, err = v.ReviewInventory(context.Background(), []map[string]interface{}{{"name": "arn:aws:s3:::my-bucket", "arn": "arn:aws:s3:::my-bucket"}})
+	if err == nil {
+		t.Error("expected error reviewing inventory with assets of registered targets")
+	}
+}
+
+func TestRegisterTargetTwice(t *testing.T) {
+	for _, newTarget := range []func() cfclient.TargetHandler{
+		func() cfclient.TargetHandler { return inventoryTarget{} },
+		func() cfclient.TargetHandler { return gcptarget.New() },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected panic registering %s", newTarget().GetName())
+				}
+			}()
+			RegisterTarget(newTarget)
+		}()
+	}
+}
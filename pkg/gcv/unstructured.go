@@ -0,0 +1,56 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+
+	"github.com/forseti-security/config-validator/pkg/k8sunwrap"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// ReviewUnstructured reviews u, a CAI asset in the form of CAI exports, such as decoded by
+// programs handling objects of any schema. u is not modified. Kubernetes objects are reviewed
+// with ReviewK8SUnstructured.
+func (v *Validator) ReviewUnstructured(ctx context.Context, u *unstructured.Unstructured) (*Result, error) {
+	if _, found := u.Object["asset_type"]; !found && u.GetKind() != "" {
+		return nil, classify(ErrInvalidAsset, errors.Errorf(
+			"%s %s is a Kubernetes object, not a CAI asset, review it with ReviewK8SUnstructured", u.GetKind(), u.GetName()))
+	}
+	return v.ReviewUnmarshalledJSON(ctx, runtime.DeepCopyJSON(u.Object))
+}
+
+// ReviewK8SUnstructured reviews u, a Kubernetes object such as held by a controller, as the CAI
+// asset of the object in cluster, the CAI name of a GKE cluster such as
+// //container.googleapis.com/projects/p/locations/us-central1/clusters/c, with the given
+// ancestors of the cluster, from its project up. The ancestors may be nil if the validator was set
+// up WithAncestryResolver. u is not modified.
+func (v *Validator) ReviewK8SUnstructured(ctx context.Context, cluster string, u *unstructured.Unstructured, ancestors []string) (*Result, error) {
+	name := k8sunwrap.AssetName(cluster, u.GroupVersionKind(), u.GetNamespace(), u.GetName())
+	asset, err := k8sunwrap.Wrap(name, u, ancestors)
+	if err != nil {
+		return nil, classify(ErrInvalidAsset, err)
+	}
+	if !k8sunwrap.IsK8S(asset) {
+		return nil, classify(ErrInvalidAsset, errors.Errorf("%s is not the CAI name of a GKE cluster", cluster))
+	}
+	// Resolvers look up the ancestors of assets without ancestry from the parent cluster.
+	if err := unstructured.SetNestedField(asset, cluster, "resource", "parent"); err != nil {
+		return nil, err
+	}
+	return v.ReviewUnmarshalledJSON(ctx, asset)
+}
@@ -0,0 +1,134 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcv
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const testCluster = "//container.googleapis.com/projects/malaise-forever/zones/us-central1-a/clusters/test-1"
+
+func TestReviewUnstructured(t *testing.T) {
+	v, err := NewValidator(testOptions()...)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	ctx := context.Background()
+	want, err := v.ReviewJSON(ctx, storageAssetNoLoggingJSON)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	u := &unstructured.Unstructured{}
+	if err := json.Unmarshal([]byte(storageAssetNoLoggingJSON), &u.Object); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	original := u.DeepCopy()
+	got, err := v.ReviewUnstructured(ctx, u)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if got.Name != want.Name || len(got.ConstraintViolations) != len(want.ConstraintViolations) {
+		t.Errorf("got %d violations of %s, want %d of %s", len(got.ConstraintViolations), got.Name, len(want.ConstraintViolations), want.Name)
+	}
+	if diff := cmp.Diff(original.Object, u.Object); diff != "" {
+		t.Errorf("asset was modified (-want +got):\n%s", diff)
+	}
+
+	namespace := &unstructured.Unstructured{}
+	namespace.SetAPIVersion("v1")
+	namespace.SetKind("Namespace")
+	namespace.SetName("whatever")
+	if _, err := v.ReviewUnstructured(ctx, namespace); errors.Cause(err) != ErrInvalidAsset {
+		t.Errorf("got error %v reviewing a Kubernetes object, want invalid asset", err)
+	}
+}
+
+func TestReviewK8SUnstructured(t *testing.T) {
+	v, err := NewValidator(testOptions()...)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	ctx := context.Background()
+	namespace := func(labels map[string]string) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{}
+		u.SetAPIVersion("v1")
+		u.SetKind("Namespace")
+		u.SetName("whatever")
+		u.SetLabels(labels)
+		return u
+	}
+	ancestors := []string{"projects/1234567890", "organizations/1234567899"}
+
+	unlabeled := namespace(nil)
+	original := unlabeled.DeepCopy()
+	result, err := v.ReviewK8SUnstructured(ctx, testCluster, unlabeled, ancestors)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if result.Name != testCluster+"/k8s/namespaces/whatever" || len(result.ConstraintViolations) != 1 {
+		t.Errorf("got %d violations of %s, want 1 of the namespace", len(result.ConstraintViolations), result.Name)
+	}
+	if path, _, _ := unstructured.NestedString(result.CAIResource, ancestryPathKey); path != "organizations/1234567899/projects/1234567890" {
+		t.Errorf("got ancestry path %q", path)
+	}
+	if diff := cmp.Diff(original.Object, unlabeled.Object); diff != "" {
+		t.Errorf("object was modified (-want +got):\n%s", diff)
+	}
+
+	result, err = v.ReviewK8SUnstructured(ctx, testCluster, namespace(map[string]string{"cost-center": "eng"}), ancestors)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if len(result.ConstraintViolations) != 0 {
+		t.Errorf("got violations %v of labeled namespace, want none", result.ConstraintViolations)
+	}
+
+	if _, err := v.ReviewK8SUnstructured(ctx, "//compute.googleapis.com/projects/p", unlabeled, ancestors); errors.Cause(err) != ErrInvalidAsset {
+		t.Errorf("got error %v reviewing in a cluster that is not GKE, want invalid asset", err)
+	}
+}
+
+func TestReviewK8SUnstructuredResolvesAncestry(t *testing.T) {
+	var got []string
+	resolver := ancestryFunc(func(ctx context.Context, name, parent string) ([]string, error) {
+		got = append(got, name, parent)
+		return []string{"projects/1234567890", "organizations/1234567899"}, nil
+	})
+	v, err := NewValidator(append(testOptions(), WithAncestryResolver(resolver))...)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	namespace := &unstructured.Unstructured{}
+	namespace.SetAPIVersion("v1")
+	namespace.SetKind("Namespace")
+	namespace.SetName("whatever")
+	result, err := v.ReviewK8SUnstructured(context.Background(), testCluster, namespace, nil)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if diff := cmp.Diff([]string{testCluster + "/k8s/namespaces/whatever", testCluster}, got); diff != "" {
+		t.Errorf("unexpected resolver calls (-want +got):\n%s", diff)
+	}
+	if path, _, _ := unstructured.NestedString(result.CAIResource, ancestryPathKey); path != "organizations/1234567899/projects/1234567890" {
+		t.Errorf("got ancestry path %q", path)
+	}
+}
@@ -17,24 +17,37 @@ package gcv
 
 import (
 	"context"
-	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
 
 	"github.com/forseti-security/config-validator/pkg/api/validator"
 	asset2 "github.com/forseti-security/config-validator/pkg/asset"
+	"github.com/forseti-security/config-validator/pkg/externaldata"
 	"github.com/forseti-security/config-validator/pkg/gcptarget"
 	"github.com/forseti-security/config-validator/pkg/gcv/configs"
+	"github.com/forseti-security/config-validator/pkg/k8sunwrap"
+	"github.com/forseti-security/config-validator/pkg/logging"
 	"github.com/forseti-security/config-validator/pkg/multierror"
-	"github.com/golang/glog"
+	"github.com/forseti-security/config-validator/pkg/redact"
+	"github.com/forseti-security/config-validator/pkg/transform"
+	"github.com/golang/protobuf/proto"
 	cfclient "github.com/open-policy-agent/frameworks/constraint/pkg/client"
 	"github.com/open-policy-agent/frameworks/constraint/pkg/client/drivers/local"
 	cftemplates "github.com/open-policy-agent/frameworks/constraint/pkg/core/templates"
+	cftypes "github.com/open-policy-agent/frameworks/constraint/pkg/types"
 	k8starget "github.com/open-policy-agent/gatekeeper/pkg/target"
 	"github.com/pkg/errors"
+	"go.uber.org/zap"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
+// semverPattern matches semantic versions as defined by https://semver.org.
+var semverPattern = regexp.MustCompile(`^(0|[1-9]\d*)\.(0|[1-9]\d*)\.(0|[1-9]\d*)` +
+	`(-[0-9A-Za-z-]+(\.[0-9A-Za-z-]+)*)?(\+[0-9A-Za-z-]+(\.[0-9A-Za-z-]+)*)?$`)
+
 const (
-	logRequestsVerboseLevel = 2
 	// The JSON object key for ancestry path
 	ancestryPathKey = "ancestry_path"
 	// The JSON object key for ancestors list
@@ -65,8 +78,198 @@ type Validator struct {
 	// These rego dependencies should be packaged with the GCV deployment.
 	// Right now expected to be set to point to "//policies/validator/lib" folder
 	policyLibraryDir string
-	gcpCFClient      *cfclient.Client
-	k8sCFClient      *cfclient.Client
+	gcpCFClient      *clientPool
+	k8sCFClient      *clientPool
+	// targets are the targets registered with RegisterTarget when the validator was created.
+	targets []*registeredTarget
+	// clientPoolSize is the number of Constraint Framework clients of each target.
+	clientPoolSize int
+	// gcpScopes holds the ancestry scope of each GCP constraint by scopeKey.
+	gcpScopes map[string]*gcptarget.Scope
+	// config holds the loaded templates and constraints, used to load single constraints by Explain.
+	config *configs.Configuration
+	// policyVersion is the content hash of the loaded templates and constraints.
+	policyVersion string
+	// declaredVersion optionally overrides policyVersion on review outputs.
+	declaredVersion string
+	// bundles optionally restricts the constraints of config to those of the bundles.
+	bundles []string
+	// cacheSize is the number of results to keep in cache, zero disables caching.
+	cacheSize int
+	cache     *resultCache
+	// transformer optionally rewrites assets before they are reviewed.
+	transformer *transform.Transformer
+	// enricher optionally adds data to assets before they are transformed and reviewed.
+	enricher Enricher
+	// ancestry optionally resolves the ancestors of assets without ancestry information.
+	ancestry AncestryResolver
+	// providers are the data providers templates can call with external_data.
+	providers externaldata.Registry
+	// remediator optionally renders remediation snippets for violations.
+	remediator Remediator
+	// quarantine optionally records assets whose review panicked.
+	quarantine *Quarantine
+	// reportSkipped returns skipped results for assets no target handles instead of failing.
+	reportSkipped bool
+	// multiTarget reviews the Kubernetes assets of CAI with the GCP target as well.
+	multiTarget bool
+	// withoutResourceBodies keeps only the identity of the reviewed asset in results.
+	withoutResourceBodies bool
+	// redactor optionally replaces sensitive fields of results.
+	redactor *redact.Redactor
+	// inventoryMu is held exclusively by ReviewInventory while data.inventory is populated.
+	inventoryMu sync.RWMutex
+}
+
+// Enricher adds data that is not part of CAI exports to assets before they are reviewed, such
+// as the effective members of groups in IAM policies.
+type Enricher interface {
+	// Enrich returns the asset to review. It must not modify asset.
+	Enrich(ctx context.Context, asset map[string]interface{}) (map[string]interface{}, error)
+}
+
+// AncestryResolver looks up the ancestors of assets that arrive without ancestry information,
+// such as the assets of feed events.
+type AncestryResolver interface {
+	// Ancestors returns the ancestors of the asset with the given name and resource parent, which
+	// may be empty, in the order of the ancestors field of CAI exports. It returns nil if the
+	// ancestors cannot be told.
+	Ancestors(ctx context.Context, name, parent string) ([]string, error)
+}
+
+// Option configures optional Validator behavior.
+type Option func(*Validator)
+
+// WithPolicyPaths adds paths to load constraints and constraint templates from. Each path is a
+// local directory or file, a gs:// URL of a Cloud Storage prefix or object, a PolicySet
+// manifest or a built in policy set such as builtin:cis. At least one path is required
+// by NewValidator.
+func WithPolicyPaths(paths ...string) Option {
+	return func(v *Validator) {
+		v.policyPaths = append(v.policyPaths, paths...)
+	}
+}
+
+// WithPolicyLibrary sets the local directory or gs:// URL of the rego library the templates
+// depend on. It is required unless the policy paths are only built in policy sets, or include a PolicySet manifest declaring its library.
+func WithPolicyLibrary(path string) Option {
+	return func(v *Validator) {
+		v.policyLibraryDir = path
+	}
+}
+
+// WithResultCache enables an LRU cache holding up to size review results. Results are keyed by
+// the asset content and the policy set, so reviewing an unchanged asset again skips constraint
+// evaluation entirely. Cached results share violation data with earlier results and must not be
+// modified by callers.
+func WithResultCache(size int) Option {
+	return func(v *Validator) {
+		v.cacheSize = size
+	}
+}
+
+// WithTransformer applies the transforms of t to each asset before it is reviewed. Results refer
+// to the asset as provided in CAIResource and to the transformed asset in ReviewResource.
+func WithTransformer(t *transform.Transformer) Option {
+	return func(v *Validator) {
+		v.transformer = t
+	}
+}
+
+// WithEnricher applies e to each asset before transforms are applied and the asset is reviewed.
+// With a result cache, enriched data is only refreshed when the asset itself changes.
+func WithEnricher(e Enricher) Option {
+	return func(v *Validator) {
+		v.enricher = e
+	}
+}
+
+// WithAncestryResolver sets the ancestors of assets that have neither ancestors nor an ancestry
+// path to those resolved by r, before the assets are validated. Assets whose ancestors cannot be
+// resolved are invalid as before.
+func WithAncestryResolver(r AncestryResolver) Option {
+	return func(v *Validator) {
+		v.ancestry = r
+	}
+}
+
+// WithDataProvider registers p as the data provider name, which templates call with
+// external_data. Values are cached for the duration of a review. With a result cache, provider
+// data is only refreshed when the asset itself changes.
+func WithDataProvider(name string, p externaldata.Provider) Option {
+	return func(v *Validator) {
+		if v.providers == nil {
+			v.providers = externaldata.Registry{}
+		}
+		v.providers[name] = p
+	}
+}
+
+// WithPolicyVersion declares the semantic version of the policy set, such as 1.4.0, which is
+// stamped on review outputs in place of the content hash of the templates and constraints.
+func WithPolicyVersion(version string) Option {
+	return func(v *Validator) {
+		v.declaredVersion = version
+	}
+}
+
+// WithSkippedAssets reports assets that no target handles, such as assets with a kind of content
+// added to CAI exports after the targets were written, as results with Skipped set. Without it,
+// reviewing such an asset fails.
+func WithSkippedAssets() Option {
+	return func(v *Validator) {
+		v.reportSkipped = true
+	}
+}
+
+// WithMultiTargetReview reviews each asset against the constraints of all targets handling it,
+// and merges their violations into one Result. The Kubernetes resources of CAI exports are then
+// reviewed both as Kubernetes objects, against the Gatekeeper constraints, and as CAI assets,
+// against the GCP constraints. Without it, Kubernetes resources are only reviewed against the
+// Gatekeeper constraints.
+func WithMultiTargetReview() Option {
+	return func(v *Validator) {
+		v.multiTarget = true
+	}
+}
+
+// WithoutResourceBodies keeps only the name, asset type and ancestry path of the reviewed asset in
+// the CAIResource of results, and leaves ReviewResource unset. Violations, reports and sinks only
+// need the identity of the asset, while whole resources per result bloat the memory of large
+// reviews and the result cache, and can leak sensitive data into insights and other downstream
+// systems. Remediation snippets are rendered before the resource is dropped.
+func WithoutResourceBodies() Option {
+	return func(v *Validator) {
+		v.withoutResourceBodies = true
+	}
+}
+
+// WithRedactor replaces the fields of results matching the patterns of r. Patterns are applied to
+// CAIResource, ReviewResource and the metadata of each violation, so they also apply to the
+// violations and insights made from results. Fields are redacted before remediation snippets are
+// rendered.
+func WithRedactor(r *redact.Redactor) Option {
+	return func(v *Validator) {
+		v.redactor = r
+	}
+}
+
+// WithClientPool sets up size Constraint Framework clients per target instead of one, and
+// spreads reviews across them round-robin. This relieves the lock contention of a single client
+// under many concurrent reviews, at the cost of compiling the templates size times.
+func WithClientPool(size int) Option {
+	return func(v *Validator) {
+		v.clientPoolSize = size
+	}
+}
+
+// WithBundles loads only the constraints belonging to at least one of bundles, given by name
+// such as cis-v1.1 or as bundle annotation, see configs.SelectBundles. The policy version is the
+// content hash of the selected constraints. Without bundles all constraints are loaded.
+func WithBundles(bundles ...string) Option {
+	return func(v *Validator) {
+		v.bundles = append(v.bundles, bundles...)
+	}
 }
 
 // NewValidatorConfig returns a new ValidatorConfig.
@@ -74,15 +277,29 @@ type Validator struct {
 // We may want to make this initialization behavior configurable in the future.
 func NewValidatorConfig(policyPaths []string, policyLibraryPath string) (*configs.Configuration, error) {
 	if len(policyPaths) == 0 {
-		return nil, errors.Errorf("No policy path set, provide an option to set the policy path gcv.PolicyPath")
+		return nil, errors.Errorf("No policy path set, provide an option to set the policy path gcv.WithPolicyPaths")
 	}
-	if policyLibraryPath == "" {
+	// Built in policy sets need no library and PolicySet manifests may declare it.
+	if policyLibraryPath == "" && needsLibrary(policyPaths) {
 		return nil, errors.Errorf("No policy library set")
 	}
-	glog.V(logRequestsVerboseLevel).Infof("loading policy dir: %v lib dir: %s", policyPaths, policyLibraryPath)
+	zap.L().Debug("loading policies", zap.Strings("policy_paths", policyPaths), zap.String("library_path", policyLibraryPath))
 	return configs.NewConfiguration(policyPaths, policyLibraryPath)
 }
 
+// needsLibrary returns true unless policyPaths name only built in policy sets, or include a
+// PolicySet manifest, which may declare its library.
+func needsLibrary(policyPaths []string) bool {
+	builtin := true
+	for _, path := range policyPaths {
+		if configs.IsPolicySet(path) {
+			return false
+		}
+		builtin = builtin && configs.IsBuiltin(path)
+	}
+	return !builtin
+}
+
 func newCFClient(
 	targetHandler cfclient.TargetHandler,
 	templates []*cftemplates.ConstraintTemplate,
@@ -106,7 +323,7 @@ func newCFClient(
 		}
 	}
 	if !errs.Empty() {
-		return nil, errs.ToError()
+		return nil, classify(ErrPolicyCompile, errs.ToError())
 	}
 
 	for _, constraint := range constraints {
@@ -115,54 +332,193 @@ func newCFClient(
 		}
 	}
 	if !errs.Empty() {
-		return nil, errs.ToError()
+		return nil, classify(ErrPolicyCompile, errs.ToError())
 	}
 	return cfClient, nil
 }
 
-// NewValidatorFromConfig creates the validator from a config.
-func NewValidatorFromConfig(config *configs.Configuration) (*Validator, error) {
-	gcpCFClient, err := newCFClient(gcptarget.New(), config.GCPTemplates, config.GCPConstraints)
+func newGCPTarget() cfclient.TargetHandler {
+	return gcptarget.New()
+}
+
+func newK8STarget() cfclient.TargetHandler {
+	return &k8starget.K8sValidationTarget{}
+}
+
+// scopeKey identifies a constraint within its scopes map.
+func scopeKey(constraint *unstructured.Unstructured) string {
+	return constraint.GetKind() + "/" + constraint.GetName()
+}
+
+// newScopes returns the ancestry scopes of the GCP constraints by scopeKey.
+func newScopes(constraints []*unstructured.Unstructured) (map[string]*gcptarget.Scope, error) {
+	scopes := make(map[string]*gcptarget.Scope, len(constraints))
+	var errs multierror.Errors
+	for _, constraint := range constraints {
+		scope, err := gcptarget.NewScope(constraint)
+		if err != nil {
+			errs.Add(errors.Wrapf(err, "invalid scope of constraint %s", ConstraintName(constraint)))
+			continue
+		}
+		scopes[scopeKey(constraint)] = scope
+	}
+	if !errs.Empty() {
+		return nil, classify(ErrPolicyCompile, errs.ToError())
+	}
+	return scopes, nil
+}
+
+// NewValidatorFromConfig creates the validator from a config. The policy paths and library of
+// opts are ignored.
+func NewValidatorFromConfig(config *configs.Configuration, opts ...Option) (*Validator, error) {
+	return newValidator(opts).load(config)
+}
+
+// newValidator returns a Validator configured by opts, without policies.
+func newValidator(opts []Option) *Validator {
+	v := &Validator{}
+	for _, opt := range opts {
+		opt(v)
+	}
+	return v
+}
+
+// load sets up the Constraint Framework clients of v for the templates and constraints of
+// config.
+func (v *Validator) load(config *configs.Configuration) (*Validator, error) {
+	if len(v.bundles) != 0 {
+		selected, err := config.SelectBundles(v.bundles)
+		if err != nil {
+			return nil, err
+		}
+		config = selected
+	}
+	policyVersion, err := config.ContentHash()
 	if err != nil {
-		return nil, errors.Wrap(err, "unable to set up GCP Constraint Framework client")
+		return nil, err
+	}
+	v.config = config
+	v.policyVersion = policyVersion
+	if v.declaredVersion != "" && !semverPattern.MatchString(v.declaredVersion) {
+		return nil, errors.Errorf("policy version %q is not a semantic version", v.declaredVersion)
 	}
 
-	k8sCFClient, err := newCFClient(&k8starget.K8sValidationTarget{}, config.K8STemplates, config.K8SConstraints)
+	v.gcpCFClient, err = newClientPool(v.clientPoolSize, newGCPTarget, config.GCPTemplates, config.GCPConstraints)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to set up GCP Constraint Framework client")
+	}
+	if v.gcpScopes, err = newScopes(config.GCPConstraints); err != nil {
+		return nil, err
+	}
+	v.k8sCFClient, err = newClientPool(v.clientPoolSize, newK8STarget, config.K8STemplates, config.K8SConstraints)
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to set up K8S Constraint Framework client")
 	}
-
-	ret := &Validator{
-		gcpCFClient: gcpCFClient,
-		k8sCFClient: k8sCFClient,
+	if v.targets, err = newRegisteredTargets(v.clientPoolSize, config); err != nil {
+		return nil, err
 	}
-	return ret, nil
+	if v.cacheSize > 0 {
+		v.cache = newResultCache(v.cacheSize)
+	}
+	return v, nil
 }
 
 // NewValidator returns a new Validator.
 // By default it will initialize the underlying query evaluation engine by loading supporting library, constraints, and constraint templates.
-// We may want to make this initialization behavior configurable in the future.
-func NewValidator(policyPaths []string, policyLibraryPath string) (*Validator, error) {
-	config, err := NewValidatorConfig(policyPaths, policyLibraryPath)
+// The policies are set with WithPolicyPaths and WithPolicyLibrary.
+func NewValidator(opts ...Option) (*Validator, error) {
+	v := newValidator(opts)
+	config, err := NewValidatorConfig(v.policyPaths, v.policyLibraryDir)
 	if err != nil {
 		return nil, err
 	}
-	return NewValidatorFromConfig(config)
+	return v.load(config)
+}
+
+// NewValidatorFromPaths returns a new Validator loading the policies from policyPaths and
+// policyLibraryPath.
+//
+// Deprecated: use NewValidator with WithPolicyPaths and WithPolicyLibrary.
+func NewValidatorFromPaths(policyPaths []string, policyLibraryPath string, opts ...Option) (*Validator, error) {
+	return NewValidator(append([]Option{WithPolicyPaths(policyPaths...), WithPolicyLibrary(policyLibraryPath)}, opts...)...)
 }
 
-// ReviewAsset reviews a single asset.
+// Constraints returns the loaded GCP constraints followed by the loaded Kubernetes constraints
+// and the constraints of registered targets.
+// The constraints are shared with the validator and must not be modified.
+func (v *Validator) Constraints() []*unstructured.Unstructured {
+	return v.config.AllConstraints()
+}
+
+// NewConstraintInfo returns the description of a loaded constraint, including its effective
+// parameters, with the defaults declared by its template applied on load.
+func NewConstraintInfo(constraint *unstructured.Unstructured) (*validator.ConstraintInfo, error) {
+	severity, _, _ := unstructured.NestedString(constraint.Object, "spec", "severity")
+	cv := &ConstraintViolation{Constraint: constraint}
+	parameters, err := toValue(cv.parameters())
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to convert parameters of constraint %s", ConstraintName(constraint))
+	}
+	return &validator.ConstraintInfo{
+		Name:       ConstraintName(constraint),
+		Kind:       constraint.GetKind(),
+		Severity:   severity,
+		Path:       configs.DeclaredPath(constraint),
+		Parameters: parameters,
+	}, nil
+}
+
+// PolicyVersion returns the version of the policy set used for a review, which is stamped on
+// all review outputs. This is the version declared with WithPolicyVersion, or the content hash
+// of the loaded templates and constraints if none was declared.
+func (v *Validator) PolicyVersion() string {
+	if v.declaredVersion != "" {
+		return v.declaredVersion
+	}
+	return v.policyVersion
+}
+
+// PolicyContentHash returns the content hash of the loaded templates and constraints, which
+// changes with any change to the policy set regardless of its declared version.
+func (v *Validator) PolicyContentHash() string {
+	return v.policyVersion
+}
+
+// CacheStats returns the hit and miss counters of the result cache. All counters are zero if the
+// cache is not enabled.
+func (v *Validator) CacheStats() CacheStats {
+	if v.cache == nil {
+		return CacheStats{}
+	}
+	return v.cache.stats()
+}
+
+// ReviewAsset reviews a single asset. Cancelling ctx aborts the review, including a Rego
+// evaluation that is in progress.
 func (v *Validator) ReviewAsset(ctx context.Context, asset *validator.Asset) ([]*validator.Violation, error) {
+	if v.ancestry != nil && len(asset.GetAncestors()) == 0 && asset.GetAncestryPath() == "" {
+		ancestors, err := v.lookupAncestry(ctx, asset.GetName(), asset.GetResource().GetParent())
+		if err != nil {
+			return nil, err
+		}
+		// Review a copy with the resolved ancestors, the asset of the caller is left as given.
+		asset = proto.Clone(asset).(*validator.Asset)
+		asset.Ancestors = ancestors
+	}
 	if err := asset2.ValidateAsset(asset); err != nil {
-		return nil, err
+		return nil, classify(ErrInvalidAsset, err)
 	}
 
 	if err := asset2.SanitizeAncestryPath(asset); err != nil {
-		return nil, err
+		return nil, classify(ErrInvalidAsset, err)
 	}
 
 	assetInterface, err := asset2.ConvertResourceViaJSONToInterface(asset)
 	if err != nil {
-		return nil, err
+		return nil, classify(ErrConversion, err)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, errors.Wrapf(err, "review of asset %s cancelled after conversion", asset.Name)
 	}
 
 	assetMapInterface := assetInterface.(map[string]interface{})
@@ -171,14 +527,81 @@ func (v *Validator) ReviewAsset(ctx context.Context, asset *validator.Asset) ([]
 		return nil, err
 	}
 
-	return result.ToViolations()
+	violations, err := result.ToViolations()
+	return violations, classify(ErrConversion, err)
+}
+
+// normalize validates asset and sets its normalized ancestry path. Assets without content are
+// left to the targets when skipped assets are reported.
+func (v *Validator) normalize(asset map[string]interface{}) error {
+	if err := asset2.ValidateJSON(asset, !v.reportSkipped); err != nil {
+		return classify(ErrInvalidAsset, err)
+	}
+	return classify(ErrInvalidAsset, v.fixAncestry(asset))
+}
+
+// resolveAncestry sets the ancestors of asset if it has no ancestry information and the
+// validator was set up WithAncestryResolver.
+func (v *Validator) resolveAncestry(ctx context.Context, asset map[string]interface{}) error {
+	if v.ancestry == nil {
+		return nil
+	}
+	if ancestors, _, _ := unstructured.NestedSlice(asset, ancestorSliceKey); len(ancestors) != 0 {
+		return nil
+	}
+	if path, _, _ := unstructured.NestedString(asset, ancestryPathKey); path != "" {
+		return nil
+	}
+	name, _, _ := unstructured.NestedString(asset, "name")
+	parent, _, _ := unstructured.NestedString(asset, "resource", "parent")
+	ancestors, err := v.lookupAncestry(ctx, name, parent)
+	if err != nil || len(ancestors) == 0 {
+		return err
+	}
+	ancestorsIface := make([]interface{}, len(ancestors))
+	for idx, ancestor := range ancestors {
+		ancestorsIface[idx] = ancestor
+	}
+	asset[ancestorSliceKey] = ancestorsIface
+	return nil
+}
+
+// lookupAncestry returns the ancestors of the asset name with parent from the resolver set up
+// WithAncestryResolver. If the resolver knows none, it returns no ancestors, or an
+// ErrUnknownAncestry error if constraints match assets by their ancestry.
+func (v *Validator) lookupAncestry(ctx context.Context, name, parent string) ([]string, error) {
+	ancestors, err := v.ancestry.Ancestors(ctx, name, parent)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to resolve ancestry of asset %s", name)
+	}
+	if len(ancestors) == 0 {
+		return nil, v.unknownAncestry(name)
+	}
+	return ancestors, nil
+}
+
+// unknownAncestry returns an ErrUnknownAncestry error for the asset name if any GCP constraint
+// matches assets by their ancestry. Otherwise the asset is left to fail validation as before.
+func (v *Validator) unknownAncestry(name string) error {
+	var constraints []string
+	for key, scope := range v.gcpScopes {
+		if scope.DependsOnAncestry() {
+			constraints = append(constraints, key)
+		}
+	}
+	if len(constraints) == 0 {
+		return nil
+	}
+	sort.Strings(constraints)
+	return classify(ErrUnknownAncestry, errors.Errorf("ancestry of asset %s cannot be determined, and constraints %s match on ancestry",
+		name, strings.Join(constraints, ", ")))
 }
 
 // fixAncestry will try to use the ancestors array to create the ancestorPath
 // value if it is not present.
 func (v *Validator) fixAncestry(input map[string]interface{}) error {
 	ancestors, found, err := unstructured.NestedStringSlice(input, ancestorSliceKey)
-	if found && err == nil {
+	if found && err == nil && len(ancestors) != 0 {
 		input[ancestryPathKey] = asset2.AncestryPath(ancestors)
 		return nil
 	}
@@ -191,45 +614,318 @@ func (v *Validator) fixAncestry(input map[string]interface{}) error {
 	return errors.Errorf("asset missing ancestry information: %v", input)
 }
 
-// ReviewJSON reviews the content of a JSON string
+// ReviewJSON reviews the content of a JSON string, see ReviewAssetJSON.
 func (v *Validator) ReviewJSON(ctx context.Context, data string) (*Result, error) {
+	return v.ReviewAssetJSON(ctx, []byte(data))
+}
+
+// ReviewAssetJSON reviews a single CAI asset given as JSON, such as a line of a CAI export or an
+// asset of a Cloud Asset API response. Fields may be named as in exports, such as asset_type, or
+// with their JSON names, such as assetType. Numbers keep their precision.
+func (v *Validator) ReviewAssetJSON(ctx context.Context, data []byte) (*Result, error) {
 	asset := map[string]interface{}{}
-	if err := json.Unmarshal([]byte(data), &asset); err != nil {
-		return nil, errors.Wrapf(err, "failed to unmarshal json")
+	if err := asset2.UnmarshalJSON(data, &asset); err != nil {
+		return nil, classify(ErrConversion, errors.Wrapf(err, "failed to unmarshal json"))
 	}
+	asset2.NormalizeFieldNames(asset)
 	return v.ReviewUnmarshalledJSON(ctx, asset)
 }
 
-// ReviewJSON evaluates a single asset without any threading in the background.
-func (v *Validator) ReviewUnmarshalledJSON(ctx context.Context, asset map[string]interface{}) (*Result, error) {
-	if err := v.fixAncestry(asset); err != nil {
+// ReviewJSON evaluates a single asset without any threading in the background. A panic during
+// the review is returned as a *PanicError.
+func (v *Validator) ReviewUnmarshalledJSON(ctx context.Context, asset map[string]interface{}) (result *Result, err error) {
+	defer v.recoverReview(ctx, asset, &err)
+	return v.reviewUnmarshalledJSON(ctx, asset)
+}
+
+func (v *Validator) reviewUnmarshalledJSON(ctx context.Context, asset map[string]interface{}) (*Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, errors.Wrapf(err, "review cancelled")
+	}
+	target, err := v.targetOf(asset)
+	if err != nil {
 		return nil, err
 	}
+	// Assets of registered targets are reviewed as given.
+	if !isRegisteredTarget(target) {
+		if err := v.resolveAncestry(ctx, asset); err != nil {
+			return nil, err
+		}
+		if err := v.normalize(asset); err != nil {
+			return nil, err
+		}
+	}
+	if name, ok := asset["name"].(string); ok {
+		ctx = logging.WithAsset(ctx, name)
+	}
+	// Reviews wait for a running ReviewInventory, their results must not depend on its inventory.
+	v.inventoryMu.RLock()
+	defer v.inventoryMu.RUnlock()
+	ctx = externaldata.NewContext(ctx, v.providers)
 
-	if asset2.IsK8S(asset) {
-		return v.reviewK8SResource(ctx, asset)
+	if v.cache == nil {
+		return v.review(ctx, asset, target)
+	}
+
+	key, err := cacheKey(v.policyVersion, asset)
+	if err != nil {
+		return nil, err
 	}
-	return v.reviewGCPResource(ctx, asset)
+	if cached, found := v.cache.get(key); found {
+		// The cached review resource is equivalent, but only refers to the asset if it was not enriched or transformed.
+		return v.dropResourceBody(v.redact(cached.forAsset(asset, target != configs.K8STargetName && v.transformer == nil && v.enricher == nil))), nil
+	}
+	result, err := v.review(ctx, asset, target)
+	if err != nil {
+		return nil, err
+	}
+	v.cache.add(key, result)
+	return result, nil
+}
+
+// review applies the configured enricher and transforms and sends the asset to the Constraint
+// Framework client of target.
+func (v *Validator) review(ctx context.Context, asset map[string]interface{}, target string) (*Result, error) {
+	reviewAsset, err := v.prepare(ctx, asset)
+	if err != nil {
+		return nil, err
+	}
+	return v.reviewPrepared(ctx, asset, reviewAsset, target)
+}
+
+// prepare applies the configured enricher and transforms to asset, returning the asset to review.
+func (v *Validator) prepare(ctx context.Context, asset map[string]interface{}) (map[string]interface{}, error) {
+	reviewAsset := asset
+	if v.enricher != nil {
+		var err error
+		if reviewAsset, err = v.enricher.Enrich(ctx, reviewAsset); err != nil {
+			return nil, errors.Wrapf(err, "failed to enrich asset")
+		}
+	}
+	if v.transformer != nil {
+		var err error
+		if reviewAsset, err = v.transformer.Apply(reviewAsset); err != nil {
+			return nil, errors.Wrapf(err, "failed to transform asset")
+		}
+	}
+	return reviewAsset, nil
+}
+
+// reviewPrepared sends reviewAsset, the prepared form of asset, to the Constraint Framework
+// client of target.
+func (v *Validator) reviewPrepared(
+	ctx context.Context, asset, reviewAsset map[string]interface{}, target string) (*Result, error) {
+	var result *Result
+	var err error
+	switch target {
+	case configs.K8STargetName:
+		result, err = v.reviewK8SResource(ctx, reviewAsset)
+	case gcptarget.Name:
+		result, err = v.reviewGCPResource(ctx, reviewAsset)
+	default:
+		result, err = v.reviewRegisteredTarget(ctx, target, reviewAsset)
+	}
+	if err != nil {
+		return nil, err
+	}
+	result.CAIResource = asset
+	result.PolicyVersion = v.PolicyVersion()
+	v.remediate(v.redact(result))
+	return v.dropResourceBody(result), nil
+}
+
+// redact replaces the sensitive fields of result if the validator was set up WithRedactor.
+func (v *Validator) redact(result *Result) *Result {
+	if v.redactor == nil {
+		return result
+	}
+	result.CAIResource = v.redactor.Redact(result.CAIResource)
+	result.ReviewResource = v.redactor.Redact(result.ReviewResource)
+	for idx := range result.ConstraintViolations {
+		cv := &result.ConstraintViolations[idx]
+		cv.Metadata = v.redactor.Redact(cv.Metadata)
+	}
+	return result
+}
+
+// resourceIdentityKeys are the fields of an asset kept in results WithoutResourceBodies.
+var resourceIdentityKeys = []string{"name", "asset_type", ancestryPathKey}
+
+// dropResourceBody replaces the resources of result with the identity of the asset if the
+// validator was set up WithoutResourceBodies.
+func (v *Validator) dropResourceBody(result *Result) *Result {
+	if !v.withoutResourceBodies {
+		return result
+	}
+	identity := make(map[string]interface{}, len(resourceIdentityKeys))
+	for _, key := range resourceIdentityKeys {
+		if value, found := result.CAIResource[key]; found {
+			identity[key] = value
+		}
+	}
+	result.CAIResource = identity
+	result.ReviewResource = nil
+	return result
 }
 
 // reviewK8SResource will unwrap k8s resources then pass them to the cf client with the gatekeeper target.
+// With multiTarget, the asset is also passed to the cf client with the GCP target.
 func (v *Validator) reviewK8SResource(ctx context.Context, asset map[string]interface{}) (*Result, error) {
-	k8sResource, err := asset2.UnwrapCAIResource(asset)
+	k8sResource, err := k8sunwrap.Unwrap(asset)
+	if err != nil {
+		return nil, classify(ErrConversion, errors.Wrapf(err, "failed to convert asset to admission request"))
+	}
+	responses, err := reviewK8SObject(ctx, k8sResource, v.k8sCFClient.Review)
 	if err != nil {
-		return nil, errors.Wrapf(err, "failed to convert asset to admission request")
+		return nil, err
+	}
+	if !v.multiTarget {
+		return NewResult(configs.K8STargetName, asset, k8sResource.Object, responses)
 	}
-	responses, err := v.k8sCFClient.Review(ctx, k8sResource)
+
+	id, err := newGCPIdentity(asset)
 	if err != nil {
-		return nil, errors.Wrapf(err, "K8S target Constraint Framework review call failed")
+		return nil, err
+	}
+	gcpResponses, err := v.reviewGCPAsset(ctx, asset, id)
+	if err != nil {
+		return nil, err
+	}
+	byTarget := map[string]*cftypes.Responses{configs.K8STargetName: responses}
+	if _, found := gcpResponses.ByTarget[gcptarget.Name]; found {
+		byTarget[gcptarget.Name] = gcpResponses
+	}
+	result, err := NewMultiTargetResult(asset, k8sResource.Object, byTarget)
+	if err != nil {
+		return nil, err
 	}
-	return NewResult(configs.K8STargetName, asset, k8sResource.Object, responses)
+	return v.dropOutOfScope(result, id), nil
 }
 
-// reviewGCPResource will unwrap k8s resources then pass them to the cf client with the gatekeeper target.
+// reviewK8SObject reviews an unwrapped K8S resource with review. Gatekeeper policies are written
+// for single objects, so the items of lists are reviewed one by one and their violations are
+// reported together, on the list.
+func reviewK8SObject(
+	ctx context.Context,
+	u *unstructured.Unstructured,
+	review func(context.Context, interface{}) (*cftypes.Responses, error)) (*cftypes.Responses, error) {
+	if !u.IsList() {
+		responses, err := review(ctx, u)
+		return responses, errors.Wrapf(err, "K8S target Constraint Framework review call failed")
+	}
+	items, err := k8sunwrap.Items(u)
+	if err != nil {
+		return nil, classify(ErrConversion, err)
+	}
+	merged := &cftypes.Responses{
+		ByTarget: map[string]*cftypes.Response{
+			configs.K8STargetName: {Target: configs.K8STargetName},
+		},
+		Handled: map[string]bool{configs.K8STargetName: true},
+	}
+	for idx, item := range items {
+		responses, err := review(ctx, item)
+		if err != nil {
+			return nil, errors.Wrapf(err, "K8S target Constraint Framework review call failed for item %d %s", idx, item.GetName())
+		}
+		if response, found := responses.ByTarget[configs.K8STargetName]; found {
+			merged.ByTarget[configs.K8STargetName].Results = append(merged.ByTarget[configs.K8STargetName].Results, response.Results...)
+		}
+	}
+	return merged, nil
+}
+
+// reviewGCPResource passes GCP resources to the cf client with the GCP target. The scopes of the
+// constraints are checked before and after the review: assets outside the scope of every
+// constraint are not evaluated at all, and violations of constraints whose scope does not
+// include the asset are dropped even if the template matched it.
 func (v *Validator) reviewGCPResource(ctx context.Context, asset map[string]interface{}) (*Result, error) {
-	responses, err := v.gcpCFClient.Review(ctx, asset)
+	id, err := newGCPIdentity(asset)
+	if err != nil {
+		return nil, err
+	}
+	responses, err := v.reviewGCPAsset(ctx, asset, id)
+	if err != nil {
+		return nil, err
+	}
+	if _, found := responses.ByTarget[gcptarget.Name]; !found && v.reportSkipped {
+		return &Result{Name: id.name, CAIResource: asset, ReviewResource: asset, Skipped: true}, nil
+	}
+	result, err := NewResult(gcptarget.Name, asset, asset, responses)
 	if err != nil {
+		return nil, err
+	}
+	return v.dropOutOfScope(result, id), nil
+}
+
+// gcpIdentity holds the fields of an asset that GCP constraint scopes match on.
+type gcpIdentity struct {
+	name         string
+	ancestryPath string
+	labels       map[string]string
+}
+
+func newGCPIdentity(asset map[string]interface{}) (gcpIdentity, error) {
+	ancestryPath, _, err := unstructured.NestedString(asset, ancestryPathKey)
+	if err != nil {
+		return gcpIdentity{}, errors.Wrapf(err, "invalid ancestry path")
+	}
+	name, _, err := unstructured.NestedString(asset, "name")
+	if err != nil {
+		return gcpIdentity{}, errors.Wrapf(err, "invalid name")
+	}
+	return gcpIdentity{name: name, ancestryPath: ancestryPath, labels: gcptarget.Labels(asset)}, nil
+}
+
+// reviewGCPAsset reviews asset with the GCP target, unless it is outside the scope of every GCP
+// constraint.
+func (v *Validator) reviewGCPAsset(ctx context.Context, asset map[string]interface{}, id gcpIdentity) (*cftypes.Responses, error) {
+	var responses *cftypes.Responses
+	var err error
+	if v.anyInScope(id.name, id.ancestryPath, id.labels) {
+		if responses, err = v.gcpCFClient.Review(ctx, asset); err != nil {
+			return nil, errors.Wrapf(err, "GCP target Constraint Framework review call failed")
+		}
+	} else if responses, err = emptyResponses(asset); err != nil {
 		return nil, errors.Wrapf(err, "GCP target Constraint Framework review call failed")
 	}
-	return NewResult(gcptarget.Name, asset, asset, responses)
+	return responses, nil
+}
+
+// dropOutOfScope drops the violations of GCP constraints whose scope does not include the asset
+// identified by id from result.
+func (v *Validator) dropOutOfScope(result *Result, id gcpIdentity) *Result {
+	inScope := result.ConstraintViolations[:0]
+	for _, cv := range result.ConstraintViolations {
+		if scope, found := v.gcpScopes[scopeKey(cv.Constraint)]; !found || scope.Matches(id.name, id.ancestryPath, id.labels) {
+			inScope = append(inScope, cv)
+		}
+	}
+	result.ConstraintViolations = inScope
+	return result
+}
+
+// emptyResponses returns the responses of a review of asset by constraints that do not apply to
+// it, which only have a response for the GCP target if the target handles the asset.
+func emptyResponses(asset map[string]interface{}) (*cftypes.Responses, error) {
+	responses := &cftypes.Responses{ByTarget: map[string]*cftypes.Response{}}
+	handled, _, err := gcptarget.New().HandleReview(asset)
+	if err != nil {
+		return nil, err
+	}
+	if handled {
+		responses.ByTarget[gcptarget.Name] = &cftypes.Response{Target: gcptarget.Name}
+	}
+	return responses, nil
+}
+
+// anyInScope returns whether an asset with name, ancestryPath and labels is in the scope of any
+// GCP constraint.
+func (v *Validator) anyInScope(name, ancestryPath string, labels map[string]string) bool {
+	for _, scope := range v.gcpScopes {
+		if scope.Matches(name, ancestryPath, labels) {
+			return true
+		}
+	}
+	return false
 }
@@ -17,34 +17,105 @@ package gcv
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/forseti-security/config-validator/pkg/api/validator"
+	"github.com/forseti-security/config-validator/pkg/externaldata"
+	"github.com/forseti-security/config-validator/pkg/gcv/configs"
+	"github.com/forseti-security/config-validator/pkg/redact"
+	"github.com/forseti-security/config-validator/pkg/transform"
 	"github.com/golang/protobuf/jsonpb"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
 const (
 	testRoot          = "../../test/cf"
 	localPolicyDir    = testRoot
 	localPolicyDepDir = testRoot + "/library"
+	// featurePolicyDir holds the templates and constraints of the tests of features such as org
+	// policy, Access Context Manager, inventory and CEL reviews.
+	featurePolicyDir = "testdata"
 )
 
 func TestCreateValidatorWithNoOptions(t *testing.T) {
-	_, err := NewValidator(nil, "/foo")
+	_, err := NewValidator(WithPolicyLibrary("/foo"))
 	if err == nil {
 		t.Fatal("expected an error since no policy path is provided")
 	}
-	_, err = NewValidator([]string{"/foo"}, "")
+	_, err = NewValidator(WithPolicyPaths("/foo"))
 	if err == nil {
 		t.Fatal("expected an error since no policy library path is provided")
 	}
 }
 
+func TestNewValidatorOptions(t *testing.T) {
+	local, err := NewValidator(testOptions()...)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	builtin, err := NewValidator(WithPolicyPaths(configs.BuiltinCIS))
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	// Policy paths accumulate across options.
+	both, err := NewValidator(WithPolicyPaths(localPolicyDir), WithPolicyLibrary(localPolicyDepDir), WithPolicyPaths(configs.BuiltinCIS))
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if got, want := len(both.Constraints()), len(local.Constraints())+len(builtin.Constraints()); got != want {
+		t.Errorf("got %d constraints, want %d", got, want)
+	}
+
+	shim, err := NewValidatorFromPaths([]string{localPolicyDir}, localPolicyDepDir, WithPolicyVersion("1.0.0"))
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if shim.PolicyVersion() != "1.0.0" || len(shim.Constraints()) != len(local.Constraints()) {
+		t.Errorf("got policy version %s with %d constraints, want 1.0.0 with %d", shim.PolicyVersion(), len(shim.Constraints()), len(local.Constraints()))
+	}
+}
+
+func TestReviewAssetJSON(t *testing.T) {
+	v, err := NewValidator(testOptions()...)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	ctx := context.Background()
+	want, err := v.ReviewAssetJSON(ctx, []byte(storageAssetNoLoggingJSON))
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if len(want.ConstraintViolations) == 0 {
+		t.Fatalf("got no violations of %s", want.Name)
+	}
+	// Assets of Cloud Asset API responses name their fields in camel case.
+	camelCase := strings.NewReplacer(`"asset_type"`, `"assetType"`, `"ancestry_path"`, `"ancestryPath"`,
+		`"discovery_document_uri"`, `"discoveryDocumentUri"`, `"discovery_name"`, `"discoveryName"`).Replace(storageAssetNoLoggingJSON)
+	got, err := v.ReviewAssetJSON(ctx, []byte(camelCase))
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if diff := cmp.Diff(want.CAIResource, got.CAIResource); diff != "" {
+		t.Errorf("unexpected asset (-want +got):\n%s", diff)
+	}
+	if len(got.ConstraintViolations) != len(want.ConstraintViolations) {
+		t.Errorf("got %d violations, want %d", len(got.ConstraintViolations), len(want.ConstraintViolations))
+	}
+	if _, err := v.ReviewAssetJSON(ctx, []byte(`{"name": `)); errors.Cause(err) != ErrConversion {
+		t.Errorf("got error %v for invalid JSON, want conversion error", err)
+	}
+}
+
 func TestDefaultTestDataCreatesValidator(t *testing.T) {
-	_, err := NewValidator(testOptions())
+	_, err := NewValidator(testOptions()...)
 	if err != nil {
 		t.Fatal("unexpected error", err)
 	}
@@ -86,11 +157,76 @@ func TestReviewAsset(t *testing.T) {
 			assetJson:      namespaceAssetWithNoLabelJSON,
 			wantViolations: 1,
 		},
+		{
+			name:           "test k8s list asset violation",
+			assetJson:      namespaceListAssetJSON,
+			wantViolations: 1,
+		},
+		{
+			name:           "test org policy denying all values",
+			assetJson:      orgPolicyJSON(`{"all_values": "DENY"}`),
+			wantViolations: 0,
+		},
+		{
+			name:           "test org policy allowing values",
+			assetJson:      orgPolicyJSON(`{"allowed_values": ["projects/1234567890/zones/us-central1-a/instances/vm"]}`),
+			wantViolations: 1,
+		},
+		{
+			name:           "test v2 org policy denying all values",
+			assetJson:      orgPolicyV2JSON(`[{"denyAll": true}]`),
+			wantViolations: 0,
+		},
+		{
+			name:           "test v2 org policy allowing values",
+			assetJson:      orgPolicyV2JSON(`[{"allowAll": true}]`),
+			wantViolations: 1,
+		},
+		{
+			name:           "test access policy",
+			assetJson:      accessContextJSON("AccessPolicy", "access_policy", `{"name": "accessPolicies/1", "parent": "organizations/1234567899", "title": "default"}`),
+			wantViolations: 0,
+		},
+		{
+			name:           "test access level",
+			assetJson:      accessContextJSON("AccessLevel", "access_level", `{"name": "accessPolicies/1/accessLevels/corp", "basic": {"conditions": [{"ip_subnetworks": ["10.0.0.0/8"]}]}}`),
+			wantViolations: 0,
+		},
+		{
+			name:           "test service perimeter restricting storage",
+			assetJson:      accessContextJSON("ServicePerimeter", "service_perimeter", `{"name": "accessPolicies/1/servicePerimeters/p", "status": {"resources": ["projects/1234567890"], "restricted_services": ["bigquery.googleapis.com", "storage.googleapis.com"]}}`),
+			wantViolations: 0,
+		},
+		{
+			name:           "test service perimeter not restricting storage",
+			assetJson:      accessContextJSON("ServicePerimeter", "service_perimeter", `{"name": "accessPolicies/1/servicePerimeters/p", "status": {"resources": ["projects/1234567890"], "restricted_services": ["bigquery.googleapis.com"]}}`),
+			wantViolations: 1,
+		},
+		{
+			name:           "test service perimeter bridge",
+			assetJson:      accessContextJSON("ServicePerimeter", "service_perimeter", `{"name": "accessPolicies/1/servicePerimeters/b", "perimeter_type": "PERIMETER_TYPE_BRIDGE", "status": {"resources": ["projects/1234567890"]}}`),
+			wantViolations: 0,
+		},
+		{
+			name:           "test sql instance with public ip",
+			assetJson:      sqlInstanceJSON("db", true),
+			wantViolations: 1,
+		},
+		{
+			name:           "test sql instance with private ip",
+			assetJson:      sqlInstanceJSON("db", false),
+			wantViolations: 0,
+		},
+		{
+			name:           "test allowed sql instance with public ip",
+			assetJson:      sqlInstanceJSON("bastion", true),
+			wantViolations: 0,
+		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			v, err := NewValidator(testOptions())
+			v, err := NewValidator(featureTestOptions()...)
 			if err != nil {
 				t.Fatal("unexpected error", err)
 			}
@@ -131,6 +267,650 @@ func TestReviewAsset(t *testing.T) {
 	}
 }
 
+func TestReviewWithTransformer(t *testing.T) {
+	transformer, err := transform.Parse([]byte(`
+transforms:
+- assetType: storage.googleapis.com/Bucket
+  set:
+  - field: resource.data.logging
+    expression: '{"logBucket": "central-logs"}'
+`))
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	v, err := NewValidator(append(testOptions(), WithTransformer(transformer), WithResultCache(4))...)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	// The second review is answered from the cache.
+	for i := 0; i < 2; i++ {
+		result, err := v.ReviewJSON(context.Background(), storageAssetNoLoggingJSON)
+		if err != nil {
+			t.Fatal("unexpected error", err)
+		}
+		if len(result.ConstraintViolations) != 0 {
+			t.Errorf("wanted no violations for transformed bucket, got %v", result.ConstraintViolations)
+		}
+		if logging, _, _ := unstructured.NestedMap(result.CAIResource, "resource", "data", "logging"); len(logging) != 0 {
+			t.Errorf("CAIResource should be the asset as provided, got logging %v", logging)
+		}
+		if bucket, _, _ := unstructured.NestedString(result.ReviewResource, "resource", "data", "logging", "logBucket"); bucket != "central-logs" {
+			t.Errorf("ReviewResource should be the transformed asset, got log bucket %q", bucket)
+		}
+	}
+}
+
+// enricherFunc adapts a function to the Enricher interface.
+type enricherFunc func(ctx context.Context, asset map[string]interface{}) (map[string]interface{}, error)
+
+func (f enricherFunc) Enrich(ctx context.Context, asset map[string]interface{}) (map[string]interface{}, error) {
+	return f(ctx, asset)
+}
+
+func TestReviewWithEnricher(t *testing.T) {
+	enricher := enricherFunc(func(ctx context.Context, asset map[string]interface{}) (map[string]interface{}, error) {
+		enriched := runtime.DeepCopyJSON(asset)
+		err := unstructured.SetNestedField(enriched, "central-logs", "resource", "data", "centralLogBucket")
+		return enriched, err
+	})
+	// Transforms see the enriched asset.
+	transformer, err := transform.Parse([]byte(`
+transforms:
+- assetType: storage.googleapis.com/Bucket
+  set:
+  - field: resource.data.logging.logBucket
+    expression: asset.resource.data.centralLogBucket
+`))
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	v, err := NewValidator(append(testOptions(), WithEnricher(enricher), WithTransformer(transformer))...)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	result, err := v.ReviewJSON(context.Background(), storageAssetNoLoggingJSON)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if len(result.ConstraintViolations) != 0 {
+		t.Errorf("wanted no violations for enriched bucket, got %v", result.ConstraintViolations)
+	}
+	if _, found, _ := unstructured.NestedString(result.CAIResource, "resource", "data", "centralLogBucket"); found {
+		t.Errorf("CAIResource should be the asset as provided")
+	}
+	if bucket, _, _ := unstructured.NestedString(result.ReviewResource, "resource", "data", "logging", "logBucket"); bucket != "central-logs" {
+		t.Errorf("ReviewResource should be the enriched asset, got log bucket %q", bucket)
+	}
+
+	failing := enricherFunc(func(ctx context.Context, asset map[string]interface{}) (map[string]interface{}, error) {
+		return nil, errors.New("lookup failed")
+	})
+	v, err = NewValidator(append(testOptions(), WithEnricher(failing))...)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if _, err := v.ReviewJSON(context.Background(), storageAssetNoLoggingJSON); err == nil {
+		t.Errorf("expected error from failing enricher")
+	}
+}
+
+// ancestryFunc adapts a function to the AncestryResolver interface.
+type ancestryFunc func(ctx context.Context, name, parent string) ([]string, error)
+
+func (f ancestryFunc) Ancestors(ctx context.Context, name, parent string) ([]string, error) {
+	return f(ctx, name, parent)
+}
+
+func TestReviewWithAncestryResolver(t *testing.T) {
+	var lookups []string
+	resolver := ancestryFunc(func(ctx context.Context, name, parent string) ([]string, error) {
+		lookups = append(lookups, parent)
+		return []string{"projects/68478495408", "folders/2", "organizations/1"}, nil
+	})
+	v, err := NewValidator(append(testOptions(), WithAncestryResolver(resolver))...)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	asset := unmarshalAssets(t, storageAssetNoLoggingJSON)[0]
+	delete(asset, "ancestry_path")
+	result, err := v.ReviewUnmarshalledJSON(context.Background(), asset)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if path, _, _ := unstructured.NestedString(result.CAIResource, "ancestry_path"); path != "organizations/1/folders/2/projects/68478495408" {
+		t.Errorf("got ancestry path %q", path)
+	}
+	if len(result.ConstraintViolations) == 0 {
+		t.Errorf("wanted violations for bucket with resolved ancestry")
+	}
+
+	pbAsset := storageAssetNoLogging()
+	pbAsset.AncestryPath = ""
+	violations, err := v.ReviewAsset(context.Background(), pbAsset)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if len(violations) == 0 {
+		t.Errorf("wanted violations for bucket with resolved ancestry")
+	}
+	if len(pbAsset.Ancestors) != 0 || pbAsset.AncestryPath != "" {
+		t.Errorf("ReviewAsset changed the ancestry of the reviewed asset to %v, %q", pbAsset.Ancestors, pbAsset.AncestryPath)
+	}
+	// Assets with ancestry information are not resolved.
+	if _, err := v.ReviewJSON(context.Background(), storageAssetNoLoggingJSON); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	want := []string{"//cloudresourcemanager.googleapis.com/projects/68478495408", "//cloudresourcemanager.googleapis.com/projects/68478495408"}
+	if diff := cmp.Diff(want, lookups); diff != "" {
+		t.Errorf("unexpected lookups (-want +got):\n%s", diff)
+	}
+
+	failing := ancestryFunc(func(ctx context.Context, name, parent string) ([]string, error) {
+		return nil, errors.New("lookup failed")
+	})
+	v, err = NewValidator(append(testOptions(), WithAncestryResolver(failing))...)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	asset = unmarshalAssets(t, storageAssetNoLoggingJSON)[0]
+	delete(asset, "ancestry_path")
+	if _, err := v.ReviewUnmarshalledJSON(context.Background(), asset); err == nil || !strings.Contains(err.Error(), "lookup failed") {
+		t.Errorf("got error %v, want lookup failure", err)
+	}
+}
+
+func TestReviewWithUnknownAncestry(t *testing.T) {
+	unknown := ancestryFunc(func(ctx context.Context, name, parent string) ([]string, error) {
+		return nil, nil
+	})
+	v, err := NewValidator(append(testOptions(), WithAncestryResolver(unknown))...)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	asset := unmarshalAssets(t, storageAssetNoLoggingJSON)[0]
+	delete(asset, "ancestry_path")
+	_, err = v.ReviewUnmarshalledJSON(context.Background(), asset)
+	if errors.Cause(err) != ErrUnknownAncestry || !strings.Contains(err.Error(), "GCPStorageLoggingConstraint/require-storage-logging-xx") {
+		t.Errorf("got error %v, want unknown ancestry naming the constraint", err)
+	}
+	pbAsset := storageAssetNoLogging()
+	pbAsset.AncestryPath = ""
+	if _, err := v.ReviewAsset(context.Background(), pbAsset); errors.Cause(err) != ErrUnknownAncestry {
+		t.Errorf("got error %v, want unknown ancestry", err)
+	}
+
+	// Without constraints matching on ancestry, the asset fails validation as before.
+	policyDir, err := ioutil.TempDir("", "UnknownAncestryTest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(policyDir)
+	unbound := strings.Replace(locationConstraint, `target: ["organizations/**"]`, `target: ["**"]`, 1)
+	for name, content := range map[string]string{"template.yaml": locationTemplate, "constraint.yaml": unbound} {
+		if err := ioutil.WriteFile(filepath.Join(policyDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	v, err = NewValidator(WithPolicyPaths(policyDir), WithPolicyLibrary(localPolicyDepDir), WithAncestryResolver(unknown))
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	asset = unmarshalAssets(t, storageAssetNoLoggingJSON)[0]
+	delete(asset, "ancestry_path")
+	if _, err := v.ReviewUnmarshalledJSON(context.Background(), asset); err == nil || errors.Cause(err) == ErrUnknownAncestry {
+		t.Errorf("got error %v, want invalid asset", err)
+	}
+}
+
+// ownerTemplate flags buckets whose owner, as returned by the owners data provider, is not
+// approved.
+const ownerTemplate = `apiVersion: templates.gatekeeper.sh/v1alpha1
+kind: ConstraintTemplate
+metadata:
+  name: gcp-bucket-owner
+spec:
+  crd:
+    spec:
+      names:
+        kind: GCPBucketOwnerConstraint
+  targets:
+    validation.gcp.forsetisecurity.org:
+      rego: |
+        package templates.gcp.GCPBucketOwnerConstraint
+
+        deny[{"msg": message, "details": {}}] {
+        	asset := input.asset
+        	response := external_data({"provider": "owners", "keys": [asset.name]})
+        	response.errors[_][0] == asset.name
+        	message := sprintf("%v has no owner", [asset.name])
+        }
+`
+
+const ownerConstraint = `apiVersion: constraints.gatekeeper.sh/v1alpha1
+kind: GCPBucketOwnerConstraint
+metadata:
+  name: bucket-owner
+spec:
+  match:
+    target: ["organizations/**"]
+`
+
+func TestReviewWithDataProvider(t *testing.T) {
+	policyDir, err := ioutil.TempDir("", "DataProviderTest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(policyDir)
+	for name, content := range map[string]string{"template.yaml": ownerTemplate, "constraint.yaml": ownerConstraint} {
+		if err := ioutil.WriteFile(filepath.Join(policyDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var lookups int
+	owners := externaldata.ProviderFunc(func(ctx context.Context, keys []string) (map[string]interface{}, error) {
+		lookups++
+		return map[string]interface{}{}, nil
+	})
+	v, err := NewValidator(WithPolicyPaths(policyDir), WithPolicyLibrary(localPolicyDepDir), WithDataProvider("owners", owners))
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	result, err := v.ReviewJSON(context.Background(), storageAssetNoLoggingJSON)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if len(result.ConstraintViolations) != 1 || lookups != 1 {
+		t.Errorf("got %d violations after %d lookups, want 1 violation after 1 lookup", len(result.ConstraintViolations), lookups)
+	}
+}
+
+const locationTemplate = `apiVersion: templates.gatekeeper.sh/v1alpha1
+kind: ConstraintTemplate
+metadata:
+  name: gcp-bucket-location
+spec:
+  crd:
+    spec:
+      names:
+        kind: GCPBucketLocationConstraint
+      validation:
+        openAPIV3Schema:
+          properties:
+            location:
+              type: string
+              default: EU
+  targets:
+    validation.gcp.forsetisecurity.org:
+      rego: |
+        package templates.gcp.GCPBucketLocationConstraint
+
+        deny[{"msg": message, "details": {}}] {
+        	asset := input.asset
+        	location := input.constraint.spec.parameters.location
+        	asset.resource.data.location != location
+        	message := sprintf("%v is not in %v", [asset.name, location])
+        }
+`
+
+const locationConstraint = `apiVersion: constraints.gatekeeper.sh/v1alpha1
+kind: GCPBucketLocationConstraint
+metadata:
+  name: bucket-location
+spec:
+  match:
+    target: ["organizations/**"]
+`
+
+const namespaceAssetTemplate = `apiVersion: templates.gatekeeper.sh/v1alpha1
+kind: ConstraintTemplate
+metadata:
+  name: gcp-namespace-asset
+spec:
+  crd:
+    spec:
+      names:
+        kind: GCPNamespaceAssetConstraint
+  targets:
+    validation.gcp.forsetisecurity.org:
+      rego: |
+        package templates.gcp.GCPNamespaceAssetConstraint
+
+        deny[{"msg": message, "details": {}}] {
+        	asset := input.asset
+        	asset.asset_type == "k8s.io/Namespace"
+        	message := sprintf("%v is a namespace asset", [asset.name])
+        }
+`
+
+const namespaceAssetConstraint = `apiVersion: constraints.gatekeeper.sh/v1alpha1
+kind: GCPNamespaceAssetConstraint
+metadata:
+  name: namespace-asset
+spec:
+  match:
+    target: ["organizations/**"]
+`
+
+func TestReviewWithMultiTargetReview(t *testing.T) {
+	policyDir, err := ioutil.TempDir("", "MultiTargetTest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(policyDir)
+	files := map[string]string{"template.yaml": namespaceAssetTemplate, "constraint.yaml": namespaceAssetConstraint}
+	for name, path := range map[string]string{
+		"k8s_template.yaml":   filepath.Join(testRoot, "templates", "k8srequiredlabels_template.yaml"),
+		"k8s_constraint.yaml": filepath.Join(testRoot, "constraints", "all_namespace_must_have_cost_center.yaml"),
+	} {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[name] = string(content)
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(policyDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tc := range []struct {
+		name string
+		opts []Option
+		want []string
+	}{
+		{
+			name: "kubernetes target only",
+			want: []string{"K8sRequiredLabels.namespace-cost-center-label"},
+		},
+		{
+			name: "all targets",
+			opts: []Option{WithMultiTargetReview()},
+			want: []string{"GCPNamespaceAssetConstraint.namespace-asset", "K8sRequiredLabels.namespace-cost-center-label"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			v, err := NewValidator(append([]Option{WithPolicyPaths(policyDir), WithPolicyLibrary(localPolicyDepDir)}, tc.opts...)...)
+			if err != nil {
+				t.Fatal("unexpected error", err)
+			}
+			result, err := v.ReviewJSON(context.Background(), namespaceAssetWithNoLabelJSON)
+			if err != nil {
+				t.Fatal("unexpected error", err)
+			}
+			var got []string
+			for _, cv := range result.ConstraintViolations {
+				got = append(got, cv.ConstraintName())
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected violations (-want +got):\n%s", diff)
+			}
+			// Kubernetes constraints review the unwrapped object.
+			if kind, _, _ := unstructured.NestedString(result.ReviewResource, "kind"); kind != "Namespace" {
+				t.Errorf("got review resource of kind %q, want Namespace", kind)
+			}
+		})
+	}
+
+	// GCP assets are reviewed as before.
+	v, err := NewValidator(WithPolicyPaths(policyDir), WithPolicyLibrary(localPolicyDepDir), WithMultiTargetReview())
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	result, err := v.ReviewJSON(context.Background(), storageAssetNoLoggingJSON)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if len(result.ConstraintViolations) != 0 {
+		t.Errorf("got violations %v of bucket, want none", result.ConstraintViolations)
+	}
+}
+
+func TestReviewWithParameterDefaults(t *testing.T) {
+	policyDir, err := ioutil.TempDir("", "ParameterDefaultsTest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(policyDir)
+	for name, content := range map[string]string{"template.yaml": locationTemplate, "constraint.yaml": locationConstraint} {
+		if err := ioutil.WriteFile(filepath.Join(policyDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	v, err := NewValidator(WithPolicyPaths(policyDir), WithPolicyLibrary(localPolicyDepDir))
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	// The constraint omits the location, which defaults to the one declared by the template.
+	result, err := v.ReviewJSON(context.Background(), storageAssetNoLoggingJSON)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	var got []string
+	for _, cv := range result.ConstraintViolations {
+		got = append(got, cv.Message)
+	}
+	if diff := cmp.Diff([]string{"//storage.googleapis.com/my-storage-bucket is not in EU"}, got); diff != "" {
+		t.Errorf("unexpected violations (-want +got):\n%s", diff)
+	}
+
+	info, err := NewConstraintInfo(v.Constraints()[0])
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if location := info.Parameters.GetStructValue().GetFields()["location"].GetStringValue(); info.Name != "GCPBucketLocationConstraint.bucket-location" || location != "EU" {
+		t.Errorf("got constraint info %v, want effective location EU", info)
+	}
+}
+
+func TestPolicyVersion(t *testing.T) {
+	hashed, err := NewValidator(testOptions()...)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	declared, err := NewValidator(append(testOptions(), WithPolicyVersion("2.1.0-rc.1+build.5"))...)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if hashed.PolicyVersion() != hashed.PolicyContentHash() || len(hashed.PolicyVersion()) != 64 {
+		t.Errorf("got version %q, want content hash %q", hashed.PolicyVersion(), hashed.PolicyContentHash())
+	}
+	if declared.PolicyContentHash() != hashed.PolicyContentHash() {
+		t.Errorf("declared version changed content hash to %q", declared.PolicyContentHash())
+	}
+
+	result, err := declared.ReviewJSON(context.Background(), storageAssetNoLoggingJSON)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if result.PolicyVersion != "2.1.0-rc.1+build.5" {
+		t.Errorf("got result policy version %q, want 2.1.0-rc.1+build.5", result.PolicyVersion)
+	}
+
+	for _, invalid := range []string{"v1.0.0", "1.0", "01.0.0", "1.0.0-"} {
+		if _, err := NewValidator(append(testOptions(), WithPolicyVersion(invalid))...); err == nil {
+			t.Errorf("%q: expected error for invalid semantic version", invalid)
+		}
+	}
+}
+
+func TestReviewWithScope(t *testing.T) {
+	v, err := NewValidator(testOptions()...)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	var testCases = []struct {
+		name      string
+		match     map[string]interface{}
+		wantMatch bool
+	}{
+		{
+			name:      "in ancestries",
+			match:     map[string]interface{}{"ancestries": []interface{}{"organizations/1/folders/2/**"}},
+			wantMatch: true,
+		},
+		{
+			name:  "outside of ancestries",
+			match: map[string]interface{}{"ancestries": []interface{}{"organizations/1/folders/9/**"}},
+		},
+		{
+			name: "excluded ancestry",
+			match: map[string]interface{}{
+				"ancestries":         []interface{}{"organizations/1/**"},
+				"excludedAncestries": []interface{}{"organizations/1/folders/2/**"},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var constraints []*unstructured.Unstructured
+			for _, constraint := range v.Constraints() {
+				if constraint.GetName() == "require-storage-logging" {
+					constraint = constraint.DeepCopy()
+					if err := unstructured.SetNestedMap(constraint.Object, tc.match, "spec", "match"); err != nil {
+						t.Fatal("unexpected error", err)
+					}
+				}
+				constraints = append(constraints, constraint)
+			}
+			config, err := v.config.WithConstraints(constraints)
+			if err != nil {
+				t.Fatal("unexpected error", err)
+			}
+			scoped, err := NewValidatorFromConfig(config)
+			if err != nil {
+				t.Fatal("unexpected error", err)
+			}
+			result, err := scoped.ReviewJSON(context.Background(), storageAssetNoLoggingJSON)
+			if err != nil {
+				t.Fatal("unexpected error", err)
+			}
+			gotMatch := false
+			for _, cv := range result.ConstraintViolations {
+				if cv.Constraint.GetName() == "require-storage-logging" {
+					gotMatch = true
+				}
+			}
+			if gotMatch != tc.wantMatch {
+				t.Errorf("got violation %v, want %v", gotMatch, tc.wantMatch)
+			}
+		})
+	}
+}
+
+func TestReviewSkippedAsset(t *testing.T) {
+	const osInventoryJSON = `{
+  "name": "//compute.googleapis.com/projects/p/zones/z/instances/i",
+  "asset_type": "compute.googleapis.com/Instance",
+  "ancestry_path": "organizations/1/projects/2",
+  "os_inventory": {}
+}`
+	v, err := NewValidator(testOptions()...)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if _, err := v.ReviewJSON(context.Background(), osInventoryJSON); err == nil {
+		t.Errorf("expected error for unhandled asset")
+	}
+
+	v, err = NewValidator(append(testOptions(), WithSkippedAssets())...)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	result, err := v.ReviewJSON(context.Background(), osInventoryJSON)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if !result.Skipped || result.Name != "//compute.googleapis.com/projects/p/zones/z/instances/i" {
+		t.Errorf("got result %+v, want skipped instance", result)
+	}
+	result, err = v.ReviewJSON(context.Background(), storageAssetNoLoggingJSON)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if result.Skipped || len(result.ConstraintViolations) == 0 {
+		t.Errorf("got result %+v, want reviewed asset with violations", result)
+	}
+}
+
+func TestReviewWithoutResourceBodies(t *testing.T) {
+	v, err := NewValidator(append(testOptions(), WithoutResourceBodies(), WithResultCache(10))...)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	// The second review is served from the cache.
+	for i := 0; i < 2; i++ {
+		result, err := v.ReviewJSON(context.Background(), storageAssetNoLoggingJSON)
+		if err != nil {
+			t.Fatal("unexpected error", err)
+		}
+		want := map[string]interface{}{
+			"name":          "//storage.googleapis.com/my-storage-bucket",
+			"asset_type":    "storage.googleapis.com/Bucket",
+			"ancestry_path": "organizations/1/folders/2/projects/3",
+		}
+		if diff := cmp.Diff(want, result.CAIResource); diff != "" {
+			t.Errorf("unexpected resource (-want +got):\n%s", diff)
+		}
+		if result.ReviewResource != nil {
+			t.Errorf("got review resource %v, want none", result.ReviewResource)
+		}
+		violations, err := result.ToViolations()
+		if err != nil {
+			t.Fatal("unexpected error", err)
+		}
+		if len(violations) == 0 {
+			t.Errorf("got no violations")
+		}
+	}
+}
+
+func TestReviewWithRedactor(t *testing.T) {
+	r, err := redact.New([]string{"resource.data.acl", "details.resource"})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	v, err := NewValidator(append(testOptions(), WithRedactor(r))...)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	result, err := v.ReviewJSON(context.Background(), storageAssetNoLoggingJSON)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if acl, _, _ := unstructured.NestedFieldNoCopy(result.CAIResource, "resource", "data", "acl"); acl != redact.Redacted {
+		t.Errorf("got acl %v, want %s", acl, redact.Redacted)
+	}
+	violations, err := result.ToViolations()
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if len(violations) == 0 {
+		t.Fatal("got no violations")
+	}
+	for _, violation := range violations {
+		details := violation.Metadata.GetStructValue().Fields["details"].GetStructValue()
+		if got := details.Fields["resource"].GetStringValue(); got != redact.Redacted {
+			t.Errorf("got resource %q in details of %s, want %s", got, violation.Constraint, redact.Redacted)
+		}
+	}
+}
+
+func TestReviewAssetCancelled(t *testing.T) {
+	v, err := NewValidator(testOptions()...)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := v.ReviewAsset(ctx, storageAssetNoLogging()); err == nil {
+		t.Fatal("expected error reviewing with cancelled context")
+	}
+}
+
 func TestCreateNoDir(t *testing.T) {
 	emptyFolder, err := ioutil.TempDir("", "emptyPolicyDir")
 	defer cleanup(t, emptyFolder)
@@ -139,8 +919,8 @@ func TestCreateNoDir(t *testing.T) {
 	}
 
 	if _, err = NewValidator(
-		[]string{filepath.Join(emptyFolder, "someDirThatDoesntExist")},
-		filepath.Join(emptyFolder, "someDirThatDoesntExist"),
+		WithPolicyPaths(filepath.Join(emptyFolder, "someDirThatDoesntExist")),
+		WithPolicyLibrary(filepath.Join(emptyFolder, "someDirThatDoesntExist")),
 	); err == nil {
 		t.Fatal("expected a file system error but got no error")
 	}
@@ -161,7 +941,7 @@ func TestCreateNoReadAccess(t *testing.T) {
 		t.Fatal("creating temp dir sub dir:", err)
 	}
 
-	if _, err = NewValidator([]string{tmpDir}, tmpDir); err == nil {
+	if _, err = NewValidator(WithPolicyPaths(tmpDir), WithPolicyLibrary(tmpDir)); err == nil {
 		t.Fatal("expected a file system error but got no error")
 	}
 }
@@ -178,7 +958,7 @@ func TestCreateEmptyDir(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	if _, err = NewValidator([]string{policyDir}, policyLibDir); err == nil {
+	if _, err = NewValidator(WithPolicyPaths(policyDir), WithPolicyLibrary(policyLibDir)); err == nil {
 		t.Fatal("directory without a configuration should generate error")
 	}
 }
@@ -191,9 +971,14 @@ func cleanup(t *testing.T, dir string) {
 
 // testOptions provides a set of default options that allows the successful creation
 // of a validator.
-func testOptions() ([]string, string) {
+func testOptions() []Option {
 	// Add default options to this list
-	return []string{localPolicyDir}, localPolicyDepDir
+	return []Option{WithPolicyPaths(localPolicyDir), WithPolicyLibrary(localPolicyDepDir)}
+}
+
+// featureTestOptions returns testOptions with the policies of featurePolicyDir added.
+func featureTestOptions() []Option {
+	return append(testOptions(), WithPolicyPaths(featurePolicyDir))
 }
 
 var defaultReviewTestAssetJSONs = map[string]string{
@@ -382,6 +1167,104 @@ var namespaceAssetWithNoLabelJSON = `
 }
 `
 
+var namespaceListAssetJSON = `
+{
+  "name": "//container.googleapis.com/projects/malaise-forever/zones/us-central1-a/clusters/test-1/k8s/namespaces",
+  "asset_type": "k8s.io/NamespaceList",
+  "resource": {
+    "version": "v1",
+    "data": {
+      "items": [
+        {"metadata": {"name": "labeled", "labels": {"cost-center": "1"}, "managedFields": [{"manager": "kubectl"}]}},
+        {"metadata": {"name": "unlabeled"}}
+      ]
+    }
+  },
+  "ancestors": [
+    "projects/1234567890",
+    "organizations/1234567899"
+  ]
+}
+`
+
+// orgPolicyJSON is a v1 org policy on constraints/compute.vmExternalIpAccess with the
+// given list policy.
+func orgPolicyJSON(listPolicy string) string {
+	return `{
+  "name": "//cloudresourcemanager.googleapis.com/projects/1234567890",
+  "asset_type": "cloudresourcemanager.googleapis.com/Project",
+  "org_policy": [
+    {
+      "constraint": "constraints/compute.vmExternalIpAccess",
+      "list_policy": ` + listPolicy + `,
+      "update_time": "2020-03-01T10:00:00Z"
+    }
+  ],
+  "ancestry_path": "organizations/1234567899/projects/1234567890",
+  "ancestors": [
+    "projects/1234567890",
+    "organizations/1234567899"
+  ]
+}`
+}
+
+// orgPolicyV2JSON is an orgpolicy.googleapis.com policy on
+// constraints/compute.vmExternalIpAccess with the given rules.
+func orgPolicyV2JSON(rules string) string {
+	return `{
+  "name": "//orgpolicy.googleapis.com/projects/1234567890/policies/compute.vmExternalIpAccess",
+  "asset_type": "orgpolicy.googleapis.com/Policy",
+  "resource": {
+    "version": "v2",
+    "discovery_document_uri": "https://orgpolicy.googleapis.com/$discovery/rest",
+    "discovery_name": "Policy",
+    "parent": "//cloudresourcemanager.googleapis.com/projects/1234567890",
+    "data": {
+      "name": "projects/1234567890/policies/compute.vmExternalIpAccess",
+      "spec": {
+        "rules": ` + rules + `
+      }
+    }
+  },
+  "ancestry_path": "organizations/1234567899/projects/1234567890",
+  "ancestors": [
+    "projects/1234567890",
+    "organizations/1234567899"
+  ]
+}`
+}
+
+// accessContextJSON is an Access Context Manager asset of the given type with the policy
+// stored under field.
+func accessContextJSON(assetType, field, policy string) string {
+	return `{
+  "name": "//accesscontextmanager.googleapis.com/accessPolicies/1",
+  "asset_type": "accesscontextmanager.googleapis.com/` + assetType + `",
+  "` + field + `": ` + policy + `,
+  "ancestry_path": "organizations/1234567899",
+  "ancestors": [
+    "organizations/1234567899"
+  ]
+}`
+}
+
+// sqlInstanceJSON is a Cloud SQL instance of project p, reviewed by the CEL template.
+func sqlInstanceJSON(name string, ipv4Enabled bool) string {
+	return fmt.Sprintf(`{
+  "name": "//cloudsql.googleapis.com/projects/p/instances/%s",
+  "asset_type": "sqladmin.googleapis.com/Instance",
+  "resource": {
+    "version": "v1beta4",
+    "data": {"name": "%s", "settings": {"ipConfiguration": {"ipv4Enabled": %t}}}
+  },
+  "ancestry_path": "organizations/1234567899/projects/1234567890",
+  "ancestors": [
+    "projects/1234567890",
+    "organizations/1234567899"
+  ]
+}`, name, name, ipv4Enabled)
+}
+
 func namespaceAssetWithNoLabel() *validator.Asset {
 	return mustMakeAsset(namespaceAssetWithNoLabelJSON)
 }
@@ -395,7 +1278,7 @@ func mustMakeAsset(assetJSON string) *validator.Asset {
 }
 
 func BenchmarkReviewJSON(b *testing.B) {
-	v, err := NewValidator(testOptions())
+	v, err := NewValidator(testOptions()...)
 	if err != nil {
 		b.Fatal("unexpected error", err)
 	}
@@ -414,7 +1297,7 @@ func BenchmarkReviewJSON(b *testing.B) {
 }
 
 func BenchmarkReviewAsset(b *testing.B) {
-	v, err := NewValidator(testOptions())
+	v, err := NewValidator(testOptions()...)
 	if err != nil {
 		b.Fatal("unexpected error", err)
 	}
@@ -0,0 +1,186 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package gkaudit imports the violations Gatekeeper audit records in the status of the
+// constraints on a cluster, so that on-cluster findings can be reported together with those of
+// reviews of CAI exports.
+package gkaudit
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	asset2 "github.com/forseti-security/config-validator/pkg/asset"
+	"github.com/forseti-security/config-validator/pkg/gcv"
+	"github.com/forseti-security/config-validator/pkg/gcv/configs"
+	"github.com/forseti-security/config-validator/pkg/k8sunwrap"
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// constraintGroup is the API group of Gatekeeper constraints.
+const constraintGroup = "constraints.gatekeeper.sh"
+
+// MetadataKey is the metadata key of imported violations holding the enforcement action and
+// audit timestamp recorded by Gatekeeper.
+const MetadataKey = "gatekeeper_audit"
+
+// Audit holds the violations imported from the constraints of a cluster.
+type Audit struct {
+	// Results are the results of the violating resources, ordered by name. The resources only
+	// hold the identity of the violating objects, which Gatekeeper records without their content.
+	Results []*gcv.Result
+	// Truncated names the constraints which found more violations than their status records,
+	// as Gatekeeper keeps the first --constraint-violations-limit violations only.
+	Truncated []string
+}
+
+// violation is a violation recorded in the status of a constraint.
+type violation struct {
+	EnforcementAction string `json:"enforcementAction"`
+	Group             string `json:"group"`
+	Version           string `json:"version"`
+	Kind              string `json:"kind"`
+	Name              string `json:"name"`
+	Namespace         string `json:"namespace"`
+	Message           string `json:"message"`
+}
+
+// status is the status Gatekeeper audit writes to constraints.
+type status struct {
+	AuditTimestamp  string      `json:"auditTimestamp"`
+	TotalViolations int         `json:"totalViolations"`
+	Violations      []violation `json:"violations"`
+}
+
+// Read imports the constraints in content, YAML or JSON documents as written by
+// `kubectl get constraints -o yaml`, either lists or single constraints. cluster is the CAI name
+// of the audited cluster, such as //container.googleapis.com/projects/p/locations/l/clusters/c,
+// and ancestors are the ancestors of its project, so that violations are reported for the
+// resources and with the fingerprints a review of the CAI export of the cluster reports.
+//
+// Violations recorded by Gatekeeper releases that omit the group and version of the violating
+// object are taken to be of the core v1 API.
+func Read(content []byte, cluster string, ancestors []string) (*Audit, error) {
+	audit := &Audit{}
+	results := map[string]*gcv.Result{}
+	for _, rawDoc := range strings.Split(string(content), "\n---") {
+		document := strings.TrimLeft(rawDoc, "\n ")
+		if len(document) == 0 {
+			continue
+		}
+		jsonDoc, err := yaml.YAMLToJSON([]byte(document))
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid yaml")
+		}
+		obj := map[string]interface{}{}
+		if err := asset2.UnmarshalJSON(jsonDoc, &obj); err != nil {
+			return nil, errors.Wrapf(err, "invalid document")
+		}
+		u := &unstructured.Unstructured{Object: obj}
+		constraints := []*unstructured.Unstructured{u}
+		if u.IsList() {
+			list, err := u.ToList()
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid list")
+			}
+			constraints = nil
+			for idx := range list.Items {
+				constraints = append(constraints, &list.Items[idx])
+			}
+		}
+		for _, constraint := range constraints {
+			if err := audit.add(results, constraint, cluster, ancestors); err != nil {
+				return nil, errors.Wrapf(err, "constraint %s", constraint.GetName())
+			}
+		}
+	}
+	for _, result := range results {
+		audit.Results = append(audit.Results, result)
+	}
+	sort.Slice(audit.Results, func(i, j int) bool {
+		return audit.Results[i].Name < audit.Results[j].Name
+	})
+	return audit, nil
+}
+
+// add adds the violations recorded by constraint to the results by resource name.
+func (a *Audit) add(results map[string]*gcv.Result, constraint *unstructured.Unstructured, cluster string, ancestors []string) error {
+	if group := constraint.GroupVersionKind().Group; group != constraintGroup {
+		return errors.Errorf("got object of group %q, want a constraint of group %s", group, constraintGroup)
+	}
+	rawStatus, found, err := unstructured.NestedMap(constraint.Object, "status")
+	if err != nil || !found {
+		return err
+	}
+	statusJSON, err := json.Marshal(rawStatus)
+	if err != nil {
+		return errors.Wrapf(err, "failed to marshal status")
+	}
+	var s status
+	if err := json.Unmarshal(statusJSON, &s); err != nil {
+		return errors.Wrapf(err, "invalid status")
+	}
+	if s.TotalViolations > len(s.Violations) {
+		a.Truncated = append(a.Truncated, gcv.ConstraintName(constraint))
+	}
+
+	// The constraint is reported as loaded from policy files, without the audit status.
+	constraint = constraint.DeepCopy()
+	unstructured.RemoveNestedField(constraint.Object, "status")
+	severity, _, _ := unstructured.NestedString(constraint.Object, "spec", "severity")
+	remediation, err := configs.ConstraintRemediation(constraint)
+	if err != nil {
+		return err
+	}
+	for _, v := range s.Violations {
+		if v.Kind == "" || v.Name == "" {
+			return errors.Errorf("violation %q does not identify the violating object", v.Message)
+		}
+		gvk := schema.GroupVersionKind{Group: v.Group, Version: v.Version, Kind: v.Kind}
+		if gvk.Version == "" {
+			gvk.Version = "v1"
+		}
+		name := k8sunwrap.AssetName(cluster, gvk, v.Namespace, v.Name)
+		result, found := results[name]
+		if !found {
+			obj := &unstructured.Unstructured{}
+			obj.SetGroupVersionKind(gvk)
+			obj.SetName(v.Name)
+			obj.SetNamespace(v.Namespace)
+			caiResource, err := k8sunwrap.Wrap(name, obj, ancestors)
+			if err != nil {
+				return err
+			}
+			result = &gcv.Result{Name: name, CAIResource: caiResource, ReviewResource: obj.Object}
+			results[name] = result
+		}
+		metadata := map[string]interface{}{"enforcement_action": v.EnforcementAction}
+		if s.AuditTimestamp != "" {
+			metadata["audit_timestamp"] = s.AuditTimestamp
+		}
+		result.ConstraintViolations = append(result.ConstraintViolations, gcv.ConstraintViolation{
+			Message:        v.Message,
+			Metadata:       map[string]interface{}{MetadataKey: metadata},
+			Constraint:     constraint,
+			Severity:       severity,
+			Remediation:    remediation.Text,
+			RemediationURL: remediation.URL,
+		})
+	}
+	return nil
+}
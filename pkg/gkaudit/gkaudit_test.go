@@ -0,0 +1,169 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gkaudit
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/forseti-security/config-validator/pkg/gcv"
+	"github.com/google/go-cmp/cmp"
+)
+
+const (
+	testPolicies = "../../test/cf"
+	testLibs     = "../../test/cf/library"
+	testCluster  = "//container.googleapis.com/projects/malaise-forever/zones/us-central1-a/clusters/test-1"
+)
+
+// namespaceAssetJSON is an unlabeled namespace of the test cluster as exported by CAI.
+const namespaceAssetJSON = `{
+  "name": "//container.googleapis.com/projects/malaise-forever/zones/us-central1-a/clusters/test-1/k8s/namespaces/whatever",
+  "asset_type": "k8s.io/Namespace",
+  "resource": {"version": "v1", "data": {"metadata": {"name": "whatever"}}},
+  "ancestors": ["projects/1234567890", "organizations/1234567899"]
+}`
+
+// auditYAML is the output of kubectl get constraints -o yaml for the test cluster, with the
+// namespace violation message substituted for MESSAGE.
+const auditYAML = `apiVersion: v1
+kind: List
+items:
+- apiVersion: constraints.gatekeeper.sh/v1beta1
+  kind: K8sRequiredLabels
+  metadata:
+    name: namespace-cost-center-label
+  spec:
+    match:
+      kinds:
+      - apiGroups: [""]
+        kinds: ["Namespace"]
+    parameters:
+      labels: ["cost-center"]
+  status:
+    auditTimestamp: "2020-06-01T10:00:00Z"
+    totalViolations: 1
+    violations:
+    - enforcementAction: deny
+      kind: Namespace
+      message: MESSAGE
+      name: whatever
+- apiVersion: constraints.gatekeeper.sh/v1beta1
+  kind: K8sAllowedRepos
+  metadata:
+    name: allowed-repos
+  spec:
+    severity: high
+  status:
+    totalViolations: 3
+    violations:
+    - enforcementAction: dryrun
+      group: apps
+      version: v1
+      kind: Deployment
+      message: container nginx uses a disallowed repo
+      name: web
+      namespace: default
+    - enforcementAction: dryrun
+      group: apps
+      version: v1
+      kind: Deployment
+      message: container sidecar uses a disallowed repo
+      name: web
+      namespace: default
+---
+apiVersion: constraints.gatekeeper.sh/v1beta1
+kind: K8sRequiredProbes
+metadata:
+  name: no-violations
+status:
+  totalViolations: 0
+`
+
+func TestRead(t *testing.T) {
+	ctx := context.Background()
+	v, err := gcv.NewValidator(gcv.WithPolicyPaths(testPolicies), gcv.WithPolicyLibrary(testLibs))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	reviewed, err := v.ReviewJSON(ctx, namespaceAssetJSON)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(reviewed.ConstraintViolations) != 1 {
+		t.Fatalf("got %d violations of the namespace, want 1", len(reviewed.ConstraintViolations))
+	}
+	message := reviewed.ConstraintViolations[0].Message
+
+	quoted, err := json.Marshal(message)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	audit, err := Read([]byte(strings.Replace(auditYAML, "MESSAGE", string(quoted), 1)), testCluster, []string{"projects/1234567890", "organizations/1234567899"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var got []string
+	for _, result := range audit.Results {
+		for _, cv := range result.ConstraintViolations {
+			got = append(got, result.Name+" "+cv.ConstraintName()+" "+cv.Severity+" "+cv.Message)
+		}
+	}
+	want := []string{
+		testCluster + "/k8s/namespaces/default/apps/deployments/web K8sAllowedRepos.allowed-repos high container nginx uses a disallowed repo",
+		testCluster + "/k8s/namespaces/default/apps/deployments/web K8sAllowedRepos.allowed-repos high container sidecar uses a disallowed repo",
+		testCluster + "/k8s/namespaces/whatever K8sRequiredLabels.namespace-cost-center-label  " + message,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected violations (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"K8sAllowedRepos.allowed-repos"}, audit.Truncated); diff != "" {
+		t.Errorf("unexpected truncated constraints (-want +got):\n%s", diff)
+	}
+
+	// The imported violation has the fingerprint of the violation found in the CAI export.
+	imported := audit.Results[1]
+	if got, want := imported.ConstraintViolations[0].Fingerprint(imported.Name), reviewed.ConstraintViolations[0].Fingerprint(reviewed.Name); got != want {
+		t.Errorf("got fingerprint %s, want %s", got, want)
+	}
+	violations, err := imported.ToViolations()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	metadata := violations[0].Metadata.GetStructValue().Fields[MetadataKey].GetStructValue().Fields
+	if metadata["enforcement_action"].GetStringValue() != "deny" || metadata["audit_timestamp"].GetStringValue() != "2020-06-01T10:00:00Z" {
+		t.Errorf("unexpected metadata %v", metadata)
+	}
+	if _, found := imported.ConstraintViolations[0].Constraint.Object["status"]; found {
+		t.Errorf("constraint has status")
+	}
+}
+
+func TestReadInvalid(t *testing.T) {
+	for name, content := range map[string]string{
+		"not a constraint":   "apiVersion: v1\nkind: Namespace\nmetadata:\n  name: x\n",
+		"missing kind":       "apiVersion: constraints.gatekeeper.sh/v1beta1\nkind: K8sRequiredLabels\nstatus:\n  violations:\n  - name: x\n",
+		"invalid yaml":       "apiVersion: [",
+		"invalid violations": "apiVersion: constraints.gatekeeper.sh/v1beta1\nkind: K8sRequiredLabels\nstatus:\n  violations: x\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Read([]byte(content), testCluster, nil); err == nil {
+				t.Errorf("expected error")
+			}
+		})
+	}
+}
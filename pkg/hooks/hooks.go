@@ -0,0 +1,163 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package hooks runs user supplied commands after a review run, passing a JSON summary of the
+// run on stdin and the run metadata in the environment.
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/forseti-security/config-validator/pkg/gcv"
+	"github.com/forseti-security/config-validator/pkg/multierror"
+	"github.com/forseti-security/config-validator/pkg/report"
+	"github.com/pkg/errors"
+)
+
+// Summary describes the outcome of a review run.
+type Summary struct {
+	RunID         string    `json:"run_id"`
+	PolicyVersion string    `json:"policy_version,omitempty"`
+	StartTime     time.Time `json:"start_time"`
+	EndTime       time.Time `json:"end_time"`
+	// Assets is the number of assets reviewed successfully.
+	Assets int `json:"assets"`
+	// Errors is the number of assets that could not be reviewed.
+	Errors int `json:"errors"`
+	// Violations is the total number of violations.
+	Violations   int            `json:"violations"`
+	BySeverity   map[string]int `json:"by_severity"`
+	ByConstraint map[string]int `json:"by_constraint"`
+}
+
+// NewSummary returns an empty summary for a run started now.
+func NewSummary(runID, policyVersion string) *Summary {
+	return &Summary{
+		RunID:         runID,
+		PolicyVersion: policyVersion,
+		StartTime:     time.Now(),
+		BySeverity:    map[string]int{},
+		ByConstraint:  map[string]int{},
+	}
+}
+
+// NewReportSummary returns the summary of the run that produced r, taking the run ID and times
+// from its manifest if set. This covers runs whose report is restored from a checkpoint, which a
+// summary built alongside the report would miss.
+func NewReportSummary(r *report.Report) *Summary {
+	s := NewSummary("", r.PolicyVersion)
+	if r.Manifest != nil {
+		s.RunID, s.StartTime, s.EndTime = r.Manifest.RunID, r.Manifest.StartTime, r.Manifest.EndTime
+	}
+	s.Assets, s.Errors, s.Violations = r.Assets, r.Errors, len(r.Violations)
+	for _, violation := range r.Violations {
+		s.BySeverity[violation.Severity]++
+		s.ByConstraint[violation.Constraint]++
+	}
+	return s
+}
+
+// Add records the violations of a reviewed asset.
+func (s *Summary) Add(result *gcv.Result) {
+	s.Assets++
+	for _, cv := range result.ConstraintViolations {
+		s.Violations++
+		s.BySeverity[cv.Severity]++
+		s.ByConstraint[cv.ConstraintName()]++
+	}
+}
+
+// AddError records an asset that failed review.
+func (s *Summary) AddError() {
+	s.Errors++
+}
+
+// Hook is a command run after a review run. The command is run by the system shell.
+type Hook struct {
+	Command string
+	// Timeout bounds the run time of the command, zero means no limit.
+	Timeout time.Duration
+	// Output optionally receives the combined stdout and stderr of the command.
+	Output io.Writer
+}
+
+// env returns the environment for hooks, the process environment extended with the run metadata.
+func (s *Summary) env() []string {
+	return append(os.Environ(),
+		"GCV_RUN_ID="+s.RunID,
+		"GCV_POLICY_VERSION="+s.PolicyVersion,
+		"GCV_START_TIME="+s.StartTime.UTC().Format(time.RFC3339),
+		"GCV_END_TIME="+s.EndTime.UTC().Format(time.RFC3339),
+		"GCV_ASSETS="+strconv.Itoa(s.Assets),
+		"GCV_ERRORS="+strconv.Itoa(s.Errors),
+		"GCV_VIOLATIONS="+strconv.Itoa(s.Violations),
+	)
+}
+
+// Run runs each hook in order with the summary JSON on stdin. The end time of the summary is set
+// if it is not already. All hooks are run even if one fails, and the failures are returned
+// together with the output of the failing commands.
+func Run(ctx context.Context, summary *Summary, hooks []Hook) error {
+	if len(hooks) == 0 {
+		return nil
+	}
+	if summary.EndTime.IsZero() {
+		summary.EndTime = time.Now()
+	}
+	input, err := json.Marshal(summary)
+	if err != nil {
+		return errors.Wrapf(err, "failed to marshal run summary")
+	}
+
+	var errs multierror.Errors
+	for _, hook := range hooks {
+		if err := hook.run(ctx, input, summary.env()); err != nil {
+			errs.Add(err)
+		}
+	}
+	return errs.ToError()
+}
+
+func (h Hook) run(ctx context.Context, input []byte, env []string) error {
+	if h.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.Timeout)
+		defer cancel()
+	}
+	shell, flag := "/bin/sh", "-c"
+	if runtime.GOOS == "windows" {
+		shell, flag = "cmd", "/C"
+	}
+	cmd := exec.CommandContext(ctx, shell, flag, h.Command)
+	cmd.Env = env
+	cmd.Stdin = bytes.NewReader(input)
+	var output bytes.Buffer
+	var w io.Writer = &output
+	if h.Output != nil {
+		w = io.MultiWriter(&output, h.Output)
+	}
+	cmd.Stdout, cmd.Stderr = w, w
+	if err := cmd.Run(); err != nil {
+		return errors.Wrapf(err, "post-run hook %q failed, output:\n%s", h.Command, output.String())
+	}
+	return nil
+}
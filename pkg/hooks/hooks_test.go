@@ -0,0 +1,145 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hooks
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/forseti-security/config-validator/pkg/gcv"
+	"github.com/forseti-security/config-validator/pkg/report"
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func testSummary() *Summary {
+	constraint := &unstructured.Unstructured{}
+	constraint.SetKind("GCPStorageLoggingConstraint")
+	constraint.SetName("require-storage-logging")
+	summary := NewSummary("run-1", "abc123")
+	summary.Add(&gcv.Result{ConstraintViolations: []gcv.ConstraintViolation{
+		{Message: "no logging", Constraint: constraint, Severity: "high"},
+	}})
+	summary.Add(&gcv.Result{})
+	summary.AddError()
+	return summary
+}
+
+func TestRun(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test uses a POSIX shell")
+	}
+	tmpDir, err := ioutil.TempDir("", "hooksTest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	stdinFile := filepath.Join(tmpDir, "stdin.json")
+	envFile := filepath.Join(tmpDir, "env")
+
+	hooks := []Hook{
+		{Command: "cat > " + stdinFile},
+		{Command: "echo $GCV_RUN_ID $GCV_ASSETS $GCV_VIOLATIONS $GCV_ERRORS > " + envFile},
+	}
+	if err := Run(context.Background(), testSummary(), hooks); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	stdin, err := ioutil.ReadFile(stdinFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := &Summary{}
+	if err := json.Unmarshal(stdin, got); err != nil {
+		t.Fatalf("hook input is not a summary: %v", err)
+	}
+	want := testSummary()
+	if diff := cmp.Diff(got.ByConstraint, want.ByConstraint); diff != "" {
+		t.Errorf("by constraint mismatch, +got -want\n%s", diff)
+	}
+	if got.Assets != 2 || got.Errors != 1 || got.BySeverity["high"] != 1 || got.EndTime.IsZero() {
+		t.Errorf("unexpected summary %+v", got)
+	}
+
+	env, err := ioutil.ReadFile(envFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(env) != "run-1 2 1 1\n" {
+		t.Errorf("got hook environment %q", env)
+	}
+}
+
+func TestRunFailures(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test uses a POSIX shell")
+	}
+	tmpDir, err := ioutil.TempDir("", "hooksTest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	marker := filepath.Join(tmpDir, "ran")
+
+	hooks := []Hook{
+		{Command: "echo failing >&2; exit 3"},
+		{Command: "exec sleep 5", Timeout: 50 * time.Millisecond},
+		{Command: "touch " + marker},
+	}
+	if err := Run(context.Background(), testSummary(), hooks); err == nil {
+		t.Errorf("expected error from failing hooks")
+	}
+	if _, err := os.Stat(marker); err != nil {
+		t.Errorf("hooks after a failure should still run: %v", err)
+	}
+}
+
+func TestNewReportSummary(t *testing.T) {
+	start := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	r := &report.Report{
+		PolicyVersion: "abc123",
+		Manifest:      &report.Manifest{RunID: "run-1", StartTime: start, EndTime: start.Add(time.Minute)},
+		Assets:        3,
+		Errors:        1,
+		Violations: []*report.Violation{
+			{Constraint: "GCPStorageLoggingConstraint.require-storage-logging", Severity: "high"},
+			{Constraint: "GCPStorageLoggingConstraint.require-storage-logging", Severity: "high"},
+			{Constraint: "GCPAllowedRegionsConstraint.regions", Severity: "low"},
+		},
+	}
+	want := &Summary{
+		RunID:         "run-1",
+		PolicyVersion: "abc123",
+		StartTime:     start,
+		EndTime:       start.Add(time.Minute),
+		Assets:        3,
+		Errors:        1,
+		Violations:    3,
+		BySeverity:    map[string]int{"high": 2, "low": 1},
+		ByConstraint: map[string]int{
+			"GCPStorageLoggingConstraint.require-storage-logging": 2,
+			"GCPAllowedRegionsConstraint.regions":                 1,
+		},
+	}
+	if diff := cmp.Diff(NewReportSummary(r), want); diff != "" {
+		t.Errorf("summary mismatch, +got -want\n%s", diff)
+	}
+}
@@ -0,0 +1,263 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package iammembers expands the group members of IAM policies into their effective members so
+// that constraints can check who is granted a role rather than which groups are.
+//
+// Each binding of an asset's iam_policy gains an expanded_members list holding the members of
+// the binding with groups replaced by their transitive members:
+//
+//   "bindings": [{
+//     "role": "roles/owner",
+//     "members": ["group:admins@example.com"],
+//     "expanded_members": ["user:alice@example.com", "user:bob@other.com"]
+//   }]
+//
+// Groups that cannot be looked up, for example because they belong to another customer, are
+// kept as they are.
+package iammembers
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	cloudidentity "google.golang.org/api/cloudidentity/v1"
+	"google.golang.org/api/googleapi"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// ExpandedMembersField is the field of each binding holding the expanded members.
+const ExpandedMembersField = "expanded_members"
+
+// defaultTTL is how long group memberships are cached by default.
+const defaultTTL = 10 * time.Minute
+
+const (
+	groupPrefix          = "group:"
+	userPrefix           = "user:"
+	serviceAccountPrefix = "serviceAccount:"
+	serviceAccountSuffix = ".gserviceaccount.com"
+)
+
+// Groups looks up the direct members of groups.
+type Groups interface {
+	// Members returns the emails of the direct members of the group with the given email. It
+	// returns false if email is not a group that can be looked up.
+	Members(ctx context.Context, email string) ([]string, bool, error)
+}
+
+// Option configures optional Expander behavior.
+type Option func(*Expander)
+
+// WithTTL sets how long the members of a group are cached, defaults to 10 minutes.
+func WithTTL(ttl time.Duration) Option {
+	return func(e *Expander) {
+		e.ttl = ttl
+	}
+}
+
+// Expander expands the group members of IAM policies. It is safe for concurrent use.
+type Expander struct {
+	groups Groups
+	ttl    time.Duration
+	now    func() time.Time
+
+	mu    sync.Mutex
+	cache map[string]*cacheEntry
+}
+
+// cacheEntry holds the direct members of a group.
+type cacheEntry struct {
+	members []string
+	isGroup bool
+	expires time.Time
+}
+
+// NewExpander returns an Expander looking up group members with groups.
+func NewExpander(groups Groups, opts ...Option) *Expander {
+	e := &Expander{
+		groups: groups,
+		ttl:    defaultTTL,
+		now:    time.Now,
+		cache:  map[string]*cacheEntry{},
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// NewCloudIdentityExpander returns an Expander looking up group members with the Cloud Identity
+// API. The service requires the cloud-identity.groups.readonly scope.
+func NewCloudIdentityExpander(service *cloudidentity.Service, opts ...Option) *Expander {
+	return NewExpander(&cloudIdentityGroups{service: service}, opts...)
+}
+
+// Enrich returns a copy of asset with the members of each IAM policy binding expanded. Assets
+// without an IAM policy are returned unchanged.
+func (e *Expander) Enrich(ctx context.Context, asset map[string]interface{}) (map[string]interface{}, error) {
+	bindings, found, err := unstructured.NestedSlice(asset, "iam_policy", "bindings")
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid iam_policy.bindings")
+	}
+	if !found {
+		return asset, nil
+	}
+
+	result := runtime.DeepCopyJSON(asset)
+	for idx, b := range bindings {
+		binding, ok := b.(map[string]interface{})
+		if !ok {
+			return nil, errors.Errorf("binding %d is not an object", idx)
+		}
+		members, _, err := unstructured.NestedStringSlice(binding, "members")
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid members of binding %d", idx)
+		}
+		expanded, err := e.Expand(ctx, members)
+		if err != nil {
+			return nil, err
+		}
+		expandedIface := make([]interface{}, len(expanded))
+		for i, member := range expanded {
+			expandedIface[i] = member
+		}
+		binding[ExpandedMembersField] = expandedIface
+	}
+	if err := unstructured.SetNestedSlice(result, bindings, "iam_policy", "bindings"); err != nil {
+		return nil, errors.Wrapf(err, "failed to set iam_policy.bindings")
+	}
+	return result, nil
+}
+
+// Expand returns the sorted, deduplicated IAM members with groups replaced by their transitive
+// members.
+func (e *Expander) Expand(ctx context.Context, members []string) ([]string, error) {
+	seen := map[string]bool{}
+	visited := map[string]bool{}
+	for _, member := range members {
+		if !strings.HasPrefix(member, groupPrefix) {
+			seen[member] = true
+			continue
+		}
+		if err := e.expandGroup(ctx, strings.TrimPrefix(member, groupPrefix), visited, seen); err != nil {
+			return nil, err
+		}
+	}
+	expanded := make([]string, 0, len(seen))
+	for member := range seen {
+		expanded = append(expanded, member)
+	}
+	sort.Strings(expanded)
+	return expanded, nil
+}
+
+// expandGroup adds the transitive members of the group email to seen. Groups already in visited
+// are skipped, which stops expansion of membership cycles.
+func (e *Expander) expandGroup(ctx context.Context, email string, visited, seen map[string]bool) error {
+	if visited[email] {
+		return nil
+	}
+	visited[email] = true
+
+	entry, err := e.lookup(ctx, email)
+	if err != nil {
+		return err
+	}
+	if !entry.isGroup {
+		seen[groupPrefix+email] = true
+		return nil
+	}
+	for _, member := range entry.members {
+		memberEntry, err := e.lookup(ctx, member)
+		if err != nil {
+			return err
+		}
+		if memberEntry.isGroup {
+			if err := e.expandGroup(ctx, member, visited, seen); err != nil {
+				return err
+			}
+			continue
+		}
+		seen[memberName(member)] = true
+	}
+	return nil
+}
+
+// lookup returns the cached members of the group email, looking them up if needed.
+func (e *Expander) lookup(ctx context.Context, email string) (*cacheEntry, error) {
+	e.mu.Lock()
+	entry, found := e.cache[email]
+	e.mu.Unlock()
+	if found && e.now().Before(entry.expires) {
+		return entry, nil
+	}
+
+	members, isGroup, err := e.groups.Members(ctx, email)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to look up members of %s", email)
+	}
+	entry = &cacheEntry{members: members, isGroup: isGroup, expires: e.now().Add(e.ttl)}
+	e.mu.Lock()
+	e.cache[email] = entry
+	e.mu.Unlock()
+	return entry, nil
+}
+
+// memberName returns the IAM member for the email of a group member that is not a group.
+func memberName(email string) string {
+	if strings.HasSuffix(email, serviceAccountSuffix) {
+		return serviceAccountPrefix + email
+	}
+	return userPrefix + email
+}
+
+// cloudIdentityGroups looks up groups with the Cloud Identity API.
+type cloudIdentityGroups struct {
+	service *cloudidentity.Service
+}
+
+var _ Groups = &cloudIdentityGroups{}
+
+// Members implements Groups.
+func (g *cloudIdentityGroups) Members(ctx context.Context, email string) ([]string, bool, error) {
+	lookup, err := g.service.Groups.Lookup().GroupKeyId(email).Context(ctx).Do()
+	if err != nil {
+		// Users, and groups of other customers, are not found or not visible.
+		if apiErr, ok := err.(*googleapi.Error); ok && (apiErr.Code == http.StatusNotFound || apiErr.Code == http.StatusForbidden) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+
+	var members []string
+	err = g.service.Groups.Memberships.List(lookup.Name).Pages(ctx, func(resp *cloudidentity.ListMembershipsResponse) error {
+		for _, membership := range resp.Memberships {
+			if membership.PreferredMemberKey != nil && membership.PreferredMemberKey.Id != "" {
+				members = append(members, membership.PreferredMemberKey.Id)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, false, errors.Wrapf(err, "failed to list memberships of %s", lookup.Name)
+	}
+	return members, true, nil
+}
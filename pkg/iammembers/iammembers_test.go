@@ -0,0 +1,228 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iammembers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	cloudidentity "google.golang.org/api/cloudidentity/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// fakeGroups serves group members from a map and counts lookups.
+type fakeGroups struct {
+	members map[string][]string
+	lookups map[string]int
+	err     error
+}
+
+func newFakeGroups(members map[string][]string) *fakeGroups {
+	return &fakeGroups{members: members, lookups: map[string]int{}}
+}
+
+func (f *fakeGroups) Members(ctx context.Context, email string) ([]string, bool, error) {
+	f.lookups[email]++
+	if f.err != nil {
+		return nil, false, f.err
+	}
+	members, found := f.members[email]
+	return members, found, nil
+}
+
+var testGroups = map[string][]string{
+	"admins@example.com":  {"alice@example.com", "ops@example.com"},
+	"ops@example.com":     {"bob@other.com", "deploy@p.iam.gserviceaccount.com", "admins@example.com"},
+	"empty@example.com":   nil,
+	"readers@example.com": {"carol@example.com"},
+}
+
+func TestExpand(t *testing.T) {
+	var testCases = []struct {
+		name    string
+		members []string
+		want    []string
+	}{
+		{
+			name:    "nested groups with cycle",
+			members: []string{"group:admins@example.com"},
+			want: []string{
+				"serviceAccount:deploy@p.iam.gserviceaccount.com",
+				"user:alice@example.com",
+				"user:bob@other.com",
+			},
+		},
+		{
+			name:    "duplicates removed",
+			members: []string{"user:alice@example.com", "group:readers@example.com", "group:admins@example.com"},
+			want: []string{
+				"serviceAccount:deploy@p.iam.gserviceaccount.com",
+				"user:alice@example.com",
+				"user:bob@other.com",
+				"user:carol@example.com",
+			},
+		},
+		{
+			name:    "unknown group kept",
+			members: []string{"group:partners@other.com", "allUsers"},
+			want:    []string{"allUsers", "group:partners@other.com"},
+		},
+		{
+			name:    "empty group",
+			members: []string{"group:empty@example.com"},
+			want:    []string{},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := NewExpander(newFakeGroups(testGroups)).Expand(context.Background(), tc.members)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected members (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestExpandCache(t *testing.T) {
+	groups := newFakeGroups(testGroups)
+	now := time.Unix(0, 0)
+	expander := NewExpander(groups, WithTTL(time.Minute))
+	expander.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if _, err := expander.Expand(context.Background(), []string{"group:readers@example.com"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if got := groups.lookups["readers@example.com"]; got != 1 {
+		t.Errorf("got %d lookups within TTL, want 1", got)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, err := expander.Expand(context.Background(), []string{"group:readers@example.com"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := groups.lookups["readers@example.com"]; got != 2 {
+		t.Errorf("got %d lookups after TTL, want 2", got)
+	}
+}
+
+func TestEnrich(t *testing.T) {
+	var asset map[string]interface{}
+	if err := json.Unmarshal([]byte(`{
+  "name": "//cloudresourcemanager.googleapis.com/projects/p",
+  "asset_type": "cloudresourcemanager.googleapis.com/Project",
+  "iam_policy": {
+    "bindings": [
+      {"role": "roles/owner", "members": ["group:readers@example.com"]},
+      {"role": "roles/viewer", "members": ["user:dave@example.com"]}
+    ]
+  }
+}`), &asset); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := NewExpander(newFakeGroups(testGroups)).Enrich(context.Background(), asset)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	bindings, _, _ := unstructured.NestedSlice(got, "iam_policy", "bindings")
+	var expanded [][]interface{}
+	for _, binding := range bindings {
+		expanded = append(expanded, binding.(map[string]interface{})[ExpandedMembersField].([]interface{}))
+	}
+	want := [][]interface{}{{"user:carol@example.com"}, {"user:dave@example.com"}}
+	if diff := cmp.Diff(want, expanded); diff != "" {
+		t.Errorf("unexpected expanded members (-want +got):\n%s", diff)
+	}
+	if original, _, _ := unstructured.NestedSlice(asset, "iam_policy", "bindings"); original[0].(map[string]interface{})[ExpandedMembersField] != nil {
+		t.Errorf("Enrich modified the asset")
+	}
+
+	resource := map[string]interface{}{"name": "//storage.googleapis.com/b", "resource": map[string]interface{}{}}
+	if got, err := NewExpander(newFakeGroups(nil)).Enrich(context.Background(), resource); err != nil || !cmp.Equal(resource, got) {
+		t.Errorf("got %v, %v, want asset without IAM policy unchanged", got, err)
+	}
+}
+
+func TestEnrichError(t *testing.T) {
+	groups := newFakeGroups(nil)
+	groups.err = errors.New("quota exceeded")
+	asset := map[string]interface{}{
+		"iam_policy": map[string]interface{}{
+			"bindings": []interface{}{
+				map[string]interface{}{"role": "roles/owner", "members": []interface{}{"group:admins@example.com"}},
+			},
+		},
+	}
+	_, err := NewExpander(groups).Enrich(context.Background(), asset)
+	if err == nil || !strings.Contains(err.Error(), "quota exceeded") {
+		t.Errorf("got error %v, want lookup error", err)
+	}
+}
+
+func TestCloudIdentityGroups(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v1/groups:lookup" && r.URL.Query().Get("groupKey.id") == "admins@example.com":
+			_ = json.NewEncoder(w).Encode(&cloudidentity.LookupGroupNameResponse{Name: "groups/123"})
+		case r.URL.Path == "/v1/groups:lookup":
+			http.Error(w, `{"error": {"code": 404, "message": "not found"}}`, http.StatusNotFound)
+		case r.URL.Path == "/v1/groups/123/memberships" && r.URL.Query().Get("pageToken") == "":
+			_ = json.NewEncoder(w).Encode(&cloudidentity.ListMembershipsResponse{
+				Memberships: []*cloudidentity.Membership{
+					{PreferredMemberKey: &cloudidentity.EntityKey{Id: "alice@example.com"}},
+				},
+				NextPageToken: "next",
+			})
+		case r.URL.Path == "/v1/groups/123/memberships":
+			_ = json.NewEncoder(w).Encode(&cloudidentity.ListMembershipsResponse{
+				Memberships: []*cloudidentity.Membership{
+					{PreferredMemberKey: &cloudidentity.EntityKey{Id: "bob@example.com"}},
+				},
+			})
+		default:
+			http.Error(w, "unexpected request "+r.URL.String(), http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+	service, err := cloudidentity.New(server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.BasePath = server.URL + "/"
+	groups := &cloudIdentityGroups{service: service}
+
+	members, isGroup, err := groups.Members(context.Background(), "admins@example.com")
+	if err != nil || !isGroup {
+		t.Fatalf("got %v, %v, want group", isGroup, err)
+	}
+	if diff := cmp.Diff([]string{"alice@example.com", "bob@example.com"}, members); diff != "" {
+		t.Errorf("unexpected members (-want +got):\n%s", diff)
+	}
+	if _, isGroup, err := groups.Members(context.Background(), "alice@example.com"); err != nil || isGroup {
+		t.Errorf("got %v, %v, want no group for user", isGroup, err)
+	}
+}
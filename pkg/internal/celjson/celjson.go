@@ -0,0 +1,46 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package celjson converts values decoded from JSON to the representation CEL programs evaluate.
+package celjson
+
+import "encoding/json"
+
+// Value returns a copy of a decoded JSON value with json.Number replaced by the int64 or float64
+// CEL expects.
+func Value(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for key, value := range t {
+			m[key] = Value(value)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(t))
+		for idx, value := range t {
+			l[idx] = Value(value)
+		}
+		return l
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return i
+		}
+		if f, err := t.Float64(); err == nil {
+			return f
+		}
+		return string(t)
+	}
+	return v
+}
@@ -0,0 +1,43 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package celjson
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestValue(t *testing.T) {
+	input := map[string]interface{}{
+		"int":   json.Number("9007199254740993"),
+		"float": json.Number("1.5"),
+		"list":  []interface{}{json.Number("1"), "two", nil},
+		"huge":  json.Number("1e400"),
+	}
+	want := map[string]interface{}{
+		"int":   int64(9007199254740993),
+		"float": 1.5,
+		"list":  []interface{}{int64(1), "two", nil},
+		"huge":  "1e400",
+	}
+	if diff := cmp.Diff(want, Value(input)); diff != "" {
+		t.Errorf("unexpected value (-want +got):\n%s", diff)
+	}
+	if _, ok := input["int"].(json.Number); !ok {
+		t.Errorf("Value changed its input")
+	}
+}
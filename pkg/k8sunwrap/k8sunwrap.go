@@ -0,0 +1,323 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package k8sunwrap converts between Kubernetes resources exported by CAI from GKE clusters and
+// the plain Kubernetes objects they describe.
+package k8sunwrap
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	asset2 "github.com/forseti-security/config-validator/pkg/asset"
+	"github.com/pkg/errors"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// AncestorPathAnnotation is set on unwrapped resources to the ancestry path of the CAI asset.
+const AncestorPathAnnotation = "validator.forsetisecurity.org/ancestorPath"
+
+// coreGroup is the group CAI reports for resources in the Kubernetes core API group.
+const coreGroup = "k8s.io"
+
+// caiGroups maps the groups CAI reports for built in Kubernetes API groups without a domain to
+// the group used by the Kubernetes API. Groups not listed here, including those of custom
+// resources, are reported by CAI as served by the cluster.
+var caiGroups = map[string]string{
+	coreGroup:            "",
+	"apps.k8s.io":        "apps",
+	"autoscaling.k8s.io": "autoscaling",
+	"batch.k8s.io":       "batch",
+	"extensions.k8s.io":  "extensions",
+	"policy.k8s.io":      "policy",
+}
+
+// k8s assset names will follow pattern:
+// //container.googleapis.com/projects/*/(locations|zones)/*/clusters/*/k8s
+var assetPath = regexp.MustCompile(`^//container.googleapis.com/projects/[^/]*/(locations|zones)/[^/]*/clusters/[^/]*/k8s`)
+
+// IsK8S returns true if the CAI asset is an asset from a kubernetes cluster.
+func IsK8S(asset map[string]interface{}) bool {
+	assetName, found, err := unstructured.NestedString(asset, "name")
+	if !found || err != nil {
+		return false
+	}
+	return assetPath.MatchString(assetName)
+}
+
+// Group returns the Kubernetes API group for the group of a CAI asset type.
+func Group(caiGroup string) string {
+	if group, found := caiGroups[caiGroup]; found {
+		return group
+	}
+	return caiGroup
+}
+
+// CAIGroup returns the group CAI uses in asset types for a Kubernetes API group.
+func CAIGroup(group string) string {
+	for caiGroup, k8sGroup := range caiGroups {
+		if k8sGroup == group {
+			return caiGroup
+		}
+	}
+	return group
+}
+
+// irregularResources maps the kinds whose resource name is not derived by pluralizing the kind.
+var irregularResources = map[string]string{
+	"Endpoints": "endpoints",
+}
+
+// resourceName returns the lower case plural resource name of the Kubernetes API for kind.
+func resourceName(kind string) string {
+	if resource, found := irregularResources[kind]; found {
+		return resource
+	}
+	resource := strings.ToLower(kind)
+	switch {
+	case strings.HasSuffix(resource, "s"), strings.HasSuffix(resource, "x"),
+		strings.HasSuffix(resource, "ch"), strings.HasSuffix(resource, "sh"):
+		return resource + "es"
+	case len(resource) > 1 && strings.HasSuffix(resource, "y") && !strings.ContainsRune("aeiou", rune(resource[len(resource)-2])):
+		return resource[:len(resource)-1] + "ies"
+	}
+	return resource + "s"
+}
+
+// AssetName returns the name CAI gives the asset of the Kubernetes object of kind gvk with the
+// given namespace, empty for cluster scoped objects, and name in the cluster with the CAI name
+// cluster, such as //container.googleapis.com/projects/p/locations/us-central1/clusters/c.
+func AssetName(cluster string, gvk schema.GroupVersionKind, namespace, name string) string {
+	parts := []string{strings.TrimSuffix(cluster, "/"), "k8s"}
+	if namespace != "" {
+		parts = append(parts, "namespaces", namespace)
+	}
+	if gvk.Group != "" {
+		parts = append(parts, gvk.Group)
+	}
+	return strings.Join(append(parts, resourceName(gvk.Kind), name), "/")
+}
+
+// Unwrap will unwrap a K8S resource from the CAI payload and populate any omitted fields. The
+// group and version are taken from the apiVersion of the resource data when present, which is
+// the case for custom resources, and derived from the asset type and resource version otherwise.
+// Custom resources of any schema are unwrapped, the resource version may be omitted if the data
+// sets its apiVersion. The managedFields of the metadata are dropped.
+func Unwrap(asset map[string]interface{}) (*unstructured.Unstructured, error) {
+	gvk, err := groupVersionKind(asset)
+	if err != nil {
+		return nil, err
+	}
+
+	resource, found, err := unstructured.NestedMap(asset, "resource", "data")
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to access resource.data field")
+	}
+	if !found {
+		return nil, errors.Errorf("resource.data field not found")
+	}
+
+	u := &unstructured.Unstructured{Object: resource}
+	if apiVersion := u.GetAPIVersion(); apiVersion != "" {
+		gv, err := schema.ParseGroupVersion(apiVersion)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid apiVersion %q in resource.data", apiVersion)
+		}
+		gvk.Group, gvk.Version = gv.Group, gv.Version
+	}
+	if gvk.Version == "" {
+		return nil, errors.Errorf("resource.version field not found and resource.data sets no apiVersion")
+	}
+	if kind := u.GetKind(); kind != "" && kind != gvk.Kind {
+		return nil, errors.Errorf("resource.data kind %s does not match asset type kind %s", kind, gvk.Kind)
+	}
+	u.SetGroupVersionKind(gvk)
+	// Server side apply bookkeeping is noise to policies, and large.
+	unstructured.RemoveNestedField(u.Object, "metadata", "managedFields")
+
+	ancestors, found, err := unstructured.NestedStringSlice(asset, "ancestors")
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to access ancestors field")
+	}
+	if !found {
+		return nil, errors.Errorf("ancestors field not found")
+	}
+
+	annotations := u.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[AncestorPathAnnotation] = asset2.AncestryPath(ancestors)
+	u.SetAnnotations(annotations)
+
+	return u, nil
+}
+
+// groupVersionKind derives the group, version and kind of a CAI asset from its asset_type and
+// resource.version fields.
+func groupVersionKind(asset map[string]interface{}) (schema.GroupVersionKind, error) {
+	groupKind, found, err := unstructured.NestedString(asset, "asset_type")
+	if err != nil {
+		return schema.GroupVersionKind{}, errors.Wrapf(err, "failed to access asset_type field")
+	}
+	if !found {
+		return schema.GroupVersionKind{}, errors.Errorf("asset_type field not found")
+	}
+	idx := strings.LastIndex(groupKind, "/")
+	if idx <= 0 || idx == len(groupKind)-1 {
+		return schema.GroupVersionKind{}, errors.Errorf(
+			"expected asset_type to be of form \"<group>/<kind>\", got %s", groupKind)
+	}
+
+	// Custom resources may only declare their version in the apiVersion of resource.data, which
+	// Unwrap checks for.
+	version, _, err := unstructured.NestedString(asset, "resource", "version")
+	if err != nil {
+		return schema.GroupVersionKind{}, errors.Wrapf(err, "failed to access resource.version field")
+	}
+
+	gvk := schema.GroupVersionKind{
+		Group:   Group(groupKind[:idx]),
+		Version: version,
+		Kind:    groupKind[idx+1:],
+	}
+	// The version may be qualified with the group, as in apiVersion.
+	if strings.Contains(version, "/") {
+		gv, err := schema.ParseGroupVersion(version)
+		if err != nil {
+			return schema.GroupVersionKind{}, errors.Wrapf(err, "invalid resource.version %q", version)
+		}
+		gvk.Group, gvk.Version = gv.Group, gv.Version
+	}
+	return gvk, nil
+}
+
+// UnwrapList unwraps a K8S resource from the CAI payload the same way as Unwrap, and if it is a
+// list, returns its items. Items inherit the ancestry annotation of the list, and the group,
+// version and kind of the list if they do not set their own. Resources that are not lists are
+// returned as the only element.
+func UnwrapList(asset map[string]interface{}) ([]*unstructured.Unstructured, error) {
+	u, err := Unwrap(asset)
+	if err != nil {
+		return nil, err
+	}
+	if !u.IsList() {
+		return []*unstructured.Unstructured{u}, nil
+	}
+	return Items(u)
+}
+
+// Items returns the items of u, a list unwrapped by Unwrap, set up as UnwrapList does.
+func Items(u *unstructured.Unstructured) ([]*unstructured.Unstructured, error) {
+	list, err := u.ToList()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to convert %s to list", u.GetKind())
+	}
+	listGVK := u.GroupVersionKind()
+	itemGVK := listGVK
+	itemGVK.Kind = strings.TrimSuffix(listGVK.Kind, "List")
+	ancestorPath := u.GetAnnotations()[AncestorPathAnnotation]
+
+	items := make([]*unstructured.Unstructured, 0, len(list.Items))
+	for idx := range list.Items {
+		item := &list.Items[idx]
+		if item.GetKind() == "" {
+			if itemGVK.Kind == "" {
+				return nil, errors.Errorf("item %d of %s does not set its kind", idx, listGVK.Kind)
+			}
+			item.SetGroupVersionKind(itemGVK)
+		} else if item.GetAPIVersion() == "" {
+			item.SetAPIVersion(listGVK.GroupVersion().String())
+		}
+		annotations := item.GetAnnotations()
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[AncestorPathAnnotation] = ancestorPath
+		item.SetAnnotations(annotations)
+		unstructured.RemoveNestedField(item.Object, "metadata", "managedFields")
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// Wrap is the inverse of Unwrap, it returns the CAI asset for the K8S resource u with the given
+// asset name and ancestors. The ancestry annotation set by Unwrap is removed.
+func Wrap(name string, u *unstructured.Unstructured, ancestors []string) (map[string]interface{}, error) {
+	gvk := u.GroupVersionKind()
+	if gvk.Kind == "" || gvk.Version == "" {
+		return nil, errors.Errorf("resource %s must set apiVersion and kind", u.GetName())
+	}
+
+	data := u.DeepCopy()
+	annotations := data.GetAnnotations()
+	delete(annotations, AncestorPathAnnotation)
+	if len(annotations) == 0 {
+		unstructured.RemoveNestedField(data.Object, "metadata", "annotations")
+	} else {
+		data.SetAnnotations(annotations)
+	}
+	// CAI does not export apiVersion and kind for built in resources.
+	unstructured.RemoveNestedField(data.Object, "apiVersion")
+	unstructured.RemoveNestedField(data.Object, "kind")
+
+	ancestorsIface := make([]interface{}, len(ancestors))
+	for idx, ancestor := range ancestors {
+		ancestorsIface[idx] = ancestor
+	}
+	return map[string]interface{}{
+		"name":          name,
+		"asset_type":    CAIGroup(gvk.Group) + "/" + gvk.Kind,
+		"ancestors":     ancestorsIface,
+		"ancestry_path": asset2.AncestryPath(ancestors),
+		"resource": map[string]interface{}{
+			"version": gvk.Version,
+			"data":    data.Object,
+		},
+	}, nil
+}
+
+// ConvertToAdmissionRequest converts a CAI asset containing a K8S type to an AdmissionRequest which is the format that
+// the Gatekeeper Constraint Framework target expects.
+func ConvertToAdmissionRequest(asset map[string]interface{}) (*admissionv1beta1.AdmissionRequest, error) {
+	resource, err := Unwrap(asset)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to unwrap k8s resource from CAI asset")
+	}
+
+	resourceJSON, err := json.Marshal(resource.Object)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to convert unwrapped resource to JSON")
+	}
+
+	gvk := resource.GroupVersionKind()
+	req := &admissionv1beta1.AdmissionRequest{
+		Kind: metav1.GroupVersionKind{
+			Group:   gvk.Group,
+			Version: gvk.Version,
+			Kind:    gvk.Kind,
+		},
+		Object: runtime.RawExtension{
+			Raw: resourceJSON,
+		},
+		Name:      resource.GetName(),
+		Namespace: resource.GetNamespace(),
+	}
+	return req, nil
+}
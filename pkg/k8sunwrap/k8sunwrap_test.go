@@ -0,0 +1,371 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8sunwrap
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"testing"
+
+	asset2 "github.com/forseti-security/config-validator/pkg/asset"
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	benchmarkExport = "../../test/cai/assets.json"
+	clusterName     = "//container.googleapis.com/projects/p/zones/us-central1-a/clusters/c/k8s"
+)
+
+var testAncestors = []string{"projects/3", "organizations/1"}
+
+func mustParse(t *testing.T, data string) map[string]interface{} {
+	obj := map[string]interface{}{}
+	if err := asset2.UnmarshalJSON([]byte(data), &obj); err != nil {
+		t.Fatalf("failed to parse %s: %v", data, err)
+	}
+	return obj
+}
+
+func TestUnwrap(t *testing.T) {
+	var testCases = []struct {
+		name      string
+		asset     string
+		wantGVK   schema.GroupVersionKind
+		wantError bool
+	}{
+		{
+			name:    "core",
+			asset:   `{"asset_type":"k8s.io/Namespace","ancestors":["projects/3","organizations/1"],"resource":{"version":"v1","data":{"metadata":{"name":"ns"}}}}`,
+			wantGVK: schema.GroupVersionKind{Version: "v1", Kind: "Namespace"},
+		},
+		{
+			name:    "legacy group",
+			asset:   `{"asset_type":"apps.k8s.io/Deployment","ancestors":["projects/3","organizations/1"],"resource":{"version":"v1","data":{"metadata":{"name":"d"}}}}`,
+			wantGVK: schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"},
+		},
+		{
+			name:    "extensions group",
+			asset:   `{"asset_type":"extensions.k8s.io/Ingress","ancestors":["projects/3","organizations/1"],"resource":{"version":"v1beta1","data":{"metadata":{"name":"i"}}}}`,
+			wantGVK: schema.GroupVersionKind{Group: "extensions", Version: "v1beta1", Kind: "Ingress"},
+		},
+		{
+			name:    "domain group",
+			asset:   `{"asset_type":"rbac.authorization.k8s.io/ClusterRole","ancestors":["projects/3","organizations/1"],"resource":{"version":"v1","data":{"metadata":{"name":"r"}}}}`,
+			wantGVK: schema.GroupVersionKind{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "ClusterRole"},
+		},
+		{
+			name:    "group qualified version",
+			asset:   `{"asset_type":"networking.k8s.io/NetworkPolicy","ancestors":["projects/3","organizations/1"],"resource":{"version":"networking.k8s.io/v1","data":{"metadata":{"name":"n"}}}}`,
+			wantGVK: schema.GroupVersionKind{Group: "networking.k8s.io", Version: "v1", Kind: "NetworkPolicy"},
+		},
+		{
+			name:    "custom resource",
+			asset:   `{"asset_type":"cloud.google.com/BackendConfig","ancestors":["projects/3","organizations/1"],"resource":{"version":"v1","data":{"apiVersion":"cloud.google.com/v1beta1","kind":"BackendConfig","metadata":{"name":"b"}}}}`,
+			wantGVK: schema.GroupVersionKind{Group: "cloud.google.com", Version: "v1beta1", Kind: "BackendConfig"},
+		},
+		{
+			name:    "custom resource without version",
+			asset:   `{"asset_type":"example.com/Widget","ancestors":["projects/3","organizations/1"],"resource":{"data":{"apiVersion":"example.com/v1alpha1","kind":"Widget","metadata":{"name":"w"}}}}`,
+			wantGVK: schema.GroupVersionKind{Group: "example.com", Version: "v1alpha1", Kind: "Widget"},
+		},
+		{
+			name:      "mismatched kind",
+			asset:     `{"asset_type":"k8s.io/Pod","ancestors":["projects/3","organizations/1"],"resource":{"version":"v1","data":{"kind":"Namespace"}}}`,
+			wantError: true,
+		},
+		{
+			name:      "invalid asset type",
+			asset:     `{"asset_type":"Namespace","ancestors":["projects/3","organizations/1"],"resource":{"version":"v1","data":{}}}`,
+			wantError: true,
+		},
+		{
+			name:      "missing version",
+			asset:     `{"asset_type":"k8s.io/Namespace","ancestors":["projects/3","organizations/1"],"resource":{"data":{}}}`,
+			wantError: true,
+		},
+		{
+			name:      "missing data",
+			asset:     `{"asset_type":"k8s.io/Namespace","ancestors":["projects/3","organizations/1"],"resource":{"version":"v1"}}`,
+			wantError: true,
+		},
+		{
+			name:      "missing ancestors",
+			asset:     `{"asset_type":"k8s.io/Namespace","resource":{"version":"v1","data":{}}}`,
+			wantError: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			u, err := Unwrap(mustParse(t, tc.asset))
+			if tc.wantError {
+				if err == nil {
+					t.Errorf("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := u.GroupVersionKind(); got != tc.wantGVK {
+				t.Errorf("got %v, want %v", got, tc.wantGVK)
+			}
+			if got := u.GetAnnotations()[AncestorPathAnnotation]; got != "organizations/1/projects/3" {
+				t.Errorf("got ancestor path %q, want organizations/1/projects/3", got)
+			}
+		})
+	}
+}
+
+func TestUnwrapStripsManagedFields(t *testing.T) {
+	asset := mustParse(t, `{
+  "asset_type": "apps.k8s.io/DeploymentList",
+  "ancestors": ["projects/3"],
+  "resource": {
+    "version": "v1",
+    "data": {
+      "metadata": {"managedFields": [{"manager": "kubectl"}]},
+      "items": [
+        {"metadata": {"name": "d", "managedFields": [{"manager": "kube-controller-manager"}]}}
+      ]
+    }
+  }
+}`)
+	items, err := UnwrapList(asset)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(items) != 1 {
+		t.Fatalf("got %d items, want 1", len(items))
+	}
+	if fields := items[0].GetManagedFields(); len(fields) != 0 {
+		t.Errorf("item has managed fields: %v", fields)
+	}
+	if _, found := asset["resource"].(map[string]interface{})["data"].(map[string]interface{})["metadata"].(map[string]interface{})["managedFields"]; !found {
+		t.Errorf("asset was modified")
+	}
+}
+
+func TestUnwrapList(t *testing.T) {
+	listAsset := mustParse(t, `{
+  "asset_type": "k8s.io/PodList",
+  "ancestors": ["projects/3", "organizations/1"],
+  "resource": {
+    "version": "v1",
+    "data": {
+      "items": [
+        {"metadata": {"name": "a", "namespace": "default"}},
+        {"kind": "Pod", "metadata": {"name": "b", "namespace": "default"}}
+      ]
+    }
+  }
+}`)
+	items, err := UnwrapList(listAsset)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var got []string
+	for _, item := range items {
+		got = append(got, item.GetAPIVersion()+" "+item.GetKind()+" "+item.GetName()+" "+
+			item.GetAnnotations()[AncestorPathAnnotation])
+	}
+	want := []string{
+		"v1 Pod a organizations/1/projects/3",
+		"v1 Pod b organizations/1/projects/3",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected items (-want +got):\n%s", diff)
+	}
+
+	single, err := UnwrapList(mustParse(t,
+		`{"asset_type":"k8s.io/Pod","ancestors":["projects/3"],"resource":{"version":"v1","data":{"metadata":{"name":"p"}}}}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(single) != 1 || single[0].GetName() != "p" {
+		t.Errorf("got %v, want single pod p", single)
+	}
+
+	_, err = UnwrapList(mustParse(t,
+		`{"asset_type":"k8s.io/List","ancestors":["projects/3"],"resource":{"version":"v1","data":{"items":[{"metadata":{"name":"x"}}]}}}`))
+	if err == nil {
+		t.Errorf("expected error for generic list item without kind")
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	var testCases = []struct {
+		name   string
+		object string
+	}{
+		{
+			name:   "core",
+			object: `{"apiVersion":"v1","kind":"Namespace","metadata":{"name":"ns","labels":{"team":"payments"}}}`,
+		},
+		{
+			name:   "legacy group",
+			object: `{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"name":"d","namespace":"default","annotations":{"owner":"me"}},"spec":{"replicas":3}}`,
+		},
+		{
+			name:   "batch group",
+			object: `{"apiVersion":"batch/v1beta1","kind":"CronJob","metadata":{"name":"c","namespace":"default"}}`,
+		},
+		{
+			name:   "domain group",
+			object: `{"apiVersion":"rbac.authorization.k8s.io/v1","kind":"Role","metadata":{"name":"r","namespace":"default"}}`,
+		},
+		{
+			name:   "custom resource",
+			object: `{"apiVersion":"cloud.google.com/v1beta1","kind":"BackendConfig","metadata":{"name":"b","namespace":"default"},"spec":{"timeoutSec":40}}`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			want := &unstructured.Unstructured{Object: mustParse(t, tc.object)}
+			wrapped, err := Wrap(clusterName+"/"+want.GetName(), want, testAncestors)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !IsK8S(wrapped) {
+				t.Errorf("wrapped asset %v is not recognized as a K8S asset", wrapped["name"])
+			}
+
+			// Round trip through JSON as the asset would be when read from an export.
+			wrappedJSON, err := json.Marshal(wrapped)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := Unwrap(mustParse(t, string(wrappedJSON)))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got.GetAnnotations()[AncestorPathAnnotation] != "organizations/1/projects/3" {
+				t.Errorf("got annotations %v, want ancestor path", got.GetAnnotations())
+			}
+
+			rewrapped, err := Wrap(clusterName+"/"+want.GetName(), got, testAncestors)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(wrapped, rewrapped); diff != "" {
+				t.Errorf("unexpected asset after round trip (-want +got):\n%s", diff)
+			}
+
+			annotations := got.GetAnnotations()
+			delete(annotations, AncestorPathAnnotation)
+			if len(annotations) == 0 {
+				unstructured.RemoveNestedField(got.Object, "metadata", "annotations")
+			} else {
+				got.SetAnnotations(annotations)
+			}
+			if diff := cmp.Diff(mustParse(t, tc.object), got.Object); diff != "" {
+				t.Errorf("unexpected object after round trip (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestConvertToAdmissionRequest(t *testing.T) {
+	req, err := ConvertToAdmissionRequest(mustParse(t,
+		`{"asset_type":"apps.k8s.io/Deployment","ancestors":["projects/3"],"resource":{"version":"v1","data":{"metadata":{"name":"d","namespace":"prod"}}}}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if req.Kind.Group != "apps" || req.Kind.Version != "v1" || req.Kind.Kind != "Deployment" {
+		t.Errorf("got kind %v, want apps/v1 Deployment", req.Kind)
+	}
+	if req.Name != "d" || req.Namespace != "prod" {
+		t.Errorf("got %s/%s, want prod/d", req.Namespace, req.Name)
+	}
+}
+
+func TestIsK8S(t *testing.T) {
+	var testCases = []struct {
+		name string
+		want bool
+	}{
+		{name: clusterName + "/namespaces/default", want: true},
+		{name: "//container.googleapis.com/projects/p/locations/us-central1/clusters/c/k8s/nodes/n", want: true},
+		{name: "//container.googleapis.com/projects/p/zones/us-central1-a/clusters/c", want: false},
+		{name: "//storage.googleapis.com/bucket", want: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := IsK8S(map[string]interface{}{"name": tc.name}); got != tc.want {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func BenchmarkConvertToAdmissionRequest(b *testing.B) {
+	f, err := os.Open(benchmarkExport)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer f.Close()
+	reader := asset2.NewReader(f, benchmarkExport)
+	for {
+		record, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			b.Fatal("unexpected error", err)
+		}
+		if !IsK8S(record.Asset) {
+			continue
+		}
+		assetType, _, _ := unstructured.NestedString(record.Asset, "asset_type")
+		b.Run(assetType, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := ConvertToAdmissionRequest(record.Asset); err != nil {
+					b.Fatal("unexpected error", err)
+				}
+			}
+		})
+	}
+}
+
+func TestAssetName(t *testing.T) {
+	cluster := "//container.googleapis.com/projects/p/locations/us-central1/clusters/c"
+	var testCases = []struct {
+		gvk       schema.GroupVersionKind
+		namespace string
+		want      string
+	}{
+		{gvk: schema.GroupVersionKind{Version: "v1", Kind: "Namespace"}, want: cluster + "/k8s/namespaces/x"},
+		{gvk: schema.GroupVersionKind{Version: "v1", Kind: "Pod"}, namespace: "default", want: cluster + "/k8s/namespaces/default/pods/x"},
+		{gvk: schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}, namespace: "default", want: cluster + "/k8s/namespaces/default/apps/deployments/x"},
+		{gvk: schema.GroupVersionKind{Group: "networking.k8s.io", Version: "v1", Kind: "Ingress"}, namespace: "default", want: cluster + "/k8s/namespaces/default/networking.k8s.io/ingresses/x"},
+		{gvk: schema.GroupVersionKind{Group: "networking.k8s.io", Version: "v1", Kind: "NetworkPolicy"}, namespace: "default", want: cluster + "/k8s/namespaces/default/networking.k8s.io/networkpolicies/x"},
+		{gvk: schema.GroupVersionKind{Version: "v1", Kind: "Endpoints"}, namespace: "default", want: cluster + "/k8s/namespaces/default/endpoints/x"},
+		{gvk: schema.GroupVersionKind{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "ClusterRole"}, want: cluster + "/k8s/rbac.authorization.k8s.io/clusterroles/x"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.gvk.Kind, func(t *testing.T) {
+			name := AssetName(cluster, tc.gvk, tc.namespace, "x")
+			if name != tc.want {
+				t.Errorf("got %s, want %s", name, tc.want)
+			}
+			if !IsK8S(map[string]interface{}{"name": name}) {
+				t.Errorf("%s is not a K8S asset name", name)
+			}
+		})
+	}
+}
@@ -0,0 +1,434 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package lint statically checks constraint templates and constraints before they are loaded,
+// reporting problems as diagnostics with the file and line they were found at. Unlike loading
+// policies, linting reports all problems rather than stopping at the first one.
+package lint
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/forseti-security/config-validator/pkg/celtemplate"
+	// Registers external_data, which templates may call.
+	_ "github.com/forseti-security/config-validator/pkg/externaldata"
+	"github.com/forseti-security/config-validator/pkg/gcv/configs"
+	"github.com/open-policy-agent/opa/ast"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/kubectl/pkg/scheme"
+)
+
+// Severity is the severity of a diagnostic.
+type Severity int
+
+const (
+	// Error is a problem that fails loading policies or keeps a constraint from working.
+	Error Severity = iota
+	// Warning is a problem that does not fail loading policies.
+	Warning
+)
+
+func (s Severity) String() string {
+	if s == Warning {
+		return "warning"
+	}
+	return "error"
+}
+
+// Diagnostic codes.
+const (
+	CodeInvalidYAML      = "invalid-yaml"
+	CodeMissingKind      = "missing-kind"
+	CodeDuplicateKind    = "duplicate-kind"
+	CodeUnknownTarget    = "unknown-target"
+	CodeRegoError        = "rego-error"
+	CodeCELError         = "cel-error"
+	CodeDeprecatedImport = "deprecated-lib-import"
+	CodeNoTemplate       = "no-template"
+	CodeUnknownParameter = "unknown-parameter"
+	CodeInvalidParameter = "invalid-parameter"
+	CodeInvalidSchema    = "invalid-parameter-schema"
+)
+
+// Diagnostic is a problem found in a policy file.
+type Diagnostic struct {
+	// Path is the file the problem was found in.
+	Path string
+	// Line is the 1-based line of the problem, 0 if unknown.
+	Line     int
+	Severity Severity
+	// Code identifies the kind of problem, e.g. unknown-parameter.
+	Code    string
+	Message string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s:%d: %s: %s (%s)", d.Path, d.Line, d.Severity, d.Message, d.Code)
+}
+
+// HasErrors returns true if any of diagnostics is an error.
+func HasErrors(diagnostics []Diagnostic) bool {
+	for _, d := range diagnostics {
+		if d.Severity == Error {
+			return true
+		}
+	}
+	return false
+}
+
+// knownTargets are the targets templates can be written for.
+var knownTargets = map[string]bool{
+	"validation.gcp.forsetisecurity.org": true,
+	configs.K8STargetName:                true,
+}
+
+// deprecatedImportPrefix is the prefix of the shared library packages templates used to import
+// before libraries were inlined into templates.
+const deprecatedImportPrefix = "data.validator."
+
+// document is one YAML document of a policy file.
+type document struct {
+	path string
+	// line is the line of the file the document starts at.
+	line    int
+	content string
+	obj     *unstructured.Unstructured
+}
+
+// template is a template found while linting.
+type template struct {
+	doc    *document
+	schema map[string]interface{}
+}
+
+type linter struct {
+	libs        map[string]*ast.Module
+	templates   map[string]*template
+	constraints []*document
+	diagnostics []Diagnostic
+}
+
+// LintPaths lints the YAML files below policyPaths, using the Rego libraries below libDir.
+func LintPaths(ctx context.Context, policyPaths []string, libDir string) ([]Diagnostic, error) {
+	var files []configs.File
+	for _, policyPath := range policyPaths {
+		path, err := configs.NewPath(policyPath)
+		if err != nil {
+			return nil, err
+		}
+		pathFiles, err := path.ReadAll(ctx, configs.SuffixPredicate(".yaml"))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, pathFiles...)
+	}
+	libs, err := ReadLibs(ctx, libDir)
+	if err != nil {
+		return nil, err
+	}
+	return Lint(files, libs), nil
+}
+
+// ReadLibs reads the Rego libraries below libDir for Lint, none if libDir is empty.
+func ReadLibs(ctx context.Context, libDir string) ([]configs.File, error) {
+	if libDir == "" {
+		return nil, nil
+	}
+	path, err := configs.NewPath(libDir)
+	if err != nil {
+		return nil, err
+	}
+	return path.ReadAll(ctx, configs.SuffixPredicate(".rego"))
+}
+
+// Lint checks the templates and constraints in files, using the Rego libraries in libs.
+// Diagnostics are sorted by path and line.
+func Lint(files []configs.File, libs []configs.File) []Diagnostic {
+	l := &linter{
+		libs:      map[string]*ast.Module{},
+		templates: map[string]*template{},
+	}
+	for _, lib := range libs {
+		module, err := ast.ParseModule(lib.Path, string(lib.Content))
+		if err != nil {
+			l.addRegoErrors(lib.Path, 0, err)
+			continue
+		}
+		l.libs[lib.Path] = module
+	}
+
+	for _, file := range files {
+		for _, doc := range splitDocuments(file) {
+			l.addDocument(doc)
+		}
+	}
+	for _, doc := range l.constraints {
+		l.lintConstraint(doc)
+	}
+
+	sort.SliceStable(l.diagnostics, func(i, j int) bool {
+		if l.diagnostics[i].Path != l.diagnostics[j].Path {
+			return l.diagnostics[i].Path < l.diagnostics[j].Path
+		}
+		return l.diagnostics[i].Line < l.diagnostics[j].Line
+	})
+	return l.diagnostics
+}
+
+func (l *linter) add(doc *document, line int, severity Severity, code, format string, args ...interface{}) {
+	l.diagnostics = append(l.diagnostics, Diagnostic{
+		Path:     doc.path,
+		Line:     line,
+		Severity: severity,
+		Code:     code,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// splitDocuments splits a YAML file into its documents the way policies are loaded.
+func splitDocuments(file configs.File) []*document {
+	var docs []*document
+	// All but the first part start on the line of the separator.
+	line := 1
+	for _, raw := range strings.Split(string(file.Content), "\n---") {
+		content := strings.TrimLeft(raw, "\n ")
+		if len(content) != 0 {
+			start := line + strings.Count(raw[:len(raw)-len(content)], "\n")
+			docs = append(docs, &document{path: file.Path, line: start, content: content})
+		}
+		line += strings.Count(raw, "\n") + 1
+	}
+	return docs
+}
+
+func (l *linter) addDocument(doc *document) {
+	var u unstructured.Unstructured
+	if _, _, err := scheme.Codecs.UniversalDeserializer().Decode([]byte(doc.content), nil, &u); err != nil {
+		l.add(doc, doc.line, Error, CodeInvalidYAML, "failed to decode document: %s", err)
+		return
+	}
+	doc.obj = &u
+	switch {
+	case u.GroupVersionKind().GroupKind() == configs.TemplateGK:
+		l.lintTemplate(doc)
+	case u.GroupVersionKind().Group == "constraints.gatekeeper.sh":
+		l.constraints = append(l.constraints, doc)
+	}
+}
+
+func (l *linter) lintTemplate(doc *document) {
+	kind, _, _ := unstructured.NestedString(doc.obj.Object, "spec", "crd", "spec", "names", "kind")
+	if kind == "" {
+		l.add(doc, doc.fieldLine("spec", "crd"), Error, CodeMissingKind, "template %s has no spec.crd.spec.names.kind", doc.obj.GetName())
+		return
+	}
+	if dup, found := l.templates[kind]; found {
+		l.add(doc, doc.fieldLine("spec", "crd", "spec", "names", "kind"), Error, CodeDuplicateKind,
+			"kind %s is already defined by template at %s:%d", kind, dup.doc.path, dup.doc.line)
+		return
+	}
+	schema, err := configs.TemplateParameterSchema(doc.obj)
+	if err != nil {
+		l.add(doc, doc.fieldLine("spec", "crd", "spec", "validation"), Error, CodeInvalidSchema, "%s", err)
+	}
+	l.templates[kind] = &template{doc: doc, schema: schema}
+
+	for _, target := range targets(doc.obj) {
+		path := append([]string{"spec", "targets"}, target.key...)
+		if !knownTargets[target.name] {
+			l.add(doc, doc.fieldLine(path...), Error, CodeUnknownTarget,
+				"target %s of template %s has no matching kind of asset, want one of %s or %s",
+				target.name, kind, "validation.gcp.forsetisecurity.org", configs.K8STargetName)
+		}
+		if celConfig, found := target.fields["cel"]; found {
+			l.lintCEL(doc, doc.fieldLine(append(path, "cel")...), kind, celConfig)
+			continue
+		}
+		rego, _ := target.fields["rego"].(string)
+		if rego == "" {
+			l.add(doc, doc.fieldLine(path...), Error, CodeRegoError, "target %s of template %s has no rego", target.name, kind)
+			continue
+		}
+		l.lintRego(doc, doc.fieldLine(append(path, "rego")...), rego)
+	}
+}
+
+// templateTarget is a target of a template.
+type templateTarget struct {
+	name string
+	// key is the path of the target below spec.targets used to find its line.
+	key    []string
+	fields map[string]interface{}
+}
+
+// targets returns the targets of a template, which are a map in legacy templates and a list
+// otherwise.
+func targets(u *unstructured.Unstructured) []templateTarget {
+	var result []templateTarget
+	if targetMap, found, _ := unstructured.NestedMap(u.Object, "spec", "targets"); found {
+		for name, fields := range targetMap {
+			fieldMap, _ := fields.(map[string]interface{})
+			result = append(result, templateTarget{name: name, key: []string{name}, fields: fieldMap})
+		}
+	}
+	if targetList, found, _ := unstructured.NestedSlice(u.Object, "spec", "targets"); found {
+		for _, fields := range targetList {
+			fieldMap, _ := fields.(map[string]interface{})
+			name, _ := fieldMap["target"].(string)
+			result = append(result, templateTarget{name: name, key: []string{"target"}, fields: fieldMap})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].name < result[j].name })
+	return result
+}
+
+// lintRego checks the rego of a template, which starts on the line after regoLine.
+func (l *linter) lintRego(doc *document, regoLine int, rego string) {
+	module, err := ast.ParseModule(doc.path, rego)
+	if err != nil {
+		l.addRegoErrors(doc.path, regoLine, err)
+		return
+	}
+	for _, imp := range module.Imports {
+		if path := imp.Path.String(); strings.HasPrefix(path, deprecatedImportPrefix) {
+			l.add(doc, regoLine+imp.Location.Row, Warning, CodeDeprecatedImport,
+				"import of shared library %s is deprecated, inline the library with libs instead", path)
+		}
+	}
+
+	modules := map[string]*ast.Module{doc.path: module}
+	for path, lib := range l.libs {
+		modules[path] = lib
+	}
+	compiler := ast.NewCompiler()
+	if compiler.Compile(modules); compiler.Failed() {
+		var templateErrs ast.Errors
+		for _, compileErr := range compiler.Errors {
+			// Library errors are reported and accounted to the library files.
+			if compileErr.Location == nil || compileErr.Location.File == doc.path {
+				templateErrs = append(templateErrs, compileErr)
+			}
+		}
+		if len(templateErrs) > 0 {
+			l.addRegoErrors(doc.path, regoLine, templateErrs)
+		}
+	}
+}
+
+// addRegoErrors adds a diagnostic for each error in err, offsetting their rows by line.
+func (l *linter) addRegoErrors(path string, line int, err error) {
+	regoErrs, ok := err.(ast.Errors)
+	if !ok {
+		l.diagnostics = append(l.diagnostics, Diagnostic{Path: path, Line: line, Severity: Error, Code: CodeRegoError, Message: err.Error()})
+		return
+	}
+	for _, regoErr := range regoErrs {
+		errLine := line
+		if regoErr.Location != nil {
+			errLine += regoErr.Location.Row
+		}
+		l.diagnostics = append(l.diagnostics, Diagnostic{
+			Path:     path,
+			Line:     errLine,
+			Severity: Error,
+			Code:     CodeRegoError,
+			Message:  regoErr.Message,
+		})
+	}
+}
+
+func (l *linter) lintCEL(doc *document, line int, kind string, celConfig interface{}) {
+	encoded, err := json.Marshal(celConfig)
+	if err != nil {
+		l.add(doc, line, Error, CodeCELError, "invalid cel: %s", err)
+		return
+	}
+	var config celtemplate.Config
+	if err := json.Unmarshal(encoded, &config); err != nil {
+		l.add(doc, line, Error, CodeCELError, "invalid cel: %s", err)
+		return
+	}
+	if _, err := celtemplate.Rego(kind, config); err != nil {
+		l.add(doc, line, Error, CodeCELError, "%s", err)
+	}
+}
+
+func (l *linter) lintConstraint(doc *document) {
+	kind := doc.obj.GetKind()
+	tmpl, found := l.templates[kind]
+	if !found {
+		l.add(doc, doc.fieldLine("kind"), Error, CodeNoTemplate, "constraint %s has kind %s, which no template defines", doc.obj.GetName(), kind)
+		return
+	}
+	params, _, err := unstructured.NestedMap(doc.obj.Object, "spec", "parameters")
+	if err != nil {
+		l.add(doc, doc.fieldLine("spec", "parameters"), Error, CodeInvalidParameter, "spec.parameters must be an object")
+		return
+	}
+	if tmpl.schema == nil || params == nil {
+		return
+	}
+	paramErrs, err := configs.ValidateParameters(tmpl.schema, params)
+	if err != nil {
+		l.add(tmpl.doc, tmpl.doc.fieldLine("spec", "crd", "spec", "validation"), Error, CodeInvalidSchema, "%s", err)
+		return
+	}
+	for _, paramErr := range paramErrs {
+		line := doc.fieldLine(append([]string{"spec", "parameters"}, strings.Split(paramErr.Field, ".")...)...)
+		if paramErr.Unknown {
+			l.add(doc, line, Error, CodeUnknownParameter, "spec.parameters.%s is not a parameter of %s", paramErr.Field, kind)
+		} else {
+			l.add(doc, line, Error, CodeInvalidParameter, "spec.parameters.%s %s", paramErr.Field, paramErr.Message)
+		}
+	}
+}
+
+// fieldLine returns the line of the deepest key of path found in the document, or the line the
+// document starts at. Lines are found by indentation, which is enough for the block style
+// policy files are written in.
+func (d *document) fieldLine(path ...string) int {
+	lines := strings.Split(d.content, "\n")
+	found, indent := -1, -1
+	for _, key := range path {
+		next := -1
+		for i := found + 1; i < len(lines); i++ {
+			trimmed := strings.TrimLeft(lines[i], " ")
+			if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+				continue
+			}
+			lineIndent := len(lines[i]) - len(trimmed)
+			if lineIndent <= indent {
+				break
+			}
+			item := strings.TrimPrefix(trimmed, "- ")
+			if strings.HasPrefix(item, key+":") || strings.HasPrefix(item, `"`+key+`":`) {
+				next = i
+				indent = lineIndent + len(trimmed) - len(item)
+				break
+			}
+		}
+		if next < 0 {
+			break
+		}
+		found = next
+	}
+	if found < 0 {
+		return d.line
+	}
+	return d.line + found
+}
@@ -0,0 +1,206 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lint
+
+import (
+	"context"
+	"testing"
+
+	"github.com/forseti-security/config-validator/pkg/gcv/configs"
+	"github.com/google/go-cmp/cmp"
+)
+
+const testLib = `package validator.gcp.lib
+
+has_field(obj, field) {
+	obj[field]
+}
+`
+
+const locationTemplate = `apiVersion: templates.gatekeeper.sh/v1alpha1
+kind: ConstraintTemplate
+metadata:
+  name: gcp-location
+spec:
+  crd:
+    spec:
+      names:
+        kind: GCPLocationConstraint
+      validation:
+        openAPIV3Schema:
+          properties:
+            locations:
+              type: array
+              items:
+                type: string
+  targets:
+    validation.gcp.forsetisecurity.org:
+      rego: |
+        package templates.gcp.GCPLocationConstraint
+
+        import data.validator.gcp.lib as lib
+
+        deny[{"msg": "wrong location", "details": {}}] {
+        	lib.has_field(input.asset, "resource")
+        }
+`
+
+// locationConstraints has a valid constraint and one with parameter errors.
+const locationConstraints = `apiVersion: constraints.gatekeeper.sh/v1alpha1
+kind: GCPLocationConstraint
+metadata:
+  name: eu-only
+spec:
+  parameters:
+    locations: [EU]
+---
+apiVersion: constraints.gatekeeper.sh/v1alpha1
+kind: GCPLocationConstraint
+metadata:
+  name: typo
+spec:
+  parameters:
+    locations: EU
+    loactions: [EU]
+`
+
+const brokenTemplates = `apiVersion: templates.gatekeeper.sh/v1alpha1
+kind: ConstraintTemplate
+metadata:
+  name: gcp-broken
+spec:
+  crd:
+    spec:
+      names:
+        kind: GCPBrokenConstraint
+  targets:
+    validation.gcp.forsetisecurity.org:
+      rego: |
+        package templates.gcp.GCPBrokenConstraint
+
+        deny[{"msg": "broken", "details": {}}] {
+        	undefined_function(input.asset)
+        }
+---
+apiVersion: templates.gatekeeper.sh/v1alpha1
+kind: ConstraintTemplate
+metadata:
+  name: gcp-unknown-target
+spec:
+  crd:
+    spec:
+      names:
+        kind: GCPUnknownTargetConstraint
+  targets:
+    validation.aws.example.com:
+      cel:
+        validations:
+        - expression: "asset.name =="
+---
+apiVersion: templates.gatekeeper.sh/v1alpha1
+kind: ConstraintTemplate
+metadata:
+  name: gcp-location-copy
+spec:
+  crd:
+    spec:
+      names:
+        kind: GCPLocationConstraint
+  targets:
+    validation.gcp.forsetisecurity.org:
+      rego: |
+        package templates.gcp.GCPLocationConstraint
+        deny[{"msg": "copy", "details": {}}] {
+`
+
+const orphanConstraint = `apiVersion: constraints.gatekeeper.sh/v1alpha1
+kind: GCPMissingConstraint
+metadata:
+  name: orphan
+`
+
+func TestLint(t *testing.T) {
+	files := []configs.File{
+		{Path: "templates/location.yaml", Content: []byte(locationTemplate)},
+		{Path: "constraints/location.yaml", Content: []byte(locationConstraints)},
+		{Path: "templates/broken.yaml", Content: []byte(brokenTemplates)},
+		{Path: "constraints/orphan.yaml", Content: []byte(orphanConstraint)},
+	}
+	libs := []configs.File{{Path: "lib/util.rego", Content: []byte(testLib)}}
+
+	got := Lint(files, libs)
+	want := []Diagnostic{
+		{Path: "constraints/location.yaml", Line: 15, Severity: Error, Code: CodeInvalidParameter,
+			Message: `spec.parameters.locations must be of type array: "string"`},
+		{Path: "constraints/location.yaml", Line: 16, Severity: Error, Code: CodeUnknownParameter,
+			Message: "spec.parameters.loactions is not a parameter of GCPLocationConstraint"},
+		{Path: "constraints/orphan.yaml", Line: 2, Severity: Error, Code: CodeNoTemplate,
+			Message: "constraint orphan has kind GCPMissingConstraint, which no template defines"},
+		{Path: "templates/broken.yaml", Line: 16, Severity: Error, Code: CodeRegoError,
+			Message: "undefined function undefined_function"},
+		{Path: "templates/broken.yaml", Line: 29, Severity: Error, Code: CodeUnknownTarget,
+			Message: "target validation.aws.example.com of template GCPUnknownTargetConstraint has no matching kind of asset, " +
+				"want one of validation.gcp.forsetisecurity.org or admission.k8s.gatekeeper.sh"},
+		{Path: "templates/broken.yaml", Line: 30, Severity: Error, Code: CodeCELError},
+		{Path: "templates/broken.yaml", Line: 42, Severity: Error, Code: CodeDuplicateKind,
+			Message: "kind GCPLocationConstraint is already defined by template at templates/location.yaml:1"},
+		{Path: "templates/location.yaml", Line: 22, Severity: Warning, Code: CodeDeprecatedImport,
+			Message: "import of shared library data.validator.gcp.lib is deprecated, inline the library with libs instead"},
+	}
+	// CEL messages come from the CEL parser.
+	for idx := range got {
+		if got[idx].Code == CodeCELError {
+			got[idx].Message = ""
+		}
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected diagnostics (-want +got):\n%s", diff)
+	}
+	if !HasErrors(got) {
+		t.Errorf("HasErrors returned false")
+	}
+}
+
+func TestLintSyntaxError(t *testing.T) {
+	template := `apiVersion: templates.gatekeeper.sh/v1alpha1
+kind: ConstraintTemplate
+metadata:
+  name: gcp-syntax
+spec:
+  crd:
+    spec:
+      names:
+        kind: GCPSyntaxConstraint
+  targets:
+    validation.gcp.forsetisecurity.org:
+      rego: |
+        package templates.gcp.GCPSyntaxConstraint
+        deny[{"msg": "x"}] {
+`
+	got := Lint([]configs.File{{Path: "syntax.yaml", Content: []byte(template)}}, nil)
+	if len(got) != 1 || got[0].Code != CodeRegoError || got[0].Line < 13 {
+		t.Errorf("got %v, want one rego error in the rego block", got)
+	}
+}
+
+func TestLintPaths(t *testing.T) {
+	got, err := LintPaths(context.Background(), []string{"../../test/cf"}, "../../test/cf/library")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if HasErrors(got) {
+		t.Errorf("got errors for test policies: %v", got)
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package logging configures the structured logger used by the validator, its server and its
+// commands. Loggers travel in contexts, so that every line logged while handling a review run is
+// tagged with the ID of the run and the name of the asset under review.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Output formats supported by New.
+const (
+	// Text writes human readable lines.
+	Text = "text"
+	// JSON writes one JSON object per line, using the field names Cloud Logging recognizes for
+	// the severity, message and time of structured log entries.
+	JSON = "json"
+)
+
+// Keys of the correlation fields added to log lines.
+const (
+	RunIDKey = "run_id"
+	AssetKey = "asset"
+)
+
+// New returns a logger writing lines of level or higher to w in format, Text or JSON. Level is
+// one of debug, info, warn or error.
+func New(w io.Writer, format, level string) (*zap.Logger, error) {
+	var zapLevel zapcore.Level
+	if err := zapLevel.UnmarshalText([]byte(level)); err != nil {
+		return nil, errors.Errorf("unknown log level %q, want one of debug, info, warn, error", level)
+	}
+
+	var encoder zapcore.Encoder
+	switch format {
+	case Text:
+		config := zap.NewDevelopmentEncoderConfig()
+		config.EncodeTime = zapcore.ISO8601TimeEncoder
+		encoder = zapcore.NewConsoleEncoder(config)
+	case JSON:
+		config := zap.NewProductionEncoderConfig()
+		config.LevelKey = "severity"
+		config.MessageKey = "message"
+		config.TimeKey = "time"
+		config.EncodeLevel = cloudLoggingLevel
+		config.EncodeTime = rfc3339NanoTime
+		encoder = zapcore.NewJSONEncoder(config)
+	default:
+		return nil, errors.Errorf("unknown log format %q, want %s or %s", format, Text, JSON)
+	}
+	core := zapcore.NewCore(encoder, zapcore.AddSync(w), zapLevel)
+	return zap.New(core, zap.AddCaller()), nil
+}
+
+// Setup replaces the global logger with one writing to stderr in format at level, see New.
+func Setup(format, level string) error {
+	logger, err := New(os.Stderr, format, level)
+	if err != nil {
+		return err
+	}
+	zap.ReplaceGlobals(logger)
+	return nil
+}
+
+// cloudLoggingLevel encodes levels as Cloud Logging severities.
+func cloudLoggingLevel(level zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
+	switch level {
+	case zapcore.WarnLevel:
+		enc.AppendString("WARNING")
+	case zapcore.DPanicLevel, zapcore.PanicLevel:
+		enc.AppendString("CRITICAL")
+	case zapcore.FatalLevel:
+		enc.AppendString("EMERGENCY")
+	default:
+		enc.AppendString(strings.ToUpper(level.String()))
+	}
+}
+
+func rfc3339NanoTime(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
+	enc.AppendString(t.UTC().Format(time.RFC3339Nano))
+}
+
+type loggerKey struct{}
+
+// NewContext returns a copy of ctx that carries logger.
+func NewContext(ctx context.Context, logger *zap.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// FromContext returns the logger carried by ctx, or the global logger if there is none.
+func FromContext(ctx context.Context) *zap.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*zap.Logger); ok {
+		return logger
+	}
+	return zap.L()
+}
+
+// WithFields returns a copy of ctx whose logger adds fields to every line.
+func WithFields(ctx context.Context, fields ...zap.Field) context.Context {
+	return NewContext(ctx, FromContext(ctx).With(fields...))
+}
+
+// WithRunID returns a copy of ctx whose logger tags every line with the ID of a review run.
+func WithRunID(ctx context.Context, runID string) context.Context {
+	return WithFields(ctx, zap.String(RunIDKey, runID))
+}
+
+// WithAsset returns a copy of ctx whose logger tags every line with the name of an asset.
+func WithAsset(ctx context.Context, name string) context.Context {
+	return WithFields(ctx, zap.String(AssetKey, name))
+}
+
+// NewRunID returns a random ID for a review run.
+func NewRunID() string {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return time.Now().UTC().Format(time.RFC3339Nano)
+	}
+	return hex.EncodeToString(id)
+}
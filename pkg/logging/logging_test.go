@@ -0,0 +1,84 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestJSON(t *testing.T) {
+	var out bytes.Buffer
+	logger, err := New(&out, JSON, "info")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ctx := WithAsset(WithRunID(NewContext(context.Background(), logger), "run-1"), "//storage.googleapis.com/b")
+	FromContext(ctx).Debug("not logged")
+	FromContext(ctx).Warn("review failed")
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("got %q, want one JSON line: %v", out.String(), err)
+	}
+	for _, key := range []string{"time", "caller"} {
+		if _, found := got[key]; !found {
+			t.Errorf("missing %s in %v", key, got)
+		}
+		delete(got, key)
+	}
+	want := map[string]interface{}{
+		"severity": "WARNING",
+		"message":  "review failed",
+		"run_id":   "run-1",
+		"asset":    "//storage.googleapis.com/b",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected log entry (-want +got):\n%s", diff)
+	}
+}
+
+func TestText(t *testing.T) {
+	var out bytes.Buffer
+	logger, err := New(&out, Text, "debug")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	FromContext(WithRunID(NewContext(context.Background(), logger), "run-1")).Debug("reviewing")
+	if line := out.String(); !strings.Contains(line, "DEBUG") || !strings.Contains(line, `reviewing	{"run_id": "run-1"}`) {
+		t.Errorf("unexpected log line %q", line)
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	if _, err := New(&bytes.Buffer{}, "xml", "info"); err == nil {
+		t.Errorf("unknown format: got no error")
+	}
+	if _, err := New(&bytes.Buffer{}, Text, "loud"); err == nil {
+		t.Errorf("unknown level: got no error")
+	}
+}
+
+func TestNewRunID(t *testing.T) {
+	a, b := NewRunID(), NewRunID()
+	if len(a) != 16 || a == b {
+		t.Errorf("got run IDs %q and %q, want distinct 16 character IDs", a, b)
+	}
+}
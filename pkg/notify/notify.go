@@ -0,0 +1,185 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package notify pushes alerts about violations found while the server reviews assets, e.g. to a
+// chat webhook.
+package notify
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/forseti-security/config-validator/pkg/api/validator"
+	"github.com/forseti-security/config-validator/pkg/gcv"
+	"github.com/forseti-security/config-validator/pkg/logging"
+	"go.uber.org/zap"
+)
+
+// Notifier is told about violations as they are found.
+type Notifier interface {
+	Notify(ctx context.Context, violations []*validator.Violation) error
+}
+
+// Deduplicator forwards violations to a notifier unless they were already forwarded, so that
+// assets reviewed again and again do not raise the same alert every time.
+type Deduplicator struct {
+	next Notifier
+	// ttl is how long a violation is remembered, after which it is forwarded again.
+	ttl time.Duration
+	now func() time.Time
+
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+var _ Notifier = &Deduplicator{}
+
+// NewDeduplicator returns a Deduplicator forwarding to next, which notifies again about
+// violations that are still found ttl after they were last forwarded.
+func NewDeduplicator(next Notifier, ttl time.Duration) *Deduplicator {
+	return &Deduplicator{next: next, ttl: ttl, now: time.Now, seen: map[string]time.Time{}}
+}
+
+// Notify forwards the violations that were not forwarded within ttl. Violations that failed to
+// be forwarded are forwarded again on the next call.
+func (n *Deduplicator) Notify(ctx context.Context, violations []*validator.Violation) error {
+	now := n.now()
+	var fresh []*validator.Violation
+	var fingerprints []string
+	n.mu.Lock()
+	for fingerprint, seen := range n.seen {
+		if now.Sub(seen) >= n.ttl {
+			delete(n.seen, fingerprint)
+		}
+	}
+	for _, v := range violations {
+		fingerprint := gcv.ViolationFingerprint(v)
+		if _, found := n.seen[fingerprint]; found {
+			continue
+		}
+		n.seen[fingerprint] = now
+		fresh = append(fresh, v)
+		fingerprints = append(fingerprints, fingerprint)
+	}
+	n.mu.Unlock()
+
+	if len(fresh) == 0 {
+		return nil
+	}
+	err := n.next.Notify(ctx, fresh)
+	if err != nil {
+		n.mu.Lock()
+		for _, fingerprint := range fingerprints {
+			delete(n.seen, fingerprint)
+		}
+		n.mu.Unlock()
+	}
+	return err
+}
+
+// Batcher collects violations and forwards them to a notifier in batches, either once a batch is
+// full or when the flush interval expires, so that a stream of reviews results in few alerts.
+type Batcher struct {
+	next Notifier
+	size int
+
+	mu      sync.Mutex
+	pending []*validator.Violation
+	// flushMu serializes flushes so batches are forwarded in order.
+	flushMu sync.Mutex
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+var _ Notifier = &Batcher{}
+
+// NewBatcher returns a Batcher forwarding batches of up to size violations to next, flushing
+// pending violations every interval. Close must be called to stop the flushing.
+func NewBatcher(next Notifier, size int, interval time.Duration) *Batcher {
+	b := &Batcher{
+		next: next,
+		size: size,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go b.run(interval)
+	return b
+}
+
+func (b *Batcher) run(interval time.Duration) {
+	defer close(b.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-b.stop:
+			return
+		case <-ticker.C:
+			ctx := context.Background()
+			if err := b.Flush(ctx); err != nil {
+				logging.FromContext(ctx).Error("failed to send notifications", zap.Error(err))
+			}
+		}
+	}
+}
+
+// Notify queues violations, forwarding the full batches immediately.
+func (b *Batcher) Notify(ctx context.Context, violations []*validator.Violation) error {
+	b.mu.Lock()
+	b.pending = append(b.pending, violations...)
+	full := len(b.pending) >= b.size
+	b.mu.Unlock()
+	if !full {
+		return nil
+	}
+	return b.flush(ctx, false)
+}
+
+// Flush forwards all pending violations.
+func (b *Batcher) Flush(ctx context.Context) error {
+	return b.flush(ctx, true)
+}
+
+// flush forwards the pending violations in batches, leaving a partial batch pending unless all is
+// set.
+func (b *Batcher) flush(ctx context.Context, all bool) error {
+	b.flushMu.Lock()
+	defer b.flushMu.Unlock()
+	for {
+		b.mu.Lock()
+		count := len(b.pending)
+		if count > b.size {
+			count = b.size
+		}
+		if count == 0 || (count < b.size && !all) {
+			b.mu.Unlock()
+			return nil
+		}
+		batch := b.pending[:count:count]
+		b.pending = b.pending[count:]
+		b.mu.Unlock()
+
+		if err := b.next.Notify(ctx, batch); err != nil {
+			return err
+		}
+	}
+}
+
+// Close stops the periodic flushing and forwards the pending violations.
+func (b *Batcher) Close(ctx context.Context) error {
+	close(b.stop)
+	<-b.done
+	return b.Flush(ctx)
+}
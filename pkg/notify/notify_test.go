@@ -0,0 +1,131 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notify
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/forseti-security/config-validator/pkg/api/validator"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+)
+
+// recorder records the resources of the violations it is notified about.
+type recorder struct {
+	mu      sync.Mutex
+	batches [][]string
+	err     error
+}
+
+func (r *recorder) Notify(ctx context.Context, violations []*validator.Violation) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return r.err
+	}
+	var batch []string
+	for _, v := range violations {
+		batch = append(batch, v.Resource)
+	}
+	r.batches = append(r.batches, batch)
+	return nil
+}
+
+func violations(resources ...string) []*validator.Violation {
+	var vs []*validator.Violation
+	for _, resource := range resources {
+		vs = append(vs, &validator.Violation{Constraint: "GCPStorageLoggingConstraint.require-storage-logging", Resource: resource})
+	}
+	return vs
+}
+
+func TestDeduplicator(t *testing.T) {
+	ctx := context.Background()
+	next := &recorder{}
+	now := time.Date(2020, 4, 1, 12, 0, 0, 0, time.UTC)
+	d := NewDeduplicator(next, time.Hour)
+	d.now = func() time.Time { return now }
+
+	steps := []struct {
+		advance    time.Duration
+		resources  []string
+		failToSend bool
+	}{
+		{resources: []string{"a", "b"}},
+		// Already notified about a.
+		{advance: time.Minute, resources: []string{"a", "c"}},
+		// Failed notifications are retried with the next call.
+		{failToSend: true, resources: []string{"d"}},
+		{resources: []string{"d"}},
+		// a is forgotten an hour after it was notified, c is not.
+		{advance: 59 * time.Minute, resources: []string{"a", "c"}},
+	}
+	for _, step := range steps {
+		now = now.Add(step.advance)
+		next.err = nil
+		if step.failToSend {
+			next.err = errors.New("unavailable")
+		}
+		err := d.Notify(ctx, violations(step.resources...))
+		if (err != nil) != step.failToSend {
+			t.Fatalf("got error %v", err)
+		}
+	}
+	want := [][]string{{"a", "b"}, {"c"}, {"d"}, {"a"}}
+	if diff := cmp.Diff(want, next.batches); diff != "" {
+		t.Errorf("unexpected notifications (-want +got):\n%s", diff)
+	}
+}
+
+func TestBatcher(t *testing.T) {
+	ctx := context.Background()
+	next := &recorder{}
+	b := NewBatcher(next, 2, time.Hour)
+	for _, resource := range []string{"a", "b", "c", "d", "e"} {
+		if err := b.Notify(ctx, violations(resource)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := b.Close(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := [][]string{{"a", "b"}, {"c", "d"}, {"e"}}
+	if diff := cmp.Diff(want, next.batches); diff != "" {
+		t.Errorf("unexpected batches (-want +got):\n%s", diff)
+	}
+}
+
+func TestBatcherFlushesPeriodically(t *testing.T) {
+	next := &recorder{}
+	b := NewBatcher(next, 100, 10*time.Millisecond)
+	defer b.Close(context.Background())
+	if err := b.Notify(context.Background(), violations("a")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		next.mu.Lock()
+		flushed := len(next.batches) != 0
+		next.mu.Unlock()
+		if flushed {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Errorf("pending violation was not flushed")
+}
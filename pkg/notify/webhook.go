@@ -0,0 +1,270 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/forseti-security/config-validator/pkg/api/validator"
+	"github.com/forseti-security/config-validator/pkg/logging"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// Payload formats with built in templates.
+const (
+	// FormatJSON posts {"violations": [...]}, with the fields of Violation for each violation.
+	FormatJSON = "json"
+	// FormatSlack posts a Slack incoming webhook message.
+	FormatSlack = "slack"
+	// FormatTeams posts a Microsoft Teams connector card.
+	FormatTeams = "teams"
+)
+
+var formatTemplates = map[string]string{
+	FormatJSON:  `{"violations": {{json .Violations}}}`,
+	FormatSlack: `{"text": {{json (printf "*%s*\n%s" .Summary .Text)}}}`,
+	FormatTeams: `{"@type": "MessageCard", "@context": "https://schema.org/extensions", ` +
+		`"summary": {{json .Summary}}, "title": {{json .Summary}}, "text": {{json .Text}}}`,
+}
+
+// Violation is a violation as seen by payload templates.
+type Violation struct {
+	Constraint    string `json:"constraint"`
+	Resource      string `json:"resource"`
+	Message       string `json:"message"`
+	Severity      string `json:"severity,omitempty"`
+	PolicyVersion string `json:"policy_version,omitempty"`
+	// Remediation and RemediationURL are the guidance the constraint declares on fixing the
+	// violation, if any.
+	Remediation    string `json:"remediation,omitempty"`
+	RemediationURL string `json:"remediation_url,omitempty"`
+}
+
+// Payload is the data payload templates are executed with.
+type Payload struct {
+	Violations []Violation
+	// Summary is a one line description of the batch, e.g. "3 new policy violations".
+	Summary string
+	// Text has one line per violation.
+	Text string
+}
+
+// Webhook posts violations to an HTTP endpoint, one request per call to Notify, with a body
+// produced by a template.
+type Webhook struct {
+	url            string
+	template       *template.Template
+	headers        map[string]string
+	client         *http.Client
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	sleep          func(ctx context.Context, d time.Duration) error
+}
+
+var _ Notifier = &Webhook{}
+
+// WebhookOption configures a Webhook.
+type WebhookOption func(*webhookOptions)
+
+type webhookOptions struct {
+	format   string
+	template string
+	hook     *Webhook
+}
+
+// WithFormat selects one of the built in payload templates, FormatJSON by default.
+func WithFormat(format string) WebhookOption {
+	return func(o *webhookOptions) {
+		o.format = format
+	}
+}
+
+// WithTemplate sets a text/template for the request body, executed with a Payload, instead of a
+// built in format. The template can call json to encode a value as JSON.
+func WithTemplate(text string) WebhookOption {
+	return func(o *webhookOptions) {
+		o.template = text
+	}
+}
+
+// WithHeader adds a header to the requests, e.g. for authentication.
+func WithHeader(name, value string) WebhookOption {
+	return func(o *webhookOptions) {
+		o.hook.headers[name] = value
+	}
+}
+
+// WithHTTPClient sets the client sending the requests, http.DefaultClient by default.
+func WithHTTPClient(client *http.Client) WebhookOption {
+	return func(o *webhookOptions) {
+		o.hook.client = client
+	}
+}
+
+// WithRetry sets the number of attempts made for each request and the exponential backoff
+// between attempts.
+func WithRetry(maxAttempts int, initialBackoff, maxBackoff time.Duration) WebhookOption {
+	return func(o *webhookOptions) {
+		o.hook.maxAttempts = maxAttempts
+		o.hook.initialBackoff = initialBackoff
+		o.hook.maxBackoff = maxBackoff
+	}
+}
+
+// NewWebhook returns a Webhook posting to url.
+func NewWebhook(url string, opts ...WebhookOption) (*Webhook, error) {
+	if url == "" {
+		return nil, errors.Errorf("webhook URL must be set")
+	}
+	hook := &Webhook{
+		url:            url,
+		headers:        map[string]string{"Content-Type": "application/json"},
+		client:         http.DefaultClient,
+		maxAttempts:    5,
+		initialBackoff: time.Second,
+		maxBackoff:     30 * time.Second,
+		sleep:          sleep,
+	}
+	o := &webhookOptions{format: FormatJSON, hook: hook}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	text := o.template
+	if text == "" {
+		var found bool
+		if text, found = formatTemplates[o.format]; !found {
+			return nil, errors.Errorf("unknown webhook format %q, want one of %s, %s, %s", o.format, FormatJSON, FormatSlack, FormatTeams)
+		}
+	}
+	tmpl, err := template.New("webhook").Funcs(template.FuncMap{"json": toJSON}).Parse(text)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid webhook template")
+	}
+	hook.template = tmpl
+	return hook, nil
+}
+
+func toJSON(v interface{}) (string, error) {
+	encoded, err := json.Marshal(v)
+	return string(encoded), err
+}
+
+// Notify posts the violations, retrying on rate limiting and server errors.
+func (w *Webhook) Notify(ctx context.Context, violations []*validator.Violation) error {
+	if len(violations) == 0 {
+		return nil
+	}
+	var body bytes.Buffer
+	if err := w.template.Execute(&body, newPayload(violations)); err != nil {
+		return errors.Wrapf(err, "failed to render webhook payload")
+	}
+
+	backoff := w.initialBackoff
+	for attempt := 1; ; attempt++ {
+		retryable, err := w.post(ctx, body.Bytes())
+		if err == nil {
+			return nil
+		}
+		if !retryable || attempt >= w.maxAttempts {
+			return errors.Wrapf(err, "failed to post %d violations to webhook", len(violations))
+		}
+		logging.FromContext(ctx).Debug("retrying webhook", zap.Int("attempt", attempt), zap.Int("max_attempts", w.maxAttempts),
+			zap.Duration("backoff", backoff), zap.Error(err))
+		if sleepErr := w.sleep(ctx, backoff); sleepErr != nil {
+			return errors.Wrapf(sleepErr, "failed to post violations to webhook: %s", err)
+		}
+		backoff *= 2
+		if backoff > w.maxBackoff {
+			backoff = w.maxBackoff
+		}
+	}
+}
+
+// post sends body, returning whether a failed request may be retried.
+func (w *Webhook) post(ctx context.Context, body []byte) (bool, error) {
+	request, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	for name, value := range w.headers {
+		request.Header.Set(name, value)
+	}
+	response, err := w.client.Do(request.WithContext(ctx))
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode/100 == 2 {
+		_, _ = io.Copy(ioutil.Discard, response.Body)
+		return false, nil
+	}
+	message, _ := ioutil.ReadAll(io.LimitReader(response.Body, 1024))
+	err = errors.Errorf("webhook returned %s: %s", response.Status, strings.TrimSpace(string(message)))
+	switch response.StatusCode {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true, err
+	}
+	return false, err
+}
+
+func newPayload(violations []*validator.Violation) *Payload {
+	payload := &Payload{Summary: fmt.Sprintf("%d new policy violations", len(violations))}
+	if len(violations) == 1 {
+		payload.Summary = "1 new policy violation"
+	}
+	var lines []string
+	for _, v := range violations {
+		payload.Violations = append(payload.Violations, Violation{
+			Constraint:     v.Constraint,
+			Resource:       v.Resource,
+			Message:        v.Message,
+			Severity:       v.Severity,
+			PolicyVersion:  v.PolicyVersion,
+			Remediation:    v.Remediation,
+			RemediationURL: v.RemediationUrl,
+		})
+		line := fmt.Sprintf("%s: %s", v.Constraint, v.Message)
+		if v.Severity != "" {
+			line = fmt.Sprintf("[%s] %s", v.Severity, line)
+		}
+		lines = append(lines, line)
+	}
+	payload.Text = strings.Join(lines, "\n")
+	return payload
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
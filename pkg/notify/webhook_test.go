@@ -0,0 +1,189 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/forseti-security/config-validator/pkg/api/validator"
+	"github.com/google/go-cmp/cmp"
+)
+
+var testViolations = []*validator.Violation{
+	{
+		Constraint: "GCPStorageLoggingConstraint.require-storage-logging",
+		Resource:   "//storage.googleapis.com/my-storage-bucket",
+		Message:    "//storage.googleapis.com/my-storage-bucket does not have the required logging destination.",
+		Severity:   "high",
+	},
+	{
+		Constraint: "GCPSQLPublicIPCELConstraint.sql-no-public-ip",
+		Resource:   "//cloudsql.googleapis.com/projects/p/instances/db",
+		Message:    "//cloudsql.googleapis.com/projects/p/instances/db has a public IP address.",
+	},
+}
+
+const testText = "[high] GCPStorageLoggingConstraint.require-storage-logging: " +
+	"//storage.googleapis.com/my-storage-bucket does not have the required logging destination.\n" +
+	"GCPSQLPublicIPCELConstraint.sql-no-public-ip: //cloudsql.googleapis.com/projects/p/instances/db has a public IP address."
+
+// webhookServer answers requests with the given statuses in turn, then with 200, and records the
+// request bodies.
+func webhookServer(t *testing.T, statuses ...int) (*httptest.Server, *[][]byte) {
+	var bodies [][]byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("failed to read body: %v", err)
+		}
+		if r.Header.Get("Content-Type") != "application/json" || r.Header.Get("Authorization") != "Bearer token" {
+			t.Errorf("unexpected headers %v", r.Header)
+		}
+		bodies = append(bodies, body)
+		if len(statuses) != 0 {
+			w.WriteHeader(statuses[0])
+			statuses = statuses[1:]
+		}
+	}))
+	return server, &bodies
+}
+
+func TestWebhookFormats(t *testing.T) {
+	testCases := []struct {
+		format string
+		want   map[string]interface{}
+	}{
+		{
+			format: FormatJSON,
+			want: map[string]interface{}{"violations": []interface{}{
+				map[string]interface{}{
+					"constraint": testViolations[0].Constraint,
+					"resource":   testViolations[0].Resource,
+					"message":    testViolations[0].Message,
+					"severity":   "high",
+				},
+				map[string]interface{}{
+					"constraint": testViolations[1].Constraint,
+					"resource":   testViolations[1].Resource,
+					"message":    testViolations[1].Message,
+				},
+			}},
+		},
+		{
+			format: FormatSlack,
+			want:   map[string]interface{}{"text": "*2 new policy violations*\n" + testText},
+		},
+		{
+			format: FormatTeams,
+			want: map[string]interface{}{
+				"@type":    "MessageCard",
+				"@context": "https://schema.org/extensions",
+				"summary":  "2 new policy violations",
+				"title":    "2 new policy violations",
+				"text":     testText,
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.format, func(t *testing.T) {
+			server, bodies := webhookServer(t)
+			defer server.Close()
+			hook, err := NewWebhook(server.URL, WithFormat(tc.format), WithHeader("Authorization", "Bearer token"))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err := hook.Notify(context.Background(), testViolations); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(*bodies) != 1 {
+				t.Fatalf("got %d requests, want 1", len(*bodies))
+			}
+			var got map[string]interface{}
+			if err := json.Unmarshal((*bodies)[0], &got); err != nil {
+				t.Fatalf("body %s is not JSON: %v", (*bodies)[0], err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected body (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestWebhookTemplate(t *testing.T) {
+	server, bodies := webhookServer(t)
+	defer server.Close()
+	hook, err := NewWebhook(server.URL, WithHeader("Authorization", "Bearer token"),
+		WithTemplate(`{"count": {{len .Violations}}, "first": {{json (index .Violations 0).Resource}}}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := hook.Notify(context.Background(), testViolations); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := `{"count": 2, "first": "//storage.googleapis.com/my-storage-bucket"}`
+	if got := string((*bodies)[0]); got != want {
+		t.Errorf("got body %s, want %s", got, want)
+	}
+}
+
+func TestWebhookRetry(t *testing.T) {
+	testCases := []struct {
+		name         string
+		statuses     []int
+		wantRequests int
+		wantErr      bool
+	}{
+		{name: "retried", statuses: []int{http.StatusTooManyRequests, http.StatusServiceUnavailable}, wantRequests: 3},
+		{name: "attempts exhausted", statuses: []int{500, 500, 500}, wantRequests: 3, wantErr: true},
+		{name: "not retried", statuses: []int{http.StatusBadRequest}, wantRequests: 1, wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server, bodies := webhookServer(t, tc.statuses...)
+			defer server.Close()
+			hook, err := NewWebhook(server.URL, WithHeader("Authorization", "Bearer token"), WithRetry(3, time.Millisecond, time.Millisecond))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			err = hook.Notify(context.Background(), testViolations)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("got error %v, want error %v", err, tc.wantErr)
+			}
+			if len(*bodies) != tc.wantRequests {
+				t.Errorf("got %d requests, want %d", len(*bodies), tc.wantRequests)
+			}
+		})
+	}
+}
+
+func TestNewWebhookErrors(t *testing.T) {
+	for name, opts := range map[string][]WebhookOption{
+		"unknown format":   {WithFormat("pager")},
+		"invalid template": {WithTemplate("{{")},
+	} {
+		if _, err := NewWebhook("https://example.com/hook", opts...); err == nil {
+			t.Errorf("%s: got no error", name)
+		}
+	}
+	if _, err := NewWebhook(""); err == nil {
+		t.Errorf("missing URL: got no error")
+	}
+}
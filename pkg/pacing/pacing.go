@@ -0,0 +1,295 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package pacing rate limits outbound Google API calls per API so that large runs stay within
+// API quotas. Rates are reduced when an API responds with 429 Too Many Requests and recover as
+// requests succeed.
+package pacing
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/forseti-security/config-validator/pkg/logging"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+	"google.golang.org/api/option"
+	htransport "google.golang.org/api/transport/http"
+)
+
+const (
+	// cloudPlatformScope is requested by NewHTTPClient, it covers all APIs used by the validator.
+	cloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+	// recoveryRequests is the number of successful requests over which a reduced rate linearly
+	// recovers to the configured rate.
+	recoveryRequests = 100
+	// initialBackoff is the wait before retrying a throttled request without a Retry-After header.
+	initialBackoff = time.Second
+	// maxBackoff caps the wait between retries of a throttled request.
+	maxBackoff = 32 * time.Second
+)
+
+// Config is the pacing configuration of an API.
+type Config struct {
+	// QPS is the maximum number of requests per second, 0 disables pacing.
+	QPS float64
+	// Burst is the number of requests that may be sent at once, defaults to 1.
+	Burst int
+	// MinQPS is the rate adaptive backoff does not go below, defaults to a tenth of QPS.
+	MinQPS float64
+	// MaxRetries is the number of times a throttled request is retried, 0 disables retries.
+	MaxRetries int
+}
+
+// Stats reports the outbound requests made to an API.
+type Stats struct {
+	// Requests is the number of requests sent, including retries.
+	Requests uint64 `json:"requests"`
+	// Throttled is the number of responses with status 429.
+	Throttled uint64 `json:"throttled"`
+	// Retries is the number of throttled requests that were retried.
+	Retries uint64 `json:"retries"`
+	// QPS is the current rate, lower than the configured rate after throttling.
+	QPS float64 `json:"qps"`
+}
+
+// Transport is an http.RoundTripper that paces requests per API.
+type Transport struct {
+	base     http.RoundTripper
+	configs  map[string]Config
+	fallback Config
+	sleep    func(context.Context, time.Duration) error
+
+	mu   sync.Mutex
+	apis map[string]*api
+}
+
+var _ http.RoundTripper = &Transport{}
+
+// api holds the pacing state of a single API.
+type api struct {
+	config  Config
+	limiter *rate.Limiter
+
+	mu    sync.Mutex
+	stats Stats
+}
+
+// New returns a Transport sending requests through base. Configs holds the pacing of APIs by
+// name as returned by APIName. APIs without an entry use fallback.
+func New(base http.RoundTripper, configs map[string]Config, fallback Config) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Transport{
+		base:     base,
+		configs:  configs,
+		fallback: fallback,
+		sleep:    sleep,
+		apis:     map[string]*api{},
+	}
+}
+
+// NewHTTPClient returns an HTTP client authenticated with the application default credentials,
+// or the credentials given in opts, whose requests are paced by t. The client can be passed to
+// API clients with option.WithHTTPClient.
+func NewHTTPClient(ctx context.Context, t *Transport, opts ...option.ClientOption) (*http.Client, error) {
+	opts = append([]option.ClientOption{option.WithScopes(cloudPlatformScope)}, opts...)
+	transport, err := htransport.NewTransport(ctx, t, opts...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create authenticated transport")
+	}
+	return &http.Client{Transport: transport}, nil
+}
+
+// APIName returns the name of the API a request is sent to, the service name of the host, such
+// as bigquery for bigquery.googleapis.com. For APIs served from www.googleapis.com, the first
+// path segment is used instead.
+func APIName(req *http.Request) string {
+	host := req.URL.Hostname()
+	if host == "www.googleapis.com" {
+		parts := strings.SplitN(strings.TrimPrefix(req.URL.Path, "/"), "/", 2)
+		return parts[0]
+	}
+	return strings.TrimSuffix(host, ".googleapis.com")
+}
+
+// ParseConfigs parses per API rates in the form name=qps[,name=qps...]. Each API gets the
+// retry setting of fallback.
+func ParseConfigs(s string, fallback Config) (map[string]Config, error) {
+	configs := map[string]Config{}
+	if s == "" {
+		return configs, nil
+	}
+	for _, entry := range strings.Split(s, ",") {
+		parts := strings.SplitN(strings.TrimSpace(entry), "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, errors.Errorf("invalid API rate %q, expected name=qps", entry)
+		}
+		qps, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil || qps < 0 {
+			return nil, errors.Errorf("invalid API rate %q, expected name=qps", entry)
+		}
+		configs[parts[0]] = Config{QPS: qps, Burst: fallback.Burst, MaxRetries: fallback.MaxRetries}
+	}
+	return configs, nil
+}
+
+// Stats returns the request statistics by API name.
+func (t *Transport) Stats() map[string]Stats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	stats := map[string]Stats{}
+	for name, a := range t.apis {
+		a.mu.Lock()
+		stats[name] = a.stats
+		a.mu.Unlock()
+	}
+	return stats
+}
+
+func (t *Transport) api(name string) *api {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if a, found := t.apis[name]; found {
+		return a
+	}
+	config, found := t.configs[name]
+	if !found {
+		config = t.fallback
+	}
+	if config.Burst <= 0 {
+		config.Burst = 1
+	}
+	if config.MinQPS <= 0 || config.MinQPS > config.QPS {
+		config.MinQPS = config.QPS / 10
+	}
+	a := &api{config: config, stats: Stats{QPS: config.QPS}}
+	if config.QPS > 0 {
+		a.limiter = rate.NewLimiter(rate.Limit(config.QPS), config.Burst)
+	}
+	t.apis[name] = a
+	return a
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	name := APIName(req)
+	a := t.api(name)
+	backoff := initialBackoff
+	for attempt := 0; ; attempt++ {
+		if a.limiter != nil {
+			if err := a.limiter.Wait(ctx); err != nil {
+				return nil, err
+			}
+		}
+		attemptReq := req
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to rewind request body for retry")
+			}
+			attemptReq = new(http.Request)
+			*attemptReq = *req
+			attemptReq.Body = body
+		}
+
+		resp, err := t.base.RoundTrip(attemptReq)
+		a.record(err == nil && resp.StatusCode == http.StatusTooManyRequests)
+		if err != nil || resp.StatusCode != http.StatusTooManyRequests {
+			return resp, err
+		}
+		if attempt >= a.config.MaxRetries || !replayable(req) {
+			return resp, nil
+		}
+
+		wait := retryAfter(resp)
+		if wait == 0 {
+			wait = backoff
+			if backoff *= 2; backoff > maxBackoff {
+				backoff = maxBackoff
+			}
+		}
+		resp.Body.Close()
+		logging.FromContext(req.Context()).Debug("API throttled, retrying", zap.String("api", name), zap.Duration("wait", wait))
+		a.mu.Lock()
+		a.stats.Retries++
+		a.mu.Unlock()
+		if err := t.sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// record updates the statistics and the rate of the API after a response. Throttling halves the
+// rate down to MinQPS, success recovers it linearly towards QPS.
+func (a *api) record(throttled bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.stats.Requests++
+	if a.limiter == nil {
+		if throttled {
+			a.stats.Throttled++
+		}
+		return
+	}
+
+	qps := a.stats.QPS
+	if throttled {
+		a.stats.Throttled++
+		qps /= 2
+		if qps < a.config.MinQPS {
+			qps = a.config.MinQPS
+		}
+	} else if qps < a.config.QPS {
+		qps += a.config.QPS / recoveryRequests
+		if qps > a.config.QPS {
+			qps = a.config.QPS
+		}
+	}
+	if qps != a.stats.QPS {
+		a.stats.QPS = qps
+		a.limiter.SetLimit(rate.Limit(qps))
+	}
+}
+
+// replayable returns whether the body of req can be sent again.
+func replayable(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// retryAfter returns the wait requested by the Retry-After header of resp in seconds, zero if
+// it is absent or not in seconds.
+func retryAfter(resp *http.Response) time.Duration {
+	seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
@@ -0,0 +1,224 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pacing
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// fakeTransport answers requests with the next status in statuses, then with 200.
+type fakeTransport struct {
+	statuses   []int
+	retryAfter string
+	bodies     []string
+}
+
+func (f *fakeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body := ""
+	if req.Body != nil {
+		b, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		body = string(b)
+	}
+	f.bodies = append(f.bodies, body)
+
+	status := http.StatusOK
+	if len(f.statuses) > 0 {
+		status, f.statuses = f.statuses[0], f.statuses[1:]
+	}
+	resp := &http.Response{
+		StatusCode: status,
+		Header:     http.Header{},
+		Body:       ioutil.NopCloser(strings.NewReader("")),
+	}
+	if status == http.StatusTooManyRequests && f.retryAfter != "" {
+		resp.Header.Set("Retry-After", f.retryAfter)
+	}
+	return resp, nil
+}
+
+// newTestTransport returns a Transport that records sleeps instead of sleeping.
+func newTestTransport(base http.RoundTripper, configs map[string]Config, fallback Config) (*Transport, *[]time.Duration) {
+	var sleeps []time.Duration
+	t := New(base, configs, fallback)
+	t.sleep = func(ctx context.Context, d time.Duration) error {
+		sleeps = append(sleeps, d)
+		return nil
+	}
+	return t, &sleeps
+}
+
+func TestAPIName(t *testing.T) {
+	var testCases = []struct {
+		url  string
+		want string
+	}{
+		{url: "https://bigquery.googleapis.com/bigquery/v2/projects/p", want: "bigquery"},
+		{url: "https://www.googleapis.com/bigquery/v2/projects/p", want: "bigquery"},
+		{url: "https://www.googleapis.com/storage/v1/b/bucket", want: "storage"},
+		{url: "https://cloudresourcemanager.googleapis.com/v1/projects/p", want: "cloudresourcemanager"},
+		{url: "http://127.0.0.1:8080/v1/projects/p", want: "127.0.0.1"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.url, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, tc.url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := APIName(req); got != tc.want {
+				t.Errorf("got %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseConfigs(t *testing.T) {
+	got, err := ParseConfigs("bigquery=50, cloudidentity=2.5", Config{MaxRetries: 3})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]Config{
+		"bigquery":      {QPS: 50, MaxRetries: 3},
+		"cloudidentity": {QPS: 2.5, MaxRetries: 3},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected configs (-want +got):\n%s", diff)
+	}
+
+	for _, invalid := range []string{"bigquery", "=5", "bigquery=fast", "bigquery=-1"} {
+		if _, err := ParseConfigs(invalid, Config{}); err == nil {
+			t.Errorf("%q: expected error", invalid)
+		}
+	}
+}
+
+func TestRetryThrottled(t *testing.T) {
+	base := &fakeTransport{
+		statuses:   []int{http.StatusTooManyRequests, http.StatusTooManyRequests},
+		retryAfter: "3",
+	}
+	transport, sleeps := newTestTransport(base, map[string]Config{
+		"bigquery": {QPS: 1000, Burst: 10, MaxRetries: 5},
+	}, Config{})
+
+	req, err := http.NewRequest(http.MethodPost, "https://bigquery.googleapis.com/insertAll", bytes.NewBufferString("rows"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("got status %d, want 200", resp.StatusCode)
+	}
+	if diff := cmp.Diff([]string{"rows", "rows", "rows"}, base.bodies); diff != "" {
+		t.Errorf("unexpected request bodies (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]time.Duration{3 * time.Second, 3 * time.Second}, *sleeps); diff != "" {
+		t.Errorf("unexpected sleeps (-want +got):\n%s", diff)
+	}
+
+	// Two halvings followed by one success.
+	want := Stats{Requests: 3, Throttled: 2, Retries: 2, QPS: 250 + 10}
+	if got := transport.Stats()["bigquery"]; got != want {
+		t.Errorf("got stats %+v, want %+v", got, want)
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	base := &fakeTransport{statuses: []int{429, 429, 429, 429}}
+	transport, sleeps := newTestTransport(base, nil, Config{QPS: 1000, MinQPS: 400, MaxRetries: 3})
+
+	req, err := http.NewRequest(http.MethodGet, "https://www.googleapis.com/storage/v1/b", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("got status %d, want 429 after exhausting retries", resp.StatusCode)
+	}
+	if diff := cmp.Diff([]time.Duration{time.Second, 2 * time.Second, 4 * time.Second}, *sleeps); diff != "" {
+		t.Errorf("unexpected sleeps (-want +got):\n%s", diff)
+	}
+	if got := transport.Stats()["storage"]; got.QPS != 400 {
+		t.Errorf("got QPS %v, want MinQPS 400", got.QPS)
+	}
+}
+
+func TestNoRetryWithoutRewindableBody(t *testing.T) {
+	base := &fakeTransport{statuses: []int{429}}
+	transport, sleeps := newTestTransport(base, nil, Config{MaxRetries: 3})
+
+	req, err := http.NewRequest(http.MethodPost, "https://pubsub.googleapis.com/v1/topics/t:publish", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Body = ioutil.NopCloser(strings.NewReader("message"))
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.StatusCode != http.StatusTooManyRequests || len(*sleeps) != 0 {
+		t.Errorf("got status %d after %d retries, want 429 without retries", resp.StatusCode, len(*sleeps))
+	}
+}
+
+func TestPacing(t *testing.T) {
+	transport := New(&fakeTransport{}, map[string]Config{"bigquery": {QPS: 100}}, Config{})
+	start := time.Now()
+	for i := 0; i < 6; i++ {
+		req, err := http.NewRequest(http.MethodGet, "https://bigquery.googleapis.com/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := transport.RoundTrip(req); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 45*time.Millisecond {
+		t.Errorf("6 requests at 100 QPS took %s, want at least 50ms", elapsed)
+	}
+}
+
+func TestPacingCancelled(t *testing.T) {
+	transport := New(&fakeTransport{}, nil, Config{QPS: 0.001})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req, err := http.NewRequest(http.MethodGet, "https://bigquery.googleapis.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The first request uses the burst, the second has to wait.
+	if _, err := transport.RoundTrip(req); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := transport.RoundTrip(req.WithContext(ctx)); err == nil {
+		t.Errorf("expected error for cancelled context")
+	}
+}
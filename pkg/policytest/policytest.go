@@ -0,0 +1,406 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package policytest runs policy unit tests, which review fixture assets with the policies they
+// are stored alongside and compare the violations to the expected ones. Tests are PolicyTest
+// documents in the YAML files of the policy directories:
+//
+//	apiVersion: policytest.forsetisecurity.org/v1alpha1
+//	kind: PolicyTest
+//	metadata:
+//	  name: storage-logging
+//	spec:
+//	  # Only violations of these constraints are compared, defaults to all constraints.
+//	  constraints: [require-storage-logging]
+//	  cases:
+//	  - name: bucket without logging
+//	    # JSON files of assets relative to the test file, with one asset, an array of assets or
+//	    # newline delimited assets.
+//	    assetFiles: [bucket_without_logging.json]
+//	    # Assets can also be given inline.
+//	    assets: []
+//	    violations:
+//	    - constraint: require-storage-logging
+//	      # Optional name of the violating asset and substring of the violation message.
+//	      resource: //storage.googleapis.com/my-storage-bucket
+//	      message: logging
+//
+// The assets of a case are reviewed together, so templates can reference other assets of the
+// case through data.inventory. Loading policies ignores PolicyTest documents.
+package policytest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/forseti-security/config-validator/pkg/gcv"
+	"github.com/forseti-security/config-validator/pkg/gcv/configs"
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+)
+
+const (
+	// APIVersion is the apiVersion of PolicyTest documents.
+	APIVersion = "policytest.forsetisecurity.org/v1alpha1"
+	// Kind is the kind of PolicyTest documents.
+	Kind = "PolicyTest"
+)
+
+// Expectation is an expected violation.
+type Expectation struct {
+	// Constraint is the name of the violated constraint, as declared in its file.
+	Constraint string `json:"constraint"`
+	// Resource is the name of the violating asset, any asset if empty.
+	Resource string `json:"resource,omitempty"`
+	// Message is a substring of the violation message, any message if empty.
+	Message string `json:"message,omitempty"`
+}
+
+func (e Expectation) String() string {
+	s := e.Constraint
+	if e.Resource != "" {
+		s += " on " + e.Resource
+	}
+	if e.Message != "" {
+		s += fmt.Sprintf(" with message containing %q", e.Message)
+	}
+	return s
+}
+
+// Case is a set of assets and the violations they are expected to have.
+type Case struct {
+	Name       string                   `json:"name"`
+	Assets     []map[string]interface{} `json:"assets,omitempty"`
+	AssetFiles []string                 `json:"assetFiles,omitempty"`
+	Violations []Expectation            `json:"violations,omitempty"`
+}
+
+// Suite is a PolicyTest document.
+type Suite struct {
+	// Name is the name of the PolicyTest.
+	Name string
+	// Path is the file the PolicyTest was loaded from.
+	Path string
+	// Constraints limits the compared violations to these constraints if set.
+	Constraints []string
+	Cases       []*Case
+}
+
+type policyTest struct {
+	Metadata struct {
+		Name string `json:"name"`
+	} `json:"metadata"`
+	Spec struct {
+		Constraints []string `json:"constraints"`
+		Cases       []*Case  `json:"cases"`
+	} `json:"spec"`
+}
+
+// Load returns the PolicyTest suites in the YAML files below policyPaths, reading the asset files
+// of their cases.
+func Load(ctx context.Context, policyPaths []string) ([]*Suite, error) {
+	var suites []*Suite
+	for _, policyPath := range policyPaths {
+		path, err := configs.NewPath(policyPath)
+		if err != nil {
+			return nil, err
+		}
+		files, err := path.ReadAll(ctx, configs.SuffixPredicate(".yaml"))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			fileSuites, err := loadFile(ctx, file)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to load tests from %s", file.Path)
+			}
+			suites = append(suites, fileSuites...)
+		}
+	}
+	return suites, nil
+}
+
+func loadFile(ctx context.Context, file configs.File) ([]*Suite, error) {
+	var suites []*Suite
+	for _, rawDoc := range strings.Split(string(file.Content), "\n---") {
+		document := strings.TrimLeft(rawDoc, "\n ")
+		if len(document) == 0 {
+			continue
+		}
+		jsonDoc, err := yaml.YAMLToJSON([]byte(document))
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid yaml")
+		}
+		var typeMeta struct {
+			APIVersion string `json:"apiVersion"`
+			Kind       string `json:"kind"`
+		}
+		if err := json.Unmarshal(jsonDoc, &typeMeta); err != nil || typeMeta.APIVersion != APIVersion || typeMeta.Kind != Kind {
+			continue
+		}
+		var pt policyTest
+		if err := unmarshalJSON(jsonDoc, &pt); err != nil {
+			return nil, errors.Wrapf(err, "invalid %s", Kind)
+		}
+
+		suite := &Suite{
+			Name:        pt.Metadata.Name,
+			Path:        file.Path,
+			Constraints: pt.Spec.Constraints,
+			Cases:       pt.Spec.Cases,
+		}
+		for idx, c := range suite.Cases {
+			if c.Name == "" {
+				c.Name = fmt.Sprintf("case-%d", idx)
+			}
+			for _, assetFile := range c.AssetFiles {
+				assets, err := readAssets(ctx, configs.RelativePath(file.Path, assetFile))
+				if err != nil {
+					return nil, errors.Wrapf(err, "%s case %s", suite.Name, c.Name)
+				}
+				c.Assets = append(c.Assets, assets...)
+			}
+		}
+		suites = append(suites, suite)
+	}
+	return suites, nil
+}
+
+// readAssets reads a JSON file with one asset, an array of assets or newline delimited assets.
+func readAssets(ctx context.Context, assetPath string) ([]map[string]interface{}, error) {
+	path, err := configs.NewPath(assetPath)
+	if err != nil {
+		return nil, err
+	}
+	files, err := path.ReadAll(ctx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read assets")
+	}
+	var assets []map[string]interface{}
+	for _, file := range files {
+		decoder := json.NewDecoder(bytes.NewReader(file.Content))
+		decoder.UseNumber()
+		for decoder.More() {
+			var value interface{}
+			if err := decoder.Decode(&value); err != nil {
+				return nil, errors.Wrapf(err, "failed to decode assets in %s", file.Path)
+			}
+			switch v := value.(type) {
+			case map[string]interface{}:
+				assets = append(assets, v)
+			case []interface{}:
+				for _, item := range v {
+					asset, ok := item.(map[string]interface{})
+					if !ok {
+						return nil, errors.Errorf("invalid asset in %s, want an object, got %T", file.Path, item)
+					}
+					assets = append(assets, asset)
+				}
+			default:
+				return nil, errors.Errorf("invalid assets in %s, want an object or array, got %T", file.Path, value)
+			}
+		}
+	}
+	return assets, nil
+}
+
+// unmarshalJSON decodes data into v, keeping numbers as json.Number like assets reviewed from
+// CAI exports.
+func unmarshalJSON(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+// CaseResult is the result of running a case.
+type CaseResult struct {
+	Suite *Suite
+	Case  *Case
+	// Failures describes how the violations differ from the expected ones, empty if the case
+	// passed.
+	Failures []string
+}
+
+// Passed returns true if the violations of the case were the expected ones.
+func (r *CaseResult) Passed() bool {
+	return len(r.Failures) == 0
+}
+
+// Name returns the name of the case, prefixed with the name of its suite.
+func (r *CaseResult) Name() string {
+	return r.Suite.Name + "/" + r.Case.Name
+}
+
+// Report holds the results of running suites.
+type Report struct {
+	Results []*CaseResult
+}
+
+// Failed returns the number of failed cases.
+func (r *Report) Failed() int {
+	failed := 0
+	for _, result := range r.Results {
+		if !result.Passed() {
+			failed++
+		}
+	}
+	return failed
+}
+
+// Write writes the outcome of each failed case, and of passed cases if verbose, followed by a
+// summary.
+func (r *Report) Write(w io.Writer, verbose bool) error {
+	for _, result := range r.Results {
+		if result.Passed() {
+			if verbose {
+				if _, err := fmt.Fprintf(w, "--- PASS: %s\n", result.Name()); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "--- FAIL: %s (%s)\n", result.Name(), result.Suite.Path); err != nil {
+			return err
+		}
+		for _, failure := range result.Failures {
+			if _, err := fmt.Fprintf(w, "    %s\n", failure); err != nil {
+				return err
+			}
+		}
+	}
+	status := "PASS"
+	if r.Failed() > 0 {
+		status = "FAIL"
+	}
+	_, err := fmt.Fprintf(w, "%s: %d passed, %d failed\n", status, len(r.Results)-r.Failed(), r.Failed())
+	return err
+}
+
+// violation is a violation found while running a case.
+type violation struct {
+	constraints []string
+	resource    string
+	message     string
+}
+
+func (v *violation) matches(e Expectation) bool {
+	return v.hasConstraint(e.Constraint) &&
+		(e.Resource == "" || e.Resource == v.resource) &&
+		strings.Contains(v.message, e.Message)
+}
+
+func (v *violation) hasConstraint(name string) bool {
+	for _, constraint := range v.constraints {
+		if constraint == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *violation) hasAnyConstraint(names []string) bool {
+	for _, name := range names {
+		if v.hasConstraint(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Run runs the cases of suites with validator.
+func Run(ctx context.Context, validator *gcv.Validator, suites []*Suite) (*Report, error) {
+	report := &Report{}
+	for _, suite := range suites {
+		for _, c := range suite.Cases {
+			result, err := runCase(ctx, validator, suite, c)
+			if err != nil {
+				return nil, errors.Wrapf(err, "%s/%s", suite.Name, c.Name)
+			}
+			report.Results = append(report.Results, result)
+		}
+	}
+	return report, nil
+}
+
+func runCase(ctx context.Context, validator *gcv.Validator, suite *Suite, c *Case) (*CaseResult, error) {
+	// Reviews modify assets, leave the case as loaded.
+	assets := make([]map[string]interface{}, len(c.Assets))
+	for idx, asset := range c.Assets {
+		copied, err := deepCopy(asset)
+		if err != nil {
+			return nil, err
+		}
+		assets[idx] = copied
+	}
+	results, err := validator.ReviewInventory(ctx, assets)
+	if err != nil {
+		return nil, err
+	}
+
+	var violations []*violation
+	for _, result := range results {
+		for _, cv := range result.ConstraintViolations {
+			v := &violation{
+				constraints: []string{cv.Constraint.GetName()},
+				resource:    result.Name,
+				message:     cv.Message,
+			}
+			if originalName, found := cv.Constraint.GetAnnotations()[configs.OriginalName]; found {
+				v.constraints = append(v.constraints, originalName)
+			}
+			if len(suite.Constraints) != 0 && !v.hasAnyConstraint(suite.Constraints) {
+				continue
+			}
+			violations = append(violations, v)
+		}
+	}
+
+	caseResult := &CaseResult{Suite: suite, Case: c}
+	matched := make([]bool, len(violations))
+	for _, expectation := range c.Violations {
+		found := false
+		for idx, v := range violations {
+			if !matched[idx] && v.matches(expectation) {
+				matched[idx], found = true, true
+				break
+			}
+		}
+		if !found {
+			caseResult.Failures = append(caseResult.Failures, fmt.Sprintf("missing violation of %s", expectation))
+		}
+	}
+	for idx, v := range violations {
+		if !matched[idx] {
+			caseResult.Failures = append(caseResult.Failures,
+				fmt.Sprintf("unexpected violation of %s on %s: %s", v.constraints[len(v.constraints)-1], v.resource, v.message))
+		}
+	}
+	return caseResult, nil
+}
+
+func deepCopy(asset map[string]interface{}) (map[string]interface{}, error) {
+	encoded, err := json.Marshal(asset)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to copy asset")
+	}
+	var copied map[string]interface{}
+	if err := unmarshalJSON(encoded, &copied); err != nil {
+		return nil, errors.Wrapf(err, "failed to copy asset")
+	}
+	return copied, nil
+}
@@ -0,0 +1,121 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policytest
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/forseti-security/config-validator/pkg/gcv"
+	"github.com/google/go-cmp/cmp"
+)
+
+const (
+	testPolicies = "../../test/cf"
+	// testSuites holds the policy tests of the constraints in testPolicies.
+	testSuites = "testdata"
+	testLibs   = "../../test/cf/library"
+)
+
+func TestLoad(t *testing.T) {
+	suites, err := Load(context.Background(), []string{testSuites})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(suites) != 1 {
+		t.Fatalf("got %d suites, want 1", len(suites))
+	}
+	suite := suites[0]
+	if suite.Name != "storage-logging" || suite.Path != "testdata/storage_logging_test.yaml" {
+		t.Errorf("got suite %s from %s", suite.Name, suite.Path)
+	}
+	var got []int
+	for _, c := range suite.Cases {
+		got = append(got, len(c.Assets))
+	}
+	// Asset files are read into the assets of their case.
+	if diff := cmp.Diff([]int{2, 1}, got); diff != "" {
+		t.Errorf("unexpected number of assets (-want +got):\n%s", diff)
+	}
+}
+
+func TestRun(t *testing.T) {
+	ctx := context.Background()
+	v, err := gcv.NewValidator(gcv.WithPolicyPaths(testPolicies), gcv.WithPolicyLibrary(testLibs))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	suites, err := Load(ctx, []string{testSuites})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	bucket := suites[0].Cases[0].Assets[0]
+	suites = append(suites, &Suite{
+		Name:        "failing",
+		Constraints: []string{"require-storage-logging"},
+		Cases: []*Case{
+			{
+				Name:   "unexpected",
+				Assets: []map[string]interface{}{bucket},
+			},
+			{
+				Name:   "missing",
+				Assets: []map[string]interface{}{bucket},
+				Violations: []Expectation{
+					{Constraint: "require-storage-logging"},
+					{Constraint: "require-storage-logging", Message: "other message"},
+				},
+			},
+		},
+	})
+
+	report, err := Run(ctx, v, suites)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := map[string][]string{}
+	for _, result := range report.Results {
+		got[result.Name()] = result.Failures
+	}
+	want := map[string][]string{
+		"storage-logging/buckets":             nil,
+		"storage-logging/bucket with logging": nil,
+		"failing/unexpected": {
+			"unexpected violation of require-storage-logging on //storage.googleapis.com/my-storage-bucket: " +
+				"//storage.googleapis.com/my-storage-bucket does not have the required logging destination.",
+		},
+		"failing/missing": {`missing violation of require-storage-logging with message containing "other message"`},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected failures (-want +got):\n%s", diff)
+	}
+	if report.Failed() != 2 {
+		t.Errorf("got %d failed cases, want 2", report.Failed())
+	}
+
+	var out bytes.Buffer
+	if err := report.Write(&out, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(out.String(), "--- FAIL: failing/missing") || !strings.HasSuffix(out.String(), "FAIL: 2 passed, 2 failed\n") {
+		t.Errorf("unexpected report:\n%s", out.String())
+	}
+	// The cases are left as loaded.
+	if _, found := bucket["ancestry_path"]; !found || len(bucket) != 4 {
+		t.Errorf("asset was modified: %v", bucket)
+	}
+}
@@ -0,0 +1,203 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ratelimit caps the reviews of each client of the validator server, by the number of
+// concurrent reviews and the rate of reviewed assets, so that one client cannot starve the
+// others of a shared server.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// concurrencyRetryDelay is the retry hint of reviews rejected for concurrency, whose end
+	// cannot be told.
+	concurrencyRetryDelay = time.Second
+	// sweepInterval is how often the state of idle clients is dropped.
+	sweepInterval = time.Minute
+)
+
+// Options are the limits applied to each client. Zero values disable a limit.
+type Options struct {
+	// MaxConcurrent is the number of reviews a client may run at once.
+	MaxConcurrent int
+	// AssetsPerSecond is the sustained rate of assets a client may review.
+	AssetsPerSecond float64
+	// Burst is the number of assets an idle client may review at once, defaults to one second of
+	// AssetsPerSecond. Requests with more assets are admitted, then hold back the next requests of
+	// the client until the rate catches up.
+	Burst int
+}
+
+// Stats reports the reviews rejected by a Limiter.
+type Stats struct {
+	// Clients is the number of clients the limiter currently tracks.
+	Clients int `json:"clients"`
+	// Active is the number of reviews running.
+	Active int `json:"active"`
+	// RejectedConcurrency is the number of reviews rejected for MaxConcurrent.
+	RejectedConcurrency uint64 `json:"rejected_concurrency"`
+	// RejectedRate is the number of reviews rejected for AssetsPerSecond.
+	RejectedRate uint64 `json:"rejected_rate"`
+}
+
+// Limiter admits the reviews of clients within their limits. It is safe for concurrent use.
+type Limiter struct {
+	opts Options
+	now  func() time.Time
+
+	mu        sync.Mutex
+	clients   map[string]*client
+	stats     Stats
+	lastSweep time.Time
+}
+
+// client is the state of one client: its running reviews and its token bucket of assets, which
+// goes negative after requests larger than the burst.
+type client struct {
+	active int
+	tokens float64
+	last   time.Time
+}
+
+// New returns the Limiter applying opts to each client.
+func New(opts Options) *Limiter {
+	if opts.Burst <= 0 {
+		opts.Burst = int(math.Ceil(opts.AssetsPerSecond))
+	}
+	return &Limiter{opts: opts, now: time.Now, clients: map[string]*client{}}
+}
+
+// Acquire admits a review of assets by the client key, and returns the function that must be
+// called once the review is done. Reviews over the limits of the client are rejected with a
+// ResourceExhausted status whose RetryInfo tells when to retry.
+func (l *Limiter) Acquire(key string, assets int) (release func(), err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	l.sweep(now)
+	c, found := l.clients[key]
+	if !found {
+		c = &client{tokens: float64(l.opts.Burst), last: now}
+		l.clients[key] = c
+	}
+	if l.opts.MaxConcurrent > 0 && c.active >= l.opts.MaxConcurrent {
+		l.stats.RejectedConcurrency++
+		return nil, exhausted(concurrencyRetryDelay, "client %s is running %d reviews, the most allowed at once", key, c.active)
+	}
+	if l.opts.AssetsPerSecond > 0 {
+		c.refill(now, l.opts)
+		if c.tokens <= 0 {
+			l.stats.RejectedRate++
+			delay := time.Duration(math.Ceil(-c.tokens/l.opts.AssetsPerSecond*1000)+1) * time.Millisecond
+			return nil, exhausted(delay, "client %s exceeded %g assets per second", key, l.opts.AssetsPerSecond)
+		}
+		c.tokens -= float64(assets)
+	}
+	c.active++
+	l.stats.Active++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			c.active--
+			l.stats.Active--
+		})
+	}, nil
+}
+
+// refill adds the tokens earned since the last refill, up to the burst.
+func (c *client) refill(now time.Time, opts Options) {
+	c.tokens = math.Min(float64(opts.Burst), c.tokens+now.Sub(c.last).Seconds()*opts.AssetsPerSecond)
+	c.last = now
+}
+
+// sweep drops the clients without running reviews whose bucket refilled, at most once per
+// sweepInterval. It must be called with mu held.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for key, c := range l.clients {
+		if c.active != 0 {
+			continue
+		}
+		if l.opts.AssetsPerSecond > 0 {
+			c.refill(now, l.opts)
+			if c.tokens < float64(l.opts.Burst) {
+				continue
+			}
+		}
+		delete(l.clients, key)
+	}
+}
+
+// Stats returns the statistics of the limiter.
+func (l *Limiter) Stats() Stats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	stats := l.stats
+	stats.Clients = len(l.clients)
+	return stats
+}
+
+// exhausted returns a ResourceExhausted status error with a RetryInfo of delay.
+func exhausted(delay time.Duration, format string, args ...interface{}) error {
+	s := status.Newf(codes.ResourceExhausted, format, args...)
+	if detailed, err := s.WithDetails(&errdetails.RetryInfo{RetryDelay: ptypes.DurationProto(delay)}); err == nil {
+		s = detailed
+	}
+	return s.Err()
+}
+
+// RetryDelay returns the retry hint of an error returned by Acquire, 0 if it has none.
+func RetryDelay(err error) time.Duration {
+	for _, detail := range status.Convert(err).Details() {
+		if info, ok := detail.(*errdetails.RetryInfo); ok {
+			if delay, err := ptypes.Duration(info.RetryDelay); err == nil {
+				return delay
+			}
+		}
+	}
+	return 0
+}
+
+// ClientKey returns the key identifying the client of the call of ctx: name, if set, such as the
+// authenticated identity of the caller, otherwise the IP address of the peer.
+func ClientKey(ctx context.Context, name string) string {
+	if name != "" {
+		return name
+	}
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return "unknown"
+	}
+	if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+		return host
+	}
+	return p.Addr.String()
+}
@@ -0,0 +1,127 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ratelimit
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func TestConcurrency(t *testing.T) {
+	l := New(Options{MaxConcurrent: 2})
+	first, err := l.Acquire("a", 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := l.Acquire("a", 1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, err = l.Acquire("a", 1)
+	if status.Code(err) != codes.ResourceExhausted || RetryDelay(err) != concurrencyRetryDelay {
+		t.Fatalf("got error %v with retry delay %s, want resource exhausted after %s", err, RetryDelay(err), concurrencyRetryDelay)
+	}
+	// Other clients have their own limits.
+	if _, err := l.Acquire("b", 1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	first()
+	first()
+	if _, err := l.Acquire("a", 1); err != nil {
+		t.Fatalf("unexpected error after release: %v", err)
+	}
+	want := Stats{Clients: 2, Active: 3, RejectedConcurrency: 1}
+	if got := l.Stats(); got != want {
+		t.Errorf("got stats %+v, want %+v", got, want)
+	}
+}
+
+func TestAssetsPerSecond(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	l := New(Options{AssetsPerSecond: 10})
+	l.now = func() time.Time { return now }
+	acquire := func(assets int) error {
+		return acquireAndRelease(l, "a", assets)
+	}
+
+	// The burst defaults to one second of assets, larger requests are admitted into debt.
+	if err := acquire(25); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err := acquire(1)
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("got error %v, want resource exhausted", err)
+	}
+	// 15 assets of debt take 1.5s to repay.
+	if delay := RetryDelay(err); delay < 1500*time.Millisecond || delay > 1510*time.Millisecond {
+		t.Errorf("got retry delay %s, want 1.5s", delay)
+	}
+	now = now.Add(time.Second)
+	if err := acquire(1); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("got error %v, want resource exhausted", err)
+	}
+	now = now.Add(600 * time.Millisecond)
+	if err := acquire(1); err != nil {
+		t.Fatalf("unexpected error once the rate caught up: %v", err)
+	}
+	if got := l.Stats().RejectedRate; got != 2 {
+		t.Errorf("got %d rejections, want 2", got)
+	}
+
+	// Idle clients whose bucket refilled are dropped.
+	now = now.Add(sweepInterval)
+	if err := acquireAndRelease(l, "b", 1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := l.Stats().Clients; got != 1 {
+		t.Errorf("got %d clients after sweep, want 1", got)
+	}
+}
+
+// acquireAndRelease admits and ends a review of assets by key.
+func acquireAndRelease(l *Limiter, key string, assets int) error {
+	release, err := l.Acquire(key, assets)
+	if err == nil {
+		release()
+	}
+	return err
+}
+
+func TestUnlimited(t *testing.T) {
+	l := New(Options{})
+	for i := 0; i < 100; i++ {
+		if _, err := l.Acquire("a", 1000); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+}
+
+func TestClientKey(t *testing.T) {
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 1234}})
+	if got := ClientKey(ctx, ""); got != "10.0.0.1" {
+		t.Errorf("got key %q, want the peer IP", got)
+	}
+	if got := ClientKey(ctx, "ci@example.com"); got != "ci@example.com" {
+		t.Errorf("got key %q, want the name", got)
+	}
+	if got := ClientKey(context.Background(), ""); got != "unknown" {
+		t.Errorf("got key %q without peer, want unknown", got)
+	}
+}
@@ -0,0 +1,273 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package recommender synchronizes the insights produced by a review with an insight store such
+// as the Recommender API.
+package recommender
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/forseti-security/config-validator/pkg/gcv"
+	"github.com/forseti-security/config-validator/pkg/logging"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"google.golang.org/api/googleapi"
+)
+
+const (
+	// StateActive is the state of an insight that is currently reported.
+	StateActive = "ACTIVE"
+	// StateInactive is the state of an insight whose underlying violation has been resolved.
+	StateInactive = "INACTIVE"
+)
+
+// API is the insight store that a Client synchronizes with. Errors of type *googleapi.Error are
+// inspected to decide whether a call is retried.
+type API interface {
+	// List returns all insights stored under parent.
+	List(ctx context.Context, parent string) ([]*gcv.Insight, error)
+	// Upsert creates or replaces the given insights under parent. Insights with a non-empty Etag
+	// must only be replaced if the stored insight still has that etag.
+	Upsert(ctx context.Context, parent string, insights []*gcv.Insight) error
+	// SetState changes the state of the named insight if it still has the given etag.
+	SetState(ctx context.Context, name, etag, state string) error
+}
+
+// Client uploads insights in batches and retires insights that are no longer reported.
+type Client struct {
+	api            API
+	batchSize      int
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	sleep          func(ctx context.Context, d time.Duration) error
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithBatchSize sets the maximum number of insights sent in a single Upsert call.
+func WithBatchSize(size int) Option {
+	return func(c *Client) {
+		c.batchSize = size
+	}
+}
+
+// WithRetry sets the number of attempts made for each API call and the exponential backoff
+// between attempts.
+func WithRetry(maxAttempts int, initialBackoff, maxBackoff time.Duration) Option {
+	return func(c *Client) {
+		c.maxAttempts = maxAttempts
+		c.initialBackoff = initialBackoff
+		c.maxBackoff = maxBackoff
+	}
+}
+
+// NewClient returns a Client for api.
+func NewClient(api API, opts ...Option) *Client {
+	c := &Client{
+		api:            api,
+		batchSize:      100,
+		maxAttempts:    5,
+		initialBackoff: time.Second,
+		maxBackoff:     30 * time.Second,
+		sleep:          sleep,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.batchSize < 1 {
+		c.batchSize = 1
+	}
+	if c.maxAttempts < 1 {
+		c.maxAttempts = 1
+	}
+	return c
+}
+
+// SyncResult summarizes the changes made by Sync.
+type SyncResult struct {
+	// Upserted is the number of insights that were created or updated.
+	Upserted int
+	// Unchanged is the number of insights that were already stored as reported.
+	Unchanged int
+	// Deactivated is the number of stored insights that were marked inactive because they were
+	// no longer reported.
+	Deactivated int
+}
+
+// InsightName returns the stable name of an insight under parent. The name is derived from the
+// subtype and target resources, so the same violation maps to the same stored insight across runs.
+func InsightName(parent string, insight *gcv.Insight) string {
+	targets := append([]string(nil), insight.TargetResources...)
+	sort.Strings(targets)
+	h := sha256.New()
+	_, _ = h.Write([]byte(insight.InsightSubtype))
+	for _, target := range targets {
+		_, _ = h.Write([]byte{0})
+		_, _ = h.Write([]byte(target))
+	}
+	return parent + "/insights/" + hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+// Sync makes the active insights stored under parent match insights. New and changed insights
+// are upserted, using the stored etag for changed ones, and active stored insights that are not
+// in insights are marked inactive. The passed insights are not modified.
+func (c *Client) Sync(ctx context.Context, parent string, insights []*gcv.Insight) (*SyncResult, error) {
+	var existing []*gcv.Insight
+	err := c.retry(ctx, "list insights", func() error {
+		var err error
+		existing, err = c.api.List(ctx, parent)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	stored := map[string]*gcv.Insight{}
+	for _, insight := range existing {
+		stored[insight.Name] = insight
+	}
+
+	result := &SyncResult{}
+	reported := map[string]bool{}
+	var pending []*gcv.Insight
+	for _, insight := range insights {
+		upload := *insight
+		if upload.Name == "" {
+			upload.Name = InsightName(parent, insight)
+		}
+		upload.StateInfo = gcv.StateInfo{State: StateActive}
+		upload.Etag = ""
+		if reported[upload.Name] {
+			logging.FromContext(ctx).Warn("skipping duplicate insight", zap.String("insight", upload.Name))
+			continue
+		}
+		reported[upload.Name] = true
+
+		if prev, found := stored[upload.Name]; found {
+			if prev.StateInfo.State == StateActive && sameContent(prev, &upload) {
+				result.Unchanged++
+				continue
+			}
+			upload.Etag = prev.Etag
+		}
+		pending = append(pending, &upload)
+	}
+
+	for start := 0; start < len(pending); start += c.batchSize {
+		end := start + c.batchSize
+		if end > len(pending) {
+			end = len(pending)
+		}
+		batch := pending[start:end]
+		if err := c.retry(ctx, "upsert insights", func() error {
+			return c.api.Upsert(ctx, parent, batch)
+		}); err != nil {
+			return result, err
+		}
+		result.Upserted += len(batch)
+	}
+
+	for _, prev := range existing {
+		if reported[prev.Name] || prev.StateInfo.State != StateActive {
+			continue
+		}
+		if err := c.retry(ctx, "deactivate insight "+prev.Name, func() error {
+			return c.api.SetState(ctx, prev.Name, prev.Etag, StateInactive)
+		}); err != nil {
+			return result, err
+		}
+		result.Deactivated++
+	}
+	return result, nil
+}
+
+// sameContent returns true if the stored insight has the same reported content as insight.
+func sameContent(stored, insight *gcv.Insight) bool {
+	if stored.Description != insight.Description ||
+		stored.InsightSubtype != insight.InsightSubtype ||
+		stored.Category != insight.Category ||
+		!reflect.DeepEqual(stored.TargetResources, insight.TargetResources) {
+		return false
+	}
+	// Stored content has been through JSON, compare the JSON forms.
+	storedContent, err := json.Marshal(stored.Content)
+	if err != nil {
+		return false
+	}
+	content, err := json.Marshal(insight.Content)
+	if err != nil {
+		return false
+	}
+	return string(storedContent) == string(content)
+}
+
+// retry calls fn until it succeeds, returns a non retryable error, or the attempts are exhausted.
+func (c *Client) retry(ctx context.Context, op string, fn func() error) error {
+	backoff := c.initialBackoff
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = fn(); err == nil {
+			return nil
+		}
+		if !isRetryable(err) || attempt >= c.maxAttempts {
+			break
+		}
+		logging.FromContext(ctx).Debug("retrying", zap.String("op", op), zap.Int("attempt", attempt), zap.Int("max_attempts", c.maxAttempts),
+			zap.Duration("backoff", backoff), zap.Error(err))
+		if sleepErr := c.sleep(ctx, backoff); sleepErr != nil {
+			return errors.Wrapf(sleepErr, "%s: %s", op, err)
+		}
+		backoff *= 2
+		if backoff > c.maxBackoff {
+			backoff = c.maxBackoff
+		}
+	}
+	return errors.Wrapf(err, "failed to %s", op)
+}
+
+// isRetryable returns true for rate limiting, transient server and temporary network errors.
+func isRetryable(err error) bool {
+	cause := errors.Cause(err)
+	if temporary, ok := cause.(interface{ Temporary() bool }); ok && temporary.Temporary() {
+		return true
+	}
+	apiErr, ok := cause.(*googleapi.Error)
+	if !ok {
+		return false
+	}
+	switch apiErr.Code {
+	case 429, 500, 502, 503, 504:
+		return true
+	}
+	return false
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,233 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recommender
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/forseti-security/config-validator/pkg/gcv"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/api/googleapi"
+)
+
+const testParent = "projects/123/locations/global/insightTypes/forseti.ConfigValidator"
+
+// fakeAPI is an in memory insight store that enforces etags.
+type fakeAPI struct {
+	insights map[string]*gcv.Insight
+	version  int
+	// failures are returned, in order, before calls start succeeding.
+	failures    []error
+	upsertCalls int
+}
+
+func newFakeAPI() *fakeAPI {
+	return &fakeAPI{insights: map[string]*gcv.Insight{}}
+}
+
+func (f *fakeAPI) fail() error {
+	if len(f.failures) == 0 {
+		return nil
+	}
+	err := f.failures[0]
+	f.failures = f.failures[1:]
+	return err
+}
+
+func (f *fakeAPI) List(ctx context.Context, parent string) ([]*gcv.Insight, error) {
+	if err := f.fail(); err != nil {
+		return nil, err
+	}
+	var insights []*gcv.Insight
+	for _, insight := range f.insights {
+		stored := *insight
+		insights = append(insights, &stored)
+	}
+	return insights, nil
+}
+
+func (f *fakeAPI) Upsert(ctx context.Context, parent string, insights []*gcv.Insight) error {
+	if err := f.fail(); err != nil {
+		return err
+	}
+	f.upsertCalls++
+	for _, insight := range insights {
+		if prev, found := f.insights[insight.Name]; found && prev.Etag != insight.Etag {
+			return &googleapi.Error{Code: 412, Message: "etag mismatch"}
+		}
+	}
+	for _, insight := range insights {
+		f.version++
+		stored := *insight
+		stored.Etag = fmt.Sprintf("v%d", f.version)
+		f.insights[insight.Name] = &stored
+	}
+	return nil
+}
+
+func (f *fakeAPI) SetState(ctx context.Context, name, etag, state string) error {
+	if err := f.fail(); err != nil {
+		return err
+	}
+	prev, found := f.insights[name]
+	if !found || prev.Etag != etag {
+		return &googleapi.Error{Code: 412, Message: "etag mismatch"}
+	}
+	f.version++
+	prev.StateInfo.State = state
+	prev.Etag = fmt.Sprintf("v%d", f.version)
+	return nil
+}
+
+func (f *fakeAPI) states() map[string]string {
+	states := map[string]string{}
+	for _, insight := range f.insights {
+		states[insight.TargetResources[0]] = insight.StateInfo.State
+	}
+	return states
+}
+
+func testInsight(resource, description string) *gcv.Insight {
+	return &gcv.Insight{
+		Description:     description,
+		TargetResources: []string{resource},
+		InsightSubtype:  "GCPStorageLoggingConstraint.require-storage-logging",
+		Content:         map[string]interface{}{"metadata": map[string]interface{}{"count": 1}},
+		Category:        "SECURITY",
+	}
+}
+
+func newTestClient(api API, opts ...Option) *Client {
+	c := NewClient(api, opts...)
+	c.sleep = func(context.Context, time.Duration) error { return nil }
+	return c
+}
+
+func TestSync(t *testing.T) {
+	api := newFakeAPI()
+	client := newTestClient(api, WithBatchSize(2))
+	ctx := context.Background()
+
+	first := []*gcv.Insight{
+		testInsight("//storage.googleapis.com/a", "no logging"),
+		testInsight("//storage.googleapis.com/b", "no logging"),
+		testInsight("//storage.googleapis.com/c", "no logging"),
+	}
+	got, err := client.Sync(ctx, testParent, first)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if diff := cmp.Diff(got, &SyncResult{Upserted: 3}); diff != "" {
+		t.Errorf("first sync mismatch, +got -want\n%s", diff)
+	}
+	if api.upsertCalls != 2 {
+		t.Errorf("got %d upsert calls, want 2 batches", api.upsertCalls)
+	}
+	if first[0].Name != "" || first[0].StateInfo.State != "" {
+		t.Errorf("input insight was modified: %+v", first[0])
+	}
+
+	// a is unchanged, b changed and c was resolved.
+	second := []*gcv.Insight{
+		testInsight("//storage.googleapis.com/a", "no logging"),
+		testInsight("//storage.googleapis.com/b", "still no logging"),
+	}
+	got, err = client.Sync(ctx, testParent, second)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if diff := cmp.Diff(got, &SyncResult{Upserted: 1, Unchanged: 1, Deactivated: 1}); diff != "" {
+		t.Errorf("second sync mismatch, +got -want\n%s", diff)
+	}
+	wantStates := map[string]string{
+		"//storage.googleapis.com/a": StateActive,
+		"//storage.googleapis.com/b": StateActive,
+		"//storage.googleapis.com/c": StateInactive,
+	}
+	if diff := cmp.Diff(api.states(), wantStates); diff != "" {
+		t.Errorf("stored states mismatch, +got -want\n%s", diff)
+	}
+
+	// c is reported again and is reactivated using its current etag.
+	got, err = client.Sync(ctx, testParent, first)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if diff := cmp.Diff(got, &SyncResult{Upserted: 2, Unchanged: 1}); diff != "" {
+		t.Errorf("third sync mismatch, +got -want\n%s", diff)
+	}
+}
+
+func TestSyncRetry(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		failures []error
+		wantErr  bool
+	}{
+		{
+			name:     "transient errors are retried",
+			failures: []error{&googleapi.Error{Code: 503}, &googleapi.Error{Code: 429}},
+		},
+		{
+			name:     "attempts are limited",
+			failures: []error{&googleapi.Error{Code: 503}, &googleapi.Error{Code: 503}, &googleapi.Error{Code: 503}},
+			wantErr:  true,
+		},
+		{
+			name:     "permanent errors are not retried",
+			failures: []error{&googleapi.Error{Code: 403}},
+			wantErr:  true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			api := newFakeAPI()
+			api.failures = tc.failures
+			client := newTestClient(api, WithRetry(3, time.Millisecond, time.Second))
+			_, err := client.Sync(context.Background(), testParent, []*gcv.Insight{
+				testInsight("//storage.googleapis.com/a", "no logging"),
+			})
+			if (err != nil) != tc.wantErr {
+				t.Errorf("got error %v, want error %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestSyncCancelled(t *testing.T) {
+	api := newFakeAPI()
+	api.failures = []error{&googleapi.Error{Code: 503}}
+	client := NewClient(api, WithRetry(3, time.Hour, time.Hour))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := client.Sync(ctx, testParent, nil); err == nil {
+		t.Errorf("expected error for cancelled context")
+	}
+}
+
+func TestInsightName(t *testing.T) {
+	a := &gcv.Insight{InsightSubtype: "K.c", TargetResources: []string{"x", "y"}}
+	b := &gcv.Insight{InsightSubtype: "K.c", TargetResources: []string{"y", "x"}}
+	c := &gcv.Insight{InsightSubtype: "K.d", TargetResources: []string{"x", "y"}}
+	if InsightName(testParent, a) != InsightName(testParent, b) {
+		t.Errorf("name should not depend on target order")
+	}
+	if InsightName(testParent, a) == InsightName(testParent, c) {
+		t.Errorf("name should depend on subtype")
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package redact replaces sensitive fields of assets and violation metadata, such as secrets in
+// instance metadata or container environment variables, before they leave the validator.
+//
+// Fields are selected with dot separated patterns of keys:
+//
+//	resource.data.metadata.items[*].value
+//	resource.data.spec.containers[*].env[*].value
+//	details.*.password
+//
+// A key of "*" matches every key of an object, "[*]" matches every element of a list and "[N]"
+// the element at index N. Fields that do not exist are left alone.
+package redact
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/forseti-security/config-validator/pkg/multierror"
+	"github.com/pkg/errors"
+)
+
+// Redacted is the value redacted fields are replaced with.
+const Redacted = "[REDACTED]"
+
+// anyIndex is the index of a segment matching every element of a list.
+const anyIndex = -1
+
+// segment is one step of a pattern, either a key of an object or an index into a list.
+type segment struct {
+	key     string
+	isIndex bool
+	index   int
+}
+
+// Redactor replaces the fields matching a set of patterns. It is safe for concurrent use.
+type Redactor struct {
+	patterns [][]segment
+}
+
+// New returns a Redactor for the given patterns.
+func New(patterns []string) (*Redactor, error) {
+	r := &Redactor{}
+	var errs multierror.Errors
+	for _, pattern := range patterns {
+		segments, err := parse(pattern)
+		if err != nil {
+			errs.Add(errors.Wrapf(err, "invalid redaction pattern %q", pattern))
+			continue
+		}
+		r.patterns = append(r.patterns, segments)
+	}
+	if !errs.Empty() {
+		return nil, errs.ToError()
+	}
+	return r, nil
+}
+
+func parse(pattern string) ([]segment, error) {
+	var segments []segment
+	for _, part := range strings.Split(pattern, ".") {
+		key := part
+		var indexes []string
+		if idx := strings.IndexByte(part, '['); idx != -1 {
+			key = part[:idx]
+			rest := part[idx:]
+			for rest != "" {
+				end := strings.IndexByte(rest, ']')
+				if rest[0] != '[' || end == -1 {
+					return nil, errors.Errorf("malformed index in %q", part)
+				}
+				indexes = append(indexes, rest[1:end])
+				rest = rest[end+1:]
+			}
+		}
+		if key == "" {
+			return nil, errors.Errorf("empty key")
+		}
+		segments = append(segments, segment{key: key})
+		for _, index := range indexes {
+			if index == "*" {
+				segments = append(segments, segment{isIndex: true, index: anyIndex})
+				continue
+			}
+			i, err := strconv.Atoi(index)
+			if err != nil || i < 0 {
+				return nil, errors.Errorf("invalid index %q", index)
+			}
+			segments = append(segments, segment{isIndex: true, index: i})
+		}
+	}
+	return segments, nil
+}
+
+// Redact returns doc with the fields matching the patterns replaced by Redacted. Doc is not
+// modified: the objects and lists on the path to redacted fields are copied, and the rest is
+// shared with doc. Doc itself is returned if nothing matched.
+func (r *Redactor) Redact(doc map[string]interface{}) map[string]interface{} {
+	if r == nil || doc == nil {
+		return doc
+	}
+	var out interface{} = doc
+	for _, pattern := range r.patterns {
+		out, _ = redact(out, pattern)
+	}
+	return out.(map[string]interface{})
+}
+
+// redact returns value with the fields matching segments redacted, and whether anything matched.
+func redact(value interface{}, segments []segment) (interface{}, bool) {
+	if len(segments) == 0 {
+		return Redacted, true
+	}
+	s, rest := segments[0], segments[1:]
+	switch t := value.(type) {
+	case map[string]interface{}:
+		if s.isIndex {
+			return value, false
+		}
+		var out map[string]interface{}
+		for key, field := range t {
+			if s.key != "*" && s.key != key {
+				continue
+			}
+			redacted, changed := redact(field, rest)
+			if !changed {
+				continue
+			}
+			if out == nil {
+				out = make(map[string]interface{}, len(t))
+				for k, v := range t {
+					out[k] = v
+				}
+			}
+			out[key] = redacted
+		}
+		if out == nil {
+			return value, false
+		}
+		return out, true
+	case []interface{}:
+		if !s.isIndex {
+			return value, false
+		}
+		var out []interface{}
+		for idx, element := range t {
+			if s.index != anyIndex && s.index != idx {
+				continue
+			}
+			redacted, changed := redact(element, rest)
+			if !changed {
+				continue
+			}
+			if out == nil {
+				out = append([]interface{}(nil), t...)
+			}
+			out[idx] = redacted
+		}
+		if out == nil {
+			return value, false
+		}
+		return out, true
+	}
+	return value, false
+}
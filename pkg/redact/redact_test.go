@@ -0,0 +1,109 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redact
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func instance() map[string]interface{} {
+	return map[string]interface{}{
+		"name": "//compute.googleapis.com/projects/p/zones/z/instances/i",
+		"resource": map[string]interface{}{
+			"data": map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"items": []interface{}{
+						map[string]interface{}{"key": "startup-script", "value": "export TOKEN=secret"},
+						map[string]interface{}{"key": "ssh-keys", "value": "user:ssh-rsa AAAA"},
+					},
+				},
+				"zone": "z",
+			},
+		},
+	}
+}
+
+func TestRedact(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		patterns []string
+		want     func(map[string]interface{})
+	}{
+		{
+			name:     "any index",
+			patterns: []string{"resource.data.metadata.items[*].value"},
+			want: func(asset map[string]interface{}) {
+				items := asset["resource"].(map[string]interface{})["data"].(map[string]interface{})["metadata"].(map[string]interface{})["items"].([]interface{})
+				items[0].(map[string]interface{})["value"] = Redacted
+				items[1].(map[string]interface{})["value"] = Redacted
+			},
+		},
+		{
+			name:     "index",
+			patterns: []string{"resource.data.metadata.items[1].value"},
+			want: func(asset map[string]interface{}) {
+				items := asset["resource"].(map[string]interface{})["data"].(map[string]interface{})["metadata"].(map[string]interface{})["items"].([]interface{})
+				items[1].(map[string]interface{})["value"] = Redacted
+			},
+		},
+		{
+			name:     "any key",
+			patterns: []string{"resource.*.zone"},
+			want: func(asset map[string]interface{}) {
+				asset["resource"].(map[string]interface{})["data"].(map[string]interface{})["zone"] = Redacted
+			},
+		},
+		{
+			name:     "object",
+			patterns: []string{"resource.data.metadata"},
+			want: func(asset map[string]interface{}) {
+				asset["resource"].(map[string]interface{})["data"].(map[string]interface{})["metadata"] = Redacted
+			},
+		},
+		{
+			name:     "no match",
+			patterns: []string{"resource.data.labels.secret", "resource.data.zone[*]", "name.first"},
+			want:     func(map[string]interface{}) {},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := New(tc.patterns)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			asset := instance()
+			got := r.Redact(asset)
+			want := instance()
+			tc.want(want)
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("unexpected redacted asset (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(instance(), asset); diff != "" {
+				t.Errorf("asset was modified (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNewInvalid(t *testing.T) {
+	for _, pattern := range []string{"", "a..b", "[0]", "a[x]", "a[-1]", "a[0", "a[0]b"} {
+		if _, err := New([]string{pattern}); err == nil {
+			t.Errorf("expected error for pattern %q", pattern)
+		}
+	}
+}
@@ -0,0 +1,272 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package remediation renders gcloud commands and Terraform changes fixing violations of a
+// curated set of constraint kinds, such as world readable buckets and open firewall rules. The
+// snippets are attached to review results with gcv.WithRemediator.
+package remediation
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/forseti-security/config-validator/pkg/gcv"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Generator renders the snippets fixing cv, a violation of result, or nil if it cannot render
+// any, for example because the resource name has an unexpected format.
+type Generator func(result *gcv.Result, cv *gcv.ConstraintViolation) []gcv.Snippet
+
+// versionSuffix matches the version suffix of constraint kinds, such as V1.
+var versionSuffix = regexp.MustCompile(`V[0-9]+$`)
+
+// Engine renders snippets with the generator registered for the kind of the violated constraint.
+// It implements gcv.Remediator.
+type Engine struct {
+	generators map[string]Generator
+}
+
+// NewEngine returns an Engine with generators for the constraint kinds of the policy library:
+//   - GCPStorageBucketWorldReadableConstraint with PublicBucket
+//   - GCPRestrictedFirewallRulesConstraint and GCPFirewallPublicInstanceConstraint with
+//     OpenFirewall
+//   - GCPIAMRestrictServiceAccountKeyAgeConstraint with ServiceAccountKeyAge
+func NewEngine() *Engine {
+	e := &Engine{generators: map[string]Generator{}}
+	e.Register("GCPStorageBucketWorldReadableConstraint", PublicBucket)
+	e.Register("GCPRestrictedFirewallRulesConstraint", OpenFirewall)
+	e.Register("GCPFirewallPublicInstanceConstraint", OpenFirewall)
+	e.Register("GCPIAMRestrictServiceAccountKeyAgeConstraint", ServiceAccountKeyAge)
+	return e
+}
+
+// Register renders the snippets of violations of constraints of kind with g, replacing the
+// generator registered for kind, if any. Kinds match regardless of their version suffix, so
+// GCPStorageBucketWorldReadableConstraint also matches GCPStorageBucketWorldReadableConstraintV1.
+func (e *Engine) Register(kind string, g Generator) {
+	e.generators[versionSuffix.ReplaceAllString(kind, "")] = g
+}
+
+// Snippets implements gcv.Remediator.
+func (e *Engine) Snippets(result *gcv.Result, cv *gcv.ConstraintViolation) []gcv.Snippet {
+	if cv.Constraint == nil {
+		return nil
+	}
+	g, found := e.generators[versionSuffix.ReplaceAllString(cv.Constraint.GetKind(), "")]
+	if !found {
+		return nil
+	}
+	return g(result, cv)
+}
+
+// publicMembers are the IAM members granting access to anyone.
+var publicMembers = map[string]bool{"allUsers": true, "allAuthenticatedUsers": true}
+
+// PublicBucket renders commands removing the public bindings from the IAM policy of a bucket, or
+// enabling public access prevention if the policy is not part of the asset, and the Terraform
+// change enforcing public access prevention.
+func PublicBucket(result *gcv.Result, cv *gcv.ConstraintViolation) []gcv.Snippet {
+	name := resourceName(result, cv)
+	bucket, ok := trimName(name, "//storage.googleapis.com/")
+	if !ok {
+		return nil
+	}
+
+	var commands []string
+	bindings, _, _ := unstructured.NestedSlice(assetFor(result, name), "iam_policy", "bindings")
+	for _, b := range bindings {
+		binding, _ := b.(map[string]interface{})
+		role, _, _ := unstructured.NestedString(binding, "role")
+		members, _, _ := unstructured.NestedStringSlice(binding, "members")
+		for _, member := range members {
+			if publicMembers[member] && role != "" {
+				commands = append(commands, fmt.Sprintf(
+					"gcloud storage buckets remove-iam-policy-binding %s --member=%s --role=%s",
+					quote("gs://"+bucket), member, quote(role)))
+			}
+		}
+	}
+	if len(commands) == 0 {
+		commands = append(commands, fmt.Sprintf("gcloud storage buckets update %s --public-access-prevention", quote("gs://"+bucket)))
+	}
+
+	terraform := []string{
+		fmt.Sprintf(` resource "google_storage_bucket" %q {`, terraformName(bucket)),
+		fmt.Sprintf(`   name = %q`, bucket),
+		`+  public_access_prevention = "enforced"`,
+		` }`,
+	}
+	return []gcv.Snippet{
+		{Format: gcv.SnippetGcloud, Text: strings.Join(commands, "\n")},
+		{Format: gcv.SnippetTerraform, Text: strings.Join(terraform, "\n")},
+	}
+}
+
+// openRanges are the source ranges allowing traffic from anywhere.
+var openRanges = map[string]bool{"0.0.0.0/0": true, "::/0": true}
+
+// OpenFirewall renders the command and Terraform change removing the ranges open to anywhere from
+// the source ranges of a firewall rule, or disabling the rule if no other ranges are left or the
+// ranges are not part of the asset. It renders nothing for rules without ranges open to anywhere.
+func OpenFirewall(result *gcv.Result, cv *gcv.ConstraintViolation) []gcv.Snippet {
+	name := resourceName(result, cv)
+	path, ok := trimName(name, "//compute.googleapis.com/")
+	if !ok {
+		return nil
+	}
+	parts := strings.Split(path, "/")
+	if len(parts) != 5 || parts[0] != "projects" || parts[2] != "global" || parts[3] != "firewalls" {
+		return nil
+	}
+	project, rule := parts[1], parts[4]
+
+	ranges, _, _ := unstructured.NestedStringSlice(assetFor(result, name), "resource", "data", "sourceRanges")
+	var kept []string
+	for _, r := range ranges {
+		if !openRanges[r] {
+			kept = append(kept, r)
+		}
+	}
+
+	if len(ranges) != 0 && len(kept) == len(ranges) {
+		return nil
+	}
+
+	command := fmt.Sprintf("gcloud compute firewall-rules update %s --project=%s --disabled", quote(rule), quote(project))
+	change := []string{`+  disabled = true`}
+	if len(kept) != 0 {
+		command = fmt.Sprintf("gcloud compute firewall-rules update %s --project=%s --source-ranges=%s",
+			quote(rule), quote(project), quote(strings.Join(kept, ",")))
+		change = []string{
+			fmt.Sprintf(`-  source_ranges = %s`, terraformList(ranges)),
+			fmt.Sprintf(`+  source_ranges = %s`, terraformList(kept)),
+		}
+	}
+	lines := []string{
+		fmt.Sprintf(` resource "google_compute_firewall" %q {`, terraformName(rule)),
+		fmt.Sprintf(`   name    = %q`, rule),
+		fmt.Sprintf(`   project = %q`, project),
+	}
+	lines = append(lines, change...)
+	lines = append(lines, ` }`)
+	return []gcv.Snippet{
+		{Format: gcv.SnippetGcloud, Text: command},
+		{Format: gcv.SnippetTerraform, Text: strings.Join(lines, "\n")},
+	}
+}
+
+// defaultKeyRotation is the rotation period of service account keys used if the constraint does
+// not declare a max_age parameter.
+const defaultKeyRotation = 90 * 24 * time.Hour
+
+// ServiceAccountKeyAge renders the commands replacing a service account key with a new one, and
+// the Terraform change rotating the key within the max_age parameter of the constraint.
+func ServiceAccountKeyAge(result *gcv.Result, cv *gcv.ConstraintViolation) []gcv.Snippet {
+	path, ok := trimName(resourceName(result, cv), "//iam.googleapis.com/")
+	if !ok {
+		return nil
+	}
+	parts := strings.Split(path, "/")
+	if len(parts) != 6 || parts[0] != "projects" || parts[2] != "serviceAccounts" || parts[4] != "keys" {
+		return nil
+	}
+	project, account, key := parts[1], parts[3], parts[5]
+
+	rotation := defaultKeyRotation
+	if maxAge, ok := cv.StringField("constraint.parameters.max_age"); ok {
+		if d, err := time.ParseDuration(maxAge); err == nil && d >= 24*time.Hour {
+			rotation = d
+		}
+	}
+	commands := []string{
+		fmt.Sprintf("gcloud iam service-accounts keys create key.json --iam-account=%s --project=%s", quote(account), quote(project)),
+		fmt.Sprintf("gcloud iam service-accounts keys delete %s --iam-account=%s --project=%s", quote(key), quote(account), quote(project)),
+	}
+	rotationName := terraformName(account) + "_key_rotation"
+	terraform := []string{
+		fmt.Sprintf(`+resource "time_rotating" %q {`, rotationName),
+		fmt.Sprintf(`+  rotation_days = %d`, int(rotation.Hours()/24)),
+		`+}`,
+		`+`,
+		fmt.Sprintf(` resource "google_service_account_key" %q {`, terraformName(account)),
+		fmt.Sprintf(`   service_account_id = %q`, "projects/"+project+"/serviceAccounts/"+account),
+		`+  keepers = {`,
+		fmt.Sprintf(`+    rotation_time = time_rotating.%s.rotation_rfc3339`, rotationName),
+		`+  }`,
+		` }`,
+	}
+	return []gcv.Snippet{
+		{Format: gcv.SnippetGcloud, Text: strings.Join(commands, "\n")},
+		{Format: gcv.SnippetTerraform, Text: strings.Join(terraform, "\n")},
+	}
+}
+
+// resourceName returns the name of the violating resource, which templates reporting violations
+// on resources other than the reviewed asset set in details.resource.
+func resourceName(result *gcv.Result, cv *gcv.ConstraintViolation) string {
+	if name, ok := cv.StringField("details.resource"); ok && name != "" {
+		return name
+	}
+	return result.Name
+}
+
+// assetFor returns the reviewed asset if it is the resource name, nil otherwise.
+func assetFor(result *gcv.Result, name string) map[string]interface{} {
+	if name != result.Name {
+		return nil
+	}
+	return result.CAIResource
+}
+
+// trimName returns the relative part of a full resource name of the service with prefix.
+func trimName(name, prefix string) (string, bool) {
+	if !strings.HasPrefix(name, prefix) || len(name) == len(prefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(name, prefix), true
+}
+
+// nonTerraform matches the characters that are not allowed in Terraform resource names.
+var nonTerraform = regexp.MustCompile(`[^a-z0-9_]+`)
+
+// terraformName returns a Terraform resource name derived from name.
+func terraformName(name string) string {
+	tfName := nonTerraform.ReplaceAllString(strings.ToLower(name), "_")
+	if tfName == "" || tfName[0] < 'a' || tfName[0] > 'z' {
+		tfName = "r_" + tfName
+	}
+	return tfName
+}
+
+func terraformList(values []string) string {
+	quoted := make([]string, len(values))
+	for idx, value := range values {
+		quoted[idx] = fmt.Sprintf("%q", value)
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
+
+// shellSafe matches values that need no quoting in a shell command.
+var shellSafe = regexp.MustCompile(`^[A-Za-z0-9@%+=:,./_-]+$`)
+
+// quote returns value quoted for a POSIX shell if needed.
+func quote(value string) string {
+	if shellSafe.MatchString(value) {
+		return value
+	}
+	return "'" + strings.Replace(value, "'", `'\''`, -1) + "'"
+}
@@ -0,0 +1,208 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remediation
+
+import (
+	"testing"
+
+	"github.com/forseti-security/config-validator/pkg/gcv"
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func violation(kind string, details, parameters map[string]interface{}) *gcv.ConstraintViolation {
+	return &gcv.ConstraintViolation{
+		Metadata: map[string]interface{}{"details": details},
+		Constraint: &unstructured.Unstructured{Object: map[string]interface{}{
+			"kind":     kind,
+			"metadata": map[string]interface{}{"name": "test"},
+			"spec":     map[string]interface{}{"parameters": parameters},
+		}},
+	}
+}
+
+func TestSnippets(t *testing.T) {
+	var testCases = []struct {
+		name   string
+		result *gcv.Result
+		cv     *gcv.ConstraintViolation
+		want   []gcv.Snippet
+	}{
+		{
+			name: "public bucket",
+			result: &gcv.Result{
+				Name: "//storage.googleapis.com/my-bucket",
+				CAIResource: map[string]interface{}{
+					"iam_policy": map[string]interface{}{
+						"bindings": []interface{}{
+							map[string]interface{}{"role": "roles/storage.objectViewer", "members": []interface{}{"allUsers", "user:a@example.com"}},
+							map[string]interface{}{"role": "roles/storage.legacyBucketReader", "members": []interface{}{"allAuthenticatedUsers"}},
+						},
+					},
+				},
+			},
+			cv: violation("GCPStorageBucketWorldReadableConstraintV1", nil, nil),
+			want: []gcv.Snippet{
+				{
+					Format: gcv.SnippetGcloud,
+					Text: "gcloud storage buckets remove-iam-policy-binding gs://my-bucket --member=allUsers --role=roles/storage.objectViewer\n" +
+						"gcloud storage buckets remove-iam-policy-binding gs://my-bucket --member=allAuthenticatedUsers --role=roles/storage.legacyBucketReader",
+				},
+				{
+					Format: gcv.SnippetTerraform,
+					Text: ` resource "google_storage_bucket" "my_bucket" {
+   name = "my-bucket"
++  public_access_prevention = "enforced"
+ }`,
+				},
+			},
+		},
+		{
+			name:   "public bucket without iam policy",
+			result: &gcv.Result{Name: "//storage.googleapis.com/9bucket"},
+			cv:     violation("GCPStorageBucketWorldReadableConstraint", nil, nil),
+			want: []gcv.Snippet{
+				{Format: gcv.SnippetGcloud, Text: "gcloud storage buckets update gs://9bucket --public-access-prevention"},
+				{
+					Format: gcv.SnippetTerraform,
+					Text: ` resource "google_storage_bucket" "r_9bucket" {
+   name = "9bucket"
++  public_access_prevention = "enforced"
+ }`,
+				},
+			},
+		},
+		{
+			name: "open firewall with other ranges",
+			result: &gcv.Result{
+				Name: "//compute.googleapis.com/projects/p/global/firewalls/allow-ssh",
+				CAIResource: map[string]interface{}{
+					"resource": map[string]interface{}{
+						"data": map[string]interface{}{"sourceRanges": []interface{}{"0.0.0.0/0", "10.0.0.0/8"}},
+					},
+				},
+			},
+			cv: violation("GCPRestrictedFirewallRulesConstraintV1", nil, nil),
+			want: []gcv.Snippet{
+				{Format: gcv.SnippetGcloud, Text: "gcloud compute firewall-rules update allow-ssh --project=p --source-ranges=10.0.0.0/8"},
+				{
+					Format: gcv.SnippetTerraform,
+					Text: ` resource "google_compute_firewall" "allow_ssh" {
+   name    = "allow-ssh"
+   project = "p"
+-  source_ranges = ["0.0.0.0/0", "10.0.0.0/8"]
++  source_ranges = ["10.0.0.0/8"]
+ }`,
+				},
+			},
+		},
+		{
+			name: "open firewall in details",
+			// The violation is reported on the firewall rule while reviewing an instance.
+			result: &gcv.Result{Name: "//compute.googleapis.com/projects/p/zones/z/instances/i"},
+			cv: violation("GCPFirewallPublicInstanceConstraint", map[string]interface{}{
+				"resource": "//compute.googleapis.com/projects/p/global/firewalls/allow-all",
+			}, nil),
+			want: []gcv.Snippet{
+				{Format: gcv.SnippetGcloud, Text: "gcloud compute firewall-rules update allow-all --project=p --disabled"},
+				{
+					Format: gcv.SnippetTerraform,
+					Text: ` resource "google_compute_firewall" "allow_all" {
+   name    = "allow-all"
+   project = "p"
++  disabled = true
+ }`,
+				},
+			},
+		},
+		{
+			name: "firewall without open ranges",
+			result: &gcv.Result{
+				Name: "//compute.googleapis.com/projects/p/global/firewalls/internal",
+				CAIResource: map[string]interface{}{
+					"resource": map[string]interface{}{
+						"data": map[string]interface{}{"sourceRanges": []interface{}{"10.0.0.0/8"}},
+					},
+				},
+			},
+			cv: violation("GCPRestrictedFirewallRulesConstraint", nil, nil),
+		},
+		{
+			name:   "service account key age",
+			result: &gcv.Result{Name: "//iam.googleapis.com/projects/p/serviceAccounts/sa@p.iam.gserviceaccount.com/keys/0123abcd"},
+			cv:     violation("GCPIAMRestrictServiceAccountKeyAgeConstraintV1", nil, map[string]interface{}{"max_age": "720h"}),
+			want: []gcv.Snippet{
+				{
+					Format: gcv.SnippetGcloud,
+					Text: "gcloud iam service-accounts keys create key.json --iam-account=sa@p.iam.gserviceaccount.com --project=p\n" +
+						"gcloud iam service-accounts keys delete 0123abcd --iam-account=sa@p.iam.gserviceaccount.com --project=p",
+				},
+				{
+					Format: gcv.SnippetTerraform,
+					Text: `+resource "time_rotating" "sa_p_iam_gserviceaccount_com_key_rotation" {
++  rotation_days = 30
++}
++
+ resource "google_service_account_key" "sa_p_iam_gserviceaccount_com" {
+   service_account_id = "projects/p/serviceAccounts/sa@p.iam.gserviceaccount.com"
++  keepers = {
++    rotation_time = time_rotating.sa_p_iam_gserviceaccount_com_key_rotation.rotation_rfc3339
++  }
+ }`,
+				},
+			},
+		},
+		{
+			name:   "unexpected name",
+			result: &gcv.Result{Name: "//storage.googleapis.com/"},
+			cv:     violation("GCPStorageBucketWorldReadableConstraint", nil, nil),
+		},
+		{
+			name:   "unsupported kind",
+			result: &gcv.Result{Name: "//storage.googleapis.com/my-bucket"},
+			cv:     violation("GCPStorageLoggingConstraint", nil, nil),
+		},
+	}
+	engine := NewEngine()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := engine.Snippets(tc.result, tc.cv)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected snippets (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRegister(t *testing.T) {
+	engine := NewEngine()
+	engine.Register("GCPMyBucketConstraintV2", PublicBucket)
+	got := engine.Snippets(&gcv.Result{Name: "//storage.googleapis.com/b"}, violation("GCPMyBucketConstraintV1", nil, nil))
+	if len(got) != 2 {
+		t.Errorf("got %d snippets, want 2", len(got))
+	}
+}
+
+func TestQuote(t *testing.T) {
+	for value, want := range map[string]string{
+		"gs://my-bucket": "gs://my-bucket",
+		"it's":           `'it'\''s'`,
+		"a b":            "'a b'",
+	} {
+		if got := quote(value); got != want {
+			t.Errorf("quote(%q) = %s, want %s", value, got, want)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package report
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// forsetiViolationType is the violation type of the Forseti config validator scanner.
+const forsetiViolationType = "CONFIG_VALIDATOR_VIOLATION"
+
+// forsetiTimeFormat is the format of timestamps in Forseti violations.
+const forsetiTimeFormat = "2006-01-02T15:04:05Z"
+
+// forsetiViolation is a violation in the schema of the Forseti scanner, as sent by its notifier.
+type forsetiViolation struct {
+	ResourceID        string                 `json:"resource_id"`
+	ResourceType      string                 `json:"resource_type"`
+	ResourceName      string                 `json:"resource_name"`
+	FullName          string                 `json:"full_name"`
+	RuleIndex         int                    `json:"rule_index"`
+	RuleName          string                 `json:"rule_name"`
+	ViolationType     string                 `json:"violation_type"`
+	ViolationData     map[string]interface{} `json:"violation_data"`
+	ViolationHash     string                 `json:"violation_hash"`
+	ViolationMessage  string                 `json:"violation_message"`
+	ResourceData      string                 `json:"resource_data"`
+	CreatedAtDatetime string                 `json:"created_at_datetime,omitempty"`
+}
+
+// forsetiColumns are the columns of the Forseti CSV output, named as the fields of the notifier
+// JSON.
+var forsetiColumns = []string{
+	"resource_id", "resource_type", "resource_name", "full_name", "rule_index", "rule_name", "violation_type",
+	"violation_data", "violation_hash", "violation_message", "resource_data", "created_at_datetime",
+}
+
+// forsetiViolations returns the violations in the schema of the Forseti scanner. Reports do not
+// hold the bodies of resources, so resource_data is empty.
+func (r *Report) forsetiViolations() []*forsetiViolation {
+	var created string
+	if r.Manifest != nil {
+		created = r.Manifest.StartTime.UTC().Format(forsetiTimeFormat)
+	}
+	violations := []*forsetiViolation{}
+	for _, v := range r.sortedViolations() {
+		data := v.Metadata
+		if data == nil {
+			data = map[string]interface{}{}
+		}
+		violations = append(violations, &forsetiViolation{
+			ResourceID:        forsetiResourceID(v.Resource),
+			ResourceType:      v.AssetType,
+			ResourceName:      v.Resource,
+			FullName:          forsetiFullName(v),
+			RuleName:          v.Constraint,
+			ViolationType:     forsetiViolationType,
+			ViolationData:     data,
+			ViolationHash:     v.Fingerprint(),
+			ViolationMessage:  v.Message,
+			CreatedAtDatetime: created,
+		})
+	}
+	return violations
+}
+
+// forsetiResourceID returns the last segment of the CAI name of a resource, such as the name of
+// a bucket.
+func forsetiResourceID(name string) string {
+	return name[strings.LastIndex(name, "/")+1:]
+}
+
+// forsetiFullName returns the Forseti full name of the resource of v, which lists its ancestors
+// and the resource itself as type/id pairs, such as
+// organization/123/project/my-project/bucket/my-bucket/. It is empty if the ancestry of the
+// resource is unknown.
+func forsetiFullName(v *Violation) string {
+	if v.AncestryPath == "" {
+		return ""
+	}
+	var b strings.Builder
+	segments := strings.Split(v.AncestryPath, "/")
+	for idx := 0; idx+1 < len(segments); idx += 2 {
+		// CAI ancestry paths use plural collection names, such as projects.
+		b.WriteString(strings.TrimSuffix(segments[idx], "s") + "/" + segments[idx+1] + "/")
+	}
+	kind := v.AssetType[strings.LastIndex(v.AssetType, "/")+1:]
+	if kind != "" {
+		b.WriteString(strings.ToLower(kind) + "/" + forsetiResourceID(v.Resource) + "/")
+	}
+	return b.String()
+}
+
+// writeForsetiJSON writes the violations as the JSON list of violations of the Forseti notifier.
+func (r *Report) writeForsetiJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return errors.Wrapf(encoder.Encode(r.forsetiViolations()), "failed to encode report")
+}
+
+// writeForsetiCSV writes the violations as CSV with a header row of forsetiColumns. The violation
+// data is JSON encoded.
+func (r *Report) writeForsetiCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(forsetiColumns); err != nil {
+		return errors.Wrapf(err, "failed to write report")
+	}
+	for _, v := range r.forsetiViolations() {
+		data, err := json.Marshal(v.ViolationData)
+		if err != nil {
+			return errors.Wrapf(err, "failed to encode violation data of %s", v.ResourceName)
+		}
+		record := []string{
+			v.ResourceID, v.ResourceType, v.ResourceName, v.FullName, strconv.Itoa(v.RuleIndex), v.RuleName, v.ViolationType,
+			string(data), v.ViolationHash, v.ViolationMessage, v.ResourceData, v.CreatedAtDatetime,
+		}
+		if err := cw.Write(record); err != nil {
+			return errors.Wrapf(err, "failed to write report")
+		}
+	}
+	cw.Flush()
+	return errors.Wrapf(cw.Error(), "failed to write report")
+}
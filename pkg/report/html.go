@@ -0,0 +1,185 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package report
+
+import (
+	"encoding/json"
+	"html/template"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/forseti-security/config-validator/pkg/gcv"
+	"github.com/pkg/errors"
+)
+
+// FromResults returns the report of results, such as those of a review by a program embedding the
+// validator. The policy version is that of the first result. Unlike New, the constraints without
+// violations are unknown, so they are not listed as passed.
+func FromResults(results []*gcv.Result) *Report {
+	r := &Report{Violations: []*Violation{}}
+	for _, result := range results {
+		if r.PolicyVersion == "" {
+			r.PolicyVersion = result.PolicyVersion
+		}
+		r.Add(result)
+	}
+	return r
+}
+
+// htmlSection holds the violations of one constraint in the HTML output.
+type htmlSection struct {
+	Constraint string
+	// Severity is the highest severity of the violations.
+	Severity   string
+	Violations []*htmlViolation
+}
+
+type htmlViolation struct {
+	*Violation
+	// Details is the indented JSON of the metadata.
+	Details string
+}
+
+type htmlReport struct {
+	*Report
+	Sections []*htmlSection
+	Passed   []string
+	// Severities counts the violations by severity, highest first.
+	Severities []htmlCount
+}
+
+type htmlCount struct {
+	Severity string
+	Count    int
+}
+
+// severityName returns the severity shown for violations of severity, - if it is not set.
+func severityName(severity string) string {
+	if severity == "" {
+		return "-"
+	}
+	return strings.ToLower(severity)
+}
+
+// writeHTML writes the report as a single HTML page without external resources, with a section
+// per violated constraint, most severe first, and the details of each violation folded away.
+func (r *Report) writeHTML(w io.Writer) error {
+	out := &htmlReport{Report: r}
+	byConstraint := map[string]*htmlSection{}
+	counts := map[string]int{}
+	for _, v := range r.sortedViolations() {
+		section, found := byConstraint[v.Constraint]
+		if !found {
+			section = &htmlSection{Constraint: v.Constraint, Severity: severityName(v.Severity)}
+			byConstraint[v.Constraint] = section
+			out.Sections = append(out.Sections, section)
+		}
+		details, err := json.MarshalIndent(v.Metadata, "", "  ")
+		if err != nil {
+			return errors.Wrapf(err, "failed to encode metadata of %s", v.Resource)
+		}
+		if v.Metadata == nil {
+			details = nil
+		}
+		section.Violations = append(section.Violations, &htmlViolation{Violation: v, Details: string(details)})
+		counts[severityName(v.Severity)]++
+	}
+	for _, constraint := range r.Constraints {
+		if _, found := byConstraint[constraint]; !found {
+			out.Passed = append(out.Passed, constraint)
+		}
+	}
+	for severity, count := range counts {
+		out.Severities = append(out.Severities, htmlCount{Severity: severity, Count: count})
+	}
+	sort.Slice(out.Severities, func(i, j int) bool {
+		return SeverityRank(out.Severities[i].Severity) > SeverityRank(out.Severities[j].Severity)
+	})
+	return errors.Wrapf(htmlTemplate.Execute(w, out), "failed to write report")
+}
+
+var htmlTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Config Validator report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #202124; }
+h2 { font-size: 1.1em; margin-top: 2em; }
+table.summary td { padding: 0.2em 1em 0.2em 0; }
+.badge { display: inline-block; min-width: 4.5em; padding: 0.1em 0.5em; border-radius: 0.8em; color: #fff;
+  font-size: 0.8em; font-weight: bold; text-align: center; text-transform: uppercase; background: #5f6368; }
+.badge.critical { background: #a50e0e; }
+.badge.high { background: #d93025; }
+.badge.medium { background: #e37400; }
+.badge.low { background: #1a73e8; }
+details { margin: 0.3em 0 0.3em 1em; }
+summary { cursor: pointer; }
+dl { margin: 0.5em 0 0.5em 1.5em; }
+dt { font-weight: bold; }
+pre { background: #f1f3f4; padding: 0.5em; overflow-x: auto; }
+</style>
+</head>
+<body>
+<h1>Config Validator report</h1>
+<table class="summary">
+<tr><td>Policy version</td><td>{{.PolicyVersion}}</td></tr>
+{{- with .Manifest}}
+<tr><td>Run</td><td>{{.RunID}}, {{.StartTime.Format "2006-01-02 15:04:05 MST"}}</td></tr>
+{{- end}}
+<tr><td>Assets</td><td>{{.Assets}}</td></tr>
+<tr><td>Errors</td><td>{{.Errors}}</td></tr>
+<tr><td>Violations</td><td>{{len .Violations}}{{range .Severities}} <span class="badge {{.Severity}}">{{.Count}} {{.Severity}}</span>{{end}}</td></tr>
+</table>
+{{- range .Sections}}
+<h2><span class="badge {{.Severity}}">{{.Severity}}</span> {{.Constraint}} ({{len .Violations}})</h2>
+{{- range .Violations}}
+<details>
+<summary>{{.Resource}}: {{.Message}}</summary>
+<dl>
+{{- with .AssetType}}
+<dt>Asset type</dt><dd>{{.}}</dd>
+{{- end}}
+{{- with .AncestryPath}}
+<dt>Ancestry</dt><dd>{{.}}</dd>
+{{- end}}
+{{- with .Source}}
+<dt>Source</dt><dd>{{.File}}:{{.Line}}</dd>
+{{- end}}
+{{- with .Remediation}}
+<dt>Remediation</dt><dd>{{.}}</dd>
+{{- end}}
+{{- with .RemediationURL}}
+<dt>Documentation</dt><dd><a href="{{.}}">{{.}}</a></dd>
+{{- end}}
+{{- with .Details}}
+<dt>Details</dt><dd><pre>{{.}}</pre></dd>
+{{- end}}
+</dl>
+</details>
+{{- end}}
+{{- end}}
+{{- with .Passed}}
+<h2>Passed constraints ({{len .}})</h2>
+<ul>
+{{- range .}}
+<li>{{.}}</li>
+{{- end}}
+</ul>
+{{- end}}
+</body>
+</html>
+`))
@@ -0,0 +1,138 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Errors     int             `xml:"errors,attr"`
+	Timestamp  string          `xml:"timestamp,attr,omitempty"`
+	Time       string          `xml:"time,attr,omitempty"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	Cases      []junitTestCase `xml:"testcase"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// writeJUnit writes the report as JUnit XML with one test case per constraint, which fails if
+// the constraint has violations. Assets that could not be reviewed are counted as errors.
+func (r *Report) writeJUnit(w io.Writer) error {
+	byConstraint := map[string][]*Violation{}
+	for _, constraint := range r.Constraints {
+		byConstraint[constraint] = nil
+	}
+	for _, v := range r.sortedViolations() {
+		byConstraint[v.Constraint] = append(byConstraint[v.Constraint], v)
+	}
+	var names []string
+	for name := range byConstraint {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	suite := junitTestSuite{Name: toolName, Errors: r.Errors}
+	if m := r.Manifest; m != nil {
+		suite.Timestamp = m.StartTime.Format("2006-01-02T15:04:05")
+		suite.Time = fmt.Sprintf("%.3f", m.DurationSeconds)
+		suite.Properties = m.junitProperties()
+	}
+	for _, name := range names {
+		testCase := junitTestCase{Name: name, ClassName: strings.SplitN(name, ".", 2)[0]}
+		if violations := byConstraint[name]; len(violations) != 0 {
+			var lines []string
+			for _, v := range violations {
+				lines = append(lines, fmt.Sprintf("%s: %s", v.Resource, v.Message))
+			}
+			testCase.Failure = &junitFailure{
+				Message: fmt.Sprintf("%d violations", len(violations)),
+				Type:    violations[0].Severity,
+				Text:    strings.Join(lines, "\n"),
+			}
+			suite.Failures++
+		}
+		suite.Cases = append(suite.Cases, testCase)
+	}
+	suite.Tests = len(suite.Cases)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(junitTestSuites{Suites: []junitTestSuite{suite}}); err != nil {
+		return errors.Wrapf(err, "failed to encode JUnit report")
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// junitProperties returns the manifest as the properties of a test suite, with one
+// policy_source property per source of the policies.
+func (m *Manifest) junitProperties() []junitProperty {
+	properties := []junitProperty{
+		{Name: "run_id", Value: m.RunID},
+		{Name: "validator_version", Value: m.ValidatorVersion},
+	}
+	if p := m.Policies; p != nil {
+		properties = append(properties,
+			junitProperty{Name: "policy_version", Value: p.PolicyVersion},
+			junitProperty{Name: "policy_content_hash", Value: p.ContentHash})
+		for _, source := range p.Sources {
+			value := source.Path
+			if source.GitCommit != "" {
+				value += "@" + source.GitCommit
+			}
+			if source.Digest != "" {
+				value += " sha256:" + source.Digest
+			}
+			properties = append(properties, junitProperty{Name: "policy_source", Value: value})
+		}
+	}
+	for _, input := range m.Inputs {
+		properties = append(properties, junitProperty{Name: "input", Value: input})
+	}
+	return properties
+}
@@ -0,0 +1,57 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package report
+
+import (
+	"time"
+
+	"github.com/forseti-security/config-validator/pkg/gcv"
+)
+
+// Manifest records how a review run was made, so that auditors can prove which policy code and
+// which asset exports produced its findings.
+type Manifest struct {
+	RunID            string `json:"run_id"`
+	ValidatorVersion string `json:"validator_version"`
+	// Policies identifies the policies of the run.
+	Policies *gcv.Provenance `json:"policies"`
+	// Inputs are the asset exports reviewed by the run.
+	Inputs    []string  `json:"inputs"`
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+	// DurationSeconds is the time from StartTime to EndTime.
+	DurationSeconds float64 `json:"duration_seconds"`
+}
+
+// NewManifest returns the manifest of a run with runID that starts now, reviewing inputs with
+// validator.
+func NewManifest(runID string, validator *gcv.Validator, inputs []string) *Manifest {
+	return &Manifest{
+		RunID:            runID,
+		ValidatorVersion: gcv.ValidatorVersion(),
+		Policies:         validator.Provenance(),
+		Inputs:           append([]string{}, inputs...),
+		StartTime:        timeNow().UTC(),
+	}
+}
+
+// Finish records the end of the run.
+func (m *Manifest) Finish() {
+	m.EndTime = timeNow().UTC()
+	m.DurationSeconds = m.EndTime.Sub(m.StartTime).Seconds()
+}
+
+// timeNow is replaced in tests.
+var timeNow = time.Now
@@ -0,0 +1,327 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package report collects the violations of a review run and writes them in formats consumed by
+// people and CI systems: JSON, YAML, SARIF, JUnit XML, plain text tables, CSV and TSV
+// spreadsheets, HTML pages and the violation schema of Forseti.
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/forseti-security/config-validator/pkg/gcv"
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+)
+
+// Output formats supported by Write.
+const (
+	JSON  = "json"
+	YAML  = "yaml"
+	SARIF = "sarif"
+	JUnit = "junit"
+	Table = "table"
+	// CSV and TSV write one row per violation, see WriteColumns.
+	CSV = "csv"
+	TSV = "tsv"
+	// HTML writes a self-contained page for people to read.
+	HTML = "html"
+	// ForsetiJSON and ForsetiCSV write the violations in the schema of the Forseti scanner, as the
+	// JSON of its notifier and as CSV, for pipelines built on Forseti.
+	ForsetiJSON = "forseti-json"
+	ForsetiCSV  = "forseti-csv"
+)
+
+// Formats are the output formats supported by Write.
+var Formats = []string{JSON, YAML, SARIF, JUnit, Table, CSV, TSV, HTML, ForsetiJSON, ForsetiCSV}
+
+// severityRanks orders the severities of constraints, unknown severities rank lowest.
+var severityRanks = map[string]int{
+	"low":      1,
+	"medium":   2,
+	"high":     3,
+	"critical": 4,
+}
+
+// SeverityRank returns the rank of severity, from 0 for unknown or unset severities to 4 for
+// critical. Severities are compared case insensitively.
+func SeverityRank(severity string) int {
+	return severityRanks[strings.ToLower(severity)]
+}
+
+// ParseSeverity returns the rank of a failure threshold given as low, medium, high or critical.
+// An empty threshold has rank 0, which every violation meets.
+func ParseSeverity(threshold string) (int, error) {
+	if threshold == "" {
+		return 0, nil
+	}
+	if rank := SeverityRank(threshold); rank != 0 {
+		return rank, nil
+	}
+	return 0, errors.Errorf("unknown severity %q, want one of low, medium, high, critical", threshold)
+}
+
+// ExitCode returns the exit status of a run whose most severe violation has rank maxRank, -1 if
+// there were none. The status is 0 if maxRank is below threshold, otherwise 2 for low or unknown
+// severity, 3 for medium, 4 for high and 5 for critical.
+func ExitCode(maxRank, threshold int) int {
+	if maxRank < 0 || maxRank < threshold {
+		return 0
+	}
+	if maxRank == 0 {
+		return 2
+	}
+	return 1 + maxRank
+}
+
+// ExitError is returned by commands whose run found violations at or above their failure
+// threshold, to exit with Code as returned by ExitCode.
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// Violation is a violation found by the review run.
+type Violation struct {
+	// Constraint is the name of the violated constraint in "[Kind].[Name]" format.
+	Constraint string `json:"constraint"`
+	// Resource is the name of the violating asset.
+	Resource string `json:"resource"`
+	// AssetType and AncestryPath are those of the violating asset, if known.
+	AssetType    string `json:"asset_type,omitempty"`
+	AncestryPath string `json:"ancestry_path,omitempty"`
+	Message      string `json:"message"`
+	Severity     string `json:"severity,omitempty"`
+	// Source is the file and line the asset was read from, if known.
+	Source *Source `json:"source,omitempty"`
+	// Metadata is the metadata returned by the constraint check.
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+	// Remediation is the guidance the constraint declares on fixing the violation, if any.
+	Remediation string `json:"remediation,omitempty"`
+	// RemediationURL links to documentation on fixing the violation, if declared.
+	RemediationURL string `json:"remediation_url,omitempty"`
+	// Snippets are concrete changes fixing the violation, if rendered.
+	Snippets []gcv.Snippet `json:"snippets,omitempty"`
+}
+
+// Fingerprint returns the fingerprint of the violation, as returned by gcv.ViolationFingerprint.
+func (v *Violation) Fingerprint() string {
+	return gcv.Fingerprint(v.Constraint, v.Resource, v.Message)
+}
+
+// Source is the location of an asset in an export file.
+type Source struct {
+	File string `json:"file"`
+	Line int    `json:"line"`
+}
+
+// Report holds the outcome of a review run.
+type Report struct {
+	// PolicyVersion is the version of the policy set used for the review.
+	PolicyVersion string `json:"policy_version"`
+	// Manifest records the policy code, inputs and timing of the run, if set.
+	Manifest *Manifest `json:"manifest,omitempty"`
+	// Constraints are the names of the constraints assets were reviewed against, used to report
+	// passed checks.
+	Constraints []string `json:"constraints,omitempty"`
+	// Assets is the number of reviewed assets.
+	Assets int `json:"assets"`
+	// Errors is the number of assets that could not be reviewed.
+	Errors     int          `json:"errors"`
+	Violations []*Violation `json:"violations"`
+	// SkippedAssets counts the assets no target handles by asset type, which were not reviewed.
+	SkippedAssets map[string]int `json:"skipped_assets,omitempty"`
+	// Duplicates is the number of records dropped as duplicates of assets already reviewed.
+	Duplicates int `json:"duplicates,omitempty"`
+}
+
+// New returns an empty report for a run with validator.
+func New(validator *gcv.Validator) *Report {
+	r := &Report{PolicyVersion: validator.PolicyVersion(), Violations: []*Violation{}}
+	for _, constraint := range validator.Constraints() {
+		r.Constraints = append(r.Constraints, gcv.ConstraintName(constraint))
+	}
+	sort.Strings(r.Constraints)
+	return r
+}
+
+// Add records the violations of a reviewed asset, or counts it as skipped if it was not reviewed.
+func (r *Report) Add(result *gcv.Result) {
+	if result.Skipped {
+		assetType, _ := result.CAIResource["asset_type"].(string)
+		if assetType == "" {
+			assetType = "unknown"
+		}
+		if r.SkippedAssets == nil {
+			r.SkippedAssets = map[string]int{}
+		}
+		r.SkippedAssets[assetType]++
+		return
+	}
+	r.Assets++
+	r.addViolations(result, nil)
+}
+
+// AddImported records the violations of a resource found outside the review run, such as by
+// Gatekeeper audit on a cluster, without counting it as a reviewed asset. Violations sharing
+// their fingerprint with a violation already in the report are dropped, so that importing the
+// findings of the same policies on the same resources after a review adds nothing.
+func (r *Report) AddImported(result *gcv.Result) {
+	seen := map[string]bool{}
+	for _, v := range r.Violations {
+		seen[v.Fingerprint()] = true
+	}
+	r.addViolations(result, seen)
+}
+
+// addViolations adds a Violation for each violation of result whose fingerprint is not in skip.
+func (r *Report) addViolations(result *gcv.Result, skip map[string]bool) {
+	assetType, _ := result.CAIResource["asset_type"].(string)
+	ancestryPath, _ := result.CAIResource["ancestry_path"].(string)
+	for idx := range result.ConstraintViolations {
+		cv := &result.ConstraintViolations[idx]
+		if skip[cv.Fingerprint(result.Name)] {
+			continue
+		}
+		v := &Violation{
+			Constraint:     cv.ConstraintName(),
+			Resource:       result.Name,
+			AssetType:      assetType,
+			AncestryPath:   ancestryPath,
+			Message:        cv.Message,
+			Severity:       cv.Severity,
+			Metadata:       cv.Metadata,
+			Remediation:    cv.Remediation,
+			RemediationURL: cv.RemediationURL,
+			Snippets:       cv.Snippets,
+		}
+		if result.Source != nil {
+			v.Source = &Source{File: result.Source.File, Line: result.Source.Line}
+		}
+		r.Violations = append(r.Violations, v)
+	}
+}
+
+// AddError records an asset that failed review.
+func (r *Report) AddError() {
+	r.Errors++
+}
+
+// AddDuplicate records a record dropped as the duplicate of an asset already reviewed.
+func (r *Report) AddDuplicate() {
+	r.Duplicates++
+}
+
+// MaxSeverityRank returns the highest SeverityRank of the violations, -1 if there are none.
+func (r *Report) MaxSeverityRank() int {
+	max := -1
+	for _, v := range r.Violations {
+		if rank := SeverityRank(v.Severity); rank > max {
+			max = rank
+		}
+	}
+	return max
+}
+
+// Write writes the report in format, one of Formats.
+func (r *Report) Write(w io.Writer, format string) error {
+	switch format {
+	case JSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return errors.Wrapf(encoder.Encode(r), "failed to encode report")
+	case YAML:
+		out, err := yaml.Marshal(r)
+		if err != nil {
+			return errors.Wrapf(err, "failed to encode report")
+		}
+		_, err = w.Write(out)
+		return err
+	case SARIF:
+		return r.writeSARIF(w)
+	case JUnit:
+		return r.writeJUnit(w)
+	case Table:
+		return r.writeTable(w)
+	case CSV, TSV:
+		return r.WriteColumns(w, format, DefaultColumns)
+	case HTML:
+		return r.writeHTML(w)
+	case ForsetiJSON:
+		return r.writeForsetiJSON(w)
+	case ForsetiCSV:
+		return r.writeForsetiCSV(w)
+	}
+	return errors.Errorf("unknown output format %q, want one of %s", format, strings.Join(Formats, ", "))
+}
+
+func (r *Report) writeTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	if len(r.Violations) != 0 {
+		fmt.Fprintln(tw, "SEVERITY\tCONSTRAINT\tRESOURCE\tMESSAGE")
+	}
+	for _, v := range r.sortedViolations() {
+		severity := v.Severity
+		if severity == "" {
+			severity = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", severity, v.Constraint, v.Resource, v.Message)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "%d violations in %d assets, %d errors\n", len(r.Violations), r.Assets, r.Errors); err != nil {
+		return err
+	}
+	var assetTypes []string
+	for assetType := range r.SkippedAssets {
+		assetTypes = append(assetTypes, assetType)
+	}
+	sort.Strings(assetTypes)
+	for _, assetType := range assetTypes {
+		if _, err := fmt.Fprintf(w, "skipped %d assets of unsupported type %s\n", r.SkippedAssets[assetType], assetType); err != nil {
+			return err
+		}
+	}
+	if r.Duplicates != 0 {
+		if _, err := fmt.Fprintf(w, "dropped %d duplicate assets\n", r.Duplicates); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sortedViolations returns the violations by descending severity, then constraint and resource.
+func (r *Report) sortedViolations() []*Violation {
+	violations := append([]*Violation{}, r.Violations...)
+	sort.SliceStable(violations, func(i, j int) bool {
+		a, b := violations[i], violations[j]
+		if rankA, rankB := SeverityRank(a.Severity), SeverityRank(b.Severity); rankA != rankB {
+			return rankA > rankB
+		}
+		if a.Constraint != b.Constraint {
+			return a.Constraint < b.Constraint
+		}
+		return a.Resource < b.Resource
+	})
+	return violations
+}
@@ -0,0 +1,483 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/forseti-security/config-validator/pkg/gcv"
+	"github.com/forseti-security/config-validator/pkg/gcv/configs"
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func testReport() *Report {
+	return &Report{
+		PolicyVersion: "v1",
+		Constraints:   []string{"GCPSQLPublicIPCELConstraint.sql-no-public-ip", "GCPStorageLoggingConstraint.require-storage-logging"},
+		Assets:        3,
+		Errors:        1,
+		Violations: []*Violation{
+			{
+				Constraint:   "GCPStorageLoggingConstraint.require-storage-logging",
+				Resource:     "//storage.googleapis.com/b",
+				Message:      "no logging",
+				Severity:     "medium",
+				AssetType:    "storage.googleapis.com/Bucket",
+				AncestryPath: "organizations/123/projects/p",
+				Metadata:     map[string]interface{}{"bucket": "b"},
+				// Remediation is reported as the help of the rule.
+				Remediation:    "Enable access logging.",
+				RemediationURL: "https://cloud.google.com/storage/docs/access-logs",
+			},
+			{
+				Constraint: "GCPExternalIPConstraint.deny-vm-external-ip-access",
+				Resource:   "//compute.googleapis.com/vm",
+				Message:    "external ip",
+				Severity:   "high",
+				Source:     &Source{File: "assets.json", Line: 7},
+			},
+		},
+	}
+}
+
+func TestSeverityRank(t *testing.T) {
+	got := []int{SeverityRank(""), SeverityRank("low"), SeverityRank("Medium"), SeverityRank("high"), SeverityRank("CRITICAL")}
+	if diff := cmp.Diff([]int{0, 1, 2, 3, 4}, got); diff != "" {
+		t.Errorf("unexpected ranks (-want +got):\n%s", diff)
+	}
+	if got := (&Report{}).MaxSeverityRank(); got != -1 {
+		t.Errorf("got MaxSeverityRank %d for no violations, want -1", got)
+	}
+	if got := testReport().MaxSeverityRank(); got != 3 {
+		t.Errorf("got MaxSeverityRank %d, want 3", got)
+	}
+}
+
+func TestWriteTable(t *testing.T) {
+	var out bytes.Buffer
+	if err := testReport().Write(&out, Table); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := `SEVERITY  CONSTRAINT                                           RESOURCE                     MESSAGE
+high      GCPExternalIPConstraint.deny-vm-external-ip-access   //compute.googleapis.com/vm  external ip
+medium    GCPStorageLoggingConstraint.require-storage-logging  //storage.googleapis.com/b   no logging
+2 violations in 3 assets, 1 errors
+`
+	if diff := cmp.Diff(want, out.String()); diff != "" {
+		t.Errorf("unexpected table (-want +got):\n%s", diff)
+	}
+}
+
+func TestAddSkipped(t *testing.T) {
+	r := &Report{}
+	for _, assetType := range []string{"compute.googleapis.com/Instance", "compute.googleapis.com/Instance", ""} {
+		r.Add(&gcv.Result{Skipped: true, CAIResource: map[string]interface{}{"asset_type": assetType}})
+	}
+	r.AddDuplicate()
+	if diff := cmp.Diff(map[string]int{"compute.googleapis.com/Instance": 2, "unknown": 1}, r.SkippedAssets); diff != "" {
+		t.Errorf("unexpected skipped assets (-want +got):\n%s", diff)
+	}
+	var out bytes.Buffer
+	if err := r.Write(&out, Table); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := `0 violations in 0 assets, 0 errors
+skipped 2 assets of unsupported type compute.googleapis.com/Instance
+skipped 1 assets of unsupported type unknown
+dropped 1 duplicate assets
+`
+	if diff := cmp.Diff(want, out.String()); diff != "" {
+		t.Errorf("unexpected table (-want +got):\n%s", diff)
+	}
+}
+
+func TestAddImported(t *testing.T) {
+	r := testReport()
+	constraint := &unstructured.Unstructured{}
+	constraint.SetKind("GCPStorageLoggingConstraint")
+	constraint.SetName("require-storage-logging")
+	r.AddImported(&gcv.Result{
+		Name: "//storage.googleapis.com/b",
+		ConstraintViolations: []gcv.ConstraintViolation{
+			{Message: "no logging", Constraint: constraint},
+			{Message: "other", Constraint: constraint},
+		},
+	})
+	// Only the violation the review did not find is added, without counting the resource.
+	if r.Assets != 3 || len(r.Violations) != 3 || r.Violations[2].Message != "other" {
+		t.Errorf("unexpected report: %d assets, violations %v", r.Assets, r.Violations)
+	}
+}
+
+func TestWriteSARIF(t *testing.T) {
+	var out bytes.Buffer
+	if err := testReport().Write(&out, SARIF); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var log sarifLog
+	if err := json.Unmarshal(out.Bytes(), &log); err != nil {
+		t.Fatalf("invalid SARIF: %v", err)
+	}
+	run := log.Runs[0]
+	var rules []string
+	for _, rule := range run.Tool.Driver.Rules {
+		rules = append(rules, rule.ID)
+	}
+	// Violated constraints missing from Constraints are added as rules.
+	wantRules := []string{
+		"GCPSQLPublicIPCELConstraint.sql-no-public-ip",
+		"GCPStorageLoggingConstraint.require-storage-logging",
+		"GCPExternalIPConstraint.deny-vm-external-ip-access",
+	}
+	if diff := cmp.Diff(wantRules, rules); diff != "" {
+		t.Errorf("unexpected rules (-want +got):\n%s", diff)
+	}
+	wantRule := sarifRule{
+		ID:      "GCPStorageLoggingConstraint.require-storage-logging",
+		Help:    &sarifMessage{Text: "Enable access logging."},
+		HelpURI: "https://cloud.google.com/storage/docs/access-logs",
+	}
+	if diff := cmp.Diff(wantRule, run.Tool.Driver.Rules[1]); diff != "" {
+		t.Errorf("unexpected rule (-want +got):\n%s", diff)
+	}
+	want := []sarifResult{
+		{
+			RuleID:    "GCPExternalIPConstraint.deny-vm-external-ip-access",
+			RuleIndex: 2,
+			Level:     "error",
+			Message:   sarifMessage{Text: "external ip"},
+			Locations: []sarifLocation{{
+				PhysicalLocation: &sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{URI: "assets.json"},
+					Region:           &sarifRegion{StartLine: 7},
+				},
+				LogicalLocations: []sarifLogicalLocation{{FullyQualifiedName: "//compute.googleapis.com/vm", Kind: "resource"}},
+			}},
+		},
+		{
+			RuleID:    "GCPStorageLoggingConstraint.require-storage-logging",
+			RuleIndex: 1,
+			Level:     "warning",
+			Message:   sarifMessage{Text: "no logging"},
+			Locations: []sarifLocation{{
+				LogicalLocations: []sarifLogicalLocation{{FullyQualifiedName: "//storage.googleapis.com/b", Kind: "resource"}},
+			}},
+		},
+	}
+	if diff := cmp.Diff(want, run.Results); diff != "" {
+		t.Errorf("unexpected results (-want +got):\n%s", diff)
+	}
+}
+
+func TestWriteJUnit(t *testing.T) {
+	var out bytes.Buffer
+	if err := testReport().Write(&out, JUnit); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var suites junitTestSuites
+	if err := xml.Unmarshal(out.Bytes(), &suites); err != nil {
+		t.Fatalf("invalid JUnit XML: %v", err)
+	}
+	want := junitTestSuite{
+		Name:     "config-validator",
+		Tests:    3,
+		Failures: 2,
+		Errors:   1,
+		Cases: []junitTestCase{
+			{
+				Name:      "GCPExternalIPConstraint.deny-vm-external-ip-access",
+				ClassName: "GCPExternalIPConstraint",
+				Failure:   &junitFailure{Message: "1 violations", Type: "high", Text: "//compute.googleapis.com/vm: external ip"},
+			},
+			{Name: "GCPSQLPublicIPCELConstraint.sql-no-public-ip", ClassName: "GCPSQLPublicIPCELConstraint"},
+			{
+				Name:      "GCPStorageLoggingConstraint.require-storage-logging",
+				ClassName: "GCPStorageLoggingConstraint",
+				Failure:   &junitFailure{Message: "1 violations", Type: "medium", Text: "//storage.googleapis.com/b: no logging"},
+			},
+		},
+	}
+	if diff := cmp.Diff([]junitTestSuite{want}, suites.Suites); diff != "" {
+		t.Errorf("unexpected test suites (-want +got):\n%s", diff)
+	}
+}
+
+func TestWriteForseti(t *testing.T) {
+	r := testReport()
+	r.Manifest = &Manifest{StartTime: time.Date(2020, 5, 1, 10, 30, 0, 0, time.UTC)}
+	var out bytes.Buffer
+	if err := r.Write(&out, ForsetiJSON); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var got []*forsetiViolation
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	storage := r.Violations[0]
+	want := []*forsetiViolation{
+		{
+			ResourceID:        "vm",
+			ResourceName:      "//compute.googleapis.com/vm",
+			RuleName:          "GCPExternalIPConstraint.deny-vm-external-ip-access",
+			ViolationType:     "CONFIG_VALIDATOR_VIOLATION",
+			ViolationData:     map[string]interface{}{},
+			ViolationHash:     r.Violations[1].Fingerprint(),
+			ViolationMessage:  "external ip",
+			CreatedAtDatetime: "2020-05-01T10:30:00Z",
+		},
+		{
+			ResourceID:        "b",
+			ResourceType:      "storage.googleapis.com/Bucket",
+			ResourceName:      "//storage.googleapis.com/b",
+			FullName:          "organization/123/project/p/bucket/b/",
+			RuleName:          "GCPStorageLoggingConstraint.require-storage-logging",
+			ViolationType:     "CONFIG_VALIDATOR_VIOLATION",
+			ViolationData:     map[string]interface{}{"bucket": "b"},
+			ViolationHash:     storage.Fingerprint(),
+			ViolationMessage:  "no logging",
+			CreatedAtDatetime: "2020-05-01T10:30:00Z",
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected violations (-want +got):\n%s", diff)
+	}
+
+	out.Reset()
+	if err := r.Write(&out, ForsetiCSV); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 || lines[0] != strings.Join(forsetiColumns, ",") {
+		t.Fatalf("unexpected CSV:\n%s", out.String())
+	}
+	wantLine := `b,storage.googleapis.com/Bucket,//storage.googleapis.com/b,organization/123/project/p/bucket/b/,0,` +
+		`GCPStorageLoggingConstraint.require-storage-logging,CONFIG_VALIDATOR_VIOLATION,"{""bucket"":""b""}",` +
+		storage.Fingerprint() + `,no logging,,2020-05-01T10:30:00Z`
+	if lines[2] != wantLine {
+		t.Errorf("got CSV line\n%s\nwant\n%s", lines[2], wantLine)
+	}
+}
+
+func TestWriteColumns(t *testing.T) {
+	r := testReport()
+	r.Manifest = &Manifest{StartTime: time.Date(2020, 5, 1, 10, 30, 0, 0, time.UTC)}
+	var out bytes.Buffer
+	if err := r.Write(&out, CSV); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := `constraint,resource,project,severity,message,timestamp
+GCPExternalIPConstraint.deny-vm-external-ip-access,//compute.googleapis.com/vm,,high,external ip,2020-05-01T10:30:00Z
+GCPStorageLoggingConstraint.require-storage-logging,//storage.googleapis.com/b,projects/p,medium,no logging,2020-05-01T10:30:00Z
+`
+	if diff := cmp.Diff(want, out.String()); diff != "" {
+		t.Errorf("unexpected csv (-want +got):\n%s", diff)
+	}
+
+	out.Reset()
+	if err := r.WriteColumns(&out, TSV, []string{ColumnSeverity, ColumnAssetType, ColumnMessage}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want = "severity\tasset_type\tmessage\nhigh\t\texternal ip\nmedium\tstorage.googleapis.com/Bucket\tno logging\n"
+	if diff := cmp.Diff(want, out.String()); diff != "" {
+		t.Errorf("unexpected tsv (-want +got):\n%s", diff)
+	}
+
+	for _, tc := range []struct {
+		format  string
+		columns []string
+		wantErr string
+	}{
+		{format: CSV, columns: []string{"owner"}, wantErr: `unknown column "owner"`},
+		{format: JSON, columns: DefaultColumns, wantErr: "output format json has no columns"},
+	} {
+		err := r.WriteColumns(&bytes.Buffer{}, tc.format, tc.columns)
+		if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("got error %v, want %s", err, tc.wantErr)
+		}
+	}
+}
+
+func TestWriteHTML(t *testing.T) {
+	r := testReport()
+	r.Violations[1].Message = "<script>alert(1)</script>"
+	var out bytes.Buffer
+	if err := r.Write(&out, HTML); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	page := out.String()
+	for _, want := range []string{
+		`<span class="badge high">1 high</span> <span class="badge medium">1 medium</span>`,
+		`<h2><span class="badge medium">medium</span> GCPStorageLoggingConstraint.require-storage-logging (1)</h2>`,
+		`<dt>Ancestry</dt><dd>organizations/123/projects/p</dd>`,
+		`<dt>Source</dt><dd>assets.json:7</dd>`,
+		"<pre>{\n  &#34;bucket&#34;: &#34;b&#34;\n}</pre>",
+		"&lt;script&gt;alert(1)&lt;/script&gt;",
+		"<li>GCPSQLPublicIPCELConstraint.sql-no-public-ip</li>",
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("page does not contain %s:\n%s", want, page)
+		}
+	}
+	// Sections are ordered by severity.
+	if high, medium := strings.Index(page, "deny-vm-external-ip-access (1)"), strings.Index(page, "require-storage-logging (1)"); high > medium {
+		t.Errorf("high severity section after medium severity section")
+	}
+	if strings.Contains(page, "<script>") || strings.Contains(page, "http://") {
+		t.Errorf("page is not self-contained or not escaped:\n%s", page)
+	}
+}
+
+func TestFromResults(t *testing.T) {
+	constraint := &unstructured.Unstructured{}
+	constraint.SetKind("GCPStorageLoggingConstraint")
+	constraint.SetName("require-storage-logging")
+	r := FromResults([]*gcv.Result{
+		{
+			Name:                 "//storage.googleapis.com/b",
+			PolicyVersion:        "v2",
+			CAIResource:          map[string]interface{}{"asset_type": "storage.googleapis.com/Bucket"},
+			ConstraintViolations: []gcv.ConstraintViolation{{Message: "no logging", Constraint: constraint}},
+		},
+		{Name: "//storage.googleapis.com/c", PolicyVersion: "v2"},
+	})
+	if r.PolicyVersion != "v2" || r.Assets != 2 || len(r.Violations) != 1 || r.Violations[0].AssetType != "storage.googleapis.com/Bucket" {
+		t.Errorf("unexpected report %+v", r)
+	}
+}
+
+func TestWriteUnknownFormat(t *testing.T) {
+	err := testReport().Write(&bytes.Buffer{}, "xml")
+	if err == nil || !strings.Contains(err.Error(), `unknown output format "xml"`) {
+		t.Errorf("got error %v, want unknown output format", err)
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	for _, tc := range []struct {
+		threshold string
+		maxRank   int
+		want      int
+	}{
+		{threshold: "", maxRank: -1, want: 0},
+		{threshold: "", maxRank: 0, want: 2},
+		{threshold: "", maxRank: 1, want: 2},
+		{threshold: "", maxRank: 2, want: 3},
+		{threshold: "high", maxRank: 2, want: 0},
+		{threshold: "HIGH", maxRank: 3, want: 4},
+		{threshold: "high", maxRank: 4, want: 5},
+		{threshold: "low", maxRank: 0, want: 0},
+	} {
+		threshold, err := ParseSeverity(tc.threshold)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := ExitCode(tc.maxRank, threshold); got != tc.want {
+			t.Errorf("ExitCode(%d, %q) = %d, want %d", tc.maxRank, tc.threshold, got, tc.want)
+		}
+	}
+	if _, err := ParseSeverity("severe"); err == nil {
+		t.Errorf("ParseSeverity(severe) returned no error")
+	}
+}
+
+func testManifest() *Manifest {
+	start := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	return &Manifest{
+		RunID:            "run-1",
+		ValidatorVersion: "v1.2.3",
+		Policies: &gcv.Provenance{
+			PolicyVersion: "v1",
+			ContentHash:   "abc",
+			Sources: []configs.PolicySource{
+				{Path: "policies", GitCommit: "1234"},
+				{Path: "main.policyset.yaml", Digest: "beef"},
+			},
+		},
+		Inputs:          []string{"export.json"},
+		StartTime:       start,
+		EndTime:         start.Add(1500 * time.Millisecond),
+		DurationSeconds: 1.5,
+	}
+}
+
+func TestManifestFinish(t *testing.T) {
+	defer func(now func() time.Time) { timeNow = now }(timeNow)
+	m := testManifest()
+	timeNow = func() time.Time { return m.StartTime.Add(time.Minute) }
+	m.Finish()
+	if m.DurationSeconds != 60 || !m.EndTime.Equal(m.StartTime.Add(time.Minute)) {
+		t.Errorf("got end time %s and duration %v, want a minute after start", m.EndTime, m.DurationSeconds)
+	}
+}
+
+func TestWriteManifest(t *testing.T) {
+	r := testReport()
+	r.Manifest = testManifest()
+
+	var out bytes.Buffer
+	if err := r.Write(&out, SARIF); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var log sarifLog
+	if err := json.Unmarshal(out.Bytes(), &log); err != nil {
+		t.Fatalf("invalid SARIF: %v", err)
+	}
+	run := log.Runs[0]
+	wantInvocations := []sarifInvocation{{
+		ExecutionSuccessful: false,
+		StartTimeUTC:        "2020-06-01T12:00:00Z",
+		EndTimeUTC:          "2020-06-01T12:00:01.5Z",
+	}}
+	if diff := cmp.Diff(wantInvocations, run.Invocations); diff != "" {
+		t.Errorf("unexpected invocations (-want +got):\n%s", diff)
+	}
+	if run.Properties == nil || run.Properties.Manifest == nil {
+		t.Fatalf("run has no manifest: %s", out.String())
+	}
+	if diff := cmp.Diff(r.Manifest, run.Properties.Manifest); diff != "" {
+		t.Errorf("unexpected manifest (-want +got):\n%s", diff)
+	}
+
+	out.Reset()
+	if err := r.Write(&out, JUnit); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var suites junitTestSuites
+	if err := xml.Unmarshal(out.Bytes(), &suites); err != nil {
+		t.Fatalf("invalid JUnit XML: %v", err)
+	}
+	suite := suites.Suites[0]
+	if suite.Timestamp != "2020-06-01T12:00:00" || suite.Time != "1.500" {
+		t.Errorf("got timestamp %q and time %q", suite.Timestamp, suite.Time)
+	}
+	wantProperties := []junitProperty{
+		{Name: "run_id", Value: "run-1"},
+		{Name: "validator_version", Value: "v1.2.3"},
+		{Name: "policy_version", Value: "v1"},
+		{Name: "policy_content_hash", Value: "abc"},
+		{Name: "policy_source", Value: "policies@1234"},
+		{Name: "policy_source", Value: "main.policyset.yaml sha256:beef"},
+		{Name: "input", Value: "export.json"},
+	}
+	if diff := cmp.Diff(wantProperties, suite.Properties); diff != "" {
+		t.Errorf("unexpected properties (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,181 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package report
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://schemastore.azurewebsites.net/schemas/json/sarif-2.1.0-rtm.4.json"
+	toolName     = "config-validator"
+	toolURI      = "https://github.com/forseti-security/config-validator"
+)
+
+// The subset of SARIF 2.1.0 written by writeSARIF.
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool        sarifTool         `json:"tool"`
+	Invocations []sarifInvocation `json:"invocations,omitempty"`
+	Results     []sarifResult     `json:"results"`
+	Properties  *sarifProperties  `json:"properties,omitempty"`
+}
+
+type sarifInvocation struct {
+	ExecutionSuccessful bool   `json:"executionSuccessful"`
+	StartTimeUTC        string `json:"startTimeUtc"`
+	EndTimeUTC          string `json:"endTimeUtc"`
+}
+
+type sarifProperties struct {
+	Manifest *Manifest `json:"manifest"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Version        string      `json:"version,omitempty"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID      string        `json:"id"`
+	Help    *sarifMessage `json:"help,omitempty"`
+	HelpURI string        `json:"helpUri,omitempty"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	RuleIndex int             `json:"ruleIndex"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation *sarifPhysicalLocation `json:"physicalLocation,omitempty"`
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+type sarifLogicalLocation struct {
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+// sarifLevel maps the severity of a constraint to a SARIF result level.
+func sarifLevel(severity string) string {
+	switch rank := SeverityRank(severity); {
+	case rank >= SeverityRank("high"):
+		return "error"
+	case rank == SeverityRank("low"):
+		return "note"
+	}
+	return "warning"
+}
+
+// writeSARIF writes the report as a SARIF log with one rule per constraint. Violations are
+// located at the asset name and, if known, the line of the export file the asset was read from.
+func (r *Report) writeSARIF(w io.Writer) error {
+	driver := sarifDriver{Name: toolName, InformationURI: toolURI, Version: r.PolicyVersion, Rules: []sarifRule{}}
+	ruleIndex := map[string]int{}
+	addRule := func(id string) {
+		if _, found := ruleIndex[id]; !found {
+			ruleIndex[id] = len(driver.Rules)
+			driver.Rules = append(driver.Rules, sarifRule{ID: id})
+		}
+	}
+	for _, constraint := range r.Constraints {
+		addRule(constraint)
+	}
+
+	results := []sarifResult{}
+	for _, v := range r.sortedViolations() {
+		addRule(v.Constraint)
+		// Remediation is declared per constraint, so it is the same for all of its violations.
+		rule := &driver.Rules[ruleIndex[v.Constraint]]
+		if v.Remediation != "" {
+			rule.Help = &sarifMessage{Text: v.Remediation}
+		}
+		if v.RemediationURL != "" {
+			rule.HelpURI = v.RemediationURL
+		}
+		location := sarifLocation{
+			LogicalLocations: []sarifLogicalLocation{{FullyQualifiedName: v.Resource, Kind: "resource"}},
+		}
+		if v.Source != nil && v.Source.File != "" {
+			location.PhysicalLocation = &sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifactLocation{URI: v.Source.File},
+				Region:           &sarifRegion{StartLine: v.Source.Line},
+			}
+		}
+		results = append(results, sarifResult{
+			RuleID:    v.Constraint,
+			RuleIndex: ruleIndex[v.Constraint],
+			Level:     sarifLevel(v.Severity),
+			Message:   sarifMessage{Text: v.Message},
+			Locations: []sarifLocation{location},
+		})
+	}
+
+	run := sarifRun{Tool: sarifTool{Driver: driver}, Results: results}
+	if m := r.Manifest; m != nil {
+		// The run manifest is kept whole in the properties of the run.
+		run.Invocations = []sarifInvocation{{
+			ExecutionSuccessful: r.Errors == 0,
+			StartTimeUTC:        m.StartTime.Format(time.RFC3339Nano),
+			EndTimeUTC:          m.EndTime.Format(time.RFC3339Nano),
+		}}
+		run.Properties = &sarifProperties{Manifest: m}
+	}
+	log := sarifLog{
+		Version: sarifVersion,
+		Schema:  sarifSchema,
+		Runs:    []sarifRun{run},
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return errors.Wrapf(encoder.Encode(log), "failed to encode SARIF log")
+}
@@ -0,0 +1,114 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package report
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Columns of the CSV and TSV output.
+const (
+	ColumnConstraint   = "constraint"
+	ColumnResource     = "resource"
+	ColumnAssetType    = "asset_type"
+	ColumnProject      = "project"
+	ColumnAncestryPath = "ancestry_path"
+	ColumnSeverity     = "severity"
+	ColumnMessage      = "message"
+	ColumnRemediation  = "remediation"
+	ColumnFingerprint  = "fingerprint"
+	ColumnTimestamp    = "timestamp"
+)
+
+// Columns are the columns the CSV and TSV output can have.
+var Columns = []string{
+	ColumnConstraint, ColumnResource, ColumnAssetType, ColumnProject, ColumnAncestryPath, ColumnSeverity, ColumnMessage,
+	ColumnRemediation, ColumnFingerprint, ColumnTimestamp,
+}
+
+// DefaultColumns are the columns of the CSV and TSV output written by Write.
+var DefaultColumns = []string{ColumnConstraint, ColumnResource, ColumnProject, ColumnSeverity, ColumnMessage, ColumnTimestamp}
+
+// columnValues returns the value of each column for a violation of a run started at timestamp.
+var columnValues = map[string]func(v *Violation, timestamp string) string{
+	ColumnConstraint:   func(v *Violation, _ string) string { return v.Constraint },
+	ColumnResource:     func(v *Violation, _ string) string { return v.Resource },
+	ColumnAssetType:    func(v *Violation, _ string) string { return v.AssetType },
+	ColumnProject:      func(v *Violation, _ string) string { return project(v.AncestryPath) },
+	ColumnAncestryPath: func(v *Violation, _ string) string { return v.AncestryPath },
+	ColumnSeverity:     func(v *Violation, _ string) string { return v.Severity },
+	ColumnMessage:      func(v *Violation, _ string) string { return v.Message },
+	ColumnRemediation:  func(v *Violation, _ string) string { return v.Remediation },
+	ColumnFingerprint:  func(v *Violation, _ string) string { return v.Fingerprint() },
+	ColumnTimestamp:    func(_ *Violation, timestamp string) string { return timestamp },
+}
+
+// project returns the project of an ancestry path, such as projects/123 for
+// organizations/1/folders/2/projects/123, empty if the path has no project.
+func project(ancestryPath string) string {
+	segments := strings.Split(ancestryPath, "/")
+	for idx := 0; idx+1 < len(segments); idx += 2 {
+		if segments[idx] == "projects" {
+			return segments[idx] + "/" + segments[idx+1]
+		}
+	}
+	return ""
+}
+
+// WriteColumns writes the violations in format CSV or TSV, with a header row of columns, each one
+// of Columns. Violations are in the order of the table output, and their timestamp is the start
+// of the run recorded in the manifest, empty without one.
+func (r *Report) WriteColumns(w io.Writer, format string, columns []string) error {
+	cw := csv.NewWriter(w)
+	switch format {
+	case CSV:
+	case TSV:
+		cw.Comma = '\t'
+	default:
+		return errors.Errorf("output format %s has no columns, want one of %s, %s", format, CSV, TSV)
+	}
+	if len(columns) == 0 {
+		return errors.Errorf("no columns")
+	}
+	for _, column := range columns {
+		if _, found := columnValues[column]; !found {
+			return errors.Errorf("unknown column %q, want one of %s", column, strings.Join(Columns, ", "))
+		}
+	}
+
+	var timestamp string
+	if r.Manifest != nil {
+		timestamp = r.Manifest.StartTime.UTC().Format(time.RFC3339)
+	}
+	if err := cw.Write(columns); err != nil {
+		return errors.Wrapf(err, "failed to write report")
+	}
+	record := make([]string, len(columns))
+	for _, v := range r.sortedViolations() {
+		for idx, column := range columns {
+			record[idx] = columnValues[column](v, timestamp)
+		}
+		if err := cw.Write(record); err != nil {
+			return errors.Wrapf(err, "failed to write report")
+		}
+	}
+	cw.Flush()
+	return errors.Wrapf(cw.Error(), "failed to write report")
+}
@@ -0,0 +1,48 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpcauth
+
+import (
+	"context"
+)
+
+// certificateAuthenticator authenticates callers by the client certificates verified by TLS.
+type certificateAuthenticator struct{}
+
+// NewCertificateAuthenticator returns the Authenticator of callers presenting a client
+// certificate verified by the TLS handshake (mTLS). Callers are known by the URI, email and DNS
+// subject alternative names of their certificate, then its subject common name. The server must
+// verify client certificates, which tlsconfig.ServerOptions.ClientCAFile enables.
+func NewCertificateAuthenticator() Authenticator {
+	return certificateAuthenticator{}
+}
+
+// Authenticate implements Authenticator.
+func (certificateAuthenticator) Authenticate(ctx context.Context, creds *Credentials) (*Identity, error) {
+	if len(creds.VerifiedChains) == 0 || len(creds.VerifiedChains[0]) == 0 {
+		return nil, nil
+	}
+	leaf := creds.VerifiedChains[0][0]
+	id := &Identity{Method: "mtls"}
+	for _, uri := range leaf.URIs {
+		id.Names = append(id.Names, uri.String())
+	}
+	id.Names = append(id.Names, leaf.EmailAddresses...)
+	id.Names = append(id.Names, leaf.DNSNames...)
+	if leaf.Subject.CommonName != "" {
+		id.Names = append(id.Names, leaf.Subject.CommonName)
+	}
+	return id, nil
+}
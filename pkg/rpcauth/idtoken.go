@@ -0,0 +1,325 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpcauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// GoogleCertsURL serves the JSON Web Key Set of the keys Google signs ID tokens with.
+const GoogleCertsURL = "https://www.googleapis.com/oauth2/v3/certs"
+
+const (
+	// defaultKeysTTL is how long keys are cached if the key set response has no max-age.
+	defaultKeysTTL = time.Hour
+	// minKeysRefresh is the minimum time between fetches of the key set when tokens are signed
+	// by unknown keys, so that such tokens cannot make the server hammer the endpoint.
+	minKeysRefresh = time.Minute
+	// clockSkew is how far the expiry and issue time of tokens may be off.
+	clockSkew = time.Minute
+)
+
+// googleIssuers are the issuers of Google-signed ID tokens.
+var googleIssuers = map[string]bool{"accounts.google.com": true, "https://accounts.google.com": true}
+
+// IDTokenOption configures optional IDTokenAuthenticator behavior.
+type IDTokenOption func(*IDTokenAuthenticator)
+
+// WithCertsURL fetches the signing keys from url instead of GoogleCertsURL.
+func WithCertsURL(url string) IDTokenOption {
+	return func(a *IDTokenAuthenticator) {
+		a.certsURL = url
+	}
+}
+
+// WithHTTPClient fetches the signing keys with client instead of http.DefaultClient.
+func WithHTTPClient(client *http.Client) IDTokenOption {
+	return func(a *IDTokenAuthenticator) {
+		a.client = client
+	}
+}
+
+// IDTokenAuthenticator authenticates callers by Google-signed OpenID Connect ID tokens, such as
+// those of service accounts from the metadata server or gcloud auth print-identity-token. It is
+// safe for concurrent use.
+type IDTokenAuthenticator struct {
+	audiences map[string]bool
+	certsURL  string
+	client    *http.Client
+	now       func() time.Time
+
+	// fetchMu serializes key set fetches, mu guards the cached key set. Fetches do not hold mu, so
+	// tokens signed by cached keys are verified while the key set is fetched.
+	fetchMu sync.Mutex
+	mu      sync.Mutex
+	keys    map[string]*rsa.PublicKey
+	fetched time.Time
+	expires time.Time
+}
+
+// NewIDTokenAuthenticator returns the Authenticator of callers presenting an ID token issued for
+// one of audiences as bearer token. Callers are known by the email of the token if it is verified,
+// then its subject.
+func NewIDTokenAuthenticator(audiences []string, opts ...IDTokenOption) (*IDTokenAuthenticator, error) {
+	if len(audiences) == 0 {
+		return nil, errors.New("ID tokens require an audience")
+	}
+	a := &IDTokenAuthenticator{
+		audiences: map[string]bool{},
+		certsURL:  GoogleCertsURL,
+		client:    http.DefaultClient,
+		now:       time.Now,
+	}
+	for _, audience := range audiences {
+		a.audiences[audience] = true
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a, nil
+}
+
+// idTokenHeader is the JOSE header of an ID token.
+type idTokenHeader struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+}
+
+// idTokenClaims are the claims of an ID token checked by IDTokenAuthenticator.
+type idTokenClaims struct {
+	Issuer        string   `json:"iss"`
+	Audience      audience `json:"aud"`
+	Subject       string   `json:"sub"`
+	Email         string   `json:"email"`
+	EmailVerified bool     `json:"email_verified"`
+	Expiry        int64    `json:"exp"`
+	IssuedAt      int64    `json:"iat"`
+}
+
+// audience is the aud claim, a string or an array of strings.
+type audience []string
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(a))
+}
+
+// Authenticate implements Authenticator.
+func (a *IDTokenAuthenticator) Authenticate(ctx context.Context, creds *Credentials) (*Identity, error) {
+	if creds.Token == "" {
+		return nil, nil
+	}
+	claims, err := a.verify(ctx, creds.Token)
+	if err != nil {
+		return nil, err
+	}
+	id := &Identity{Method: "idtoken"}
+	if claims.Email != "" && claims.EmailVerified {
+		id.Names = append(id.Names, claims.Email)
+	}
+	id.Names = append(id.Names, claims.Subject)
+	return id, nil
+}
+
+// verify returns the claims of token if it is a valid ID token for the audiences of a.
+func (a *IDTokenAuthenticator) verify(ctx context.Context, token string) (*idTokenClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed ID token")
+	}
+	header := &idTokenHeader{}
+	if err := decodeSegment(parts[0], header); err != nil {
+		return nil, errors.Wrapf(err, "malformed ID token header")
+	}
+	if header.Algorithm != "RS256" {
+		return nil, errors.Errorf("unsupported ID token algorithm %q", header.Algorithm)
+	}
+	key, err := a.key(ctx, header.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.Wrapf(err, "malformed ID token signature")
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+		return nil, errors.New("invalid ID token signature")
+	}
+
+	claims := &idTokenClaims{}
+	if err := decodeSegment(parts[1], claims); err != nil {
+		return nil, errors.Wrapf(err, "malformed ID token claims")
+	}
+	if !googleIssuers[claims.Issuer] {
+		return nil, errors.Errorf("ID token issued by %q, not Google", claims.Issuer)
+	}
+	audienceOK := false
+	for _, aud := range claims.Audience {
+		audienceOK = audienceOK || a.audiences[aud]
+	}
+	if !audienceOK {
+		return nil, errors.Errorf("ID token for audience %s", strings.Join(claims.Audience, ", "))
+	}
+	now := a.now()
+	if now.Add(-clockSkew).After(time.Unix(claims.Expiry, 0)) {
+		return nil, errors.New("expired ID token")
+	}
+	if now.Add(clockSkew).Before(time.Unix(claims.IssuedAt, 0)) {
+		return nil, errors.New("ID token issued in the future")
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("ID token without subject")
+	}
+	return claims, nil
+}
+
+// decodeSegment decodes the base64url encoded JSON segment of a token into v.
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// key returns the signing key with id, fetching the key set if the cached one expired or lacks
+// the key.
+func (a *IDTokenAuthenticator) key(ctx context.Context, id string) (*rsa.PublicKey, error) {
+	key, found, fetch := a.cachedKey(id)
+	if fetch {
+		var err error
+		if key, found, err = a.refreshKey(ctx, id); err != nil {
+			return nil, err
+		}
+	}
+	if !found {
+		return nil, errors.Errorf("ID token signed by unknown key %q", id)
+	}
+	return key, nil
+}
+
+// cachedKey returns the cached signing key with id, if found, and whether the key set must be
+// fetched before the key is used.
+func (a *IDTokenAuthenticator) cachedKey(id string) (*rsa.PublicKey, bool, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	now := a.now()
+	key, found := a.keys[id]
+	if found && now.Before(a.expires) {
+		return key, true, false
+	}
+	return key, found, !now.Before(a.expires) || now.Sub(a.fetched) >= minKeysRefresh
+}
+
+// refreshKey fetches the key set and returns the key with id from it. Callers that waited for
+// another fetch use the key set it fetched instead of fetching again.
+func (a *IDTokenAuthenticator) refreshKey(ctx context.Context, id string) (*rsa.PublicKey, bool, error) {
+	a.fetchMu.Lock()
+	defer a.fetchMu.Unlock()
+	key, found, fetch := a.cachedKey(id)
+	if !fetch {
+		return key, found, nil
+	}
+	if err := a.fetchKeys(ctx); err != nil {
+		return nil, false, err
+	}
+	key, found, _ = a.cachedKey(id)
+	return key, found, nil
+}
+
+// jsonWebKeySet is the response of the certs endpoint.
+type jsonWebKeySet struct {
+	Keys []struct {
+		KeyType  string `json:"kty"`
+		KeyID    string `json:"kid"`
+		Modulus  string `json:"n"`
+		Exponent string `json:"e"`
+	} `json:"keys"`
+}
+
+// fetchKeys replaces the cached key set, holding mu only to swap in the fetched keys.
+func (a *IDTokenAuthenticator) fetchKeys(ctx context.Context) error {
+	req, err := http.NewRequest(http.MethodGet, a.certsURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := a.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return errors.Wrapf(err, "failed to fetch ID token keys")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("failed to fetch ID token keys: %s", resp.Status)
+	}
+	set := &jsonWebKeySet{}
+	if err := json.NewDecoder(resp.Body).Decode(set); err != nil {
+		return errors.Wrapf(err, "failed to decode ID token keys")
+	}
+	keys := map[string]*rsa.PublicKey{}
+	for _, k := range set.Keys {
+		if k.KeyType != "RSA" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.Modulus)
+		if err != nil {
+			return errors.Wrapf(err, "invalid modulus of ID token key %s", k.KeyID)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.Exponent)
+		if err != nil {
+			return errors.Wrapf(err, "invalid exponent of ID token key %s", k.KeyID)
+		}
+		keys[k.KeyID] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	fetched := a.now()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.fetched = fetched
+	a.expires = fetched.Add(maxAge(resp.Header.Get("Cache-Control")))
+	a.keys = keys
+	return nil
+}
+
+// maxAge returns the max-age of a Cache-Control header, defaultKeysTTL if it has none.
+func maxAge(cacheControl string) time.Duration {
+	for _, directive := range strings.Split(cacheControl, ",") {
+		directive = strings.TrimSpace(directive)
+		if !strings.HasPrefix(directive, "max-age=") {
+			continue
+		}
+		if seconds, err := strconv.Atoi(strings.TrimPrefix(directive, "max-age=")); err == nil && seconds > 0 {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+	return defaultKeysTTL
+}
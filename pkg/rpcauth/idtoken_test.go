@@ -0,0 +1,252 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpcauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// signToken returns a token of claims signed by key with the key ID kid.
+func signToken(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]interface{}) string {
+	var segments []string
+	for _, v := range []interface{}{map[string]string{"alg": "RS256", "kid": kid}, claims} {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		segments = append(segments, base64.RawURLEncoding.EncodeToString(data))
+	}
+	digest := sha256.Sum256([]byte(strings.Join(segments, ".")))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return strings.Join(append(segments, base64.RawURLEncoding.EncodeToString(signature)), ".")
+}
+
+// serveKeys serves the public key of key with the key ID kid as JSON Web Key Set, and counts
+// the fetches.
+func serveKeys(t *testing.T, key *rsa.PrivateKey, kid string, fetches *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*fetches++
+		w.Header().Set("Cache-Control", "public, max-age=600")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": kid,
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	}))
+}
+
+func TestIDTokenAuthenticator(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var fetches int
+	server := serveKeys(t, key, "k1", &fetches)
+	defer server.Close()
+	a, err := NewIDTokenAuthenticator([]string{"https://validator.example.com"}, WithCertsURL(server.URL))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	now := time.Unix(1600000000, 0)
+	a.now = func() time.Time { return now }
+
+	claims := func(overrides map[string]interface{}) map[string]interface{} {
+		c := map[string]interface{}{
+			"iss":            "https://accounts.google.com",
+			"aud":            "https://validator.example.com",
+			"sub":            "1234",
+			"email":          "ci@project.iam.gserviceaccount.com",
+			"email_verified": true,
+			"iat":            now.Add(-time.Minute).Unix(),
+			"exp":            now.Add(time.Hour).Unix(),
+		}
+		for k, v := range overrides {
+			c[k] = v
+		}
+		return c
+	}
+	var testCases = []struct {
+		name    string
+		token   string
+		want    []string
+		wantErr string
+	}{
+		{
+			name:  "valid",
+			token: signToken(t, key, "k1", claims(nil)),
+			want:  []string{"ci@project.iam.gserviceaccount.com", "1234"},
+		},
+		{
+			name:  "audience list and unverified email",
+			token: signToken(t, key, "k1", claims(map[string]interface{}{"aud": []string{"other", "https://validator.example.com"}, "email_verified": false})),
+			want:  []string{"1234"},
+		},
+		{
+			name:    "other audience",
+			token:   signToken(t, key, "k1", claims(map[string]interface{}{"aud": "other"})),
+			wantErr: "audience other",
+		},
+		{
+			name:    "other issuer",
+			token:   signToken(t, key, "k1", claims(map[string]interface{}{"iss": "https://issuer.example.com"})),
+			wantErr: "not Google",
+		},
+		{
+			name:    "expired",
+			token:   signToken(t, key, "k1", claims(map[string]interface{}{"exp": now.Add(-time.Hour).Unix()})),
+			wantErr: "expired",
+		},
+		{
+			name:    "other key",
+			token:   signToken(t, other, "k1", claims(nil)),
+			wantErr: "invalid ID token signature",
+		},
+		{
+			name:    "unknown key",
+			token:   signToken(t, key, "k2", claims(nil)),
+			wantErr: "unknown key",
+		},
+		{
+			name:    "malformed",
+			token:   "not-a-token",
+			wantErr: "malformed",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			id, err := a.Authenticate(context.Background(), &Credentials{Token: tc.token})
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("got error %v, want %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, id.Names); diff != "" {
+				t.Errorf("unexpected names (-want +got):\n%s", diff)
+			}
+		})
+	}
+	// Keys are fetched once, unknown keys do not refetch them within a minute.
+	if fetches != 1 {
+		t.Errorf("got %d fetches of the keys, want 1", fetches)
+	}
+	now = now.Add(11 * time.Minute)
+	if _, err := a.Authenticate(context.Background(), &Credentials{Token: signToken(t, key, "k1", claims(nil))}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fetches != 2 {
+		t.Errorf("got %d fetches of the keys after they expired, want 2", fetches)
+	}
+
+	if id, err := a.Authenticate(context.Background(), &Credentials{}); id != nil || err != nil {
+		t.Errorf("got %v, %v without token, want neither", id, err)
+	}
+	if _, err := NewIDTokenAuthenticator(nil); err == nil {
+		t.Errorf("authenticator without audience succeeded, want error")
+	}
+}
+
+func TestIDTokenAuthenticatorFetchDoesNotBlock(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var fetches int
+	keys := serveKeys(t, key, "k1", &fetches)
+	defer keys.Close()
+	// Fetches after the first one hang until released.
+	started, release := make(chan struct{}), make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fetches != 0 {
+			close(started)
+			<-release
+		}
+		keys.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+	a, err := NewIDTokenAuthenticator([]string{"https://validator.example.com"}, WithCertsURL(server.URL))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	now := time.Unix(1600000000, 0)
+	a.now = func() time.Time { return now }
+	token := func(kid string) *Credentials {
+		return &Credentials{Token: signToken(t, key, kid, map[string]interface{}{
+			"iss": "https://accounts.google.com",
+			"aud": "https://validator.example.com",
+			"sub": "1234",
+			"iat": now.Add(-time.Minute).Unix(),
+			"exp": now.Add(time.Hour).Unix(),
+		})}
+	}
+	if _, err := a.Authenticate(context.Background(), token("k1")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// A token signed by an unknown key fetches the keys again, while tokens signed by cached keys
+	// are still verified.
+	now = now.Add(2 * minKeysRefresh)
+	unknown := token("k2")
+	done := make(chan error)
+	go func() {
+		_, err := a.Authenticate(context.Background(), unknown)
+		done <- err
+	}()
+	<-started
+	cached := make(chan error)
+	go func() {
+		_, err := a.Authenticate(context.Background(), token("k1"))
+		cached <- err
+	}()
+	select {
+	case err := <-cached:
+		if err != nil {
+			t.Errorf("unexpected error during fetch: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Errorf("token signed by a cached key waited for the fetch")
+	}
+	close(release)
+	if err := <-done; err == nil || !strings.Contains(err.Error(), "unknown key") {
+		t.Errorf("got error %v, want unknown key", err)
+	}
+}
@@ -0,0 +1,180 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpcauth
+
+import (
+	"io/ioutil"
+	"strings"
+
+	"github.com/forseti-security/config-validator/pkg/multierror"
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+)
+
+// servicePrefix is the prefix of the full method names of the validator service.
+const servicePrefix = "/validator.Validator/"
+
+// Method groups of authorization rules.
+const (
+	// ReviewMethods are the methods reviewing and auditing assets and reading the policies and
+	// capabilities of the server.
+	ReviewMethods = "review"
+	// AdminMethods are the methods changing the state of the server, reloading policies.
+	AdminMethods = "admin"
+)
+
+// methodGroups are the names of the methods of each method group.
+var methodGroups = map[string][]string{
+	ReviewMethods: {"AddData", "Audit", "Reset", "Review", "StreamReview", "Lint", "ListConstraints", "GetCapabilities"},
+	AdminMethods:  {"ReloadPolicies"},
+}
+
+// Config is the YAML encoded authorization policy, such as:
+//
+//	rules:
+//	- identities: ["*@ci-project.iam.gserviceaccount.com"]
+//	  methods: [review]
+//	- identities: ["spiffe://cluster.local/ns/ops/sa/admin"]
+//	  methods: [review, admin]
+type Config struct {
+	Rules []Rule `json:"rules"`
+}
+
+// Rule allows callers some methods.
+type Rule struct {
+	// Identities are the names of the callers the rule applies to. "*" matches any authenticated
+	// caller, and names starting with "*" match by suffix, such as "*@example.com".
+	Identities []string `json:"identities"`
+	// Methods are the methods the rule allows: the groups review and admin, method names such as
+	// Lint or /validator.Validator/Lint, or "*" for all.
+	Methods []string `json:"methods"`
+}
+
+// Policy authorizes callers to call methods, denying all calls no rule allows.
+type Policy struct {
+	rules []*rule
+}
+
+// rule is a compiled Rule.
+type rule struct {
+	identities []string
+	// methods are the allowed full method names, nil if all are allowed.
+	methods map[string]bool
+}
+
+// Load reads the authorization policy from the YAML file at path.
+func Load(path string) (*Policy, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read authorization policy %s", path)
+	}
+	p, err := Parse(data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid authorization policy %s", path)
+	}
+	return p, nil
+}
+
+// Parse parses the YAML encoded authorization policy in data.
+func Parse(data []byte) (*Policy, error) {
+	var config Config
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse authorization policy")
+	}
+	return New(config)
+}
+
+// New returns the policy of config. All invalid rules are reported together.
+func New(config Config) (*Policy, error) {
+	p := &Policy{}
+	var errs multierror.Errors
+	for idx, r := range config.Rules {
+		compiled, err := compileRule(r)
+		if err != nil {
+			errs.Add(errors.Wrapf(err, "rule %d", idx))
+			continue
+		}
+		p.rules = append(p.rules, compiled)
+	}
+	if err := errs.ToError(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func compileRule(r Rule) (*rule, error) {
+	if len(r.Identities) == 0 {
+		return nil, errors.New("no identities")
+	}
+	if len(r.Methods) == 0 {
+		return nil, errors.New("no methods")
+	}
+	compiled := &rule{identities: r.Identities, methods: map[string]bool{}}
+	for _, method := range r.Methods {
+		if method == "*" {
+			compiled.methods = nil
+			break
+		}
+		if group, found := methodGroups[method]; found {
+			for _, name := range group {
+				compiled.methods[servicePrefix+name] = true
+			}
+			continue
+		}
+		name := strings.TrimPrefix(method, servicePrefix)
+		if !knownMethod(name) {
+			return nil, errors.Errorf("unknown method %q, want review, admin or a method of the validator service", method)
+		}
+		compiled.methods[servicePrefix+name] = true
+	}
+	return compiled, nil
+}
+
+// knownMethod returns true if name is the name of a method of the validator service.
+func knownMethod(name string) bool {
+	for _, group := range methodGroups {
+		for _, method := range group {
+			if method == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Allows returns true if a rule allows the caller id to call the full method.
+func (p *Policy) Allows(id *Identity, method string) bool {
+	for _, r := range p.rules {
+		if (r.methods == nil || r.methods[method]) && r.matches(id) {
+			return true
+		}
+	}
+	return false
+}
+
+// matches returns true if any of the names of id matches the identities of r.
+func (r *rule) matches(id *Identity) bool {
+	for _, pattern := range r.identities {
+		if pattern == "*" {
+			return true
+		}
+		for _, name := range id.Names {
+			if pattern == name || (strings.HasPrefix(pattern, "*") && strings.HasSuffix(name, pattern[1:])) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpcauth
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testPolicy = `
+rules:
+- identities: ["*@ci.iam.gserviceaccount.com", "1234"]
+  methods: [review]
+- identities: ["spiffe://cluster.local/ns/ops/sa/admin"]
+  methods: [review, admin]
+- identities: ["*"]
+  methods: [ListConstraints]
+- identities: [root@example.com]
+  methods: ["*"]
+`
+
+func TestPolicy(t *testing.T) {
+	p, err := Parse([]byte(testPolicy))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ci := &Identity{Names: []string{"deploy@ci.iam.gserviceaccount.com"}}
+	admin := &Identity{Names: []string{"spiffe://cluster.local/ns/ops/sa/admin", "admin.ops.svc"}}
+	sub := &Identity{Names: []string{"5678@other.iam.gserviceaccount.com", "1234"}}
+	other := &Identity{Names: []string{"someone@example.com"}}
+	root := &Identity{Names: []string{"root@example.com"}}
+	var testCases = []struct {
+		id     *Identity
+		method string
+		want   bool
+	}{
+		{ci, "/validator.Validator/Review", true},
+		{ci, "/validator.Validator/StreamReview", true},
+		{ci, "/validator.Validator/ReloadPolicies", false},
+		{sub, "/validator.Validator/Audit", true},
+		{admin, "/validator.Validator/ReloadPolicies", true},
+		{other, "/validator.Validator/ListConstraints", true},
+		{other, "/validator.Validator/Review", false},
+		{root, "/validator.Validator/ReloadPolicies", true},
+		{root, "/grpc.health.v1.Health/Check", true},
+	}
+	for _, tc := range testCases {
+		if got := p.Allows(tc.id, tc.method); got != tc.want {
+			t.Errorf("Allows(%s, %s) = %v, want %v", tc.id, tc.method, got, tc.want)
+		}
+	}
+}
+
+func TestPolicyErrors(t *testing.T) {
+	for content, wantErr := range map[string]string{
+		"rules: [{identities: [a], methods: [Revew]}]": `unknown method "Revew"`,
+		"rules: [{methods: [review]}]":                 "no identities",
+		"rules: [{identities: [a]}]":                   "no methods",
+		"rules: {}":                                    "failed to parse",
+	} {
+		if _, err := Parse([]byte(content)); err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Errorf("Parse(%q) got error %v, want %q", content, err, wantErr)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rpcauth")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "policy.yaml")
+	if _, err := Load(path); err == nil {
+		t.Errorf("loading missing policy succeeded, want error")
+	}
+	if err := ioutil.WriteFile(path, []byte(testPolicy), 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	p, err := Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(p.rules) != 4 {
+		t.Errorf("got %d rules, want 4", len(p.rules))
+	}
+}
@@ -0,0 +1,212 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package rpcauth authenticates the callers of the validator server, by Google-signed ID tokens
+// or client certificates, and authorizes the RPCs they call according to a policy mapping
+// identities to allowed methods.
+package rpcauth
+
+import (
+	"context"
+	"crypto/x509"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Credentials are what the caller of an RPC presented.
+type Credentials struct {
+	// Token is the bearer token of the Authorization header, if any.
+	Token string
+	// VerifiedChains are the client certificate chains verified by the TLS handshake, if any.
+	VerifiedChains [][]*x509.Certificate
+}
+
+// CredentialsFromContext returns the credentials of the gRPC call of ctx.
+func CredentialsFromContext(ctx context.Context) *Credentials {
+	creds := &Credentials{}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for _, value := range md.Get("authorization") {
+			if token := bearerToken(value); token != "" {
+				creds.Token = token
+				break
+			}
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			creds.VerifiedChains = info.State.VerifiedChains
+		}
+	}
+	return creds
+}
+
+// CredentialsFromRequest returns the credentials of an HTTP request.
+func CredentialsFromRequest(r *http.Request) *Credentials {
+	creds := &Credentials{Token: bearerToken(r.Header.Get("Authorization"))}
+	if r.TLS != nil {
+		creds.VerifiedChains = r.TLS.VerifiedChains
+	}
+	return creds
+}
+
+// bearerToken returns the token of an Authorization header value of the Bearer scheme.
+func bearerToken(value string) string {
+	parts := strings.SplitN(value, " ", 2)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "bearer") {
+		return ""
+	}
+	return strings.TrimSpace(parts[1])
+}
+
+// Identity is an authenticated caller.
+type Identity struct {
+	// Names are the names the caller is known by, any of which authorization rules can match.
+	Names []string
+	// Method is how the caller was authenticated, such as "idtoken" or "mtls".
+	Method string
+}
+
+// String returns the first name of the identity.
+func (id *Identity) String() string {
+	if len(id.Names) == 0 {
+		return "anonymous " + id.Method + " caller"
+	}
+	return id.Names[0]
+}
+
+// Authenticator authenticates callers by one kind of credentials.
+type Authenticator interface {
+	// Authenticate returns the identity proven by creds, nil if creds lack the kind of
+	// credentials the authenticator checks, or an error if they are invalid.
+	Authenticate(ctx context.Context, creds *Credentials) (*Identity, error)
+}
+
+type identityKey struct{}
+
+// NewContext returns ctx carrying the identity of the caller.
+func NewContext(ctx context.Context, id *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// FromContext returns the identity of the caller of an RPC authorized by a Guard, nil if there
+// is none.
+func FromContext(ctx context.Context) *Identity {
+	id, _ := ctx.Value(identityKey{}).(*Identity)
+	return id
+}
+
+// Guard authenticates the callers of RPCs and authorizes their calls.
+type Guard struct {
+	authenticators []Authenticator
+	policy         *Policy
+}
+
+// NewGuard returns a Guard accepting callers proven by any of authenticators, tried in order, and
+// allowing them the methods policy grants. A nil policy allows authenticated callers all methods.
+func NewGuard(policy *Policy, authenticators ...Authenticator) *Guard {
+	return &Guard{authenticators: authenticators, policy: policy}
+}
+
+// Authorize returns the identity of the caller with creds if it may call the full gRPC method,
+// such as /validator.Validator/Review. Otherwise it returns an error with code Unauthenticated
+// or PermissionDenied.
+func (g *Guard) Authorize(ctx context.Context, method string, creds *Credentials) (*Identity, error) {
+	var firstErr error
+	var id *Identity
+	for _, a := range g.authenticators {
+		var err error
+		id, err = a.Authenticate(ctx, creds)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		if id != nil {
+			break
+		}
+	}
+	if id == nil {
+		if firstErr != nil {
+			return nil, status.Errorf(codes.Unauthenticated, "invalid credentials: %v", firstErr)
+		}
+		return nil, status.Error(codes.Unauthenticated, "missing credentials")
+	}
+	if g.policy != nil && !g.policy.Allows(id, method) {
+		return nil, status.Errorf(codes.PermissionDenied, "%s may not call %s", id, method)
+	}
+	return id, nil
+}
+
+// UnaryServerInterceptor returns the interceptor authorizing unary calls. Handlers get the
+// identity of the caller with FromContext.
+func (g *Guard) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		id, err := g.Authorize(ctx, info.FullMethod, CredentialsFromContext(ctx))
+		if err != nil {
+			return nil, err
+		}
+		return handler(NewContext(ctx, id), req)
+	}
+}
+
+// StreamServerInterceptor returns the interceptor authorizing streaming calls.
+func (g *Guard) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		id, err := g.Authorize(ss.Context(), info.FullMethod, CredentialsFromContext(ss.Context()))
+		if err != nil {
+			return err
+		}
+		return handler(srv, &identityStream{ServerStream: ss, ctx: NewContext(ss.Context(), id)})
+	}
+}
+
+// identityStream is a grpc.ServerStream whose context carries the identity of the caller.
+type identityStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context implements grpc.ServerStream.
+func (s *identityStream) Context() context.Context {
+	return s.ctx
+}
+
+// Handler returns the handler authorizing HTTP requests before passing them to next. method
+// returns the full gRPC method a request is served by, requests it returns empty for are
+// answered with 404.
+func (g *Guard) Handler(next http.Handler, method func(r *http.Request) string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rpc := method(r)
+		if rpc == "" {
+			http.NotFound(w, r)
+			return
+		}
+		id, err := g.Authorize(r.Context(), rpc, CredentialsFromRequest(r))
+		if err != nil {
+			code := http.StatusForbidden
+			if status.Code(err) == codes.Unauthenticated {
+				code = http.StatusUnauthorized
+				w.Header().Set("WWW-Authenticate", "Bearer")
+			}
+			http.Error(w, status.Convert(err).Message(), code)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), id)))
+	})
+}
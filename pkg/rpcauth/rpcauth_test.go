@@ -0,0 +1,208 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpcauth
+
+import (
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/forseti-security/config-validator/pkg/api/validator"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// tokenAuthenticator authenticates callers whose token is the name of their identity, and
+// rejects the token "invalid".
+type tokenAuthenticator struct{}
+
+func (tokenAuthenticator) Authenticate(ctx context.Context, creds *Credentials) (*Identity, error) {
+	switch creds.Token {
+	case "":
+		return nil, nil
+	case "invalid":
+		return nil, status.Error(codes.Unknown, "bad token")
+	}
+	return &Identity{Names: []string{creds.Token}, Method: "token"}, nil
+}
+
+// identityServer answers with violations whose message is the name of the caller.
+type identityServer struct {
+	validator.UnimplementedValidatorServer
+}
+
+func (s *identityServer) Review(ctx context.Context, request *validator.ReviewRequest) (*validator.ReviewResponse, error) {
+	return &validator.ReviewResponse{Violations: []*validator.Violation{{Message: FromContext(ctx).String()}}}, nil
+}
+
+func (s *identityServer) StreamReview(request *validator.ReviewRequest, stream validator.Validator_StreamReviewServer) error {
+	return stream.Send(&validator.ReviewResponse{Violations: []*validator.Violation{{Message: FromContext(stream.Context()).String()}}})
+}
+
+func (s *identityServer) ReloadPolicies(ctx context.Context, request *validator.ReloadPoliciesRequest) (*validator.ReloadPoliciesResponse, error) {
+	return &validator.ReloadPoliciesResponse{}, nil
+}
+
+func TestGuard(t *testing.T) {
+	policy, err := Parse([]byte(`
+rules:
+- identities: [reviewer, admin]
+  methods: [review]
+- identities: [admin]
+  methods: [admin]
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	guard := NewGuard(policy, tokenAuthenticator{})
+	server := grpc.NewServer(grpc.UnaryInterceptor(guard.UnaryServerInterceptor()), grpc.StreamInterceptor(guard.StreamServerInterceptor()))
+	validator.RegisterValidatorServer(server, &identityServer{})
+	listener := bufconn.Listen(1024 * 1024)
+	go server.Serve(listener)
+	defer server.Stop()
+	conn, err := grpc.Dial("bufnet", grpc.WithInsecure(), grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+		return listener.Dial()
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer conn.Close()
+	client := validator.NewValidatorClient(conn)
+
+	var testCases = []struct {
+		name     string
+		token    string
+		wantCode codes.Code
+	}{
+		{name: "no token", wantCode: codes.Unauthenticated},
+		{name: "invalid token", token: "invalid", wantCode: codes.Unauthenticated},
+		{name: "unknown caller", token: "other", wantCode: codes.PermissionDenied},
+		{name: "reviewer", token: "reviewer"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tc.token != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+tc.token)
+			}
+			response, err := client.Review(ctx, &validator.ReviewRequest{})
+			if status.Code(err) != tc.wantCode {
+				t.Fatalf("got error %v, want code %s", err, tc.wantCode)
+			}
+			if err == nil && response.Violations[0].Message != tc.token {
+				t.Errorf("handler saw caller %q, want %q", response.Violations[0].Message, tc.token)
+			}
+
+			stream, err := client.StreamReview(ctx, &validator.ReviewRequest{})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			response, err = stream.Recv()
+			if status.Code(err) != tc.wantCode {
+				t.Fatalf("got stream error %v, want code %s", err, tc.wantCode)
+			}
+			if err == nil && response.Violations[0].Message != tc.token {
+				t.Errorf("stream handler saw caller %q, want %q", response.Violations[0].Message, tc.token)
+			}
+		})
+	}
+
+	reload := func(token string) error {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+		_, err := client.ReloadPolicies(ctx, &validator.ReloadPoliciesRequest{})
+		return err
+	}
+	if err := reload("reviewer"); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("got error %v reloading as reviewer, want permission denied", err)
+	}
+	if err := reload("admin"); err != nil {
+		t.Errorf("unexpected error reloading as admin: %v", err)
+	}
+}
+
+func TestGuardHandler(t *testing.T) {
+	guard := NewGuard(nil, tokenAuthenticator{})
+	handler := guard.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(FromContext(r.Context()).String()))
+	}), func(r *http.Request) string {
+		if r.URL.Path == "/v1/review" {
+			return "/validator.Validator/Review"
+		}
+		return ""
+	})
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	var testCases = []struct {
+		path  string
+		token string
+		want  int
+	}{
+		{path: "/v1/review", want: http.StatusUnauthorized},
+		{path: "/v1/review", token: "invalid", want: http.StatusUnauthorized},
+		{path: "/v1/review", token: "anyone", want: http.StatusOK},
+		{path: "/v1/unknown", token: "anyone", want: http.StatusNotFound},
+	}
+	for _, tc := range testCases {
+		req, err := http.NewRequest(http.MethodPost, server.URL+tc.path, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if tc.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.want {
+			t.Errorf("%s with token %q: got status %d, want %d", tc.path, tc.token, resp.StatusCode, tc.want)
+		}
+	}
+}
+
+func TestCertificateAuthenticator(t *testing.T) {
+	uri, err := url.Parse("spiffe://cluster.local/ns/ops/sa/admin")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	leaf := &x509.Certificate{
+		Subject:        pkix.Name{CommonName: "admin"},
+		URIs:           []*url.URL{uri},
+		EmailAddresses: []string{"admin@example.com"},
+		DNSNames:       []string{"admin.ops.svc"},
+	}
+	id, err := NewCertificateAuthenticator().Authenticate(context.Background(), &Credentials{VerifiedChains: [][]*x509.Certificate{{leaf}}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := &Identity{Names: []string{uri.String(), "admin@example.com", "admin.ops.svc", "admin"}, Method: "mtls"}
+	if diff := cmp.Diff(want, id); diff != "" {
+		t.Errorf("unexpected identity (-want +got):\n%s", diff)
+	}
+	if id, err := NewCertificateAuthenticator().Authenticate(context.Background(), &Credentials{}); id != nil || err != nil {
+		t.Errorf("got %v, %v without certificates, want neither", id, err)
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package rpcconfig builds the gRPC options shared by the validator server and its Go clients,
+// the message size limits and the compression of messages on the wire.
+package rpcconfig
+
+import (
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+	// Registers the gzip compressor, which servers then use to answer gzip compressed requests.
+	_ "google.golang.org/grpc/encoding/gzip"
+)
+
+// DefaultMaxMessageSize is the message size limit of the server, well above the 4MB gRPC default
+// so that reviews of assets with large IAM policies fit.
+const DefaultMaxMessageSize = 128 * 1024 * 1024
+
+// Options describes the message limits and compression of a gRPC endpoint. Zero values keep the
+// gRPC defaults.
+type Options struct {
+	// MaxRecvSize is the size in bytes of the largest message received.
+	MaxRecvSize int
+	// MaxSendSize is the size in bytes of the largest message sent.
+	MaxSendSize int
+	// Compression is the name of the compressor for sent messages, e.g. "gzip". Servers always
+	// answer with the compression of the request, so it only applies to clients.
+	Compression string
+}
+
+// ServerOptions returns the grpc.ServerOptions applying opts.
+func ServerOptions(opts Options) ([]grpc.ServerOption, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	var serverOpts []grpc.ServerOption
+	if opts.MaxRecvSize != 0 {
+		serverOpts = append(serverOpts, grpc.MaxRecvMsgSize(opts.MaxRecvSize))
+	}
+	if opts.MaxSendSize != 0 {
+		serverOpts = append(serverOpts, grpc.MaxSendMsgSize(opts.MaxSendSize))
+	}
+	return serverOpts, nil
+}
+
+// DialOptions returns the grpc.DialOptions applying opts to all calls of a client connection.
+func DialOptions(opts Options) ([]grpc.DialOption, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	var callOpts []grpc.CallOption
+	if opts.MaxRecvSize != 0 {
+		callOpts = append(callOpts, grpc.MaxCallRecvMsgSize(opts.MaxRecvSize))
+	}
+	if opts.MaxSendSize != 0 {
+		callOpts = append(callOpts, grpc.MaxCallSendMsgSize(opts.MaxSendSize))
+	}
+	if opts.Compression != "" {
+		callOpts = append(callOpts, grpc.UseCompressor(opts.Compression))
+	}
+	if len(callOpts) == 0 {
+		return nil, nil
+	}
+	return []grpc.DialOption{grpc.WithDefaultCallOptions(callOpts...)}, nil
+}
+
+func (o Options) validate() error {
+	if o.MaxRecvSize < 0 || o.MaxSendSize < 0 {
+		return errors.Errorf("message size limits must not be negative, got receive %d and send %d", o.MaxRecvSize, o.MaxSendSize)
+	}
+	if o.Compression != "" && encoding.GetCompressor(o.Compression) == nil {
+		return errors.Errorf("unknown compression %q", o.Compression)
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpcconfig
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/forseti-security/config-validator/pkg/api/validator"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/stats"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// echoServer answers reviews with one violation whose message is the name of the first asset.
+type echoServer struct {
+	validator.UnimplementedValidatorServer
+}
+
+func (s *echoServer) Review(ctx context.Context, request *validator.ReviewRequest) (*validator.ReviewResponse, error) {
+	return &validator.ReviewResponse{Violations: []*validator.Violation{{Message: request.Assets[0].Name}}}, nil
+}
+
+// compressionRecorder records the compression of incoming requests.
+type compressionRecorder struct {
+	mu          sync.Mutex
+	compression string
+}
+
+func (r *compressionRecorder) TagRPC(ctx context.Context, info *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+func (r *compressionRecorder) HandleRPC(ctx context.Context, s stats.RPCStats) {
+	if header, ok := s.(*stats.InHeader); ok {
+		r.mu.Lock()
+		r.compression = header.Compression
+		r.mu.Unlock()
+	}
+}
+
+func (r *compressionRecorder) TagConn(ctx context.Context, info *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (r *compressionRecorder) HandleConn(ctx context.Context, s stats.ConnStats) {}
+
+// review sends a review of an asset named with size bytes from a client configured with
+// clientOpts to a server configured with serverOpts, and returns the compression of the request.
+func review(t *testing.T, serverOpts, clientOpts Options, size int) (string, error) {
+	opts, err := ServerOptions(serverOpts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	recorder := &compressionRecorder{}
+	server := grpc.NewServer(append(opts, grpc.StatsHandler(recorder))...)
+	validator.RegisterValidatorServer(server, &echoServer{})
+	listener := bufconn.Listen(1024 * 1024)
+	go server.Serve(listener)
+	defer server.Stop()
+
+	dialOpts, err := DialOptions(clientOpts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	dialOpts = append(dialOpts, grpc.WithInsecure(), grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+		return listener.Dial()
+	}))
+	conn, err := grpc.Dial("bufnet", dialOpts...)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer conn.Close()
+	name := strings.Repeat("a", size)
+	response, err := validator.NewValidatorClient(conn).Review(context.Background(), &validator.ReviewRequest{
+		Assets: []*validator.Asset{{Name: name}},
+	})
+	if err == nil && response.Violations[0].Message != name {
+		t.Errorf("unexpected response")
+	}
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	return recorder.compression, err
+}
+
+func TestMessageSizes(t *testing.T) {
+	const mb = 1024 * 1024
+	testCases := []struct {
+		name       string
+		serverOpts Options
+		clientOpts Options
+		size       int
+		wantErr    bool
+	}{
+		{name: "defaults", size: mb},
+		{name: "above server limit", serverOpts: Options{MaxRecvSize: mb}, size: 2 * mb, wantErr: true},
+		{name: "above server send limit", serverOpts: Options{MaxSendSize: mb}, size: 2 * mb, wantErr: true},
+		// The response echoing the asset is above the 4MB client default.
+		{name: "above client limit", serverOpts: Options{MaxRecvSize: 8 * mb}, size: 5 * mb, wantErr: true},
+		{
+			name:       "raised limits",
+			serverOpts: Options{MaxRecvSize: DefaultMaxMessageSize},
+			clientOpts: Options{MaxRecvSize: DefaultMaxMessageSize},
+			size:       5 * mb,
+		},
+		{name: "above client send limit", clientOpts: Options{MaxSendSize: mb}, size: 2 * mb, wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := review(t, tc.serverOpts, tc.clientOpts, tc.size)
+			if !tc.wantErr && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tc.wantErr && status.Code(err) != codes.ResourceExhausted {
+				t.Fatalf("got %v, want ResourceExhausted", err)
+			}
+		})
+	}
+}
+
+func TestCompression(t *testing.T) {
+	compression, err := review(t, Options{}, Options{Compression: "gzip"}, 1024)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if compression != "gzip" {
+		t.Errorf("got request compression %q, want gzip", compression)
+	}
+}
+
+func TestInvalidOptions(t *testing.T) {
+	for _, opts := range []Options{{Compression: "zstd"}, {MaxRecvSize: -1}} {
+		if _, err := ServerOptions(opts); err == nil {
+			t.Errorf("ServerOptions(%+v) got no error", opts)
+		}
+		if _, err := DialOptions(opts); err == nil {
+			t.Errorf("DialOptions(%+v) got no error", opts)
+		}
+	}
+}
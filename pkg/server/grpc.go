@@ -0,0 +1,78 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+
+	"github.com/forseti-security/config-validator/pkg/api/validator"
+	"google.golang.org/grpc"
+)
+
+// GRPCConfig configures the gRPC server built by NewGRPCServer.
+type GRPCConfig struct {
+	// ServerOptions are passed to grpc.NewServer, such as the options of rpcconfig.ServerOptions
+	// and transport credentials. They must not set interceptors, only one of which gRPC accepts.
+	ServerOptions []grpc.ServerOption
+	// UnaryInterceptors run in order around unary calls, the first outermost, such as the
+	// interceptor of an rpcauth.Guard followed by logging or quota interceptors.
+	UnaryInterceptors []grpc.UnaryServerInterceptor
+	// StreamInterceptors run in order around streaming calls, the first outermost.
+	StreamInterceptors []grpc.StreamServerInterceptor
+}
+
+// NewGRPCServer returns a gRPC server with the validator service of s registered, applying
+// config. Callers can register further services on it before serving. Interceptors do not apply
+// to the REST gateway, which calls the service in process.
+func (s *Server) NewGRPCServer(config GRPCConfig) *grpc.Server {
+	opts := append([]grpc.ServerOption{}, config.ServerOptions...)
+	if len(config.UnaryInterceptors) != 0 {
+		opts = append(opts, grpc.UnaryInterceptor(chainUnary(config.UnaryInterceptors)))
+	}
+	if len(config.StreamInterceptors) != 0 {
+		opts = append(opts, grpc.StreamInterceptor(chainStream(config.StreamInterceptors)))
+	}
+	grpcServer := grpc.NewServer(opts...)
+	validator.RegisterValidatorServer(grpcServer, s)
+	return grpcServer
+}
+
+// chainUnary returns the interceptor running interceptors in order.
+func chainUnary(interceptors []grpc.UnaryServerInterceptor) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		next := handler
+		for idx := len(interceptors) - 1; idx >= 0; idx-- {
+			interceptor, inner := interceptors[idx], next
+			next = func(ctx context.Context, req interface{}) (interface{}, error) {
+				return interceptor(ctx, req, info, inner)
+			}
+		}
+		return next(ctx, req)
+	}
+}
+
+// chainStream returns the interceptor running interceptors in order.
+func chainStream(interceptors []grpc.StreamServerInterceptor) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		next := handler
+		for idx := len(interceptors) - 1; idx >= 0; idx-- {
+			interceptor, inner := interceptors[idx], next
+			next = func(srv interface{}, ss grpc.ServerStream) error {
+				return interceptor(srv, ss, info, inner)
+			}
+		}
+		return next(srv, ss)
+	}
+}
@@ -0,0 +1,357 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package server implements the validator RPC service and builds the gRPC server serving it, for
+// the server binary and for programs embedding the service with their own interceptors and
+// services.
+package server
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+	"syscall"
+
+	"github.com/forseti-security/config-validator/pkg/api/validator"
+	"github.com/forseti-security/config-validator/pkg/gcv"
+	"github.com/forseti-security/config-validator/pkg/gcv/configs"
+	"github.com/forseti-security/config-validator/pkg/lint"
+	"github.com/forseti-security/config-validator/pkg/logging"
+	"github.com/forseti-security/config-validator/pkg/multierror"
+	"github.com/forseti-security/config-validator/pkg/notify"
+	"github.com/forseti-security/config-validator/pkg/ratelimit"
+	"github.com/forseti-security/config-validator/pkg/rpcauth"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Server implements the validator RPC service, reviewing against policy sets that are reloaded
+// from their sources on request.
+type Server struct {
+	validator *gcv.ParallelValidator
+	// policies are the policy sets by name, where policyPath is the set with the empty name.
+	policies map[string]*gcv.Reloader
+	// policyLibraryPath is the policy library reloaded with the policies.
+	policyLibraryPath string
+	mu                sync.RWMutex
+	// libs are the policy library files Lint compiles templates with.
+	libs []configs.File
+	// notifier, if set, is told about the violations found by Review.
+	notifier notify.Notifier
+	// notifications tracks the notifications sent in the background.
+	notifications sync.WaitGroup
+	// limiter, if set, caps the reviews of each client.
+	limiter *ratelimit.Limiter
+	// validatorOpts are applied to the validators of the policy sets.
+	validatorOpts []gcv.Option
+	// flags are the features configured for the deployment, reported by GetCapabilities.
+	flags map[string]bool
+}
+
+func (s *Server) AddData(ctx context.Context, request *validator.AddDataRequest) (*validator.AddDataResponse, error) {
+	return &validator.AddDataResponse{}, status.Error(codes.Internal, "Not supported")
+}
+
+func (s *Server) Audit(ctx context.Context, request *validator.AuditRequest) (*validator.AuditResponse, error) {
+	return &validator.AuditResponse{}, status.Error(codes.Internal, "Not supported")
+}
+
+func (s *Server) Reset(ctx context.Context, request *validator.ResetRequest) (*validator.ResetResponse, error) {
+	return &validator.ResetResponse{}, status.Error(codes.Internal, "Not supported")
+}
+
+func (s *Server) Review(ctx context.Context, request *validator.ReviewRequest) (*validator.ReviewResponse, error) {
+	policies, err := s.policySet(request.PolicySet)
+	if err != nil {
+		return nil, err
+	}
+	release, err := s.admit(ctx, len(request.Assets))
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	ctx = logging.WithRunID(ctx, logging.NewRunID())
+	logging.FromContext(ctx).Debug("reviewing assets", zap.Int("assets", len(request.Assets)), zap.String("policy_set", request.PolicySet))
+	response, err := s.validator.ReviewWith(ctx, policies, request)
+	if err == nil && s.notifier != nil && len(response.Violations) != 0 {
+		// Notify in the background so slow webhooks do not delay the response.
+		s.notifications.Add(1)
+		go s.notify(logging.NewContext(context.Background(), logging.FromContext(ctx)), response.Violations)
+	}
+	return response, err
+}
+
+// defaultStreamBatchSize is the number of violations sent in each StreamReview response if the
+// request does not set a batch size.
+const defaultStreamBatchSize = 500
+
+func (s *Server) StreamReview(request *validator.ReviewRequest, stream validator.Validator_StreamReviewServer) error {
+	policies, err := s.policySet(request.PolicySet)
+	if err != nil {
+		return err
+	}
+	release, err := s.admit(stream.Context(), len(request.Assets))
+	if err != nil {
+		return err
+	}
+	defer release()
+	ctx := logging.WithRunID(stream.Context(), logging.NewRunID())
+	logging.FromContext(ctx).Debug("streaming review of assets", zap.Int("assets", len(request.Assets)), zap.String("policy_set", request.PolicySet))
+	batchSize := int(request.BatchSize)
+	if batchSize <= 0 {
+		batchSize = defaultStreamBatchSize
+	}
+	var found []*validator.Violation
+	err = s.validator.StreamReviewWith(ctx, policies, request, batchSize, func(violations []*validator.Violation) error {
+		if s.notifier != nil {
+			found = append(found, violations...)
+		}
+		return stream.Send(&validator.ReviewResponse{Violations: violations})
+	})
+	if err == nil && len(found) != 0 {
+		s.notifications.Add(1)
+		go s.notify(logging.NewContext(context.Background(), logging.FromContext(ctx)), found)
+	}
+	return err
+}
+
+// admit admits a review of assets by the client of ctx within the limits of the server, and
+// returns the function to call once the review is done.
+func (s *Server) admit(ctx context.Context, assets int) (func(), error) {
+	if s.limiter == nil {
+		return func() {}, nil
+	}
+	var name string
+	if id := rpcauth.FromContext(ctx); id != nil {
+		name = id.String()
+	}
+	key := ratelimit.ClientKey(ctx, name)
+	release, err := s.limiter.Acquire(key, assets)
+	if err != nil {
+		logging.FromContext(ctx).Debug("rejected review", zap.String("client", key), zap.Int("assets", assets), zap.Error(err))
+	}
+	return release, err
+}
+
+func (s *Server) notify(ctx context.Context, violations []*validator.Violation) {
+	defer s.notifications.Done()
+	if err := s.notifier.Notify(ctx, violations); err != nil {
+		logging.FromContext(ctx).Error("failed to notify violations", zap.Int("violations", len(violations)), zap.Error(err))
+	}
+}
+
+func (s *Server) Lint(ctx context.Context, request *validator.LintRequest) (*validator.LintResponse, error) {
+	var files []configs.File
+	for _, file := range request.Files {
+		files = append(files, configs.File{Path: file.Path, Content: []byte(file.Content)})
+	}
+	response := &validator.LintResponse{}
+	s.mu.RLock()
+	libs := s.libs
+	s.mu.RUnlock()
+	for _, d := range lint.Lint(files, libs) {
+		severity := validator.Diagnostic_ERROR
+		if d.Severity == lint.Warning {
+			severity = validator.Diagnostic_WARNING
+		}
+		response.Diagnostics = append(response.Diagnostics, &validator.Diagnostic{
+			Path:     d.Path,
+			Line:     int32(d.Line),
+			Severity: severity,
+			Code:     d.Code,
+			Message:  d.Message,
+		})
+	}
+	return response, nil
+}
+
+func (s *Server) ListConstraints(ctx context.Context, request *validator.ListConstraintsRequest) (*validator.ListConstraintsResponse, error) {
+	policies, err := s.policySet(request.PolicySet)
+	if err != nil {
+		return nil, err
+	}
+	cv := policies.Validator()
+	response := &validator.ListConstraintsResponse{PolicyVersion: cv.PolicyVersion()}
+	for _, constraint := range cv.Constraints() {
+		info, err := gcv.NewConstraintInfo(constraint)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "%v", err)
+		}
+		response.Constraints = append(response.Constraints, info)
+	}
+	return response, nil
+}
+
+func (s *Server) ReloadPolicies(ctx context.Context, request *validator.ReloadPoliciesRequest) (*validator.ReloadPoliciesResponse, error) {
+	policies, err := s.policySet(request.PolicySet)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Reload(ctx, request.PolicySet); err != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "failed to reload policies, keeping the current policy set: %v", err)
+	}
+	cv := policies.Validator()
+	return &validator.ReloadPoliciesResponse{PolicyVersion: cv.PolicyVersion(), Constraints: int32(len(cv.Constraints()))}, nil
+}
+
+func (s *Server) GetCapabilities(ctx context.Context, request *validator.GetCapabilitiesRequest) (*validator.GetCapabilitiesResponse, error) {
+	c := gcv.NewCapabilities()
+	response := &validator.GetCapabilitiesResponse{
+		ValidatorVersion: c.ValidatorVersion,
+		ProtoVersion:     c.ProtoVersion,
+		Methods:          c.Methods,
+		OpaVersion:       c.OPAVersion,
+		Targets:          c.Targets,
+		InputFormats:     c.InputFormats,
+		Features:         c.Features,
+		Flags:            s.flags,
+	}
+	for name := range s.policies {
+		if name != "" {
+			response.PolicySets = append(response.PolicySets, name)
+		}
+	}
+	sort.Strings(response.PolicySets)
+	return response, nil
+}
+
+// policySet returns the policy set with the given name.
+func (s *Server) policySet(name string) (*gcv.Reloader, error) {
+	policies, found := s.policies[name]
+	if !found {
+		return nil, status.Errorf(codes.NotFound, "unknown policy set %q", name)
+	}
+	return policies, nil
+}
+
+// Reload loads the policy library and the named policy sets again. Sets that fail to load keep
+// their current policies, and unknown names are reported as NotFound errors.
+func (s *Server) Reload(ctx context.Context, names ...string) error {
+	libs, err := lint.ReadLibs(ctx, s.policyLibraryPath)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.libs = libs
+	s.mu.Unlock()
+	var errs multierror.Errors
+	for _, name := range names {
+		policies, err := s.policySet(name)
+		if err != nil {
+			errs.Add(err)
+			continue
+		}
+		if _, err := policies.Reload(ctx); err != nil {
+			errs.Add(errors.Wrapf(err, "policy set %q", name))
+		}
+	}
+	return errs.ToError()
+}
+
+// ReloadOnHangup reloads all policy sets whenever the process receives SIGHUP. It does not
+// return.
+func (s *Server) ReloadOnHangup() {
+	var names []string
+	for name := range s.policies {
+		names = append(names, name)
+	}
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	for range hangups {
+		zap.L().Info("reloading policies on SIGHUP")
+		if err := s.Reload(context.Background(), names...); err != nil {
+			zap.L().Error("failed to reload policies, keeping the current policy sets that failed", zap.Error(err))
+		}
+	}
+}
+
+// Option configures optional Server behavior.
+type Option func(*Server)
+
+// WithValidatorOptions applies opts to the validators of all policy sets.
+func WithValidatorOptions(opts ...gcv.Option) Option {
+	return func(s *Server) {
+		s.validatorOpts = append(s.validatorOpts, opts...)
+	}
+}
+
+// WithNotifier tells notifier about the violations found by reviews, in the background.
+func WithNotifier(notifier notify.Notifier) Option {
+	return func(s *Server) {
+		s.notifier = notifier
+	}
+}
+
+// WithLimiter caps the reviews of each client with limiter. Clients are told apart by their
+// rpcauth identity, or the address of their peer.
+func WithLimiter(limiter *ratelimit.Limiter) Option {
+	return func(s *Server) {
+		s.limiter = limiter
+	}
+}
+
+// WithFlags reports flags, whether features configured for the deployment are enabled by name,
+// in GetCapabilities responses.
+func WithFlags(flags map[string]bool) Option {
+	return func(s *Server) {
+		s.flags = flags
+	}
+}
+
+// New returns a server for the policy sets, given as paths by name, with the library at
+// policyLibraryPath. The set with the empty name is the default of requests without a policy set,
+// and must be given. Reviews stop when stopChannel is closed.
+func New(stopChannel <-chan struct{}, policySets map[string][]string, policyLibraryPath string, opts ...Option) (*Server, error) {
+	if _, found := policySets[""]; !found {
+		return nil, errors.New("missing the default policy set")
+	}
+	s := &Server{policyLibraryPath: policyLibraryPath, policies: map[string]*gcv.Reloader{}}
+	for _, opt := range opts {
+		opt(s)
+	}
+	for name, paths := range policySets {
+		reloader, err := gcv.NewPolicyReloader(paths, policyLibraryPath, s.validatorOpts...)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to load policy set %q", name)
+		}
+		s.policies[name] = reloader
+	}
+	libs, err := lint.ReadLibs(context.Background(), policyLibraryPath)
+	if err != nil {
+		return nil, err
+	}
+	s.libs = libs
+	s.validator = gcv.NewParallelValidator(stopChannel, s.policies[""])
+	return s, nil
+}
+
+// PolicySet returns the policy set with name, false if there is none.
+func (s *Server) PolicySet(name string) (*gcv.Reloader, bool) {
+	policies, found := s.policies[name]
+	return policies, found
+}
+
+// MemoryStats returns the memory held by the reviews of the server.
+func (s *Server) MemoryStats() gcv.MemoryStats {
+	return s.validator.MemoryStats()
+}
+
+// WaitNotifications waits for the notifications sent in the background to complete.
+func (s *Server) WaitNotifications() {
+	s.notifications.Wait()
+}
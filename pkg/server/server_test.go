@@ -0,0 +1,198 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/forseti-security/config-validator/pkg/api/validator"
+	"github.com/forseti-security/config-validator/pkg/ratelimit"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const (
+	testPolicies = "../../test/cf"
+	testLibs     = "../../test/cf/library"
+)
+
+// newTestServer returns a server for the test policies, which runs until stop is closed.
+func newTestServer(t *testing.T, stop <-chan struct{}, opts ...Option) *Server {
+	s, err := New(stop, map[string][]string{"": {testPolicies}}, testLibs, opts...)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return s
+}
+
+// dial serves grpcServer on an in-memory listener and returns a connection to it, and a function
+// closing the connection and stopping grpcServer.
+func dial(t *testing.T, grpcServer *grpc.Server) (*grpc.ClientConn, func()) {
+	listener := bufconn.Listen(1024 * 1024)
+	go grpcServer.Serve(listener)
+	conn, err := grpc.Dial("bufnet", grpc.WithInsecure(), grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+		return listener.Dial()
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return conn, func() {
+		conn.Close()
+		grpcServer.Stop()
+	}
+}
+
+func TestNewGRPCServer(t *testing.T) {
+	var calls []string
+	unary := func(name string) grpc.UnaryServerInterceptor {
+		return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			calls = append(calls, name+" "+info.FullMethod)
+			return handler(ctx, req)
+		}
+	}
+	stream := func(name string) grpc.StreamServerInterceptor {
+		return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			calls = append(calls, name+" "+info.FullMethod)
+			return handler(srv, ss)
+		}
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	grpcServer := newTestServer(t, stop).NewGRPCServer(GRPCConfig{
+		UnaryInterceptors:  []grpc.UnaryServerInterceptor{unary("auth"), unary("log")},
+		StreamInterceptors: []grpc.StreamServerInterceptor{stream("auth"), stream("log")},
+	})
+	// Embedders register their own services next to the validator service.
+	healthpb.RegisterHealthServer(grpcServer, health.NewServer())
+	conn, cleanup := dial(t, grpcServer)
+	defer cleanup()
+
+	ctx := context.Background()
+	response, err := validator.NewValidatorClient(conn).ListConstraints(ctx, &validator.ListConstraintsRequest{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(response.Constraints) == 0 {
+		t.Errorf("got no constraints")
+	}
+	reviews, err := validator.NewValidatorClient(conn).StreamReview(ctx, &validator.ReviewRequest{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for err == nil {
+		_, err = reviews.Recv()
+	}
+	if _, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{
+		"auth /validator.Validator/ListConstraints",
+		"log /validator.Validator/ListConstraints",
+		"auth /validator.Validator/StreamReview",
+		"log /validator.Validator/StreamReview",
+		"auth /grpc.health.v1.Health/Check",
+		"log /grpc.health.v1.Health/Check",
+	}
+	if diff := cmp.Diff(want, calls); diff != "" {
+		t.Errorf("unexpected interceptor calls (-want +got):\n%s", diff)
+	}
+}
+
+func TestServerLimits(t *testing.T) {
+	stop := make(chan struct{})
+	defer close(stop)
+	s := newTestServer(t, stop, WithLimiter(ratelimit.New(ratelimit.Options{AssetsPerSecond: 1})))
+	conn, cleanup := dial(t, s.NewGRPCServer(GRPCConfig{}))
+	defer cleanup()
+	client := validator.NewValidatorClient(conn)
+	request := &validator.ReviewRequest{Assets: []*validator.Asset{bucket(t, "a"), bucket(t, "b")}}
+	if _, err := client.Review(context.Background(), request); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := client.Review(context.Background(), request); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("got error %v, want resource exhausted", err)
+	}
+}
+
+// bucket returns a storage bucket asset named name.
+func bucket(t *testing.T, name string) *validator.Asset {
+	asset := &validator.Asset{}
+	err := jsonpb.UnmarshalString(`{
+  "name": "//storage.googleapis.com/`+name+`",
+  "asset_type": "storage.googleapis.com/Bucket",
+  "ancestry_path": "organizations/1/projects/2",
+  "resource": {"data": {"name": "`+name+`"}}
+}`, asset)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return asset
+}
+
+func TestServerPolicySets(t *testing.T) {
+	stop := make(chan struct{})
+	defer close(stop)
+	s := newTestServer(t, stop)
+	if _, found := s.PolicySet(""); !found {
+		t.Errorf("default policy set not found")
+	}
+	_, err := s.ReloadPolicies(context.Background(), &validator.ReloadPoliciesRequest{PolicySet: "other"})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("got error %v reloading unknown set, want not found", err)
+	}
+	response, err := s.ReloadPolicies(context.Background(), &validator.ReloadPoliciesRequest{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if response.Constraints == 0 || response.PolicyVersion == "" {
+		t.Errorf("unexpected response %v", response)
+	}
+	if err := s.Reload(context.Background(), "", "other"); err == nil || !strings.Contains(err.Error(), `unknown policy set "other"`) {
+		t.Errorf("got error %v reloading unknown set, want unknown policy set", err)
+	}
+
+	if _, err := New(stop, map[string][]string{"sales": {testPolicies}}, testLibs); err == nil {
+		t.Errorf("server without default policy set succeeded, want error")
+	}
+}
+
+func TestGetCapabilities(t *testing.T) {
+	stop := make(chan struct{})
+	defer close(stop)
+	s, err := New(stop, map[string][]string{"": {testPolicies}, "sales": {testPolicies}}, testLibs,
+		WithFlags(map[string]bool{"resolve-ancestry": true}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	response, err := s.GetCapabilities(context.Background(), &validator.GetCapabilitiesRequest{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if diff := cmp.Diff([]string{"sales"}, response.PolicySets); diff != "" {
+		t.Errorf("unexpected policy sets (-want +got):\n%s", diff)
+	}
+	if !response.Flags["resolve-ancestry"] || len(response.Targets) == 0 || response.ProtoVersion == "" {
+		t.Errorf("unexpected response %v", response)
+	}
+}
@@ -0,0 +1,296 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package shard splits reviews of large CAI exports across workers. Assets are assigned to one
+// of N shards by the hash of their name, so every worker agrees on the shard of an asset without
+// coordination, and all content types of an asset land in the same shard. A coordinator can
+// Split an export into one file per shard, or each worker can read the whole export through a
+// Reader that skips the assets of other shards. The reports and summaries of the shards are
+// combined with MergeReports and MergeSummaries.
+package shard
+
+import (
+	"context"
+	"encoding/json"
+	"hash/fnv"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/forseti-security/config-validator/pkg/asset"
+	"github.com/forseti-security/config-validator/pkg/gcv"
+	"github.com/forseti-security/config-validator/pkg/hooks"
+	"github.com/forseti-security/config-validator/pkg/logging"
+	"github.com/forseti-security/config-validator/pkg/multierror"
+	"github.com/forseti-security/config-validator/pkg/report"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// Spec identifies shard Index, counting from 0, of Count shards. The zero Spec is a single shard
+// holding every asset.
+type Spec struct {
+	Index int
+	Count int
+}
+
+// Parse parses a shard given as "index/count", such as "0/8".
+func Parse(s string) (Spec, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) != 2 {
+		return Spec{}, errors.Errorf("invalid shard %q, want index/count", s)
+	}
+	index, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return Spec{}, errors.Errorf("invalid shard index in %q", s)
+	}
+	count, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return Spec{}, errors.Errorf("invalid shard count in %q", s)
+	}
+	spec := Spec{Index: index, Count: count}
+	if err := spec.validate(); err != nil {
+		return Spec{}, err
+	}
+	return spec, nil
+}
+
+// String returns the shard in "index/count" format.
+func (s Spec) String() string {
+	return strconv.Itoa(s.Index) + "/" + strconv.Itoa(s.Count)
+}
+
+func (s Spec) validate() error {
+	if s.Count < 0 || s.Index < 0 || (s.Count > 0 && s.Index >= s.Count) || (s.Count == 0 && s.Index != 0) {
+		return errors.Errorf("invalid shard %s, want an index from 0 to count-1", s)
+	}
+	return nil
+}
+
+// Contains returns true if the asset belongs to the shard.
+func (s Spec) Contains(asset map[string]interface{}) bool {
+	return s.Count <= 1 || Of(asset, s.Count) == s.Index
+}
+
+// Of returns the shard of asset among count shards, from 0 to count-1.
+func Of(asset map[string]interface{}, count int) int {
+	if count <= 1 {
+		return 0
+	}
+	name, _ := asset["name"].(string)
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	return int(h.Sum32() % uint32(count))
+}
+
+// Split reads the export in r and writes each record to the writer of its shard, the shards
+// being the len(w) writers. Records are written in the newline delimited JSON export format, in
+// the order they were read, whatever the format of the export. Records that fail to decode are not
+// written, they are returned together after the rest of the export was split. It returns the
+// number of records written to each writer.
+func Split(r io.Reader, file string, w []io.Writer) ([]int, error) {
+	if len(w) == 0 {
+		return nil, errors.Errorf("no shards to split %s into", file)
+	}
+	counts := make([]int, len(w))
+	encoders := make([]*json.Encoder, len(w))
+	for idx := range w {
+		encoders[idx] = json.NewEncoder(w[idx])
+	}
+	var errs multierror.Errors
+	reader := asset.NewReader(r, file)
+	for {
+		record, err := reader.Next()
+		if err == io.EOF {
+			return counts, errs.ToError()
+		}
+		if decodeErr, ok := err.(*asset.DecodeError); ok {
+			errs.Add(decodeErr)
+			continue
+		}
+		if err != nil {
+			return counts, err
+		}
+		shard := Of(record.Asset, len(w))
+		if err := encoders[shard].Encode(record.Asset); err != nil {
+			return counts, errors.Wrapf(err, "failed to write shard %d", shard)
+		}
+		counts[shard]++
+	}
+}
+
+// Reader reads the records of one shard of an export.
+type Reader struct {
+	reader *asset.Reader
+	spec   Spec
+}
+
+// NewReader returns a Reader for the records of the shard in reader. Records that fail to decode
+// have no name to assign them a shard, they are returned by shard 0 only so that every such
+// record is counted once across the shards.
+func NewReader(reader *asset.Reader, spec Spec) *Reader {
+	return &Reader{reader: reader, spec: spec}
+}
+
+// Next returns the next record of the shard, with the semantics of asset.Reader.Next.
+func (r *Reader) Next() (*asset.Record, error) {
+	for {
+		record, err := r.reader.Next()
+		if _, ok := err.(*asset.DecodeError); ok && r.spec.Index != 0 {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if r.spec.Contains(record.Asset) {
+			return record, nil
+		}
+	}
+}
+
+// Review reviews the records of the shard read by r with v, as a worker does. The summary is that
+// of run runID, which the workers of a run share. Records that fail to decode or review are logged
+// and counted as errors in the report and summary.
+func Review(ctx context.Context, v *gcv.Validator, r *Reader, runID string) (*report.Report, *hooks.Summary, error) {
+	rep := report.New(v)
+	summary := hooks.NewSummary(runID, v.PolicyVersion())
+	for {
+		record, err := r.Next()
+		if err == io.EOF {
+			summary.EndTime = time.Now()
+			return rep, summary, nil
+		}
+		if decodeErr, ok := err.(*asset.DecodeError); ok {
+			logging.FromContext(ctx).Error("failed to decode asset", zap.Stringer("source", decodeErr.Source), zap.Error(decodeErr.Err))
+			rep.AddError()
+			summary.AddError()
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		result, err := v.ReviewRecord(ctx, record)
+		if err != nil {
+			name, _ := record.Asset["name"].(string)
+			logging.FromContext(ctx).Error("failed to review asset",
+				zap.String(logging.AssetKey, name), zap.Stringer("source", record.Source), zap.Error(err))
+			rep.AddError()
+			summary.AddError()
+			continue
+		}
+		rep.Add(result)
+		summary.Add(result)
+	}
+}
+
+// MergeReports combines the reports of the shards of a review into the report of the whole
+// review. The violations of the first report come first. All reports must come from the same
+// policies.
+func MergeReports(reports ...*report.Report) (*report.Report, error) {
+	if len(reports) == 0 {
+		return nil, errors.Errorf("no reports to merge")
+	}
+	merged := &report.Report{PolicyVersion: reports[0].PolicyVersion, Violations: []*report.Violation{}}
+	constraints := map[string]bool{}
+	for idx, r := range reports {
+		if r.PolicyVersion != merged.PolicyVersion {
+			return nil, errors.Errorf("report %d has policy version %s, want %s", idx, r.PolicyVersion, merged.PolicyVersion)
+		}
+		for _, constraint := range r.Constraints {
+			if !constraints[constraint] {
+				constraints[constraint] = true
+				merged.Constraints = append(merged.Constraints, constraint)
+			}
+		}
+		merged.Assets += r.Assets
+		merged.Errors += r.Errors
+		merged.Duplicates += r.Duplicates
+		merged.Violations = append(merged.Violations, r.Violations...)
+		merged.Manifest = mergeManifests(merged.Manifest, r.Manifest)
+		for assetType, count := range r.SkippedAssets {
+			if merged.SkippedAssets == nil {
+				merged.SkippedAssets = map[string]int{}
+			}
+			merged.SkippedAssets[assetType] += count
+		}
+	}
+	sort.Strings(merged.Constraints)
+	return merged, nil
+}
+
+// mergeManifests returns the manifest of a run combining the run of merged, nil if none, with the
+// run of m. The combined run starts with the earliest and ends with the latest, and keeps the run
+// ID of the first.
+func mergeManifests(merged, m *report.Manifest) *report.Manifest {
+	if m == nil {
+		return merged
+	}
+	if merged == nil {
+		copied := *m
+		copied.Inputs = append([]string{}, m.Inputs...)
+		return &copied
+	}
+	for _, input := range m.Inputs {
+		found := false
+		for _, prev := range merged.Inputs {
+			found = found || prev == input
+		}
+		if !found {
+			merged.Inputs = append(merged.Inputs, input)
+		}
+	}
+	if m.StartTime.Before(merged.StartTime) {
+		merged.StartTime = m.StartTime
+	}
+	if m.EndTime.After(merged.EndTime) {
+		merged.EndTime = m.EndTime
+	}
+	merged.DurationSeconds = merged.EndTime.Sub(merged.StartTime).Seconds()
+	return merged
+}
+
+// MergeSummaries combines the summaries of the shards of a review run into the summary of the
+// whole run, which starts with the earliest shard and ends with the latest. The run ID is that of
+// the first summary. All summaries must come from the same policies.
+func MergeSummaries(summaries ...*hooks.Summary) (*hooks.Summary, error) {
+	if len(summaries) == 0 {
+		return nil, errors.Errorf("no summaries to merge")
+	}
+	first := summaries[0]
+	merged := hooks.NewSummary(first.RunID, first.PolicyVersion)
+	merged.StartTime, merged.EndTime = first.StartTime, first.EndTime
+	for idx, s := range summaries {
+		if s.PolicyVersion != merged.PolicyVersion {
+			return nil, errors.Errorf("summary %d has policy version %s, want %s", idx, s.PolicyVersion, merged.PolicyVersion)
+		}
+		if s.StartTime.Before(merged.StartTime) {
+			merged.StartTime = s.StartTime
+		}
+		if s.EndTime.After(merged.EndTime) {
+			merged.EndTime = s.EndTime
+		}
+		merged.Assets += s.Assets
+		merged.Errors += s.Errors
+		merged.Violations += s.Violations
+		for severity, count := range s.BySeverity {
+			merged.BySeverity[severity] += count
+		}
+		for constraint, count := range s.ByConstraint {
+			merged.ByConstraint[constraint] += count
+		}
+	}
+	return merged, nil
+}
@@ -0,0 +1,226 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shard
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/forseti-security/config-validator/pkg/asset"
+	"github.com/forseti-security/config-validator/pkg/gcv"
+	"github.com/forseti-security/config-validator/pkg/hooks"
+	"github.com/forseti-security/config-validator/pkg/report"
+	"github.com/google/go-cmp/cmp"
+)
+
+const (
+	testPolicies = "../../test/cf"
+	testLibs     = "../../test/cf/library"
+)
+
+// testExport returns an export of buckets bucket assets holding both a resource and an IAM policy
+// record, followed by a record that fails to decode.
+func testExport(buckets int) string {
+	var export strings.Builder
+	for i := 0; i < buckets; i++ {
+		name := fmt.Sprintf("//storage.googleapis.com/bucket-%d", i)
+		fmt.Fprintf(&export, `{"name":%q,"asset_type":"storage.googleapis.com/Bucket","ancestry_path":"organization/1/project/2","resource":{"data":{"name":"bucket-%d"}}}`+"\n", name, i)
+		fmt.Fprintf(&export, `{"name":%q,"asset_type":"storage.googleapis.com/Bucket","ancestry_path":"organization/1/project/2","iam_policy":{}}`+"\n", name)
+	}
+	export.WriteString("{not json\n")
+	return export.String()
+}
+
+func TestParse(t *testing.T) {
+	for _, tc := range []struct {
+		in      string
+		want    Spec
+		wantErr bool
+	}{
+		{in: "0/8", want: Spec{Index: 0, Count: 8}},
+		{in: "7/8", want: Spec{Index: 7, Count: 8}},
+		{in: "8/8", wantErr: true},
+		{in: "-1/8", wantErr: true},
+		{in: "1", wantErr: true},
+		{in: "a/8", wantErr: true},
+	} {
+		got, err := Parse(tc.in)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("Parse(%q) got error %v, want error %v", tc.in, err, tc.wantErr)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("Parse(%q) = %v, want %v", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	writers := make([]io.Writer, 4)
+	buffers := make([]*bytes.Buffer, len(writers))
+	for idx := range writers {
+		buffers[idx] = &bytes.Buffer{}
+		writers[idx] = buffers[idx]
+	}
+	counts, err := Split(strings.NewReader(testExport(50)), "export.json", writers)
+	// The record that failed to decode is returned once the export is split.
+	if err == nil {
+		t.Error("expected decode error")
+	}
+	total := 0
+	for idx, buf := range buffers {
+		reader := asset.NewReader(buf, "")
+		n := 0
+		for {
+			record, err := reader.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("unexpected error in shard %d: %v", idx, err)
+			}
+			if shard := Of(record.Asset, len(writers)); shard != idx {
+				t.Errorf("record of %s in shard %d, want %d", record.Asset["name"], idx, shard)
+			}
+			n++
+		}
+		if n != counts[idx] {
+			t.Errorf("got %d records in shard %d, counted %d", n, idx, counts[idx])
+		}
+		if n == 0 {
+			t.Errorf("shard %d is empty", idx)
+		}
+		total += n
+	}
+	if total != 100 {
+		t.Errorf("got %d records in all shards, want 100", total)
+	}
+}
+
+func TestReader(t *testing.T) {
+	const count = 3
+	export := testExport(20)
+	names := map[string]int{}
+	decodeErrors := 0
+	for idx := 0; idx < count; idx++ {
+		reader := NewReader(asset.NewReader(strings.NewReader(export), ""), Spec{Index: idx, Count: count})
+		for {
+			record, err := reader.Next()
+			if err == io.EOF {
+				break
+			}
+			if _, ok := err.(*asset.DecodeError); ok {
+				decodeErrors++
+				continue
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			names[record.Asset["name"].(string)]++
+		}
+	}
+	// Every record is read by exactly one shard.
+	if len(names) != 20 || decodeErrors != 1 {
+		t.Errorf("got %d assets and %d decode errors, want 20 and 1", len(names), decodeErrors)
+	}
+	for name, n := range names {
+		if n != 2 {
+			t.Errorf("got %d records of %s, want 2", n, name)
+		}
+	}
+}
+
+func TestReviewAndMerge(t *testing.T) {
+	ctx := context.Background()
+	v, err := gcv.NewValidator(gcv.WithPolicyPaths(testPolicies), gcv.WithPolicyLibrary(testLibs))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	export := testExport(10)
+	full, fullSummary, err := Review(ctx, v, NewReader(asset.NewReader(strings.NewReader(export), ""), Spec{}), "run")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var reports []*report.Report
+	var summaries []*hooks.Summary
+	for idx := 0; idx < 4; idx++ {
+		r, s, err := Review(ctx, v, NewReader(asset.NewReader(strings.NewReader(export), ""), Spec{Index: idx, Count: 4}), "run")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		reports = append(reports, r)
+		summaries = append(summaries, s)
+	}
+	merged, err := MergeReports(reports...)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if merged.Assets != full.Assets || merged.Errors != full.Errors || len(merged.Violations) != len(full.Violations) {
+		t.Errorf("merged report has %d assets, %d errors and %d violations, want %d, %d and %d",
+			merged.Assets, merged.Errors, len(merged.Violations), full.Assets, full.Errors, len(full.Violations))
+	}
+	if diff := cmp.Diff(full.Constraints, merged.Constraints); diff != "" {
+		t.Errorf("unexpected constraints (-want +got):\n%s", diff)
+	}
+
+	mergedSummary, err := MergeSummaries(summaries...)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	fullSummary.StartTime, fullSummary.EndTime = time.Time{}, time.Time{}
+	mergedSummary.StartTime, mergedSummary.EndTime = time.Time{}, time.Time{}
+	if diff := cmp.Diff(fullSummary, mergedSummary); diff != "" {
+		t.Errorf("unexpected summary (-want +got):\n%s", diff)
+	}
+
+	reports[1].PolicyVersion = "other"
+	if _, err := MergeReports(reports...); err == nil {
+		t.Error("expected error merging reports of other policies")
+	}
+}
+
+func TestMergeManifests(t *testing.T) {
+	start := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	first := &report.Manifest{RunID: "run", Inputs: []string{"a.json"}, StartTime: start.Add(time.Second), EndTime: start.Add(2 * time.Second)}
+	second := &report.Manifest{RunID: "other", Inputs: []string{"a.json", "b.json"}, StartTime: start, EndTime: start.Add(time.Second)}
+	merged, err := MergeReports(
+		&report.Report{PolicyVersion: "v1", Manifest: first},
+		&report.Report{PolicyVersion: "v1"},
+		&report.Report{PolicyVersion: "v1", Manifest: second},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// The run spans the shards, the run ID is that of the first.
+	want := &report.Manifest{
+		RunID:           "run",
+		Inputs:          []string{"a.json", "b.json"},
+		StartTime:       start,
+		EndTime:         start.Add(2 * time.Second),
+		DurationSeconds: 2,
+	}
+	if diff := cmp.Diff(want, merged.Manifest); diff != "" {
+		t.Errorf("unexpected manifest (-want +got):\n%s", diff)
+	}
+	if len(first.Inputs) != 1 || !first.StartTime.Equal(start.Add(time.Second)) {
+		t.Errorf("manifest of the first report was modified: %+v", first)
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package signing
+
+import (
+	"context"
+	"crypto"
+	"encoding/base64"
+
+	"github.com/pkg/errors"
+	cloudkms "google.golang.org/api/cloudkms/v1"
+)
+
+// KMSPrefix marks Cloud KMS key versions in key flags, as in
+// gcpkms://projects/p/locations/l/keyRings/r/cryptoKeys/k/cryptoKeyVersions/1.
+const KMSPrefix = "gcpkms://"
+
+// kmsAlgorithms maps the asymmetric signing algorithms of Cloud KMS to the Algorithm of their
+// signatures.
+var kmsAlgorithms = map[string]Algorithm{
+	"EC_SIGN_P256_SHA256":        ECDSASHA256,
+	"EC_SIGN_P384_SHA384":        ECDSASHA384,
+	"RSA_SIGN_PKCS1_2048_SHA256": RSAPKCS1SHA256,
+	"RSA_SIGN_PKCS1_3072_SHA256": RSAPKCS1SHA256,
+	"RSA_SIGN_PKCS1_4096_SHA256": RSAPKCS1SHA256,
+	"RSA_SIGN_PKCS1_4096_SHA512": RSAPKCS1SHA512,
+	"RSA_SIGN_PSS_2048_SHA256":   RSAPSSSHA256,
+	"RSA_SIGN_PSS_3072_SHA256":   RSAPSSSHA256,
+	"RSA_SIGN_PSS_4096_SHA256":   RSAPSSSHA256,
+	"RSA_SIGN_PSS_4096_SHA512":   RSAPSSSHA512,
+}
+
+// kmsSigner signs with an asymmetric Cloud KMS key version. The private key never leaves KMS.
+type kmsSigner struct {
+	service   *cloudkms.Service
+	name      string
+	algorithm Algorithm
+}
+
+var _ Signer = &kmsSigner{}
+
+// NewKMSSigner returns a Signer signing with the Cloud KMS key version with the resource name
+// name, which must have an asymmetric signing purpose. The key ID is the resource name. The
+// service requires the cloudkms.cryptoKeyVersions.useToSign and viewPublicKey permissions.
+func NewKMSSigner(ctx context.Context, service *cloudkms.Service, name string) (Signer, error) {
+	key, err := getKMSPublicKey(ctx, service, name)
+	if err != nil {
+		return nil, err
+	}
+	algorithm, ok := kmsAlgorithms[key.Algorithm]
+	if !ok {
+		return nil, errors.Errorf("key %s has algorithm %s, want an asymmetric signing key", name, key.Algorithm)
+	}
+	return &kmsSigner{service: service, name: name, algorithm: algorithm}, nil
+}
+
+// KeyID implements Signer.
+func (s *kmsSigner) KeyID() string {
+	return s.name
+}
+
+// Algorithm implements Signer.
+func (s *kmsSigner) Algorithm() Algorithm {
+	return s.algorithm
+}
+
+// SignDigest implements Signer.
+func (s *kmsSigner) SignDigest(ctx context.Context, digest []byte) ([]byte, error) {
+	encoded := base64.StdEncoding.EncodeToString(digest)
+	request := &cloudkms.AsymmetricSignRequest{Digest: &cloudkms.Digest{}}
+	switch hashes[s.algorithm] {
+	case crypto.SHA256:
+		request.Digest.Sha256 = encoded
+	case crypto.SHA384:
+		request.Digest.Sha384 = encoded
+	case crypto.SHA512:
+		request.Digest.Sha512 = encoded
+	}
+	resp, err := s.service.Projects.Locations.KeyRings.CryptoKeys.CryptoKeyVersions.AsymmetricSign(s.name, request).Context(ctx).Do()
+	if err != nil {
+		return nil, err
+	}
+	signature, err := base64.StdEncoding.DecodeString(resp.Signature)
+	return signature, errors.Wrapf(err, "invalid signature returned by KMS")
+}
+
+// KMSPublicKey returns the public key of the Cloud KMS key version with the resource name name,
+// to verify its signatures with.
+func KMSPublicKey(ctx context.Context, service *cloudkms.Service, name string) (crypto.PublicKey, error) {
+	key, err := getKMSPublicKey(ctx, service, name)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePublicKey([]byte(key.Pem))
+}
+
+func getKMSPublicKey(ctx context.Context, service *cloudkms.Service, name string) (*cloudkms.PublicKey, error) {
+	key, err := service.Projects.Locations.KeyRings.CryptoKeys.CryptoKeyVersions.GetPublicKey(name).Context(ctx).Do()
+	return key, errors.Wrapf(err, "failed to get public key of %s", name)
+}
@@ -0,0 +1,282 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package signing signs review outputs and verifies their signatures, so that compliance evidence
+// produced by the validator is tamper-evident. Outputs are signed with a local private key or an
+// asymmetric Cloud KMS key, and the detached signature is written next to them:
+//
+//	{
+//	  "algorithm": "ECDSA_SHA256",
+//	  "key_id": "projects/p/locations/global/keyRings/r/cryptoKeys/k/cryptoKeyVersions/1",
+//	  "digest": "sha256:5b3a02e5...",
+//	  "signature": "MEUCIQ..."
+//	}
+//
+// Anyone holding the public key can verify that the output is unchanged since it was signed.
+package signing
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Algorithm is a signature algorithm.
+type Algorithm string
+
+// Supported signature algorithms. ECDSA signatures are ASN.1 encoded.
+const (
+	ECDSASHA256    Algorithm = "ECDSA_SHA256"
+	ECDSASHA384    Algorithm = "ECDSA_SHA384"
+	RSAPKCS1SHA256 Algorithm = "RSA_PKCS1_SHA256"
+	RSAPKCS1SHA512 Algorithm = "RSA_PKCS1_SHA512"
+	RSAPSSSHA256   Algorithm = "RSA_PSS_SHA256"
+	RSAPSSSHA512   Algorithm = "RSA_PSS_SHA512"
+)
+
+// hashes are the digests signed by each algorithm.
+var hashes = map[Algorithm]crypto.Hash{
+	ECDSASHA256:    crypto.SHA256,
+	ECDSASHA384:    crypto.SHA384,
+	RSAPKCS1SHA256: crypto.SHA256,
+	RSAPKCS1SHA512: crypto.SHA512,
+	RSAPSSSHA256:   crypto.SHA256,
+	RSAPSSSHA512:   crypto.SHA512,
+}
+
+// Signer signs digests with a private key.
+type Signer interface {
+	// KeyID identifies the key, such as the resource name of a Cloud KMS key version.
+	KeyID() string
+	// Algorithm returns the algorithm of the signatures.
+	Algorithm() Algorithm
+	// SignDigest signs a digest computed with the hash of the algorithm.
+	SignDigest(ctx context.Context, digest []byte) ([]byte, error)
+}
+
+// Signature is the detached signature of a review output.
+type Signature struct {
+	Algorithm Algorithm `json:"algorithm"`
+	KeyID     string    `json:"key_id"`
+	// Digest is the SHA-256 digest of the signed content as "sha256:" followed by its hex
+	// encoding, which identifies the content regardless of the algorithm.
+	Digest    string `json:"digest"`
+	Signature []byte `json:"signature"`
+}
+
+// Sign returns the signature of content by signer.
+func Sign(ctx context.Context, signer Signer, content []byte) (*Signature, error) {
+	algorithm := signer.Algorithm()
+	hash, ok := hashes[algorithm]
+	if !ok {
+		return nil, errors.Errorf("unsupported signature algorithm %q", algorithm)
+	}
+	signature, err := signer.SignDigest(ctx, digest(hash, content))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to sign with %s", signer.KeyID())
+	}
+	return &Signature{Algorithm: algorithm, KeyID: signer.KeyID(), Digest: contentDigest(content), Signature: signature}, nil
+}
+
+// Verify returns an error unless s is a signature of content by the private key of key.
+func Verify(content []byte, s *Signature, key crypto.PublicKey) error {
+	hash, ok := hashes[s.Algorithm]
+	if !ok {
+		return errors.Errorf("unsupported signature algorithm %q", s.Algorithm)
+	}
+	if got := contentDigest(content); s.Digest != got {
+		return errors.Errorf("content has digest %s, signature was made for %s", got, s.Digest)
+	}
+	d := digest(hash, content)
+	var valid bool
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		if !strings.HasPrefix(string(s.Algorithm), "ECDSA_") {
+			return errors.Errorf("cannot verify %s signature with ECDSA key", s.Algorithm)
+		}
+		var sig struct{ R, S *big.Int }
+		if rest, err := asn1.Unmarshal(s.Signature, &sig); err != nil || len(rest) != 0 {
+			return errors.New("invalid ECDSA signature encoding")
+		}
+		valid = ecdsa.Verify(k, d, sig.R, sig.S)
+	case *rsa.PublicKey:
+		var err error
+		switch {
+		case strings.HasPrefix(string(s.Algorithm), "RSA_PKCS1_"):
+			err = rsa.VerifyPKCS1v15(k, hash, d, s.Signature)
+		case strings.HasPrefix(string(s.Algorithm), "RSA_PSS_"):
+			err = rsa.VerifyPSS(k, hash, d, s.Signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthAuto})
+		default:
+			return errors.Errorf("cannot verify %s signature with RSA key", s.Algorithm)
+		}
+		valid = err == nil
+	default:
+		return errors.Errorf("unsupported public key type %T", key)
+	}
+	if !valid {
+		return errors.Errorf("invalid signature by %s", s.KeyID)
+	}
+	return nil
+}
+
+// ReadSignature reads a JSON encoded signature.
+func ReadSignature(r io.Reader) (*Signature, error) {
+	s := &Signature{}
+	if err := json.NewDecoder(r).Decode(s); err != nil {
+		return nil, errors.Wrapf(err, "failed to decode signature")
+	}
+	return s, nil
+}
+
+// Write writes the signature as JSON.
+func (s *Signature) Write(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return errors.Wrapf(encoder.Encode(s), "failed to encode signature")
+}
+
+func digest(hash crypto.Hash, content []byte) []byte {
+	h := hash.New()
+	_, _ = h.Write(content)
+	return h.Sum(nil)
+}
+
+func contentDigest(content []byte) string {
+	d := sha256.Sum256(content)
+	return "sha256:" + hex.EncodeToString(d[:])
+}
+
+// localSigner signs with a private key held in memory.
+type localSigner struct {
+	key       crypto.Signer
+	keyID     string
+	algorithm Algorithm
+}
+
+var _ Signer = &localSigner{}
+
+// NewLocalSigner returns a Signer signing with key, an ECDSA P-256 or P-384 or an RSA private
+// key. RSA keys sign with PKCS #1 v1.5 and SHA-256. The key ID is the fingerprint of the public
+// key, see KeyFingerprint.
+func NewLocalSigner(key crypto.Signer) (Signer, error) {
+	s := &localSigner{key: key}
+	switch k := key.Public().(type) {
+	case *ecdsa.PublicKey:
+		switch k.Curve {
+		case elliptic.P256():
+			s.algorithm = ECDSASHA256
+		case elliptic.P384():
+			s.algorithm = ECDSASHA384
+		default:
+			return nil, errors.Errorf("unsupported ECDSA curve %s", k.Curve.Params().Name)
+		}
+	case *rsa.PublicKey:
+		s.algorithm = RSAPKCS1SHA256
+	default:
+		return nil, errors.Errorf("unsupported private key type %T", key)
+	}
+	keyID, err := KeyFingerprint(key.Public())
+	if err != nil {
+		return nil, err
+	}
+	s.keyID = keyID
+	return s, nil
+}
+
+// KeyID implements Signer.
+func (s *localSigner) KeyID() string {
+	return s.keyID
+}
+
+// Algorithm implements Signer.
+func (s *localSigner) Algorithm() Algorithm {
+	return s.algorithm
+}
+
+// SignDigest implements Signer.
+func (s *localSigner) SignDigest(ctx context.Context, digest []byte) ([]byte, error) {
+	return s.key.Sign(rand.Reader, digest, hashes[s.algorithm])
+}
+
+// KeyFingerprint returns "sha256:" followed by the hex encoded SHA-256 digest of the DER encoded
+// public key.
+func KeyFingerprint(key crypto.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return "", errors.Wrapf(err, "invalid public key")
+	}
+	return contentDigest(der), nil
+}
+
+// ParsePrivateKey parses a PEM encoded PKCS #8, SEC 1 EC or PKCS #1 RSA private key, as written
+// by openssl genpkey, openssl ecparam -genkey and openssl genrsa.
+func ParsePrivateKey(content []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, errors.New("no PEM encoded key found")
+	}
+	var key interface{}
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		return nil, errors.Errorf("unsupported PEM block %q, want a private key", block.Type)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid private key")
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.Errorf("unsupported private key type %T", key)
+	}
+	return signer, nil
+}
+
+// ParsePublicKey parses a PEM encoded public key, as written by openssl pkey -pubout and
+// returned by Cloud KMS. The public key of a private key accepted by ParsePrivateKey is returned
+// as well.
+func ParsePublicKey(content []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, errors.New("no PEM encoded key found")
+	}
+	if block.Type != "PUBLIC KEY" {
+		key, err := ParsePrivateKey(content)
+		if err != nil {
+			return nil, err
+		}
+		return key.Public(), nil
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	return key, errors.Wrapf(err, "invalid public key")
+}
@@ -0,0 +1,238 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package signing
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	cloudkms "google.golang.org/api/cloudkms/v1"
+)
+
+var testContent = []byte(`{"policy_version": "v1", "violations": []}`)
+
+func TestSignAndVerify(t *testing.T) {
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		name      string
+		key       crypto.Signer
+		algorithm Algorithm
+	}{
+		{name: "P-256", key: p256, algorithm: ECDSASHA256},
+		{name: "P-384", key: p384, algorithm: ECDSASHA384},
+		{name: "RSA", key: rsaKey, algorithm: RSAPKCS1SHA256},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			signer, err := NewLocalSigner(tc.key)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			s, err := Sign(context.Background(), signer, testContent)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if s.Algorithm != tc.algorithm || !strings.HasPrefix(s.KeyID, "sha256:") {
+				t.Errorf("got algorithm %s and key ID %s, want %s and a key fingerprint", s.Algorithm, s.KeyID, tc.algorithm)
+			}
+
+			// The signature survives encoding.
+			var buf bytes.Buffer
+			if err := s.Write(&buf); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			read, err := ReadSignature(&buf)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(s, read); diff != "" {
+				t.Errorf("unexpected signature (-want +got):\n%s", diff)
+			}
+			if err := Verify(testContent, read, tc.key.Public()); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+
+			tampered := bytes.Replace(testContent, []byte("v1"), []byte("v2"), 1)
+			if err := Verify(tampered, read, tc.key.Public()); err == nil || !strings.Contains(err.Error(), "digest") {
+				t.Errorf("got error %v, want digest mismatch", err)
+			}
+			forged := *read
+			forged.Digest = contentDigest(tampered)
+			if err := Verify(tampered, &forged, tc.key.Public()); err == nil || !strings.Contains(err.Error(), "invalid signature") {
+				t.Errorf("got error %v, want invalid signature", err)
+			}
+		})
+	}
+
+	s, err := Sign(context.Background(), mustLocalSigner(t, p256), testContent)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(testContent, s, other.Public()); err == nil {
+		t.Error("expected error verifying with another key")
+	}
+	if err := Verify(testContent, s, rsaKey.Public()); err == nil || !strings.Contains(err.Error(), "with RSA key") {
+		t.Errorf("got error %v, want algorithm mismatch", err)
+	}
+}
+
+func mustLocalSigner(t *testing.T, key crypto.Signer) Signer {
+	signer, err := NewLocalSigner(key)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return signer
+}
+
+func TestParseKeys(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sec1, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkix, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := KeyFingerprint(key.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, block := range []*pem.Block{
+		{Type: "PRIVATE KEY", Bytes: pkcs8},
+		{Type: "EC PRIVATE KEY", Bytes: sec1},
+		{Type: "PUBLIC KEY", Bytes: pkix},
+	} {
+		public, err := ParsePublicKey(pem.EncodeToMemory(block))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", block.Type, err)
+		}
+		if got, _ := KeyFingerprint(public); got != want {
+			t.Errorf("%s: got key %s, want %s", block.Type, got, want)
+		}
+	}
+	if _, err := ParsePrivateKey(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pkix})); err == nil {
+		t.Error("expected error parsing public key as private key")
+	}
+	if _, err := ParsePublicKey([]byte("not a key")); err == nil {
+		t.Error("expected error parsing invalid key")
+	}
+}
+
+func TestKMSSigner(t *testing.T) {
+	const name = "projects/p/locations/global/keyRings/r/cryptoKeys/k/cryptoKeyVersions/1"
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkix, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/" + name + "/publicKey":
+			_ = json.NewEncoder(w).Encode(&cloudkms.PublicKey{
+				Algorithm: "EC_SIGN_P256_SHA256",
+				Pem:       string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pkix})),
+			})
+		case "/v1/" + name + ":asymmetricSign":
+			request := &cloudkms.AsymmetricSignRequest{}
+			if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			digest, err := base64.StdEncoding.DecodeString(request.Digest.Sha256)
+			if err != nil || len(digest) != sha256.Size {
+				http.Error(w, "invalid digest", http.StatusBadRequest)
+				return
+			}
+			signature, err := key.Sign(rand.Reader, digest, crypto.SHA256)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(&cloudkms.AsymmetricSignResponse{Signature: base64.StdEncoding.EncodeToString(signature)})
+		default:
+			http.Error(w, `{"error": {"code": 404, "message": "not found"}}`, http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	service, err := cloudkms.New(server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.BasePath = server.URL + "/"
+	ctx := context.Background()
+
+	signer, err := NewKMSSigner(ctx, service, name)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s, err := Sign(ctx, signer, testContent)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if s.KeyID != name || s.Algorithm != ECDSASHA256 {
+		t.Errorf("got key ID %s and algorithm %s", s.KeyID, s.Algorithm)
+	}
+	public, err := KMSPublicKey(ctx, service, name)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := Verify(testContent, s, public); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := NewKMSSigner(ctx, service, "projects/p/locations/global/keyRings/r/cryptoKeys/other/cryptoKeyVersions/1"); err == nil {
+		t.Error("expected error for unknown key")
+	}
+}
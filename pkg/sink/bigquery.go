@@ -0,0 +1,214 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sink
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/forseti-security/config-validator/pkg/gcv"
+	"github.com/forseti-security/config-validator/pkg/multierror"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/pkg/errors"
+	bigquery "google.golang.org/api/bigquery/v2"
+	"google.golang.org/api/googleapi"
+)
+
+// maxInsertRows is the number of rows sent per streaming insert request.
+const maxInsertRows = 500
+
+// BigQueryTableSchema is the schema of the violations table. Columns are only ever added to it,
+// so queries written against it keep working across releases.
+var BigQueryTableSchema = &bigquery.TableSchema{
+	Fields: []*bigquery.TableFieldSchema{
+		{Name: "run_id", Type: "STRING", Mode: "REQUIRED", Description: "Identifier of the review run"},
+		{Name: "timestamp", Type: "TIMESTAMP", Mode: "REQUIRED", Description: "Start time of the review run"},
+		{Name: "constraint", Type: "STRING", Mode: "REQUIRED", Description: "Constraint in Kind.name format"},
+		{Name: "resource", Type: "STRING", Mode: "REQUIRED", Description: "Full resource name of the violating asset"},
+		{Name: "ancestry", Type: "STRING", Description: "Ancestry path of the violating asset"},
+		{Name: "severity", Type: "STRING", Description: "Severity of the constraint"},
+		{Name: "message", Type: "STRING", Description: "Human readable violation message"},
+		{Name: "metadata", Type: "STRING", Description: "JSON encoded violation metadata"},
+		{Name: "policy_version", Type: "STRING", Description: "Version of the policy set the violation was found with"},
+	},
+}
+
+// BigQueryOptions identifies the table violations are written to.
+type BigQueryOptions struct {
+	Project string
+	Dataset string
+	Table   string
+	// RunID identifies the review run in the run_id column, defaults to the run time.
+	RunID string
+	// RunTime is stored in the timestamp column, defaults to the time the sink is created.
+	RunTime time.Time
+}
+
+// BigQuery streams violations into a BigQuery table, creating the table on first use.
+type BigQuery struct {
+	service      *bigquery.Service
+	opts         BigQueryOptions
+	tableChecked bool
+}
+
+var _ Sink = &BigQuery{}
+
+// NewBigQuery returns a sink writing to the table described by opts using service.
+func NewBigQuery(service *bigquery.Service, opts BigQueryOptions) (*BigQuery, error) {
+	if opts.Project == "" || opts.Dataset == "" || opts.Table == "" {
+		return nil, errors.Errorf("project, dataset and table must be set")
+	}
+	if opts.RunTime.IsZero() {
+		opts.RunTime = time.Now()
+	}
+	if opts.RunID == "" {
+		opts.RunID = opts.RunTime.UTC().Format(time.RFC3339Nano)
+	}
+	return &BigQuery{service: service, opts: opts}, nil
+}
+
+// ensureTable creates the violations table, partitioned by timestamp, if it does not exist. An
+// existing table is extended with columns added to BigQueryTableSchema since it was created.
+func (b *BigQuery) ensureTable(ctx context.Context) error {
+	if b.tableChecked {
+		return nil
+	}
+	existing, err := b.service.Tables.Get(b.opts.Project, b.opts.Dataset, b.opts.Table).Context(ctx).Do()
+	if err == nil {
+		err = b.extendSchema(ctx, existing)
+	}
+	if isHTTPStatus(err, http.StatusNotFound) {
+		table := &bigquery.Table{
+			TableReference: &bigquery.TableReference{
+				ProjectId: b.opts.Project,
+				DatasetId: b.opts.Dataset,
+				TableId:   b.opts.Table,
+			},
+			Schema:           BigQueryTableSchema,
+			TimePartitioning: &bigquery.TimePartitioning{Type: "DAY", Field: "timestamp"},
+		}
+		_, err = b.service.Tables.Insert(b.opts.Project, b.opts.Dataset, table).Context(ctx).Do()
+		if isHTTPStatus(err, http.StatusConflict) {
+			// Created concurrently by another writer.
+			err = nil
+		}
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to get or create table %s:%s.%s", b.opts.Project, b.opts.Dataset, b.opts.Table)
+	}
+	b.tableChecked = true
+	return nil
+}
+
+// extendSchema adds the columns of BigQueryTableSchema missing from table.
+func (b *BigQuery) extendSchema(ctx context.Context, table *bigquery.Table) error {
+	schema := &bigquery.TableSchema{}
+	if table.Schema != nil {
+		schema.Fields = append(schema.Fields, table.Schema.Fields...)
+	}
+	present := map[string]bool{}
+	for _, field := range schema.Fields {
+		present[field.Name] = true
+	}
+	for _, field := range BigQueryTableSchema.Fields {
+		if !present[field.Name] {
+			// Columns added to an existing table cannot be required.
+			added := *field
+			added.Mode = "NULLABLE"
+			schema.Fields = append(schema.Fields, &added)
+		}
+	}
+	if len(schema.Fields) == len(present) {
+		return nil
+	}
+	_, err := b.service.Tables.Patch(b.opts.Project, b.opts.Dataset, b.opts.Table, &bigquery.Table{Schema: schema}).Context(ctx).Do()
+	return err
+}
+
+// Write streams one row per violation. Rows carry an insert ID derived from the run and the
+// violation, so retried writes do not produce duplicates.
+func (b *BigQuery) Write(ctx context.Context, results []*gcv.Result) error {
+	rows, err := b.rows(results)
+	if err != nil {
+		return err
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	if err := b.ensureTable(ctx); err != nil {
+		return err
+	}
+
+	for start := 0; start < len(rows); start += maxInsertRows {
+		end := start + maxInsertRows
+		if end > len(rows) {
+			end = len(rows)
+		}
+		request := &bigquery.TableDataInsertAllRequest{Rows: rows[start:end]}
+		response, err := b.service.Tabledata.InsertAll(b.opts.Project, b.opts.Dataset, b.opts.Table, request).Context(ctx).Do()
+		if err != nil {
+			return errors.Wrapf(err, "failed to insert rows into %s", b.opts.Table)
+		}
+		var errs multierror.Errors
+		for _, insertErr := range response.InsertErrors {
+			for _, e := range insertErr.Errors {
+				errs.Add(errors.Errorf("row %d: %s: %s", int64(start)+insertErr.Index, e.Reason, e.Message))
+			}
+		}
+		if !errs.Empty() {
+			return errors.Wrapf(errs.ToError(), "failed to insert rows into %s", b.opts.Table)
+		}
+	}
+	return nil
+}
+
+func (b *BigQuery) rows(results []*gcv.Result) ([]*bigquery.TableDataInsertAllRequestRows, error) {
+	var rows []*bigquery.TableDataInsertAllRequestRows
+	marshaler := &jsonpb.Marshaler{}
+	for _, result := range results {
+		violations, err := result.ToViolations()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to convert result for %s", result.Name)
+		}
+		ancestry, _ := result.CAIResource["ancestry_path"].(string)
+		for _, v := range violations {
+			metadata, err := marshaler.MarshalToString(v.Metadata)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to marshal metadata for %s", v.Resource)
+			}
+			rows = append(rows, &bigquery.TableDataInsertAllRequestRows{
+				InsertId: b.opts.RunID + "-" + gcv.ViolationFingerprint(v),
+				Json: map[string]bigquery.JsonValue{
+					"run_id":         b.opts.RunID,
+					"timestamp":      b.opts.RunTime.UTC().Format(time.RFC3339Nano),
+					"constraint":     v.Constraint,
+					"resource":       v.Resource,
+					"ancestry":       ancestry,
+					"severity":       v.Severity,
+					"message":        v.Message,
+					"metadata":       metadata,
+					"policy_version": v.PolicyVersion,
+				},
+			})
+		}
+	}
+	return rows, nil
+}
+
+func isHTTPStatus(err error, code int) bool {
+	apiErr, ok := errors.Cause(err).(*googleapi.Error)
+	return ok && apiErr.Code == code
+}
@@ -0,0 +1,219 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/forseti-security/config-validator/pkg/gcv"
+	"github.com/google/go-cmp/cmp"
+	bigquery "google.golang.org/api/bigquery/v2"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func testResults() []*gcv.Result {
+	constraint := &unstructured.Unstructured{}
+	constraint.SetKind("GCPStorageLoggingConstraint")
+	constraint.SetName("require-storage-logging")
+	return []*gcv.Result{
+		{
+			Name:          "//storage.googleapis.com/my-storage-bucket",
+			PolicyVersion: "1.0.0",
+			CAIResource:   map[string]interface{}{"ancestry_path": "organizations/1/projects/3"},
+			ConstraintViolations: []gcv.ConstraintViolation{
+				{
+					Message:    "//storage.googleapis.com/my-storage-bucket does not have the required logging destination.",
+					Metadata:   map[string]interface{}{"resource": "//storage.googleapis.com/my-storage-bucket"},
+					Constraint: constraint,
+					Severity:   "high",
+				},
+			},
+		},
+		{
+			Name:        "//storage.googleapis.com/compliant-bucket",
+			CAIResource: map[string]interface{}{"ancestry_path": "organizations/1/projects/3"},
+		},
+	}
+}
+
+// fakeBigQuery serves the subset of the BigQuery API used by the sink.
+type fakeBigQuery struct {
+	mu        sync.Mutex
+	created   *bigquery.Table
+	patched   *bigquery.Table
+	tableGets int
+	rows      []*bigquery.TableDataInsertAllRequestRows
+}
+
+func (f *fakeBigQuery) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/projects/p/datasets/d/tables/t":
+		f.tableGets++
+		if f.created == nil {
+			http.Error(w, `{"error": {"code": 404, "message": "not found"}}`, http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(f.created)
+	case r.Method == http.MethodPatch && r.URL.Path == "/projects/p/datasets/d/tables/t":
+		f.patched = &bigquery.Table{}
+		_ = json.NewDecoder(r.Body).Decode(f.patched)
+		f.created.Schema = f.patched.Schema
+		_ = json.NewEncoder(w).Encode(f.created)
+	case r.Method == http.MethodPost && r.URL.Path == "/projects/p/datasets/d/tables":
+		f.created = &bigquery.Table{}
+		_ = json.NewDecoder(r.Body).Decode(f.created)
+		_ = json.NewEncoder(w).Encode(f.created)
+	case r.Method == http.MethodPost && r.URL.Path == "/projects/p/datasets/d/tables/t/insertAll":
+		request := &bigquery.TableDataInsertAllRequest{}
+		_ = json.NewDecoder(r.Body).Decode(request)
+		f.rows = append(f.rows, request.Rows...)
+		_ = json.NewEncoder(w).Encode(&bigquery.TableDataInsertAllResponse{})
+	default:
+		http.Error(w, "unexpected request "+r.Method+" "+r.URL.Path, http.StatusBadRequest)
+	}
+}
+
+func newTestService(t *testing.T, handler http.Handler) (*bigquery.Service, func()) {
+	server := httptest.NewServer(handler)
+	service, err := bigquery.New(server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.BasePath = server.URL + "/"
+	return service, server.Close
+}
+
+func TestBigQuery(t *testing.T) {
+	fake := &fakeBigQuery{}
+	service, cleanup := newTestService(t, fake)
+	defer cleanup()
+
+	runTime := time.Date(2020, 4, 1, 12, 0, 0, 0, time.UTC)
+	bq, err := NewBigQuery(service, BigQueryOptions{Project: "p", Dataset: "d", Table: "t", RunID: "run-1", RunTime: runTime})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if err := bq.Write(ctx, testResults()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if fake.created == nil || fake.created.TimePartitioning.Field != "timestamp" {
+		t.Fatalf("expected partitioned table to be created, got %+v", fake.created)
+	}
+	if diff := cmp.Diff(len(fake.created.Schema.Fields), len(BigQueryTableSchema.Fields)); diff != "" {
+		t.Errorf("schema field count mismatch, +got -want\n%s", diff)
+	}
+	if fake.tableGets != 1 {
+		t.Errorf("got %d table lookups, want 1", fake.tableGets)
+	}
+	if fake.patched != nil {
+		t.Errorf("unexpected schema update of a newly created table")
+	}
+	if len(fake.rows) != 2 {
+		t.Fatalf("got %d rows, want 2", len(fake.rows))
+	}
+	if fake.rows[0].InsertId != fake.rows[1].InsertId {
+		t.Errorf("insert IDs of the same violation should match, got %s and %s", fake.rows[0].InsertId, fake.rows[1].InsertId)
+	}
+	row := fake.rows[0].Json
+	want := map[string]bigquery.JsonValue{
+		"run_id":         "run-1",
+		"timestamp":      "2020-04-01T12:00:00Z",
+		"constraint":     "GCPStorageLoggingConstraint.require-storage-logging",
+		"resource":       "//storage.googleapis.com/my-storage-bucket",
+		"ancestry":       "organizations/1/projects/3",
+		"severity":       "high",
+		"message":        "//storage.googleapis.com/my-storage-bucket does not have the required logging destination.",
+		"metadata":       row["metadata"],
+		"policy_version": "1.0.0",
+	}
+	if diff := cmp.Diff(row, want); diff != "" {
+		t.Errorf("row mismatch, +got -want\n%s", diff)
+	}
+	metadata := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(row["metadata"].(string)), &metadata); err != nil {
+		t.Fatalf("metadata is not JSON: %v", err)
+	}
+	if metadata["resource"] != "//storage.googleapis.com/my-storage-bucket" {
+		t.Errorf("unexpected metadata %v", metadata)
+	}
+}
+
+func TestBigQueryExtendsSchema(t *testing.T) {
+	// A table created before the policy_version column was added.
+	fake := &fakeBigQuery{created: &bigquery.Table{
+		Schema: &bigquery.TableSchema{Fields: BigQueryTableSchema.Fields[:len(BigQueryTableSchema.Fields)-1]},
+	}}
+	service, cleanup := newTestService(t, fake)
+	defer cleanup()
+
+	bq, err := NewBigQuery(service, BigQueryOptions{Project: "p", Dataset: "d", Table: "t"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := bq.Write(context.Background(), testResults()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fake.patched == nil {
+		t.Fatalf("expected schema to be extended")
+	}
+	fields := fake.patched.Schema.Fields
+	if len(fields) != len(BigQueryTableSchema.Fields) {
+		t.Fatalf("got %d fields, want %d", len(fields), len(BigQueryTableSchema.Fields))
+	}
+	if added := fields[len(fields)-1]; added.Name != "policy_version" || added.Mode != "NULLABLE" {
+		t.Errorf("got added field %+v, want nullable policy_version", added)
+	}
+}
+
+func TestBigQueryInsertErrors(t *testing.T) {
+	service, cleanup := newTestService(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			_ = json.NewEncoder(w).Encode(&bigquery.Table{Schema: BigQueryTableSchema})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(&bigquery.TableDataInsertAllResponse{
+			InsertErrors: []*bigquery.TableDataInsertAllResponseInsertErrors{
+				{Index: 0, Errors: []*bigquery.ErrorProto{{Reason: "invalid", Message: "bad row"}}},
+			},
+		})
+	}))
+	defer cleanup()
+
+	bq, err := NewBigQuery(service, BigQueryOptions{Project: "p", Dataset: "d", Table: "t"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := bq.Write(context.Background(), testResults()); err == nil {
+		t.Errorf("expected error for rejected rows")
+	}
+}
+
+func TestNewBigQueryErrors(t *testing.T) {
+	if _, err := NewBigQuery(nil, BigQueryOptions{Project: "p", Dataset: "d"}); err == nil {
+		t.Errorf("expected error for missing table")
+	}
+}
@@ -0,0 +1,226 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/forseti-security/config-validator/pkg/api/validator"
+	"github.com/forseti-security/config-validator/pkg/gcv"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/pkg/errors"
+	logging "google.golang.org/api/logging/v2"
+)
+
+// maxLogEntries is the number of entries sent per write request.
+const maxLogEntries = 1000
+
+// DefaultLogID is the log violations are written to if CloudLoggingOptions.LogID is not set.
+const DefaultLogID = "config-validator-violations"
+
+// CloudLoggingOptions identifies the log violations are written to.
+type CloudLoggingOptions struct {
+	// Project is the project owning the log.
+	Project string
+	// LogID is the name of the log within the project, defaults to DefaultLogID.
+	LogID string
+	// RunID identifies the review run in the run_id label, defaults to the run time.
+	RunID string
+	// RunTime is the timestamp of the entries, defaults to the time the sink is created.
+	RunTime time.Time
+}
+
+// CloudLogging writes each violation as a structured Cloud Logging entry. The monitored resource
+// of an entry is derived from the violating asset, so that entries show up with the resource in
+// the Logs Explorer and log-based alerts can filter on its labels.
+type CloudLogging struct {
+	service *logging.Service
+	opts    CloudLoggingOptions
+}
+
+var _ Sink = &CloudLogging{}
+
+// NewCloudLogging returns a sink writing to the log described by opts using service.
+func NewCloudLogging(service *logging.Service, opts CloudLoggingOptions) (*CloudLogging, error) {
+	if opts.Project == "" {
+		return nil, errors.Errorf("project must be set")
+	}
+	if opts.LogID == "" {
+		opts.LogID = DefaultLogID
+	}
+	if opts.RunTime.IsZero() {
+		opts.RunTime = time.Now()
+	}
+	if opts.RunID == "" {
+		opts.RunID = opts.RunTime.UTC().Format(time.RFC3339Nano)
+	}
+	return &CloudLogging{service: service, opts: opts}, nil
+}
+
+// Write writes one entry per violation. Entries carry an insert ID derived from the run and the
+// violation, so retried writes do not produce duplicates.
+func (c *CloudLogging) Write(ctx context.Context, results []*gcv.Result) error {
+	entries, err := c.entries(results)
+	if err != nil {
+		return err
+	}
+	logName := fmt.Sprintf("projects/%s/logs/%s", c.opts.Project, c.opts.LogID)
+	for start := 0; start < len(entries); start += maxLogEntries {
+		end := start + maxLogEntries
+		if end > len(entries) {
+			end = len(entries)
+		}
+		request := &logging.WriteLogEntriesRequest{LogName: logName, Entries: entries[start:end]}
+		if _, err := c.service.Entries.Write(request).Context(ctx).Do(); err != nil {
+			return errors.Wrapf(err, "failed to write entries to %s", logName)
+		}
+	}
+	return nil
+}
+
+func (c *CloudLogging) entries(results []*gcv.Result) ([]*logging.LogEntry, error) {
+	var entries []*logging.LogEntry
+	marshaler := &jsonpb.Marshaler{}
+	for _, result := range results {
+		violations, err := result.ToViolations()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to convert result for %s", result.Name)
+		}
+		assetType, _ := result.CAIResource["asset_type"].(string)
+		ancestry, _ := result.CAIResource["ancestry_path"].(string)
+		resource := c.monitoredResource(result.Name, assetType, result.CAIResource)
+		for _, v := range violations {
+			payload, err := violationPayload(marshaler, v, assetType, ancestry)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, &logging.LogEntry{
+				InsertId:    c.opts.RunID + "-" + gcv.ViolationFingerprint(v),
+				Timestamp:   c.opts.RunTime.UTC().Format(time.RFC3339Nano),
+				Severity:    logSeverity(v.Severity),
+				Resource:    resource,
+				JsonPayload: payload,
+				Labels: map[string]string{
+					"run_id":     c.opts.RunID,
+					"constraint": v.Constraint,
+				},
+			})
+		}
+	}
+	return entries, nil
+}
+
+// violationPayload returns the JSON payload of the entry for v, whose message is shown as the
+// summary of the entry.
+func violationPayload(marshaler *jsonpb.Marshaler, v *validator.Violation, assetType, ancestry string) ([]byte, error) {
+	payload := map[string]interface{}{
+		"message":        v.Message,
+		"constraint":     v.Constraint,
+		"resource":       v.Resource,
+		"asset_type":     assetType,
+		"ancestry":       ancestry,
+		"severity":       v.Severity,
+		"policy_version": v.PolicyVersion,
+	}
+	if v.Remediation != "" {
+		payload["remediation"] = v.Remediation
+	}
+	if v.RemediationUrl != "" {
+		payload["remediation_url"] = v.RemediationUrl
+	}
+	if v.Metadata != nil {
+		metadata, err := marshaler.MarshalToString(v.Metadata)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to marshal metadata for %s", v.Resource)
+		}
+		payload["metadata"] = json.RawMessage(metadata)
+	}
+	encoded, err := json.Marshal(payload)
+	return encoded, errors.Wrapf(err, "failed to marshal payload for %s", v.Resource)
+}
+
+// logSeverity maps the severity of a constraint to a Cloud Logging severity.
+func logSeverity(severity string) string {
+	switch strings.ToLower(severity) {
+	case "critical":
+		return "CRITICAL"
+	case "high":
+		return "ERROR"
+	case "medium":
+		return "WARNING"
+	case "low":
+		return "NOTICE"
+	}
+	return "DEFAULT"
+}
+
+// monitoredResource returns the Cloud Logging monitored resource for an asset. Asset types
+// without a matching resource type are logged against the global resource.
+func (c *CloudLogging) monitoredResource(name, assetType string, asset map[string]interface{}) *logging.MonitoredResource {
+	project := c.opts.Project
+	if id := nameSegment(name, "projects"); id != "" {
+		project = id
+	}
+	data, _ := asset["resource"].(map[string]interface{})
+	data, _ = data["data"].(map[string]interface{})
+	resource := &logging.MonitoredResource{Type: "global", Labels: map[string]string{"project_id": project}}
+
+	switch assetType {
+	case "storage.googleapis.com/Bucket":
+		resource.Type = "gcs_bucket"
+		resource.Labels["bucket_name"] = lastSegment(name)
+		if location, ok := data["location"].(string); ok {
+			resource.Labels["location"] = strings.ToLower(location)
+		}
+	case "compute.googleapis.com/Instance":
+		resource.Type = "gce_instance"
+		resource.Labels["zone"] = nameSegment(name, "zones")
+		if id, ok := data["id"].(string); ok {
+			resource.Labels["instance_id"] = id
+		}
+	case "cloudresourcemanager.googleapis.com/Project":
+		resource.Type = "project"
+		if id, ok := data["projectId"].(string); ok {
+			resource.Labels["project_id"] = id
+		}
+	case "sqladmin.googleapis.com/Instance":
+		resource.Type = "cloudsql_database"
+		resource.Labels["database_id"] = project + ":" + lastSegment(name)
+		if region, ok := data["region"].(string); ok {
+			resource.Labels["region"] = region
+		}
+	}
+	return resource
+}
+
+// nameSegment returns the segment following collection in a full resource name, e.g. the
+// project of //compute.googleapis.com/projects/p/zones/z/instances/i for projects.
+func nameSegment(name, collection string) string {
+	segments := strings.Split(name, "/")
+	for idx := 0; idx < len(segments)-1; idx++ {
+		if segments[idx] == collection {
+			return segments[idx+1]
+		}
+	}
+	return ""
+}
+
+func lastSegment(name string) string {
+	return name[strings.LastIndex(name, "/")+1:]
+}
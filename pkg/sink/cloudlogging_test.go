@@ -0,0 +1,156 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/forseti-security/config-validator/pkg/gcv"
+	"github.com/google/go-cmp/cmp"
+	logging "google.golang.org/api/logging/v2"
+)
+
+// fakeCloudLogging records the write requests sent to the Cloud Logging API.
+type fakeCloudLogging struct {
+	mu       sync.Mutex
+	requests []*logging.WriteLogEntriesRequest
+}
+
+func (f *fakeCloudLogging) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.Method != http.MethodPost || r.URL.Path != "/v2/entries:write" {
+		http.Error(w, "unexpected request "+r.Method+" "+r.URL.Path, http.StatusBadRequest)
+		return
+	}
+	request := &logging.WriteLogEntriesRequest{}
+	_ = json.NewDecoder(r.Body).Decode(request)
+	f.requests = append(f.requests, request)
+	_ = json.NewEncoder(w).Encode(&logging.WriteLogEntriesResponse{})
+}
+
+func newTestLoggingService(t *testing.T, handler http.Handler) (*logging.Service, func()) {
+	server := httptest.NewServer(handler)
+	service, err := logging.New(server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.BasePath = server.URL + "/"
+	return service, server.Close
+}
+
+func TestCloudLogging(t *testing.T) {
+	fake := &fakeCloudLogging{}
+	service, cleanup := newTestLoggingService(t, fake)
+	defer cleanup()
+
+	results := testResults()
+	results[0].CAIResource["asset_type"] = "storage.googleapis.com/Bucket"
+	results[0].CAIResource["resource"] = map[string]interface{}{"data": map[string]interface{}{"location": "EU"}}
+	instance := *results[0]
+	instance.Name = "//compute.googleapis.com/projects/my-project/zones/europe-west1-b/instances/vm"
+	instance.CAIResource = map[string]interface{}{
+		"asset_type":    "compute.googleapis.com/Instance",
+		"ancestry_path": "organizations/1/projects/3",
+		"resource":      map[string]interface{}{"data": map[string]interface{}{"id": "1234"}},
+	}
+	results = append(results, &instance)
+
+	runTime := time.Date(2020, 4, 1, 12, 0, 0, 0, time.UTC)
+	sink, err := NewCloudLogging(service, CloudLoggingOptions{Project: "logs", RunID: "run-1", RunTime: runTime})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := sink.Write(context.Background(), results); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(fake.requests) != 1 {
+		t.Fatalf("got %d requests, want 1", len(fake.requests))
+	}
+	request := fake.requests[0]
+	if request.LogName != "projects/logs/logs/config-validator-violations" {
+		t.Errorf("got log name %s", request.LogName)
+	}
+	if len(request.Entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(request.Entries))
+	}
+	bucket := request.Entries[0]
+	if bucket.Severity != "ERROR" || bucket.Timestamp != "2020-04-01T12:00:00Z" || bucket.InsertId == "" {
+		t.Errorf("unexpected entry %+v", bucket)
+	}
+	wantLabels := map[string]string{"run_id": "run-1", "constraint": "GCPStorageLoggingConstraint.require-storage-logging"}
+	if diff := cmp.Diff(wantLabels, bucket.Labels); diff != "" {
+		t.Errorf("unexpected labels (-want +got):\n%s", diff)
+	}
+	wantResources := []*logging.MonitoredResource{
+		{Type: "gcs_bucket", Labels: map[string]string{"project_id": "logs", "bucket_name": "my-storage-bucket", "location": "eu"}},
+		{Type: "gce_instance", Labels: map[string]string{"project_id": "my-project", "zone": "europe-west1-b", "instance_id": "1234"}},
+	}
+	gotResources := []*logging.MonitoredResource{bucket.Resource, request.Entries[1].Resource}
+	if diff := cmp.Diff(wantResources, gotResources); diff != "" {
+		t.Errorf("unexpected resources (-want +got):\n%s", diff)
+	}
+
+	payload := map[string]interface{}{}
+	if err := json.Unmarshal(bucket.JsonPayload, &payload); err != nil {
+		t.Fatalf("payload is not JSON: %v", err)
+	}
+	wantPayload := map[string]interface{}{
+		"message":        "//storage.googleapis.com/my-storage-bucket does not have the required logging destination.",
+		"constraint":     "GCPStorageLoggingConstraint.require-storage-logging",
+		"resource":       "//storage.googleapis.com/my-storage-bucket",
+		"asset_type":     "storage.googleapis.com/Bucket",
+		"ancestry":       "organizations/1/projects/3",
+		"severity":       "high",
+		"policy_version": "1.0.0",
+		"metadata":       payload["metadata"],
+	}
+	if diff := cmp.Diff(wantPayload, payload); diff != "" {
+		t.Errorf("unexpected payload (-want +got):\n%s", diff)
+	}
+	if metadata, _ := payload["metadata"].(map[string]interface{}); metadata["resource"] != "//storage.googleapis.com/my-storage-bucket" {
+		t.Errorf("unexpected metadata %v", payload["metadata"])
+	}
+}
+
+func TestCloudLoggingNoViolations(t *testing.T) {
+	fake := &fakeCloudLogging{}
+	service, cleanup := newTestLoggingService(t, fake)
+	defer cleanup()
+
+	sink, err := NewCloudLogging(service, CloudLoggingOptions{Project: "logs"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := sink.Write(context.Background(), []*gcv.Result{testResults()[1]}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(fake.requests) != 0 {
+		t.Errorf("got %d requests, want none", len(fake.requests))
+	}
+}
+
+func TestNewCloudLoggingErrors(t *testing.T) {
+	if _, err := NewCloudLogging(nil, CloudLoggingOptions{}); err == nil {
+		t.Errorf("expected error for missing project")
+	}
+}
@@ -0,0 +1,27 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sink exports review results to external systems.
+package sink
+
+import (
+	"context"
+
+	"github.com/forseti-security/config-validator/pkg/gcv"
+)
+
+// Sink receives the results of a review run. Write may be called multiple times per run.
+type Sink interface {
+	Write(ctx context.Context, results []*gcv.Result) error
+}
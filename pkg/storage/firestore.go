@@ -0,0 +1,255 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/pkg/errors"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// firestoreMaxWrites is the number of writes Firestore accepts per commit.
+const firestoreMaxWrites = 500
+
+// firestoreRun is the document of a run, keyed by run ID.
+type firestoreRun struct {
+	StartTime     time.Time `firestore:"start_time"`
+	PolicyVersion string    `firestore:"policy_version"`
+}
+
+// firestoreViolation is the document of a violation, keyed by fingerprint. Resolved is null while
+// the violation is open.
+type firestoreViolation struct {
+	Constraint string     `firestore:"constraint"`
+	Resource   string     `firestore:"resource"`
+	Severity   string     `firestore:"severity"`
+	Message    string     `firestore:"message"`
+	FirstSeen  time.Time  `firestore:"first_seen"`
+	FirstRunID string     `firestore:"first_run_id"`
+	LastSeen   time.Time  `firestore:"last_seen"`
+	LastRunID  string     `firestore:"last_run_id"`
+	Resolved   *time.Time `firestore:"resolved"`
+}
+
+// Firestore is a Store in Firestore, for tracking violations from serverless deployments such as
+// Cloud Run jobs without managing a database. Runs and violations are kept in two collections,
+// with documents keyed by run ID and fingerprint.
+type Firestore struct {
+	client     *firestore.Client
+	runs       *firestore.CollectionRef
+	violations *firestore.CollectionRef
+}
+
+var _ Store = &Firestore{}
+
+// OpenFirestore opens the store in the Firestore database of project. The collections are named
+// runs and violations, after prefix, so several stores can share a database.
+func OpenFirestore(ctx context.Context, project, prefix string, opts ...option.ClientOption) (*Firestore, error) {
+	client, err := firestore.NewClient(ctx, project, opts...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create Firestore client for project %s", project)
+	}
+	return &Firestore{
+		client:     client,
+		runs:       client.Collection(prefix + "runs"),
+		violations: client.Collection(prefix + "violations"),
+	}, nil
+}
+
+// SaveRun implements Store.
+func (s *Firestore) SaveRun(ctx context.Context, run *Run) error {
+	_, err := s.runs.Doc(run.ID).Create(ctx, &firestoreRun{StartTime: run.StartTime, PolicyVersion: run.PolicyVersion})
+	return errors.Wrapf(err, "failed to save run %s", run.ID)
+}
+
+// runStart returns the start time of the run runID.
+func (s *Firestore) runStart(ctx context.Context, runID string) (time.Time, error) {
+	doc, err := s.runs.Doc(runID).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return time.Time{}, errors.Errorf("run %s was not saved", runID)
+	}
+	if err != nil {
+		return time.Time{}, errors.Wrapf(err, "failed to look up run %s", runID)
+	}
+	run := &firestoreRun{}
+	if err := doc.DataTo(run); err != nil {
+		return time.Time{}, errors.Wrapf(err, "failed to read run %s", runID)
+	}
+	return run.StartTime, nil
+}
+
+// SaveViolations implements Store. Firestore limits the writes of a transaction, so the
+// violations are saved in one transaction per 500 violations.
+func (s *Firestore) SaveViolations(ctx context.Context, runID string, violations []*Violation) error {
+	start, err := s.runStart(ctx, runID)
+	if err != nil {
+		return err
+	}
+	// Later duplicates replace earlier ones, as they would in a single transaction.
+	byFingerprint := map[string]*Violation{}
+	var fingerprints []string
+	for _, v := range violations {
+		if _, found := byFingerprint[v.Fingerprint]; !found {
+			fingerprints = append(fingerprints, v.Fingerprint)
+		}
+		byFingerprint[v.Fingerprint] = v
+	}
+	for len(fingerprints) != 0 {
+		chunk := fingerprints
+		if len(chunk) > firestoreMaxWrites {
+			chunk = chunk[:firestoreMaxWrites]
+		}
+		fingerprints = fingerprints[len(chunk):]
+		refs := make([]*firestore.DocumentRef, len(chunk))
+		for idx, fingerprint := range chunk {
+			refs[idx] = s.violations.Doc(fingerprint)
+		}
+		err := s.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+			docs, err := tx.GetAll(refs)
+			if err != nil {
+				return err
+			}
+			for idx, doc := range docs {
+				v := byFingerprint[chunk[idx]]
+				saved := &firestoreViolation{}
+				if doc.Exists() {
+					if err := doc.DataTo(saved); err != nil {
+						return errors.Wrapf(err, "failed to read violation %s", v.Fingerprint)
+					}
+				}
+				// Violations not seen before, or resolved since, are opened by the run.
+				if !doc.Exists() || saved.Resolved != nil {
+					saved = &firestoreViolation{FirstSeen: start, FirstRunID: runID}
+				}
+				saved.Constraint, saved.Resource, saved.Message = v.Constraint, v.Resource, v.Message
+				saved.Severity = v.Severity
+				saved.LastSeen, saved.LastRunID = start, runID
+				if err := tx.Set(refs[idx], saved); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return errors.Wrapf(err, "failed to save violations of run %s", runID)
+		}
+	}
+	return nil
+}
+
+// openViolations returns the documents of the open violations, restricted to those of constraint
+// if set.
+func (s *Firestore) openViolations(ctx context.Context, constraint string) ([]*firestore.DocumentSnapshot, error) {
+	query := s.violations.Where("resolved", "==", nil)
+	if constraint != "" {
+		query = query.Where("constraint", "==", constraint)
+	}
+	docs, err := query.Documents(ctx).GetAll()
+	return docs, errors.Wrapf(err, "failed to query open violations")
+}
+
+// QueryOpenViolations implements Store. Resource prefixes are matched and records ordered by the
+// store rather than by Firestore, which would need a composite index for either.
+func (s *Firestore) QueryOpenViolations(ctx context.Context, q Query) ([]*Record, error) {
+	docs, err := s.openViolations(ctx, q.Constraint)
+	if err != nil {
+		return nil, err
+	}
+	var records []*Record
+	for _, doc := range docs {
+		v := &firestoreViolation{}
+		if err := doc.DataTo(v); err != nil {
+			return nil, errors.Wrapf(err, "failed to read open violation %s", doc.Ref.ID)
+		}
+		if !strings.HasPrefix(v.Resource, q.ResourcePrefix) {
+			continue
+		}
+		records = append(records, &Record{
+			Violation: Violation{
+				Fingerprint: doc.Ref.ID,
+				Constraint:  v.Constraint,
+				Resource:    v.Resource,
+				Severity:    v.Severity,
+				Message:     v.Message,
+			},
+			FirstSeen:  v.FirstSeen.UTC(),
+			FirstRunID: v.FirstRunID,
+			LastSeen:   v.LastSeen.UTC(),
+			LastRunID:  v.LastRunID,
+		})
+	}
+	sort.Slice(records, func(i, j int) bool {
+		a, b := records[i], records[j]
+		if !a.FirstSeen.Equal(b.FirstSeen) {
+			return a.FirstSeen.Before(b.FirstSeen)
+		}
+		return a.Fingerprint < b.Fingerprint
+	})
+	return records, nil
+}
+
+// ResolveMissing implements Store. The violations are resolved in batches of 500, a failure may
+// leave some of them resolved, which calling ResolveMissing again completes.
+func (s *Firestore) ResolveMissing(ctx context.Context, runID string) (int, error) {
+	start, err := s.runStart(ctx, runID)
+	if err != nil {
+		return 0, err
+	}
+	docs, err := s.openViolations(ctx, "")
+	if err != nil {
+		return 0, err
+	}
+	resolved := 0
+	batch, writes := s.client.Batch(), 0
+	for _, doc := range docs {
+		lastRunID, err := doc.DataAt("last_run_id")
+		if err != nil {
+			return resolved, errors.Wrapf(err, "failed to read open violation %s", doc.Ref.ID)
+		}
+		if lastRunID == runID {
+			continue
+		}
+		// The update fails if the violation changed since it was read, such as by being found
+		// by a concurrent SaveViolations.
+		batch.Update(doc.Ref, []firestore.Update{{Path: "resolved", Value: start}}, firestore.LastUpdateTime(doc.UpdateTime))
+		writes++
+		if writes == firestoreMaxWrites {
+			if _, err := batch.Commit(ctx); err != nil {
+				return resolved, errors.Wrapf(err, "failed to resolve violations missing from run %s", runID)
+			}
+			resolved += writes
+			batch, writes = s.client.Batch(), 0
+		}
+	}
+	if writes != 0 {
+		if _, err := batch.Commit(ctx); err != nil {
+			return resolved, errors.Wrapf(err, "failed to resolve violations missing from run %s", runID)
+		}
+		resolved += writes
+	}
+	return resolved, nil
+}
+
+// Close implements Store.
+func (s *Firestore) Close() error {
+	return s.client.Close()
+}
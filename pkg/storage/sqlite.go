@@ -0,0 +1,188 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	// Registers the sqlite3 driver.
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/pkg/errors"
+)
+
+// sqliteSchema creates the tables of the store. Times are stored as nanoseconds since the Unix
+// epoch, resolved is NULL while a violation is open.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS runs (
+	id TEXT PRIMARY KEY,
+	start_time INTEGER NOT NULL,
+	policy_version TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS violations (
+	fingerprint TEXT PRIMARY KEY,
+	constraint_name TEXT NOT NULL,
+	resource TEXT NOT NULL,
+	severity TEXT NOT NULL,
+	message TEXT NOT NULL,
+	first_seen INTEGER NOT NULL,
+	first_run_id TEXT NOT NULL,
+	last_seen INTEGER NOT NULL,
+	last_run_id TEXT NOT NULL,
+	resolved INTEGER
+);
+CREATE INDEX IF NOT EXISTS violations_open ON violations (resolved, first_seen);
+`
+
+// sqliteUpsertViolation opens a violation, or updates its last run. The expressions of the update
+// see the row as it was, so a violation that was resolved is opened again with the run as its
+// first run.
+const sqliteUpsertViolation = `
+INSERT INTO violations (
+	fingerprint, constraint_name, resource, severity, message, first_seen, first_run_id, last_seen, last_run_id, resolved)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, NULL)
+ON CONFLICT (fingerprint) DO UPDATE SET
+	severity = excluded.severity,
+	first_seen = CASE WHEN resolved IS NULL THEN first_seen ELSE excluded.first_seen END,
+	first_run_id = CASE WHEN resolved IS NULL THEN first_run_id ELSE excluded.first_run_id END,
+	last_seen = excluded.last_seen,
+	last_run_id = excluded.last_run_id,
+	resolved = NULL
+`
+
+// SQLite is a Store in a SQLite database file, for tracking violations without a database server.
+type SQLite struct {
+	db *sql.DB
+}
+
+var _ Store = &SQLite{}
+
+// OpenSQLite opens the store in the SQLite database file at path, creating the file and its
+// tables if they do not exist.
+func OpenSQLite(path string) (*SQLite, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open %s", path)
+	}
+	// SQLite allows one writer at a time, serializing access avoids busy errors.
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, errors.Wrapf(err, "failed to create tables in %s", path)
+	}
+	return &SQLite{db: db}, nil
+}
+
+// SaveRun implements Store.
+func (s *SQLite) SaveRun(ctx context.Context, run *Run) error {
+	_, err := s.db.ExecContext(ctx, "INSERT INTO runs (id, start_time, policy_version) VALUES (?, ?, ?)",
+		run.ID, run.StartTime.UnixNano(), run.PolicyVersion)
+	return errors.Wrapf(err, "failed to save run %s", run.ID)
+}
+
+// queryRower is implemented by *sql.DB and *sql.Tx.
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// runStart returns the start time of the run runID in nanoseconds since the Unix epoch.
+func runStart(ctx context.Context, q queryRower, runID string) (int64, error) {
+	var start int64
+	err := q.QueryRowContext(ctx, "SELECT start_time FROM runs WHERE id = ?", runID).Scan(&start)
+	if err == sql.ErrNoRows {
+		return 0, errors.Errorf("run %s was not saved", runID)
+	}
+	return start, errors.Wrapf(err, "failed to look up run %s", runID)
+}
+
+// SaveViolations implements Store. The violations are saved in one transaction.
+func (s *SQLite) SaveViolations(ctx context.Context, runID string, violations []*Violation) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrapf(err, "failed to begin transaction")
+	}
+	defer tx.Rollback()
+	start, err := runStart(ctx, tx, runID)
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.PrepareContext(ctx, sqliteUpsertViolation)
+	if err != nil {
+		return errors.Wrapf(err, "failed to prepare statement")
+	}
+	defer stmt.Close()
+	for _, v := range violations {
+		if _, err := stmt.ExecContext(ctx, v.Fingerprint, v.Constraint, v.Resource, v.Severity, v.Message,
+			start, runID, start, runID); err != nil {
+			return errors.Wrapf(err, "failed to save violation of %s by %s", v.Constraint, v.Resource)
+		}
+	}
+	return errors.Wrapf(tx.Commit(), "failed to save violations of run %s", runID)
+}
+
+// QueryOpenViolations implements Store.
+func (s *SQLite) QueryOpenViolations(ctx context.Context, q Query) ([]*Record, error) {
+	query := `SELECT fingerprint, constraint_name, resource, severity, message, first_seen, first_run_id, last_seen, last_run_id
+FROM violations WHERE resolved IS NULL`
+	var args []interface{}
+	if q.Constraint != "" {
+		query += " AND constraint_name = ?"
+		args = append(args, q.Constraint)
+	}
+	if q.ResourcePrefix != "" {
+		// Unlike LIKE, substr takes the prefix literally.
+		query += " AND substr(resource, 1, length(?)) = ?"
+		args = append(args, q.ResourcePrefix, q.ResourcePrefix)
+	}
+	query += " ORDER BY first_seen, fingerprint"
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to query open violations")
+	}
+	defer rows.Close()
+	var records []*Record
+	for rows.Next() {
+		r := &Record{}
+		var firstSeen, lastSeen int64
+		if err := rows.Scan(&r.Fingerprint, &r.Constraint, &r.Resource, &r.Severity, &r.Message,
+			&firstSeen, &r.FirstRunID, &lastSeen, &r.LastRunID); err != nil {
+			return nil, errors.Wrapf(err, "failed to read open violation")
+		}
+		r.FirstSeen = time.Unix(0, firstSeen).UTC()
+		r.LastSeen = time.Unix(0, lastSeen).UTC()
+		records = append(records, r)
+	}
+	return records, errors.Wrapf(rows.Err(), "failed to query open violations")
+}
+
+// ResolveMissing implements Store.
+func (s *SQLite) ResolveMissing(ctx context.Context, runID string) (int, error) {
+	start, err := runStart(ctx, s.db, runID)
+	if err != nil {
+		return 0, err
+	}
+	result, err := s.db.ExecContext(ctx, "UPDATE violations SET resolved = ? WHERE resolved IS NULL AND last_run_id != ?", start, runID)
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to resolve violations missing from run %s", runID)
+	}
+	resolved, err := result.RowsAffected()
+	return int(resolved), errors.Wrapf(err, "failed to resolve violations missing from run %s", runID)
+}
+
+// Close implements Store.
+func (s *SQLite) Close() error {
+	return s.db.Close()
+}
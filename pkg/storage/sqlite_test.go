@@ -0,0 +1,130 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func violation(resource string) *Violation {
+	return &Violation{
+		Fingerprint: "fp-" + resource,
+		Constraint:  "GCPStorageLoggingConstraint.require-storage-logging",
+		Resource:    resource,
+		Severity:    "medium",
+		Message:     "no logging",
+	}
+}
+
+func TestSQLite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "SQLiteTest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	s, err := OpenSQLite(filepath.Join(dir, "violations.db"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer s.Close()
+	testStore(t, s)
+}
+
+// testStore tracks three runs in s, which must be empty, and checks the recorded lifecycle of
+// their violations.
+func testStore(t *testing.T, s Store) {
+	ctx := context.Background()
+	start := time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC)
+	runs := []struct {
+		resources    []string
+		wantResolved int
+	}{
+		{resources: []string{"//storage.googleapis.com/a", "//storage.googleapis.com/b"}},
+		// b is fixed.
+		{resources: []string{"//storage.googleapis.com/a"}, wantResolved: 1},
+		// b regresses.
+		{resources: []string{"//storage.googleapis.com/a", "//storage.googleapis.com/b"}},
+	}
+	for idx, run := range runs {
+		var violations []*Violation
+		for _, resource := range run.resources {
+			violations = append(violations, violation(resource))
+		}
+		r := &Run{ID: fmt.Sprintf("run-%d", idx), StartTime: start.Add(time.Duration(idx) * time.Hour), PolicyVersion: "v1"}
+		resolved, err := Track(ctx, s, r, violations)
+		if err != nil {
+			t.Fatalf("run %d: unexpected error: %v", idx, err)
+		}
+		if resolved != run.wantResolved {
+			t.Errorf("run %d: got %d resolved violations, want %d", idx, resolved, run.wantResolved)
+		}
+	}
+
+	got, err := s.QueryOpenViolations(ctx, Query{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []*Record{
+		{
+			Violation:  *violation("//storage.googleapis.com/a"),
+			FirstSeen:  start,
+			FirstRunID: "run-0",
+			LastSeen:   start.Add(2 * time.Hour),
+			LastRunID:  "run-2",
+		},
+		{
+			// The regression is open since the run finding it again.
+			Violation:  *violation("//storage.googleapis.com/b"),
+			FirstSeen:  start.Add(2 * time.Hour),
+			FirstRunID: "run-2",
+			LastSeen:   start.Add(2 * time.Hour),
+			LastRunID:  "run-2",
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected open violations (-want +got):\n%s", diff)
+	}
+
+	for _, tc := range []struct {
+		query Query
+		want  int
+	}{
+		{query: Query{ResourcePrefix: "//storage.googleapis.com/b"}, want: 1},
+		{query: Query{ResourcePrefix: "//storage.googleapis.com/%"}, want: 0},
+		{query: Query{Constraint: "GCPStorageLoggingConstraint.require-storage-logging"}, want: 2},
+		{query: Query{Constraint: "GCPStorageLoggingConstraint.other"}, want: 0},
+	} {
+		got, err := s.QueryOpenViolations(ctx, tc.query)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(got) != tc.want {
+			t.Errorf("query %+v: got %d records, want %d", tc.query, len(got), tc.want)
+		}
+	}
+
+	if err := s.SaveViolations(ctx, "unknown", nil); err == nil || !strings.Contains(err.Error(), "run unknown was not saved") {
+		t.Errorf("got error %v, want run not saved", err)
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package storage tracks violations across review runs, recording when each was first seen, last
+// seen and resolved.
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/forseti-security/config-validator/pkg/gcv"
+	"github.com/forseti-security/config-validator/pkg/report"
+)
+
+// Run is a review run.
+type Run struct {
+	ID        string
+	StartTime time.Time
+	// PolicyVersion is the version of the policy set of the run.
+	PolicyVersion string
+}
+
+// Violation is a violation found by a run.
+type Violation struct {
+	// Fingerprint identifies the violation across runs, see gcv.Fingerprint.
+	Fingerprint string
+	Constraint  string
+	Resource    string
+	Severity    string
+	Message     string
+}
+
+// Record is the lifecycle of a violation across runs.
+type Record struct {
+	Violation
+	// FirstSeen and FirstRunID identify the first run finding the violation since it was last
+	// resolved.
+	FirstSeen  time.Time
+	FirstRunID string
+	// LastSeen and LastRunID identify the last run finding the violation.
+	LastSeen  time.Time
+	LastRunID string
+	// Resolved is the start of the first run no longer finding the violation, zero while the
+	// violation is open.
+	Resolved time.Time
+}
+
+// Query restricts the records returned by QueryOpenViolations. Empty fields match all records.
+type Query struct {
+	// Constraint matches records of the constraint in Kind.name format.
+	Constraint string
+	// ResourcePrefix matches records whose resource starts with the prefix.
+	ResourcePrefix string
+}
+
+// Store records runs and the lifecycle of their violations. Implementations are safe for
+// concurrent use.
+type Store interface {
+	// SaveRun records run. A run must be saved before its violations.
+	SaveRun(ctx context.Context, run *Run) error
+	// SaveViolations records violations found by the run runID. Violations not seen before, or
+	// resolved since, are opened with the run as their first run. Open violations get the run as
+	// their last run. SaveViolations may be called several times per run.
+	SaveViolations(ctx context.Context, runID string, violations []*Violation) error
+	// QueryOpenViolations returns the open violations matching q, ordered by when they were first
+	// seen, then by fingerprint.
+	QueryOpenViolations(ctx context.Context, q Query) ([]*Record, error)
+	// ResolveMissing resolves the open violations the run runID did not find, as of the start of
+	// the run, and returns how many it resolved. It must only be called once all violations of the
+	// run were saved, and only for runs reviewing all assets: the violations of assets left out
+	// of a run, such as those of other shards, are resolved as well.
+	ResolveMissing(ctx context.Context, runID string) (int, error)
+	// Close releases the resources of the store.
+	Close() error
+}
+
+// Track saves run and its violations to s and resolves the open violations the run did not find.
+// It returns the number of resolved violations.
+func Track(ctx context.Context, s Store, run *Run, violations []*Violation) (int, error) {
+	if err := s.SaveRun(ctx, run); err != nil {
+		return 0, err
+	}
+	if err := s.SaveViolations(ctx, run.ID, violations); err != nil {
+		return 0, err
+	}
+	return s.ResolveMissing(ctx, run.ID)
+}
+
+// NewViolations returns the violations of results.
+func NewViolations(results []*gcv.Result) []*Violation {
+	var violations []*Violation
+	for _, result := range results {
+		for idx := range result.ConstraintViolations {
+			cv := &result.ConstraintViolations[idx]
+			violations = append(violations, &Violation{
+				Fingerprint: cv.Fingerprint(result.Name),
+				Constraint:  cv.ConstraintName(),
+				Resource:    result.Name,
+				Severity:    cv.Severity,
+				Message:     cv.Message,
+			})
+		}
+	}
+	return violations
+}
+
+// ReportViolations returns the violations of r, such as a report written by gcv review.
+func ReportViolations(r *report.Report) []*Violation {
+	violations := make([]*Violation, len(r.Violations))
+	for idx, v := range r.Violations {
+		violations[idx] = &Violation{
+			Fingerprint: v.Fingerprint(),
+			Constraint:  v.Constraint,
+			Resource:    v.Resource,
+			Severity:    v.Severity,
+			Message:     v.Message,
+		}
+	}
+	return violations
+}
@@ -0,0 +1,56 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"testing"
+
+	"github.com/forseti-security/config-validator/pkg/gcv"
+	"github.com/forseti-security/config-validator/pkg/report"
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestNewViolations(t *testing.T) {
+	constraint := &unstructured.Unstructured{}
+	constraint.SetKind("GCPStorageLoggingConstraint")
+	constraint.SetName("require-storage-logging")
+	results := []*gcv.Result{
+		{
+			Name: "//storage.googleapis.com/b",
+			ConstraintViolations: []gcv.ConstraintViolation{
+				{Message: "no logging", Constraint: constraint, Severity: "medium"},
+			},
+		},
+		{Name: "//storage.googleapis.com/c"},
+	}
+	got := NewViolations(results)
+	want := []*Violation{
+		{
+			Fingerprint: results[0].ConstraintViolations[0].Fingerprint("//storage.googleapis.com/b"),
+			Constraint:  "GCPStorageLoggingConstraint.require-storage-logging",
+			Resource:    "//storage.googleapis.com/b",
+			Severity:    "medium",
+			Message:     "no logging",
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected violations (-want +got):\n%s", diff)
+	}
+	// Violations read from reports are tracked alike.
+	if diff := cmp.Diff(want, ReportViolations(report.FromResults(results))); diff != "" {
+		t.Errorf("unexpected report violations (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tlsconfig builds TLS configurations for the validator server whose certificates are
+// reloaded from disk when the underlying files change.
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// ServerOptions describes the files used to configure server side TLS.
+type ServerOptions struct {
+	// CertFile is the PEM encoded server certificate chain.
+	CertFile string
+	// KeyFile is the PEM encoded private key for CertFile.
+	KeyFile string
+	// ClientCAFile is an optional PEM bundle of CAs used to verify client certificates.
+	ClientCAFile string
+	// RequireClientCert rejects connections that do not present a client certificate signed by
+	// one of the CAs in ClientCAFile.
+	RequireClientCert bool
+}
+
+// NewServerConfig returns a tls.Config for the server. The certificate, key and client CA bundle
+// are checked for modification on each handshake and reloaded if they changed, so rotating
+// certificates does not require a restart. A failed reload keeps serving the previous material.
+func NewServerConfig(opts ServerOptions) (*tls.Config, error) {
+	if opts.CertFile == "" || opts.KeyFile == "" {
+		return nil, errors.Errorf("both certificate and key files must be set")
+	}
+	if opts.RequireClientCert && opts.ClientCAFile == "" {
+		return nil, errors.Errorf("client certificates required but no client CA file set")
+	}
+
+	r := &reloader{opts: opts}
+	if err := r.maybeReload(); err != nil {
+		return nil, err
+	}
+
+	base := &tls.Config{MinVersion: tls.VersionTLS12}
+	base.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		if err := r.maybeReload(); err != nil {
+			zap.L().Error("failed to reload TLS material, continuing with previous", zap.Error(err))
+		}
+		return r.config(), nil
+	}
+	return base, nil
+}
+
+// reloader caches the loaded TLS material along with modification times of the source files.
+type reloader struct {
+	opts ServerOptions
+
+	mu      sync.RWMutex
+	modTime map[string]time.Time
+	cfg     *tls.Config
+}
+
+func (r *reloader) files() []string {
+	files := []string{r.opts.CertFile, r.opts.KeyFile}
+	if r.opts.ClientCAFile != "" {
+		files = append(files, r.opts.ClientCAFile)
+	}
+	return files
+}
+
+// maybeReload reloads the TLS material if any of the files were modified since the last load.
+func (r *reloader) maybeReload() error {
+	modTime := map[string]time.Time{}
+	for _, f := range r.files() {
+		info, err := os.Stat(f)
+		if err != nil {
+			return errors.Wrapf(err, "failed to stat %s", f)
+		}
+		modTime[f] = info.ModTime()
+	}
+
+	r.mu.RLock()
+	changed := r.cfg == nil
+	for f, t := range modTime {
+		if !r.modTime[f].Equal(t) {
+			changed = true
+		}
+	}
+	r.mu.RUnlock()
+	if !changed {
+		return nil
+	}
+
+	cfg, err := r.load()
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cfg = cfg
+	r.modTime = modTime
+	zap.L().Info("loaded TLS certificate", zap.String("file", r.opts.CertFile))
+	return nil
+}
+
+func (r *reloader) load() (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(r.opts.CertFile, r.opts.KeyFile)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load key pair %s %s", r.opts.CertFile, r.opts.KeyFile)
+	}
+	cfg := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+		// This config replaces the one grpc credentials would otherwise populate with ALPN.
+		NextProtos: []string{"h2"},
+	}
+	if r.opts.ClientCAFile == "" {
+		return cfg, nil
+	}
+
+	caBytes, err := ioutil.ReadFile(r.opts.ClientCAFile)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read client CA file %s", r.opts.ClientCAFile)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caBytes) {
+		return nil, errors.Errorf("no certificates found in client CA file %s", r.opts.ClientCAFile)
+	}
+	cfg.ClientCAs = pool
+	cfg.ClientAuth = tls.VerifyClientCertIfGiven
+	if r.opts.RequireClientCert {
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return cfg, nil
+}
+
+func (r *reloader) config() *tls.Config {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cfg
+}
@@ -0,0 +1,209 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+// newCert creates a certificate with the given common name, signed by parent (self signed if nil).
+func newCert(t *testing.T, cn string, serial int64, parent *testCert) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	signer, signerKey := tmpl, key
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+func writeFile(t *testing.T, path string, content []byte, modTime time.Time) {
+	if err := ioutil.WriteFile(path, content, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// handshake dials the listener and returns the serial of the server certificate.
+func handshake(lis net.Listener, roots *x509.CertPool, client *testCert) (int64, error) {
+	cfg := &tls.Config{RootCAs: roots}
+	if client != nil {
+		cfg.Certificates = []tls.Certificate{{
+			Certificate: [][]byte{client.cert.Raw},
+			PrivateKey:  client.key,
+		}}
+	}
+	conn, err := tls.Dial("tcp", lis.Addr().String(), cfg)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	// Client certificate failures surface on first read with TLS 1.3.
+	if _, err := conn.Read(make([]byte, 1)); err != nil {
+		return 0, err
+	}
+	return conn.ConnectionState().PeerCertificates[0].SerialNumber.Int64(), nil
+}
+
+func TestServerConfig(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "tlsconfigTest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	ca := newCert(t, "ca", 1, nil)
+	server := newCert(t, "server", 2, ca)
+	client := newCert(t, "client", 3, ca)
+	opts := ServerOptions{
+		CertFile:          filepath.Join(tmpDir, "server.crt"),
+		KeyFile:           filepath.Join(tmpDir, "server.key"),
+		ClientCAFile:      filepath.Join(tmpDir, "ca.crt"),
+		RequireClientCert: true,
+	}
+	start := time.Now().Add(-time.Minute)
+	writeFile(t, opts.CertFile, server.certPEM, start)
+	writeFile(t, opts.KeyFile, server.keyPEM, start)
+	writeFile(t, opts.ClientCAFile, ca.certPEM, start)
+
+	cfg, err := NewServerConfig(opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	lis, err := tls.Listen("tcp", "127.0.0.1:0", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			go func(c net.Conn) {
+				defer c.Close()
+				if tlsConn, ok := c.(*tls.Conn); ok && tlsConn.Handshake() == nil {
+					_, _ = c.Write([]byte("x"))
+				}
+			}(conn)
+		}
+	}()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	if _, err := handshake(lis, roots, nil); err == nil {
+		t.Errorf("expected error connecting without client certificate")
+	}
+	serial, err := handshake(lis, roots, client)
+	if err != nil {
+		t.Fatalf("unexpected error connecting with client certificate: %v", err)
+	}
+	if serial != 2 {
+		t.Errorf("got server cert serial %d, want 2", serial)
+	}
+
+	rotated := newCert(t, "server", 4, ca)
+	writeFile(t, opts.CertFile, rotated.certPEM, time.Now())
+	writeFile(t, opts.KeyFile, rotated.keyPEM, time.Now())
+	serial, err = handshake(lis, roots, client)
+	if err != nil {
+		t.Fatalf("unexpected error after rotation: %v", err)
+	}
+	if serial != 4 {
+		t.Errorf("got server cert serial %d after rotation, want 4", serial)
+	}
+}
+
+func TestServerConfigErrors(t *testing.T) {
+	var testCases = []struct {
+		name string
+		opts ServerOptions
+	}{
+		{
+			name: "missing key",
+			opts: ServerOptions{CertFile: "server.crt"},
+		},
+		{
+			name: "client cert required without CA",
+			opts: ServerOptions{CertFile: "server.crt", KeyFile: "server.key", RequireClientCert: true},
+		},
+		{
+			name: "files do not exist",
+			opts: ServerOptions{CertFile: "/does/not/exist.crt", KeyFile: "/does/not/exist.key"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := NewServerConfig(tc.opts); err == nil {
+				t.Errorf("expected error")
+			}
+		})
+	}
+}
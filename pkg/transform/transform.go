@@ -0,0 +1,247 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package transform applies CEL expressions configured in YAML to CAI assets before they are
+// reviewed, allowing light normalization of asset content without code changes.
+//
+// A transform file looks like:
+//
+//   transforms:
+//   - assetType: storage.googleapis.com/Bucket
+//     set:
+//     - field: resource.data.locationUpper
+//       expression: asset.resource.data.location
+//     delete:
+//     - resource.data.legacyField
+//
+// Expressions are evaluated against the original asset bound to the variable "asset", so the
+// order of set entries does not matter. Fields listed in delete are removed after all set
+// entries have been applied, which together with set allows renaming a field. An assetType of
+// "*" applies to all assets.
+package transform
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/forseti-security/config-validator/pkg/internal/celjson"
+	"github.com/forseti-security/config-validator/pkg/multierror"
+	"github.com/ghodss/yaml"
+	structpb "github.com/golang/protobuf/ptypes/struct"
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/checker/decls"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// AllAssetTypes is the asset type matching every asset.
+const AllAssetTypes = "*"
+
+// assetVar is the name of the CEL variable holding the asset.
+const assetVar = "asset"
+
+// Config is the YAML representation of a set of transforms.
+type Config struct {
+	Transforms []TransformConfig `json:"transforms"`
+}
+
+// TransformConfig is the set of field changes applied to assets of one type.
+type TransformConfig struct {
+	// AssetType is the CAI asset type the transform applies to, or AllAssetTypes.
+	AssetType string `json:"assetType"`
+	// Set assigns the result of an expression to a field.
+	Set []SetConfig `json:"set,omitempty"`
+	// Delete lists fields that are removed from the asset.
+	Delete []string `json:"delete,omitempty"`
+}
+
+// SetConfig assigns the result of a CEL expression to a dot separated field path.
+type SetConfig struct {
+	Field      string `json:"field"`
+	Expression string `json:"expression"`
+}
+
+type setter struct {
+	field   []string
+	source  string
+	program cel.Program
+}
+
+type transform struct {
+	setters []setter
+	deletes [][]string
+}
+
+// Transformer applies compiled transforms to assets.
+type Transformer struct {
+	byType map[string][]*transform
+}
+
+// Load reads and compiles a transform file.
+func Load(path string) (*Transformer, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read transforms %s", path)
+	}
+	t, err := Parse(data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid transforms %s", path)
+	}
+	return t, nil
+}
+
+// Parse compiles the YAML encoded transforms in data.
+func Parse(data []byte) (*Transformer, error) {
+	var config Config
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse transforms")
+	}
+	return New(config)
+}
+
+// New compiles the transforms in config. All invalid expressions are reported together.
+func New(config Config) (*Transformer, error) {
+	env, err := cel.NewEnv(cel.Declarations(decls.NewIdent(assetVar, decls.Dyn, nil)))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create CEL environment")
+	}
+
+	t := &Transformer{byType: map[string][]*transform{}}
+	var errs multierror.Errors
+	for idx, tc := range config.Transforms {
+		if tc.AssetType == "" {
+			errs.Add(errors.Errorf("transform %d: assetType must be set", idx))
+			continue
+		}
+		compiled := &transform{}
+		for _, set := range tc.Set {
+			field, err := fieldPath(set.Field)
+			if err != nil {
+				errs.Add(errors.Wrapf(err, "transform %d (%s)", idx, tc.AssetType))
+				continue
+			}
+			ast, issues := env.Compile(set.Expression)
+			if issues != nil && issues.Err() != nil {
+				errs.Add(errors.Errorf("transform %d (%s) field %s: %s", idx, tc.AssetType, set.Field, issues.Err()))
+				continue
+			}
+			program, err := env.Program(ast)
+			if err != nil {
+				errs.Add(errors.Wrapf(err, "transform %d (%s) field %s", idx, tc.AssetType, set.Field))
+				continue
+			}
+			compiled.setters = append(compiled.setters, setter{field: field, source: set.Expression, program: program})
+		}
+		for _, del := range tc.Delete {
+			field, err := fieldPath(del)
+			if err != nil {
+				errs.Add(errors.Wrapf(err, "transform %d (%s)", idx, tc.AssetType))
+				continue
+			}
+			compiled.deletes = append(compiled.deletes, field)
+		}
+		t.byType[tc.AssetType] = append(t.byType[tc.AssetType], compiled)
+	}
+	if !errs.Empty() {
+		return nil, errs.ToError()
+	}
+	return t, nil
+}
+
+func fieldPath(field string) ([]string, error) {
+	path := strings.Split(field, ".")
+	for _, part := range path {
+		if part == "" {
+			return nil, errors.Errorf("invalid field path %q", field)
+		}
+	}
+	return path, nil
+}
+
+// Apply returns a transformed copy of asset. The asset is returned unchanged if no transform
+// applies to its type.
+func (t *Transformer) Apply(asset map[string]interface{}) (map[string]interface{}, error) {
+	assetType, _, _ := unstructured.NestedString(asset, "asset_type")
+	transforms := append(append([]*transform(nil), t.byType[AllAssetTypes]...), t.byType[assetType]...)
+	if len(transforms) == 0 {
+		return asset, nil
+	}
+
+	input := map[string]interface{}{assetVar: celjson.Value(asset)}
+	result, err := deepCopyJSON(asset)
+	if err != nil {
+		return nil, err
+	}
+	for _, tr := range transforms {
+		for _, s := range tr.setters {
+			out, _, err := s.program.Eval(input)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to evaluate %q for %s", s.source, strings.Join(s.field, "."))
+			}
+			native, err := out.ConvertToNative(reflect.TypeOf(&structpb.Value{}))
+			if err != nil {
+				return nil, errors.Wrapf(err, "result of %q is not a JSON value", s.source)
+			}
+			if err := unstructured.SetNestedField(result, jsonValue(native.(*structpb.Value)), s.field...); err != nil {
+				return nil, errors.Wrapf(err, "failed to set %s", strings.Join(s.field, "."))
+			}
+		}
+		for _, field := range tr.deletes {
+			unstructured.RemoveNestedField(result, field...)
+		}
+	}
+	return result, nil
+}
+
+// deepCopyJSON returns a deep copy of asset, or an error if asset holds values other than those
+// decoded from JSON, which runtime.DeepCopyJSON panics on. Assets may come from enrichers and
+// callers of the review APIs rather than from decoding.
+func deepCopyJSON(asset map[string]interface{}) (result map[string]interface{}, err error) {
+	defer func() {
+		if x := recover(); x != nil {
+			result = nil
+			err = errors.Errorf("asset is not a JSON value: %v", x)
+		}
+	}()
+	return runtime.DeepCopyJSON(asset), nil
+}
+
+// jsonValue converts an expression result to the representation used for decoded assets.
+func jsonValue(v *structpb.Value) interface{} {
+	switch kind := v.Kind.(type) {
+	case *structpb.Value_BoolValue:
+		return kind.BoolValue
+	case *structpb.Value_StringValue:
+		return kind.StringValue
+	case *structpb.Value_NumberValue:
+		return json.Number(strconv.FormatFloat(kind.NumberValue, 'f', -1, 64))
+	case *structpb.Value_StructValue:
+		m := make(map[string]interface{}, len(kind.StructValue.Fields))
+		for key, value := range kind.StructValue.Fields {
+			m[key] = jsonValue(value)
+		}
+		return m
+	case *structpb.Value_ListValue:
+		l := make([]interface{}, len(kind.ListValue.Values))
+		for idx, value := range kind.ListValue.Values {
+			l[idx] = jsonValue(value)
+		}
+		return l
+	}
+	return nil
+}
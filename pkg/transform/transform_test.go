@@ -0,0 +1,161 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transform
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const testTransforms = `
+transforms:
+- assetType: storage.googleapis.com/Bucket
+  set:
+  - field: resource.data.logging
+    expression: '{"logBucket": asset.resource.data.legacyLogBucket}'
+  - field: resource.data.sizeGB
+    expression: asset.resource.data.sizeBytes / 1000000000
+  - field: resource.data.public
+    expression: asset.resource.data.acl.exists(a, a == "allUsers")
+  delete:
+  - resource.data.legacyLogBucket
+- assetType: "*"
+  set:
+  - field: normalized
+    expression: "true"
+`
+
+func mustUnmarshal(t *testing.T, data string) map[string]interface{} {
+	asset := map[string]interface{}{}
+	decoder := json.NewDecoder(strings.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&asset); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	return asset
+}
+
+func TestApply(t *testing.T) {
+	transformer, err := Parse([]byte(testTransforms))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var testCases = []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name: "bucket",
+			input: `{"asset_type": "storage.googleapis.com/Bucket", "resource": {"data": {
+				"legacyLogBucket": "logs", "sizeBytes": 5000000000, "acl": ["allUsers"]}}}`,
+			want: `{"asset_type": "storage.googleapis.com/Bucket", "normalized": true, "resource": {"data": {
+				"logging": {"logBucket": "logs"}, "sizeBytes": 5000000000, "sizeGB": 5, "public": true,
+				"acl": ["allUsers"]}}}`,
+		},
+		{
+			name:  "other type",
+			input: `{"asset_type": "compute.googleapis.com/Instance", "resource": {"data": {}}}`,
+			want:  `{"asset_type": "compute.googleapis.com/Instance", "normalized": true, "resource": {"data": {}}}`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			input := mustUnmarshal(t, tc.input)
+			original := mustUnmarshal(t, tc.input)
+			got, err := transformer.Apply(input)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(got, mustUnmarshal(t, tc.want)); diff != "" {
+				t.Errorf("transformed asset mismatch, +got -want\n%s", diff)
+			}
+			if diff := cmp.Diff(input, original); diff != "" {
+				t.Errorf("input asset was modified, +got -want\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestApplyEvalError(t *testing.T) {
+	transformer, err := Parse([]byte(testTransforms))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	input := mustUnmarshal(t, `{"asset_type": "storage.googleapis.com/Bucket", "resource": {"data": {}}}`)
+	if _, err := transformer.Apply(input); err == nil {
+		t.Errorf("expected error for missing field")
+	}
+}
+
+func TestApplyNonJSONValue(t *testing.T) {
+	transformer, err := Parse([]byte(testTransforms))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	input := map[string]interface{}{
+		"asset_type": "storage.googleapis.com/Bucket",
+		"resource": map[string]interface{}{
+			"data": map[string]interface{}{"legacyLogBucket": "logs", "sizeBytes": 1, "acl": []string{"allUsers"}},
+		},
+	}
+	if _, err := transformer.Apply(input); err == nil {
+		t.Errorf("expected error for non-JSON value")
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	var testCases = []struct {
+		name   string
+		config string
+	}{
+		{name: "invalid yaml", config: "transforms: ["},
+		{name: "missing asset type", config: "transforms: [{set: [{field: a, expression: '1'}]}]"},
+		{name: "invalid expression", config: "transforms: [{assetType: '*', set: [{field: a, expression: 'asset.'}]}]"},
+		{name: "invalid field", config: "transforms: [{assetType: '*', delete: ['a..b']}]"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := Parse([]byte(tc.config)); err == nil {
+				t.Errorf("expected error")
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "transformTest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	path := filepath.Join(tmpDir, "transforms.yaml")
+	if err := ioutil.WriteFile(path, []byte(testTransforms), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := Load(filepath.Join(tmpDir, "missing.yaml")); err == nil {
+		t.Errorf("expected error for missing file")
+	}
+}